@@ -0,0 +1,308 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Access message acknowledgments. Access messages are published
+// fire-and-forget by default, so a message rejected by fga-sync goes
+// unnoticed. With ACCESS_ACK_TIMEOUT set, they are sent as NATS requests
+// instead, and the fga-sync reply is checked: an empty or "OK" reply
+// acknowledges the message, and any other reply rejects it. A rejected
+// message is sent once more with a corrected payload when one can be derived
+// (blank and duplicate list entries dropped, strings trimmed); messages still
+// rejected are recorded as access failures of their v1 record, in the
+// mappings bucket and on /statusz, until an access message of the record is
+// acknowledged. Requests without a reply count as failed publishes, so their
+// KV message is retried and its content is not recorded as synced.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// accessFailureKeyPrefix is the mappings key prefix of the access failures
+// of v1 records, followed by the v1-objects key.
+const accessFailureKeyPrefix = "v1_access_failures."
+
+var accessAcks = newCounterVec(
+	"v1_sync_helper_access_acks_total",
+	"Number of access messages sent as requests to fga-sync, by object type and result (acknowledged, corrected, rejected, or unacknowledged).",
+	"object_type", "result",
+)
+
+// accessFailure is an access message of a v1 record rejected by fga-sync.
+type accessFailure struct {
+	Key      string    `json:"key"`
+	Subject  string    `json:"subject"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+var (
+	// accessFailuresMu guards accessFailures.
+	accessFailuresMu sync.Mutex
+	// accessFailures are the access failures seen by this replica, by v1
+	// key, until an access message of the record is acknowledged.
+	accessFailures = map[string]accessFailure{}
+)
+
+// accessRecordKeyContextKey is the context key of the v1 key of the record
+// whose access messages are sent.
+type accessRecordKeyContextKey struct{}
+
+// withAccessRecordKey returns a context carrying the v1 key of the record
+// being handled, so its access failures are recorded against it.
+func withAccessRecordKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, accessRecordKeyContextKey{}, key)
+}
+
+// contextAccessRecordKey returns the v1 key carried by the context, if any.
+func contextAccessRecordKey(ctx context.Context) string {
+	key, _ := ctx.Value(accessRecordKeyContextKey{}).(string)
+	return key
+}
+
+// accessAckEnabled reports whether access messages are sent as requests.
+// Dry runs always publish them, so they are recorded rather than sent.
+func accessAckEnabled(ctx context.Context) bool {
+	return cfg.AccessAckTimeout > 0 && contextDryRun(ctx) == nil
+}
+
+// requestAccessMessage sends an access message as a request to fga-sync, and
+// retries it once with a corrected payload if it is rejected. It returns an
+// error if the message is not acknowledged.
+func requestAccessMessage(ctx context.Context, subject string, data []byte) error {
+	key := contextAccessRecordKey(ctx)
+	objectType := kvObjectType(key)
+
+	rejection, err := requestAccessAck(ctx, subject, data)
+	if err != nil {
+		accessAcks.inc(objectType, "unacknowledged")
+		recordPublishFailure(ctx)
+		return err
+	}
+	result := "acknowledged"
+	if rejection != "" {
+		corrected, ok := correctAccessPayload(data)
+		if !ok {
+			return rejectAccessMessage(ctx, key, subject, rejection)
+		}
+		logger.With("subject", subject, "key", key, "rejection", rejection).WarnContext(ctx, "access message rejected, retrying with a corrected payload")
+		if rejection, err = requestAccessAck(ctx, subject, corrected); err != nil {
+			accessAcks.inc(objectType, "unacknowledged")
+			recordPublishFailure(ctx)
+			return err
+		}
+		if rejection != "" {
+			return rejectAccessMessage(ctx, key, subject, rejection)
+		}
+		result = "corrected"
+	}
+
+	accessAcks.inc(objectType, result)
+	recordPublished(ctx)
+	clearAccessFailure(ctx, key)
+	return nil
+}
+
+// requestAccessAck sends an access message as a request, and returns the
+// rejection of fga-sync, or an empty string if it was acknowledged.
+func requestAccessAck(ctx context.Context, subject string, data []byte) (string, error) {
+	if err := checkPublishSubject("request", subject); err != nil {
+		return "", err
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, cfg.AccessAckTimeout)
+	defer cancel()
+
+	msg := &nats.Msg{Subject: subject, Data: data}
+	bootstrap.SetCorrelationHeader(ctx, msg)
+	signMessage(msg)
+	reply, err := natsConn.RequestMsgWithContext(requestCtx, msg)
+	if err != nil {
+		return "", fmt.Errorf("no acknowledgment of access message: %w", err)
+	}
+	rejection := accessReplyRejection(reply)
+	if rejection == "" {
+		fanOutMessage(msg)
+		sampleSchema(subject, data)
+	}
+	return rejection, nil
+}
+
+// accessReplyRejection returns the rejection of an fga-sync reply, or an
+// empty string for an acknowledgment (an empty, "OK", or JSON reply without
+// an error).
+func accessReplyRejection(reply *nats.Msg) string {
+	body := strings.TrimSpace(string(reply.Data))
+	if body == "" || strings.EqualFold(body, "ok") {
+		return ""
+	}
+	var result struct {
+		Error   string `json:"error"`
+		Success *bool  `json:"success"`
+	}
+	if err := json.Unmarshal(reply.Data, &result); err == nil {
+		switch {
+		case result.Error != "":
+			return result.Error
+		case result.Success != nil && !*result.Success:
+			return body
+		}
+		return ""
+	}
+	return body
+}
+
+// correctAccessPayload returns a corrected access message payload, with
+// strings trimmed and blank or duplicate list entries dropped, and whether it
+// differs from the original one.
+func correctAccessPayload(data []byte) ([]byte, bool) {
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, false
+	}
+	changed := false
+	for field, value := range payload {
+		corrected := correctAccessValue(value)
+		if !jsonEqual(value, corrected) {
+			payload[field] = corrected
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	corrected, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false
+	}
+	return corrected, true
+}
+
+// correctAccessValue returns a corrected access message field value.
+func correctAccessValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []any:
+		corrected := make([]any, 0, len(v))
+		seen := map[string]bool{}
+		for _, item := range v {
+			item = correctAccessValue(item)
+			if s, ok := item.(string); ok {
+				if s == "" || seen[s] {
+					continue
+				}
+				seen[s] = true
+			}
+			if item == nil {
+				continue
+			}
+			corrected = append(corrected, item)
+		}
+		return corrected
+	case map[string]any:
+		corrected := make(map[string]any, len(v))
+		for field, item := range v {
+			corrected[field] = correctAccessValue(item)
+		}
+		return corrected
+	}
+	return value
+}
+
+// jsonEqual reports whether two decoded JSON values are equal.
+func jsonEqual(a, b any) bool {
+	aBytes, aErr := json.Marshal(a)
+	bBytes, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aBytes) == string(bBytes)
+}
+
+// rejectAccessMessage records the access failure of a v1 record, and returns
+// the rejection as an error.
+func rejectAccessMessage(ctx context.Context, key, subject, rejection string) error {
+	accessAcks.inc(kvObjectType(key), "rejected")
+	if key != "" {
+		failure := accessFailure{Key: key, Subject: subject, Error: rejection, FailedAt: bootstrap.Now().UTC()}
+		accessFailuresMu.Lock()
+		accessFailures[key] = failure
+		accessFailuresMu.Unlock()
+
+		if value, err := json.Marshal(failure); err == nil {
+			if _, err := mappingsKV.Put(ctx, accessFailureKeyPrefix+key, value); err != nil {
+				logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to record access failure")
+			}
+		}
+	}
+	return fmt.Errorf("access message rejected by fga-sync: %s", rejection)
+}
+
+// clearAccessFailure forgets the access failure of a v1 record, once an
+// access message of it is acknowledged.
+func clearAccessFailure(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	accessFailuresMu.Lock()
+	_, failed := accessFailures[key]
+	delete(accessFailures, key)
+	accessFailuresMu.Unlock()
+
+	// Failures recorded by other replicas (or before a restart) are not
+	// known here, so the mappings key is checked too.
+	if !failed {
+		if _, err := mappingsKV.Get(ctx, accessFailureKeyPrefix+key); err != nil {
+			if !errors.Is(err, jetstream.ErrKeyNotFound) {
+				logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to get access failure")
+			}
+			return
+		}
+	}
+	if err := mappingsKV.Purge(ctx, accessFailureKeyPrefix+key); err != nil {
+		logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to clear access failure")
+	}
+}
+
+// accessFailuresStatus is the access acknowledgment status on /statusz.
+type accessFailuresStatus struct {
+	Enabled  bool                         `json:"enabled"`
+	Results  map[string]map[string]uint64 `json:"results"`
+	Failures []accessFailure              `json:"failures"`
+}
+
+// accessStatus returns the access acknowledgment results by object type, and
+// the current access failures seen by this replica, by key.
+func accessStatus() accessFailuresStatus {
+	status := accessFailuresStatus{
+		Enabled:  cfg.AccessAckTimeout > 0,
+		Results:  map[string]map[string]uint64{},
+		Failures: []accessFailure{},
+	}
+	// Samples are labeled by object type and result.
+	for _, sample := range accessAcks.samples() {
+		objectType, result := sample.labelValues[0], sample.labelValues[1]
+		if status.Results[objectType] == nil {
+			status.Results[objectType] = map[string]uint64{}
+		}
+		status.Results[objectType][result] = sample.value
+	}
+
+	accessFailuresMu.Lock()
+	defer accessFailuresMu.Unlock()
+	for _, key := range slices.Sorted(maps.Keys(accessFailures)) {
+		status.Failures = append(status.Failures, accessFailures[key])
+	}
+	return status
+}
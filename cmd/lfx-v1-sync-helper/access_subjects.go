@@ -0,0 +1,199 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Project-sharded access subjects. With ACCESS_SUBJECT_SHARDS set, access
+// messages (the subjects sent to fga-sync) are published with a suffix
+// identifying the shard of the project the record belongs to, e.g.
+// lfx.update_access.v1_meeting.7, so fga-sync can shard its processing by
+// project while keeping the messages of a project in order. Records whose
+// project cannot be resolved use the "unassigned" suffix. Without it, the
+// flat subjects are used. The project of each synced record is kept in the
+// mappings bucket under accessProjectKeyPrefix, so the access messages of its
+// hard delete, for which the record is no longer available, keep its shard.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// unassignedAccessShard is the subject suffix of access messages of records
+// whose project cannot be resolved.
+const unassignedAccessShard = "unassigned"
+
+// accessProjectKeyPrefix prefixes the mappings KV keys of the v2 project UIDs
+// of synced records, followed by their v1-objects key.
+const accessProjectKeyPrefix = "v1_access_projects."
+
+// accessParentFields are the v1 record fields referencing the parent record
+// of a record that does not reference its project directly, in order of
+// precedence, with the key prefix of the parent record.
+var accessParentFields = []struct {
+	field  string
+	prefix string
+}{
+	{"meeting_and_occurrence_id", "itx-zoom-past-meetings"},
+	{"meeting_id", "itx-zoom-meetings-v2"},
+}
+
+// accessSubjects are the subjects suffixed with a project shard when access
+// subject sharding is enabled.
+func accessSubjects() []string {
+	return []string{
+		UpdateAccessV1MeetingSubject,
+		V1MeetingRegistrantPutSubject,
+		V1MeetingRegistrantRemoveSubject,
+		V1MeetingRegistrantHostPromoteSubject,
+		V1MeetingRegistrantHostDemoteSubject,
+		DeleteAllAccessV1MeetingSubject,
+		DeleteAllAccessV1PastMeetingSubject,
+		V1PastMeetingUpdateAccessSubject,
+		V1PastMeetingParticipantPutSubject,
+		V1PastMeetingParticipantRemoveSubject,
+		V1PastMeetingRecordingUpdateAccessSubject,
+		V1PastMeetingTranscriptUpdateAccessSubject,
+		V1PastMeetingSummaryUpdateAccessSubject,
+		UpdateAccessSubject,
+	}
+}
+
+// accessProjectContextKey is the context key of the v2 project UID of the
+// record being handled.
+type accessProjectContextKey struct{}
+
+// withRecordAccessProject returns a context carrying the v2 project UID of a
+// v1 record, for sharding its access subjects, falling back to the project
+// stored when the record was last synced, e.g. for hard deletes. The project
+// is only resolved when sharding is enabled.
+func withRecordAccessProject(ctx context.Context, key string, v1Data map[string]any) context.Context {
+	if cfg.AccessSubjectShards <= 0 {
+		return ctx
+	}
+	projectUID := recordProjectUID(ctx, v1Data)
+	if projectUID == "" {
+		projectUID = storedAccessProject(ctx, key)
+	}
+	return context.WithValue(ctx, accessProjectContextKey{}, projectUID)
+}
+
+// storedAccessProject returns the v2 project UID stored for a v1 record when
+// it was last synced, or an empty string if none is.
+func storedAccessProject(ctx context.Context, key string) string {
+	entry, err := mappingsKV.Get(ctx, accessProjectKeyPrefix+key)
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to get record access project")
+		}
+		return ""
+	}
+	return string(entry.Value())
+}
+
+// storeAccessProject stores the v2 project UID carried by the context as the
+// project of a synced v1 record, unless it is unresolved or already stored.
+func storeAccessProject(ctx context.Context, key string) {
+	projectUID := contextAccessProject(ctx)
+	if cfg.AccessSubjectShards <= 0 || projectUID == "" || contextDryRun(ctx) != nil {
+		return
+	}
+	if storedAccessProject(ctx, key) == projectUID {
+		return
+	}
+	if _, err := mappingsKV.Put(ctx, accessProjectKeyPrefix+key, []byte(projectUID)); err != nil {
+		logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to store record access project")
+	}
+}
+
+// forgetAccessProject removes the v2 project UID stored for a deleted v1
+// record, if any.
+func forgetAccessProject(ctx context.Context, key string) {
+	if cfg.AccessSubjectShards <= 0 || contextDryRun(ctx) != nil {
+		return
+	}
+	// Deleting a missing key would still write a delete marker.
+	if storedAccessProject(ctx, key) == "" {
+		return
+	}
+	if err := mappingsKV.Delete(ctx, accessProjectKeyPrefix+key); err != nil && !errors.Is(err, jetstream.ErrKeyNotFound) {
+		logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to delete record access project")
+	}
+}
+
+// contextAccessProject returns the v2 project UID carried by the context.
+func contextAccessProject(ctx context.Context) string {
+	projectUID, _ := ctx.Value(accessProjectContextKey{}).(string)
+	return projectUID
+}
+
+// recordProjectUID returns the v2 project UID of a v1 record, looking up its
+// parent meeting or past meeting for records that do not reference a project
+// directly. Returns an empty string if it cannot be resolved.
+func recordProjectUID(ctx context.Context, v1Data map[string]any) string {
+	projectSFID := recordProjectSFID(ctx, v1Data)
+	if projectSFID == "" {
+		return ""
+	}
+
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf("project.sfid.%s", projectSFID))
+	if err != nil || isTombstonedMapping(entry.Value()) {
+		return ""
+	}
+	return string(entry.Value())
+}
+
+// recordProjectSFID returns the v1 project SFID of a v1 record, looking up its
+// parent meeting or past meeting for records that do not reference a project
+// directly. Returns an empty string if it cannot be resolved.
+func recordProjectSFID(ctx context.Context, v1Data map[string]any) string {
+	if v1Data == nil {
+		return ""
+	}
+	projectSFID := extractProjectSFID(v1Data)
+	if projectSFID == "" {
+		for _, parent := range accessParentFields {
+			parentID := v1FieldString(v1Data, parent.field)
+			if parentID == "" {
+				continue
+			}
+			parentData, exists, err := getV1ObjectData(ctx, fmt.Sprintf("%s.%s", parent.prefix, parentID))
+			if err != nil {
+				logger.With(errKey, err, parent.field, parentID).WarnContext(ctx, "failed to get parent record to resolve record project")
+				return ""
+			}
+			if exists {
+				projectSFID = extractProjectSFID(parentData)
+			}
+			break
+		}
+	}
+	return projectSFID
+}
+
+// accessSubject returns the subject to publish an access message to, sharded
+// by the project carried by the context when sharding is enabled.
+func accessSubject(ctx context.Context, subject string) string {
+	return shardedAccessSubject(subject, contextAccessProject(ctx))
+}
+
+// shardedAccessSubject returns the subject to publish an access message of
+// the given v2 project to, suffixed with the project's shard when sharding is
+// enabled.
+func shardedAccessSubject(subject, projectUID string) string {
+	shards := cfg.AccessSubjectShards
+	if shards <= 0 {
+		return subject
+	}
+	if projectUID == "" {
+		return subject + "." + unassignedAccessShard
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(projectUID))
+	return subject + "." + strconv.FormatUint(uint64(h.Sum32()%uint32(shards)), 10)
+}
@@ -0,0 +1,83 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/testkit"
+)
+
+func TestShardedAccessSubject(t *testing.T) {
+	previousCfg := cfg
+	t.Cleanup(func() { cfg = previousCfg })
+
+	const projectUID = "00000000-0000-4000-8000-0000000000aa"
+	tests := []struct {
+		name       string
+		shards     int
+		projectUID string
+		want       string
+	}{
+		{"sharding disabled", 0, projectUID, UpdateAccessV1MeetingSubject},
+		{"negative shards", -1, projectUID, UpdateAccessV1MeetingSubject},
+		{"unresolved project", 8, "", UpdateAccessV1MeetingSubject + ".unassigned"},
+		// FNV-1a of the project UID modulo the number of shards.
+		{"project shard", 8, projectUID, UpdateAccessV1MeetingSubject + ".7"},
+		{"single shard", 1, projectUID, UpdateAccessV1MeetingSubject + ".0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &Config{AccessSubjectShards: tt.shards}
+			got := shardedAccessSubject(UpdateAccessV1MeetingSubject, tt.projectUID)
+			if got != tt.want {
+				t.Errorf("shardedAccessSubject() = %q, want %q", got, tt.want)
+			}
+			// The shard of a project is stable.
+			if again := shardedAccessSubject(UpdateAccessV1MeetingSubject, tt.projectUID); again != got {
+				t.Errorf("shardedAccessSubject() changed from %q to %q", got, again)
+			}
+		})
+	}
+}
+
+func TestHardDeleteAccessShard(t *testing.T) {
+	ctx := context.Background()
+	v1, mappings, publisher := setupHandlerTest(t)
+	cfg.AccessSubjectShards = 8
+
+	const projectUID = "00000000-0000-4000-8000-0000000000aa"
+	if _, err := mappings.Put(ctx, "project.sfid.a0941000002wBz9AAE", []byte(projectUID)); err != nil {
+		t.Fatal(err)
+	}
+	meeting := testkit.V1Meeting("91234567890", "a0941000002wBz9AAE")
+	if _, err := meeting.Put(ctx, v1); err != nil {
+		t.Fatal(err)
+	}
+
+	putCtx := withRecordAccessProject(ctx, meeting.Key, recordData(t, meeting))
+	handleZoomMeetingUpdate(putCtx, meeting.Key, recordData(t, meeting))
+	storeAccessProject(putCtx, meeting.Key)
+
+	// The hard delete no longer has the record to resolve its project from.
+	if err := v1.Delete(ctx, meeting.Key); err != nil {
+		t.Fatal(err)
+	}
+	publisher.Reset()
+	if handleResourceDelete(ctx, meeting.Key, "", nil) {
+		t.Fatal("meeting delete requested a retry")
+	}
+
+	want := shardedAccessSubject(DeleteAllAccessV1MeetingSubject, projectUID)
+	if n := len(publisher.Messages(want)); n != 1 {
+		t.Errorf("delete access messages on %s: got %d, want 1", want, n)
+	}
+	if n := len(publisher.Messages(DeleteAllAccessV1MeetingSubject + ".unassigned")); n != 0 {
+		t.Errorf("unassigned delete access messages: got %d, want 0", n)
+	}
+	if _, err := mappings.Get(ctx, accessProjectKeyPrefix+meeting.Key); err == nil {
+		t.Error("access project of the deleted meeting was not forgotten")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Admin resync. Forcing the resync of a record used to mean touching its
+// source record in DynamoDB, so that a new revision is written to v1-objects.
+// POST /admin/resync on the admin server re-reads the current revision of a
+// record (by object type and ID), or of every record under a key prefix, from
+// the v1-objects bucket and re-runs its handler, like the replay subcommand.
+// Prefix requests can be limited to the records modified since a time (see
+// replay_window.go).
+// As it has side effects, the endpoint requires the admin basic auth to be
+// configured.
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// adminResyncMaxBody bounds the size of a resync request.
+const adminResyncMaxBody = 4 << 10
+
+// adminResyncRequest is the /admin/resync request: either an object type (a
+// v1 key prefix) and a record ID, or a key prefix, optionally with the RFC
+// 3339 time or duration the records must have been modified since.
+type adminResyncRequest struct {
+	ObjectType string `json:"object_type"`
+	ID         string `json:"id"`
+	Prefix     string `json:"prefix"`
+	Since      string `json:"since"`
+}
+
+// adminResyncResponse is the /admin/resync response.
+type adminResyncResponse struct {
+	Keys int `json:"keys"`
+	// Failed are the keys still requesting a retry after the last pass.
+	Failed []string `json:"failed"`
+}
+
+// adminResyncHandler re-runs the handlers for the current revision of the
+// requested v1-objects keys, processing keys requesting a retry for up to
+// replayMaxPasses passes.
+func adminResyncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cfg.AdminUsername == "" {
+		http.Error(w, "resyncs require the admin server authentication (set ADMIN_USERNAME and ADMIN_PASSWORD)", http.StatusForbidden)
+		return
+	}
+
+	var request adminResyncRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, adminResyncMaxBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, "invalid resync request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	objectType, id := strings.TrimSpace(request.ObjectType), strings.TrimSpace(request.ID)
+	prefix := strings.TrimSuffix(strings.TrimSpace(request.Prefix), ".")
+	if (objectType == "" || id == "") == (prefix == "") {
+		http.Error(w, "either object_type and id, or prefix, is required", http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if request.Since != "" {
+		if prefix == "" {
+			http.Error(w, "since requires prefix", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if since, err = parseReplaySince(strings.TrimSpace(request.Since), time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if prefix != "" {
+		objectType = prefix
+	}
+	if _, ok := kvTableHandlers[objectType]; !ok {
+		http.Error(w, "no handler is registered for object type "+objectType, http.StatusBadRequest)
+		return
+	}
+	if strings.ContainsAny(objectType+id, ".*> \t") {
+		http.Error(w, "object type and id must not contain dots, wildcards, or spaces", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var keys []string
+	if prefix == "" {
+		key := objectType + "." + id
+		if _, err := v1KV.Get(ctx, key); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, jetstream.ErrKeyNotFound) || errors.Is(err, jetstream.ErrKeyDeleted) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, "error getting v1-objects entry "+key+": "+err.Error(), status)
+			return
+		}
+		keys = append(keys, key)
+	} else {
+		lister, err := v1KV.ListKeysFiltered(ctx, prefix+".>")
+		if err != nil {
+			http.Error(w, "error listing v1-objects keys: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for k := range lister.Keys() {
+			keys = append(keys, k)
+		}
+		if !since.IsZero() {
+			if keys, err = filterModifiedSince(ctx, keys, since); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	response := adminResyncResponse{Keys: len(keys), Failed: replayKeys(ctx, "resync", keys)}
+	if response.Failed == nil {
+		response.Failed = []string{}
+	}
+	logger.With("object_type", objectType, "id", id, "since", request.Since, "keys", response.Keys, "failed", len(response.Failed)).InfoContext(ctx, "admin resync completed")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to encode resync response")
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Past meeting attendees who joined Zoom without signing in have no LF user ID,
+// only a display name (and sometimes an email). When enabled, attendee
+// enrichment fuzzily matches these attendees against the registrants of the
+// meeting and annotates the participant payload with the matched registrant.
+//
+// Registrants are found through a per-meeting index in the v1-mappings bucket,
+// holding one key per registrant, maintained by the registrant update and
+// delete handlers.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// keyIndexUpdateAttempts is the number of attempts to update a key index
+	// (e.g. a meeting registrant index) on concurrent modification.
+	keyIndexUpdateAttempts = 3
+
+	// registrantFetchConcurrency bounds the concurrent reads of the
+	// registrant records of a meeting.
+	registrantFetchConcurrency = 16
+
+	// Confidence scores for the kinds of attendee matches.
+	matchConfidenceEmail          = 1.0
+	matchConfidenceFullName       = 0.95
+	matchConfidenceReorderedName  = 0.9
+	matchConfidenceEmailLocalPart = 0.85
+	matchConfidenceSimilarName    = 0.85
+)
+
+// attendeeMatch is a registrant matched to a past meeting attendee.
+type attendeeMatch struct {
+	RegistrantID string
+	Name         string
+	Confidence   float64
+}
+
+// meetingRegistrantIndexPrefix returns the v1-mappings key prefix of the
+// registrant index of a meeting, followed by the registrant record keys.
+func meetingRegistrantIndexPrefix(meetingID string) string {
+	return fmt.Sprintf("v1_meeting_registrant_index.%s", meetingID)
+}
+
+// updateMeetingRegistrantIndex adds or removes a registrant record key from the
+// registrant index of a meeting.
+func updateMeetingRegistrantIndex(ctx context.Context, meetingID, registrantKey string, isDeleted bool) error {
+	if isDeleted {
+		_, err := removeKeyIndexMember(ctx, meetingRegistrantIndexPrefix(meetingID), registrantKey)
+		return err
+	}
+	_, err := addKeyIndexMember(ctx, meetingRegistrantIndexPrefix(meetingID), registrantKey)
+	return err
+}
+
+// addKeyIndexMember adds a member to a v1-mappings index holding one key per
+// member under the index prefix. It reports whether the index changed.
+func addKeyIndexMember(ctx context.Context, indexPrefix, member string) (bool, error) {
+	if _, err := mappingsKV.Create(ctx, indexPrefix+"."+member, nil); err != nil {
+		if errors.Is(err, jetstream.ErrKeyExists) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to add %s to index %s: %w", member, indexPrefix, err)
+	}
+	return true, nil
+}
+
+// removeKeyIndexMember removes a member from a v1-mappings index holding one
+// key per member under the index prefix. It reports whether the index
+// changed.
+func removeKeyIndexMember(ctx context.Context, indexPrefix, member string) (bool, error) {
+	// Deleting a missing key would still write a delete marker.
+	entry, err := mappingsKV.Get(ctx, indexPrefix+"."+member)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s in index %s: %w", member, indexPrefix, err)
+	}
+	if err := mappingsKV.Delete(ctx, indexPrefix+"."+member, jetstream.LastRevision(entry.Revision())); err != nil {
+		if isRevisionMismatchError(err) {
+			// Removed (or added again) meanwhile.
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove %s from index %s: %w", member, indexPrefix, err)
+	}
+	return true, nil
+}
+
+// listKeyIndex returns the members of a v1-mappings index holding one key per
+// member under the index prefix, in sorted order.
+func listKeyIndex(ctx context.Context, indexPrefix string) ([]string, error) {
+	lister, err := mappingsKV.ListKeysFiltered(ctx, indexPrefix+".>")
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list index %s: %w", indexPrefix, err)
+	}
+	var members []string
+	for key := range lister.Keys() {
+		members = append(members, strings.TrimPrefix(key, indexPrefix+"."))
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+// updateKeyIndex adds or removes a member from a v1-mappings index holding a
+// JSON list of keys, using optimistic concurrency control. It reports whether
+// the index changed.
+func updateKeyIndex(ctx context.Context, indexKey, member string, isDeleted bool) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		var members []string
+		var revision uint64
+
+		entry, err := mappingsKV.Get(ctx, indexKey)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound):
+			members = []string{}
+		case err != nil:
+			return false, fmt.Errorf("failed to get index %s: %w", indexKey, err)
+		default:
+			revision = entry.Revision()
+			if err := json.Unmarshal(entry.Value(), &members); err != nil {
+				return false, fmt.Errorf("failed to unmarshal index %s: %w", indexKey, err)
+			}
+		}
+
+		if slices.Contains(members, member) != isDeleted {
+			// Already up to date.
+			return false, nil
+		}
+		if isDeleted {
+			members = slices.DeleteFunc(members, func(k string) bool { return k == member })
+		} else {
+			members = append(members, member)
+		}
+
+		data, err := json.Marshal(members)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal index %s: %w", indexKey, err)
+		}
+
+		if revision == 0 {
+			_, err = mappingsKV.Create(ctx, indexKey, data)
+		} else {
+			_, err = mappingsKV.Update(ctx, indexKey, data, revision)
+		}
+		if err == nil {
+			return true, nil
+		}
+		if (isRevisionMismatchError(err) || errors.Is(err, jetstream.ErrKeyExists)) && attempt < keyIndexUpdateAttempts {
+			continue
+		}
+		return false, fmt.Errorf("failed to store index %s: %w", indexKey, err)
+	}
+}
+
+// enrichAttendeeMatch annotates the participant payload of an attendee who
+// could not be identified (no LF user ID or registrant ID) with the meeting
+// registrant they most likely are. Attendees already auto-matched in v1 keep
+// their v1 match.
+func enrichAttendeeMatch(ctx context.Context, attendee *pastMeetingAttendeeInput, participant *V2PastMeetingParticipant) {
+	if attendee.IsAutoMatched {
+		participant.IsAutoMatched = true
+		participant.MappedInviteeName = attendee.MappedInviteeName
+		return
+	}
+	if !contextSettings(ctx).AttendeeAutoMatchEnabled || attendee.LFUserID != "" || attendee.RegistrantID != "" || attendee.MeetingID == "" {
+		return
+	}
+
+	funcLogger := logger.With("attendee_id", attendee.ID, "meeting_id", attendee.MeetingID)
+
+	match, err := matchAttendeeToRegistrant(ctx, attendee)
+	if err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to match attendee to meeting registrants")
+		return
+	}
+	if match == nil || match.Confidence < contextSettings(ctx).AttendeeAutoMatchMinConfidence {
+		funcLogger.DebugContext(ctx, "no registrant match found for attendee")
+		return
+	}
+
+	participant.IsAutoMatched = true
+	participant.MatchedRegistrantUID = match.RegistrantID
+	participant.MatchConfidence = match.Confidence
+	participant.MappedInviteeName = match.Name
+	funcLogger.With("registrant_id", match.RegistrantID, "confidence", match.Confidence).InfoContext(ctx, "auto-matched attendee to meeting registrant")
+}
+
+// matchAttendeeToRegistrant returns the registrant of the attendee's meeting
+// with the highest match confidence, or nil if there is none or the best match
+// is ambiguous.
+func matchAttendeeToRegistrant(ctx context.Context, attendee *pastMeetingAttendeeInput) (*attendeeMatch, error) {
+	registrants, err := getMeetingRegistrants(ctx, attendee.MeetingID)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *attendeeMatch
+	ambiguous := false
+	for _, registrantData := range registrants {
+		confidence := scoreRegistrantMatch(attendee, registrantData)
+		if confidence == 0 {
+			continue
+		}
+		if best != nil && confidence == best.Confidence {
+			ambiguous = true
+			continue
+		}
+		if best == nil || confidence > best.Confidence {
+			firstName, _ := registrantData["first_name"].(string)
+			lastName, _ := registrantData["last_name"].(string)
+			best = &attendeeMatch{
+				RegistrantID: registrantRecordID(registrantData),
+				Name:         strings.TrimSpace(firstName + " " + lastName),
+				Confidence:   confidence,
+			}
+			ambiguous = false
+		}
+	}
+
+	if ambiguous {
+		logger.With("attendee_id", attendee.ID, "confidence", best.Confidence).DebugContext(ctx, "multiple registrants match attendee equally, not matching")
+		return nil, nil
+	}
+	return best, nil
+}
+
+// getMeetingRegistrants returns the data of the registrants of a meeting
+// which are not deleted, from the meeting registrant index. The registrant
+// records are read concurrently.
+func getMeetingRegistrants(ctx context.Context, meetingID string) ([]map[string]any, error) {
+	registrantKeys, err := listKeyIndex(ctx, meetingRegistrantIndexPrefix(meetingID))
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]map[string]any, len(registrantKeys))
+	errs := make([]error, len(registrantKeys))
+	slots := make(chan struct{}, registrantFetchConcurrency)
+	var wg sync.WaitGroup
+	for i, registrantKey := range registrantKeys {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			registrantData, exists, err := getV1ObjectData(ctx, registrantKey)
+			if err != nil || !exists {
+				errs[i] = err
+				return
+			}
+			if deletedAt, ok := registrantData["_sdc_deleted_at"]; ok && deletedAt != nil && deletedAt != "" {
+				return
+			}
+			found[i] = registrantData
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var registrants []map[string]any
+	for _, registrantData := range found {
+		if registrantData != nil {
+			registrants = append(registrants, registrantData)
+		}
+	}
+	return registrants, nil
+}
+
+// registrantRecordID returns the ID of a v1 registrant record.
+func registrantRecordID(registrantData map[string]any) string {
+	if registrantID, _ := registrantData["registrant_id"].(string); registrantID != "" {
+		return registrantID
+	}
+	registrantID, _ := registrantData["id"].(string)
+	return registrantID
+}
+
+// scoreRegistrantMatch returns the confidence (0-1) that an attendee is the
+// given registrant, based on email and display name.
+func scoreRegistrantMatch(attendee *pastMeetingAttendeeInput, registrantData map[string]any) float64 {
+	registrantEmail, _ := registrantData["email"].(string)
+	if attendee.Email != "" && strings.EqualFold(strings.TrimSpace(attendee.Email), strings.TrimSpace(registrantEmail)) {
+		return matchConfidenceEmail
+	}
+
+	firstName, _ := registrantData["first_name"].(string)
+	lastName, _ := registrantData["last_name"].(string)
+	registrantName := normalizeName(firstName + " " + lastName)
+	emailLocalPart := ""
+	if at := strings.Index(registrantEmail, "@"); at > 0 {
+		emailLocalPart = strings.ReplaceAll(normalizeName(registrantEmail[:at]), " ", "")
+	}
+
+	best := 0.0
+	for _, displayName := range []string{attendee.ZoomUserName, attendee.Name} {
+		name := normalizeName(displayName)
+		if name == "" {
+			continue
+		}
+		var confidence float64
+		switch {
+		case registrantName != "" && name == registrantName:
+			confidence = matchConfidenceFullName
+		case registrantName != "" && sortedTokens(name) == sortedTokens(registrantName):
+			confidence = matchConfidenceReorderedName
+		case emailLocalPart != "" && strings.ReplaceAll(name, " ", "") == emailLocalPart:
+			confidence = matchConfidenceEmailLocalPart
+		case registrantName != "":
+			// Scale edit distance similarity so near-identical names (typos,
+			// missing accents) score at most matchConfidenceSimilarName.
+			confidence = nameSimilarity(name, registrantName) * matchConfidenceSimilarName
+		}
+		best = max(best, confidence)
+	}
+	return best
+}
+
+// normalizeName lowercases a name, replaces punctuation with spaces, and
+// collapses whitespace.
+func normalizeName(name string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(normalized), " ")
+}
+
+// sortedTokens returns the space-separated tokens of a normalized name in
+// sorted order, so "doe john" and "john doe" compare equal.
+func sortedTokens(name string) string {
+	tokens := strings.Fields(name)
+	slices.Sort(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// nameSimilarity returns 1 minus the Levenshtein distance between two names
+// divided by the length of the longer name.
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(rb)])/float64(longest)
+}
@@ -0,0 +1,153 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/testkit"
+)
+
+func TestKeyIndex(t *testing.T) {
+	ctx := context.Background()
+	_, mappings, _ := setupHandlerTest(t)
+	const indexPrefix = "v1_meeting_registrant_index.91234567890"
+
+	for _, member := range []string{"itx-zoom-meetings-registrants-v2.b", "itx-zoom-meetings-registrants-v2.a"} {
+		if changed, err := addKeyIndexMember(ctx, indexPrefix, member); err != nil || !changed {
+			t.Fatalf("add %s: got %v, %v, want true, nil", member, changed, err)
+		}
+	}
+	if changed, err := addKeyIndexMember(ctx, indexPrefix, "itx-zoom-meetings-registrants-v2.a"); err != nil || changed {
+		t.Fatalf("add of an indexed member: got %v, %v, want false, nil", changed, err)
+	}
+	// Indexes of other meetings sharing the ID as a prefix are not listed.
+	if _, err := addKeyIndexMember(ctx, indexPrefix+"0", "itx-zoom-meetings-registrants-v2.c"); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := listKeyIndex(ctx, indexPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"itx-zoom-meetings-registrants-v2.a", "itx-zoom-meetings-registrants-v2.b"}
+	if !slices.Equal(members, want) {
+		t.Fatalf("members: got %v, want %v", members, want)
+	}
+
+	if changed, err := removeKeyIndexMember(ctx, indexPrefix, "itx-zoom-meetings-registrants-v2.a"); err != nil || !changed {
+		t.Fatalf("remove: got %v, %v, want true, nil", changed, err)
+	}
+	if changed, err := removeKeyIndexMember(ctx, indexPrefix, "itx-zoom-meetings-registrants-v2.a"); err != nil || changed {
+		t.Fatalf("remove of a removed member: got %v, %v, want false, nil", changed, err)
+	}
+	// Removing a member which was never indexed writes no delete marker.
+	if _, err := removeKeyIndexMember(ctx, indexPrefix, "itx-zoom-meetings-registrants-v2.z"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mappings.History(ctx, indexPrefix+".itx-zoom-meetings-registrants-v2.z"); err == nil {
+		t.Error("removal of a member never indexed wrote to the bucket")
+	}
+
+	members, err = listKeyIndex(ctx, indexPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"itx-zoom-meetings-registrants-v2.b"}; !slices.Equal(members, want) {
+		t.Fatalf("members after remove: got %v, want %v", members, want)
+	}
+	if members, err := listKeyIndex(ctx, "v1_meeting_registrant_index.none"); err != nil || len(members) != 0 {
+		t.Fatalf("members of an empty index: got %v, %v", members, err)
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"alex garcia", "alex garcia", 1},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"josé", "jose", 1 - 1.0/4},
+		{"abc", "", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		if got := nameSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("nameSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScoreRegistrantMatch(t *testing.T) {
+	registrant := map[string]any{"email": "agarcia@example.com", "first_name": "Alex", "last_name": "Garcia"}
+	tests := []struct {
+		name     string
+		attendee pastMeetingAttendeeInput
+		want     float64
+	}{
+		{"email", pastMeetingAttendeeInput{Email: " AGarcia@Example.com", Name: "Someone Else"}, matchConfidenceEmail},
+		{"full name", pastMeetingAttendeeInput{Name: "alex  GARCIA"}, matchConfidenceFullName},
+		{"reordered name", pastMeetingAttendeeInput{Name: "Garcia, Alex"}, matchConfidenceReorderedName},
+		{"email local part", pastMeetingAttendeeInput{ZoomUserName: "A. Garcia"}, matchConfidenceEmailLocalPart},
+		{"similar name", pastMeetingAttendeeInput{Name: "Alex Garcis"}, (1 - 1.0/11) * matchConfidenceSimilarName},
+		{"best display name", pastMeetingAttendeeInput{ZoomUserName: "Alex Garcis", Name: "Alex Garcia"}, matchConfidenceFullName},
+		{"other email", pastMeetingAttendeeInput{Email: "alex@example.org"}, 0},
+		{"no name", pastMeetingAttendeeInput{}, 0},
+	}
+	for _, tt := range tests {
+		if got := scoreRegistrantMatch(&tt.attendee, registrant); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAttendeeToRegistrant(t *testing.T) {
+	const meetingID = "91234567890"
+	tests := []struct {
+		name        string
+		registrants map[string]string
+		attendee    string
+		want        string
+	}{
+		{"single match", map[string]string{"r1": "Alex Garcia", "r2": "Sam Lee"}, "Alex Garcia", "r1"},
+		{"best match", map[string]string{"r1": "Alex Garcis", "r2": "Alex Garcia"}, "Alex Garcia", "r2"},
+		{"tie", map[string]string{"r1": "Alex Garcia", "r2": "Alex Garcia"}, "Alex Garcia", ""},
+		{"tie below the best match", map[string]string{"r1": "Alex Garcis", "r2": "Alex Garcis", "r3": "Alex Garcia"}, "Alex Garcia", "r3"},
+		{"tie above another match", map[string]string{"r1": "Alex Garcia", "r2": "Alex Garcia", "r3": "Alex Garcis"}, "Alex Garcia", ""},
+		{"registrant without a name", map[string]string{"r1": ""}, "Alex Garcia", ""},
+		{"no registrants", nil, "Alex Garcia", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			v1, _, _ := setupHandlerTest(t)
+			for registrantID, name := range tt.registrants {
+				first, last, _ := strings.Cut(name, " ")
+				registrant := testkit.V1Registrant(registrantID, meetingID, "a0941000002wBz9AAE").With("first_name", first).With("last_name", last)
+				if _, err := registrant.Put(ctx, v1); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := addKeyIndexMember(ctx, meetingRegistrantIndexPrefix(meetingID), registrant.Key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			match, err := matchAttendeeToRegistrant(ctx, &pastMeetingAttendeeInput{ID: "attendee", MeetingID: meetingID, Name: tt.attendee})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if match != nil {
+				got = match.RegistrantID
+			}
+			if got != tt.want {
+				t.Errorf("matched registrant: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Historical past meeting backfill from the Zoom API.
+//
+// Some past meetings predate the v1 past meeting tables. For each configured
+// meeting ID, the ended instances, participants, and cloud recordings are read
+// from the Zoom report and recording endpoints and written to the v1-objects
+// KV bucket in the same shape as the v1 records. The existing KV handlers then
+// propagate them downstream like any other v1 record.
+//
+// Records are only ever created, never overwritten, so existing v1 data always
+// takes precedence and re-running the backfill is a no-op. v1 identifies
+// occurrences by their scheduled start, and backfilled ones by their actual
+// start, so instances of which v1 already has a past meeting (with a session
+// of the instance, or scheduled for the occurrence the instance started in)
+// are skipped rather than backfilled under a second key.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// zoomBackfillParentTimeout is how long to wait for a backfilled past
+	// meeting to be synced before writing its participants and recording,
+	// whose handlers require the parent past meeting mapping.
+	zoomBackfillParentTimeout = 60 * time.Second
+	zoomBackfillPollInterval  = 1 * time.Second
+
+	// zoomBackfillEarlyStartTolerance is how long before its scheduled start
+	// an instance may start and still be matched to a v1 occurrence.
+	zoomBackfillEarlyStartTolerance = time.Hour
+)
+
+// zoomBackfillMeetingFields are the v1 meeting fields copied onto backfilled
+// past meeting records.
+var zoomBackfillMeetingFields = []string{
+	"proj_id",
+	"project_slug",
+	"topic",
+	"agenda",
+	"visibility",
+	"meeting_type",
+	"timezone",
+	"duration",
+	"type",
+	"restricted",
+	"recording_enabled",
+	"recording_access",
+	"transcript_enabled",
+	"transcript_access",
+	"ai_summary_access",
+	"zoom_ai_enabled",
+	"ai_summary_require_approval",
+	"committee",
+	"committee_filters",
+	"committees",
+	"early_join_time_minutes",
+	"recurrence",
+}
+
+// runZoomBackfill backfills past meetings for all configured meeting IDs.
+func runZoomBackfill(ctx context.Context) {
+	for _, meetingID := range cfg.ZoomBackfillMeetingIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := backfillZoomMeeting(ctx, meetingID); err != nil {
+			logger.With(errKey, err, "meeting_id", meetingID).ErrorContext(ctx, "failed to backfill past meetings from Zoom")
+		}
+	}
+	logger.With("meetings", len(cfg.ZoomBackfillMeetingIDs)).InfoContext(ctx, "zoom past meeting backfill completed")
+}
+
+// backfillZoomMeeting backfills all ended instances of a single meeting.
+func backfillZoomMeeting(ctx context.Context, meetingID string) error {
+	funcLogger := logger.With("meeting_id", meetingID)
+
+	// The parent meeting supplies the project, committees, and access
+	// settings, and must already be synced for past meetings to be accepted.
+	meetingData, exists, err := getV1ObjectData(ctx, fmt.Sprintf("itx-zoom-meetings-v2.%s", meetingID))
+	if err != nil {
+		return fmt.Errorf("failed to get v1 meeting: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("v1 meeting not found")
+	}
+
+	pastMeetings, err := listV1PastMeetings(ctx, meetingID)
+	if err != nil {
+		return fmt.Errorf("failed to list v1 past meetings: %w", err)
+	}
+
+	instances, err := listZoomPastMeetingInstances(ctx, meetingID)
+	if err != nil {
+		return fmt.Errorf("failed to list past meeting instances: %w", err)
+	}
+	funcLogger.With("instances", len(instances), "past_meetings", len(pastMeetings)).InfoContext(ctx, "backfilling past meeting instances from Zoom")
+
+	for _, instance := range instances {
+		if err := backfillZoomMeetingInstance(ctx, meetingID, meetingData, pastMeetings, instance); err != nil {
+			funcLogger.With(errKey, err, "instance_uuid", instance.UUID).ErrorContext(ctx, "failed to backfill past meeting instance")
+		}
+	}
+	return nil
+}
+
+// backfillZoomMeetingInstance backfills a single ended meeting instance along
+// with its participants and recording, unless v1 already has its past
+// meeting.
+func backfillZoomMeetingInstance(ctx context.Context, meetingID string, meetingData map[string]any, pastMeetings []map[string]any, instance ZoomPastMeetingInstance) error {
+	report, err := getZoomMeetingReport(ctx, instance.UUID)
+	if err != nil {
+		return fmt.Errorf("failed to get meeting report: %w", err)
+	}
+	if report == nil {
+		return fmt.Errorf("meeting report not found")
+	}
+
+	startTime, err := time.Parse(time.RFC3339, report.StartTime)
+	if err != nil {
+		return fmt.Errorf("failed to parse meeting start time: %w", err)
+	}
+
+	// Occurrences of backfilled meetings are identified by their actual start
+	// time, following the v1 convention of unix timestamp occurrence IDs.
+	occurrenceID := strconv.FormatInt(startTime.Unix(), 10)
+	meetingAndOccurrenceID := fmt.Sprintf("%s-%s", meetingID, occurrenceID)
+	funcLogger := logger.With("meeting_id", meetingID, "meeting_and_occurrence_id", meetingAndOccurrenceID)
+
+	// Past meetings backfilled by a previous run are identified by the same
+	// actual start, and resumed.
+	if existingID := matchV1PastMeeting(pastMeetings, report.UUID, startTime); existingID != "" && existingID != meetingAndOccurrenceID {
+		funcLogger.With("existing_meeting_and_occurrence_id", existingID).InfoContext(ctx, "v1 already has the past meeting, skipping backfill")
+		return nil
+	}
+
+	pastMeeting := buildZoomBackfillPastMeeting(meetingID, occurrenceID, meetingAndOccurrenceID, meetingData, report)
+	created, err := createV1Object(ctx, fmt.Sprintf("itx-zoom-past-meetings.%s", meetingAndOccurrenceID), pastMeeting)
+	if err != nil {
+		return err
+	}
+	if created {
+		funcLogger.InfoContext(ctx, "backfilled past meeting from Zoom")
+	}
+
+	if err := waitForMapping(ctx, fmt.Sprintf("v1_past_meetings.%s", meetingAndOccurrenceID)); err != nil {
+		return fmt.Errorf("past meeting was not synced: %w", err)
+	}
+
+	participants, err := listZoomMeetingParticipants(ctx, instance.UUID)
+	if err != nil {
+		return fmt.Errorf("failed to list meeting participants: %w", err)
+	}
+	for _, attendee := range buildZoomBackfillAttendees(meetingID, occurrenceID, meetingAndOccurrenceID, pastMeeting, participants) {
+		if _, err := createV1Object(ctx, fmt.Sprintf("itx-zoom-past-meetings-attendees.%s", attendee["id"]), attendee); err != nil {
+			funcLogger.With(errKey, err, "attendee_id", attendee["id"]).ErrorContext(ctx, "failed to backfill past meeting attendee")
+		}
+	}
+
+	recording, err := getZoomMeetingRecording(ctx, instance.UUID)
+	if err != nil {
+		return fmt.Errorf("failed to get meeting recording: %w", err)
+	}
+	if recording != nil {
+		recordingData := buildZoomBackfillRecording(meetingID, occurrenceID, meetingAndOccurrenceID, pastMeeting, recording)
+		if _, err := createV1Object(ctx, fmt.Sprintf("itx-zoom-past-meetings-recordings.%s", meetingAndOccurrenceID), recordingData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildZoomBackfillPastMeeting builds a v1 past meeting record from the parent
+// v1 meeting and the Zoom meeting report.
+func buildZoomBackfillPastMeeting(meetingID, occurrenceID, meetingAndOccurrenceID string, meetingData map[string]any, report *ZoomMeetingReport) map[string]any {
+	now := bootstrap.Now().UTC().Format(time.RFC3339)
+
+	pastMeeting := map[string]any{}
+	for _, field := range zoomBackfillMeetingFields {
+		if value, ok := meetingData[field]; ok {
+			pastMeeting[field] = value
+		}
+	}
+
+	scheduledEnd := report.EndTime
+	if startTime, err := time.Parse(time.RFC3339, report.StartTime); err == nil {
+		if duration, err := strconv.Atoi(fmt.Sprint(meetingData["duration"])); err == nil && duration > 0 {
+			scheduledEnd = startTime.Add(time.Duration(duration) * time.Minute).UTC().Format(time.RFC3339)
+		}
+	}
+	if _, ok := pastMeeting["topic"]; !ok {
+		pastMeeting["topic"] = report.Topic
+	}
+
+	pastMeeting["meeting_and_occurrence_id"] = meetingAndOccurrenceID
+	pastMeeting["meeting_id"] = meetingID
+	pastMeeting["occurrence_id"] = occurrenceID
+	pastMeeting["scheduled_start_time"] = report.StartTime
+	pastMeeting["scheduled_end_time"] = scheduledEnd
+	pastMeeting["sessions"] = []map[string]any{{
+		"uuid":       report.UUID,
+		"start_time": report.StartTime,
+		"end_time":   report.EndTime,
+	}}
+	pastMeeting["created_at"] = now
+	pastMeeting["modified_at"] = now
+
+	return pastMeeting
+}
+
+// buildZoomBackfillAttendees builds v1 past meeting attendee records from the
+// Zoom participant report. Report entries are grouped per participant (by
+// email, falling back to display name) into attendance sessions. Attendee IDs
+// are derived from the past meeting and participant so reruns are idempotent.
+func buildZoomBackfillAttendees(meetingID, occurrenceID, meetingAndOccurrenceID string, pastMeeting map[string]any, participants []ZoomParticipantReport) []map[string]any {
+	now := bootstrap.Now().UTC().Format(time.RFC3339)
+
+	var order []string
+	attendees := map[string]map[string]any{}
+	for _, participant := range participants {
+		identity := strings.ToLower(strings.TrimSpace(participant.UserEmail))
+		if identity == "" {
+			identity = strings.TrimSpace(participant.Name)
+		}
+		if identity == "" {
+			continue
+		}
+
+		attendee, ok := attendees[identity]
+		if !ok {
+			attendee = map[string]any{
+				"id":                        uuid.NewSHA1(uuid.NameSpaceOID, []byte(meetingAndOccurrenceID+"/"+identity)).String(),
+				"proj_id":                   pastMeeting["proj_id"],
+				"project_slug":              pastMeeting["project_slug"],
+				"meeting_id":                meetingID,
+				"occurrence_id":             occurrenceID,
+				"meeting_and_occurrence_id": meetingAndOccurrenceID,
+				"registrant_id":             participant.RegistrantID,
+				"email":                     participant.UserEmail,
+				"name":                      participant.Name,
+				"zoom_user_name":            participant.Name,
+				"sessions":                  []map[string]any{},
+				"created_at":                now,
+				"modified_at":               now,
+			}
+			attendees[identity] = attendee
+			order = append(order, identity)
+		}
+		if attendee["registrant_id"] == "" && participant.RegistrantID != "" {
+			attendee["registrant_id"] = participant.RegistrantID
+		}
+
+		attendee["sessions"] = append(attendee["sessions"].([]map[string]any), map[string]any{
+			"participant_uuid": participant.UserID,
+			"join_time":        participant.JoinTime,
+			"leave_time":       participant.LeaveTime,
+		})
+	}
+
+	result := make([]map[string]any, 0, len(order))
+	for _, identity := range order {
+		result = append(result, attendees[identity])
+	}
+	return result
+}
+
+// buildZoomBackfillRecording builds a v1 past meeting recording record from
+// the Zoom cloud recording.
+func buildZoomBackfillRecording(meetingID, occurrenceID, meetingAndOccurrenceID string, pastMeeting map[string]any, recording *ZoomRecording) map[string]any {
+	now := bootstrap.Now().UTC().Format(time.RFC3339)
+
+	files := make([]map[string]any, 0, len(recording.RecordingFiles))
+	for _, file := range recording.RecordingFiles {
+		files = append(files, map[string]any{
+			"id":              file.ID,
+			"meeting_id":      meetingID,
+			"recording_start": file.RecordingStart,
+			"recording_end":   file.RecordingEnd,
+			"file_type":       file.FileType,
+			"file_extension":  file.FileExtension,
+			"file_size":       file.FileSize,
+			"play_url":        file.PlayURL,
+			"download_url":    file.DownloadURL,
+			"status":          file.Status,
+			"recording_type":  file.RecordingType,
+		})
+	}
+
+	recordingData := map[string]any{
+		"meeting_and_occurrence_id": meetingAndOccurrenceID,
+		"meeting_id":                meetingID,
+		"occurrence_id":             occurrenceID,
+		"proj_id":                   pastMeeting["proj_id"],
+		"project_slug":              pastMeeting["project_slug"],
+		"topic":                     pastMeeting["topic"],
+		"visibility":                pastMeeting["visibility"],
+		"recording_access":          pastMeeting["recording_access"],
+		"transcript_access":         pastMeeting["transcript_access"],
+		"transcript_enabled":        pastMeeting["transcript_enabled"],
+		"host_email":                recording.HostEmail,
+		"host_id":                   recording.HostID,
+		"start_time":                recording.StartTime,
+		"total_size":                recording.TotalSize,
+		"recording_count":           recording.RecordingCount,
+		"recording_files":           files,
+		"sessions": []map[string]any{{
+			"uuid":       recording.UUID,
+			"share_url":  recording.ShareURL,
+			"total_size": recording.TotalSize,
+			"start_time": recording.StartTime,
+		}},
+		"created_at":  now,
+		"modified_at": now,
+	}
+
+	// Drop unset access fields so the handler defaults apply.
+	for _, field := range []string{"recording_access", "transcript_access", "transcript_enabled", "visibility"} {
+		if recordingData[field] == nil {
+			delete(recordingData, field)
+		}
+	}
+
+	return recordingData
+}
+
+// listV1PastMeetings returns the v1 past meeting records of a meeting.
+func listV1PastMeetings(ctx context.Context, meetingID string) ([]map[string]any, error) {
+	lister, err := v1KV.ListKeysFiltered(ctx, "itx-zoom-past-meetings.>")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list past meeting keys: %w", err)
+	}
+
+	var pastMeetings []map[string]any
+	for key := range lister.Keys() {
+		if !strings.HasPrefix(key, "itx-zoom-past-meetings."+meetingID+"-") {
+			continue
+		}
+		pastMeetingData, exists, err := getV1ObjectData(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get past meeting %s: %w", key, err)
+		}
+		if exists {
+			pastMeetings = append(pastMeetings, pastMeetingData)
+		}
+	}
+	return pastMeetings, nil
+}
+
+// matchV1PastMeeting returns the meeting and occurrence ID of the v1 past
+// meeting of a Zoom meeting instance: the past meeting with a session of the
+// instance, or else the one whose scheduled occurrence the instance started
+// in. It returns an empty ID if there is none.
+func matchV1PastMeeting(pastMeetings []map[string]any, sessionUUID string, startTime time.Time) string {
+	for _, pastMeeting := range pastMeetings {
+		sessions, _ := pastMeeting["sessions"].([]any)
+		for _, session := range sessions {
+			if session, ok := session.(map[string]any); ok && session["uuid"] == sessionUUID {
+				id, _ := pastMeeting["meeting_and_occurrence_id"].(string)
+				return id
+			}
+		}
+	}
+
+	for _, pastMeeting := range pastMeetings {
+		scheduledStartStr, _ := pastMeeting["scheduled_start_time"].(string)
+		scheduledStart, err := time.Parse(time.RFC3339, scheduledStartStr)
+		if err != nil {
+			continue
+		}
+		scheduledEnd := scheduledStart.Add(zoomBackfillEarlyStartTolerance)
+		if scheduledEndStr, _ := pastMeeting["scheduled_end_time"].(string); scheduledEndStr != "" {
+			if end, err := time.Parse(time.RFC3339, scheduledEndStr); err == nil && end.After(scheduledStart) {
+				scheduledEnd = end
+			}
+		}
+		if !startTime.Before(scheduledStart.Add(-zoomBackfillEarlyStartTolerance)) && startTime.Before(scheduledEnd) {
+			id, _ := pastMeeting["meeting_and_occurrence_id"].(string)
+			return id
+		}
+	}
+	return ""
+}
+
+// createV1Object creates a record in the v1-objects KV bucket using the
+// configured encoding. Existing records are left untouched. Returns whether
+// the record was created.
+func createV1Object(ctx context.Context, key string, data map[string]any) (bool, error) {
+	var dataBytes []byte
+	var err error
+	if cfg.UseMsgpack {
+		dataBytes, err = msgpack.Marshal(data)
+	} else {
+		dataBytes, err = json.Marshal(data)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal v1 object %s: %w", key, err)
+	}
+
+	if _, err := v1KV.Create(ctx, key, dataBytes); err != nil {
+		if errors.Is(err, jetstream.ErrKeyExists) {
+			logger.With("key", key).DebugContext(ctx, "v1 object already exists, skipping backfill")
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create v1 object %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// waitForMapping polls the mappings KV bucket until the given key exists or
+// the backfill parent timeout elapses.
+func waitForMapping(ctx context.Context, mappingKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, zoomBackfillParentTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(zoomBackfillPollInterval)
+	defer ticker.Stop()
+	for {
+		if entry, err := mappingsKV.Get(ctx, mappingKey); err == nil && !isTombstonedMapping(entry.Value()) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for mapping %s: %w", mappingKey, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// First-run bootstrap. A new environment must sync projects, then committees,
+// then meetings, then their children: with a fresh KV consumer delivering the
+// last value of every key in stream order, children arrive before their
+// parents and are parked (or retried) until the parents are synced. The
+// bootstrap subcommand provisions the v1-mappings bucket, backfills the
+// v1-objects records one object type at a time, parents first, with progress
+// reporting, then creates the KV consumers starting after the stream sequence
+// the backfill started from, so the sync service only consumes the changes
+// written since and resumes in steady state.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// deletedObjectsPrefix is the v1 key prefix of the deleted objects, backfilled
+// last as they delete the records synced by the other phases.
+const deletedObjectsPrefix = "itx-deleted-objects"
+
+// bootstrapMappingsBucketConfig is the configuration of the v1-mappings bucket
+// when the bootstrap provisions it, matching the chart defaults.
+var bootstrapMappingsBucketConfig = jetstream.KeyValueConfig{
+	Bucket:       "v1-mappings",
+	History:      20,
+	Storage:      jetstream.FileStorage,
+	MaxValueSize: 10 * 1024 * 1024,
+	MaxBytes:     2 * 1024 * 1024 * 1024,
+	Compression:  true,
+}
+
+// bootstrapPhase is the report of the backfill of an object type.
+type bootstrapPhase struct {
+	ObjectType string `json:"object_type"`
+	Keys       int    `json:"keys"`
+	Failed     int    `json:"failed"`
+	Duration   string `json:"duration"`
+}
+
+// runBootstrap provisions a new environment: it creates the v1-mappings bucket
+// if missing, backfills the v1-objects records by object type in dependency
+// order, then creates the KV consumers for the sync service to take over.
+func runBootstrap(name string, args []string) {
+	var objectTypes *string
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		objectTypes = flags.String("object-types", "", "only backfill these comma-separated v1 key prefixes (default: all with a registered handler, in dependency order)")
+	})
+	ctx := p.ctx
+
+	if err := provisionMappingsBucket(ctx); err != nil {
+		logger.With(errKey, err).Error("error provisioning v1-mappings KV bucket")
+		os.Exit(1)
+	}
+	p.openBuckets()
+
+	// The consumers are only created once the backfill completes, so existing
+	// consumers mean the environment was already bootstrapped (or the service
+	// already ran): their progress would be lost.
+	consumers := []jetstream.ConsumerConfig{kvConsumerConfig()}
+	for _, prefix := range kvPrefixes() {
+		consumers = append(consumers, kvPrefixConsumerConfig(prefix))
+	}
+	for _, config := range consumers {
+		_, err := jsContext.Consumer(ctx, kvStreamName, config.Durable)
+		switch {
+		case err == nil:
+			logger.With("consumer", config.Durable, "stream", kvStreamName).Error("KV consumer already exists: the environment is already bootstrapped, use the replay subcommand instead")
+			os.Exit(1)
+		case !errors.Is(err, jetstream.ErrConsumerNotFound):
+			logger.With(errKey, err, "consumer", config.Durable, "stream", kvStreamName).Error("error checking KV consumer")
+			os.Exit(1)
+		}
+	}
+
+	// Changes written during the backfill are delivered by the consumers.
+	stream, err := jsContext.Stream(ctx, kvStreamName)
+	if err != nil {
+		logger.With(errKey, err, "stream", kvStreamName).Error("error accessing KV stream")
+		os.Exit(1)
+	}
+	startSeq := stream.CachedInfo().State.LastSeq + 1
+
+	prefixes := bootstrapPrefixes()
+	if *objectTypes != "" {
+		selected := bootstrap.ParseList(*objectTypes)
+		prefixes = slices.DeleteFunc(prefixes, func(prefix string) bool { return !slices.Contains(selected, prefix) })
+	}
+	logger.With("object_types", prefixes, "start_seq", startSeq).InfoContext(ctx, "bootstrap backfill started")
+
+	var phases []bootstrapPhase
+	failed := 0
+	for i, prefix := range prefixes {
+		started := time.Now()
+		lister, err := v1KV.ListKeysFiltered(ctx, prefix+".>")
+		if err != nil {
+			logger.With(errKey, err, "prefix", prefix).Error("error listing v1-objects keys")
+			os.Exit(1)
+		}
+		var keys []string
+		for k := range lister.Keys() {
+			keys = append(keys, k)
+		}
+		logger.With("object_type", prefix, "phase", i+1, "phases", len(prefixes), "keys", len(keys)).InfoContext(ctx, "bootstrap phase started")
+
+		phaseFailed := replayKeys(ctx, prefix, keys)
+		if len(phaseFailed) > 0 {
+			logger.With("object_type", prefix, "keys", phaseFailed).WarnContext(ctx, "keys still requesting a retry after the last bootstrap pass")
+		}
+		failed += len(phaseFailed)
+		phases = append(phases, bootstrapPhase{
+			ObjectType: prefix,
+			Keys:       len(keys),
+			Failed:     len(phaseFailed),
+			Duration:   time.Since(started).Round(time.Second).String(),
+		})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	output, _ := json.MarshalIndent(phases, "", "  ")
+	_, _ = os.Stdout.Write(append(output, '\n'))
+
+	// Leave the consumers uncreated on failure, so the bootstrap can be run
+	// again once the failures are fixed.
+	if failed > 0 || ctx.Err() != nil {
+		logger.With("failed", failed).ErrorContext(ctx, "bootstrap backfill incomplete, KV consumers not created")
+		p.shutdown()
+		os.Exit(1)
+	}
+
+	for _, config := range consumers {
+		config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		config.OptStartSeq = startSeq
+		if _, err := jsContext.CreateConsumer(ctx, kvStreamName, config); err != nil {
+			logger.With(errKey, err, "consumer", config.Durable, "stream", kvStreamName).Error("error creating KV consumer")
+			p.shutdown()
+			os.Exit(1)
+		}
+		logger.With("consumer", config.Durable, "stream", kvStreamName, "start_seq", startSeq).InfoContext(ctx, "KV consumer created")
+	}
+	logger.With("object_types", len(phases)).InfoContext(ctx, "bootstrap completed, the sync service can be started")
+	p.shutdown()
+}
+
+// provisionMappingsBucket creates the v1-mappings bucket if it does not exist.
+// The v1-objects bucket is written by Meltano, so it is never created here.
+func provisionMappingsBucket(ctx context.Context) error {
+	_, err := jsContext.KeyValue(ctx, bootstrapMappingsBucketConfig.Bucket)
+	if !errors.Is(err, jetstream.ErrBucketNotFound) {
+		return err
+	}
+	if _, err := jsContext.CreateKeyValue(ctx, bootstrapMappingsBucketConfig); err != nil {
+		return err
+	}
+	logger.With("bucket", bootstrapMappingsBucketConfig.Bucket).InfoContext(ctx, "KV bucket created")
+	return nil
+}
+
+// bootstrapPrefixes returns the v1 key prefixes with a registered handler, in
+// bootstrap order: the parent object types of replayParentPrefixes, then the
+// remaining prefixes with their parents first, and deleted objects last.
+func bootstrapPrefixes() []string {
+	var prefixes []string
+	add := func(prefix string) {
+		if _, ok := kvTableHandlers[prefix]; ok && !slices.Contains(prefixes, prefix) && prefix != deletedObjectsPrefix {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	for _, prefix := range replayParentPrefixes {
+		add(prefix)
+	}
+	for _, prefix := range lintScanOrder(dataReferences()) {
+		add(prefix)
+	}
+	for _, prefix := range slices.Sorted(maps.Keys(kvTableHandlers)) {
+		add(prefix)
+	}
+	if _, ok := kvTableHandlers[deletedObjectsPrefix]; ok {
+		prefixes = append(prefixes, deletedObjectsPrefix)
+	}
+	return prefixes
+}
@@ -0,0 +1,326 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Canary mode. A prefix can register a candidate replacement of its update
+// handler (the canary field of its kvTableHandler). With CANARY_PERCENT set,
+// that percentage of the records of the prefix, sampled by key so a record is
+// always sampled or never, is processed by both handlers: the candidate runs
+// first in a dry run, on a copy of the record, then the current handler runs
+// with its side effects recorded but still performed. Only the current handler
+// publishes and writes. The messages, KV writes, and v2 API writes of the two
+// runs (and their retry decisions) are compared, divergences are logged and
+// counted, and the recent divergences of each object type are served by the
+// /canaryz admin endpoint, to review before cutting over to the candidate.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+const (
+	// canaryMaxDifferences bounds the differences kept per divergence.
+	canaryMaxDifferences = 20
+
+	// canaryRecentDivergences is how many divergences are kept per object
+	// type for the /canaryz report.
+	canaryRecentDivergences = 50
+)
+
+var canaryResults = newCounterVec(
+	"v1_sync_helper_canary_results_total",
+	"Number of records processed by both a current and a candidate handler in canary mode, by object type and result (match, diverged, or error).",
+	"object_type", "result",
+)
+
+// canaryDivergence is a record for which a candidate handler diverged from
+// the current one.
+type canaryDivergence struct {
+	Key         string    `json:"key"`
+	Time        time.Time `json:"time"`
+	Differences []string  `json:"differences"`
+}
+
+var (
+	// canaryDivergencesMu guards canaryDivergences.
+	canaryDivergencesMu sync.Mutex
+	// canaryDivergences are the recent divergences by object type, oldest
+	// first.
+	canaryDivergences = map[string][]canaryDivergence{}
+)
+
+// canaryPrefixes returns the key prefixes with a candidate handler.
+func canaryPrefixes() []string {
+	var prefixes []string
+	for prefix, table := range kvTableHandlers {
+		if table.canary != nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// canarySampled reports whether a key is in the canary sample. Records are
+// only sampled once the dry-run hooks are installed, and never within a dry
+// run (e.g. of the inspect subcommand).
+func canarySampled(ctx context.Context, key string) bool {
+	if cfg.CanaryPercent <= 0 || !dryRunHooksInstalled.Load() || contextDryRun(ctx) != nil {
+		return false
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32()%100) < cfg.CanaryPercent
+}
+
+// copyV1Data returns a deep copy of a v1 record, so a handler modifying it
+// does not affect the other.
+func copyV1Data(v1Data map[string]any) map[string]any {
+	copied, _ := copyValue(v1Data).(map[string]any)
+	return copied
+}
+
+// copyValue returns a deep copy of a decoded JSON or msgpack value.
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// runCanary processes a record with both the current and the candidate update
+// handlers, and records whether they diverged. Only the current handler's side
+// effects are performed, and its retry decision is returned.
+func runCanary(ctx context.Context, objectType, key string, v1Data map[string]any, current, candidate kvUpdateHandler) bool {
+	candidateRecorder := &dryRunRecorder{}
+	candidateRetry, candidateErr := runCandidate(withDryRun(ctx, candidateRecorder), key, copyV1Data(v1Data), candidate)
+
+	currentRecorder := &dryRunRecorder{passthrough: true}
+	retry := current(withDryRun(ctx, currentRecorder), key, v1Data)
+
+	log := logger.With("key", key, "object_type", objectType)
+	if candidateErr != nil {
+		canaryResults.inc(objectType, "error")
+		log.With(errKey, candidateErr).ErrorContext(ctx, "canary handler failed")
+		return retry
+	}
+
+	// The candidate's v2 API writes are rejected, so retry decisions are only
+	// compared for runs without them.
+	var differences []string
+	_, _, candidateAPIRequests := candidateRecorder.results()
+	if retry != candidateRetry && len(candidateAPIRequests) == 0 {
+		differences = append(differences, fmt.Sprintf("retry: current %t, candidate %t", retry, candidateRetry))
+	}
+	differences = append(differences, diffSideEffects(canarySideEffects(currentRecorder), canarySideEffects(candidateRecorder))...)
+	if len(differences) == 0 {
+		canaryResults.inc(objectType, "match")
+		return retry
+	}
+
+	if len(differences) > canaryMaxDifferences {
+		differences = append(differences[:canaryMaxDifferences], fmt.Sprintf("... %d more", len(differences)-canaryMaxDifferences))
+	}
+	canaryResults.inc(objectType, "diverged")
+	recordCanaryDivergence(objectType, canaryDivergence{Key: key, Time: bootstrap.Now(), Differences: differences})
+	log.With("differences", differences).WarnContext(ctx, "canary handler diverged from current handler")
+	return retry
+}
+
+// runCandidate runs a candidate handler, recovering from panics so a broken
+// candidate never stops the current handler.
+func runCandidate(ctx context.Context, key string, v1Data map[string]any, candidate kvUpdateHandler) (retry bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("canary handler panicked: %v", r)
+		}
+	}()
+	return candidate(ctx, key, v1Data), nil
+}
+
+// canarySideEffects returns the side effects recorded by a run, keyed by what
+// they affect (with an occurrence index for repeats), with decoded values.
+func canarySideEffects(recorder *dryRunRecorder) map[string]any {
+	messages, kvWrites, apiRequests := recorder.results()
+	effects := map[string]any{}
+	add := func(id string, value json.RawMessage) {
+		for i := 0; ; i++ {
+			indexed := fmt.Sprintf("%s[%d]", id, i)
+			if _, exists := effects[indexed]; !exists {
+				effects[indexed] = decodeCanaryValue(value)
+				return
+			}
+		}
+	}
+	for _, msg := range messages {
+		add("message "+msg.Subject, msg.Payload)
+	}
+	for _, write := range kvWrites {
+		add(fmt.Sprintf("kv %s %s/%s", write.Operation, write.Bucket, write.Key), write.Value)
+	}
+	for _, request := range apiRequests {
+		add(fmt.Sprintf("api %s %s", request.Method, request.URL), request.Body)
+	}
+	return effects
+}
+
+// decodeCanaryValue decodes a recorded JSON value, for comparison.
+func decodeCanaryValue(value json.RawMessage) any {
+	if len(value) == 0 {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return string(value)
+	}
+	return decoded
+}
+
+// diffSideEffects returns the differences between the side effects of the
+// current and candidate runs.
+func diffSideEffects(current, candidate map[string]any) []string {
+	ids := map[string]bool{}
+	for id := range current {
+		ids[id] = true
+	}
+	for id := range candidate {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	var differences []string
+	for _, id := range sorted {
+		currentValue, inCurrent := current[id]
+		candidateValue, inCandidate := candidate[id]
+		switch {
+		case !inCandidate:
+			differences = append(differences, "missing from candidate: "+id)
+		case !inCurrent:
+			differences = append(differences, "only in candidate: "+id)
+		default:
+			differences = append(differences, diffValues(id, currentValue, candidateValue)...)
+		}
+	}
+	return differences
+}
+
+// diffValues returns the paths at which two decoded JSON values differ.
+func diffValues(path string, current, candidate any) []string {
+	currentMap, currentIsMap := current.(map[string]any)
+	candidateMap, candidateIsMap := candidate.(map[string]any)
+	if currentIsMap && candidateIsMap {
+		keys := map[string]bool{}
+		for key := range currentMap {
+			keys[key] = true
+		}
+		for key := range candidateMap {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		var differences []string
+		for _, key := range sorted {
+			differences = append(differences, diffValues(path+"."+key, currentMap[key], candidateMap[key])...)
+		}
+		return differences
+	}
+	if reflect.DeepEqual(current, candidate) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: current %s, candidate %s", path, canaryValueString(current), canaryValueString(candidate))}
+}
+
+// canaryValueString formats a decoded JSON value for a difference.
+func canaryValueString(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// recordCanaryDivergence keeps a divergence for the /canaryz report.
+func recordCanaryDivergence(objectType string, divergence canaryDivergence) {
+	canaryDivergencesMu.Lock()
+	defer canaryDivergencesMu.Unlock()
+	divergences := append(canaryDivergences[objectType], divergence)
+	if len(divergences) > canaryRecentDivergences {
+		divergences = divergences[len(divergences)-canaryRecentDivergences:]
+	}
+	canaryDivergences[objectType] = divergences
+}
+
+// canaryObjectTypeReport is the canary report of an object type.
+type canaryObjectTypeReport struct {
+	Results     map[string]uint64  `json:"results"`
+	Divergences []canaryDivergence `json:"recent_divergences"`
+}
+
+// canaryzResponse is the response body of the /canaryz endpoint.
+type canaryzResponse struct {
+	Percent     int                                `json:"percent"`
+	Candidates  []string                           `json:"candidates"`
+	ObjectTypes map[string]*canaryObjectTypeReport `json:"object_types"`
+}
+
+// canaryzHandler serves the canary results and recent divergences by object
+// type as JSON.
+func canaryzHandler(w http.ResponseWriter, r *http.Request) {
+	response := canaryzResponse{
+		Percent:     cfg.CanaryPercent,
+		Candidates:  canaryPrefixes(),
+		ObjectTypes: map[string]*canaryObjectTypeReport{},
+	}
+	report := func(objectType string) *canaryObjectTypeReport {
+		if response.ObjectTypes[objectType] == nil {
+			response.ObjectTypes[objectType] = &canaryObjectTypeReport{Results: map[string]uint64{}, Divergences: []canaryDivergence{}}
+		}
+		return response.ObjectTypes[objectType]
+	}
+
+	// Samples are labeled by object type and result.
+	for _, sample := range canaryResults.samples() {
+		report(sample.labelValues[0]).Results[sample.labelValues[1]] = sample.value
+	}
+	canaryDivergencesMu.Lock()
+	for objectType, divergences := range canaryDivergences {
+		report(objectType).Divergences = append([]canaryDivergence{}, divergences...)
+	}
+	canaryDivergencesMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.With(errKey, err).ErrorContext(r.Context(), "failed to encode canaryz response")
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Cascade jobs. Re-running the children of a parent whose change must reach
+// them (e.g. the registrants and invite responses of a meeting whose snapshot
+// changed) used to be an in-memory loop in a goroutine, bounded by a timeout:
+// a restart, or a parent with tens of thousands of children, silently left the
+// rest stale. Cascades are now jobs kept in the v1-mappings bucket under
+// cascadeJobKeyPrefix, listing the per-parent indexes of the child record keys
+// to re-run, with a checkpoint of the progress through them. Every replica
+// polls the jobs, claims a job by leasing it with a revision-checked update,
+// re-runs its children in batches at up to CASCADE_JOB_RATE records per
+// second, and checkpoints each batch, so an interrupted job resumes from its
+// last checkpoint once its lease expires. /admin/cascade-jobs lists the jobs
+// and their progress, and pauses, resumes, or cancels them.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// cascadeJobKeyPrefix prefixes the v1-mappings keys of cascade jobs,
+	// followed by their ID.
+	cascadeJobKeyPrefix = "v1_cascade_jobs."
+
+	// cascadeJobMeetingSnapshot is the kind of the jobs refreshing the
+	// registrants and invite responses of a meeting whose snapshot changed.
+	cascadeJobMeetingSnapshot = "meeting_snapshot"
+
+	// cascadeJobPollInterval is how often the cascade jobs are polled.
+	cascadeJobPollInterval = 5 * time.Second
+
+	// cascadeJobBatchSize is the number of child records re-run between two
+	// checkpoints of a job.
+	cascadeJobBatchSize = 100
+
+	// cascadeJobLease is how long a claimed job is not claimed by another
+	// replica, renewed by each checkpoint, unless a batch takes longer at
+	// the rate limit.
+	cascadeJobLease = time.Minute
+
+	// defaultCascadeJobRate is the default rate limit of the child records
+	// re-run by cascade jobs, per replica and second.
+	defaultCascadeJobRate = 20
+
+	// adminCascadeJobsMaxBody bounds the size of a cascade job action request.
+	adminCascadeJobsMaxBody = 4 << 10
+)
+
+// cascadeJobResults are the counters of the re-run child records of each
+// cascade job kind.
+var cascadeJobResults = map[string]*counterVec{
+	cascadeJobMeetingSnapshot: meetingSnapshotCascades,
+}
+
+// cascadeJob is the v1-mappings value of a cascade job.
+type cascadeJob struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// IndexKeys are the v1-mappings key prefixes of the indexes of the child
+	// record keys to re-run (see listKeyIndex), in order.
+	IndexKeys []string `json:"index_keys"`
+	// Index is the position of the index being processed in IndexKeys, and
+	// After the last child record re-run in it, in key order.
+	Index int    `json:"index"`
+	After string `json:"after,omitempty"`
+
+	Refreshed int `json:"refreshed"`
+	Retried   int `json:"retried"`
+	Dropped   int `json:"dropped"`
+
+	Paused     bool      `json:"paused"`
+	LeasedTill time.Time `json:"leased_till,omitzero"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// done reports whether every index of the job has been processed.
+func (j *cascadeJob) done() bool {
+	return j.Index >= len(j.IndexKeys)
+}
+
+// enqueueCascadeJob creates the cascade job re-running the children listed
+// in the given indexes, by kind and parent ID. A job of the same parent still
+// queued is restarted from the beginning.
+func enqueueCascadeJob(ctx context.Context, kind, parentID string, indexKeys ...string) error {
+	now := bootstrap.Now().UTC()
+	job := cascadeJob{
+		ID:        kind + "." + parentID,
+		Kind:      kind,
+		IndexKeys: indexKeys,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	value, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cascade job: %w", err)
+	}
+	if _, err := mappingsKV.Put(ctx, cascadeJobKeyPrefix+job.ID, value); err != nil {
+		return fmt.Errorf("failed to store cascade job: %w", err)
+	}
+	logger.With("job_id", job.ID).InfoContext(ctx, "queued cascade job")
+	return nil
+}
+
+// getCascadeJob returns a cascade job and the revision of its entry.
+func getCascadeJob(ctx context.Context, id string) (*cascadeJob, uint64, error) {
+	entry, err := mappingsKV.Get(ctx, cascadeJobKeyPrefix+id)
+	if err != nil {
+		return nil, 0, err
+	}
+	var job cascadeJob
+	if err := json.Unmarshal(entry.Value(), &job); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal cascade job %s: %w", id, err)
+	}
+	return &job, entry.Revision(), nil
+}
+
+// updateCascadeJob stores a cascade job if its entry is still at the given
+// revision, and returns the new revision.
+func updateCascadeJob(ctx context.Context, job *cascadeJob, revision uint64) (uint64, error) {
+	job.UpdatedAt = bootstrap.Now().UTC()
+	value, err := json.Marshal(job)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal cascade job: %w", err)
+	}
+	return mappingsKV.Update(ctx, cascadeJobKeyPrefix+job.ID, value, revision)
+}
+
+// listCascadeJobIDs returns the IDs of the queued cascade jobs.
+func listCascadeJobIDs(ctx context.Context) ([]string, error) {
+	lister, err := mappingsKV.ListKeysFiltered(ctx, cascadeJobKeyPrefix+">")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for key := range lister.Keys() {
+		ids = append(ids, strings.TrimPrefix(key, cascadeJobKeyPrefix))
+	}
+	return ids, nil
+}
+
+// watchCascadeJobs runs the claimable cascade jobs every
+// cascadeJobPollInterval, until the context is cancelled.
+func watchCascadeJobs(ctx context.Context) {
+	ticker := time.NewTicker(cascadeJobPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ids, err := listCascadeJobIDs(ctx)
+		if err != nil {
+			if !errors.Is(err, jetstream.ErrNoKeysFound) {
+				logger.With(errKey, err).WarnContext(ctx, "failed to list cascade jobs")
+			}
+			continue
+		}
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				return
+			}
+			runCascadeJob(ctx, id)
+		}
+	}
+}
+
+// runCascadeJob claims a cascade job, unless it is paused or leased by
+// another replica, and re-runs its remaining children, checkpointing after
+// each batch. It stops when the job changed meanwhile (it was paused,
+// cancelled, or restarted), and deletes it once done.
+func runCascadeJob(ctx context.Context, id string) {
+	log := logger.With("job_id", id)
+	job, revision, err := getCascadeJob(ctx, id)
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			log.With(errKey, err).WarnContext(ctx, "failed to get cascade job")
+		}
+		return
+	}
+	if job.Paused || bootstrap.Now().Before(job.LeasedTill) {
+		return
+	}
+	results, ok := cascadeJobResults[job.Kind]
+	if !ok {
+		log.With("kind", job.Kind).ErrorContext(ctx, "unknown cascade job kind")
+		return
+	}
+
+	// The lease outlasts a batch at the rate limit.
+	interval, lease := time.Duration(0), cascadeJobLease
+	if rate := contextSettings(ctx).CascadeJobRate; rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+		lease = max(lease, 2*cascadeJobBatchSize*interval)
+	}
+
+	// Claim the job; a conflict means another replica claimed it first.
+	job.LeasedTill = bootstrap.Now().Add(lease).UTC()
+	if revision, err = updateCascadeJob(ctx, job, revision); err != nil {
+		return
+	}
+	log.With("index", job.Index, "after", job.After).InfoContext(ctx, "running cascade job")
+
+	for !job.done() {
+		if err := runCascadeJobBatch(ctx, job, results, interval); err != nil {
+			log.With(errKey, err).WarnContext(ctx, "cascade job batch failed, resuming once its lease expires")
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		job.LeasedTill = bootstrap.Now().Add(lease).UTC()
+		if revision, err = updateCascadeJob(ctx, job, revision); err != nil {
+			log.With(errKey, err).InfoContext(ctx, "cascade job changed meanwhile, stopping")
+			return
+		}
+	}
+
+	if err := mappingsKV.Delete(ctx, cascadeJobKeyPrefix+id, jetstream.LastRevision(revision)); err != nil {
+		log.With(errKey, err).InfoContext(ctx, "cascade job changed meanwhile, not deleting it")
+		return
+	}
+	log.With("refreshed", job.Refreshed, "retried", job.Retried, "dropped", job.Dropped).InfoContext(ctx, "cascade job completed")
+}
+
+// runCascadeJobBatch re-runs up to cascadeJobBatchSize children of a job,
+// waiting the interval between two records, and advances its checkpoint.
+// Child records no longer in v1-objects are dropped from their index.
+func runCascadeJobBatch(ctx context.Context, job *cascadeJob, results *counterVec, interval time.Duration) error {
+	indexKey := job.IndexKeys[job.Index]
+	keys, err := listKeyIndex(ctx, indexKey)
+	if err != nil {
+		return fmt.Errorf("failed to list cascade index %s: %w", indexKey, err)
+	}
+	// The checkpoint is a key rather than a position, so children added or
+	// removed meanwhile do not shift the remaining ones.
+	start, found := slices.BinarySearch(keys, job.After)
+	if found {
+		start++
+	}
+	end := min(start+cascadeJobBatchSize, len(keys))
+	for _, key := range keys[start:end] {
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+		}
+		job.After = key
+		objectType := kvObjectType(key)
+		childEntry, err := v1KV.Get(ctx, key)
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			results.inc(objectType, "dropped")
+			job.Dropped++
+			if _, err := removeKeyIndexMember(ctx, indexKey, key); err != nil {
+				logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to drop record from cascade index")
+			}
+			continue
+		}
+		if err != nil {
+			results.inc(objectType, "retried")
+			job.Retried++
+			logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to get record for cascade job")
+			continue
+		}
+		if rerunKVEntry(ctx, childEntry) {
+			// The next sync of the record picks up the change.
+			results.inc(objectType, "retried")
+			job.Retried++
+			continue
+		}
+		results.inc(objectType, "refreshed")
+		job.Refreshed++
+	}
+
+	if end >= len(keys) {
+		job.Index++
+		job.After = ""
+	}
+	return nil
+}
+
+// adminCascadeJobRequest is the /admin/cascade-jobs action request.
+type adminCascadeJobRequest struct {
+	ID string `json:"id"`
+	// Action is "pause", "resume", or "cancel".
+	Action string `json:"action"`
+}
+
+// adminCascadeJobsHandler lists the cascade jobs and their progress (GET),
+// or pauses, resumes, or cancels one (POST).
+func adminCascadeJobsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		ids, err := listCascadeJobIDs(ctx)
+		if err != nil && !errors.Is(err, jetstream.ErrNoKeysFound) {
+			http.Error(w, "error listing cascade jobs: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jobs := []*cascadeJob{}
+		for _, id := range ids {
+			if job, _, err := getCascadeJob(ctx, id); err == nil {
+				jobs = append(jobs, job)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(jobs); err != nil {
+			logger.With(errKey, err).ErrorContext(ctx, "failed to encode cascade jobs response")
+		}
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if cfg.AdminUsername == "" {
+		http.Error(w, "cascade job actions require the admin server authentication (set ADMIN_USERNAME and ADMIN_PASSWORD)", http.StatusForbidden)
+		return
+	}
+	var request adminCascadeJobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, adminCascadeJobsMaxBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, "invalid cascade job request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	job, revision, err := getCascadeJob(ctx, request.ID)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		http.Error(w, "no cascade job "+request.ID, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch request.Action {
+	case "pause":
+		job.Paused = true
+	case "resume":
+		job.Paused = false
+		job.LeasedTill = time.Time{}
+	case "cancel":
+		err = mappingsKV.Delete(ctx, cascadeJobKeyPrefix+job.ID, jetstream.LastRevision(revision))
+	default:
+		http.Error(w, "action must be pause, resume, or cancel", http.StatusBadRequest)
+		return
+	}
+	if request.Action != "cancel" {
+		_, err = updateCascadeJob(ctx, job, revision)
+	}
+	if err != nil {
+		// The job was checkpointed meanwhile.
+		http.Error(w, "cascade job changed meanwhile, try again: "+err.Error(), http.StatusConflict)
+		return
+	}
+	logger.With("job_id", job.ID, "action", request.Action).InfoContext(ctx, "cascade job updated")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/testkit"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestRunCascadeJobBatch(t *testing.T) {
+	ctx := context.Background()
+	v1, _, _ := setupHandlerTest(t)
+	previousRerun := rerunKVEntry
+	t.Cleanup(func() { rerunKVEntry = previousRerun })
+	var rerun []string
+	rerunKVEntry = func(_ context.Context, entry jetstream.KeyValueEntry) bool {
+		rerun = append(rerun, entry.Key())
+		return false
+	}
+
+	const meetingID = "91234567890"
+	indexPrefix := meetingRegistrantIndexPrefix(meetingID)
+	var keys []string
+	for i := range cascadeJobBatchSize + 2 {
+		registrant := testkit.V1Registrant(fmt.Sprintf("reg-%03d", i), meetingID, "a0941000002wBz9AAE")
+		if _, err := registrant.Put(ctx, v1); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateMeetingRegistrantIndex(ctx, meetingID, registrant.Key, false); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, registrant.Key)
+	}
+	job := &cascadeJob{ID: cascadeJobMeetingSnapshot + "." + meetingID, Kind: cascadeJobMeetingSnapshot, IndexKeys: []string{indexPrefix}}
+
+	if err := runCascadeJobBatch(ctx, job, meetingSnapshotCascades, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(rerun) != cascadeJobBatchSize || job.After != keys[cascadeJobBatchSize-1] || job.done() {
+		t.Fatalf("first batch: re-ran %d records up to %q (done: %v), want %d up to %q", len(rerun), job.After, job.done(), cascadeJobBatchSize, keys[cascadeJobBatchSize-1])
+	}
+
+	// Removing a record already re-run does not shift the remaining ones, and
+	// a record no longer in v1-objects is dropped from the index.
+	if err := updateMeetingRegistrantIndex(ctx, meetingID, keys[0], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.Delete(ctx, keys[cascadeJobBatchSize+1]); err != nil {
+		t.Fatal(err)
+	}
+	rerun = nil
+	if err := runCascadeJobBatch(ctx, job, meetingSnapshotCascades, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(rerun) != 1 || rerun[0] != keys[cascadeJobBatchSize] {
+		t.Fatalf("second batch: re-ran %v, want [%s]", rerun, keys[cascadeJobBatchSize])
+	}
+	if job.Dropped != 1 || !job.done() || job.After != "" {
+		t.Fatalf("second batch: dropped %d, done %v, after %q, want 1, true, empty", job.Dropped, job.done(), job.After)
+	}
+	members, err := listKeyIndex(ctx, indexPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != cascadeJobBatchSize {
+		t.Fatalf("index members: got %d, want %d", len(members), cascadeJobBatchSize)
+	}
+}
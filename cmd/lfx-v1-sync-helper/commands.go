@@ -0,0 +1,225 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// One-shot subcommands sharing the sync service configuration and handlers.
+
+import (
+	"context"
+	"flag"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+const (
+	// replayMaxPasses is how many times the replay subcommand processes keys
+	// whose handlers requested a retry before giving up on them.
+	replayMaxPasses = 3
+
+	// replayProgressInterval is how often the progress of a replay pass is
+	// logged.
+	replayProgressInterval = 10 * time.Second
+)
+
+// runVerify runs the startup preflight checks and exits with their result.
+func runVerify(name string, args []string) {
+	p := startSyncProcess(name, args, nil)
+
+	err := runPreflightChecks(p.ctx)
+	p.shutdown()
+	if err != nil {
+		logger.With(errKey, err).Error("preflight checks failed")
+		os.Exit(1)
+	}
+	logger.Info("preflight checks passed")
+}
+
+// runBackfill backfills historical past meetings from the Zoom API into the
+// v1-objects bucket, then exits. The backfilled records are propagated by the
+// running sync service.
+func runBackfill(name string, args []string) {
+	var meetingIDs *string
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		meetingIDs = flags.String("meeting-ids", strings.Join(cfg.ZoomBackfillMeetingIDs, ","), "comma-separated Zoom meeting IDs to backfill")
+	})
+
+	cfg.ZoomBackfillMeetingIDs = nil
+	for _, meetingID := range strings.Split(*meetingIDs, ",") {
+		if meetingID = strings.TrimSpace(meetingID); meetingID != "" {
+			cfg.ZoomBackfillMeetingIDs = append(cfg.ZoomBackfillMeetingIDs, meetingID)
+		}
+	}
+	if len(cfg.ZoomBackfillMeetingIDs) == 0 {
+		logger.Error("no meeting IDs to backfill: set -meeting-ids or ZOOM_BACKFILL_MEETING_IDS")
+		os.Exit(2)
+	}
+	if cfg.ZoomAccountID == "" || cfg.ZoomClientID == "" || cfg.ZoomClientSecret == "" {
+		logger.Error("ZOOM_ACCOUNT_ID, ZOOM_CLIENT_ID, and ZOOM_CLIENT_SECRET environment variables are required for the backfill")
+		os.Exit(2)
+	}
+	initZoomClient(cfg)
+
+	p.openBuckets()
+	runZoomBackfill(p.ctx)
+	p.shutdown()
+}
+
+// replayParentPrefixes are the v1 key prefixes of the parent object types,
+// in replay order. Their keys are replayed before any other key, so children
+// replayed in the same run find their parents' mappings instead of being
+// parked until they appear.
+var replayParentPrefixes = []string{
+	"salesforce-project__c",
+	"platform-collaboration__c",
+	"itx-zoom-meetings-v2",
+	"itx-zoom-past-meetings",
+}
+
+// runReplay re-runs the KV handlers for the current revision of the
+// v1-objects keys under one or more prefixes (or a single key), without
+// waiting for a new revision to be written. Keys are replayed in two phases:
+// parent object types first, then the remaining (child) keys. With -since,
+// only the keys modified since a time are replayed (see replay_window.go).
+func runReplay(name string, args []string) {
+	var prefix, key, sinceFlag *string
+	var all *bool
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		prefix = flags.String("prefix", "", "replay all keys under these comma-separated prefixes, e.g. \"itx-zoom-meetings-v2\"")
+		key = flags.String("key", "", "replay a single key")
+		all = flags.Bool("all", false, "replay all keys with a registered handler")
+		sinceFlag = flags.String("since", "", "with -prefix or -all, only replay keys modified since this RFC 3339 time or duration, e.g. \"6h\"")
+	})
+	ctx := p.ctx
+
+	selected := 0
+	for _, set := range []bool{*prefix != "", *key != "", *all} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		logger.Error("exactly one of -prefix, -key, or -all is required")
+		os.Exit(2)
+	}
+	var since time.Time
+	if *sinceFlag != "" {
+		if *key != "" {
+			logger.Error("-since requires -prefix or -all")
+			os.Exit(2)
+		}
+		var err error
+		if since, err = parseReplaySince(*sinceFlag, time.Now()); err != nil {
+			logger.With(errKey, err).Error("invalid -since")
+			os.Exit(2)
+		}
+	}
+
+	p.openBuckets()
+
+	var prefixes []string
+	switch {
+	case *all:
+		for tablePrefix := range kvTableHandlers {
+			prefixes = append(prefixes, tablePrefix)
+		}
+		slices.Sort(prefixes)
+	case *prefix != "":
+		for _, tablePrefix := range strings.Split(*prefix, ",") {
+			if tablePrefix = strings.TrimSuffix(strings.TrimSpace(tablePrefix), "."); tablePrefix != "" {
+				prefixes = append(prefixes, tablePrefix)
+			}
+		}
+	}
+
+	keys := []string{*key}
+	if len(prefixes) > 0 {
+		keys = keys[:0]
+		for _, tablePrefix := range prefixes {
+			lister, err := v1KV.ListKeysFiltered(ctx, tablePrefix+".>")
+			if err != nil {
+				logger.With(errKey, err, "prefix", tablePrefix).Error("error listing v1-objects keys")
+				os.Exit(1)
+			}
+			for k := range lister.Keys() {
+				keys = append(keys, k)
+			}
+		}
+	}
+	if !since.IsZero() {
+		listed := len(keys)
+		var err error
+		if keys, err = filterModifiedSince(ctx, keys, since); err != nil {
+			logger.With(errKey, err).Error("error filtering v1-objects keys by modification time")
+			os.Exit(1)
+		}
+		logger.With("since", since, "listed", listed, "modified", len(keys)).InfoContext(ctx, "replaying keys modified since")
+	}
+
+	parents, children := splitReplayKeys(keys)
+	failed := replayKeys(ctx, "parents", parents)
+	failed = append(failed, replayKeys(ctx, "children", children)...)
+
+	if len(failed) > 0 {
+		logger.With("keys", failed).WarnContext(ctx, "keys still requesting a retry after the last replay pass")
+	}
+	logger.With("processed", len(keys), "parents", len(parents), "children", len(children), "failed", len(failed)).InfoContext(ctx, "replay completed")
+	p.shutdown()
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// splitReplayKeys splits v1-objects keys into the keys of parent object
+// types, ordered by replayParentPrefixes, and the remaining keys.
+func splitReplayKeys(keys []string) (parents, children []string) {
+	byPrefix := map[string][]string{}
+	for _, k := range keys {
+		tablePrefix, _, _ := strings.Cut(k, ".")
+		if slices.Contains(replayParentPrefixes, tablePrefix) {
+			byPrefix[tablePrefix] = append(byPrefix[tablePrefix], k)
+			continue
+		}
+		children = append(children, k)
+	}
+	for _, tablePrefix := range replayParentPrefixes {
+		parents = append(parents, byPrefix[tablePrefix]...)
+	}
+	return parents, children
+}
+
+// replayKeys runs the KV handlers for the keys of a replay phase, processing
+// keys whose handlers requested a retry for up to replayMaxPasses passes, and
+// logging its progress every replayProgressInterval. It returns the keys still
+// requesting a retry.
+func replayKeys(ctx context.Context, phase string, keys []string) []string {
+	for pass := 1; pass <= replayMaxPasses && len(keys) > 0; pass++ {
+		var retry []string
+		lastProgress := time.Now()
+		for i, k := range keys {
+			if ctx.Err() != nil {
+				break
+			}
+			if time.Since(lastProgress) >= replayProgressInterval {
+				lastProgress = time.Now()
+				logger.With("phase", phase, "pass", pass, "processed", i, "keys", len(keys), "retry", len(retry)).InfoContext(ctx, "replay pass in progress")
+			}
+			entry, err := v1KV.Get(ctx, k)
+			if err != nil {
+				logger.With(errKey, err, "key", k).ErrorContext(ctx, "error getting v1-objects entry")
+				continue
+			}
+			if kvHandler(bootstrap.MessageContext(ctx, nil), entry) {
+				retry = append(retry, k)
+			}
+		}
+		logger.With("phase", phase, "pass", pass, "keys", len(keys), "retry", len(retry)).InfoContext(ctx, "replay pass completed")
+		keys = retry
+	}
+	return keys
+}
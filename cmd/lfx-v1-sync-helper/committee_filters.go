@@ -0,0 +1,202 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Committee filter access recompute. A meeting mapping restricts the
+// committee registrants of a meeting to the committee members with one of its
+// voting statuses. Empty filters mean every voting status, or none with
+// COMMITTEE_FILTERS_EMPTY=none, as downstream interpretations differ per
+// environment: the committees of the meeting payloads always carry an
+// explicit list of allowed voting statuses instead. When the filters of a
+// mapping change, the committee's registrants of the meeting are enumerated
+// from the registrant index, their voting statuses are read from the
+// committee member records in v1-objects, and registrants whose access
+// changed get a put or remove access message.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// committeeFiltersEmptyAll makes empty committee filters allow every
+	// voting status.
+	committeeFiltersEmptyAll = "all"
+
+	// committeeFiltersEmptyNone makes empty committee filters allow no voting
+	// status.
+	committeeFiltersEmptyNone = "none"
+)
+
+// committeeVotingStatuses are the voting statuses of committee members,
+// listed for empty committee filters allowing every voting status.
+var committeeVotingStatuses = []string{
+	string(CommitteeVotingStatusVotingRep),
+	string(CommitteeVotingStatusAlternateVotingRep),
+	string(CommitteeVotingStatusObserver),
+	string(CommitteeVotingStatusEmeritus),
+}
+
+var committeeFilterAccessChanges = newCounterVec(
+	"v1_sync_helper_committee_filter_access_changes_total",
+	"Number of registrant access messages sent after committee filter changes, by action (put or remove).",
+	"action",
+)
+
+// committeeFiltersChanged reports whether two committee voting status
+// filters differ, ignoring order.
+func committeeFiltersChanged(previous, current []string) bool {
+	previous, current = slices.Clone(previous), slices.Clone(current)
+	slices.Sort(previous)
+	slices.Sort(current)
+	return !slices.Equal(slices.Compact(previous), slices.Compact(current))
+}
+
+// normalizeCommitteeFilters returns the voting statuses allowed by committee
+// filters as an explicit list, never nil: the filters without blanks and
+// duplicates, or for empty filters, every voting status or none per
+// COMMITTEE_FILTERS_EMPTY.
+func normalizeCommitteeFilters(filters []string) []string {
+	statuses := []string{}
+	for _, filter := range filters {
+		filter = strings.TrimSpace(filter)
+		if filter == "" || slices.ContainsFunc(statuses, func(status string) bool { return strings.EqualFold(status, filter) }) {
+			continue
+		}
+		statuses = append(statuses, filter)
+	}
+	if len(statuses) == 0 && cfg.CommitteeFiltersEmpty != committeeFiltersEmptyNone {
+		statuses = slices.Clone(committeeVotingStatuses)
+	}
+	return statuses
+}
+
+// committeeFiltersAllow reports whether committee voting status filters allow
+// a member with the voting status. Empty filters allow every member, or none
+// per COMMITTEE_FILTERS_EMPTY.
+func committeeFiltersAllow(filters []string, votingStatus string) bool {
+	if !slices.ContainsFunc(filters, func(filter string) bool { return strings.TrimSpace(filter) != "" }) {
+		return cfg.CommitteeFiltersEmpty != committeeFiltersEmptyNone
+	}
+	return slices.ContainsFunc(filters, func(filter string) bool {
+		return strings.EqualFold(filter, votingStatus)
+	})
+}
+
+// committeeMemberVotingStatuses returns the voting statuses of the members of
+// a v1 committee by lowercase email, from the committee member records.
+func committeeMemberVotingStatuses(ctx context.Context, committeeID string) (map[string]string, error) {
+	lister, err := v1KV.ListKeysFiltered(ctx, "platform-community__c.>")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list committee member keys: %w", err)
+	}
+
+	statuses := make(map[string]string)
+	for key := range lister.Keys() {
+		memberData, exists, err := getV1ObjectData(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get committee member %s: %w", key, err)
+		}
+		if !exists {
+			continue
+		}
+		if collaborationID, _ := memberData["collaboration_name__c"].(string); collaborationID != committeeID {
+			continue
+		}
+		email, _ := memberData["contactemail__c"].(string)
+		if email == "" {
+			continue
+		}
+		votingStatus, _ := memberData["voting_status__c"].(string)
+		statuses[strings.ToLower(email)] = votingStatus
+	}
+	return statuses, nil
+}
+
+// recomputeCommitteeRegistrantAccess sends put or remove access messages for
+// the registrants of a meeting's committee whose access changed between the
+// previous and current committee filters.
+func recomputeCommitteeRegistrantAccess(ctx context.Context, meetingID, committeeID string, previousFilters, currentFilters []string) error {
+	funcLogger := logger.With("meeting_id", meetingID, "committee_id", committeeID)
+
+	registrantKeys, err := listKeyIndex(ctx, meetingRegistrantIndexPrefix(meetingID))
+	if err != nil {
+		return fmt.Errorf("failed to list meeting registrant index: %w", err)
+	}
+	if len(registrantKeys) == 0 {
+		funcLogger.DebugContext(ctx, "meeting has no indexed registrants, skipping committee filter access recompute")
+		return nil
+	}
+
+	var votingStatuses map[string]string
+	for _, registrantKey := range registrantKeys {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		registrantData, exists, err := getV1ObjectData(ctx, registrantKey)
+		if err != nil {
+			return fmt.Errorf("failed to get registrant %s: %w", registrantKey, err)
+		}
+		if !exists {
+			continue
+		}
+		registrant, err := convertMapToInputRegistrant(ctx, registrantData)
+		if err != nil {
+			funcLogger.With(errKey, err, "key", registrantKey).WarnContext(ctx, "failed to convert registrant for committee filter access recompute")
+			continue
+		}
+		if registrant.CommitteeUID != committeeID || registrant.Username == "" || registrant.UID == "" {
+			continue
+		}
+
+		// Only read the committee members once the committee has registrants.
+		if votingStatuses == nil {
+			if votingStatuses, err = committeeMemberVotingStatuses(ctx, committeeID); err != nil {
+				return err
+			}
+		}
+		votingStatus := votingStatuses[strings.ToLower(registrant.Email)]
+
+		allowed := committeeFiltersAllow(currentFilters, votingStatus)
+		if allowed == committeeFiltersAllow(previousFilters, votingStatus) {
+			continue
+		}
+
+		host := registrant.Host != nil && *registrant.Host && allowed
+		authSub := mapUsernameToAuthSub(registrant.Username)
+		_, hostState, _, err := advanceRegistrantHostState(ctx, registrant.UID, authSub, registrant.CommitteeUID, host)
+		if err != nil {
+			return fmt.Errorf("failed to update registrant host state: %w", err)
+		}
+
+		accessMsgBytes, err := json.Marshal(MeetingRegistrantAccessMessage{
+			ID:           registrant.UID,
+			MeetingID:    meetingID,
+			Username:     authSub,
+			Host:         host,
+			CommitteeUID: registrant.CommitteeUID,
+			Sequence:     hostState.Sequence,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal registrant access message: %w", err)
+		}
+
+		subject, action := V1MeetingRegistrantPutSubject, "put"
+		if !allowed {
+			subject, action = V1MeetingRegistrantRemoveSubject, "remove"
+		}
+		if err := sendAccessMessage(ctx, subject, accessMsgBytes); err != nil {
+			return fmt.Errorf("failed to send registrant %s message: %w", action, err)
+		}
+		committeeFilterAccessChanges.inc(action)
+		funcLogger.With("registrant_id", registrant.UID, "voting_status", votingStatus, "action", action).
+			InfoContext(ctx, "recomputed registrant access after committee filter change")
+	}
+	return nil
+}
@@ -8,8 +8,11 @@ import (
 	"fmt"
 	"net/url"
 	"os"
-	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 )
 
 // projectAllowlist contains the list of project slugs that are allowed to be
@@ -90,6 +93,17 @@ type Config struct {
 	Auth0PrivateKey string   // Auth0 private key in PEM format
 	LFXAPIGateway   *url.URL // LFX API Gateway URL (audience for Auth0 tokens)
 
+	// Zoom API configuration for historical past meeting backfill
+	ZoomAccountID          string   // Zoom Server-to-Server OAuth account ID
+	ZoomClientID           string   // Zoom Server-to-Server OAuth client ID
+	ZoomClientSecret       string   // Zoom Server-to-Server OAuth client secret
+	ZoomBackfillMeetingIDs []string // Meeting IDs to backfill past meetings for (disabled if empty)
+
+	// Transcript content indexing
+	TranscriptContentEnabled            bool // Download transcript files and index their text in chunks (default: false)
+	TranscriptContentMaxBytes           int  // Size cap of downloaded transcript files (default: 5 MiB)
+	TranscriptContentDownloadsPerMinute int  // Rate limit of transcript file downloads per replica (default: 30)
+
 	// Service URLs
 	ProjectServiceURL   *url.URL
 	CommitteeServiceURL *url.URL
@@ -98,19 +112,154 @@ type Config struct {
 	NATSURL string
 
 	// Server configuration
-	Port string
-	Bind string
+	Port          string // Health check server port
+	Bind          string // Health check server interface
+	AdminPort     string // Admin (metrics and diagnostics) server port
+	AdminBind     string // Admin server interface
+	AdminUsername string // Optional basic auth username for the admin server
+	AdminPassword string // Optional basic auth password for the admin server
 
 	// Logging
-	Debug     bool
-	HTTPDebug bool
+	Debug          bool
+	HTTPDebug      bool
+	LogScrubFields []string // Log attribute key patterns redacted in addition to the defaults
 
 	// Data encoding
 	UseMsgpack bool
 
+	// Indexer messages
+	IndexerLegacyAuthorization bool   // Deprecated: send the placeholder "Bearer v1-sync-helper" instead of a service token
+	IndexerMaxPayloadBytes     int    // Maximum indexer message size (default: the NATS server max payload)
+	IndexerOversizePolicy      string // Policy for oversize indexer messages: "truncate" (default) or "object_store"
+	IndexerPayloadBucket       string // Object store bucket for oversize indexer payloads (default: "v1-indexer-payloads")
+	IndexerSyncWarnings        bool   // Include conversion warnings in the _sync_warnings field of indexed documents (default: false)
+	IndexerDocumentID          string // Document ID of meeting indexer messages: "uid" (default), "namespaced", or "none"
+
+	// Indexer feedback
+	IndexerResultSubject string // Subject of the indexer's results, consumed to re-enqueue or dead-letter failed documents (default: none, disabled)
+
+	// Indexer payload redaction
+	IndexerRedactionAllowlist []string // Sensitive fields kept in indexer payloads instead of redacted (default: none)
+
+	// Publish targets
+	PublishTargets []string // Additional NATS clusters receiving the sync output, as name=url pairs (default: none)
+
+	// Mappings
+	MappingsMirrorBucket         string   // Optional mirror of the v1-mappings bucket, read when the primary bucket fails
+	MappingsMemoryMirrorPrefixes []string // Mapping key prefixes mirrored in memory for reads, e.g. project.sfid (default: none, disabled)
+
+	// Downstream health (extended readiness)
+	DownstreamHealthChecks   []string      // Downstream services probed for readiness, as name=nats:<service> or name=<http url> pairs (default: none, disabled)
+	DownstreamHealthInterval time.Duration // Interval between the downstream health probes (default: 15s)
+
+	// Startup
+	SkipPreflight               bool     // Skip the startup preflight checks (default: false)
+	AcknowledgeRecreatedStreams []string // Streams whose recreation is acknowledged, so consuming them resumes
+
+	// Runtime configuration
+	ConfigFile string // Optional JSON config file with reloadable settings (e.g. a mounted ConfigMap)
+
 	// DynamoDB stream ingestion
 	DynamoDBIngestEnabled bool   // Whether to consume dynamodb_streams events (default: false)
 	DynamoDBStreamName    string // NATS stream name to consume (default: "dynamodb_streams")
+
+	// Ingest source priority
+	IngestSourcePriorities map[string]ingestSourcePriority // Authoritative ingest source by object type, suppressing the other source (INGEST_SOURCE_PRIORITY)
+
+	// KV consumers
+	KVPrefixConsumers map[string]kvPrefixConsumerSettings // Dedicated consumer delivery settings by v1 key prefix (KV_CONSUMER_PREFIXES)
+	KVFairnessWorkers int                                 // Worker slots shared by object types in proportion to their weight (default: 0, disabled)
+
+	// Message processing deadlines
+	HandlerTimeout  time.Duration            // Processing deadline of consumed messages (default: 0, the consumer AckWait)
+	HandlerTimeouts map[string]time.Duration // Processing deadlines by object type (HANDLER_TIMEOUTS)
+
+	// Consumer provisioning
+	ConsumerProvisioning        string        // Which replicas create and update the durable consumers: "all" or "leader" (default: "all")
+	ConsumerProvisioningTimeout time.Duration // How long a follower waits for a consumer to be provisioned (default: 2m)
+
+	// Canary mode
+	CanaryPercent int // Percentage (0-100) of records also run through candidate handlers and compared (default: 0, disabled)
+
+	// Config change dry runs
+	ConfigDiffSamples int // Last processed records kept per object type for /admin/config-diff dry runs (default: 0, disabled)
+
+	// Published payload schema inference
+	SchemaInferenceDir        string  // Directory the JSON Schemas inferred from published payloads are written to (default: none, disabled)
+	SchemaInferenceSampleRate float64 // Ratio of published payloads sampled for schema inference (default: 0.01)
+
+	// Mass purge safe mode
+	MassPurgeThreshold int // Hard deletes per minute above which delete propagation is paused (default: 5000, 0 disables)
+
+	// Access subject sharding
+	AccessSubjectShards int // Number of project shards suffixed to access message subjects (default: 0, flat subjects)
+
+	// Access message acknowledgments
+	AccessAckTimeout time.Duration // Timeout of fga-sync replies to access messages sent as requests (default: 0, published without replies)
+
+	// Downstream subject coverage
+	SubjectCoveragePolicy   string        // Handling of downstream subjects not covered by any stream: "warn" (default) or "fail" readiness
+	SubjectCoverageInterval time.Duration // Interval of the subject coverage checks (default: 5m, 0 disables)
+
+	// Acknowledged publishes
+	PublishAckTimeout time.Duration // Timeout of JetStream acknowledgments of published messages (default: 0, published with core NATS)
+	PublishAckRetries int           // Retries of unacknowledged JetStream publishes (default: 3)
+
+	// Publish subject allowlist
+	PublishSubjectAllowlist []string // NATS subject patterns messages may be published to (default: none, unrestricted)
+
+	// Outbound message signing
+	MessageSigningAlgorithm string // Signature algorithm of published messages: "hmac-sha256" or "ed25519" (default: none, disabled)
+	MessageSigningKey       string // HMAC secret, or Ed25519 private key (PEM, PKCS #8)
+	MessageSigningKeyID     string // Optional key ID sent with signatures, for key rotation
+
+	// Meeting type classification
+	MeetingTypeRules []meetingTypeRule // Ordered rules deriving canonical meeting types (MEETING_TYPE_RULES, default: built-in rules)
+
+	// Committee filters
+	CommitteeFiltersEmpty string // Voting statuses allowed by empty committee filters: "all" (default) or "none"
+
+	// Summary translation
+	SummaryTranslationProvider       string // Translation provider of non-English summaries (default: none, disabled)
+	SummaryTranslationURL            string // Endpoint of the http translation provider
+	SummaryTranslationTargetLanguage string // Language summaries are translated to (default: "en")
+
+	// Processing latency SLO
+	SLOLatencyTarget time.Duration // Latency from stream write to acknowledgment within which messages meet the SLO (default: 60s)
+	SLOObjective     float64       // Ratio of messages meeting the latency target (default: 0.99)
+
+	// Meeting snapshot enrichment
+	MeetingSnapshotEnrichment bool // Embed a snapshot of the parent meeting in registrant and invite response payloads (default: false)
+
+	// Meeting mapping batching
+	MeetingMappingBatchWindow time.Duration // Window coalescing the committee mapping updates of a meeting (default: 0, disabled)
+
+	// Past meeting attendee enrichment
+	AttendeeAutoMatchEnabled       bool    // Fuzzy match unidentified attendees to meeting registrants (default: false)
+	AttendeeAutoMatchMinConfidence float64 // Minimum confidence (0-1) to annotate an attendee match (default: 0.85)
+
+	// Project SFID fallback resolution
+	ProjectSFIDAPIFallback bool // Resolve missing project.sfid mappings through the v1 Project Service (default: false)
+
+	// Parked records
+	ParkedRecordMaxAge time.Duration // Age after which records still waiting for their parent mapping are dropped (default: 168h, 0 keeps them)
+
+	// Content deduplication
+	ContentDedupForce bool // Sync KV puts of records whose content is unchanged since they were last synced (default: false)
+
+	// Cascade jobs
+	CascadeJobRate float64 // Child records re-run by cascade jobs per replica and second (default: 20, 0 for no limit)
+
+	// Tracing
+	OTLPEndpoint    string            // OTLP/HTTP endpoint spans are exported to (default: none, disabled)
+	OTLPHeaders     map[string]string // Headers sent with exported spans, e.g. for authentication
+	OTELServiceName string            // Service name of the spans (default: "lfx-v1-sync-helper")
+	OTELSampleRatio float64           // Ratio of new traces sampled (default: 1)
+
+	// Project scoping (v1 project SFIDs or v2 project UIDs)
+	ProjectScopeAllow []string // If set, only records of these projects are synced
+	ProjectScopeDeny  []string // Records of these projects are never synced
+	PausedProjects    []string // Records of these projects are held until they are unpaused
 }
 
 // LoadConfig loads configuration from environment variables
@@ -129,15 +278,71 @@ func LoadConfig() (*Config, error) {
 		Auth0Tenant:     os.Getenv("AUTH0_TENANT"),
 		Auth0ClientID:   os.Getenv("AUTH0_CLIENT_ID"),
 		Auth0PrivateKey: os.Getenv("AUTH0_PRIVATE_KEY"),
+		// Zoom API configuration
+		ZoomAccountID:          os.Getenv("ZOOM_ACCOUNT_ID"),
+		ZoomClientID:           os.Getenv("ZOOM_CLIENT_ID"),
+		ZoomClientSecret:       os.Getenv("ZOOM_CLIENT_SECRET"),
+		ZoomBackfillMeetingIDs: bootstrap.ParseListEnv("ZOOM_BACKFILL_MEETING_IDS"),
+		// Transcript content indexing
+		TranscriptContentEnabled: bootstrap.ParseBooleanEnv("TRANSCRIPT_CONTENT_ENABLED"),
 		// Other configuration
 		NATSURL:               os.Getenv("NATS_URL"),
 		Port:                  os.Getenv("PORT"),
 		Bind:                  os.Getenv("BIND"),
-		Debug:                 parseBooleanEnv("DEBUG"),
-		HTTPDebug:             parseBooleanEnv("HTTP_DEBUG"),
-		UseMsgpack:            parseBooleanEnv("USE_MSGPACK"),
-		DynamoDBIngestEnabled: parseBooleanEnv("DYNAMODB_INGEST_ENABLED"),
+		Debug:                 bootstrap.ParseBooleanEnv("DEBUG"),
+		HTTPDebug:             bootstrap.ParseBooleanEnv("HTTP_DEBUG"),
+		LogScrubFields:        bootstrap.ParseListEnv("LOG_SCRUB_FIELDS"),
+		UseMsgpack:            bootstrap.ParseBooleanEnv("USE_MSGPACK"),
+		IndexerResultSubject:  os.Getenv("INDEXER_RESULT_SUBJECT"),
+		SkipPreflight:         bootstrap.ParseBooleanEnv("SKIP_PREFLIGHT"),
+		ConfigFile:            os.Getenv("CONFIG_FILE"),
+		DynamoDBIngestEnabled: bootstrap.ParseBooleanEnv("DYNAMODB_INGEST_ENABLED"),
 		DynamoDBStreamName:    os.Getenv("DYNAMODB_STREAM_NAME"),
+		ProjectScopeAllow:     bootstrap.ParseListEnv("PROJECT_SCOPE_ALLOW"),
+		ProjectScopeDeny:      bootstrap.ParseListEnv("PROJECT_SCOPE_DENY"),
+		PausedProjects:        bootstrap.ParseListEnv("PAUSED_PROJECTS"),
+		// Admin server configuration
+		AdminPort:     os.Getenv("ADMIN_PORT"),
+		AdminBind:     os.Getenv("ADMIN_BIND"),
+		AdminUsername: os.Getenv("ADMIN_USERNAME"),
+		AdminPassword: os.Getenv("ADMIN_PASSWORD"),
+		// Indexer messages
+		IndexerLegacyAuthorization: bootstrap.ParseBooleanEnv("INDEXER_LEGACY_AUTHORIZATION"),
+		IndexerMaxPayloadBytes:     bootstrap.ParseIntEnv("INDEXER_MAX_PAYLOAD_BYTES", 0),
+		IndexerOversizePolicy:      os.Getenv("INDEXER_OVERSIZE_POLICY"),
+		IndexerPayloadBucket:       os.Getenv("INDEXER_PAYLOAD_BUCKET"),
+		IndexerSyncWarnings:        bootstrap.ParseBooleanEnv("INDEXER_SYNC_WARNINGS"),
+		IndexerDocumentID:          os.Getenv("INDEXER_DOCUMENT_ID"),
+		// Indexer payload redaction
+		IndexerRedactionAllowlist: bootstrap.ParseListEnv("INDEXER_REDACTION_ALLOWLIST"),
+		// Publish targets
+		PublishTargets: bootstrap.ParseListEnv("PUBLISH_TARGETS"),
+		// Mappings
+		MappingsMirrorBucket:         os.Getenv("MAPPINGS_MIRROR_BUCKET"),
+		MappingsMemoryMirrorPrefixes: bootstrap.ParseListEnv("MAPPINGS_MEMORY_MIRROR_PREFIXES"),
+		// Downstream health
+		DownstreamHealthChecks:   bootstrap.ParseListEnv("DOWNSTREAM_HEALTH_CHECKS"),
+		DownstreamHealthInterval: defaultDownstreamHealthInterval,
+		// Startup
+		AcknowledgeRecreatedStreams: bootstrap.ParseListEnv("ACKNOWLEDGE_RECREATED_STREAMS"),
+		// Publish subject allowlist
+		PublishSubjectAllowlist: bootstrap.ParseListEnv("PUBLISH_SUBJECT_ALLOWLIST"),
+		// Outbound message signing
+		MessageSigningAlgorithm: os.Getenv("MESSAGE_SIGNING_ALGORITHM"),
+		MessageSigningKey:       os.Getenv("MESSAGE_SIGNING_KEY"),
+		MessageSigningKeyID:     os.Getenv("MESSAGE_SIGNING_KEY_ID"),
+		// Summary translation
+		SummaryTranslationProvider:       os.Getenv("SUMMARY_TRANSLATION_PROVIDER"),
+		SummaryTranslationURL:            os.Getenv("SUMMARY_TRANSLATION_URL"),
+		SummaryTranslationTargetLanguage: os.Getenv("SUMMARY_TRANSLATION_TARGET_LANGUAGE"),
+		// Meeting snapshot enrichment
+		MeetingSnapshotEnrichment: bootstrap.ParseBooleanEnv("MEETING_SNAPSHOT_ENRICHMENT"),
+		// Past meeting attendee enrichment
+		AttendeeAutoMatchEnabled: bootstrap.ParseBooleanEnv("ATTENDEE_AUTO_MATCH_ENABLED"),
+		// Project SFID fallback resolution
+		ProjectSFIDAPIFallback: bootstrap.ParseBooleanEnv("PROJECT_SFID_API_FALLBACK"),
+		// Content deduplication
+		ContentDedupForce: bootstrap.ParseBooleanEnv("CONTENT_DEDUP_FORCE"),
 	}
 
 	// Set defaults
@@ -153,11 +358,309 @@ func LoadConfig() (*Config, error) {
 		cfg.Bind = "*"
 	}
 
+	if cfg.AdminPort == "" {
+		cfg.AdminPort = "8081"
+	}
+
+	if cfg.AdminBind == "" {
+		cfg.AdminBind = "*"
+	}
+
+	if (cfg.AdminUsername == "") != (cfg.AdminPassword == "") {
+		return nil, fmt.Errorf("ADMIN_USERNAME and ADMIN_PASSWORD must be set together")
+	}
+
 	// Set defaults
 	if cfg.DynamoDBStreamName == "" {
 		cfg.DynamoDBStreamName = "dynamodb_streams"
 	}
 
+	ingestSourcePriorities, err := parseIngestSourcePriorities(os.Getenv("INGEST_SOURCE_PRIORITY"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.IngestSourcePriorities = ingestSourcePriorities
+
+	kvPrefixConsumers, err := parseKVPrefixConsumers(os.Getenv("KV_CONSUMER_PREFIXES"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.KVPrefixConsumers = kvPrefixConsumers
+
+	if kvFairnessWorkersStr := os.Getenv("KV_FAIRNESS_WORKERS"); kvFairnessWorkersStr != "" {
+		kvFairnessWorkers, err := strconv.Atoi(kvFairnessWorkersStr)
+		if err != nil || kvFairnessWorkers < 0 {
+			return nil, fmt.Errorf("KV_FAIRNESS_WORKERS must be a non-negative integer")
+		}
+		cfg.KVFairnessWorkers = kvFairnessWorkers
+	}
+
+	if handlerTimeoutStr := os.Getenv("HANDLER_TIMEOUT"); handlerTimeoutStr != "" {
+		handlerTimeout, err := time.ParseDuration(handlerTimeoutStr)
+		if err != nil || handlerTimeout < 0 {
+			return nil, fmt.Errorf("HANDLER_TIMEOUT must be a non-negative duration (e.g. 30s)")
+		}
+		cfg.HandlerTimeout = handlerTimeout
+	}
+	handlerTimeouts, err := parseHandlerTimeouts(os.Getenv("HANDLER_TIMEOUTS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.HandlerTimeouts = handlerTimeouts
+
+	meetingTypeRules, err := parseMeetingTypeRules(os.Getenv("MEETING_TYPE_RULES"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.MeetingTypeRules = meetingTypeRules
+
+	cfg.ConsumerProvisioning = os.Getenv("CONSUMER_PROVISIONING")
+	if cfg.ConsumerProvisioning == "" {
+		cfg.ConsumerProvisioning = consumerProvisioningAll
+	}
+	if err := validateConsumerProvisioning(cfg.ConsumerProvisioning); err != nil {
+		return nil, err
+	}
+	cfg.ConsumerProvisioningTimeout = defaultConsumerProvisioningTimeout
+	if provisioningTimeoutStr := os.Getenv("CONSUMER_PROVISIONING_TIMEOUT"); provisioningTimeoutStr != "" {
+		provisioningTimeout, err := time.ParseDuration(provisioningTimeoutStr)
+		if err != nil || provisioningTimeout <= 0 {
+			return nil, fmt.Errorf("CONSUMER_PROVISIONING_TIMEOUT must be a positive duration (e.g. 2m)")
+		}
+		cfg.ConsumerProvisioningTimeout = provisioningTimeout
+	}
+
+	if canaryPercentStr := os.Getenv("CANARY_PERCENT"); canaryPercentStr != "" {
+		canaryPercent, err := strconv.Atoi(canaryPercentStr)
+		if err != nil || canaryPercent < 0 || canaryPercent > 100 {
+			return nil, fmt.Errorf("CANARY_PERCENT must be an integer from 0 to 100")
+		}
+		cfg.CanaryPercent = canaryPercent
+	}
+
+	if configDiffSamplesStr := os.Getenv("CONFIG_DIFF_SAMPLES"); configDiffSamplesStr != "" {
+		configDiffSamples, err := strconv.Atoi(configDiffSamplesStr)
+		if err != nil || configDiffSamples < 0 {
+			return nil, fmt.Errorf("CONFIG_DIFF_SAMPLES must be a non-negative integer")
+		}
+		cfg.ConfigDiffSamples = configDiffSamples
+	}
+
+	cfg.SchemaInferenceDir = os.Getenv("SCHEMA_INFERENCE_DIR")
+	cfg.SchemaInferenceSampleRate = defaultSchemaInferenceSampleRate
+	if sampleRateStr := os.Getenv("SCHEMA_INFERENCE_SAMPLE_RATE"); sampleRateStr != "" {
+		sampleRate, err := strconv.ParseFloat(sampleRateStr, 64)
+		if err != nil || sampleRate <= 0 || sampleRate > 1 {
+			return nil, fmt.Errorf("SCHEMA_INFERENCE_SAMPLE_RATE must be a number greater than 0 and at most 1")
+		}
+		cfg.SchemaInferenceSampleRate = sampleRate
+	}
+
+	cfg.MassPurgeThreshold = defaultMassPurgeThreshold
+	if massPurgeThresholdStr := os.Getenv("MASS_PURGE_THRESHOLD"); massPurgeThresholdStr != "" {
+		massPurgeThreshold, err := strconv.Atoi(massPurgeThresholdStr)
+		if err != nil || massPurgeThreshold < 0 {
+			return nil, fmt.Errorf("MASS_PURGE_THRESHOLD must be a non-negative integer")
+		}
+		cfg.MassPurgeThreshold = massPurgeThreshold
+	}
+
+	if accessSubjectShardsStr := os.Getenv("ACCESS_SUBJECT_SHARDS"); accessSubjectShardsStr != "" {
+		accessSubjectShards, err := strconv.Atoi(accessSubjectShardsStr)
+		if err != nil || accessSubjectShards < 0 {
+			return nil, fmt.Errorf("ACCESS_SUBJECT_SHARDS must be a non-negative integer")
+		}
+		cfg.AccessSubjectShards = accessSubjectShards
+	}
+
+	if accessAckTimeoutStr := os.Getenv("ACCESS_ACK_TIMEOUT"); accessAckTimeoutStr != "" {
+		accessAckTimeout, err := time.ParseDuration(accessAckTimeoutStr)
+		if err != nil || accessAckTimeout < 0 {
+			return nil, fmt.Errorf("ACCESS_ACK_TIMEOUT must be a non-negative duration (e.g. 5s)")
+		}
+		cfg.AccessAckTimeout = accessAckTimeout
+	}
+
+	cfg.SubjectCoveragePolicy = os.Getenv("SUBJECT_COVERAGE_POLICY")
+	switch cfg.SubjectCoveragePolicy {
+	case "":
+		cfg.SubjectCoveragePolicy = subjectCoverageWarn
+	case subjectCoverageWarn, subjectCoverageFail:
+	default:
+		return nil, fmt.Errorf("SUBJECT_COVERAGE_POLICY must be %q or %q", subjectCoverageWarn, subjectCoverageFail)
+	}
+	cfg.SubjectCoverageInterval = defaultSubjectCoverageInterval
+	if subjectCoverageIntervalStr := os.Getenv("SUBJECT_COVERAGE_INTERVAL"); subjectCoverageIntervalStr != "" {
+		subjectCoverageInterval, err := time.ParseDuration(subjectCoverageIntervalStr)
+		if err != nil || subjectCoverageInterval < 0 {
+			return nil, fmt.Errorf("SUBJECT_COVERAGE_INTERVAL must be a non-negative duration (e.g. 5m)")
+		}
+		cfg.SubjectCoverageInterval = subjectCoverageInterval
+	}
+
+	if publishAckTimeoutStr := os.Getenv("PUBLISH_ACK_TIMEOUT"); publishAckTimeoutStr != "" {
+		publishAckTimeout, err := time.ParseDuration(publishAckTimeoutStr)
+		if err != nil || publishAckTimeout < 0 {
+			return nil, fmt.Errorf("PUBLISH_ACK_TIMEOUT must be a non-negative duration (e.g. 5s)")
+		}
+		cfg.PublishAckTimeout = publishAckTimeout
+	}
+	cfg.PublishAckRetries = defaultPublishAckRetries
+	if publishAckRetriesStr := os.Getenv("PUBLISH_ACK_RETRIES"); publishAckRetriesStr != "" {
+		publishAckRetries, err := strconv.Atoi(publishAckRetriesStr)
+		if err != nil || publishAckRetries < 0 {
+			return nil, fmt.Errorf("PUBLISH_ACK_RETRIES must be a non-negative integer")
+		}
+		cfg.PublishAckRetries = publishAckRetries
+	}
+
+	if batchWindowStr := os.Getenv("MEETING_MAPPING_BATCH_WINDOW"); batchWindowStr != "" {
+		batchWindow, err := time.ParseDuration(batchWindowStr)
+		if err != nil || batchWindow < 0 {
+			return nil, fmt.Errorf("MEETING_MAPPING_BATCH_WINDOW must be a non-negative duration (e.g. 2s)")
+		}
+		cfg.MeetingMappingBatchWindow = batchWindow
+	}
+
+	for i, prefix := range cfg.MappingsMemoryMirrorPrefixes {
+		prefix = strings.TrimSuffix(prefix, ".")
+		if prefix == "" || strings.ContainsAny(prefix, "*> ") {
+			return nil, fmt.Errorf("MAPPINGS_MEMORY_MIRROR_PREFIXES entries must be mapping key prefixes without wildcards (e.g. project.sfid)")
+		}
+		cfg.MappingsMemoryMirrorPrefixes[i] = prefix
+	}
+
+	if _, err := parseDownstreamHealthChecks(cfg.DownstreamHealthChecks); err != nil {
+		return nil, err
+	}
+	if intervalStr := os.Getenv("DOWNSTREAM_HEALTH_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("DOWNSTREAM_HEALTH_INTERVAL must be a positive duration (e.g. 15s)")
+		}
+		cfg.DownstreamHealthInterval = interval
+	}
+
+	if err := validatePublishSubjectAllowlist(cfg.PublishSubjectAllowlist); err != nil {
+		return nil, err
+	}
+
+	if err := bootstrap.ValidateLogScrubFields(cfg.LogScrubFields); err != nil {
+		return nil, fmt.Errorf("invalid LOG_SCRUB_FIELDS: %w", err)
+	}
+
+	if err := validateMessageSigningAlgorithm(cfg.MessageSigningAlgorithm); err != nil {
+		return nil, err
+	}
+	if cfg.MessageSigningAlgorithm != "" && cfg.MessageSigningKey == "" {
+		return nil, fmt.Errorf("MESSAGE_SIGNING_KEY environment variable is required when MESSAGE_SIGNING_ALGORITHM is set")
+	}
+
+	switch cfg.IndexerOversizePolicy {
+	case "":
+		cfg.IndexerOversizePolicy = indexerOversizeTruncate
+	case indexerOversizeTruncate, indexerOversizeObjectStore:
+	default:
+		return nil, fmt.Errorf("INDEXER_OVERSIZE_POLICY must be %q or %q", indexerOversizeTruncate, indexerOversizeObjectStore)
+	}
+
+	switch cfg.IndexerDocumentID {
+	case "":
+		cfg.IndexerDocumentID = indexerDocumentIDUID
+	case indexerDocumentIDUID, indexerDocumentIDNamespaced, indexerDocumentIDNone:
+	default:
+		return nil, fmt.Errorf("INDEXER_DOCUMENT_ID must be %q, %q, or %q", indexerDocumentIDUID, indexerDocumentIDNamespaced, indexerDocumentIDNone)
+	}
+
+	cfg.CommitteeFiltersEmpty = os.Getenv("COMMITTEE_FILTERS_EMPTY")
+	switch cfg.CommitteeFiltersEmpty {
+	case "":
+		cfg.CommitteeFiltersEmpty = committeeFiltersEmptyAll
+	case committeeFiltersEmptyAll, committeeFiltersEmptyNone:
+	default:
+		return nil, fmt.Errorf("COMMITTEE_FILTERS_EMPTY must be %q or %q", committeeFiltersEmptyAll, committeeFiltersEmptyNone)
+	}
+
+	if cfg.IndexerPayloadBucket == "" {
+		cfg.IndexerPayloadBucket = "v1-indexer-payloads"
+	}
+
+	if err := validateRedactionAllowlist(cfg.IndexerRedactionAllowlist); err != nil {
+		return nil, err
+	}
+
+	if _, err := parsePublishTargets(cfg.PublishTargets); err != nil {
+		return nil, err
+	}
+
+	cfg.SLOLatencyTarget = defaultSLOLatencyTarget
+	if sloLatencyTargetStr := os.Getenv("SLO_LATENCY_TARGET"); sloLatencyTargetStr != "" {
+		sloLatencyTarget, err := time.ParseDuration(sloLatencyTargetStr)
+		if err != nil || sloLatencyTarget <= 0 {
+			return nil, fmt.Errorf("SLO_LATENCY_TARGET must be a positive duration (e.g. 60s)")
+		}
+		cfg.SLOLatencyTarget = sloLatencyTarget
+	}
+	cfg.SLOObjective = defaultSLOObjective
+	if sloObjectiveStr := os.Getenv("SLO_OBJECTIVE"); sloObjectiveStr != "" {
+		sloObjective, err := strconv.ParseFloat(sloObjectiveStr, 64)
+		if err != nil || sloObjective <= 0 || sloObjective >= 1 {
+			return nil, fmt.Errorf("SLO_OBJECTIVE must be a number greater than 0 and less than 1")
+		}
+		cfg.SLOObjective = sloObjective
+	}
+
+	cfg.ParkedRecordMaxAge = defaultParkedRecordMaxAge
+	if parkedRecordMaxAgeStr := os.Getenv("PARKED_RECORD_MAX_AGE"); parkedRecordMaxAgeStr != "" {
+		parkedRecordMaxAge, err := time.ParseDuration(parkedRecordMaxAgeStr)
+		if err != nil || parkedRecordMaxAge < 0 {
+			return nil, fmt.Errorf("PARKED_RECORD_MAX_AGE must be a non-negative duration (e.g. 168h)")
+		}
+		cfg.ParkedRecordMaxAge = parkedRecordMaxAge
+	}
+
+	cfg.CascadeJobRate = defaultCascadeJobRate
+	if cascadeJobRateStr := os.Getenv("CASCADE_JOB_RATE"); cascadeJobRateStr != "" {
+		cascadeJobRate, err := strconv.ParseFloat(cascadeJobRateStr, 64)
+		if err != nil || cascadeJobRate < 0 {
+			return nil, fmt.Errorf("CASCADE_JOB_RATE must be a non-negative number")
+		}
+		cfg.CascadeJobRate = cascadeJobRate
+	}
+
+	cfg.OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if cfg.OTLPEndpoint != "" {
+		if endpointURL, err := url.Parse(cfg.OTLPEndpoint); err != nil || endpointURL.Host == "" {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT must be a URL (e.g. http://otel-collector:4318)")
+		}
+	}
+	otlpHeaders, err := parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.OTLPHeaders = otlpHeaders
+	cfg.OTELServiceName = os.Getenv("OTEL_SERVICE_NAME")
+	if cfg.OTELServiceName == "" {
+		cfg.OTELServiceName = defaultOTELServiceName
+	}
+	cfg.OTELSampleRatio = 1
+	if sampleRatioStr := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); sampleRatioStr != "" {
+		sampleRatio, err := strconv.ParseFloat(sampleRatioStr, 64)
+		if err != nil || sampleRatio < 0 || sampleRatio > 1 {
+			return nil, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a number from 0 to 1")
+		}
+		cfg.OTELSampleRatio = sampleRatio
+	}
+
+	cfg.AttendeeAutoMatchMinConfidence = 0.85
+	if minConfidenceStr := os.Getenv("ATTENDEE_AUTO_MATCH_MIN_CONFIDENCE"); minConfidenceStr != "" {
+		minConfidence, err := strconv.ParseFloat(minConfidenceStr, 64)
+		if err != nil || minConfidence <= 0 || minConfidence > 1 {
+			return nil, fmt.Errorf("ATTENDEE_AUTO_MATCH_MIN_CONFIDENCE must be a number greater than 0 and at most 1")
+		}
+		cfg.AttendeeAutoMatchMinConfidence = minConfidence
+	}
+
 	if cfg.HeimdallClientID == "" {
 		cfg.HeimdallClientID = "v1_sync_helper"
 	}
@@ -194,6 +697,43 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("AUTH0_PRIVATE_KEY environment variable is required")
 	}
 
+	// Validate Zoom configuration when backfill is enabled
+	if len(cfg.ZoomBackfillMeetingIDs) > 0 {
+		if cfg.ZoomAccountID == "" || cfg.ZoomClientID == "" || cfg.ZoomClientSecret == "" {
+			return nil, fmt.Errorf("ZOOM_ACCOUNT_ID, ZOOM_CLIENT_ID, and ZOOM_CLIENT_SECRET environment variables are required when ZOOM_BACKFILL_MEETING_IDS is set")
+		}
+	}
+
+	cfg.TranscriptContentMaxBytes = defaultTranscriptContentMaxBytes
+	if maxBytesStr := os.Getenv("TRANSCRIPT_CONTENT_MAX_BYTES"); maxBytesStr != "" {
+		maxBytes, err := strconv.Atoi(maxBytesStr)
+		if err != nil || maxBytes <= 0 {
+			return nil, fmt.Errorf("TRANSCRIPT_CONTENT_MAX_BYTES must be a positive integer")
+		}
+		cfg.TranscriptContentMaxBytes = maxBytes
+	}
+	cfg.TranscriptContentDownloadsPerMinute = defaultTranscriptContentDownloadsPerMinute
+	if downloadsStr := os.Getenv("TRANSCRIPT_CONTENT_DOWNLOADS_PER_MINUTE"); downloadsStr != "" {
+		downloads, err := strconv.Atoi(downloadsStr)
+		if err != nil || downloads <= 0 {
+			return nil, fmt.Errorf("TRANSCRIPT_CONTENT_DOWNLOADS_PER_MINUTE must be a positive integer")
+		}
+		cfg.TranscriptContentDownloadsPerMinute = downloads
+	}
+	if cfg.TranscriptContentEnabled && (cfg.ZoomAccountID == "" || cfg.ZoomClientID == "" || cfg.ZoomClientSecret == "") {
+		return nil, fmt.Errorf("ZOOM_ACCOUNT_ID, ZOOM_CLIENT_ID, and ZOOM_CLIENT_SECRET environment variables are required when TRANSCRIPT_CONTENT_ENABLED is set")
+	}
+
+	if err := validateSummaryTranslationProvider(cfg.SummaryTranslationProvider); err != nil {
+		return nil, err
+	}
+	if cfg.SummaryTranslationProvider == "http" && cfg.SummaryTranslationURL == "" {
+		return nil, fmt.Errorf("SUMMARY_TRANSLATION_URL environment variable is required when SUMMARY_TRANSLATION_PROVIDER is http")
+	}
+	if cfg.SummaryTranslationTargetLanguage == "" {
+		cfg.SummaryTranslationTargetLanguage = defaultSummaryTranslationTargetLanguage
+	}
+
 	// Validate service URLs
 	if projectServiceURLStr == "" {
 		return nil, fmt.Errorf("PROJECT_SERVICE_URL environment variable is required")
@@ -216,19 +756,3 @@ func LoadConfig() (*Config, error) {
 
 	return cfg, nil
 }
-
-// parseBooleanEnv parses a boolean environment variable with common truthy values.
-// Returns true if the value (case-insensitive) is "true", "yes", "t", "y", or "1".
-// Returns false for any other value including empty string.
-//
-// Examples:
-//   - parseBooleanEnv("USE_MSGPACK") where USE_MSGPACK="true" returns true
-//   - parseBooleanEnv("USE_MSGPACK") where USE_MSGPACK="YES" returns true
-//   - parseBooleanEnv("USE_MSGPACK") where USE_MSGPACK="1" returns true
-//   - parseBooleanEnv("USE_MSGPACK") where USE_MSGPACK="false" returns false
-//   - parseBooleanEnv("USE_MSGPACK") where USE_MSGPACK="" returns false
-func parseBooleanEnv(envVar string) bool {
-	value := strings.ToLower(strings.TrimSpace(os.Getenv(envVar)))
-	truthyValues := []string{"true", "yes", "t", "y", "1"}
-	return slices.Contains(truthyValues, value)
-}
@@ -0,0 +1,200 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Config change dry runs. With CONFIG_DIFF_SAMPLES set, the last records
+// processed of each object type are kept in memory, and the
+// /admin/config-diff endpoint takes a proposed runtime config (in the schema
+// of the CONFIG_FILE config file, omitted settings keeping their current
+// value) and runs those records through the handlers twice, in dry runs: with
+// the current runtime settings, then with the proposed ones. The messages, KV
+// writes, and v2 API writes of the two runs are compared as in canary mode,
+// and the records whose side effects would change are reported by object
+// type, before the config file is edited in production.
+//
+// Both runs read mappings and related records at their current state, and
+// meeting and summary fingerprints are hidden, so unchanged records are still
+// synced. Only the settings reloadable from the config file can be proposed.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+// configDiffMaxBody bounds the size of a proposed config.
+const configDiffMaxBody = 1 << 20
+
+var (
+	// configDiffSamplesMu guards configDiffSamples.
+	configDiffSamplesMu sync.Mutex
+	// configDiffSamples are the last records processed by object type,
+	// oldest first.
+	configDiffSamples = map[string][]*kvEntry{}
+)
+
+// captureConfigDiffSample keeps a processed record for config change dry
+// runs, when they are enabled.
+func captureConfigDiffSample(objectType string, entry *kvEntry) {
+	if cfg.ConfigDiffSamples <= 0 {
+		return
+	}
+	configDiffSamplesMu.Lock()
+	defer configDiffSamplesMu.Unlock()
+	samples := append(configDiffSamples[objectType], entry)
+	if len(samples) > cfg.ConfigDiffSamples {
+		samples = samples[len(samples)-cfg.ConfigDiffSamples:]
+	}
+	configDiffSamples[objectType] = samples
+}
+
+// configDiffRecord is a record whose side effects would change with the
+// proposed config.
+type configDiffRecord struct {
+	Key         string   `json:"key"`
+	Differences []string `json:"differences"`
+}
+
+// configDiffObjectType is the config diff of the samples of an object type.
+type configDiffObjectType struct {
+	Samples int                `json:"samples"`
+	Changed int                `json:"changed"`
+	Errors  int                `json:"errors"`
+	Records []configDiffRecord `json:"records"`
+}
+
+// configDiffResponse is the response body of the /admin/config-diff
+// endpoint.
+type configDiffResponse struct {
+	// Changes are the changed settings, as "old -> new" strings.
+	Changes     map[string]string                `json:"changes"`
+	Samples     int                              `json:"samples"`
+	Changed     int                              `json:"changed"`
+	ObjectTypes map[string]*configDiffObjectType `json:"object_types"`
+}
+
+// runConfigDiffSample runs a record through the handlers with the given
+// runtime settings in a dry run, returning its side effects, with its retry
+// decision.
+func runConfigDiffSample(ctx context.Context, entry *kvEntry, s *runtimeSettings) (effects map[string]any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	recorder := &dryRunRecorder{
+		hiddenPrefixes: []string{
+			strings.TrimSuffix(meetingFingerprintKeyFmt, "%s"),
+			strings.TrimSuffix(summaryFingerprintKeyFmt, "%s"),
+		},
+	}
+	retry := kvHandler(withDryRun(withRuntimeSettings(ctx, s), recorder), entry)
+	effects = canarySideEffects(recorder)
+	effects["retry"] = retry
+	return effects, nil
+}
+
+// diffConfig runs the sampled records with the current and the proposed
+// runtime settings, and reports the records whose side effects differ.
+func diffConfig(ctx context.Context, current, next *runtimeSettings, objectType string) configDiffResponse {
+	response := configDiffResponse{
+		Changes:     diffRuntimeSettings(current, next),
+		ObjectTypes: map[string]*configDiffObjectType{},
+	}
+
+	configDiffSamplesMu.Lock()
+	samples := map[string][]*kvEntry{}
+	for sampleType, entries := range configDiffSamples {
+		if objectType == "" || objectType == sampleType {
+			samples[sampleType] = append([]*kvEntry{}, entries...)
+		}
+	}
+	configDiffSamplesMu.Unlock()
+
+	objectTypes := make([]string, 0, len(samples))
+	for sampleType := range samples {
+		objectTypes = append(objectTypes, sampleType)
+	}
+	sort.Strings(objectTypes)
+
+	for _, sampleType := range objectTypes {
+		report := &configDiffObjectType{Records: []configDiffRecord{}}
+		response.ObjectTypes[sampleType] = report
+		for _, entry := range samples[sampleType] {
+			if ctx.Err() != nil {
+				return response
+			}
+			report.Samples++
+			response.Samples++
+
+			currentEffects, currentErr := runConfigDiffSample(ctx, entry, current)
+			proposedEffects, proposedErr := runConfigDiffSample(ctx, entry, next)
+			if err := errors.Join(currentErr, proposedErr); err != nil {
+				report.Errors++
+				logger.With(errKey, err, "key", entry.Key()).ErrorContext(ctx, "config diff dry run failed")
+				continue
+			}
+
+			// diffSideEffects labels the proposed run as the candidate.
+			differences := diffSideEffects(currentEffects, proposedEffects)
+			if len(differences) == 0 {
+				continue
+			}
+			if len(differences) > canaryMaxDifferences {
+				differences = append(differences[:canaryMaxDifferences], fmt.Sprintf("... %d more", len(differences)-canaryMaxDifferences))
+			}
+			report.Changed++
+			response.Changed++
+			report.Records = append(report.Records, configDiffRecord{Key: entry.Key(), Differences: differences})
+		}
+	}
+	return response
+}
+
+// configDiffHandler serves the config diff of the proposed runtime config in
+// the request body, for the sampled records of every object type, or of the
+// object_type query parameter.
+func configDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cfg.ConfigDiffSamples <= 0 {
+		http.Error(w, "config diff dry runs are disabled (set CONFIG_DIFF_SAMPLES)", http.StatusNotFound)
+		return
+	}
+
+	var proposed runtimeConfigFile
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, configDiffMaxBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&proposed); err != nil {
+		http.Error(w, "invalid proposed config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current := settings()
+	next, err := proposed.apply(current)
+	if err != nil {
+		http.Error(w, "invalid proposed config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := bootstrap.MessageContext(r.Context(), nil)
+	response := diffConfig(ctx, current, next, strings.TrimSpace(r.URL.Query().Get("object_type")))
+	logger.With("changes", response.Changes, "samples", response.Samples, "changed", response.Changed).InfoContext(ctx, "config diff dry run completed")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to encode config diff response")
+	}
+}
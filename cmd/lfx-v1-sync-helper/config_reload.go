@@ -0,0 +1,267 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Runtime configuration reload from a mounted config file (e.g. a Kubernetes
+// ConfigMap volume). Only non-connection settings may be reloaded: debug
+// logging, project scope and pauses, rate limits, feature toggles, and meeting
+// type rules. Clients, consumers, and credentials still require a restart.
+//
+// The file is JSON, and any setting it omits falls back to the value from the
+// environment. The file is polled rather than watched with inotify, because
+// ConfigMap volume updates are applied by atomically swapping a symlink, which
+// is reliably detected by comparing content hashes.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+const configReloadInterval = 10 * time.Second
+
+// runtimeSettings holds the settings which can be changed without a restart.
+// A settings value is immutable once published; reloads swap in a new value.
+type runtimeSettings struct {
+	Debug             bool     `json:"debug"`
+	ProjectScopeAllow []string `json:"project_scope_allow"`
+	ProjectScopeDeny  []string `json:"project_scope_deny"`
+	PausedProjects    []string `json:"paused_projects"`
+
+	// Rate limits.
+	CascadeJobRate                      float64 `json:"cascade_job_rate"`
+	TranscriptContentDownloadsPerMinute int     `json:"transcript_content_downloads_per_minute"`
+
+	// Feature toggles.
+	IndexerSyncWarnings            bool    `json:"indexer_sync_warnings"`
+	AttendeeAutoMatchEnabled       bool    `json:"attendee_auto_match_enabled"`
+	AttendeeAutoMatchMinConfidence float64 `json:"attendee_auto_match_min_confidence"`
+	ContentDedupForce              bool    `json:"content_dedup_force"`
+
+	// MeetingTypeRules derive the canonical meeting types, and so the
+	// canonical_meeting_type tags, of meetings.
+	MeetingTypeRules []meetingTypeRule `json:"meeting_type_rules"`
+}
+
+// runtimeConfigFile is the schema of the config file. Nil fields are not set
+// in the file and fall back to the environment.
+type runtimeConfigFile struct {
+	Debug             *bool     `json:"debug"`
+	ProjectScopeAllow *[]string `json:"project_scope_allow"`
+	ProjectScopeDeny  *[]string `json:"project_scope_deny"`
+	PausedProjects    *[]string `json:"paused_projects"`
+
+	CascadeJobRate                      *float64 `json:"cascade_job_rate"`
+	TranscriptContentDownloadsPerMinute *int     `json:"transcript_content_downloads_per_minute"`
+
+	IndexerSyncWarnings            *bool    `json:"indexer_sync_warnings"`
+	AttendeeAutoMatchEnabled       *bool    `json:"attendee_auto_match_enabled"`
+	AttendeeAutoMatchMinConfidence *float64 `json:"attendee_auto_match_min_confidence"`
+	ContentDedupForce              *bool    `json:"content_dedup_force"`
+
+	MeetingTypeRules *[]meetingTypeRule `json:"meeting_type_rules"`
+}
+
+var (
+	// logLevel is the dynamic level of the service logger.
+	logLevel = new(slog.LevelVar)
+
+	currentRuntimeSettings atomic.Pointer[runtimeSettings]
+	configFileHash         [sha256.Size]byte
+)
+
+// settings returns the current runtime settings.
+func settings() *runtimeSettings {
+	return currentRuntimeSettings.Load()
+}
+
+// runtimeSettingsContextKey is the context key of runtime settings overriding
+// the current ones.
+type runtimeSettingsContextKey struct{}
+
+// withRuntimeSettings returns a context whose handlers use the given runtime
+// settings instead of the current ones, e.g. to dry run proposed settings.
+func withRuntimeSettings(ctx context.Context, s *runtimeSettings) context.Context {
+	return context.WithValue(ctx, runtimeSettingsContextKey{}, s)
+}
+
+// contextSettings returns the runtime settings of the context, or the current
+// runtime settings.
+func contextSettings(ctx context.Context) *runtimeSettings {
+	if s, ok := ctx.Value(runtimeSettingsContextKey{}).(*runtimeSettings); ok {
+		return s
+	}
+	return settings()
+}
+
+// envRuntimeSettings returns the runtime settings from the environment.
+func envRuntimeSettings(cfg *Config) *runtimeSettings {
+	return &runtimeSettings{
+		Debug:             cfg.Debug,
+		ProjectScopeAllow: cfg.ProjectScopeAllow,
+		ProjectScopeDeny:  cfg.ProjectScopeDeny,
+		PausedProjects:    cfg.PausedProjects,
+
+		CascadeJobRate:                      cfg.CascadeJobRate,
+		TranscriptContentDownloadsPerMinute: cfg.TranscriptContentDownloadsPerMinute,
+
+		IndexerSyncWarnings:            cfg.IndexerSyncWarnings,
+		AttendeeAutoMatchEnabled:       cfg.AttendeeAutoMatchEnabled,
+		AttendeeAutoMatchMinConfidence: cfg.AttendeeAutoMatchMinConfidence,
+		ContentDedupForce:              cfg.ContentDedupForce,
+
+		MeetingTypeRules: cfg.MeetingTypeRules,
+	}
+}
+
+// initRuntimeSettings publishes the initial runtime settings from the
+// environment, overridden by the config file if one is configured. An invalid
+// config file at startup is logged and the environment settings are used.
+func initRuntimeSettings(ctx context.Context, cfg *Config) {
+	applyRuntimeSettings(envRuntimeSettings(cfg))
+	if cfg.ConfigFile == "" {
+		return
+	}
+	if _, err := reloadConfigFile(ctx, cfg); err != nil {
+		logger.With(errKey, err, "path", cfg.ConfigFile).ErrorContext(ctx, "failed to load config file, using environment settings")
+	}
+}
+
+// watchConfigFile polls the config file and reloads it when its content
+// changes, until the context is canceled.
+func watchConfigFile(ctx context.Context, cfg *Config) {
+	ticker := time.NewTicker(configReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := reloadConfigFile(ctx, cfg); err != nil {
+				logger.With(errKey, err, "path", cfg.ConfigFile).ErrorContext(ctx, "failed to reload config file, keeping current settings")
+			}
+		}
+	}
+}
+
+// reloadConfigFile reads the config file and, if its content changed since
+// the last load, publishes the new settings. Returns whether settings were
+// reloaded.
+func reloadConfigFile(ctx context.Context, cfg *Config) (bool, error) {
+	data, err := os.ReadFile(cfg.ConfigFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	if hash == configFileHash {
+		return false, nil
+	}
+
+	var file runtimeConfigFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	next, err := file.apply(envRuntimeSettings(cfg))
+	if err != nil {
+		return false, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	changes := diffRuntimeSettings(settings(), next)
+	applyRuntimeSettings(next)
+	configFileHash = hash
+
+	logger.With("path", cfg.ConfigFile, "changes", changes).InfoContext(ctx, "config file reloaded")
+	return true, nil
+}
+
+// apply returns the given settings overridden by the settings set in the
+// file, validated as their environment variables are.
+func (file runtimeConfigFile) apply(base *runtimeSettings) (*runtimeSettings, error) {
+	next := *base
+	if file.Debug != nil {
+		next.Debug = *file.Debug
+	}
+	if file.ProjectScopeAllow != nil {
+		next.ProjectScopeAllow = *file.ProjectScopeAllow
+	}
+	if file.ProjectScopeDeny != nil {
+		next.ProjectScopeDeny = *file.ProjectScopeDeny
+	}
+	if file.PausedProjects != nil {
+		next.PausedProjects = *file.PausedProjects
+	}
+	if file.CascadeJobRate != nil {
+		if *file.CascadeJobRate < 0 {
+			return nil, fmt.Errorf("cascade_job_rate must be a non-negative number")
+		}
+		next.CascadeJobRate = *file.CascadeJobRate
+	}
+	if file.TranscriptContentDownloadsPerMinute != nil {
+		if *file.TranscriptContentDownloadsPerMinute <= 0 {
+			return nil, fmt.Errorf("transcript_content_downloads_per_minute must be a positive integer")
+		}
+		next.TranscriptContentDownloadsPerMinute = *file.TranscriptContentDownloadsPerMinute
+	}
+	if file.IndexerSyncWarnings != nil {
+		next.IndexerSyncWarnings = *file.IndexerSyncWarnings
+	}
+	if file.AttendeeAutoMatchEnabled != nil {
+		next.AttendeeAutoMatchEnabled = *file.AttendeeAutoMatchEnabled
+	}
+	if file.AttendeeAutoMatchMinConfidence != nil {
+		if minConfidence := *file.AttendeeAutoMatchMinConfidence; minConfidence <= 0 || minConfidence > 1 {
+			return nil, fmt.Errorf("attendee_auto_match_min_confidence must be a number greater than 0 and at most 1")
+		}
+		next.AttendeeAutoMatchMinConfidence = *file.AttendeeAutoMatchMinConfidence
+	}
+	if file.ContentDedupForce != nil {
+		next.ContentDedupForce = *file.ContentDedupForce
+	}
+	if file.MeetingTypeRules != nil {
+		if err := validateMeetingTypeRules("meeting_type_rules", *file.MeetingTypeRules); err != nil {
+			return nil, err
+		}
+		next.MeetingTypeRules = *file.MeetingTypeRules
+	}
+	return &next, nil
+}
+
+// applyRuntimeSettings atomically publishes the given settings.
+func applyRuntimeSettings(next *runtimeSettings) {
+	if next.Debug {
+		logLevel.Set(slog.LevelDebug)
+	} else {
+		logLevel.Set(slog.LevelInfo)
+	}
+	currentRuntimeSettings.Store(next)
+}
+
+// diffRuntimeSettings returns the changed settings, keyed by their config
+// file name, as "old -> new" strings.
+func diffRuntimeSettings(prev, next *runtimeSettings) map[string]string {
+	changes := map[string]string{}
+	if prev == nil {
+		prev = &runtimeSettings{}
+	}
+
+	prevValue := reflect.ValueOf(*prev)
+	nextValue := reflect.ValueOf(*next)
+	for i := 0; i < prevValue.NumField(); i++ {
+		oldField, newField := prevValue.Field(i).Interface(), nextValue.Field(i).Interface()
+		if !reflect.DeepEqual(oldField, newField) {
+			name := prevValue.Type().Field(i).Tag.Get("json")
+			changes[name] = fmt.Sprintf("%v -> %v", oldField, newField)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,146 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Consumer backlog gauges, so an autoscaler such as KEDA (with its Prometheus
+// scaler) can scale the number of replicas with the JetStream backlog depth.
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// consumerInfoTimeout bounds the time spent fetching consumer info.
+	consumerInfoTimeout = 5 * time.Second
+	// consumerInfoTTL is how long fetched consumer info is reused, so several
+	// gauges (and concurrent scrapes) share a single request per consumer.
+	consumerInfoTTL = 5 * time.Second
+
+	// allObjectTypes is the object_type label of consumers which are not
+	// filtered to a single object type.
+	allObjectTypes = "all"
+)
+
+// syncConsumer identifies a durable JetStream consumer of the service.
+type syncConsumer struct {
+	name   string
+	stream string
+}
+
+// consumerInfoResult is the fetched info of a consumer, or the error fetching it.
+type consumerInfoResult struct {
+	info *jetstream.ConsumerInfo
+	err  error
+}
+
+var (
+	consumerInfoMu        sync.Mutex
+	consumerInfoCache     map[string]consumerInfoResult
+	consumerInfoFetchedAt time.Time
+)
+
+var (
+	consumerPendingMessages = newGaugeFunc(
+		"v1_sync_helper_consumer_pending_messages",
+		"Number of JetStream messages not yet delivered to the consumer, by consumer, stream, and object type.",
+		func() []gaugeSample {
+			return consumerGaugeSamples(func(info *jetstream.ConsumerInfo) float64 { return float64(info.NumPending) })
+		},
+		"consumer", "stream", "object_type",
+	)
+	consumerAckPendingMessages = newGaugeFunc(
+		"v1_sync_helper_consumer_ack_pending_messages",
+		"Number of JetStream messages delivered to the consumer and awaiting acknowledgement, by consumer, stream, and object type.",
+		func() []gaugeSample {
+			return consumerGaugeSamples(func(info *jetstream.ConsumerInfo) float64 { return float64(info.NumAckPending) })
+		},
+		"consumer", "stream", "object_type",
+	)
+)
+
+// syncConsumers returns the durable consumers enabled by the configuration.
+func syncConsumers() []syncConsumer {
+	consumers := []syncConsumer{
+		{name: kvConsumerName, stream: "KV_v1-objects"},
+		{name: walConsumerName, stream: "wal_listener"},
+	}
+	for _, prefix := range kvPrefixes() {
+		consumers = append(consumers, syncConsumer{name: kvPrefixConsumerName(prefix), stream: "KV_v1-objects"})
+	}
+	if cfg != nil && cfg.DynamoDBIngestEnabled {
+		consumers = append(consumers, syncConsumer{name: dynamodbConsumerName, stream: cfg.DynamoDBStreamName})
+	}
+	return consumers
+}
+
+// consumerInfos returns the info of each consumer by name, fetching it from
+// the server at most once per consumerInfoTTL.
+func consumerInfos(ctx context.Context) map[string]consumerInfoResult {
+	consumerInfoMu.Lock()
+	defer consumerInfoMu.Unlock()
+
+	if consumerInfoCache != nil && time.Since(consumerInfoFetchedAt) < consumerInfoTTL {
+		return consumerInfoCache
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, consumerInfoTimeout)
+	defer cancel()
+
+	results := map[string]consumerInfoResult{}
+	for _, c := range syncConsumers() {
+		if jsContext == nil {
+			continue
+		}
+		consumer, err := jsContext.Consumer(ctx, c.stream, c.name)
+		if err != nil {
+			results[c.name] = consumerInfoResult{err: err}
+			continue
+		}
+		info, err := consumer.Info(ctx)
+		results[c.name] = consumerInfoResult{info: info, err: err}
+	}
+
+	consumerInfoCache = results
+	consumerInfoFetchedAt = time.Now()
+	return results
+}
+
+// consumerGaugeSamples returns one sample per consumer whose info could be
+// fetched, with the value extracted from its info.
+func consumerGaugeSamples(value func(info *jetstream.ConsumerInfo) float64) []gaugeSample {
+	infos := consumerInfos(context.Background())
+
+	samples := []gaugeSample{}
+	for _, c := range syncConsumers() {
+		result, ok := infos[c.name]
+		if !ok || result.err != nil || result.info == nil {
+			continue
+		}
+		samples = append(samples, gaugeSample{
+			labelValues: []string{c.name, c.stream, consumerObjectType(result.info.Config)},
+			value:       value(result.info),
+		})
+	}
+	return samples
+}
+
+// consumerObjectType returns the v1 object type a consumer is filtered to, or
+// allObjectTypes when it is not filtered to a single v1-objects key prefix.
+func consumerObjectType(config jetstream.ConsumerConfig) string {
+	filter := config.FilterSubject
+	if len(config.FilterSubjects) == 1 {
+		filter = config.FilterSubjects[0]
+	}
+	prefix, ok := strings.CutPrefix(filter, "$KV.v1-objects.")
+	if !ok || prefix == "" || prefix == ">" {
+		return allObjectTypes
+	}
+	return kvObjectType(prefix)
+}
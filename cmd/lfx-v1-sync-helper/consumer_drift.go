@@ -0,0 +1,176 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Consumer configuration drift detection. The durable consumers are shared by
+// all replicas and can be edited out of band (e.g. with the nats CLI), so
+// their live configuration is compared with the one the service expects, on
+// startup and periodically. Drift in mutable settings (filter subject, ack
+// wait, delivery limits) is corrected in place. Drift in settings the server
+// does not allow updating (deliver and ack policies) is only reported, as
+// recreating the consumer would replay the whole stream.
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// consumerDriftCheckInterval is how often the live consumer configurations
+// are checked for drift.
+const consumerDriftCheckInterval = 5 * time.Minute
+
+var consumerConfigDrift = newCounterVec(
+	"v1_sync_helper_consumer_config_drift_total",
+	"Number of consumer configuration drifts detected, by consumer, field, and action (corrected or incompatible).",
+	"consumer", "field", "action",
+)
+
+// expectedConsumers holds the configuration each consumer was ensured with,
+// by stream and consumer name.
+var expectedConsumers sync.Map
+
+// expectedConsumer is the expected configuration of a consumer on a stream.
+type expectedConsumer struct {
+	stream string
+	config jetstream.ConsumerConfig
+}
+
+// ensureConsumer creates the consumer, or corrects its configuration when it
+// drifted, and records the configuration for the periodic drift checks. When
+// the live consumer has drifted in settings which cannot be updated, it is
+// left untouched and returned as is, and the drift is reported. In the leader
+// provisioning mode, only the replica holding the provisioning lock of the
+// consumer does so (see consumer_provisioning.go).
+func ensureConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	expectedConsumers.Store(stream+"."+config.Durable, expectedConsumer{stream: stream, config: config})
+
+	if cfg.ConsumerProvisioning == consumerProvisioningLeader {
+		return awaitProvisionedConsumer(ctx, stream, config)
+	}
+	return provisionConsumer(ctx, stream, config)
+}
+
+// correctConsumerDrift updates the consumer back to its expected configuration,
+// keeping the start sequence of a consumer created by the bootstrap subcommand.
+func correctConsumerDrift(ctx context.Context, stream string, config, live jetstream.ConsumerConfig, fields []string) (jetstream.Consumer, error) {
+	if live.DeliverPolicy == jetstream.DeliverByStartSequencePolicy {
+		config.DeliverPolicy, config.OptStartSeq = live.DeliverPolicy, live.OptStartSeq
+	}
+	logger.With("consumer", config.Durable, "stream", stream, "fields", fields).WarnContext(ctx, "consumer configuration drifted, correcting it")
+	consumer, err := jsContext.UpdateConsumer(ctx, stream, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to correct consumer configuration: %w", err)
+	}
+	for _, field := range fields {
+		consumerConfigDrift.inc(config.Durable, field, "corrected")
+	}
+	return consumer, nil
+}
+
+// reportIncompatibleConsumerDrift reports drift which cannot be corrected
+// without recreating the consumer.
+func reportIncompatibleConsumerDrift(ctx context.Context, stream, name string, fields []string) {
+	logger.With("consumer", name, "stream", stream, "fields", fields).ErrorContext(ctx,
+		"consumer configuration drifted in settings which cannot be updated; recreating the consumer would replay the stream, so it must be fixed manually")
+	for _, field := range fields {
+		consumerConfigDrift.inc(name, field, "incompatible")
+	}
+}
+
+// consumerConfigDiff returns the settings of the live consumer configuration
+// differing from the expected one, split by whether the server allows
+// updating them. Only the settings the service sets are compared.
+func consumerConfigDiff(expected, live jetstream.ConsumerConfig) (mutable, immutable []string) {
+	// The consumers created by the bootstrap subcommand start after its
+	// backfill, instead of from the last value of every key.
+	bootstrapped := live.DeliverPolicy == jetstream.DeliverByStartSequencePolicy && expected.DeliverPolicy == jetstream.DeliverLastPerSubjectPolicy
+	if live.DeliverPolicy != expected.DeliverPolicy && !bootstrapped {
+		immutable = append(immutable, "deliver_policy")
+	}
+	if live.AckPolicy != expected.AckPolicy {
+		immutable = append(immutable, "ack_policy")
+	}
+	if live.FilterSubject != expected.FilterSubject || !slices.Equal(live.FilterSubjects, expected.FilterSubjects) {
+		mutable = append(mutable, "filter_subject")
+	}
+	if live.AckWait != expected.AckWait {
+		mutable = append(mutable, "ack_wait")
+	}
+	if live.MaxDeliver != expected.MaxDeliver {
+		mutable = append(mutable, "max_deliver")
+	}
+	if live.MaxAckPending != expected.MaxAckPending {
+		mutable = append(mutable, "max_ack_pending")
+	}
+	if live.Description != expected.Description {
+		mutable = append(mutable, "description")
+	}
+	return mutable, immutable
+}
+
+// watchConsumerDrift checks the ensured consumers for configuration drift
+// every consumerDriftCheckInterval until the context is cancelled.
+func watchConsumerDrift(ctx context.Context) {
+	ticker := time.NewTicker(consumerDriftCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		expectedConsumers.Range(func(_, value any) bool {
+			expected := value.(expectedConsumer)
+			checkConsumerDrift(ctx, expected.stream, expected.config)
+			return ctx.Err() == nil
+		})
+	}
+}
+
+// checkConsumerDrift compares the live configuration of a consumer with the
+// expected one, correcting or reporting any drift. In the leader provisioning
+// mode, the check is skipped while another replica holds the provisioning
+// lock of the consumer.
+func checkConsumerDrift(ctx context.Context, stream string, config jetstream.ConsumerConfig) {
+	ctx, cancel := context.WithTimeout(ctx, consumerInfoTimeout)
+	defer cancel()
+
+	if cfg.ConsumerProvisioning == consumerProvisioningLeader {
+		lockKey := consumerProvisioningLockKey(stream, config.Durable)
+		if acquired, _ := distributedSync.acquire(ctx, lockKey); !acquired {
+			return
+		}
+		defer func() {
+			if err := distributedSync.release(ctx, lockKey); err != nil {
+				logger.With(errKey, err, "consumer", config.Durable, "stream", stream).WarnContext(ctx, "failed to release consumer provisioning lock")
+			}
+		}()
+	}
+
+	consumer, err := jsContext.Consumer(ctx, stream, config.Durable)
+	if err != nil {
+		logger.With(errKey, err, "consumer", config.Durable, "stream", stream).WarnContext(ctx, "failed to get consumer for drift check")
+		return
+	}
+
+	live := consumer.CachedInfo().Config
+	mutable, immutable := consumerConfigDiff(config, live)
+	if len(immutable) > 0 {
+		reportIncompatibleConsumerDrift(ctx, stream, config.Durable, immutable)
+		return
+	}
+	if len(mutable) > 0 {
+		if _, err := correctConsumerDrift(ctx, stream, config, live, mutable); err != nil {
+			logger.With(errKey, err, "consumer", config.Durable, "stream", stream).ErrorContext(ctx, "failed to correct consumer configuration drift")
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Consumer provisioning at startup. During a rollout, several replicas start
+// at once and all create (or correct) the shared durable consumers. Creation
+// is retried with jittered backoff, and a consumer created concurrently by
+// another replica is compared with the expected configuration rather than
+// overwritten, so it is only updated when it actually differs.
+//
+// With CONSUMER_PROVISIONING=leader, provisioning is serialized through a lock
+// in the mappings bucket: the replica holding the lock of a consumer (the
+// leader) creates or updates it, while the others (followers) wait for the
+// consumer to exist with the expected configuration, without updating it
+// themselves, and take over if the lock is released before it does. Periodic
+// drift corrections also need the lock, so replicas never update a consumer
+// concurrently.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// consumerProvisioningAll has every replica provision the consumers.
+	consumerProvisioningAll = "all"
+
+	// consumerProvisioningLeader has the replica holding the provisioning lock
+	// of a consumer provision it, while the others wait.
+	consumerProvisioningLeader = "leader"
+
+	// defaultConsumerProvisioningTimeout is how long a follower waits for a
+	// consumer to be provisioned by default.
+	defaultConsumerProvisioningTimeout = 2 * time.Minute
+
+	// consumerProvisioningLockKeyPrefix prefixes the mappings KV keys of the
+	// consumer provisioning locks, followed by the stream and consumer names.
+	consumerProvisioningLockKeyPrefix = "v1_sync_helper_consumer_provisioning."
+
+	// consumerCreateAttempts is how many times creating a consumer is tried.
+	consumerCreateAttempts = 5
+
+	// consumerCreateBackoff is the base delay between consumer creation
+	// attempts, and between follower checks, doubled on each attempt up to
+	// consumerCreateMaxBackoff, plus up to the same amount of jitter.
+	consumerCreateBackoff    = 250 * time.Millisecond
+	consumerCreateMaxBackoff = 4 * time.Second
+)
+
+var consumerProvisioning = newCounterVec(
+	"v1_sync_helper_consumer_provisioning_total",
+	"Number of consumer provisionings, at startup or when recreating a consumer, by consumer and result (created, updated, unchanged, followed, or error).",
+	"consumer", "result",
+)
+
+// validateConsumerProvisioning checks the CONSUMER_PROVISIONING mode.
+func validateConsumerProvisioning(mode string) error {
+	switch mode {
+	case consumerProvisioningAll, consumerProvisioningLeader:
+		return nil
+	}
+	return fmt.Errorf("CONSUMER_PROVISIONING must be %q or %q", consumerProvisioningAll, consumerProvisioningLeader)
+}
+
+// consumerProvisioningLockKey returns the mappings KV key of the provisioning
+// lock of a consumer.
+func consumerProvisioningLockKey(stream, name string) string {
+	return consumerProvisioningLockKeyPrefix + stream + "." + name
+}
+
+// consumerCreateDelay returns the jittered delay before an attempt.
+func consumerCreateDelay(attempt int) time.Duration {
+	delay := min(consumerCreateBackoff<<(attempt-1), consumerCreateMaxBackoff)
+	return delay + rand.N(delay)
+}
+
+// sleepContext waits for d, or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// provisionConsumer creates the consumer, retrying with jittered backoff, or
+// corrects its configuration when it drifted. A consumer created concurrently
+// by another replica is compared with the expected configuration like an
+// existing one.
+func provisionConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	var lastErr error
+	for attempt := 1; attempt <= consumerCreateAttempts; attempt++ {
+		if attempt > 1 {
+			if err := sleepContext(ctx, consumerCreateDelay(attempt-1)); err != nil {
+				return nil, err
+			}
+		}
+
+		existing, err := jsContext.Consumer(ctx, stream, config.Durable)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			var consumer jetstream.Consumer
+			consumer, err = jsContext.CreateConsumer(ctx, stream, config)
+			if err == nil {
+				consumerProvisioning.inc(config.Durable, "created")
+				return consumer, nil
+			}
+			// Created by another replica meanwhile; compared on the next
+			// attempt.
+		}
+		if err != nil {
+			lastErr = err
+			logger.With(errKey, err, "consumer", config.Durable, "stream", stream, "attempt", attempt).WarnContext(ctx, "failed to provision consumer")
+			continue
+		}
+
+		live := existing.CachedInfo().Config
+		mutable, immutable := consumerConfigDiff(config, live)
+		if len(immutable) > 0 {
+			reportIncompatibleConsumerDrift(ctx, stream, config.Durable, immutable)
+			consumerProvisioning.inc(config.Durable, "unchanged")
+			return existing, nil
+		}
+		if len(mutable) == 0 {
+			consumerProvisioning.inc(config.Durable, "unchanged")
+			return existing, nil
+		}
+		consumer, err := correctConsumerDrift(ctx, stream, config, live, mutable)
+		if err != nil {
+			consumerProvisioning.inc(config.Durable, "error")
+			return nil, err
+		}
+		consumerProvisioning.inc(config.Durable, "updated")
+		return consumer, nil
+	}
+	consumerProvisioning.inc(config.Durable, "error")
+	return nil, fmt.Errorf("failed to provision consumer after %d attempts: %w", consumerCreateAttempts, lastErr)
+}
+
+// awaitProvisionedConsumer provisions the consumer while holding its
+// provisioning lock, or, while another replica holds it, waits for the
+// consumer to exist with the expected configuration, until
+// CONSUMER_PROVISIONING_TIMEOUT.
+func awaitProvisionedConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	log := logger.With("consumer", config.Durable, "stream", stream)
+	lockKey := consumerProvisioningLockKey(stream, config.Durable)
+	deadline := time.Now().Add(cfg.ConsumerProvisioningTimeout)
+
+	for attempt := 1; ; attempt++ {
+		if acquired, _ := distributedSync.acquire(ctx, lockKey); acquired {
+			consumer, err := provisionConsumer(ctx, stream, config)
+			if err := distributedSync.release(ctx, lockKey); err != nil {
+				log.With(errKey, err).WarnContext(ctx, "failed to release consumer provisioning lock")
+			}
+			return consumer, err
+		}
+
+		// Another replica is provisioning the consumer.
+		if existing, err := jsContext.Consumer(ctx, stream, config.Durable); err == nil {
+			mutable, immutable := consumerConfigDiff(config, existing.CachedInfo().Config)
+			if len(immutable) > 0 {
+				reportIncompatibleConsumerDrift(ctx, stream, config.Durable, immutable)
+				consumerProvisioning.inc(config.Durable, "followed")
+				return existing, nil
+			}
+			if len(mutable) == 0 {
+				consumerProvisioning.inc(config.Durable, "followed")
+				return existing, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			consumerProvisioning.inc(config.Durable, "error")
+			return nil, fmt.Errorf("timed out waiting for consumer %s to be provisioned by another replica", config.Durable)
+		}
+		log.With("attempt", attempt).InfoContext(ctx, "waiting for another replica to provision the consumer")
+		if err := sleepContext(ctx, consumerCreateDelay(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Consumer supervision. When a consumer hits an unrecoverable error (e.g. it
+// was deleted server-side), JetStream stops its Consume() subscription, and
+// the service would stay up without making progress. Supervised consumers are
+// stopped and recreated instead, with bounded retries, and if they cannot be
+// recreated the service shuts down with a failure, so it is restarted.
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// consumerRestartAttempts is how many times a consumer is recreated after
+	// an unrecoverable error before the service gives up.
+	consumerRestartAttempts = 5
+
+	// consumerRestartBackoff is the delay before the first recreation
+	// attempt, doubled on each failed attempt.
+	consumerRestartBackoff = 2 * time.Second
+)
+
+var consumerRestarts = newCounterVec(
+	"v1_sync_helper_consumer_restarts_total",
+	"Number of consumer recreation attempts after unrecoverable consume errors, by consumer and result (success or error).",
+	"consumer", "result",
+)
+
+// supervisedConsumer is a durable consumer whose Consume() subscription is
+// recreated after unrecoverable errors.
+type supervisedConsumer struct {
+	stream  string
+	config  jetstream.ConsumerConfig
+	handler jetstream.MessageHandler
+
+	mu         sync.Mutex
+	consumeCtx jetstream.ConsumeContext
+	stopped    bool
+
+	// restart is signalled by the consume error handler on unrecoverable
+	// errors.
+	restart chan error
+}
+
+// startSupervisedConsumer creates (or gets) a durable consumer and starts
+// consuming it, restarting it on unrecoverable errors until the context is
+// cancelled. If a restart fails, terminate is called with the error.
+func startSupervisedConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig, handler jetstream.MessageHandler, terminate func(error)) (*supervisedConsumer, error) {
+	c := &supervisedConsumer{
+		stream:  stream,
+		config:  config,
+		handler: handler,
+		restart: make(chan error, 1),
+	}
+	if err := c.consume(ctx); err != nil {
+		return nil, err
+	}
+	backgroundTasks.setState(c.taskName(), taskRunning, "")
+	go c.supervise(ctx, terminate)
+	return c, nil
+}
+
+// consume ensures the consumer exists and starts a Consume() subscription,
+// replacing any previous one.
+func (c *supervisedConsumer) consume(ctx context.Context) error {
+	consumer, err := ensureConsumer(ctx, c.stream, c.config)
+	if err != nil {
+		return err
+	}
+	consumeCtx, err := consumer.Consume(c.handler, jetstream.ConsumeErrHandler(c.handleConsumeError))
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		consumeCtx.Stop()
+		return nil
+	}
+	if c.consumeCtx != nil {
+		// Release the goroutines of the failed subscription.
+		c.consumeCtx.Stop()
+	}
+	c.consumeCtx = consumeCtx
+	return nil
+}
+
+// handleConsumeError logs consume errors, and schedules a restart on
+// unrecoverable ones.
+func (c *supervisedConsumer) handleConsumeError(_ jetstream.ConsumeContext, err error) {
+	logger.With(errKey, err, "consumer", c.config.Durable, "stream", c.stream).Error("consumer error encountered")
+	if !isUnrecoverableConsumeError(err) {
+		return
+	}
+	select {
+	case c.restart <- err:
+	default:
+		// A restart is already pending.
+	}
+}
+
+// isUnrecoverableConsumeError reports whether a consume error stops the
+// Consume() subscription.
+func isUnrecoverableConsumeError(err error) bool {
+	return errors.Is(err, jetstream.ErrConsumerDeleted) ||
+		errors.Is(err, jetstream.ErrConsumerNotFound) ||
+		errors.Is(err, jetstream.ErrBadRequest)
+}
+
+// taskName is the name of the consumer in the background task states.
+func (c *supervisedConsumer) taskName() string {
+	return "consumer." + c.config.Durable
+}
+
+// supervise recreates the consumer after unrecoverable errors until the
+// context is cancelled, reflecting its state in the background task states.
+func (c *supervisedConsumer) supervise(ctx context.Context, terminate func(error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			backgroundTasks.setState(c.taskName(), taskStopped, "")
+			return
+		case cause := <-c.restart:
+			log := logger.With("consumer", c.config.Durable, "stream", c.stream)
+			log.With(errKey, cause).WarnContext(ctx, "consumer stopped on an unrecoverable error, recreating it")
+			backgroundTasks.setState(c.taskName(), taskRestarting, cause.Error())
+			if err := c.recreate(ctx); err != nil {
+				if ctx.Err() != nil {
+					backgroundTasks.setState(c.taskName(), taskStopped, "")
+					return
+				}
+				log.With(errKey, err).ErrorContext(ctx, "failed to recreate consumer, shutting down")
+				backgroundTasks.setState(c.taskName(), taskFailed, err.Error())
+				terminate(err)
+				return
+			}
+			backgroundTasks.setState(c.taskName(), taskRunning, "")
+			log.InfoContext(ctx, "consumer recreated")
+		}
+	}
+}
+
+// recreate restarts the consumer, retrying with exponential backoff.
+func (c *supervisedConsumer) recreate(ctx context.Context) error {
+	backoff := consumerRestartBackoff
+	var err error
+	for attempt := 1; attempt <= consumerRestartAttempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if err = c.consume(ctx); err == nil {
+			consumerRestarts.inc(c.config.Durable, "success")
+			return nil
+		}
+		consumerRestarts.inc(c.config.Durable, "error")
+		logger.With(errKey, err, "consumer", c.config.Durable, "attempt", attempt).WarnContext(ctx, "failed to recreate consumer")
+		backoff *= 2
+	}
+	return err
+}
+
+// Drain drains the current Consume() subscription, without blocking.
+func (c *supervisedConsumer) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.consumeCtx != nil {
+		c.consumeCtx.Drain()
+	}
+}
+
+// Stop stops the current Consume() subscription.
+func (c *supervisedConsumer) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.consumeCtx != nil {
+		c.consumeCtx.Stop()
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Content deduplication. Meltano re-extracts unchanged rows, and every one of
+// these no-op KV puts used to be synced again, republishing identical
+// indexer and access messages. The SHA-256 hash of the content of each record
+// synced from the KV consumers (excluding the _sdc_ extraction metadata) is
+// kept in the mappings bucket under contentHashKeyPrefix, once its message is
+// acknowledged, and KV puts of an unchanged record are acknowledged without
+// being synced. The settings changing the published messages (redaction,
+// meeting type rules, and so on) and contentConversionVersion are hashed with
+// the content, so changing them, by a restart or a config file reload, syncs
+// unchanged records again. A put only counts as synced when its handler did
+// not request a retry and published its messages without failures; handlers
+// giving up on a record publish nothing. CONTENT_DEDUP_FORCE syncs them
+// anyway. Replays, parked and held record releases, cascade job
+// re-runs, and dry runs are always synced; deletes, and syncs which failed
+// (including re-runs), forget the hash of their record, so its next put is
+// synced even if unchanged.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// contentHashKeyPrefix prefixes the mappings KV keys of the content hashes
+	// of synced records, followed by their v1-objects key.
+	contentHashKeyPrefix = "v1_content_hashes."
+
+	// contentConversionVersion is hashed with the content of records. Bump it
+	// with handler changes which must reach the records already synced.
+	contentConversionVersion = 1
+)
+
+var contentDedupResults = newCounterVec(
+	"v1_sync_helper_content_dedup_total",
+	"Number of KV puts checked for unchanged content, by object type and result (changed, unchanged for skipped puts, or forced for unchanged puts synced per CONTENT_DEDUP_FORCE).",
+	"object_type", "result",
+)
+
+// contentDedup holds the content hash of a KV put being synced, stored once
+// its handler synced it and its message is acknowledged.
+type contentDedup struct {
+	key     string
+	hash    string
+	stored  bool
+	skipped bool
+	synced  bool
+}
+
+// contentDedupContextKey is the context key of the content deduplication of a
+// KV message.
+type contentDedupContextKey struct{}
+
+// withContentDedup returns a context skipping the sync of unchanged KV puts,
+// and recording the content hash of changed ones in the returned value.
+func withContentDedup(ctx context.Context) (context.Context, *contentDedup) {
+	dedup := &contentDedup{}
+	return context.WithValue(ctx, contentDedupContextKey{}, dedup), dedup
+}
+
+// contentConversion holds the settings changing the messages published for
+// a record, hashed with its content.
+type contentConversion struct {
+	Version int `json:"version"`
+
+	// Environment settings.
+	IndexerLegacyAuthorization       bool     `json:"indexer_legacy_authorization"`
+	IndexerMaxPayloadBytes           int      `json:"indexer_max_payload_bytes"`
+	IndexerOversizePolicy            string   `json:"indexer_oversize_policy"`
+	IndexerDocumentID                string   `json:"indexer_document_id"`
+	IndexerRedactionAllowlist        []string `json:"indexer_redaction_allowlist"`
+	AccessSubjectShards              int      `json:"access_subject_shards"`
+	MeetingSnapshotEnrichment        bool     `json:"meeting_snapshot_enrichment"`
+	CommitteeFiltersEmpty            string   `json:"committee_filters_empty"`
+	TranscriptContentEnabled         bool     `json:"transcript_content_enabled"`
+	SummaryTranslationProvider       string   `json:"summary_translation_provider"`
+	SummaryTranslationTargetLanguage string   `json:"summary_translation_target_language"`
+
+	// Runtime settings, reloaded from the config file.
+	IndexerSyncWarnings            bool              `json:"indexer_sync_warnings"`
+	AttendeeAutoMatchEnabled       bool              `json:"attendee_auto_match_enabled"`
+	AttendeeAutoMatchMinConfidence float64           `json:"attendee_auto_match_min_confidence"`
+	MeetingTypeRules               []meetingTypeRule `json:"meeting_type_rules"`
+}
+
+// currentContentConversion returns the settings changing the messages
+// published for the records synced with the context.
+func currentContentConversion(ctx context.Context) contentConversion {
+	runtime := contextSettings(ctx)
+	return contentConversion{
+		Version:                          contentConversionVersion,
+		IndexerLegacyAuthorization:       cfg.IndexerLegacyAuthorization,
+		IndexerMaxPayloadBytes:           cfg.IndexerMaxPayloadBytes,
+		IndexerOversizePolicy:            cfg.IndexerOversizePolicy,
+		IndexerDocumentID:                cfg.IndexerDocumentID,
+		IndexerRedactionAllowlist:        cfg.IndexerRedactionAllowlist,
+		AccessSubjectShards:              cfg.AccessSubjectShards,
+		MeetingSnapshotEnrichment:        cfg.MeetingSnapshotEnrichment,
+		CommitteeFiltersEmpty:            cfg.CommitteeFiltersEmpty,
+		TranscriptContentEnabled:         cfg.TranscriptContentEnabled,
+		SummaryTranslationProvider:       cfg.SummaryTranslationProvider,
+		SummaryTranslationTargetLanguage: cfg.SummaryTranslationTargetLanguage,
+		IndexerSyncWarnings:              runtime.IndexerSyncWarnings,
+		AttendeeAutoMatchEnabled:         runtime.AttendeeAutoMatchEnabled,
+		AttendeeAutoMatchMinConfidence:   runtime.AttendeeAutoMatchMinConfidence,
+		MeetingTypeRules:                 runtime.MeetingTypeRules,
+	}
+}
+
+// contentHash returns the SHA-256 hash of the content of a record, without
+// its _sdc_ extraction metadata, and of the settings converting it.
+func contentHash(ctx context.Context, v1Data map[string]any) (string, error) {
+	content := maps.Clone(v1Data)
+	maps.DeleteFunc(content, func(field string, _ any) bool {
+		return strings.HasPrefix(field, "_sdc_")
+	})
+	contentBytes, err := json.Marshal(struct {
+		Conversion contentConversion `json:"conversion"`
+		Content    map[string]any    `json:"content"`
+	}{currentContentConversion(ctx), content})
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(contentBytes), nil
+}
+
+// skipUnchangedContent reports whether the sync of a KV put can be skipped,
+// its content being unchanged since it was last synced. Records whose content
+// cannot be hashed are synced.
+func skipUnchangedContent(ctx context.Context, prefix, key string, v1Data map[string]any) bool {
+	dedup, ok := ctx.Value(contentDedupContextKey{}).(*contentDedup)
+	if !ok {
+		return false
+	}
+	hash, err := contentHash(ctx, v1Data)
+	if err != nil {
+		logger.With(errKey, err, "key", key).DebugContext(ctx, "failed to hash record content")
+		return false
+	}
+	dedup.key, dedup.hash = key, hash
+
+	entry, err := mappingsKV.Get(ctx, contentHashKeyPrefix+key)
+	dedup.stored = err == nil
+	if err != nil || string(entry.Value()) != hash {
+		contentDedupResults.inc(prefix, "changed")
+		return false
+	}
+	if contextSettings(ctx).ContentDedupForce {
+		contentDedupResults.inc(prefix, "forced")
+		return false
+	}
+	contentDedupResults.inc(prefix, "unchanged")
+	dedup.skipped = true
+	logger.With("key", key).DebugContext(ctx, "record content unchanged, skipping sync")
+	return true
+}
+
+// recordContentSync records whether the handler of a KV put synced it. The
+// content hash of a record whose sync failed is forgotten: when its KV
+// message is settled, or right away for re-runs outside of the KV consumers.
+func recordContentSync(ctx context.Context, key string, synced bool) {
+	if dedup, ok := ctx.Value(contentDedupContextKey{}).(*contentDedup); ok {
+		dedup.synced = synced
+		return
+	}
+	if !synced {
+		forgetContentHash(ctx, key)
+	}
+}
+
+// commit stores the content hash of a synced KV put, or forgets the stored
+// one of a KV put whose sync failed or is retried.
+func (d *contentDedup) commit(ctx context.Context, retried bool) {
+	switch {
+	case d.skipped || d.hash == "":
+	case d.synced && !retried:
+		if _, err := mappingsKV.Put(ctx, contentHashKeyPrefix+d.key, []byte(d.hash)); err != nil {
+			logger.With(errKey, err, "key", d.key).WarnContext(ctx, "failed to store record content hash")
+		}
+	case d.stored:
+		forgetContentHash(ctx, d.key)
+	}
+}
+
+// forgetContentHash removes the content hash of a record, if any, so it is
+// synced again even if unchanged.
+func forgetContentHash(ctx context.Context, key string) {
+	if contextDryRun(ctx) != nil {
+		return
+	}
+	// Deleting a missing key would still write a delete marker.
+	if _, err := mappingsKV.Get(ctx, contentHashKeyPrefix+key); err != nil {
+		return
+	}
+	if err := mappingsKV.Delete(ctx, contentHashKeyPrefix+key); err != nil && !errors.Is(err, jetstream.ErrKeyNotFound) {
+		logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to delete record content hash")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	ctx := context.Background()
+	setupHandlerTest(t)
+
+	record := map[string]any{"id": "91234567890", "topic": "Weekly sync"}
+	base, err := contentHash(ctx, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() map[string]any
+		same   bool
+	}{
+		{
+			name: "extraction metadata",
+			change: func() map[string]any {
+				return map[string]any{"id": "91234567890", "topic": "Weekly sync", "_sdc_extracted_at": "2026-01-01T00:00:00Z"}
+			},
+			same: true,
+		},
+		{
+			name: "content",
+			change: func() map[string]any {
+				return map[string]any{"id": "91234567890", "topic": "Monthly sync"}
+			},
+		},
+		{
+			name: "redaction allowlist",
+			change: func() map[string]any {
+				cfg.IndexerRedactionAllowlist = []string{"password"}
+				return record
+			},
+		},
+		{
+			name: "meeting type rules",
+			change: func() map[string]any {
+				runtime := *settings()
+				runtime.MeetingTypeRules = []meetingTypeRule{{MeetingType: "Board", V1MeetingTypes: []string{"Board"}}}
+				applyRuntimeSettings(&runtime)
+				return record
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previousCfg, previousSettings := *cfg, settings()
+			t.Cleanup(func() {
+				*cfg = previousCfg
+				applyRuntimeSettings(previousSettings)
+			})
+
+			hash, err := contentHash(ctx, tt.change())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hash == base; got != tt.same {
+				t.Errorf("same hash: got %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Mapping dependency tracing. Finding out why a record is not synced (or is
+// synced with stale parent data) meant reading the v1-mappings and v1-objects
+// entries of its dependency chain one by one. The trace_v1_dependencies
+// endpoint (and /admin/dependencies on the admin server) walks the chain of a
+// v1-objects key instead: the record, its own mapping, the parent mappings
+// required by its key prefix (and whether it is parked on one of them), the
+// parent records with their own parents in turn, e.g. registrant -> meeting
+// -> project, and the v1 user it references, through any merged user alias.
+// Each link reports whether it exists, its value, revision, and timestamp.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// maxDependencyTraceDepth bounds the parent records traced, in case parents
+// depend on each other.
+const maxDependencyTraceDepth = 4
+
+// traceUserFields are the fields of the records referencing a v1 user ID, by
+// key prefix.
+var traceUserFields = map[string]string{
+	"itx-zoom-meetings-registrants-v2":      "user_id",
+	"itx-zoom-meetings-registrants-v3":      "user_id",
+	"itx-zoom-meetings-invite-responses-v2": "user_id",
+	"itx-zoom-past-meetings-invitees":       "lf_user_id",
+	"itx-zoom-past-meetings-attendees":      "lf_user_id",
+}
+
+// dependencyTrace is the dependency chain of a v1-objects record.
+type dependencyTrace struct {
+	Record traceLink `json:"record"`
+	// Mapping is the primary v1-mappings entry of the record, if its key
+	// prefix has one keyed by the record ID.
+	Mapping      *traceLink       `json:"mapping,omitempty"`
+	Dependencies []dependencyLink `json:"dependencies"`
+	User         *userTrace       `json:"user,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// dependencyLink is a parent mapping required by a record.
+type dependencyLink struct {
+	Parent   string    `json:"parent"`
+	Field    string    `json:"field"`
+	ParentID string    `json:"parent_id"`
+	Mapping  traceLink `json:"mapping"`
+	// Parked is the parked record entry, if the record is parked until the
+	// parent mapping appears.
+	Parked *traceLink `json:"parked,omitempty"`
+	// Trace is the dependency chain of the parent record.
+	Trace *dependencyTrace `json:"trace,omitempty"`
+}
+
+// userTrace is the v1 user referenced by a record.
+type userTrace struct {
+	Field  string `json:"field"`
+	UserID string `json:"user_id"`
+	// Alias is the merged user alias of the user ID, if it was merged.
+	Alias  *traceLink `json:"alias,omitempty"`
+	Record traceLink  `json:"record"`
+}
+
+// traceLink is a KV entry of the dependency chain.
+type traceLink struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Exists bool   `json:"exists"`
+	// Tombstoned is whether a mapping is a delete marker; Deleted whether a
+	// v1 record is soft deleted.
+	Tombstoned bool            `json:"tombstoned,omitempty"`
+	Deleted    bool            `json:"deleted,omitempty"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Revision   uint64          `json:"revision,omitempty"`
+	Updated    *time.Time      `json:"updated,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// traceKVEntry reads a KV entry of the dependency chain, returning the entry
+// when it exists.
+func traceKVEntry(ctx context.Context, kv jetstream.KeyValue, key string) (traceLink, jetstream.KeyValueEntry) {
+	link := traceLink{Bucket: kv.Bucket(), Key: key}
+	entry, err := kv.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) && !errors.Is(err, jetstream.ErrKeyDeleted) {
+			link.Error = err.Error()
+		}
+		return link, nil
+	}
+	updated := entry.Created().UTC()
+	link.Exists = true
+	link.Revision = entry.Revision()
+	link.Updated = &updated
+	return link, entry
+}
+
+// traceMapping reads a v1-mappings entry of the dependency chain.
+func traceMapping(ctx context.Context, key string) traceLink {
+	link, entry := traceKVEntry(ctx, mappingsKV, key)
+	if entry == nil {
+		return link
+	}
+	link.Tombstoned = isTombstonedMapping(entry.Value())
+	link.Value = rawJSON(entry.Value())
+	return link
+}
+
+// traceRecord reads a v1-objects record of the dependency chain, returning
+// its data when it can be decoded.
+func traceRecord(ctx context.Context, key string) (traceLink, map[string]any) {
+	link, entry := traceKVEntry(ctx, v1KV, key)
+	if entry == nil {
+		return link, nil
+	}
+	link.Value = decodeRecord(key, entry.Value())
+	var v1Data map[string]any
+	if err := json.Unmarshal(link.Value, &v1Data); err != nil {
+		link.Error = "failed to decode record: " + err.Error()
+		return link, nil
+	}
+	if deletedAt, exists := v1Data["_sdc_deleted_at"]; exists && deletedAt != nil && deletedAt != "" {
+		link.Deleted = true
+	}
+	return link, v1Data
+}
+
+// traceDependencies walks the dependency chain of a v1-objects key.
+func traceDependencies(ctx context.Context, key string, depth int) *dependencyTrace {
+	trace := &dependencyTrace{Dependencies: []dependencyLink{}}
+	record, v1Data := traceRecord(ctx, key)
+	trace.Record = record
+
+	prefix, id, ok := strings.Cut(key, ".")
+	table, registered := kvTableHandlers[prefix]
+	if !ok || !registered {
+		trace.Error = fmt.Sprintf("no handler for key prefix %q", prefix)
+		return trace
+	}
+	if len(table.mappings) > 0 && strings.Count(table.mappings[0], "%s") == 1 {
+		mapping := traceMapping(ctx, fmt.Sprintf(table.mappings[0], id))
+		trace.Mapping = &mapping
+	}
+	if v1Data == nil {
+		return trace
+	}
+
+	for _, dependency := range table.requires {
+		link := dependencyLink{
+			Parent:   dependency.parent.name,
+			Field:    dependency.field,
+			ParentID: v1FieldString(v1Data, dependency.field),
+		}
+		if link.ParentID == "" {
+			trace.Dependencies = append(trace.Dependencies, link)
+			continue
+		}
+		mappingKey := fmt.Sprintf(dependency.parent.keyFmt, link.ParentID)
+		link.Mapping = traceMapping(ctx, mappingKey)
+		if parked := traceMapping(ctx, parkedRecordKeyPrefix+mappingKey+"."+key); parked.Exists {
+			link.Parked = &parked
+		}
+		if depth < maxDependencyTraceDepth {
+			link.Trace = traceDependencies(ctx, dependency.parent.prefix+"."+link.ParentID, depth+1)
+		}
+		trace.Dependencies = append(trace.Dependencies, link)
+	}
+
+	if field, ok := traceUserFields[prefix]; ok {
+		if userID := v1FieldString(v1Data, field); userID != "" {
+			user := &userTrace{Field: field, UserID: userID}
+			if alias := traceMapping(ctx, mergedUserAliasKey(userID)); alias.Exists {
+				user.Alias = &alias
+			}
+			user.Record, _ = traceRecord(ctx, "salesforce-merged_user."+resolveMergedV1UserID(ctx, userID))
+			trace.User = user
+		}
+	}
+	return trace
+}
+
+// traceDependenciesHandler serves the dependency chain of a v1-objects key,
+// given as the request payload, as JSON.
+func traceDependenciesHandler(req micro.Request) {
+	ctx := bootstrap.MessageContext(context.Background(), nats.Header(req.Headers()))
+	key := strings.TrimSpace(string(req.Data()))
+	if key == "" {
+		if err := req.Error("400", "v1-objects key required", nil); err != nil {
+			logger.With(errKey, err).ErrorContext(ctx, "failed to respond to dependency trace request")
+		}
+		return
+	}
+	if err := req.RespondJSON(traceDependencies(ctx, key, 0)); err != nil {
+		logger.With(errKey, err, "key", key).ErrorContext(ctx, "failed to respond to dependency trace request")
+	}
+}
+
+// dependencyTraceHandler serves the dependency chain of the v1-objects key
+// given by the key query parameter.
+func dependencyTraceHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.URL.Query().Get("key"))
+	if key == "" {
+		http.Error(w, "key query parameter required", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(traceDependencies(r.Context(), key, 0)); err != nil {
+		logger.With(errKey, err, "key", key).ErrorContext(r.Context(), "failed to encode dependency trace")
+	}
+}
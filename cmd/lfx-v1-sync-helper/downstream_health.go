@@ -0,0 +1,286 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Downstream health. When the indexer or fga-sync is hard down, every KV
+// message fails its publish or request and is retried until its deliveries
+// are exhausted and it is dead-lettered. With DOWNSTREAM_HEALTH_CHECKS set
+// (extended readiness), the listed downstream services are probed every
+// DOWNSTREAM_HEALTH_INTERVAL, by a NATS micro service ping or an HTTP health
+// endpoint. After downstreamHealthFailures consecutive failed probes of a
+// service, /readyz fails, and the KV messages wait (kept in progress) before
+// being processed until the service answers again, so no new work is pulled
+// meanwhile.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/micro"
+)
+
+const (
+	// defaultDownstreamHealthInterval is the default interval between the
+	// probes of the downstream services.
+	defaultDownstreamHealthInterval = 15 * time.Second
+
+	// downstreamHealthTimeout bounds each probe.
+	downstreamHealthTimeout = 5 * time.Second
+
+	// downstreamHealthFailures is how many consecutive probes of a service
+	// must fail before it is considered down.
+	downstreamHealthFailures = 3
+
+	// downstreamHealthNATSScheme prefixes the checks probing a NATS micro
+	// service by name.
+	downstreamHealthNATSScheme = "nats:"
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_downstream_up",
+	"Whether a downstream service answered its health probes (1) or is considered down after consecutive failed probes (0), by service.",
+	func() []gaugeSample { return downstreamHealth.upSamples() },
+	"service",
+)
+
+var downstreamHealthWaits = newCounterVec(
+	"v1_sync_helper_downstream_health_waits_total",
+	"Number of KV messages which waited for a downstream service considered down, by object type.",
+	"object_type",
+)
+
+// downstreamHealthCheck is a probe of a downstream service: a NATS micro
+// service ping, or an HTTP GET expecting a 2xx response.
+type downstreamHealthCheck struct {
+	name        string
+	natsService string
+	url         string
+}
+
+// parseDownstreamHealthChecks parses the name=target entries of
+// DOWNSTREAM_HEALTH_CHECKS, where the target is "nats:<micro service name>"
+// or an http(s) URL.
+func parseDownstreamHealthChecks(entries []string) ([]downstreamHealthCheck, error) {
+	var checks []downstreamHealthCheck
+	for _, entry := range entries {
+		name, target, ok := strings.Cut(entry, "=")
+		name, target = strings.TrimSpace(name), strings.TrimSpace(target)
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("invalid DOWNSTREAM_HEALTH_CHECKS entry %q: expected name=nats:<service> or name=<http url>", entry)
+		}
+		if slices.ContainsFunc(checks, func(check downstreamHealthCheck) bool { return check.name == name }) {
+			return nil, fmt.Errorf("duplicate DOWNSTREAM_HEALTH_CHECKS name %q", name)
+		}
+		check := downstreamHealthCheck{name: name}
+		switch {
+		case strings.HasPrefix(target, downstreamHealthNATSScheme):
+			check.natsService = strings.TrimPrefix(target, downstreamHealthNATSScheme)
+			if check.natsService == "" || strings.ContainsAny(check.natsService, ".*> ") {
+				return nil, fmt.Errorf("invalid DOWNSTREAM_HEALTH_CHECKS service name in %q", entry)
+			}
+		case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+			check.url = target
+		default:
+			return nil, fmt.Errorf("invalid DOWNSTREAM_HEALTH_CHECKS target %q: expected nats:<service> or an http(s) URL", target)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// downstreamHealth is the health of the downstream services.
+var downstreamHealth = &downstreamHealthState{
+	failures:  map[string]int{},
+	lastError: map[string]string{},
+}
+
+// downstreamHealthState tracks the consecutive failed probes of each
+// downstream service.
+type downstreamHealthState struct {
+	mu        sync.Mutex
+	checks    []downstreamHealthCheck
+	failures  map[string]int
+	lastError map[string]string
+	// recovered is closed when no service is down any longer; it is nil
+	// while no service is down.
+	recovered chan struct{}
+}
+
+// watchDownstreamHealth probes the downstream services until the context is
+// cancelled.
+func watchDownstreamHealth(ctx context.Context, checks []downstreamHealthCheck) {
+	downstreamHealth.mu.Lock()
+	downstreamHealth.checks = checks
+	downstreamHealth.mu.Unlock()
+
+	httpClient := &http.Client{Timeout: downstreamHealthTimeout}
+	ticker := time.NewTicker(cfg.DownstreamHealthInterval)
+	defer ticker.Stop()
+	for {
+		for _, check := range checks {
+			downstreamHealth.record(ctx, check.name, probeDownstream(ctx, httpClient, check))
+		}
+		select {
+		case <-ctx.Done():
+			// Release the messages waiting for a service.
+			downstreamHealth.reset()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeDownstream runs the probe of a downstream service.
+func probeDownstream(ctx context.Context, httpClient *http.Client, check downstreamHealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, downstreamHealthTimeout)
+	defer cancel()
+
+	if check.natsService != "" {
+		subject, err := micro.ControlSubject(micro.PingVerb, check.natsService, "")
+		if err != nil {
+			return err
+		}
+		// Control-plane requests are not subject to the publish subject
+		// allowlist, so they are sent on the connection directly.
+		if _, err := natsConn.RequestWithContext(ctx, subject, nil); err != nil {
+			return fmt.Errorf("ping of NATS service %s failed: %w", check.natsService, err)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health endpoint %s returned status %d", check.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// record records the result of a probe, logging the transitions of the
+// service between up and down.
+func (s *downstreamHealthState) record(ctx context.Context, name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wasDown := s.failures[name] >= downstreamHealthFailures
+	if err == nil {
+		s.failures[name] = 0
+		delete(s.lastError, name)
+		if wasDown {
+			logger.With("service", name).InfoContext(ctx, "downstream service is up again")
+			s.releaseIfUp()
+		}
+		return
+	}
+
+	s.failures[name]++
+	s.lastError[name] = err.Error()
+	if !wasDown && s.failures[name] >= downstreamHealthFailures {
+		logger.With(errKey, err, "service", name, "failures", s.failures[name]).ErrorContext(ctx, "downstream service is down, pausing KV message processing")
+		if s.recovered == nil {
+			s.recovered = make(chan struct{})
+		}
+		return
+	}
+	logger.With(errKey, err, "service", name, "failures", s.failures[name]).WarnContext(ctx, "downstream health probe failed")
+}
+
+// releaseIfUp releases the waiting messages once no service is down. The
+// lock must be held.
+func (s *downstreamHealthState) releaseIfUp() {
+	if s.recovered == nil || len(s.downLocked()) > 0 {
+		return
+	}
+	close(s.recovered)
+	s.recovered = nil
+}
+
+// reset forgets the failed probes, releasing the waiting messages.
+func (s *downstreamHealthState) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.failures)
+	clear(s.lastError)
+	s.releaseIfUp()
+}
+
+// downLocked returns the names of the services considered down. The lock
+// must be held.
+func (s *downstreamHealthState) downLocked() []string {
+	var down []string
+	for name, failures := range s.failures {
+		if failures >= downstreamHealthFailures {
+			down = append(down, name)
+		}
+	}
+	slices.Sort(down)
+	return down
+}
+
+// readiness returns an error naming the downstream services considered down,
+// if any, for /readyz.
+func (s *downstreamHealthState) readiness() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	down := s.downLocked()
+	if len(down) == 0 {
+		return nil
+	}
+	var reasons []string
+	for _, name := range down {
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", name, s.lastError[name]))
+	}
+	return fmt.Errorf("downstream services down: %s", strings.Join(reasons, ", "))
+}
+
+// wait blocks while a downstream service is considered down, calling progress
+// every progressInterval (e.g. to extend the message AckWait).
+func (s *downstreamHealthState) wait(objectType string, progressInterval time.Duration, progress func()) {
+	s.mu.Lock()
+	recovered := s.recovered
+	s.mu.Unlock()
+	if recovered == nil {
+		return
+	}
+
+	downstreamHealthWaits.inc(objectType)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-recovered:
+			return
+		case <-ticker.C:
+			progress()
+		}
+	}
+}
+
+// upSamples returns the v1_sync_helper_downstream_up samples.
+func (s *downstreamHealthState) upSamples() []gaugeSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := make([]gaugeSample, 0, len(s.checks))
+	for _, check := range s.checks {
+		up := 1.0
+		if s.failures[check.name] >= downstreamHealthFailures {
+			up = 0
+		}
+		samples = append(samples, gaugeSample{labelValues: []string{check.name}, value: up})
+	}
+	return samples
+}
@@ -0,0 +1,339 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Dry runs. Handlers run with a dry-run recorder in their context have their
+// side effects recorded: NATS messages are recorded instead of published, KV
+// writes to the v1-objects and v1-mappings buckets are kept in an in-memory
+// overlay (which later reads of the same run are served from), and v2 API
+// writes are recorded and rejected. A passthrough recorder only records the
+// side effects, which still happen. Handlers without a recorder in their
+// context are unaffected.
+//
+// The buckets and the v2 HTTP client are wrapped by installDryRunHooks, which
+// the inspect subcommand and the canary mode call before running handlers.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// errDryRunWrite is returned for v2 API writes during a dry run.
+var errDryRunWrite = errors.New("v2 API write rejected in dry-run mode")
+
+var (
+	// dryRunHooksOnce guards installing the dry-run hooks.
+	dryRunHooksOnce sync.Once
+	// dryRunHooksInstalled is set once the dry-run hooks are installed.
+	dryRunHooksInstalled atomic.Bool
+)
+
+// dryRunRecorder collects the messages and writes of a dry run.
+type dryRunRecorder struct {
+	// passthrough records the side effects without preventing them.
+	passthrough bool
+
+	// hiddenPrefixes are v1-mappings key prefixes read as not found, e.g.
+	// the fingerprints which would skip unchanged records.
+	hiddenPrefixes []string
+
+	mu          sync.Mutex
+	messages    []dryRunMessage
+	kvWrites    []dryRunKVWrite
+	apiRequests []dryRunAPIRequest
+	overlay     map[string]*dryRunEntry
+	revision    uint64
+}
+
+// dryRunMessage is a NATS message recorded instead of published.
+type dryRunMessage struct {
+	Subject string          `json:"subject"`
+	Headers nats.Header     `json:"headers,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// dryRunKVWrite is a KV write kept in the overlay instead of the bucket.
+type dryRunKVWrite struct {
+	Bucket    string          `json:"bucket"`
+	Key       string          `json:"key"`
+	Operation string          `json:"operation"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+// dryRunAPIRequest is a v2 API write recorded and rejected.
+type dryRunAPIRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// dryRunContextKey is the context key of the dry-run recorder.
+type dryRunContextKey struct{}
+
+// withDryRun returns a context whose handlers record their side effects to
+// the recorder.
+func withDryRun(ctx context.Context, recorder *dryRunRecorder) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, recorder)
+}
+
+// contextDryRun returns the dry-run recorder of the context, if any.
+func contextDryRun(ctx context.Context) *dryRunRecorder {
+	recorder, _ := ctx.Value(dryRunContextKey{}).(*dryRunRecorder)
+	return recorder
+}
+
+// rawJSON returns data as raw JSON if it is valid JSON, and as a JSON string
+// otherwise (e.g. for msgpack values).
+func rawJSON(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	encoded, _ := json.Marshal(string(data))
+	return encoded
+}
+
+// recordPublish records a published message.
+func (r *dryRunRecorder) recordPublish(msg *nats.Msg) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, dryRunMessage{Subject: msg.Subject, Headers: msg.Header, Payload: rawJSON(msg.Data)})
+}
+
+// recordKVWrite records a KV write.
+func (r *dryRunRecorder) recordKVWrite(bucket, key string, operation jetstream.KeyValueOp, value []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kvWrites = append(r.kvWrites, dryRunKVWrite{Bucket: bucket, Key: key, Operation: operation.String(), Value: rawJSON(value)})
+}
+
+// recordAPIRequest records a v2 API write.
+func (r *dryRunRecorder) recordAPIRequest(req *http.Request, body []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apiRequests = append(r.apiRequests, dryRunAPIRequest{Method: req.Method, URL: req.URL.String(), Body: rawJSON(body)})
+}
+
+// results returns copies of the recorded messages, KV writes, and API
+// requests.
+func (r *dryRunRecorder) results() ([]dryRunMessage, []dryRunKVWrite, []dryRunAPIRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]dryRunMessage{}, r.messages...),
+		append([]dryRunKVWrite{}, r.kvWrites...),
+		append([]dryRunAPIRequest{}, r.apiRequests...)
+}
+
+// overlayGet returns the overlay entry of a bucket key, if written.
+func (r *dryRunRecorder) overlayGet(bucket, key string) (*dryRunEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.overlay[bucket+"/"+key]
+	return entry, ok
+}
+
+// overlayWrite keeps a write in the overlay, and records it.
+func (r *dryRunRecorder) overlayWrite(bucket, key string, value []byte, op jetstream.KeyValueOp) uint64 {
+	r.mu.Lock()
+	if r.overlay == nil {
+		r.overlay = map[string]*dryRunEntry{}
+	}
+	// Overlay revisions count down from the maximum, so they never match a
+	// bucket revision.
+	r.revision++
+	revision := ^uint64(0) - r.revision
+	r.overlay[bucket+"/"+key] = &dryRunEntry{
+		bucket:   bucket,
+		key:      key,
+		value:    value,
+		revision: revision,
+		created:  bootstrap.Now(),
+		op:       op,
+	}
+	r.mu.Unlock()
+	r.recordKVWrite(bucket, key, op, value)
+	return revision
+}
+
+// dryRunTransport is the v2 HTTP client transport during dry runs: requests
+// without a dry-run recorder in their context are performed, reads are
+// performed, and writes are recorded, then rejected unless the recorder is a
+// passthrough.
+type dryRunTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := contextDryRun(req.Context())
+	if recorder == nil || req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+	}
+	recorder.recordAPIRequest(req, body)
+	if !recorder.passthrough {
+		return nil, errDryRunWrite
+	}
+	req.Body = io.NopCloser(strings.NewReader(string(body)))
+	return t.next.RoundTrip(req)
+}
+
+// dryRunKV is a KV bucket whose writes, in contexts with a dry-run recorder,
+// are kept in the recorder's overlay (or recorded, for passthrough
+// recorders).
+type dryRunKV struct {
+	jetstream.KeyValue
+}
+
+// dryRunEntry is an entry of a dry-run overlay.
+type dryRunEntry struct {
+	bucket   string
+	key      string
+	value    []byte
+	revision uint64
+	created  time.Time
+	op       jetstream.KeyValueOp
+}
+
+func (e *dryRunEntry) Bucket() string                  { return e.bucket }
+func (e *dryRunEntry) Key() string                     { return e.key }
+func (e *dryRunEntry) Value() []byte                   { return e.value }
+func (e *dryRunEntry) Revision() uint64                { return e.revision }
+func (e *dryRunEntry) Created() time.Time              { return e.created }
+func (e *dryRunEntry) Delta() uint64                   { return 0 }
+func (e *dryRunEntry) Operation() jetstream.KeyValueOp { return e.op }
+
+// overlayRecorder returns the recorder whose overlay the writes and reads of
+// the context use, or nil if they use the bucket.
+func overlayRecorder(ctx context.Context) *dryRunRecorder {
+	recorder := contextDryRun(ctx)
+	if recorder == nil || recorder.passthrough {
+		return nil
+	}
+	return recorder
+}
+
+// Get returns the overlay entry of the key, or the bucket entry.
+func (kv *dryRunKV) Get(ctx context.Context, key string) (jetstream.KeyValueEntry, error) {
+	if recorder := overlayRecorder(ctx); recorder != nil {
+		if entry, ok := recorder.overlayGet(kv.Bucket(), key); ok {
+			if entry.op != jetstream.KeyValuePut {
+				return nil, jetstream.ErrKeyNotFound
+			}
+			return entry, nil
+		}
+		for _, prefix := range recorder.hiddenPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return nil, jetstream.ErrKeyNotFound
+			}
+		}
+	}
+	return kv.KeyValue.Get(ctx, key)
+}
+
+// Put keeps the value in the overlay, or records it.
+func (kv *dryRunKV) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	if recorder := overlayRecorder(ctx); recorder != nil {
+		return recorder.overlayWrite(kv.Bucket(), key, value, jetstream.KeyValuePut), nil
+	}
+	revision, err := kv.KeyValue.Put(ctx, key, value)
+	kv.recordPassthrough(ctx, key, value, jetstream.KeyValuePut, err)
+	return revision, err
+}
+
+// PutString keeps the value in the overlay, or records it.
+func (kv *dryRunKV) PutString(ctx context.Context, key string, value string) (uint64, error) {
+	return kv.Put(ctx, key, []byte(value))
+}
+
+// Create keeps the value in the overlay if the key does not exist, or
+// records it.
+func (kv *dryRunKV) Create(ctx context.Context, key string, value []byte, opts ...jetstream.KVCreateOpt) (uint64, error) {
+	if recorder := overlayRecorder(ctx); recorder != nil {
+		if _, err := kv.Get(ctx, key); err == nil {
+			return 0, jetstream.ErrKeyExists
+		}
+		return recorder.overlayWrite(kv.Bucket(), key, value, jetstream.KeyValuePut), nil
+	}
+	revision, err := kv.KeyValue.Create(ctx, key, value, opts...)
+	kv.recordPassthrough(ctx, key, value, jetstream.KeyValuePut, err)
+	return revision, err
+}
+
+// Update keeps the value in the overlay, or records it. Overlay revisions are
+// not checked, as the overlay does not track concurrent writers.
+func (kv *dryRunKV) Update(ctx context.Context, key string, value []byte, last uint64) (uint64, error) {
+	if recorder := overlayRecorder(ctx); recorder != nil {
+		return recorder.overlayWrite(kv.Bucket(), key, value, jetstream.KeyValuePut), nil
+	}
+	revision, err := kv.KeyValue.Update(ctx, key, value, last)
+	kv.recordPassthrough(ctx, key, value, jetstream.KeyValuePut, err)
+	return revision, err
+}
+
+// Delete keeps a delete marker in the overlay, or records it.
+func (kv *dryRunKV) Delete(ctx context.Context, key string, opts ...jetstream.KVDeleteOpt) error {
+	if recorder := overlayRecorder(ctx); recorder != nil {
+		recorder.overlayWrite(kv.Bucket(), key, nil, jetstream.KeyValueDelete)
+		return nil
+	}
+	err := kv.KeyValue.Delete(ctx, key, opts...)
+	kv.recordPassthrough(ctx, key, nil, jetstream.KeyValueDelete, err)
+	return err
+}
+
+// Purge keeps a purge marker in the overlay, or records it.
+func (kv *dryRunKV) Purge(ctx context.Context, key string, opts ...jetstream.KVDeleteOpt) error {
+	if recorder := overlayRecorder(ctx); recorder != nil {
+		recorder.overlayWrite(kv.Bucket(), key, nil, jetstream.KeyValuePurge)
+		return nil
+	}
+	err := kv.KeyValue.Purge(ctx, key, opts...)
+	kv.recordPassthrough(ctx, key, nil, jetstream.KeyValuePurge, err)
+	return err
+}
+
+// recordPassthrough records a successful bucket write to the passthrough
+// recorder of the context, if any.
+func (kv *dryRunKV) recordPassthrough(ctx context.Context, key string, value []byte, op jetstream.KeyValueOp, err error) {
+	if recorder := contextDryRun(ctx); recorder != nil && err == nil {
+		recorder.recordKVWrite(kv.Bucket(), key, op, value)
+	}
+}
+
+// installDryRunHooks wraps the v1-objects and v1-mappings buckets and the v2
+// HTTP client, so handlers run with a dry-run recorder in their context have
+// their side effects recorded. The buckets must be open.
+func installDryRunHooks() {
+	dryRunHooksOnce.Do(func() {
+		v1KV = &dryRunKV{KeyValue: v1KV}
+		mappingsKV = &dryRunKV{KeyValue: mappingsKV}
+
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &dryRunTransport{next: next}
+		dryRunHooksInstalled.Store(true)
+	})
+}
@@ -0,0 +1,271 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Conversion fixtures. The fixtures subcommand samples records from the
+// v1-objects bucket, anonymizes their PII, and writes each one as an input
+// fixture with the golden output of its convertMapToInput* function under
+// testdata/fixtures/{key prefix}/. The fixtures test (build tag "fixtures")
+// re-runs the conversions and compares them byte-for-byte with the goldens.
+//
+// Only the conversions which do not resolve mappings or call other services
+// are covered, so the fixtures can be validated offline.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// fixturesDir is the default directory of the conversion fixtures, relative
+// to the repository root.
+const fixturesDir = "cmd/lfx-v1-sync-helper/testdata/fixtures"
+
+// fixtureConverters are the conversions covered by fixtures, by key prefix.
+var fixtureConverters = map[string]func(ctx context.Context, v1Data map[string]any) (any, error){
+	"itx-zoom-meetings-mappings-v2": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputMeetingMapping(ctx, d)
+	},
+	"itx-zoom-meetings-registrants-v2": func(ctx context.Context, d map[string]any) (any, error) { return convertMapToInputRegistrant(ctx, d) },
+	"itx-zoom-meetings-registrants-v3": func(ctx context.Context, d map[string]any) (any, error) { return convertMapToInputRegistrant(ctx, d) },
+	"itx-zoom-meetings-invite-responses-v2": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputInviteResponse(ctx, d)
+	},
+	"itx-zoom-past-meetings-mappings": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputPastMeetingMapping(ctx, d)
+	},
+	"itx-zoom-past-meetings-invitees": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputPastMeetingInvitee(ctx, d)
+	},
+	"itx-zoom-past-meetings-attendees": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputPastMeetingAttendee(ctx, d)
+	},
+	"itx-zoom-past-meetings-recordings": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputPastMeetingRecording(ctx, d)
+	},
+	"itx-zoom-past-meetings-summaries": func(ctx context.Context, d map[string]any) (any, error) {
+		return convertMapToInputPastMeetingSummary(ctx, d)
+	},
+}
+
+// piiFields are the v1 record fields holding personal data, which are
+// replaced when anonymizing fixtures, at any nesting level.
+var piiFields = map[string]bool{
+	"email":                   true,
+	"emails":                  true,
+	"first_name":              true,
+	"last_name":               true,
+	"name":                    true,
+	"full_name":               true,
+	"display_name":            true,
+	"username":                true,
+	"user_name":               true,
+	"lf_sso":                  true,
+	"phone":                   true,
+	"phone_number":            true,
+	"job_title":               true,
+	"org":                     true,
+	"org_name":                true,
+	"linkedin_profile":        true,
+	"profile_picture":         true,
+	"avatar_url":              true,
+	"host_email":              true,
+	"zoom_user_email":         true,
+	"zoom_user_name":          true,
+	"mapped_invitee_name":     true,
+	"created_by_email":        true,
+	"modified_by_email":       true,
+	"registrant_email":        true,
+	"participant_email":       true,
+	"participant_name":        true,
+	"zoom_meeting_host_id":    true,
+	"zoom_meeting_host_email": true,
+}
+
+// emailPattern matches email addresses in any string value.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// anonymizeV1Data returns a copy of a v1 record with its personal data
+// replaced by stable pseudonyms, so related records stay consistent.
+func anonymizeV1Data(v1Data map[string]any) map[string]any {
+	return anonymizeValue("", v1Data).(map[string]any)
+}
+
+// anonymizeValue anonymizes a value of the given field.
+func anonymizeValue(field string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		anonymized := make(map[string]any, len(v))
+		for k, fieldValue := range v {
+			anonymized[k] = anonymizeValue(k, fieldValue)
+		}
+		return anonymized
+	case []any:
+		anonymized := make([]any, len(v))
+		for i, item := range v {
+			anonymized[i] = anonymizeValue(field, item)
+		}
+		return anonymized
+	case string:
+		if v == "" {
+			return v
+		}
+		if piiFields[field] {
+			if strings.Contains(v, "@") {
+				return pseudonym(v) + "@example.com"
+			}
+			return pseudonym(v)
+		}
+		return emailPattern.ReplaceAllStringFunc(v, func(email string) string {
+			return pseudonym(email) + "@example.com"
+		})
+	default:
+		return value
+	}
+}
+
+// pseudonym returns a stable pseudonym of a value.
+func pseudonym(value string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(value)))
+	return "anon-" + hex.EncodeToString(sum[:6])
+}
+
+// encodeFixture encodes a fixture file.
+func encodeFixture(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// runFixtures samples v1-objects records of the covered key prefixes and
+// writes their anonymized conversion fixtures.
+func runFixtures(name string, args []string) {
+	var prefixes, out *string
+	var sample *int
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		prefixes = flags.String("prefixes", "", "comma-separated key prefixes to sample (default: all covered prefixes)")
+		sample = flags.Int("sample", 5, "number of records to sample per key prefix")
+		out = flags.String("out", fixturesDir, "fixtures directory")
+	})
+	ctx := p.ctx
+
+	selected := []string{}
+	for prefix := range fixtureConverters {
+		selected = append(selected, prefix)
+	}
+	if *prefixes != "" {
+		selected = strings.Split(*prefixes, ",")
+	}
+	slices.Sort(selected)
+
+	p.openBuckets()
+
+	failed := false
+	for _, prefix := range selected {
+		convert, ok := fixtureConverters[prefix]
+		if !ok {
+			logger.With("prefix", prefix).Error("no conversion fixtures for key prefix")
+			failed = true
+			continue
+		}
+
+		lister, err := v1KV.ListKeysFiltered(ctx, prefix+".>")
+		if err != nil {
+			logger.With(errKey, err, "prefix", prefix).Error("error listing v1-objects keys")
+			failed = true
+			continue
+		}
+		keys := []string{}
+		for key := range lister.Keys() {
+			keys = append(keys, key)
+		}
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+		written := 0
+		for _, key := range keys {
+			if written >= *sample {
+				break
+			}
+			if err := writeFixture(p, *out, prefix, key, convert); err != nil {
+				logger.With(errKey, err, "key", key).Warn("skipping record for fixtures")
+				continue
+			}
+			written++
+		}
+		logger.With("prefix", prefix, "fixtures", written).Info("wrote conversion fixtures")
+	}
+
+	p.shutdown()
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// writeFixture writes the anonymized input and golden output fixtures of a
+// v1-objects record.
+func writeFixture(p *syncProcess, out, prefix, key string, convert func(context.Context, map[string]any) (any, error)) error {
+	entry, err := v1KV.Get(p.ctx, key)
+	if err != nil {
+		return err
+	}
+	value, err := decompressV1Value(key, entry.Value())
+	if err != nil {
+		return err
+	}
+	var v1Data map[string]any
+	if err := json.Unmarshal(value, &v1Data); err != nil {
+		if msgErr := msgpack.Unmarshal(value, &v1Data); msgErr != nil {
+			return fmt.Errorf("failed to unmarshal record as JSON or msgpack: %w", err)
+		}
+	}
+	if deletedAt, ok := v1Data["_sdc_deleted_at"]; ok && deletedAt != nil && deletedAt != "" {
+		return fmt.Errorf("record is soft deleted")
+	}
+	if table, ok := kvTableHandlers[prefix]; ok {
+		v1Data = table.normalizeSchema(p.ctx, key, v1Data)
+	}
+
+	// Round-trip the input through JSON, so the golden output is converted
+	// from exactly what the fixtures test reads back.
+	input, err := encodeFixture(anonymizeV1Data(v1Data))
+	if err != nil {
+		return err
+	}
+	v1Data = nil
+	if err := json.Unmarshal(input, &v1Data); err != nil {
+		return err
+	}
+	converted, err := convert(p.ctx, v1Data)
+	if err != nil {
+		return err
+	}
+	golden, err := encodeFixture(converted)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(out, prefix)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := filepath.Join(dir, pseudonym(key))
+	if err := os.WriteFile(base+".input.json", input, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(base+".golden.json", golden, 0o644)
+}
@@ -0,0 +1,105 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+//go:build fixtures
+
+// Conversion fixture tests. These re-run the convertMapToInput* functions on
+// the anonymized input fixtures captured with the fixtures subcommand, and
+// compare the results byte-for-byte with the golden outputs.
+//
+// Run with:
+//
+//	go test -tags=fixtures -v -run TestConversionFixtures ./cmd/lfx-v1-sync-helper/
+//
+// After an intended conversion change, regenerate the golden outputs with:
+//
+//	go test -tags=fixtures -run TestConversionFixtures ./cmd/lfx-v1-sync-helper/ -update
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden outputs of the conversion fixtures")
+
+func TestConversionFixtures(t *testing.T) {
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg = &Config{}
+
+	inputs, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*", "*.input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Skip("no conversion fixtures; capture some with the fixtures subcommand")
+	}
+
+	for _, inputPath := range inputs {
+		prefix := filepath.Base(filepath.Dir(inputPath))
+		goldenPath := strings.TrimSuffix(inputPath, ".input.json") + ".golden.json"
+
+		t.Run(prefix+"/"+filepath.Base(goldenPath), func(t *testing.T) {
+			convert, ok := fixtureConverters[prefix]
+			if !ok {
+				t.Fatalf("no converter for key prefix %s", prefix)
+			}
+
+			input, err := os.ReadFile(inputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v1Data map[string]any
+			if err := json.Unmarshal(input, &v1Data); err != nil {
+				t.Fatal(err)
+			}
+
+			converted, err := convert(context.Background(), v1Data)
+			if err != nil {
+				t.Fatalf("conversion failed: %v", err)
+			}
+			got, err := encodeFixture(converted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Decoding the input struct from the source value must convert
+			// the same as the map round trip.
+			fromSource, err := convert(withRecordSource(context.Background(), v1Data, input), v1Data)
+			if err != nil {
+				t.Fatalf("conversion from source value failed: %v", err)
+			}
+			gotFromSource, err := encodeFixture(fromSource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotFromSource, got) {
+				t.Errorf("conversion from source value differs:\ngot:\n%s\nwant:\n%s", gotFromSource, got)
+			}
+
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("conversion differs from %s:\ngot:\n%s\nwant:\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
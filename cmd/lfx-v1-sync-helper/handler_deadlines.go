@@ -0,0 +1,84 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Per-message processing deadlines. The handlers used to run with a context
+// without a deadline, so a hung v1 API, v2 API, or KV call blocked a worker
+// forever. The processing of each consumed message (KV, WAL, and DynamoDB
+// stream messages) now runs with a deadline: HANDLER_TIMEOUTS by object type,
+// HANDLER_TIMEOUT otherwise, and by default the AckWait of the consumer,
+// after which JetStream would redeliver the message anyway. The deadline
+// propagates to the KV and HTTP calls of the handlers, and a message whose
+// processing exceeded it is NAKed for a retry, whatever its handler returned.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var handlerDeadlinesExceeded = newCounterVec(
+	"v1_sync_helper_handler_deadline_exceeded_total",
+	"Number of messages whose processing exceeded its deadline and was retried, by consumer and object type.",
+	"consumer", "object_type",
+)
+
+// parseHandlerTimeouts parses HANDLER_TIMEOUTS, a JSON object of the
+// processing deadlines by object type, e.g.
+// {"itx-zoom-past-meetings-recordings": "2m"}.
+func parseHandlerTimeouts(value string) (map[string]time.Duration, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("HANDLER_TIMEOUTS must be a JSON object of durations by object type: %w", err)
+	}
+	timeouts := make(map[string]time.Duration, len(parsed))
+	for objectType, value := range parsed {
+		if objectType == "" || strings.ContainsAny(objectType, ".*> \t") {
+			return nil, fmt.Errorf("HANDLER_TIMEOUTS object type %q is invalid", objectType)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("HANDLER_TIMEOUTS timeout of %s must be a positive duration", objectType)
+		}
+		timeouts[objectType] = timeout
+	}
+	return timeouts, nil
+}
+
+// handlerTimeout returns the processing deadline of the messages of an object
+// type delivered by a consumer.
+func handlerTimeout(consumer, objectType string) time.Duration {
+	if timeout, ok := cfg.HandlerTimeouts[objectType]; ok {
+		return timeout
+	}
+	if cfg.HandlerTimeout > 0 {
+		return cfg.HandlerTimeout
+	}
+	_, ackWait := consumerDelivery(consumer)
+	return ackWait
+}
+
+// withHandlerDeadline returns a context for processing a message of an object
+// type delivered by a consumer, with its processing deadline.
+func withHandlerDeadline(ctx context.Context, consumer, objectType string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, handlerTimeout(consumer, objectType))
+}
+
+// handlerDeadlineExceeded reports whether the processing of a message
+// exceeded its deadline, so it must be retried.
+func handlerDeadlineExceeded(ctx context.Context, consumer, objectType string) bool {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	handlerDeadlinesExceeded.inc(consumer, objectType)
+	logger.With("consumer", consumer, "object_type", objectType, "timeout", handlerTimeout(consumer, objectType).String()).WarnContext(ctx, "message processing exceeded its deadline, retrying")
+	return true
+}
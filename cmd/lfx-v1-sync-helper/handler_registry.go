@@ -0,0 +1,346 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// The handler registry maps v1 key prefixes to their update and delete
+// handlers. Because the v1 tables occasionally change shape (for example,
+// itx-zoom-meetings-registrants-v2 to -v3), each prefix may also declare the
+// schema versions it accepts: the version of a record is detected from the
+// fields present, and the record is converted to the shape the handlers expect
+// before dispatch, so old and new records are both processed during a
+// migration.
+
+import (
+	"context"
+)
+
+// kvUpdateHandler processes a v1 record create or update.
+// Returns true if the operation should be retried, false otherwise.
+type kvUpdateHandler func(ctx context.Context, key string, v1Data map[string]any) bool
+
+// kvDeleteHandler processes a v1 record deletion. The id is the part of the
+// key after the prefix. v1Data may be nil (e.g. hard deletes).
+// Returns true if the operation should be retried, false otherwise.
+type kvDeleteHandler func(ctx context.Context, key, id, v1Principal string, v1Data map[string]any) bool
+
+// schemaVersion describes one shape of the records under a key prefix.
+type schemaVersion struct {
+	// name identifies the version in logs.
+	name string
+	// detect reports whether a record has this shape. A nil detect matches
+	// any record, so it should only be used for the last version.
+	detect func(v1Data map[string]any) bool
+	// convert returns the record in the shape expected by the handlers. A nil
+	// convert leaves the record unchanged.
+	convert func(v1Data map[string]any) map[string]any
+}
+
+// kvTableHandler holds the handlers and schema versions for a key prefix.
+type kvTableHandler struct {
+	update kvUpdateHandler
+	// delete is nil for prefixes whose deletions are not synced.
+	delete kvDeleteHandler
+	// versions are checked in order; the first detected version is used. When
+	// empty, records are passed to the handlers unchanged.
+	versions []schemaVersion
+	// middleware hooks into the handlers of the prefix, in order.
+	middleware []handlerMiddleware
+	// requires are the parent mappings which must exist before update is
+	// called; records missing one are parked until it appears.
+	requires []mappingDependency
+	// canary is a candidate replacement of update, compared to it on a
+	// sample of records in canary mode (CANARY_PERCENT) before cutover.
+	canary kvUpdateHandler
+	// subjects are the downstream subjects the handlers publish to (before
+	// access subject sharding), and mappings the v1-mappings key formats they
+	// write, as documented by /admin/object-types.
+	subjects []string
+	mappings []string
+	// rawFields are the large top-level fields of the records, kept as raw
+	// JSON when decoding them (see large_values.go); handlers only read them
+	// through the conversion to their input struct.
+	rawFields []string
+}
+
+// registrantSchemaVersions are the shapes of the zoom meeting registrant
+// table. The v3 table renames registrant_id to id; v3 records are converted
+// back to the v2 field names.
+var registrantSchemaVersions = []schemaVersion{
+	{
+		name: "v2",
+		detect: func(v1Data map[string]any) bool {
+			_, ok := v1Data["registrant_id"]
+			return ok
+		},
+	},
+	{
+		name: "v3",
+		detect: func(v1Data map[string]any) bool {
+			_, ok := v1Data["id"]
+			return ok
+		},
+		convert: func(v1Data map[string]any) map[string]any {
+			return renameFields(v1Data, map[string]string{
+				"id": "registrant_id",
+			})
+		},
+	},
+}
+
+// registrantTableHandler handles both the v2 and v3 zoom meeting registrant tables.
+var registrantTableHandler = kvTableHandler{
+	update: handleZoomMeetingRegistrantUpdate,
+	delete: func(ctx context.Context, key, id, _ string, v1Data map[string]any) bool {
+		return handleZoomMeetingRegistrantDelete(ctx, key, id, v1Data)
+	},
+	versions: registrantSchemaVersions,
+	requires: meetingChildDependencies,
+	subjects: []string{
+		IndexV1MeetingRegistrantSubject,
+		V1MeetingRegistrantPutSubject,
+		V1MeetingRegistrantRemoveSubject,
+		V1MeetingRegistrantHostPromoteSubject,
+		V1MeetingRegistrantHostDemoteSubject,
+	},
+	mappings: []string{"v1_meeting_registrants.%s", "v1_meeting_registrant_index.%s.%s", registrantHostStateKeyFmt, "v1_user_reference_index.%s.%s"},
+}
+
+// kvTableHandlers maps v1 key prefixes to their handlers.
+var kvTableHandlers = map[string]kvTableHandler{
+	"salesforce-project__c": {
+		update:   withoutRetry(handleProjectUpdate),
+		mappings: []string{"project.sfid.%s", "project.uid.%s", projectPublicKeyFmt},
+		delete: func(ctx context.Context, key, id, v1Principal string, _ map[string]any) bool {
+			return handleProjectDelete(ctx, key, id, v1Principal)
+		},
+	},
+	"platform-collaboration__c": {
+		update:   withoutRetry(handleCommitteeUpdate),
+		mappings: []string{"committee.sfid.%s", "committee.uid.%s"},
+		delete: func(ctx context.Context, key, id, v1Principal string, _ map[string]any) bool {
+			return handleCommitteeDelete(ctx, key, id, v1Principal)
+		},
+	},
+	"platform-community__c": {
+		update:   withoutRetry(handleCommitteeMemberUpdate),
+		requires: []mappingDependency{committeeMappingParent.requiredBy("collaboration_name__c")},
+		mappings: []string{"committee_member.sfid.%s", "committee_member.uid.%s"},
+		delete: func(ctx context.Context, key, id, v1Principal string, _ map[string]any) bool {
+			return handleCommitteeMemberDelete(ctx, key, id, v1Principal)
+		},
+	},
+	"itx-poll": {
+		update:   withoutRetry(handleVoteUpdate),
+		delete:   withoutData(handleVoteDelete),
+		requires: []mappingDependency{projectMappingParent.requiredBy("project_id")},
+		subjects: []string{IndexVoteSubject, UpdateAccessSubject},
+		mappings: []string{"vote.%s"},
+	},
+	"itx-poll-vote": {
+		update:   handleVoteResponseUpdate,
+		delete:   withoutData(handleVoteResponseDelete),
+		requires: []mappingDependency{voteMappingParent.requiredBy("poll_id")},
+		subjects: []string{IndexVoteResponseSubject, UpdateAccessSubject},
+		mappings: []string{"vote_response.%s"},
+	},
+	"itx-surveys": {
+		update:   withoutRetry(handleSurveyUpdate),
+		delete:   withoutData(handleSurveyDelete),
+		subjects: []string{IndexSurveySubject, UpdateAccessSubject},
+		mappings: []string{"survey.%s"},
+	},
+	"itx-survey-responses": {
+		update:   handleSurveyResponseUpdate,
+		delete:   withoutData(handleSurveyResponseDelete),
+		requires: []mappingDependency{surveyMappingParent.requiredBy("survey_id")},
+		subjects: []string{IndexSurveyResponseSubject, UpdateAccessSubject},
+		mappings: []string{"survey_response.%s"},
+	},
+	"itx-zoom-meetings-v2": {
+		update:   withoutRetry(handleZoomMeetingUpdate),
+		requires: []mappingDependency{projectMappingParent.requiredBy("proj_id")},
+		delete:   withoutData(handleZoomMeetingDelete),
+		subjects: []string{IndexV1MeetingSubject, UpdateAccessV1MeetingSubject, DeleteAllAccessV1MeetingSubject, V1MeetingSeriesSplitSubject, V1MeetingOccurrenceCancelledSubject},
+		mappings: []string{"v1_meetings.%s", meetingFingerprintKeyFmt, meetingICSUIDKeyFmt, meetingSnapshotKeyFmt, meetingCancelledOccurrencesKeyFmt},
+	},
+	"itx-zoom-meetings-registrants-v2": registrantTableHandler,
+	"itx-zoom-meetings-registrants-v3": registrantTableHandler,
+	"itx-zoom-past-meetings-attendees": {
+		update:   handleZoomPastMeetingAttendeeUpdate,
+		requires: pastMeetingChildDependencies,
+		delete:   withData(handleZoomPastMeetingAttendeeDelete),
+		subjects: []string{IndexV1PastMeetingParticipantSubject, V1PastMeetingParticipantPutSubject, V1PastMeetingParticipantRemoveSubject, IndexV1PastMeetingSubject},
+		mappings: []string{"v1_past_meeting_attendees.%s", "v1-past-meeting.attendees.%s", "v1_participant_by_meeting_user.attendee.%s.%s", "v1_participant_identity.attendee.%s", "v1_user_reference_index.%s.%s"},
+	},
+	"itx-zoom-past-meetings-invitees": {
+		update:   handleZoomPastMeetingInviteeUpdate,
+		requires: pastMeetingChildDependencies,
+		delete:   withData(handleZoomPastMeetingInviteeDelete),
+		subjects: []string{IndexV1PastMeetingParticipantSubject, V1PastMeetingParticipantPutSubject, V1PastMeetingParticipantRemoveSubject, IndexV1PastMeetingSubject},
+		mappings: []string{"v1_past_meeting_invitees.%s", "v1-past-meeting.invitees.%s", "v1_participant_by_meeting_user.invitee.%s.%s", "v1_participant_identity.invitee.%s", "v1_user_reference_index.%s.%s"},
+	},
+	"itx-zoom-past-meetings-recordings": {
+		update:   handleZoomPastMeetingRecordingUpdate,
+		requires: pastMeetingChildDependencies,
+		delete:   withoutData(handleZoomPastMeetingRecordingDelete),
+		subjects: []string{
+			IndexV1PastMeetingRecordingSubject,
+			V1PastMeetingRecordingUpdateAccessSubject,
+			IndexV1PastMeetingTranscriptSubject,
+			V1PastMeetingTranscriptUpdateAccessSubject,
+			IndexV1PastMeetingTranscriptContentSubject,
+		},
+		mappings:  []string{"v1_past_meeting_recordings.%s", transcriptContentKeyFmt},
+		rawFields: []string{"recording_files", "sessions"},
+	},
+	"itx-zoom-past-meetings-summaries": {
+		update:   handleZoomPastMeetingSummaryUpdate,
+		requires: pastMeetingChildDependencies,
+		delete:   withoutData(handleZoomPastMeetingSummaryDelete),
+		subjects: []string{IndexV1PastMeetingSummarySubject, V1PastMeetingSummaryUpdateAccessSubject},
+		mappings: []string{"v1_past_meeting_summaries.%s", summaryFingerprintKeyFmt},
+	},
+	"itx-zoom-meetings-attachments-v2": {
+		update:   handleMeetingAttachmentUpdate,
+		requires: meetingChildDependencies,
+		delete:   withoutData(handleMeetingAttachmentDelete),
+		subjects: []string{IndexV1MeetingAttachmentSubject},
+		mappings: []string{"v1_meeting_attachments.%s"},
+	},
+	"itx-zoom-past-meetings-attachments": {
+		update:   handlePastMeetingAttachmentUpdate,
+		requires: pastMeetingChildDependencies,
+		delete:   withoutData(handlePastMeetingAttachmentDelete),
+		subjects: []string{IndexV1PastMeetingAttachmentSubject},
+		mappings: []string{"v1_past_meeting_attachments.%s"},
+	},
+	"itx-zoom-meetings-invite-responses-v2": {
+		update:   handleZoomMeetingInviteResponseUpdate,
+		requires: meetingChildDependencies,
+		delete:   withoutData(handleZoomMeetingInviteResponseDelete),
+		subjects: []string{IndexV1MeetingInviteResponseSubject, V1MeetingRSVPChangedSubject},
+		mappings: []string{"v1_invite_responses.%s", inviteResponseRSVPKeyFmt, "v1_meeting_invite_response_index.%s.%s"},
+	},
+	"itx-zoom-meetings-mappings-v2": {
+		update:   handleZoomMeetingMappingUpdate,
+		delete:   withData(handleZoomMeetingMappingDelete),
+		subjects: []string{IndexV1MeetingSubject, UpdateAccessV1MeetingSubject, V1MeetingRegistrantPutSubject, V1MeetingRegistrantRemoveSubject},
+		mappings: []string{"v1_meeting_mappings.%s", "v1-mappings.meeting-mappings.%s"},
+	},
+	"itx-zoom-past-meetings-mappings": {
+		update:   handleZoomPastMeetingMappingUpdate,
+		delete:   withData(handleZoomPastMeetingMappingDelete),
+		subjects: []string{IndexV1PastMeetingSubject, V1PastMeetingUpdateAccessSubject},
+		mappings: []string{"v1_past_meeting_mappings.%s", "v1-mappings.past-meeting-mappings.%s"},
+	},
+	"itx-zoom-past-meetings": {
+		update:    withoutRetry(handleZoomPastMeetingUpdate),
+		requires:  meetingChildDependencies,
+		delete:    withoutData(handleZoomPastMeetingDelete),
+		subjects:  []string{IndexV1PastMeetingSubject, V1PastMeetingUpdateAccessSubject, DeleteAllAccessV1PastMeetingSubject},
+		mappings:  []string{"v1_past_meetings.%s"},
+		rawFields: []string{"sessions"},
+	},
+	"itx-deleted-objects": {
+		update: handleDeletedObjectUpdate,
+		delete: func(ctx context.Context, key, _, _ string, _ map[string]any) bool {
+			// Deletion markers are only processed when written; removing one
+			// does not restore the deleted object.
+			logger.With("key", key).DebugContext(ctx, "itx-deleted-objects record deleted")
+			return false
+		},
+	},
+	"salesforce-merged_user": {
+		update:   handleMergedUserUpdate,
+		subjects: []string{V1PastMeetingParticipantRemoveSubject},
+		mappings: []string{"v1-merged-user.alias.%s"},
+		delete: func(ctx context.Context, key, _, _ string, v1Data map[string]any) bool {
+			// Merged user records are used on-demand during user lookups from the v1-objects KV bucket.
+			// A soft-deleted record may have been merged into another account.
+			if v1Data != nil {
+				return handleMergedUserUpdate(ctx, key, v1Data)
+			}
+			// No special processing needed here for hard deletes; this handler does not write a KV tombstone.
+			// TODO: Should clean up (tombstone) any per-user mappings, like the user sfid->email sfid index mapping.
+			logger.With("key", key).DebugContext(ctx, "salesforce-merged_user record deleted")
+			return false
+		},
+	},
+	"salesforce-alternate_email__c": {
+		update:   handleAlternateEmailUpdate,
+		mappings: []string{"v1-merged-user.alternate-emails.%s"},
+		delete: func(ctx context.Context, key, _, _ string, _ map[string]any) bool {
+			// Alternate email records remain in v1-objects KV bucket with _sdc_deleted_at set by WAL handler.
+			// The email mapping index also remains, but lookups will detect the soft-delete and skip the email.
+			// TODO: Should clean up (remove) soft-deleted email SFIDs from v1-merged-user.alternate-emails.{userSfid} mapping records.
+			logger.With("key", key).DebugContext(ctx, "salesforce-alternate_email__c record deleted")
+			return false
+		},
+	},
+}
+
+// withoutRetry adapts an update handler which never requests a retry.
+func withoutRetry(handler func(ctx context.Context, key string, v1Data map[string]any)) kvUpdateHandler {
+	return func(ctx context.Context, key string, v1Data map[string]any) bool {
+		handler(ctx, key, v1Data)
+		return false
+	}
+}
+
+// withoutData adapts a delete handler which only needs the key and ID.
+func withoutData(handler func(ctx context.Context, key, id string) bool) kvDeleteHandler {
+	return func(ctx context.Context, key, id, _ string, _ map[string]any) bool {
+		return handler(ctx, key, id)
+	}
+}
+
+// withData adapts a delete handler which uses the deleted record's data.
+func withData(handler func(ctx context.Context, key, id string, v1Data map[string]any) bool) kvDeleteHandler {
+	return func(ctx context.Context, key, id, _ string, v1Data map[string]any) bool {
+		return handler(ctx, key, id, v1Data)
+	}
+}
+
+// normalizeSchema detects the schema version of a record and converts it to
+// the shape expected by the handlers. Records matching no known version are
+// returned unchanged.
+func (t kvTableHandler) normalizeSchema(ctx context.Context, key string, v1Data map[string]any) map[string]any {
+	if len(t.versions) == 0 || v1Data == nil {
+		return v1Data
+	}
+	for _, version := range t.versions {
+		if version.detect != nil && !version.detect(v1Data) {
+			continue
+		}
+		logger.With("key", key, "schema_version", version.name).DebugContext(ctx, "detected v1 schema version")
+		if version.convert == nil {
+			return v1Data
+		}
+		return version.convert(v1Data)
+	}
+	logger.With("key", key).WarnContext(ctx, "unrecognized v1 schema version, processing record unchanged")
+	return v1Data
+}
+
+// renameFields returns a copy of v1Data with fields renamed from the keys to
+// the values of renames. Fields already present under the new name are not
+// overwritten.
+func renameFields(v1Data map[string]any, renames map[string]string) map[string]any {
+	converted := make(map[string]any, len(v1Data))
+	for field, value := range v1Data {
+		converted[field] = value
+	}
+	for from, to := range renames {
+		value, ok := converted[from]
+		if !ok {
+			continue
+		}
+		delete(converted, from)
+		if _, exists := converted[to]; !exists {
+			converted[to] = value
+		}
+	}
+	return converted
+}
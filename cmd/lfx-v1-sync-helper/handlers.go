@@ -5,8 +5,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -22,8 +24,12 @@ const (
 
 // shouldSkipSync checks if the record was last modified by this service and
 // should be skipped, because it originated in v2, and therefore does not need
-// to be synced from v1.
+// to be synced from v1. Records belonging to projects outside of the configured
+// project scope are also skipped.
 func shouldSkipSync(ctx context.Context, v1Data map[string]any) bool {
+	if !isRecordInScope(ctx, v1Data) {
+		return true
+	}
 	if lastModifiedBy, ok := v1Data["lastmodifiedbyid"].(string); ok && lastModifiedBy != "" {
 		// Check if the lastmodifiedbyid matches our Auth0 Client ID with @clients suffix.
 		ourServiceID := cfg.Auth0ClientID + "@clients"
@@ -37,8 +43,7 @@ func shouldSkipSync(ctx context.Context, v1Data map[string]any) bool {
 
 // kvHandler processes KV bucket updates from Meltano.
 // Returns true if the operation should be retried, false otherwise.
-func kvHandler(entry jetstream.KeyValueEntry) bool {
-	ctx := context.Background()
+func kvHandler(ctx context.Context, entry jetstream.KeyValueEntry) bool {
 
 	key := entry.Key()
 	operation := entry.Operation()
@@ -62,19 +67,55 @@ func kvHandler(entry jetstream.KeyValueEntry) bool {
 func handleKVPut(ctx context.Context, entry jetstream.KeyValueEntry) bool {
 	key := entry.Key()
 
-	// Parse the data (try JSON first, then msgpack)
+	// Decompress gzip-compressed values.
+	value, err := decompressV1Value(key, entry.Value())
+	if err != nil {
+		logger.With(errKey, err, "key", key).ErrorContext(ctx, "failed to decompress KV entry data")
+		return false
+	}
+
+	prefix := kvObjectType(key)
+	table, known := kvTableHandlers[prefix]
+
+	// Parse the data (try JSON first, then msgpack), keeping the large fields
+	// of known-large records as raw JSON.
 	var v1Data map[string]any
-	if err := json.Unmarshal(entry.Value(), &v1Data); err != nil {
+	if known && len(table.rawFields) > 0 && bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) {
+		if partial, partialErr := decodeV1ObjectPartially(value, table.rawFields); partialErr == nil {
+			v1Data = partial
+			partiallyDecodedRecords.inc(prefix)
+		}
+	}
+	if v1Data != nil {
+		ctx = withRecordSource(ctx, v1Data, value)
+		logger.With("key", key).DebugContext(ctx, "successfully decoded JSON data partially")
+	} else if err := json.Unmarshal(value, &v1Data); err != nil {
 		// JSON failed, try msgpack
-		if msgErr := msgpack.Unmarshal(entry.Value(), &v1Data); msgErr != nil {
+		if msgErr := msgpack.Unmarshal(value, &v1Data); msgErr != nil {
 			logger.With(errKey, err, "msgpack_error", msgErr, "key", key).ErrorContext(ctx, "failed to unmarshal KV entry data as JSON or msgpack")
 			return false
 		}
 		logger.With("key", key).DebugContext(ctx, "successfully unmarshalled msgpack data")
 	} else {
+		// Let the converters decode their input structs from the value.
+		ctx = withRecordSource(ctx, v1Data, value)
 		logger.With("key", key).DebugContext(ctx, "successfully unmarshalled JSON data")
 	}
 
+	// Convert records from older or newer v1 table schemas before any
+	// handler (including soft deletes) reads them.
+	if known {
+		v1Data = table.normalizeSchema(ctx, key, v1Data)
+	}
+
+	// Hold the records of paused projects, including soft deletes, until the
+	// project is unpaused.
+	if known {
+		if held, retry := holdForPausedProject(ctx, key, v1Data); held {
+			return retry
+		}
+	}
+
 	// Check if this is a soft delete (record has _sdc_deleted_at field).
 	if deletedAt, exists := v1Data["_sdc_deleted_at"]; exists && deletedAt != nil && deletedAt != "" {
 		logger.With("key", key, "_sdc_deleted_at", deletedAt).InfoContext(ctx, "processing soft delete from WAL")
@@ -86,70 +127,46 @@ func handleKVPut(ctx context.Context, entry jetstream.KeyValueEntry) bool {
 		return false
 	}
 
-	// Extract the prefix (everything before the first period) for faster lookup.
-	prefix := key
-	if dotIndex := strings.Index(key, "."); dotIndex != -1 {
-		prefix = key[:dotIndex]
+	if !known {
+		logger.With("key", key).WarnContext(ctx, "unknown object type, ignoring")
+		return false
 	}
 
-	// Determine the object type based on the key prefix.
-	switch prefix {
-	case "salesforce-project__c":
-		handleProjectUpdate(ctx, key, v1Data)
+	// Skip records unchanged since they were last synced.
+	if skipUnchangedContent(ctx, prefix, key, v1Data) {
 		return false
-	case "platform-collaboration__c":
-		handleCommitteeUpdate(ctx, key, v1Data)
-		return false
-	case "platform-community__c":
-		handleCommitteeMemberUpdate(ctx, key, v1Data)
-		return false
-	case "itx-poll":
-		handleVoteUpdate(ctx, key, v1Data)
-		return false
-	case "itx-poll-vote":
-		return handleVoteResponseUpdate(ctx, key, v1Data)
-	case "itx-surveys":
-		handleSurveyUpdate(ctx, key, v1Data)
-		return false
-	case "itx-survey-responses":
-		return handleSurveyResponseUpdate(ctx, key, v1Data)
-	case "itx-zoom-meetings-v2":
-		handleZoomMeetingUpdate(ctx, key, v1Data)
-		return false
-	case "itx-zoom-meetings-registrants-v2":
-		return handleZoomMeetingRegistrantUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings-attendees":
-		return handleZoomPastMeetingAttendeeUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings-invitees":
-		return handleZoomPastMeetingInviteeUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings-recordings":
-		return handleZoomPastMeetingRecordingUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings-summaries":
-		return handleZoomPastMeetingSummaryUpdate(ctx, key, v1Data)
-	case "itx-zoom-meetings-attachments-v2":
-		return handleMeetingAttachmentUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings-attachments":
-		return handlePastMeetingAttachmentUpdate(ctx, key, v1Data)
-	case "itx-zoom-meetings-invite-responses-v2":
-		return handleZoomMeetingInviteResponseUpdate(ctx, key, v1Data)
-	case "itx-zoom-meetings-mappings-v2":
-		return handleZoomMeetingMappingUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings-mappings":
-		return handleZoomPastMeetingMappingUpdate(ctx, key, v1Data)
-	case "itx-zoom-past-meetings":
-		handleZoomPastMeetingUpdate(ctx, key, v1Data)
-		return false
-	case "salesforce-merged_user":
-		// Merged user records are used on-demand during user lookups from v1-objects KV bucket.
-		// No special processing needed - just log for debugging.
-		logger.With("key", key).DebugContext(ctx, "salesforce-merged_user record updated")
-		return false
-	case "salesforce-alternate_email__c":
-		return handleAlternateEmailUpdate(ctx, key, v1Data)
-	default:
-		logger.With("key", key).WarnContext(ctx, "unknown object type, ignoring")
+	}
+
+	ctx = withMiddleware(ctx, table.middleware)
+	v1Data, err = runPreConversionMiddleware(ctx, key, v1Data)
+	if err != nil {
+		logMiddlewareStop(ctx, logger.With("key", key), err)
 		return false
 	}
+	if stop, retry := checkMappingDependencies(ctx, prefix, key, table.requires, v1Data); stop {
+		return retry
+	}
+	ctx = withRecordAccessProject(ctx, key, v1Data)
+	ctx = withAccessRecordKey(ctx, key)
+
+	ctx, span := startHandlerSpan(ctx, "sync", key)
+	ctx, publishes := withPublishTracker(ctx)
+	var retry bool
+	if table.canary != nil && canarySampled(ctx, key) {
+		retry = runCanary(ctx, prefix, key, v1Data, table.update, table.canary)
+	} else {
+		retry = table.update(ctx, key, v1Data)
+	}
+	endRetrySpan(span, retry)
+
+	// Handlers also return false when giving up on a record, so only a put
+	// whose messages were all published counts as synced.
+	recordContentSync(ctx, key, !retry && publishes.failures() == 0 && publishes.publishes() > 0)
+	if !retry {
+		storeAccessProject(ctx, key)
+		releaseDependents(ctx, prefix, key, v1Data)
+	}
+	return retry
 }
 
 // handleKVDelete processes a KV delete operation (hard delete from KV bucket).
@@ -157,6 +174,11 @@ func handleKVPut(ctx context.Context, entry jetstream.KeyValueEntry) bool {
 func handleKVDelete(ctx context.Context, entry jetstream.KeyValueEntry) bool {
 	key := entry.Key()
 
+	// Hold deletes instead of propagating them after a mass purge.
+	if held, retry := holdDuringMassPurge(ctx, entry); held {
+		return retry
+	}
+
 	logger.With("key", key).InfoContext(ctx, "processing hard delete from KV bucket")
 	return handleResourceDelete(ctx, key, "", nil)
 }
@@ -174,11 +196,8 @@ func handleKVSoftDelete(ctx context.Context, key string, v1Data map[string]any)
 // nil is acceptable and handlers must fall back gracefully.
 // Returns true if the operation should be retried, false otherwise.
 func handleResourceDelete(ctx context.Context, key string, v1Principal string, v1Data map[string]any) bool {
-	// Extract the prefix (everything before the first period) for faster lookup.
-	prefix := key
-	if dotIndex := strings.Index(key, "."); dotIndex != -1 {
-		prefix = key[:dotIndex]
-	}
+	prefix := kvObjectType(key)
+	forgetContentHash(ctx, key)
 
 	// Extract SFID from key (everything after the first period).
 	sfid := ""
@@ -192,53 +211,82 @@ func handleResourceDelete(ctx context.Context, key string, v1Principal string, v
 	}
 
 	// Determine the object type based on the key prefix and handle deletion.
-	switch prefix {
-	case "salesforce-project__c":
-		return handleProjectDelete(ctx, key, sfid, v1Principal)
-	case "platform-collaboration__c":
-		return handleCommitteeDelete(ctx, key, sfid, v1Principal)
-	case "platform-community__c":
-		return handleCommitteeMemberDelete(ctx, key, sfid, v1Principal)
-	case "salesforce-merged_user":
-		// Merged user records are used on-demand during user lookups from the v1-objects KV bucket.
-		// No special processing needed here for hard deletes; this handler does not write a KV tombstone.
-		// TODO: Should clean up (tombstone) any per-user mappings, like the user sfid->email sfid index mapping.
-		logger.With("key", key).DebugContext(ctx, "salesforce-merged_user record deleted")
+	table, ok := kvTableHandlers[prefix]
+	if !ok || table.delete == nil {
+		logger.With("key", key).WarnContext(ctx, "unknown object type for deletion, ignoring")
 		return false
-	case "salesforce-alternate_email__c":
-		// Alternate email records remain in v1-objects KV bucket with _sdc_deleted_at set by WAL handler.
-		// The email mapping index also remains, but lookups will detect the soft-delete and skip the email.
-		// TODO: Should clean up (remove) soft-deleted email SFIDs from v1-merged-user.alternate-emails.{userSfid} mapping records.
-		logger.With("key", key).DebugContext(ctx, "salesforce-alternate_email__c record deleted")
+	}
+	v1Data = table.normalizeSchema(ctx, key, v1Data)
+	ctx = withMiddleware(ctx, table.middleware)
+	ctx = withRecordAccessProject(ctx, key, v1Data)
+	ctx = withAccessRecordKey(ctx, key)
+	ctx, span := startHandlerSpan(ctx, "delete", key)
+	retry := table.delete(ctx, key, sfid, v1Principal, v1Data)
+	endRetrySpan(span, retry)
+	if !retry && v1Data == nil {
+		forgetAccessProject(ctx, key)
+	}
+	return retry
+}
+
+// handleIndexedObjectDelete processes the deletion of a v1 record synced as a
+// v2 object of the given type which is only indexed and access controlled
+// (votes, surveys, and their responses): the object is deleted from the
+// indexer, its access is removed from fga-sync, and its mapping is
+// tombstoned. Records which were never synced, or whose delete was already
+// processed, are skipped.
+// Returns true if the operation should be retried, false otherwise.
+func handleIndexedObjectDelete(ctx context.Context, key, uid, objectType, indexerSubject, mappingKeyFmt string) bool {
+	funcLogger := logger.With("key", key, "object_type", objectType, "uid", uid)
+
+	mappingKey := fmt.Sprintf(mappingKeyFmt, uid)
+	entry, err := mappingsKV.Get(ctx, mappingKey)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		funcLogger.InfoContext(ctx, "mapping not found, nothing to delete")
 		return false
-	case "itx-zoom-meetings-v2":
-		return handleZoomMeetingDelete(ctx, key, sfid)
-	case "itx-zoom-meetings-registrants-v2":
-		return handleZoomMeetingRegistrantDelete(ctx, key, sfid, v1Data)
-	case "itx-zoom-past-meetings-attendees":
-		return handleZoomPastMeetingAttendeeDelete(ctx, key, sfid, v1Data)
-	case "itx-zoom-past-meetings":
-		return handleZoomPastMeetingDelete(ctx, key, sfid)
-	case "itx-zoom-meetings-invite-responses-v2":
-		return handleZoomMeetingInviteResponseDelete(ctx, key, sfid)
-	case "itx-zoom-past-meetings-invitees":
-		return handleZoomPastMeetingInviteeDelete(ctx, key, sfid, v1Data)
-	case "itx-zoom-meetings-mappings-v2":
-		return handleZoomMeetingMappingDelete(ctx, key, sfid, v1Data)
-	case "itx-zoom-past-meetings-mappings":
-		return handleZoomPastMeetingMappingDelete(ctx, key, sfid, v1Data)
-	case "itx-zoom-past-meetings-recordings":
-		return handleZoomPastMeetingRecordingDelete(ctx, key, sfid)
-	case "itx-zoom-past-meetings-summaries":
-		return handleZoomPastMeetingSummaryDelete(ctx, key, sfid)
-	case "itx-zoom-meetings-attachments-v2":
-		return handleMeetingAttachmentDelete(ctx, key, sfid)
-	case "itx-zoom-past-meetings-attachments":
-		return handlePastMeetingAttachmentDelete(ctx, key, sfid)
-	default:
-		logger.With("key", key).WarnContext(ctx, "unknown object type for deletion, ignoring")
+	}
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to get mapping for deletion")
+		return true
+	}
+	if isTombstonedMapping(entry.Value()) {
+		funcLogger.DebugContext(ctx, "delete already processed, skipping")
 		return false
 	}
+
+	if err := sendIndexerMessage(ctx, indexerSubject, MessageActionDeleted, uid, []string{}); err != nil {
+		funcLogger.With(errKey, err, "subject", indexerSubject).ErrorContext(ctx, "failed to send delete indexer message")
+		return true
+	}
+
+	if err := sendDeleteAccessMessage(ctx, objectType, uid); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send delete access message")
+		return true
+	}
+
+	if err := tombstoneMapping(ctx, mappingKey); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone mapping")
+	}
+
+	funcLogger.InfoContext(ctx, "successfully processed delete")
+	return false
+}
+
+// sendDeleteAccessMessage sends a generic delete_access message to fga-sync,
+// removing all the relations of a deleted object.
+func sendDeleteAccessMessage(ctx context.Context, objectType, uid string) error {
+	accessMsg := GenericFGAMessage{
+		ObjectType: objectType,
+		Operation:  "delete_access",
+		Data: map[string]interface{}{
+			"uid": uid,
+		},
+	}
+	accessMsgBytes, err := json.Marshal(accessMsg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delete access message: %w", err)
+	}
+	return sendAccessMessage(ctx, UpdateAccessSubject, accessMsgBytes)
 }
 
 // tombstoneMapping stores a tombstone marker in the mapping KV store.
@@ -57,6 +57,15 @@ var allowedAppointedByValues = map[string]bool{
 	"None":                               true,
 }
 
+// allowedVotingStatuses defines the valid values for voting_status__c mapping to voting status.
+var allowedVotingStatuses = map[string]bool{
+	"Alternate Voting Rep": true,
+	"Observer":             true,
+	"Voting Rep":           true,
+	"Emeritus":             true,
+	"None":                 true,
+}
+
 // allowedRoleNames defines the valid values for role__c mapping to role name.
 var allowedRoleNames = map[string]bool{
 	"Chair":                  true,
@@ -102,6 +111,27 @@ func mapAppointedByToValidValue(ctx context.Context, appointedBy string) string
 	return "None"
 }
 
+// mapVotingStatusToValidValue filters and maps voting_status__c to a valid voting status value,
+// matching the allowed values case-insensitively.
+func mapVotingStatusToValidValue(ctx context.Context, votingStatus string) string {
+	if votingStatus == "" {
+		return "None"
+	}
+
+	if allowedVotingStatuses[votingStatus] {
+		return votingStatus
+	}
+	for allowed := range allowedVotingStatuses {
+		if strings.EqualFold(strings.TrimSpace(votingStatus), allowed) {
+			return allowed
+		}
+	}
+
+	// If the value is not in the allowed list, use None as fallback.
+	logger.With("original_voting_status", votingStatus, "fallback_voting_status", "None").WarnContext(ctx, "voting status value not in allowed list, using fallback")
+	return "None"
+}
+
 // mapTypeToCategory filters and maps type__c to category.
 func mapTypeToCategory(ctx context.Context, typeVal, committeeName string) *string {
 	if typeVal == "" {
@@ -511,7 +541,8 @@ func handleCommitteeMemberUpdate(ctx context.Context, key string, v1Data map[str
 		return
 	}
 
-	// Check if parent committee exists in mappings before proceeding.
+	// The dispatcher parks members until their parent committee mapping
+	// exists, so it is only missing if removed meanwhile.
 	committeeMappingKey := fmt.Sprintf("committee.sfid.%s", collaborationNameV1)
 	committeeEntry, committeeLookupErr := mappingsKV.Get(ctx, committeeMappingKey)
 	if committeeLookupErr != nil {
@@ -711,7 +742,7 @@ func mapV1DataToCommitteeMemberCreatePayload(ctx context.Context, committeeUID s
 			StartDate *string `json:"start_date,omitempty"`
 			EndDate   *string `json:"end_date,omitempty"`
 		}{
-			Status: votingStatus,
+			Status: mapVotingStatusToValidValue(ctx, votingStatus),
 		}
 
 		if votingStartDate, ok := v1Data["voting_start_date__c"].(string); ok && votingStartDate != "" {
@@ -878,7 +909,7 @@ func mapV1DataToCommitteeMemberUpdatePayload(ctx context.Context, committeeUID s
 			StartDate *string `json:"start_date,omitempty"`
 			EndDate   *string `json:"end_date,omitempty"`
 		}{
-			Status: votingStatus,
+			Status: mapVotingStatusToValidValue(ctx, votingStatus),
 		}
 
 		if votingStartDate, ok := v1Data["voting_start_date__c"].(string); ok && votingStartDate != "" {
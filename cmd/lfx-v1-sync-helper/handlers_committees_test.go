@@ -0,0 +1,36 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestMapVotingStatusToValidValue(t *testing.T) {
+	previousLogger := logger
+	t.Cleanup(func() { logger = previousLogger })
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		votingStatus string
+		want         string
+	}{
+		{"", "None"},
+		{"Voting Rep", "Voting Rep"},
+		{"Alternate Voting Rep", "Alternate Voting Rep"},
+		{"Observer", "Observer"},
+		{"Emeritus", "Emeritus"},
+		{"voting rep", "Voting Rep"},
+		{" OBSERVER ", "Observer"},
+		{"Non-voting", "None"},
+	}
+	for _, tt := range tests {
+		if got := mapVotingStatusToValidValue(context.Background(), tt.votingStatus); got != tt.want {
+			t.Errorf("mapVotingStatusToValidValue(%q) = %q, want %q", tt.votingStatus, got, tt.want)
+		}
+	}
+}
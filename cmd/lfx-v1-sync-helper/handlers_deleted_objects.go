@@ -0,0 +1,121 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// handleDeletedObjectUpdate processes a deletion marker from the v1
+// itx-deleted-objects table, which v1 writes when an object is deleted. Meltano
+// does not always emit a KV delete for the deleted object itself, so the
+// referenced v1-objects record is marked as deleted with "_sdc_deleted_at",
+// the same way as WAL and DynamoDB deletes, and the KV watcher then routes it
+// to the delete handler of its object type.
+//
+// Deletion markers have the fields:
+//   - object_type: the key prefix (v1 table) of the deleted object, e.g. "itx-zoom-meetings-v2"
+//   - object_id: the ID of the deleted object (the key after the prefix)
+//   - deleted_at: when the object was deleted (optional, defaults to now)
+//   - deleted_by: the v1 principal who deleted the object (optional)
+//
+// Returns true if the operation should be retried, false otherwise.
+func handleDeletedObjectUpdate(ctx context.Context, key string, v1Data map[string]any) bool {
+	funcLogger := logger.With("key", key)
+
+	objectType, _ := v1Data["object_type"].(string)
+	objectID, _ := v1Data["object_id"].(string)
+	if objectType == "" || objectID == "" {
+		funcLogger.WarnContext(ctx, "deletion marker missing object_type or object_id, skipping")
+		return false
+	}
+	if objectType == kvObjectType(key) {
+		funcLogger.WarnContext(ctx, "deletion marker references another deletion marker, skipping")
+		return false
+	}
+
+	objectKey := fmt.Sprintf("%s.%s", objectType, objectID)
+	funcLogger = funcLogger.With("object_key", objectKey)
+
+	deletedAt, _ := v1Data["deleted_at"].(string)
+	if deletedAt == "" {
+		deletedAt = bootstrap.Now().UTC().Format(time.RFC3339)
+	}
+	deletedBy, _ := v1Data["deleted_by"].(string)
+
+	existing, err := v1KV.Get(ctx, objectKey)
+	if err != nil && err != jetstream.ErrKeyNotFound {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to get deleted object from KV bucket")
+		return true
+	}
+
+	// When the object was never replicated (or has been purged), a record
+	// holding only the deletion marker is created, like DynamoDB REMOVE events
+	// without a KV entry, so its delete handler still runs.
+	objectData := map[string]any{}
+	var revision uint64
+	if err == nil {
+		revision = existing.Revision()
+		value, decompressErr := decompressV1Value(objectKey, existing.Value())
+		if decompressErr != nil {
+			funcLogger.With(errKey, decompressErr).ErrorContext(ctx, "failed to decompress deleted object data")
+			return false
+		}
+		if unmarshalErr := json.Unmarshal(value, &objectData); unmarshalErr != nil {
+			if msgpackErr := msgpack.Unmarshal(value, &objectData); msgpackErr != nil {
+				funcLogger.With(errKey, unmarshalErr, "msgpack_error", msgpackErr).ErrorContext(ctx, "failed to unmarshal deleted object data")
+				return false
+			}
+		}
+	}
+
+	if existingDeletedAt, ok := objectData["_sdc_deleted_at"]; ok && existingDeletedAt != nil && existingDeletedAt != "" {
+		funcLogger.DebugContext(ctx, "deleted object already marked as deleted, skipping")
+		return false
+	}
+
+	objectData["_sdc_deleted_at"] = deletedAt
+	objectData["_sdc_received_at"] = bootstrap.Now().UTC().Format(time.RFC3339)
+	if deletedBy != "" {
+		// Attribute the delete to the v1 principal (see extractV1Principal).
+		objectData["lastmodifiedbyid"] = deletedBy
+		objectData["lastmodifieddate"] = deletedAt
+	}
+
+	var dataBytes []byte
+	if cfg.UseMsgpack {
+		dataBytes, err = msgpack.Marshal(objectData)
+	} else {
+		dataBytes, err = json.Marshal(objectData)
+	}
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal deletion marker data")
+		return false
+	}
+
+	if revision == 0 {
+		_, err = v1KV.Create(ctx, objectKey, dataBytes)
+	} else {
+		_, err = v1KV.Update(ctx, objectKey, dataBytes, revision)
+	}
+	if err != nil {
+		if isRevisionMismatchError(err) || err == jetstream.ErrKeyExists {
+			funcLogger.With(errKey, err, "revision", revision).WarnContext(ctx, "KV revision mismatch on delete, will retry")
+			return true
+		}
+		funcLogger.With(errKey, err, "revision", revision).ErrorContext(ctx, "failed to write KV entry with deletion marker")
+		return false
+	}
+
+	funcLogger.With("encoding", getEncodingFormat()).InfoContext(ctx, "marked KV entry as deleted from v1 deletion marker")
+	return false
+}
@@ -46,9 +46,24 @@ const (
 	// V1MeetingRegistrantRemoveSubject is the subject for removing a v1 meeting registrant's access.
 	V1MeetingRegistrantRemoveSubject = "lfx.remove_registrant.v1_meeting"
 
+	// V1MeetingRegistrantHostPromoteSubject is the subject for granting a v1 meeting registrant host access.
+	V1MeetingRegistrantHostPromoteSubject = "lfx.promote_registrant_host.v1_meeting"
+
+	// V1MeetingRegistrantHostDemoteSubject is the subject for revoking a v1 meeting registrant's host access.
+	V1MeetingRegistrantHostDemoteSubject = "lfx.demote_registrant_host.v1_meeting"
+
 	// IndexV1MeetingInviteResponseSubject is the subject for the v1 meeting invite response indexing.
 	IndexV1MeetingInviteResponseSubject = "lfx.index.v1_meeting_rsvp"
 
+	// V1MeetingRSVPChangedSubject is the subject for the v1 meeting RSVP change events.
+	V1MeetingRSVPChangedSubject = "lfx.rsvp_changed.v1_meeting"
+
+	// V1MeetingSeriesSplitSubject is the subject for the v1 meeting series split events.
+	V1MeetingSeriesSplitSubject = "lfx.series_split.v1_meeting"
+
+	// V1MeetingOccurrenceCancelledSubject is the subject for the v1 meeting occurrence cancellation events.
+	V1MeetingOccurrenceCancelledSubject = "lfx.occurrence_cancelled.v1_meeting"
+
 	// IndexV1MeetingAttachmentSubject is the subject for the v1 meeting attachment indexing.
 	IndexV1MeetingAttachmentSubject = "lfx.index.v1_meeting_attachment"
 
@@ -85,6 +100,9 @@ const (
 	// IndexV1PastMeetingTranscriptSubject is the subject for the v1 past meeting transcript indexing.
 	IndexV1PastMeetingTranscriptSubject = "lfx.index.v1_past_meeting_transcript"
 
+	// IndexV1PastMeetingTranscriptContentSubject is the subject for the v1 past meeting transcript content indexing.
+	IndexV1PastMeetingTranscriptContentSubject = "lfx.index.v1_past_meeting_transcript_content"
+
 	// V1PastMeetingTranscriptUpdateAccessSubject is the subject for the v1 past meeting transcript access control updates.
 	V1PastMeetingTranscriptUpdateAccessSubject = "lfx.update_access.v1_past_meeting_transcript"
 
@@ -102,55 +120,73 @@ type MeetingIndexerMessage struct {
 	Data    any               `json:"data"`
 	// Tags is a list of tags to be set on the indexed resource for search.
 	Tags []string `json:"tags"`
+	// DocumentID is the ID of the indexed document, per INDEXER_DOCUMENT_ID.
+	DocumentID string `json:"document_id,omitempty"`
 }
 
 // sendIndexerMessage sends the message to the NATS server for the indexer.
 func sendIndexerMessage(ctx context.Context, subject string, action MessageAction, data any, tags []string) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, data); err != nil || !publish {
+		return err
+	}
+
+	applySyncWarnings(ctx, subject, data)
+	applyIndexerRedaction(subject, data)
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		// Fallback for system-generated events that don't have user auth context
-		// This is just a dummy value so that the indexer service can still process the message,
-		// given that it requires an authorization header.
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	message := MeetingIndexerMessage{
-		Action:  action,
-		Headers: headers,
-		Data:    data,
-		Tags:    tags,
+		Action:     action,
+		Headers:    headers,
+		Data:       data,
+		Tags:       tags,
+		DocumentID: indexerDocumentID(subject, data),
 	}
 
-	messageBytes, err := json.Marshal(message)
+	// Marshal the message, applying the oversize policy if needed.
+	messageBytes, err := encodeIndexerMessage(ctx, subject, message)
 	if err != nil {
-		return fmt.Errorf("failed to marshal indexer message for subject %s: %w", subject, err)
+		return err
 	}
 
 	logger.With("subject", subject, "action", action, "tags_count", len(tags)).DebugContext(ctx, "constructed indexer message")
 
 	// Publish the message to NATS
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
 
 // sendAccessMessage sends a pre-marshalled message to the NATS server.
 // This is a generic function that can be used for access control updates, put operations, etc.
-func sendAccessMessage(subject string, messageBytes []byte) error {
+func sendAccessMessage(ctx context.Context, subject string, messageBytes []byte) error {
+	subject = accessSubject(ctx, subject)
+
+	// Request an acknowledgment from fga-sync, if enabled.
+	if accessAckEnabled(ctx) {
+		return requestAccessMessage(ctx, subject, messageBytes)
+	}
+
 	// Publish the message to NATS
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish message to subject %s: %w", subject, err)
 	}
 
@@ -169,16 +205,16 @@ type MeetingAccessMessage struct {
 
 // convertMapToInputMeeting converts a map[string]any to an InputMeeting struct.
 func convertMapToInputMeeting(ctx context.Context, v1Data map[string]any) (*meetingInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for meeting: %w", err)
+	// Decode the record into the InputMeeting struct.
+	var meeting meetingInput
+	if err := decodeV1Record(ctx, v1Data, &meeting); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into meetingInput: %w", err)
 	}
 
-	// Unmarshal JSON bytes into InputMeeting struct
-	var meeting meetingInput
-	if err := json.Unmarshal(jsonBytes, &meeting); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into meetingInput: %w", err)
+	// Committees decoded from the record list their allowed voting statuses
+	// explicitly, like those built from the mapping index.
+	for i := range meeting.Committees {
+		meeting.Committees[i].AllowedVotingStatuses = normalizeCommitteeFilters(meeting.Committees[i].AllowedVotingStatuses)
 	}
 
 	// We need to populate the ID for the v2 system
@@ -192,9 +228,13 @@ func convertMapToInputMeeting(ctx context.Context, v1Data map[string]any) (*meet
 		meeting.ProjectSFID = projectSFID
 
 		// Take the v1 project salesforce ID and look up the v2 project UID.
-		projectMappingKey := fmt.Sprintf("project.sfid.%s", meeting.ProjectSFID)
-		if entry, err := mappingsKV.Get(ctx, projectMappingKey); err == nil {
-			meeting.ProjectUID = string(entry.Value())
+		if projectUID, err := projectUIDBySFID(ctx, meeting.ProjectSFID); err == nil {
+			meeting.ProjectUID = projectUID
+		}
+
+		// Meetings without a visibility take that of their project.
+		if meeting.Visibility == "" {
+			meeting.Visibility = defaultMeetingVisibility(ctx, "itx-zoom-meetings-v2", meeting.ProjectSFID, meeting.ProjectUID)
 		}
 	}
 
@@ -270,6 +310,7 @@ func getMeetingTags(meeting *meetingInput) []string {
 		fmt.Sprintf("project_uid:%s", meeting.ProjectUID),
 		fmt.Sprintf("title:%s", meeting.Title),
 		fmt.Sprintf("meeting_type:%s", meeting.MeetingType),
+		fmt.Sprintf("canonical_meeting_type:%s", meeting.CanonicalMeetingType),
 	}
 	for _, committee := range meeting.Committees {
 		tags = append(tags, fmt.Sprintf("committee_uid:%s", committee.UID))
@@ -295,6 +336,11 @@ func handleZoomMeetingUpdate(ctx context.Context, key string, v1Data map[string]
 		return
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, meeting); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return
+	}
+
 	// Extract the meeting ID
 	meetingID := meeting.ID
 	if meetingID == "" {
@@ -303,33 +349,36 @@ func handleZoomMeetingUpdate(ctx context.Context, key string, v1Data map[string]
 	}
 	funcLogger = funcLogger.With("meeting_id", meetingID)
 
-	// Check if parent project exists in mappings before proceeding. Because
-	// convertMapToInputMeeting has already looked up the SFID project ID
-	// mapping, we don't need to do it again: we can just check if ProjectID (v2
-	// UID) is set.
+	// The dispatcher parks meetings until their parent project mapping exists,
+	// so ProjectUID (looked up by convertMapToInputMeeting) is only unset for
+	// meetings without a v1 project.
 	if meeting.ProjectUID == "" {
-		funcLogger.With("project_sfid", meeting.ProjectSFID).InfoContext(ctx, "skipping meeting sync - parent project not found in mappings")
+		funcLogger.With("project_sfid", meeting.ProjectSFID).InfoContext(ctx, "skipping meeting sync - no parent project")
 		return
 	}
 
 	// Try to get committee mappings from the index first
 	var committees []string
+	indexFound := false
 	committeeMappings := make(map[string]mappingCommittee)
 	indexKey := fmt.Sprintf("v1-mappings.meeting-mappings.%s", meetingID)
 	indexEntry, err := mappingsKV.Get(ctx, indexKey)
-	if err == nil && indexEntry != nil {
+	if err == nil && indexEntry != nil && !isTombstonedMapping(indexEntry.Value()) {
 		if err := json.Unmarshal(indexEntry.Value(), &committeeMappings); err != nil {
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to unmarshal meeting mapping index")
 		} else {
-			// Extract committee IDs from the mappings
-			for committeeID := range committeeMappings {
-				committees = append(committees, committeeID)
+			// The index is keyed by mapping ID; extract the committee IDs from the values.
+			indexFound = true
+			for _, committee := range committeeMappings {
+				committees = append(committees, committee.CommitteeID)
 			}
 		}
 	}
 
-	// Fallback: Extract committees from v1Data if no mappings found
-	if len(committees) == 0 {
+	// Fallback: Extract committees from v1Data if no mapping index exists. An
+	// existing but empty index means all mappings were deleted, so the
+	// (possibly stale) committees on the meeting record must not be used.
+	if !indexFound {
 		if committeesData, ok := v1Data["committees"].([]any); ok {
 			for _, c := range committeesData {
 				if committee, ok := c.(map[string]any); ok {
@@ -347,11 +396,66 @@ func handleZoomMeetingUpdate(ctx context.Context, key string, v1Data map[string]
 		indexerAction = MessageActionUpdated
 	}
 
-	tags := getMeetingTags(meeting)
-	if err := sendIndexerMessage(ctx, IndexV1MeetingSubject, indexerAction, meeting, tags); err != nil {
-		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting indexer message")
+	meeting.CanonicalMeetingType = classifyMeetingType(ctx, meeting.MeetingType, meeting.Title, committees)
+
+	// Skip re-indexing meetings whose only changes are to ignorable fields
+	// (e.g. a host key rotation), but still update their access.
+	fingerprint, err := newMeetingFingerprint(meeting)
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to compute meeting fingerprint")
 		return
 	}
+	previousFingerprint, synced := getMeetingFingerprint(ctx, meetingID)
+	indexChanged := indexerAction == MessageActionCreated || !synced || fingerprint != previousFingerprint
+
+	// A new ICS UID starts a new calendar series: re-index the meeting with
+	// both ICS UIDs flagged, and send a series split event.
+	icsState, seriesSplit, storeICSState, err := nextMeetingICSState(ctx, meeting)
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to get meeting ICS UID state")
+		return
+	}
+	if seriesSplit {
+		indexChanged = true
+	}
+
+	// Newly cancelled occurrences re-index the meeting, and are each sent as
+	// a cancellation event.
+	cancelledOccurrences, newlyCancelled, storeCancelledOccurrences, err := nextMeetingCancelledOccurrences(ctx, meeting)
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to get meeting cancelled occurrences")
+		return
+	}
+	if len(newlyCancelled) > 0 {
+		indexChanged = true
+	}
+
+	if indexChanged {
+		tags := append(getMeetingTags(meeting), getMeetingSeriesTags(icsState)...)
+		if err := sendIndexerMessage(ctx, IndexV1MeetingSubject, indexerAction, meeting, tags); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting indexer message")
+			return
+		}
+	} else {
+		funcLogger.DebugContext(ctx, "meeting indexed fields unchanged, skipping indexer message")
+	}
+
+	if seriesSplit {
+		if err := sendMeetingSeriesSplitEvent(ctx, meeting, icsState); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting series split event")
+			return
+		}
+		funcLogger.With("previous_ics_uid", icsState.PreviousICSUID, "ics_uid", icsState.ICSUID).
+			InfoContext(ctx, "meeting series split")
+	}
+
+	if len(newlyCancelled) > 0 {
+		if err := sendMeetingOccurrenceCancelledEvents(ctx, meeting, newlyCancelled); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting occurrence cancelled events")
+			return
+		}
+		funcLogger.With("occurrence_ids", newlyCancelled).InfoContext(ctx, "meeting occurrences cancelled")
+	}
 
 	accessMsg := MeetingAccessMessage{
 		UID:        meetingID,
@@ -367,7 +471,7 @@ func handleZoomMeetingUpdate(ctx context.Context, key string, v1Data map[string]
 		return
 	}
 
-	if err := sendAccessMessage(UpdateAccessV1MeetingSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, UpdateAccessV1MeetingSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting access message")
 		return
 	}
@@ -376,9 +480,25 @@ func handleZoomMeetingUpdate(ctx context.Context, key string, v1Data map[string]
 		if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to store meeting mapping")
 		}
+		if indexChanged {
+			if err := putMeetingFingerprint(ctx, meetingID, fingerprint); err != nil {
+				funcLogger.With(errKey, err).WarnContext(ctx, "failed to store meeting fingerprint")
+			}
+		}
+		if storeICSState {
+			if err := putMeetingICSState(ctx, meetingID, icsState); err != nil {
+				funcLogger.With(errKey, err).WarnContext(ctx, "failed to store meeting ICS UID state")
+			}
+		}
+		if storeCancelledOccurrences {
+			if err := putMeetingCancelledOccurrences(ctx, meetingID, cancelledOccurrences); err != nil {
+				funcLogger.With(errKey, err).WarnContext(ctx, "failed to store meeting cancelled occurrences")
+			}
+		}
+		updateMeetingSnapshot(ctx, meeting)
 	}
 
-	funcLogger.InfoContext(ctx, "successfully sent meeting indexer and access messages")
+	funcLogger.With("index_changed", indexChanged).InfoContext(ctx, "successfully sent meeting indexer and access messages")
 }
 
 // meetingDeleteConfig holds the configuration for deleting a meeting-related resource.
@@ -408,7 +528,7 @@ func handleMeetingTypeDelete(ctx context.Context, key, id string, message []byte
 	}
 
 	if cfg.deleteAllAccessSubject != "" {
-		if err := sendAccessMessage(cfg.deleteAllAccessSubject, message); err != nil {
+		if err := sendAccessMessage(ctx, cfg.deleteAllAccessSubject, message); err != nil {
 			funcLogger.With(errKey, err, "subject", cfg.deleteAllAccessSubject).ErrorContext(ctx, "failed to send delete-all-access message")
 			return true
 		}
@@ -440,7 +560,7 @@ func handleZoomMeetingDelete(ctx context.Context, key string, meetingID string)
 	return handleMeetingTypeDelete(ctx, key, meetingID, []byte(meetingID), meetingDeleteConfig{
 		indexerSubject:         IndexV1MeetingSubject,
 		deleteAllAccessSubject: DeleteAllAccessV1MeetingSubject,
-		tombstoneKeyFmts:       []string{"v1_meetings.%s", "v1-mappings.meeting-mappings.%s"},
+		tombstoneKeyFmts:       []string{"v1_meetings.%s", "v1-mappings.meeting-mappings.%s", meetingFingerprintKeyFmt, meetingSnapshotKeyFmt, meetingCancelledOccurrencesKeyFmt},
 	})
 }
 
@@ -471,9 +591,10 @@ func handleZoomMeetingRegistrantDelete(ctx context.Context, key string, registra
 	}
 	funcLogger = funcLogger.With("meeting_id", meetingID)
 
-	// Extract username and host fields.
+	// Extract username, host, and committee fields.
 	username, _ := v1Data["username"].(string)
 	host, _ := v1Data["host"].(bool)
+	committeeUID, _ := v1Data["committee_id"].(string)
 
 	var message []byte
 	var deleteAllAccessSubject string
@@ -481,13 +602,22 @@ func handleZoomMeetingRegistrantDelete(ctx context.Context, key string, registra
 	// Only construct and send the access message if username is present, consistent with update handler.
 	// Without a username, access control cannot identify which user to remove access for.
 	if username != "" {
+		// Advance the host state, so the removal is ordered after any earlier
+		// access messages of the registrant.
+		_, hostState, _, err := advanceRegistrantHostState(ctx, registrantID, mapUsernameToAuthSub(username), "", false)
+		if err != nil {
+			funcLogger.With(errKey, err).WarnContext(ctx, "failed to update registrant host state, will retry")
+			return true
+		}
+
 		accessMsg := MeetingRegistrantAccessMessage{
-			ID:        registrantID,
-			MeetingID: meetingID,
-			Username:  mapUsernameToAuthSub(username),
-			Host:      host,
+			ID:           registrantID,
+			MeetingID:    meetingID,
+			Username:     mapUsernameToAuthSub(username),
+			Host:         host,
+			CommitteeUID: committeeUID,
+			Sequence:     hostState.Sequence,
 		}
-		var err error
 		if message, err = json.Marshal(accessMsg); err != nil {
 			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal registrant access message")
 			return false
@@ -500,6 +630,10 @@ func handleZoomMeetingRegistrantDelete(ctx context.Context, key string, registra
 		deleteAllAccessSubject = "" // Empty string skips access control message
 	}
 
+	if err := updateMeetingRegistrantIndex(ctx, meetingID, key, true); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to remove registrant from meeting registrant index")
+	}
+
 	return handleMeetingTypeDelete(ctx, key, registrantID, message, meetingDeleteConfig{
 		indexerSubject:         IndexV1MeetingRegistrantSubject,
 		deleteAllAccessSubject: deleteAllAccessSubject,
@@ -508,17 +642,11 @@ func handleZoomMeetingRegistrantDelete(ctx context.Context, key string, registra
 }
 
 // convertMapToInputMeeting converts a map[string]any to an InputMeeting struct.
-func convertMapToInputMeetingMapping(v1Data map[string]any) (*ZoomMeetingMappingDB, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for meeting mapping: %w", err)
-	}
-
-	// Unmarshal JSON bytes into ZoomMeetingMappingDB struct
+func convertMapToInputMeetingMapping(ctx context.Context, v1Data map[string]any) (*ZoomMeetingMappingDB, error) {
+	// Decode the record into the ZoomMeetingMappingDB struct.
 	var mapping ZoomMeetingMappingDB
-	if err := json.Unmarshal(jsonBytes, &mapping); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into ZoomMeetingMappingDB: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into ZoomMeetingMappingDB: %w", err)
 	}
 
 	return &mapping, nil
@@ -542,12 +670,17 @@ func handleZoomMeetingMappingUpdate(ctx context.Context, key string, v1Data map[
 	funcLogger := logger.With("key", key)
 	funcLogger.DebugContext(ctx, "processing zoom meeting mapping update")
 
-	mapping, err := convertMapToInputMeetingMapping(v1Data)
+	mapping, err := convertMapToInputMeetingMapping(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to ZoomMeetingMappingDB")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, mapping); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	meetingID := mapping.MeetingID
 	if meetingID == "" {
 		funcLogger.ErrorContext(ctx, "missing meeting_id in mapping data")
@@ -561,23 +694,55 @@ func handleZoomMeetingMappingUpdate(ctx context.Context, key string, v1Data map[
 		return false
 	}
 
+	// Coalesce the mappings of a meeting loaded in bulk into one index write
+	// and one re-index; the message is settled once its batch is flushed.
+	if meetingMappingBatches.add(ctx, mapping) {
+		funcLogger.With("committee_id", committeeID).DebugContext(ctx, "batched meeting mapping update")
+		return false
+	}
+
+	meeting, retry := getMappedMeeting(ctx, funcLogger, meetingID)
+	if meeting == nil {
+		return retry
+	}
+
+	return applyMeetingMappings(ctx, meetingID, meeting, []*ZoomMeetingMappingDB{mapping})
+}
+
+// getMappedMeeting fetches the meeting of committee mappings. It returns nil
+// if the meeting is missing, out of scope, or invalid, with whether the
+// mapping should be retried.
+func getMappedMeeting(ctx context.Context, funcLogger *slog.Logger, meetingID string) (*meetingInput, bool) {
 	// Fetch meeting data outside the lock — it is read-only here.
 	meetingKey := fmt.Sprintf("itx-zoom-meetings-v2.%s", meetingID)
 	meetingData, exists, err := getV1ObjectData(ctx, meetingKey)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to get meeting data from KV bucket")
-		return false
+		return nil, false
 	}
 	if !exists {
 		funcLogger.WarnContext(ctx, "meeting data not found or deleted in KV bucket")
-		return true
+		return nil, true
+	}
+	if !isRecordInScope(ctx, meetingData) {
+		return nil, false
 	}
 
 	meeting, err := convertMapToInputMeeting(ctx, meetingData)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert meeting data")
-		return false
+		return nil, false
 	}
+	return meeting, false
+}
+
+// applyMeetingMappings upserts committee mappings of a meeting into its
+// committee-mappings index, then re-indexes the meeting with the complete
+// committee list, and recomputes the registrant access of the committees
+// whose filters changed.
+// Returns true if the operation should be retried, false otherwise.
+func applyMeetingMappings(ctx context.Context, meetingID string, meeting *meetingInput, mappings []*ZoomMeetingMappingDB) bool {
+	funcLogger := logger.With("meeting_id", meetingID, "mappings", len(mappings))
 
 	// Acquire a per-meeting distributed lock to serialise the read-modify-write
 	// on the committee-mappings index. The lock is released before sending
@@ -607,11 +772,26 @@ func handleZoomMeetingMappingUpdate(ctx context.Context, key string, v1Data map[
 		}
 	}
 
-	// Upsert this committee into the index so the outgoing messages always
-	// carry the complete, up-to-date committee list (including the new entry).
-	committeeMappings[mapping.ID] = mappingCommittee{
-		CommitteeID:      committeeID,
-		CommitteeFilters: mapping.CommitteeFilters,
+	// Keep the previous filters of existing mappings, to recompute the access
+	// of the committee registrants when they change.
+	var filterChanges []committeeFilterChange
+	for _, mapping := range mappings {
+		previousMapping, mappingExisted := committeeMappings[mapping.ID]
+		if mappingExisted && previousMapping.CommitteeID == mapping.CommitteeID &&
+			committeeFiltersChanged(previousMapping.CommitteeFilters, mapping.CommitteeFilters) {
+			filterChanges = append(filterChanges, committeeFilterChange{
+				committeeID: mapping.CommitteeID,
+				previous:    previousMapping.CommitteeFilters,
+				current:     mapping.CommitteeFilters,
+			})
+		}
+
+		// Upsert the committee into the index so the outgoing messages always
+		// carry the complete, up-to-date committee list (including new entries).
+		committeeMappings[mapping.ID] = mappingCommittee{
+			CommitteeID:      mapping.CommitteeID,
+			CommitteeFilters: mapping.CommitteeFilters,
+		}
 	}
 
 	// Persist the updated index and the mapping marker.
@@ -629,6 +809,13 @@ func handleZoomMeetingMappingUpdate(ctx context.Context, key string, v1Data map[
 	if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store meeting mapping marker")
 	}
+	// Record the parent meeting of the mappings so that a hard delete (which
+	// carries no record data) can still locate the committee index to update.
+	for _, mapping := range mappings {
+		if _, err := mappingsKV.Put(ctx, fmt.Sprintf("v1_meeting_mappings.%s", mapping.ID), []byte(meetingID)); err != nil {
+			funcLogger.With(errKey, err, "mapping_id", mapping.ID).WarnContext(ctx, "failed to store meeting mapping parent")
+		}
+	}
 
 	// Release the lock before sending messages to minimise hold time.
 	if err := distributedSync.release(ctx, lockKey); err != nil {
@@ -642,10 +829,11 @@ func handleZoomMeetingMappingUpdate(ctx context.Context, key string, v1Data map[
 		committees = append(committees, committee.CommitteeID)
 		meeting.Committees = append(meeting.Committees, Committee{
 			UID:                   committee.CommitteeID,
-			AllowedVotingStatuses: committee.CommitteeFilters,
+			AllowedVotingStatuses: normalizeCommitteeFilters(committee.CommitteeFilters),
 		})
 	}
 
+	meeting.CanonicalMeetingType = classifyMeetingType(ctx, meeting.MeetingType, meeting.Title, committees)
 	tags := getMeetingTags(meeting)
 	if err := sendIndexerMessage(ctx, IndexV1MeetingSubject, indexerAction, meeting, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting indexer message")
@@ -664,33 +852,63 @@ func handleZoomMeetingMappingUpdate(ctx context.Context, key string, v1Data map[
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal access message")
 		return false
 	}
-	if err := sendAccessMessage(UpdateAccessV1MeetingSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, UpdateAccessV1MeetingSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting access message")
 		return false
 	}
 
-	funcLogger.With("committee_id", committeeID).InfoContext(ctx, "successfully triggered meeting re-index with updated committees")
+	// The index now holds the new filters, so a retry would not see the
+	// change: recompute failures are logged rather than retried.
+	for _, change := range filterChanges {
+		if err := recomputeCommitteeRegistrantAccess(ctx, meetingID, change.committeeID, change.previous, change.current); err != nil {
+			funcLogger.With(errKey, err, "committee_id", change.committeeID).ErrorContext(ctx, "failed to recompute registrant access after committee filter change")
+		}
+	}
+
+	funcLogger.InfoContext(ctx, "successfully triggered meeting re-index with updated committees")
 	return false
 }
 
+// committeeFilterChange is a change of the filters of a committee mapping.
+type committeeFilterChange struct {
+	committeeID string
+	previous    []string
+	current     []string
+}
+
 // handleZoomMeetingMappingDelete processes a deletion of an itx-zoom-meetings-mappings-v2 record.
 // It removes the deleted committee from the meeting's committee index and re-indexes the meeting.
+// v1Data may be nil for hard deletes, in which case the parent meeting is resolved
+// from the v1_meeting_mappings.{mappingID} entry stored by the update handler.
 // Returns true if the operation should be retried, false otherwise.
 func handleZoomMeetingMappingDelete(ctx context.Context, key string, mappingID string, v1Data map[string]any) bool {
 	funcLogger := logger.With("key", key, "mapping_id", mappingID)
 
-	if v1Data == nil {
-		funcLogger.WarnContext(ctx, "no v1Data available for meeting mapping delete, skipping")
+	// Skip if already tombstoned — prevents double processing when the DynamoDB path
+	// has already handled the delete before the KV watcher fires.
+	parentKey := fmt.Sprintf("v1_meeting_mappings.%s", mappingID)
+	parentEntry, parentErr := mappingsKV.Get(ctx, parentKey)
+	if parentErr == nil && isTombstonedMapping(parentEntry.Value()) {
+		funcLogger.DebugContext(ctx, "meeting mapping delete already processed, skipping")
 		return false
 	}
 
-	meetingID, ok := v1Data["meeting_id"].(string)
-	if !ok || meetingID == "" {
-		funcLogger.ErrorContext(ctx, "missing or invalid meeting_id in v1Data for meeting mapping delete")
+	// Resolve the parent meeting from the record data, falling back to the
+	// mapping parent stored on update (hard deletes carry no record data).
+	meetingID, _ := v1Data["meeting_id"].(string)
+	if meetingID == "" && parentErr == nil {
+		meetingID = string(parentEntry.Value())
+	}
+	if meetingID == "" {
+		funcLogger.WarnContext(ctx, "cannot resolve meeting_id for meeting mapping delete, skipping")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_id", meetingID)
 
+	// Drop a pending batched update of this mapping, so its flush does not
+	// add the deleted committee back.
+	meetingMappingBatches.discard(meetingID, mappingID)
+
 	// Acquire a per-meeting distributed lock to serialise concurrent
 	// read-modify-write operations on the committee-mappings index.
 	lockKey := meetingMappingLockKeyPrefix + meetingID
@@ -724,6 +942,9 @@ func handleZoomMeetingMappingDelete(ctx context.Context, key string, mappingID s
 	if _, err := mappingsKV.Put(ctx, indexKey, committeeMappingsBytes); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store updated committee mappings")
 	}
+	if err := tombstoneMapping(ctx, parentKey); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone meeting mapping parent")
+	}
 
 	// Fetch the meeting and re-index with the remaining committees.
 	meetingKey := fmt.Sprintf("itx-zoom-meetings-v2.%s", meetingID)
@@ -749,10 +970,11 @@ func handleZoomMeetingMappingDelete(ctx context.Context, key string, mappingID s
 		committees = append(committees, committee.CommitteeID)
 		meeting.Committees = append(meeting.Committees, Committee{
 			UID:                   committee.CommitteeID,
-			AllowedVotingStatuses: committee.CommitteeFilters,
+			AllowedVotingStatuses: normalizeCommitteeFilters(committee.CommitteeFilters),
 		})
 	}
 
+	meeting.CanonicalMeetingType = classifyMeetingType(ctx, meeting.MeetingType, meeting.Title, committees)
 	tags := getMeetingTags(meeting)
 	if err := sendIndexerMessage(ctx, IndexV1MeetingSubject, MessageActionUpdated, meeting, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting indexer message")
@@ -771,7 +993,7 @@ func handleZoomMeetingMappingDelete(ctx context.Context, key string, mappingID s
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal access message")
 		return false
 	}
-	if err := sendAccessMessage(UpdateAccessV1MeetingSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, UpdateAccessV1MeetingSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send meeting access message")
 		return false
 	}
@@ -781,17 +1003,11 @@ func handleZoomMeetingMappingDelete(ctx context.Context, key string, mappingID s
 }
 
 // convertMapToInputRegistrant converts a map[string]any to a RegistrantInput struct.
-func convertMapToInputRegistrant(v1Data map[string]any) (*registrantInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for registrant: %w", err)
-	}
-
-	// Unmarshal JSON bytes into RegistrantInput struct
+func convertMapToInputRegistrant(ctx context.Context, v1Data map[string]any) (*registrantInput, error) {
+	// Decode the record into the RegistrantInput struct.
 	var registrant registrantInput
-	if err := json.Unmarshal(jsonBytes, &registrant); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into registrantInput: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &registrant); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into registrantInput: %w", err)
 	}
 
 	if registrantID, ok := v1Data["registrant_id"].(string); ok && registrantID != "" {
@@ -828,6 +1044,11 @@ type MeetingRegistrantAccessMessage struct {
 	MeetingID string `json:"meeting_id"`
 	Username  string `json:"username"`
 	Host      bool   `json:"host"`
+	// CommitteeUID is the committee the registrant has access through, if any.
+	CommitteeUID string `json:"committee_uid,omitempty"`
+	// Sequence orders the access messages of a registrant, so older messages
+	// can be discarded.
+	Sequence uint64 `json:"sequence,omitempty"`
 }
 
 func getRegistrantTags(registrant *registrantInput) []string {
@@ -859,12 +1080,17 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 	funcLogger.DebugContext(ctx, "processing zoom meeting registrant update")
 
 	// Convert v1Data map to RegistrantInput struct
-	registrant, err := convertMapToInputRegistrant(v1Data)
+	registrant, err := convertMapToInputRegistrant(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to registrantInput")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, registrant); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the registrant ID
 	registrantID := registrant.UID
 	if registrantID == "" {
@@ -873,6 +1099,11 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 	}
 	funcLogger = funcLogger.With("registrant_id", registrantID)
 
+	// Registrants of a merged v1 user follow the surviving account.
+	if username := mergedV1Username(ctx, registrant.UserID); username != "" {
+		registrant.Username = username
+	}
+
 	// If username is blank but we have a v1 Platform ID (user_id), lookup the username.
 	if registrant.Username == "" && registrant.UserID != "" {
 		if v1User, lookupErr := lookupV1User(ctx, registrant.UserID); lookupErr == nil && v1User != nil && v1User.Username != "" {
@@ -885,17 +1116,13 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 		}
 	}
 
-	// Check if parent meeting exists in mappings before proceeding.
+	// The parent meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if registrant.MeetingID == "" {
 		funcLogger.ErrorContext(ctx, "meeting registrant missing required parent meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_id", registrant.MeetingID)
-	meetingMappingKey := fmt.Sprintf("v1_meetings.%s", registrant.MeetingID)
-	if _, err := mappingsKV.Get(ctx, meetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent meeting not found in mappings, will retry meeting registrant sync")
-		return true // Retry - meeting might be stored shortly
-	}
 
 	mappingKey := fmt.Sprintf("v1_meeting_registrants.%s", registrantID)
 	indexerAction := MessageActionCreated
@@ -903,6 +1130,8 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 		indexerAction = MessageActionUpdated
 	}
 
+	registrant.Meeting = enrichmentMeetingSnapshot(ctx, registrant.MeetingID)
+
 	tags := getRegistrantTags(registrant)
 	if err := sendIndexerMessage(ctx, IndexV1MeetingRegistrantSubject, indexerAction, registrant, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send registrant indexer message")
@@ -913,11 +1142,45 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 	if registrant.Username != "" {
 		// Map username to Auth0 "sub" format for v2 compatibility.
 		authSub := mapUsernameToAuthSub(registrant.Username)
+
+		// Record the host state being sent, to detect host flips.
+		previousHostState, hostState, hostStateFound, err := advanceRegistrantHostState(ctx, registrantID, authSub, registrant.CommitteeUID, *registrant.Host)
+		if err != nil {
+			funcLogger.With(errKey, err).WarnContext(ctx, "failed to update registrant host state, will retry")
+			return true
+		}
+
+		// A committee reassignment of the same user removes the previous
+		// committee relationship before the new one is put, with the
+		// sequence number reserved for it.
+		if hostStateFound && committeeReassigned(previousHostState, hostState) {
+			removeMsgBytes, err := json.Marshal(MeetingRegistrantAccessMessage{
+				ID:           registrantID,
+				MeetingID:    registrant.MeetingID,
+				Username:     authSub,
+				Host:         previousHostState.Host,
+				CommitteeUID: previousHostState.CommitteeUID,
+				Sequence:     hostState.Sequence - 1,
+			})
+			if err != nil {
+				funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal registrant remove message")
+				return false
+			}
+			if err := sendAccessMessage(ctx, V1MeetingRegistrantRemoveSubject, removeMsgBytes); err != nil {
+				funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send previous committee remove message")
+				return false
+			}
+			funcLogger.With("previous_committee_uid", previousHostState.CommitteeUID, "committee_uid", registrant.CommitteeUID).
+				InfoContext(ctx, "removed registrant access through previous committee")
+		}
+
 		accessMsg := MeetingRegistrantAccessMessage{
-			ID:        registrantID,
-			MeetingID: registrant.MeetingID,
-			Username:  authSub,
-			Host:      *registrant.Host,
+			ID:           registrantID,
+			MeetingID:    registrant.MeetingID,
+			Username:     authSub,
+			Host:         *registrant.Host,
+			CommitteeUID: registrant.CommitteeUID,
+			Sequence:     hostState.Sequence,
 		}
 
 		accessMsgBytes, err := json.Marshal(accessMsg)
@@ -926,10 +1189,35 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 			return false
 		}
 
-		if err := sendAccessMessage(V1MeetingRegistrantPutSubject, accessMsgBytes); err != nil {
+		if err := sendAccessMessage(ctx, V1MeetingRegistrantPutSubject, accessMsgBytes); err != nil {
 			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send registrant put message")
 			return false
 		}
+
+		if err := sendRegistrantHostChanges(ctx, registrantID, registrant.MeetingID, previousHostState, hostState, hostStateFound); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send registrant host change message")
+			return false
+		}
+
+		// A username change (e.g. a merged v1 user) removes the previous user's access.
+		if hostStateFound && previousHostState.Username != "" && previousHostState.Username != authSub {
+			removeMsgBytes, err := json.Marshal(MeetingRegistrantAccessMessage{
+				ID:           registrantID,
+				MeetingID:    registrant.MeetingID,
+				Username:     previousHostState.Username,
+				Host:         previousHostState.Host,
+				CommitteeUID: previousHostState.CommitteeUID,
+				Sequence:     hostState.Sequence,
+			})
+			if err != nil {
+				funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal registrant remove message")
+				return false
+			}
+			if err := sendAccessMessage(ctx, V1MeetingRegistrantRemoveSubject, removeMsgBytes); err != nil {
+				funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send previous registrant user remove message")
+				return false
+			}
+		}
 	}
 
 	if registrantID != "" {
@@ -938,21 +1226,20 @@ func handleZoomMeetingRegistrantUpdate(ctx context.Context, key string, v1Data m
 		}
 	}
 
+	if err := updateMeetingRegistrantIndex(ctx, registrant.MeetingID, key, false); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to update meeting registrant index")
+	}
+	recordV1UserReference(ctx, registrant.UserID, key)
+
 	funcLogger.InfoContext(ctx, "successfully sent registrant indexer and put messages")
 	return false
 }
 
-func convertMapToInputInviteResponse(v1Data map[string]any) (*inviteResponseInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for invite response: %w", err)
-	}
-
-	// Unmarshal JSON bytes into InviteResponseInput struct
+func convertMapToInputInviteResponse(ctx context.Context, v1Data map[string]any) (*inviteResponseInput, error) {
+	// Decode the record into the InviteResponseInput struct.
 	var inviteResponse inviteResponseInput
-	if err := json.Unmarshal(jsonBytes, &inviteResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into inviteResponseInput: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &inviteResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into inviteResponseInput: %w", err)
 	}
 
 	// Convert the v1 response type to the v2 response type.
@@ -1001,6 +1288,31 @@ func getInviteResponseTags(inviteResponse *inviteResponseInput) []string {
 	return tags
 }
 
+// RSVPChangedMessage is the schema of the event sent to the meeting service
+// when an invite response changes, so it can recompute the RSVP counts of the
+// meeting (or occurrence) without consuming the indexer messages.
+type RSVPChangedMessage struct {
+	MeetingUID   string    `json:"meeting_uid"`
+	OccurrenceID string    `json:"occurrence_id,omitempty"`
+	Scope        RSVPScope `json:"scope,omitempty"`
+}
+
+// inviteResponseRSVPKeyFmt is the mappings key format of the last RSVP
+// changed event of an invite response, sent again when it is deleted.
+const inviteResponseRSVPKeyFmt = "v1_invite_response_rsvps.%s"
+
+// sendRSVPChangedEvent sends an RSVP changed event.
+func sendRSVPChangedEvent(ctx context.Context, event RSVPChangedMessage) error {
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal RSVP changed event: %w", err)
+	}
+	if err := publishMessage(ctx, V1MeetingRSVPChangedSubject, eventBytes); err != nil {
+		return fmt.Errorf("failed to publish RSVP changed event: %w", err)
+	}
+	return nil
+}
+
 // handleZoomMeetingInviteResponseDelete processes a deletion of an itx-zoom-meetings-invite-responses-v2 record.
 // Returns true if the operation should be retried, false otherwise.
 func handleZoomMeetingInviteResponseDelete(ctx context.Context, key string, inviteResponseID string) bool {
@@ -1014,10 +1326,31 @@ func handleZoomMeetingInviteResponseDelete(ctx context.Context, key string, invi
 		return false
 	}
 
-	return handleMeetingTypeDelete(ctx, key, inviteResponseID, []byte(inviteResponseID), meetingDeleteConfig{
+	// Read the meeting of the invite response before its keys are
+	// tombstoned, to notify the meeting service of the change.
+	var event RSVPChangedMessage
+	eventFound := false
+	if entry, err := mappingsKV.Get(ctx, fmt.Sprintf(inviteResponseRSVPKeyFmt, inviteResponseID)); err == nil && !isTombstonedMapping(entry.Value()) {
+		eventFound = json.Unmarshal(entry.Value(), &event) == nil && event.MeetingUID != ""
+	}
+
+	if retry := handleMeetingTypeDelete(ctx, key, inviteResponseID, []byte(inviteResponseID), meetingDeleteConfig{
 		indexerSubject:   IndexV1MeetingInviteResponseSubject,
-		tombstoneKeyFmts: []string{"v1_invite_responses.%s"},
-	})
+		tombstoneKeyFmts: []string{"v1_invite_responses.%s", inviteResponseRSVPKeyFmt},
+	}); retry {
+		return true
+	}
+
+	// The delete is already recorded, so a failed event is not retried.
+	if eventFound {
+		if err := sendRSVPChangedEvent(ctx, event); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send RSVP changed event for deleted invite response")
+		}
+		if _, err := removeKeyIndexMember(ctx, meetingInviteResponseIndexPrefix(event.MeetingUID), key); err != nil {
+			funcLogger.With(errKey, err).WarnContext(ctx, "failed to remove invite response from meeting snapshot refresh index")
+		}
+	}
+	return false
 }
 
 // handleZoomMeetingInviteResponseUpdate processes a zoom meeting invite response update from itx-zoom-meetings-invite-responses-v2 records.
@@ -1033,12 +1366,17 @@ func handleZoomMeetingInviteResponseUpdate(ctx context.Context, key string, v1Da
 	funcLogger.DebugContext(ctx, "processing zoom meeting invite response update")
 
 	// Convert v1Data map to InviteResponseInput struct
-	inviteResponse, err := convertMapToInputInviteResponse(v1Data)
+	inviteResponse, err := convertMapToInputInviteResponse(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to inviteResponseInput")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, inviteResponse); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Skip sync for Mailer Daemon email addresses.
 	if inviteResponse.Email == "MAILER-DAEMON@us-west-2.amazonses.com" {
 		return false
@@ -1065,17 +1403,13 @@ func handleZoomMeetingInviteResponseUpdate(ctx context.Context, key string, v1Da
 
 	funcLogger = funcLogger.With("invite_response_id", inviteResponseID)
 
-	// Check if parent meeting exists in mappings before proceeding.
+	// The parent meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if inviteResponse.MeetingID == "" {
 		funcLogger.ErrorContext(ctx, "invite response missing required parent meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_id", inviteResponse.MeetingID)
-	meetingMappingKey := fmt.Sprintf("v1_meetings.%s", inviteResponse.MeetingID)
-	if _, err := mappingsKV.Get(ctx, meetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent meeting not found in mappings, will retry invite response sync")
-		return true
-	}
 
 	mappingKey := fmt.Sprintf("v1_invite_responses.%s", inviteResponseID)
 	indexerAction := MessageActionCreated
@@ -1083,17 +1417,45 @@ func handleZoomMeetingInviteResponseUpdate(ctx context.Context, key string, v1Da
 		indexerAction = MessageActionUpdated
 	}
 
+	// Link invite responses without a registrant ID to the meeting registrant
+	// with the same email.
+	resolveInviteResponseRegistrant(ctx, inviteResponse)
+
+	inviteResponse.Meeting = enrichmentMeetingSnapshot(ctx, inviteResponse.MeetingID)
+
 	tags := getInviteResponseTags(inviteResponse)
 	if err := sendIndexerMessage(ctx, IndexV1MeetingInviteResponseSubject, indexerAction, inviteResponse, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send invite response indexer message")
 		return false
 	}
 
+	// Notify the meeting service, so it can recompute the meeting's RSVP
+	// counts.
+	event := RSVPChangedMessage{
+		MeetingUID:   inviteResponse.MeetingID,
+		OccurrenceID: inviteResponse.OccurrenceID,
+		Scope:        inviteResponse.Scope,
+	}
+	if err := sendRSVPChangedEvent(ctx, event); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send RSVP changed event")
+		return true
+	}
+
 	if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store invite response mapping")
 	}
+	if eventBytes, err := json.Marshal(event); err == nil {
+		if _, err := mappingsKV.Put(ctx, fmt.Sprintf(inviteResponseRSVPKeyFmt, inviteResponseID), eventBytes); err != nil {
+			funcLogger.With(errKey, err).WarnContext(ctx, "failed to store invite response RSVP event")
+		}
+	}
+	if cfg.MeetingSnapshotEnrichment {
+		if _, err := addKeyIndexMember(ctx, meetingInviteResponseIndexPrefix(inviteResponse.MeetingID), key); err != nil {
+			funcLogger.With(errKey, err).WarnContext(ctx, "failed to index invite response for meeting snapshot refreshes")
+		}
+	}
 
-	funcLogger.InfoContext(ctx, "successfully sent invite response indexer message")
+	funcLogger.InfoContext(ctx, "successfully sent invite response indexer message and RSVP changed event")
 	return false
 }
 
@@ -1110,16 +1472,16 @@ type PastMeetingAccessMessage struct {
 
 // convertMapToInputPastMeeting converts a map[string]any to a PastMeetingInput struct.
 func convertMapToInputPastMeeting(ctx context.Context, v1Data map[string]any) (*pastMeetingInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting: %w", err)
+	// Decode the record into the PastMeetingInput struct.
+	var pastMeeting pastMeetingInput
+	if err := decodeV1Record(ctx, v1Data, &pastMeeting); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into pastMeetingInput: %w", err)
 	}
 
-	// Unmarshal JSON bytes into PastMeetingInput struct
-	var pastMeeting pastMeetingInput
-	if err := json.Unmarshal(jsonBytes, &pastMeeting); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into pastMeetingInput: %w", err)
+	// Committees decoded from the record list their allowed voting statuses
+	// explicitly, like those built from the mapping index.
+	for i := range pastMeeting.Committees {
+		pastMeeting.Committees[i].AllowedVotingStatuses = normalizeCommitteeFilters(pastMeeting.Committees[i].AllowedVotingStatuses)
 	}
 
 	// We need to populate the ID for the v2 system
@@ -1140,9 +1502,13 @@ func convertMapToInputPastMeeting(ctx context.Context, v1Data map[string]any) (*
 	}
 
 	// Take the v1 project salesforce ID and look up the v2 project UID.
-	projectMappingKey := fmt.Sprintf("project.sfid.%s", pastMeeting.ProjectSFID)
-	if entry, err := mappingsKV.Get(ctx, projectMappingKey); err == nil {
-		pastMeeting.ProjectUID = string(entry.Value())
+	if projectUID, err := projectUIDBySFID(ctx, pastMeeting.ProjectSFID); err == nil {
+		pastMeeting.ProjectUID = projectUID
+	}
+
+	// Past meetings without a visibility take that of their project.
+	if pastMeeting.Visibility == "" && pastMeeting.ProjectSFID != "" {
+		pastMeeting.Visibility = defaultMeetingVisibility(ctx, "itx-zoom-past-meetings", pastMeeting.ProjectSFID, pastMeeting.ProjectUID)
 	}
 
 	// Convert v1 named fields to v2 named fields.
@@ -1195,6 +1561,8 @@ func getPastMeetingTags(pastMeeting *pastMeetingInput) []string {
 		fmt.Sprintf("project_uid:%s", pastMeeting.ProjectUID),
 		fmt.Sprintf("occurrence_id:%s", pastMeeting.OccurrenceID),
 		fmt.Sprintf("title:%s", pastMeeting.Title),
+		fmt.Sprintf("meeting_type:%s", pastMeeting.MeetingType),
+		fmt.Sprintf("canonical_meeting_type:%s", pastMeeting.CanonicalMeetingType),
 	}
 	for _, committee := range pastMeeting.Committees {
 		tags = append(tags, fmt.Sprintf("committee_uid:%s", committee.UID))
@@ -1220,6 +1588,11 @@ func handleZoomPastMeetingUpdate(ctx context.Context, key string, v1Data map[str
 		return
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, pastMeeting); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return
+	}
+
 	// Extract the past meeting UID (MeetingAndOccurrenceID)
 	uid := pastMeeting.MeetingAndOccurrenceID
 	if uid == "" {
@@ -1228,17 +1601,13 @@ func handleZoomPastMeetingUpdate(ctx context.Context, key string, v1Data map[str
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", uid)
 
-	// Check if parent meeting exists in mappings before proceeding.
+	// The parent meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if pastMeeting.MeetingID == "" {
 		funcLogger.ErrorContext(ctx, "past meeting missing required parent meeting ID")
 		return
 	}
 	funcLogger = funcLogger.With("meeting_id", pastMeeting.MeetingID)
-	meetingMappingKey := fmt.Sprintf("v1_meetings.%s", pastMeeting.MeetingID)
-	if _, err := mappingsKV.Get(ctx, meetingMappingKey); err != nil {
-		funcLogger.InfoContext(ctx, "skipping past meeting sync - parent meeting not found in mappings")
-		return
-	}
 
 	mappingKey := fmt.Sprintf("v1_past_meetings.%s", uid)
 	indexerAction := MessageActionCreated
@@ -1246,6 +1615,8 @@ func handleZoomPastMeetingUpdate(ctx context.Context, key string, v1Data map[str
 		indexerAction = MessageActionUpdated
 	}
 
+	setPastMeetingParticipantCounts(ctx, pastMeeting)
+	pastMeeting.CanonicalMeetingType = classifyMeetingType(ctx, pastMeeting.MeetingType, pastMeeting.Title, meetingCommitteeIDs(pastMeeting.Committees))
 	tags := getPastMeetingTags(pastMeeting)
 	if err := sendIndexerMessage(ctx, IndexV1PastMeetingSubject, indexerAction, pastMeeting, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send past meeting indexer message")
@@ -1254,22 +1625,26 @@ func handleZoomPastMeetingUpdate(ctx context.Context, key string, v1Data map[str
 
 	// Try to get committee mappings from the index first
 	var committees []string
+	indexFound := false
 	committeeMappings := make(map[string]mappingCommittee)
 	indexKey := fmt.Sprintf("v1-mappings.past-meeting-mappings.%s", uid)
 	indexEntry, err := mappingsKV.Get(ctx, indexKey)
-	if err == nil && indexEntry != nil {
+	if err == nil && indexEntry != nil && !isTombstonedMapping(indexEntry.Value()) {
 		if err := json.Unmarshal(indexEntry.Value(), &committeeMappings); err != nil {
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to unmarshal past meeting mapping index")
 		} else {
-			// Extract committee IDs from the mappings
-			for committeeID := range committeeMappings {
-				committees = append(committees, committeeID)
+			// The index is keyed by mapping ID; extract the committee IDs from the values.
+			indexFound = true
+			for _, committee := range committeeMappings {
+				committees = append(committees, committee.CommitteeID)
 			}
 		}
 	}
 
-	// Fallback: Extract committees from v1Data if no mappings found
-	if len(committees) == 0 {
+	// Fallback: Extract committees from v1Data if no mapping index exists. An
+	// existing but empty index means all mappings were deleted, so the
+	// (possibly stale) committees on the past meeting record must not be used.
+	if !indexFound {
 		if committeesData, ok := v1Data["committees"].([]any); ok {
 			for _, c := range committeesData {
 				if committee, ok := c.(map[string]any); ok {
@@ -1295,7 +1670,7 @@ func handleZoomPastMeetingUpdate(ctx context.Context, key string, v1Data map[str
 		return
 	}
 
-	if err := sendAccessMessage(V1PastMeetingUpdateAccessSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingUpdateAccessSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send past meeting access message")
 		return
 	}
@@ -1330,17 +1705,11 @@ func handleZoomPastMeetingDelete(ctx context.Context, key string, meetingAndOccu
 }
 
 // convertMapToInputPastMeetingMapping converts a map[string]any to a ZoomPastMeetingMappingDB struct.
-func convertMapToInputPastMeetingMapping(v1Data map[string]any) (*ZoomPastMeetingMappingDB, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting mapping: %w", err)
-	}
-
-	// Unmarshal JSON bytes into ZoomPastMeetingMappingDB struct
+func convertMapToInputPastMeetingMapping(ctx context.Context, v1Data map[string]any) (*ZoomPastMeetingMappingDB, error) {
+	// Decode the record into the ZoomPastMeetingMappingDB struct.
 	var mapping ZoomPastMeetingMappingDB
-	if err := json.Unmarshal(jsonBytes, &mapping); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into ZoomPastMeetingMappingDB: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into ZoomPastMeetingMappingDB: %w", err)
 	}
 
 	return &mapping, nil
@@ -1359,12 +1728,17 @@ func handleZoomPastMeetingMappingUpdate(ctx context.Context, key string, v1Data
 
 	funcLogger.DebugContext(ctx, "processing zoom past meeting mapping update")
 
-	mapping, err := convertMapToInputPastMeetingMapping(v1Data)
+	mapping, err := convertMapToInputPastMeetingMapping(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to ZoomPastMeetingMappingDB")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, mapping); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	meetingAndOccurrenceID := mapping.MeetingAndOccurrenceID
 	if meetingAndOccurrenceID == "" {
 		funcLogger.ErrorContext(ctx, "missing meeting_and_occurrence_id in mapping data")
@@ -1389,6 +1763,9 @@ func handleZoomPastMeetingMappingUpdate(ctx context.Context, key string, v1Data
 		funcLogger.WarnContext(ctx, "past meeting data not found in KV bucket, will retry")
 		return true
 	}
+	if !isRecordInScope(ctx, pastMeetingData) {
+		return false
+	}
 
 	pastMeeting, err := convertMapToInputPastMeeting(ctx, pastMeetingData)
 	if err != nil {
@@ -1445,6 +1822,11 @@ func handleZoomPastMeetingMappingUpdate(ctx context.Context, key string, v1Data
 	if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting mapping marker")
 	}
+	// Record the parent past meeting of this mapping so that a hard delete
+	// (which carries no record data) can still locate the committee index.
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf("v1_past_meeting_mappings.%s", mapping.ID), []byte(meetingAndOccurrenceID)); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting mapping parent")
+	}
 
 	// Release the lock before sending messages to minimise hold time.
 	if err := distributedSync.release(ctx, lockKey); err != nil {
@@ -1458,10 +1840,12 @@ func handleZoomPastMeetingMappingUpdate(ctx context.Context, key string, v1Data
 		committees = append(committees, committee.CommitteeID)
 		pastMeeting.Committees = append(pastMeeting.Committees, Committee{
 			UID:                   committee.CommitteeID,
-			AllowedVotingStatuses: committee.CommitteeFilters,
+			AllowedVotingStatuses: normalizeCommitteeFilters(committee.CommitteeFilters),
 		})
 	}
 
+	setPastMeetingParticipantCounts(ctx, pastMeeting)
+	pastMeeting.CanonicalMeetingType = classifyMeetingType(ctx, pastMeeting.MeetingType, pastMeeting.Title, meetingCommitteeIDs(pastMeeting.Committees))
 	tags := getPastMeetingTags(pastMeeting)
 	if err := sendIndexerMessage(ctx, IndexV1PastMeetingSubject, indexerAction, pastMeeting, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send past meeting indexer message")
@@ -1480,7 +1864,7 @@ func handleZoomPastMeetingMappingUpdate(ctx context.Context, key string, v1Data
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal access message")
 		return false
 	}
-	if err := sendAccessMessage(V1PastMeetingUpdateAccessSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingUpdateAccessSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send past meeting access message")
 		return false
 	}
@@ -1491,18 +1875,29 @@ func handleZoomPastMeetingMappingUpdate(ctx context.Context, key string, v1Data
 
 // handleZoomPastMeetingMappingDelete processes a deletion of an itx-zoom-past-meetings-mappings record.
 // It removes the deleted committee from the past meeting's committee index and re-indexes the past meeting.
+// v1Data may be nil for hard deletes, in which case the parent past meeting is resolved
+// from the v1_past_meeting_mappings.{mappingID} entry stored by the update handler.
 // Returns true if the operation should be retried, false otherwise.
 func handleZoomPastMeetingMappingDelete(ctx context.Context, key string, mappingID string, v1Data map[string]any) bool {
 	funcLogger := logger.With("key", key, "mapping_id", mappingID)
 
-	if v1Data == nil {
-		funcLogger.WarnContext(ctx, "no v1Data available for past meeting mapping delete, skipping")
+	// Skip if already tombstoned — prevents double processing when the DynamoDB path
+	// has already handled the delete before the KV watcher fires.
+	parentKey := fmt.Sprintf("v1_past_meeting_mappings.%s", mappingID)
+	parentEntry, parentErr := mappingsKV.Get(ctx, parentKey)
+	if parentErr == nil && isTombstonedMapping(parentEntry.Value()) {
+		funcLogger.DebugContext(ctx, "past meeting mapping delete already processed, skipping")
 		return false
 	}
 
-	meetingAndOccurrenceID, ok := v1Data["meeting_and_occurrence_id"].(string)
-	if !ok || meetingAndOccurrenceID == "" {
-		funcLogger.ErrorContext(ctx, "missing or invalid meeting_and_occurrence_id in v1Data for past meeting mapping delete")
+	// Resolve the parent past meeting from the record data, falling back to the
+	// mapping parent stored on update (hard deletes carry no record data).
+	meetingAndOccurrenceID, _ := v1Data["meeting_and_occurrence_id"].(string)
+	if meetingAndOccurrenceID == "" && parentErr == nil {
+		meetingAndOccurrenceID = string(parentEntry.Value())
+	}
+	if meetingAndOccurrenceID == "" {
+		funcLogger.WarnContext(ctx, "cannot resolve meeting_and_occurrence_id for past meeting mapping delete, skipping")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", meetingAndOccurrenceID)
@@ -1540,6 +1935,9 @@ func handleZoomPastMeetingMappingDelete(ctx context.Context, key string, mapping
 	if _, err := mappingsKV.Put(ctx, indexKey, committeeMappingsBytes); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store updated committee mappings")
 	}
+	if err := tombstoneMapping(ctx, parentKey); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone past meeting mapping parent")
+	}
 
 	// Fetch the past meeting and re-index with the remaining committees.
 	pastMeetingKey := fmt.Sprintf("itx-zoom-past-meetings.%s", meetingAndOccurrenceID)
@@ -1565,10 +1963,12 @@ func handleZoomPastMeetingMappingDelete(ctx context.Context, key string, mapping
 		committees = append(committees, committee.CommitteeID)
 		pastMeeting.Committees = append(pastMeeting.Committees, Committee{
 			UID:                   committee.CommitteeID,
-			AllowedVotingStatuses: committee.CommitteeFilters,
+			AllowedVotingStatuses: normalizeCommitteeFilters(committee.CommitteeFilters),
 		})
 	}
 
+	setPastMeetingParticipantCounts(ctx, pastMeeting)
+	pastMeeting.CanonicalMeetingType = classifyMeetingType(ctx, pastMeeting.MeetingType, pastMeeting.Title, meetingCommitteeIDs(pastMeeting.Committees))
 	tags := getPastMeetingTags(pastMeeting)
 	if err := sendIndexerMessage(ctx, IndexV1PastMeetingSubject, MessageActionUpdated, pastMeeting, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send past meeting indexer message")
@@ -1587,7 +1987,7 @@ func handleZoomPastMeetingMappingDelete(ctx context.Context, key string, mapping
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal access message")
 		return false
 	}
-	if err := sendAccessMessage(V1PastMeetingUpdateAccessSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingUpdateAccessSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send past meeting access message")
 		return false
 	}
@@ -1607,17 +2007,11 @@ type PastMeetingParticipantAccessMessage struct {
 }
 
 // convertMapToInputPastMeetingInvitee converts a map[string]any to a ZoomPastMeetingInviteeDatabase struct.
-func convertMapToInputPastMeetingInvitee(v1Data map[string]any) (*pastMeetingInviteeInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting invitee: %w", err)
-	}
-
-	// Unmarshal JSON bytes into ZoomPastMeetingInviteeDatabase struct
+func convertMapToInputPastMeetingInvitee(ctx context.Context, v1Data map[string]any) (*pastMeetingInviteeInput, error) {
+	// Decode the record into the ZoomPastMeetingInviteeDatabase struct.
 	var invitee pastMeetingInviteeInput
-	if err := json.Unmarshal(jsonBytes, &invitee); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into ZoomPastMeetingInviteeDatabase: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &invitee); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into ZoomPastMeetingInviteeDatabase: %w", err)
 	}
 
 	if inviteeID, ok := v1Data["invitee_id"].(string); ok && inviteeID != "" {
@@ -1656,12 +2050,17 @@ func handleZoomPastMeetingInviteeUpdate(ctx context.Context, key string, v1Data
 	funcLogger.DebugContext(ctx, "processing zoom past meeting invitee update")
 
 	// Convert v1Data map to PastMeetingInviteeInput struct
-	invitee, err := convertMapToInputPastMeetingInvitee(v1Data)
+	invitee, err := convertMapToInputPastMeetingInvitee(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to PastMeetingInviteeInput")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, invitee); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the invitee ID
 	inviteeID := invitee.ID
 	if inviteeID == "" {
@@ -1670,17 +2069,13 @@ func handleZoomPastMeetingInviteeUpdate(ctx context.Context, key string, v1Data
 	}
 	funcLogger = funcLogger.With("invitee_id", inviteeID)
 
-	// Check if parent past meeting exists in mappings before proceeding.
+	// The parent past meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if invitee.MeetingAndOccurrenceID == "" {
 		funcLogger.ErrorContext(ctx, "past meeting invitee missing required parent past meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", invitee.MeetingAndOccurrenceID)
-	pastMeetingMappingKey := fmt.Sprintf("v1_past_meetings.%s", invitee.MeetingAndOccurrenceID)
-	if _, err := mappingsKV.Get(ctx, pastMeetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent past meeting not found in mappings, will retry past meeting invitee sync")
-		return true
-	}
 
 	// Determine if this invitee is a host by looking up their registrant record
 	isHost := false
@@ -1705,6 +2100,12 @@ func handleZoomPastMeetingInviteeUpdate(ctx context.Context, key string, v1Data
 		return false
 	}
 
+	// Participants of a merged v1 user follow the surviving account.
+	if username := mergedV1Username(ctx, invitee.LFUserID); username != "" {
+		v2Participant.Username = mapUsernameToAuthSub(username)
+		invitee.LFSSO = username
+	}
+
 	// If username is blank but we have a v1 Platform ID (lf_user_id), lookup the username.
 	if v2Participant.Username == "" && invitee.LFUserID != "" {
 		if v1User, lookupErr := lookupV1User(ctx, invitee.LFUserID); lookupErr == nil && v1User != nil && v1User.Username != "" {
@@ -1733,6 +2134,24 @@ func handleZoomPastMeetingInviteeUpdate(ctx context.Context, key string, v1Data
 		indexerAction = MessageActionUpdated
 	}
 
+	// A changed identity deletes the participant of the superseded one first.
+	identity := newParticipantIdentity(invitee.LFUserID, invitee.Email, invitee.LFSSO)
+	superseded, retry := supersedeParticipantIdentity(ctx, participantTypeInvitee, inviteeID, invitee.MeetingAndOccurrenceID, identity)
+	if retry {
+		return true
+	}
+	if superseded {
+		indexerAction = MessageActionCreated
+	}
+
+	// Merge the participant with the attendee record of the participant,
+	// whichever arrived first (see participant_state.go).
+	merge, retry := beginParticipantMerge(ctx, participantTypeInvitee, v2Participant, invitee.LFSSO)
+	if retry {
+		return true
+	}
+	defer merge.release(ctx)
+
 	tags := getPastMeetingParticipantTags(v2Participant)
 	if err := sendIndexerMessage(ctx, IndexV1PastMeetingParticipantSubject, indexerAction, v2Participant, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send invitee indexer message")
@@ -1746,7 +2165,7 @@ func handleZoomPastMeetingInviteeUpdate(ctx context.Context, key string, v1Data
 		accessMsg := PastMeetingParticipantAccessMessage{
 			MeetingAndOccurrenceID: invitee.MeetingAndOccurrenceID,
 			Username:               authSub,
-			Host:                   isHost,
+			Host:                   v2Participant.Host,
 			IsInvited:              true,
 			IsAttended:             v2Participant.IsAttended,
 		}
@@ -1757,15 +2176,25 @@ func handleZoomPastMeetingInviteeUpdate(ctx context.Context, key string, v1Data
 			return false
 		}
 
-		if err := sendAccessMessage(V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
+		if err := sendAccessMessage(ctx, V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
 			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send invitee access message")
 			return false
 		}
 	}
 
+	if merge.commit(ctx) {
+		return true
+	}
+
 	if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting invitee mapping")
 	}
+	if err := updatePastMeetingParticipantIndex(ctx, pastMeetingInvitees, invitee.MeetingAndOccurrenceID, inviteeID, false); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to update past meeting participant index")
+		return true
+	}
+	recordV1UserReference(ctx, invitee.LFUserID, key)
+	storeParticipantIdentity(ctx, participantTypeInvitee, inviteeID, identity)
 
 	// Store a cross-reference mapping keyed by meeting+username so the attendee delete handler
 	// can determine whether an invitee record still exists for this participant.
@@ -1795,7 +2224,7 @@ func convertInviteeToV2Participant(invitee *pastMeetingInviteeInput, isHost bool
 		Username:               mapUsernameToAuthSub(invitee.LFSSO),
 		IsInvited:              true,
 		IsAttended:             false,                  // may be overridden in the upsert handler if an attendee record already exists
-		Sessions:               []ParticipantSession{}, // TODO: we need to determine the sessions for the invitee from the attendee event
+		Sessions:               []ParticipantSession{}, // merged from the attendee record in the upsert handler, if any
 	}
 
 	if invitee.CreatedAt != "" {
@@ -1806,6 +2235,7 @@ func convertInviteeToV2Participant(invitee *pastMeetingInviteeInput, isHost bool
 				"invitee_id", invitee.ID,
 				"meeting_and_occurrence_id", invitee.MeetingAndOccurrenceID,
 			).Warn("failed to parse created_at for invitee")
+			pastMeetingParticipant.addSyncWarning("created_at", invitee.CreatedAt, err)
 		} else {
 			pastMeetingParticipant.CreatedAt = &createdAt
 		}
@@ -1819,6 +2249,7 @@ func convertInviteeToV2Participant(invitee *pastMeetingInviteeInput, isHost bool
 				"invitee_id", invitee.ID,
 				"meeting_and_occurrence_id", invitee.MeetingAndOccurrenceID,
 			).Warn("failed to parse modified_at for invitee")
+			pastMeetingParticipant.addSyncWarning("modified_at", invitee.ModifiedAt, err)
 		} else {
 			pastMeetingParticipant.UpdatedAt = &modifiedAt
 		}
@@ -1835,19 +2266,16 @@ func convertInviteeToV2Participant(invitee *pastMeetingInviteeInput, isHost bool
 }
 
 // convertMapToInputPastMeetingAttendee converts a map[string]any to a PastMeetingAttendeeInput struct.
-func convertMapToInputPastMeetingAttendee(v1Data map[string]any) (*pastMeetingAttendeeInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting attendee: %w", err)
-	}
-
-	// Unmarshal JSON bytes into PastMeetingAttendeeInput struct
+func convertMapToInputPastMeetingAttendee(ctx context.Context, v1Data map[string]any) (*pastMeetingAttendeeInput, error) {
+	// Decode the record into the PastMeetingAttendeeInput struct.
 	var attendee pastMeetingAttendeeInput
-	if err := json.Unmarshal(jsonBytes, &attendee); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into PastMeetingAttendeeInput: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &attendee); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into PastMeetingAttendeeInput: %w", err)
 	}
 
+	// The same session can be appended by several updates of the attendee.
+	attendee.Sessions = dedupeAttendeeSessions(attendee.Sessions)
+
 	return &attendee, nil
 }
 
@@ -1864,12 +2292,17 @@ func handleZoomPastMeetingAttendeeUpdate(ctx context.Context, key string, v1Data
 	funcLogger.DebugContext(ctx, "processing zoom past meeting attendee update")
 
 	// Convert v1Data map to PastMeetingAttendeeInput struct
-	attendee, err := convertMapToInputPastMeetingAttendee(v1Data)
+	attendee, err := convertMapToInputPastMeetingAttendee(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to PastMeetingAttendeeInput")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, attendee); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the attendee ID
 	attendeeID := attendee.ID
 	if attendeeID == "" {
@@ -1878,17 +2311,13 @@ func handleZoomPastMeetingAttendeeUpdate(ctx context.Context, key string, v1Data
 	}
 	funcLogger = funcLogger.With("attendee_id", attendeeID)
 
-	// Check if parent past meeting exists in mappings before proceeding.
+	// The parent past meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if attendee.MeetingAndOccurrenceID == "" {
 		funcLogger.ErrorContext(ctx, "past meeting attendee missing required parent past meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", attendee.MeetingAndOccurrenceID)
-	pastMeetingMappingKey := fmt.Sprintf("v1_past_meetings.%s", attendee.MeetingAndOccurrenceID)
-	if _, err := mappingsKV.Get(ctx, pastMeetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent past meeting not found in mappings, will retry past meeting attendee sync")
-		return true
-	}
 
 	// Determine if this attendee is a host by looking up their registrant record
 	isHost := false
@@ -1921,6 +2350,14 @@ func handleZoomPastMeetingAttendeeUpdate(ctx context.Context, key string, v1Data
 		return false
 	}
 
+	enrichAttendeeMatch(ctx, attendee, v2Participant)
+
+	// Participants of a merged v1 user follow the surviving account.
+	if username := mergedV1Username(ctx, attendee.LFUserID); username != "" {
+		v2Participant.Username = mapUsernameToAuthSub(username)
+		attendee.LFSSO = username
+	}
+
 	// If username is blank but we have a v1 Platform ID (lf_user_id), lookup the username.
 	if v2Participant.Username == "" && attendee.LFUserID != "" {
 		if v1User, lookupErr := lookupV1User(ctx, attendee.LFUserID); lookupErr == nil && v1User != nil && v1User.Username != "" {
@@ -1934,6 +2371,33 @@ func handleZoomPastMeetingAttendeeUpdate(ctx context.Context, key string, v1Data
 		}
 	}
 
+	// If an invitee record already exists for this participant, preserve is_invited=true so
+	// an attendee upsert doesn't reset the flag that the invitee handler already set.
+	if !v2Participant.IsInvited && attendee.LFSSO != "" {
+		inviteeXrefKey := fmt.Sprintf("v1_participant_by_meeting_user.invitee.%s.%s", attendee.MeetingAndOccurrenceID, attendee.LFSSO)
+		if entry, err := mappingsKV.Get(ctx, inviteeXrefKey); err == nil && !isTombstonedMapping(entry.Value()) {
+			v2Participant.IsInvited = true
+		}
+	}
+
+	// A changed identity deletes the participant of the superseded one first.
+	identity := newParticipantIdentity(attendee.LFUserID, attendee.Email, attendee.LFSSO)
+	superseded, retry := supersedeParticipantIdentity(ctx, participantTypeAttendee, attendeeID, attendee.MeetingAndOccurrenceID, identity)
+	if retry {
+		return true
+	}
+	if superseded {
+		indexerAction = MessageActionCreated
+	}
+
+	// Merge the participant with the invitee record of the participant,
+	// whichever arrived first (see participant_state.go).
+	merge, retry := beginParticipantMerge(ctx, participantTypeAttendee, v2Participant, attendee.LFSSO)
+	if retry {
+		return true
+	}
+	defer merge.release(ctx)
+
 	tags := getPastMeetingParticipantTags(v2Participant)
 	if err := sendIndexerMessage(ctx, IndexV1PastMeetingParticipantSubject, indexerAction, v2Participant, tags); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send attendee indexer message")
@@ -1947,8 +2411,8 @@ func handleZoomPastMeetingAttendeeUpdate(ctx context.Context, key string, v1Data
 		accessMsg := PastMeetingParticipantAccessMessage{
 			MeetingAndOccurrenceID: attendee.MeetingAndOccurrenceID,
 			Username:               authSub,
-			Host:                   isHost,
-			IsInvited:              isRegistrant,
+			Host:                   v2Participant.Host,
+			IsInvited:              v2Participant.IsInvited,
 			IsAttended:             true,
 		}
 
@@ -1958,16 +2422,26 @@ func handleZoomPastMeetingAttendeeUpdate(ctx context.Context, key string, v1Data
 			return false
 		}
 
-		if err := sendAccessMessage(V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
+		if err := sendAccessMessage(ctx, V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
 			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send attendee access message")
 			return false
 		}
 	}
 
+	if merge.commit(ctx) {
+		return true
+	}
+
 	if attendeeID != "" {
 		if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting attendee mapping")
 		}
+		if err := updatePastMeetingParticipantIndex(ctx, pastMeetingAttendees, attendee.MeetingAndOccurrenceID, attendeeID, false); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to update past meeting participant index")
+			return true
+		}
+		recordV1UserReference(ctx, attendee.LFUserID, key)
+		storeParticipantIdentity(ctx, participantTypeAttendee, attendeeID, identity)
 	}
 
 	// Store a cross-reference mapping keyed by meeting+username so the invitee delete handler
@@ -2010,6 +2484,13 @@ func handleZoomPastMeetingAttendeeDelete(ctx context.Context, key string, attend
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", meetingAndOccurrenceID)
 
+	// The attendee is removed from the participant count whether or not the
+	// participant remains in V2.
+	if err := updatePastMeetingParticipantIndex(ctx, pastMeetingAttendees, meetingAndOccurrenceID, attendeeID, true); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to update past meeting participant index")
+		return true
+	}
+
 	// Extract username (lf_sso) field.
 	username, _ := v1Data["lf_sso"].(string)
 
@@ -2052,7 +2533,7 @@ func handleZoomPastMeetingAttendeeDelete(ctx context.Context, key string, attend
 	result := handleMeetingTypeDelete(ctx, key, attendeeID, message, meetingDeleteConfig{
 		indexerSubject:         IndexV1PastMeetingParticipantSubject,
 		deleteAllAccessSubject: deleteAllAccessSubject,
-		tombstoneKeyFmts:       []string{"v1_past_meeting_attendees.%s"},
+		tombstoneKeyFmts:       []string{"v1_past_meeting_attendees.%s", "v1_participant_identity.attendee.%s"},
 	})
 	// On successful full delete, also tombstone the attendee cross-reference mapping.
 	if !result && username != "" {
@@ -2061,6 +2542,10 @@ func handleZoomPastMeetingAttendeeDelete(ctx context.Context, key string, attend
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone attendee cross-reference mapping")
 		}
 	}
+	if !result {
+		email, _ := v1Data["email"].(string)
+		removeParticipantState(ctx, participantTypeAttendee, meetingAndOccurrenceID, username, email)
+	}
 	return result
 }
 
@@ -2077,7 +2562,7 @@ func handleZoomPastMeetingPartialAttendeeDelete(ctx context.Context, funcLogger
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to fetch invitee data for partial attendee delete; skipping")
 		return false
 	}
-	invitee, err := convertMapToInputPastMeetingInvitee(inviteeData)
+	invitee, err := convertMapToInputPastMeetingInvitee(ctx, inviteeData)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert invitee data for partial attendee delete")
 		return false
@@ -2123,7 +2608,7 @@ func handleZoomPastMeetingPartialAttendeeDelete(ctx context.Context, funcLogger
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal partial attendee delete access message")
 		return false
 	}
-	if err := sendAccessMessage(V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send partial attendee delete access update")
 		return true
 	}
@@ -2132,10 +2617,14 @@ func handleZoomPastMeetingPartialAttendeeDelete(ctx context.Context, funcLogger
 	if err := tombstoneMapping(ctx, fmt.Sprintf("v1_past_meeting_attendees.%s", attendeeID)); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone attendee mapping in partial delete")
 	}
+	if err := tombstoneMapping(ctx, fmt.Sprintf(participantIdentityKeyFmt, participantTypeAttendee, attendeeID)); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone attendee identity in partial delete")
+	}
 	xrefKey := fmt.Sprintf("v1_participant_by_meeting_user.attendee.%s.%s", meetingAndOccurrenceID, username)
 	if err := tombstoneMapping(ctx, xrefKey); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone attendee cross-reference in partial delete")
 	}
+	removeParticipantState(ctx, participantTypeAttendee, meetingAndOccurrenceID, username, "")
 
 	funcLogger.InfoContext(ctx, "successfully applied partial attendee delete (invitee record remains active)")
 	return false
@@ -2168,6 +2657,13 @@ func handleZoomPastMeetingInviteeDelete(ctx context.Context, key string, invitee
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", meetingAndOccurrenceID)
 
+	// The invitee is removed from the participant count whether or not the
+	// participant remains in V2.
+	if err := updatePastMeetingParticipantIndex(ctx, pastMeetingInvitees, meetingAndOccurrenceID, inviteeID, true); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to update past meeting participant index")
+		return true
+	}
+
 	// Extract username (lf_sso) field.
 	username, _ := v1Data["lf_sso"].(string)
 
@@ -2210,7 +2706,7 @@ func handleZoomPastMeetingInviteeDelete(ctx context.Context, key string, invitee
 	result := handleMeetingTypeDelete(ctx, key, inviteeID, message, meetingDeleteConfig{
 		indexerSubject:         IndexV1PastMeetingParticipantSubject,
 		deleteAllAccessSubject: deleteAllAccessSubject,
-		tombstoneKeyFmts:       []string{"v1_past_meeting_invitees.%s"},
+		tombstoneKeyFmts:       []string{"v1_past_meeting_invitees.%s", "v1_participant_identity.invitee.%s"},
 	})
 	// On successful full delete, also tombstone the invitee cross-reference mapping.
 	if !result && username != "" {
@@ -2219,6 +2715,10 @@ func handleZoomPastMeetingInviteeDelete(ctx context.Context, key string, invitee
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone invitee cross-reference mapping")
 		}
 	}
+	if !result {
+		email, _ := v1Data["email"].(string)
+		removeParticipantState(ctx, participantTypeInvitee, meetingAndOccurrenceID, username, email)
+	}
 	return result
 }
 
@@ -2235,7 +2735,7 @@ func handleZoomPastMeetingPartialInviteeDelete(ctx context.Context, funcLogger *
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to fetch attendee data for partial invitee delete; skipping")
 		return false
 	}
-	attendee, err := convertMapToInputPastMeetingAttendee(attendeeData)
+	attendee, err := convertMapToInputPastMeetingAttendee(ctx, attendeeData)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert attendee data for partial invitee delete")
 		return false
@@ -2283,7 +2783,7 @@ func handleZoomPastMeetingPartialInviteeDelete(ctx context.Context, funcLogger *
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal partial invitee delete access message")
 		return false
 	}
-	if err := sendAccessMessage(V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingParticipantPutSubject, accessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send partial invitee delete access update")
 		return true
 	}
@@ -2292,10 +2792,14 @@ func handleZoomPastMeetingPartialInviteeDelete(ctx context.Context, funcLogger *
 	if err := tombstoneMapping(ctx, fmt.Sprintf("v1_past_meeting_invitees.%s", inviteeID)); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone invitee mapping in partial delete")
 	}
+	if err := tombstoneMapping(ctx, fmt.Sprintf(participantIdentityKeyFmt, participantTypeInvitee, inviteeID)); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone invitee identity in partial delete")
+	}
 	xrefKey := fmt.Sprintf("v1_participant_by_meeting_user.invitee.%s.%s", meetingAndOccurrenceID, username)
 	if err := tombstoneMapping(ctx, xrefKey); err != nil {
 		funcLogger.With(errKey, err).WarnContext(ctx, "failed to tombstone invitee cross-reference in partial delete")
 	}
+	removeParticipantState(ctx, participantTypeInvitee, meetingAndOccurrenceID, username, "")
 
 	funcLogger.InfoContext(ctx, "successfully applied partial invitee delete (attendee record remains active)")
 	return false
@@ -2326,7 +2830,7 @@ func convertAttendeeToV2Participant(attendee *pastMeetingAttendeeInput, isHost b
 		Username:               mapUsernameToAuthSub(attendee.LFSSO),
 		IsInvited:              isRegistrant,
 		IsAttended:             true,
-		Sessions:               []ParticipantSession{}, // TODO: we need to determine the sessions for the invitee from the attendee event
+		Sessions:               []ParticipantSession{}, // filled from the attendee sessions below
 	}
 
 	if attendee.CreatedAt != "" {
@@ -2337,6 +2841,7 @@ func convertAttendeeToV2Participant(attendee *pastMeetingAttendeeInput, isHost b
 				"attendee_id", attendee.ID,
 				"meeting_and_occurrence_id", attendee.MeetingAndOccurrenceID,
 			).Warn("failed to parse created_at for attendee")
+			pastMeetingParticipant.addSyncWarning("created_at", attendee.CreatedAt, err)
 		} else {
 			pastMeetingParticipant.CreatedAt = &createdAt
 		}
@@ -2350,6 +2855,7 @@ func convertAttendeeToV2Participant(attendee *pastMeetingAttendeeInput, isHost b
 				"attendee_id", attendee.ID,
 				"meeting_and_occurrence_id", attendee.MeetingAndOccurrenceID,
 			).Warn("failed to parse modified_at for attendee")
+			pastMeetingParticipant.addSyncWarning("modified_at", attendee.ModifiedAt, err)
 		} else {
 			pastMeetingParticipant.UpdatedAt = &modifiedAt
 		}
@@ -2363,7 +2869,7 @@ func convertAttendeeToV2Participant(attendee *pastMeetingAttendeeInput, isHost b
 		pastMeetingParticipant.OrgIsProjectMember = *attendee.OrgIsProjectMember
 	}
 
-	for _, session := range attendee.Sessions {
+	for i, session := range attendee.Sessions {
 		participantSession := ParticipantSession{
 			UID:         session.ParticipantUUID,
 			LeaveReason: session.LeaveReason,
@@ -2378,6 +2884,7 @@ func convertAttendeeToV2Participant(attendee *pastMeetingAttendeeInput, isHost b
 					"attendee_id", attendee.ID,
 					"meeting_and_occurrence_id", attendee.MeetingAndOccurrenceID,
 				).Warn("failed to parse join_time for attendee")
+				pastMeetingParticipant.addSyncWarning(sessionField(i, "join_time"), session.JoinTime, err)
 			} else {
 				participantSession.JoinTime = &joinTime
 			}
@@ -2392,6 +2899,7 @@ func convertAttendeeToV2Participant(attendee *pastMeetingAttendeeInput, isHost b
 					"attendee_id", attendee.ID,
 					"meeting_and_occurrence_id", attendee.MeetingAndOccurrenceID,
 				).Warn("failed to parse leave_time for attendee")
+				pastMeetingParticipant.addSyncWarning(sessionField(i, "leave_time"), session.LeaveTime, err)
 			} else {
 				participantSession.LeaveTime = &leaveTime
 			}
@@ -2420,21 +2928,18 @@ type PastMeetingTranscriptAccessMessage struct {
 }
 
 // convertMapToInputPastMeetingRecording converts a map[string]any to a PastMeetingRecordingInput struct.
-func convertMapToInputPastMeetingRecording(v1Data map[string]any) (*pastMeetingRecordingInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting recording: %w", err)
-	}
-
-	// Unmarshal JSON bytes into PastMeetingRecordingInput struct
+func convertMapToInputPastMeetingRecording(ctx context.Context, v1Data map[string]any) (*pastMeetingRecordingInput, error) {
+	// Decode the record into the PastMeetingRecordingInput struct.
 	var recording pastMeetingRecordingInput
-	if err := json.Unmarshal(jsonBytes, &recording); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into PastMeetingRecordingInput: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &recording); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into PastMeetingRecordingInput: %w", err)
 	}
 
 	recording.Platform = "Zoom"
 
+	// The same session can be appended by several updates of the recording.
+	recording.Sessions = dedupeRecordingSessions(recording.Sessions)
+
 	// Populate the ID for the v2 system with the partition key from v1.
 	if meetingAndOccurrenceID, ok := v1Data["meeting_and_occurrence_id"].(string); ok && meetingAndOccurrenceID != "" {
 		recording.ID = meetingAndOccurrenceID
@@ -2529,12 +3034,17 @@ func handleZoomPastMeetingRecordingUpdate(ctx context.Context, key string, v1Dat
 	funcLogger.DebugContext(ctx, "processing zoom past meeting recording update")
 
 	// Convert the v1Data map to PastMeetingRecordingInput struct
-	recordingInput, err := convertMapToInputPastMeetingRecording(v1Data)
+	recordingInput, err := convertMapToInputPastMeetingRecording(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to PastMeetingRecordingInput")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, recordingInput); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the ID (MeetingAndOccurrenceID)
 	id := recordingInput.MeetingAndOccurrenceID
 	if id == "" {
@@ -2543,10 +3053,9 @@ func handleZoomPastMeetingRecordingUpdate(ctx context.Context, key string, v1Dat
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", id)
 
-	// Check if parent past meeting exists in mappings before proceeding.
-	pastMeetingMappingKey := fmt.Sprintf("v1_past_meetings.%s", id)
-	if _, err := mappingsKV.Get(ctx, pastMeetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent past meeting not found in mappings, will retry past meeting recording sync")
+	// Reference the past meeting sessions from the recording sessions.
+	if err := linkRecordingSessions(ctx, recordingInput); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to link recording sessions to past meeting sessions")
 		return true
 	}
 
@@ -2579,7 +3088,7 @@ func handleZoomPastMeetingRecordingUpdate(ctx context.Context, key string, v1Dat
 	}
 
 	// Send recording access message
-	if err := sendAccessMessage(V1PastMeetingRecordingUpdateAccessSubject, recordingAccessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingRecordingUpdateAccessSubject, recordingAccessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send recording access message")
 		return false
 	}
@@ -2606,11 +3115,14 @@ func handleZoomPastMeetingRecordingUpdate(ctx context.Context, key string, v1Dat
 	}
 
 	// Send transcript access message
-	if err := sendAccessMessage(V1PastMeetingTranscriptUpdateAccessSubject, transcriptAccessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingTranscriptUpdateAccessSubject, transcriptAccessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send transcript access message")
 		return false
 	}
 
+	// Index the transcript text, if enabled.
+	retry := indexPastMeetingTranscriptContent(ctx, recordingInput)
+
 	if id != "" {
 		if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting recording mapping")
@@ -2618,7 +3130,7 @@ func handleZoomPastMeetingRecordingUpdate(ctx context.Context, key string, v1Dat
 	}
 
 	funcLogger.InfoContext(ctx, "successfully sent recording and transcript indexer and access messages")
-	return false
+	return retry
 }
 
 // handleZoomPastMeetingRecordingDelete processes a deletion of an itx-zoom-past-meetings-recordings record.
@@ -2642,6 +3154,11 @@ func handleZoomPastMeetingRecordingDelete(ctx context.Context, key string, meeti
 		return true
 	}
 
+	// Delete the transcript content chunks, if any were indexed.
+	if retry := deletePastMeetingTranscriptContent(ctx, meetingAndOccurrenceID); retry {
+		return true
+	}
+
 	// Delete transcript from indexer and tombstone the shared mapping.
 	return handleMeetingTypeDelete(ctx, key, meetingAndOccurrenceID, nil, meetingDeleteConfig{
 		indexerSubject:   IndexV1PastMeetingTranscriptSubject,
@@ -2658,17 +3175,11 @@ type PastMeetingSummaryAccessMessage struct {
 }
 
 // convertMapToInputPastMeetingSummary converts a map[string]any to a PastMeetingSummaryInput struct.
-func convertMapToInputPastMeetingSummary(v1Data map[string]any) (*pastMeetingSummaryInput, error) {
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting summary: %w", err)
-	}
-
-	// Unmarshal JSON bytes into PastMeetingSummaryInput struct
+func convertMapToInputPastMeetingSummary(ctx context.Context, v1Data map[string]any) (*pastMeetingSummaryInput, error) {
+	// Decode the record into the PastMeetingSummaryInput struct.
 	var summary pastMeetingSummaryInput
-	if err := json.Unmarshal(jsonBytes, &summary); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into PastMeetingSummaryInput: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into PastMeetingSummaryInput: %w", err)
 	}
 
 	if summaryID, ok := v1Data["id"].(string); ok && summaryID != "" {
@@ -2761,12 +3272,17 @@ func handleZoomPastMeetingSummaryUpdate(ctx context.Context, key string, v1Data
 	funcLogger.DebugContext(ctx, "processing zoom past meeting summary update")
 
 	// Convert the v1Data map to PastMeetingSummaryInput struct
-	summaryInput, err := convertMapToInputPastMeetingSummary(v1Data)
+	summaryInput, err := convertMapToInputPastMeetingSummary(ctx, v1Data)
 	if err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to convert v1Data to PastMeetingSummaryInput")
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, summaryInput); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the UID (ID)
 	uid := summaryInput.ID
 	if uid == "" {
@@ -2775,31 +3291,13 @@ func handleZoomPastMeetingSummaryUpdate(ctx context.Context, key string, v1Data
 	}
 	funcLogger = funcLogger.With("summary_id", uid)
 
-	// Check if parent past meeting exists in mappings before proceeding.
+	// The parent past meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if summaryInput.MeetingAndOccurrenceID == "" {
 		funcLogger.ErrorContext(ctx, "past meeting summary missing required parent past meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", summaryInput.MeetingAndOccurrenceID)
-	pastMeetingMappingKey := fmt.Sprintf("v1_past_meetings.%s", summaryInput.MeetingAndOccurrenceID)
-	if _, err := mappingsKV.Get(ctx, pastMeetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent past meeting not found in mappings, will retry past meeting summary sync")
-		return true
-	}
-
-	// Determine action based on mapping existence
-	mappingKey := fmt.Sprintf("v1_past_meeting_summaries.%s", uid)
-	indexerAction := MessageActionCreated
-	if _, err := mappingsKV.Get(ctx, mappingKey); err == nil {
-		indexerAction = MessageActionUpdated
-	}
-
-	// Send summary indexer message
-	tags := getPastMeetingSummaryTags(summaryInput)
-	if err := sendIndexerMessage(ctx, IndexV1PastMeetingSummarySubject, indexerAction, summaryInput, tags); err != nil {
-		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send summary indexer message")
-		return false
-	}
 
 	aiSummaryAccess := ""
 	if summaryInput.MeetingAndOccurrenceID != "" {
@@ -2814,6 +3312,43 @@ func handleZoomPastMeetingSummaryUpdate(ctx context.Context, key string, v1Data
 		}
 	}
 
+	// Skip re-indexing summaries v1 rewrote unchanged, but still re-evaluate
+	// their access when the parent's ai_summary_access changed.
+	fingerprint, err := newSummaryFingerprint(summaryInput, aiSummaryAccess)
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to compute past meeting summary fingerprint")
+		return false
+	}
+	previousFingerprint, synced := getSummaryFingerprint(ctx, uid)
+	contentChanged := !synced || fingerprint.contentChanged(previousFingerprint)
+	accessChanged := !synced || fingerprint.SummaryAccess != previousFingerprint.SummaryAccess
+	if !contentChanged && !accessChanged {
+		funcLogger.DebugContext(ctx, "past meeting summary unchanged, skipping")
+		return false
+	}
+
+	// Determine action based on mapping existence
+	mappingKey := fmt.Sprintf("v1_past_meeting_summaries.%s", uid)
+	indexerAction := MessageActionCreated
+	if _, err := mappingsKV.Get(ctx, mappingKey); err == nil {
+		indexerAction = MessageActionUpdated
+	}
+
+	translated := true
+	if contentChanged {
+		// Detect the summary language, and translate it if enabled.
+		translated = applySummaryLanguage(ctx, summaryInput)
+
+		// Send summary indexer message
+		tags := getPastMeetingSummaryTags(summaryInput)
+		if err := sendIndexerMessage(ctx, IndexV1PastMeetingSummarySubject, indexerAction, summaryInput, tags); err != nil {
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send summary indexer message")
+			return false
+		}
+	} else {
+		funcLogger.DebugContext(ctx, "past meeting summary content unchanged, skipping indexer message")
+	}
+
 	summaryAccessMsg := PastMeetingSummaryAccessMessage{
 		ID:                     uid,
 		MeetingAndOccurrenceID: summaryInput.MeetingAndOccurrenceID,
@@ -2828,7 +3363,7 @@ func handleZoomPastMeetingSummaryUpdate(ctx context.Context, key string, v1Data
 	}
 
 	// Send summary access message
-	if err := sendAccessMessage(V1PastMeetingSummaryUpdateAccessSubject, summaryAccessMsgBytes); err != nil {
+	if err := sendAccessMessage(ctx, V1PastMeetingSummaryUpdateAccessSubject, summaryAccessMsgBytes); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send summary access message")
 		return false
 	}
@@ -2837,9 +3372,16 @@ func handleZoomPastMeetingSummaryUpdate(ctx context.Context, key string, v1Data
 		if _, err := mappingsKV.Put(ctx, mappingKey, []byte("1")); err != nil {
 			funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting summary mapping")
 		}
+		// Summaries which failed translation are not fingerprinted, so their
+		// next rewrite is translated again.
+		if translated {
+			if err := putSummaryFingerprint(ctx, uid, fingerprint); err != nil {
+				funcLogger.With(errKey, err).WarnContext(ctx, "failed to store past meeting summary fingerprint")
+			}
+		}
 	}
 
-	funcLogger.InfoContext(ctx, "successfully sent summary indexer and access messages")
+	funcLogger.With("content_changed", contentChanged, "access_changed", accessChanged).InfoContext(ctx, "successfully sent summary indexer and access messages")
 	return false
 }
 
@@ -2858,7 +3400,7 @@ func handleZoomPastMeetingSummaryDelete(ctx context.Context, key string, summary
 
 	return handleMeetingTypeDelete(ctx, key, summaryID, []byte(summaryID), meetingDeleteConfig{
 		indexerSubject:   IndexV1PastMeetingSummarySubject,
-		tombstoneKeyFmts: []string{"v1_past_meeting_summaries.%s"},
+		tombstoneKeyFmts: []string{"v1_past_meeting_summaries.%s", summaryFingerprintKeyFmt},
 	})
 }
 
@@ -2918,16 +3460,10 @@ func handlePastMeetingAttachmentDelete(ctx context.Context, key string, attachme
 func convertMapToMeetingAttachment(ctx context.Context, v1Data map[string]any) (*InputMeetingAttachment, error) {
 	funcLogger := logger.With("handler", "meeting_attachment")
 
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for meeting attachment: %w", err)
-	}
-
-	// Unmarshal JSON bytes into MeetingAttachmentDB struct (handles flexible types)
+	// Decode the record into the MeetingAttachmentDB struct (handles flexible types).
 	var attachmentDB MeetingAttachmentDB
-	if err := json.Unmarshal(jsonBytes, &attachmentDB); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into MeetingAttachmentDB: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &attachmentDB); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into MeetingAttachmentDB: %w", err)
 	}
 
 	// Convert to InputMeetingAttachment with UID field
@@ -2963,19 +3499,25 @@ func convertMapToMeetingAttachment(ctx context.Context, v1Data map[string]any) (
 
 // sendMeetingAttachmentIndexerMessage sends the indexer message to NATS for meeting attachments.
 func sendMeetingAttachmentIndexerMessage(ctx context.Context, subject string, action indexerConstants.MessageAction, data InputMeetingAttachment) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, &data); err != nil || !publish {
+		return err
+	}
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	public := false
@@ -3026,9 +3568,10 @@ func sendMeetingAttachmentIndexerMessage(ctx context.Context, subject string, ac
 
 	logger.With("subject", subject, "action", action).DebugContext(ctx, "constructed indexer message")
 
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
@@ -3052,6 +3595,11 @@ func handleMeetingAttachmentUpdate(ctx context.Context, key string, v1Data map[s
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, attachment); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the attachment UID
 	uid := attachment.UID
 	if uid == "" {
@@ -3060,17 +3608,13 @@ func handleMeetingAttachmentUpdate(ctx context.Context, key string, v1Data map[s
 	}
 	funcLogger = funcLogger.With("attachment_id", uid)
 
-	// Check if parent meeting exists in mappings before proceeding
+	// The parent meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if attachment.MeetingID == "" {
 		funcLogger.ErrorContext(ctx, "meeting attachment missing required parent meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_id", attachment.MeetingID)
-	meetingMappingKey := fmt.Sprintf("v1_meetings.%s", attachment.MeetingID)
-	if _, err := mappingsKV.Get(ctx, meetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent meeting not found in mappings, will retry meeting attachment sync")
-		return true
-	}
 
 	mappingKey := fmt.Sprintf("v1_meeting_attachments.%s", uid)
 	indexerAction := indexerConstants.ActionCreated
@@ -3097,16 +3641,10 @@ func handleMeetingAttachmentUpdate(ctx context.Context, key string, v1Data map[s
 func convertMapToPastMeetingAttachment(ctx context.Context, v1Data map[string]any) (*InputPastMeetingAttachment, error) {
 	funcLogger := logger.With("handler", "past_meeting_attachment")
 
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for past meeting attachment: %w", err)
-	}
-
-	// Unmarshal JSON bytes into PastMeetingAttachmentDB struct (handles flexible types)
+	// Decode the record into the PastMeetingAttachmentDB struct (handles flexible types).
 	var attachmentDB PastMeetingAttachmentDB
-	if err := json.Unmarshal(jsonBytes, &attachmentDB); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into PastMeetingAttachmentDB: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &attachmentDB); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into PastMeetingAttachmentDB: %w", err)
 	}
 
 	// Convert to InputPastMeetingAttachment with UID field
@@ -3143,19 +3681,25 @@ func convertMapToPastMeetingAttachment(ctx context.Context, v1Data map[string]an
 
 // sendPastMeetingAttachmentIndexerMessage sends the indexer message to NATS for past meeting attachments.
 func sendPastMeetingAttachmentIndexerMessage(ctx context.Context, subject string, action indexerConstants.MessageAction, data InputPastMeetingAttachment) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, &data); err != nil || !publish {
+		return err
+	}
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	public := false
@@ -3209,9 +3753,10 @@ func sendPastMeetingAttachmentIndexerMessage(ctx context.Context, subject string
 
 	logger.With("subject", subject, "action", action).DebugContext(ctx, "constructed indexer message")
 
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
@@ -3235,6 +3780,11 @@ func handlePastMeetingAttachmentUpdate(ctx context.Context, key string, v1Data m
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, attachment); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the attachment UID
 	uid := attachment.UID
 	if uid == "" {
@@ -3243,17 +3793,13 @@ func handlePastMeetingAttachmentUpdate(ctx context.Context, key string, v1Data m
 	}
 	funcLogger = funcLogger.With("attachment_id", uid)
 
-	// Check if parent past meeting exists in mappings before proceeding
+	// The parent past meeting ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if attachment.MeetingAndOccurrenceID == "" {
 		funcLogger.ErrorContext(ctx, "past meeting attachment missing required parent past meeting ID")
 		return false
 	}
 	funcLogger = funcLogger.With("meeting_and_occurrence_id", attachment.MeetingAndOccurrenceID)
-	pastMeetingMappingKey := fmt.Sprintf("v1_past_meetings.%s", attachment.MeetingAndOccurrenceID)
-	if _, err := mappingsKV.Get(ctx, pastMeetingMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent past meeting not found in mappings, will retry past meeting attachment sync")
-		return true
-	}
 
 	mappingKey := fmt.Sprintf("v1_past_meeting_attachments.%s", uid)
 	indexerAction := indexerConstants.ActionCreated
@@ -0,0 +1,99 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/testkit"
+)
+
+// setupHandlerTest replaces the NATS buckets and publisher of the handlers
+// with in-memory fakes for the duration of a test.
+func setupHandlerTest(t *testing.T) (v1 *testkit.KV, mappings *testkit.KV, publisher *testkit.Publisher) {
+	t.Helper()
+	testkit.Install(t)
+
+	previousLogger, previousCfg, previousV1KV, previousMappingsKV, previousPublish := logger, cfg, v1KV, mappingsKV, publishCore
+	previousSettings := settings()
+	t.Cleanup(func() {
+		logger, cfg, v1KV, mappingsKV, publishCore = previousLogger, previousCfg, previousV1KV, previousMappingsKV, previousPublish
+		currentRuntimeSettings.Store(previousSettings)
+	})
+
+	v1, mappings, publisher = testkit.NewKV("v1-objects"), testkit.NewKV("v1-mappings"), testkit.NewPublisher()
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg = &Config{IndexerLegacyAuthorization: true, IndexerMaxPayloadBytes: 1 << 20}
+	v1KV, mappingsKV, publishCore = v1, mappings, publisher.PublishMsg
+	applyRuntimeSettings(envRuntimeSettings(cfg))
+	return v1, mappings, publisher
+}
+
+// recordData decodes a record as the KV handlers do.
+func recordData(t *testing.T, record testkit.Record) map[string]any {
+	t.Helper()
+	var data map[string]any
+	if err := json.Unmarshal(record.JSON(), &data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHandleZoomMeetingUpdate(t *testing.T) {
+	ctx := context.Background()
+	v1, mappings, publisher := setupHandlerTest(t)
+
+	const projectUID = "00000000-0000-4000-8000-0000000000aa"
+	if _, err := mappings.Put(ctx, "project.sfid.a0941000002wBz9AAE", []byte(projectUID)); err != nil {
+		t.Fatal(err)
+	}
+	meeting := testkit.V1Meeting("91234567890", "a0941000002wBz9AAE")
+	if _, err := meeting.Put(ctx, v1); err != nil {
+		t.Fatal(err)
+	}
+
+	handleZoomMeetingUpdate(ctx, meeting.Key, recordData(t, meeting))
+
+	indexed := publisher.Messages(IndexV1MeetingSubject)
+	if len(indexed) != 1 {
+		t.Fatalf("indexer messages: got %d, want 1", len(indexed))
+	}
+	var indexerMsg MeetingIndexerMessage
+	if err := json.Unmarshal(indexed[0].Data, &indexerMsg); err != nil {
+		t.Fatal(err)
+	}
+	if indexerMsg.Action != MessageActionCreated {
+		t.Errorf("indexer action: got %q, want %q", indexerMsg.Action, MessageActionCreated)
+	}
+
+	access := publisher.Messages(UpdateAccessV1MeetingSubject)
+	if len(access) != 1 {
+		t.Fatalf("access messages: got %d, want 1", len(access))
+	}
+	var accessMsg MeetingAccessMessage
+	if err := json.Unmarshal(access[0].Data, &accessMsg); err != nil {
+		t.Fatal(err)
+	}
+	if accessMsg.UID != "91234567890" || accessMsg.ProjectUID != projectUID || !accessMsg.Public {
+		t.Errorf("access message: got %+v", accessMsg)
+	}
+
+	if _, err := mappings.Get(ctx, "v1_meetings.91234567890"); err != nil {
+		t.Errorf("meeting mapping: %v", err)
+	}
+
+	// Re-processing the unchanged meeting only updates its access.
+	publisher.Reset()
+	handleZoomMeetingUpdate(ctx, meeting.Key, recordData(t, meeting))
+	if n := len(publisher.Messages(IndexV1MeetingSubject)); n != 0 {
+		t.Errorf("indexer messages for an unchanged meeting: got %d, want 0", n)
+	}
+	if n := len(publisher.Messages(UpdateAccessV1MeetingSubject)); n != 1 {
+		t.Errorf("access messages for an unchanged meeting: got %d, want 1", n)
+	}
+}
@@ -157,6 +157,7 @@ func handleProjectUpdate(ctx context.Context, key string, v1Data map[string]any)
 	}
 
 	var uid string
+	var public bool
 	var err error
 
 	if existingUID != "" {
@@ -181,6 +182,7 @@ func handleProjectUpdate(ctx context.Context, key string, v1Data map[string]any)
 
 		err = updateProject(ctx, payload, settingsPayload, v1Principal)
 		uid = existingUID
+		public = boolPtrToBool(payload.Public)
 	} else {
 		// Check allowlist before creating new project.
 		allowed, reason := isProjectAllowed(ctx, v1Data)
@@ -205,6 +207,7 @@ func handleProjectUpdate(ctx context.Context, key string, v1Data map[string]any)
 		if response != nil && response.UID != nil {
 			uid = *response.UID
 		}
+		public = boolPtrToBool(payload.Public)
 	}
 
 	if err != nil {
@@ -223,6 +226,9 @@ func handleProjectUpdate(ctx context.Context, key string, v1Data map[string]any)
 		if _, err := mappingsKV.Put(ctx, reverseMappingKey, []byte(sfid)); err != nil {
 			logger.With(errKey, err, "project_uid", uid, "sfid", sfid).WarnContext(ctx, "failed to store project reverse mapping")
 		}
+
+		// Store the public flag, which meetings without a visibility take.
+		storeProjectPublic(ctx, sfid, public)
 	}
 
 	logger.With("project_uid", uid, "sfid", sfid, "slug", slug).InfoContext(ctx, "successfully synced project")
@@ -268,6 +274,7 @@ func handleProjectDelete(ctx context.Context, key string, sfid string, v1Princip
 	if err := tombstoneMapping(ctx, reverseMappingKey); err != nil {
 		logger.With(errKey, err, "project_uid", existingUID, "sfid", sfid).WarnContext(ctx, "failed to tombstone project UID mapping")
 	}
+	deleteProjectPublic(ctx, sfid)
 
 	logger.With("project_uid", existingUID, "sfid", sfid, "key", key).InfoContext(ctx, "successfully deleted project")
 	return false
@@ -24,22 +24,25 @@ const (
 
 // sendSurveyIndexerMessage sends the message to the NATS server for the survey indexer.
 func sendSurveyIndexerMessage(ctx context.Context, subject string, action indexerConstants.MessageAction, data SurveyInput) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, &data); err != nil || !publish {
+		return err
+	}
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		// Fallback for system-generated events that don't have user auth context
-		// This is just a dummy value so that the indexer service can still process the message,
-		// given that it requires an authorization header.
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	public := false
@@ -92,15 +95,16 @@ func sendSurveyIndexerMessage(ctx context.Context, subject string, action indexe
 	logger.With("subject", subject, "action", action).DebugContext(ctx, "constructed indexer message")
 
 	// Publish the message to NATS
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
 
 // sendSurveyAccessMessage sends the message to the NATS server for the survey access control.
-func sendSurveyAccessMessage(survey SurveyInput) error {
+func sendSurveyAccessMessage(ctx context.Context, survey SurveyInput) error {
 	// Build committee and project references
 	committeeRefs := []string{}
 	projectRefs := []string{}
@@ -151,9 +155,16 @@ func sendSurveyAccessMessage(survey SurveyInput) error {
 		return fmt.Errorf("failed to marshal access message: %w", err)
 	}
 
-	// Publish the message to NATS
-	if err := natsConn.Publish(UpdateAccessSubject, accessMsgBytes); err != nil {
-		return fmt.Errorf("failed to publish access message to subject %s: %w", UpdateAccessSubject, err)
+	// Surveys may reference several projects; they are sharded by the first.
+	projectUID := ""
+	if len(projectRefs) > 0 {
+		projectUID = projectRefs[0]
+	}
+
+	// Publish the message to NATS, sharded by project when enabled.
+	subject := shardedAccessSubject(UpdateAccessSubject, projectUID)
+	if err := publishMessage(ctx, subject, accessMsgBytes); err != nil {
+		return fmt.Errorf("failed to publish access message to subject %s: %w", subject, err)
 	}
 
 	return nil
@@ -162,16 +173,10 @@ func sendSurveyAccessMessage(survey SurveyInput) error {
 func convertMapToInputSurvey(ctx context.Context, v1Data map[string]any) (*SurveyInput, error) {
 	funcLogger := logger.With("handler", "survey")
 
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for survey: %w", err)
-	}
-
-	// Unmarshal JSON bytes into SurveyDatabase struct (all strings)
+	// Decode the record into the SurveyDatabase struct (all strings).
 	var surveyDB SurveyDatabase
-	if err := json.Unmarshal(jsonBytes, &surveyDB); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into SurveyDatabase: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &surveyDB); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into SurveyDatabase: %w", err)
 	}
 
 	// Convert SurveyDatabase to SurveyInput, converting string ints to proper ints
@@ -281,6 +286,11 @@ func handleSurveyUpdate(ctx context.Context, key string, v1Data map[string]any)
 		return
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, survey); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return
+	}
+
 	// Extract the survey UID
 	uid := survey.UID
 	if uid == "" {
@@ -313,7 +323,7 @@ func handleSurveyUpdate(ctx context.Context, key string, v1Data map[string]any)
 		return
 	}
 
-	if err := sendSurveyAccessMessage(*survey); err != nil {
+	if err := sendSurveyAccessMessage(ctx, *survey); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send survey access message")
 		return
 	}
@@ -329,22 +339,25 @@ func handleSurveyUpdate(ctx context.Context, key string, v1Data map[string]any)
 
 // sendSurveyResponseIndexerMessage sends the message to the NATS server for the survey response indexer.
 func sendSurveyResponseIndexerMessage(ctx context.Context, subject string, action indexerConstants.MessageAction, data SurveyResponseInput) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, &data); err != nil || !publish {
+		return err
+	}
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		// Fallback for system-generated events that don't have user auth context
-		// This is just a dummy value so that the indexer service can still process the message,
-		// given that it requires an authorization header.
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	public := false
@@ -386,15 +399,16 @@ func sendSurveyResponseIndexerMessage(ctx context.Context, subject string, actio
 	logger.With("subject", subject, "action", action).DebugContext(ctx, "constructed indexer message")
 
 	// Publish the message to NATS
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
 
 // sendSurveyResponseAccessMessage sends the message to the NATS server for the survey response access control.
-func sendSurveyResponseAccessMessage(data SurveyResponseInput) error {
+func sendSurveyResponseAccessMessage(ctx context.Context, data SurveyResponseInput) error {
 	relations := map[string][]string{}
 	references := map[string][]string{}
 
@@ -425,9 +439,10 @@ func sendSurveyResponseAccessMessage(data SurveyResponseInput) error {
 		return fmt.Errorf("failed to marshal access message: %w", err)
 	}
 
-	// Publish the message to NATS
-	if err := natsConn.Publish(UpdateAccessSubject, accessMsgBytes); err != nil {
-		return fmt.Errorf("failed to publish access message to subject %s: %w", UpdateAccessSubject, err)
+	// Publish the message to NATS, sharded by project when enabled.
+	subject := shardedAccessSubject(UpdateAccessSubject, data.Project.ProjectUID)
+	if err := publishMessage(ctx, subject, accessMsgBytes); err != nil {
+		return fmt.Errorf("failed to publish access message to subject %s: %w", subject, err)
 	}
 
 	return nil
@@ -436,16 +451,10 @@ func sendSurveyResponseAccessMessage(data SurveyResponseInput) error {
 func convertMapToInputSurveyResponse(ctx context.Context, v1Data map[string]any) (*SurveyResponseInput, error) {
 	funcLogger := logger.With("handler", "survey_response")
 
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for survey response: %w", err)
-	}
-
-	// Unmarshal JSON bytes into SurveyResponseDatabase struct (all strings)
+	// Decode the record into the SurveyResponseDatabase struct (all strings).
 	var responseDB SurveyResponseDatabase
-	if err := json.Unmarshal(jsonBytes, &responseDB); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into SurveyResponseDatabase: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &responseDB); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into SurveyResponseDatabase: %w", err)
 	}
 
 	// Convert SurveyResponseDatabase to SurveyResponseInput, converting string ints to proper ints
@@ -543,6 +552,11 @@ func handleSurveyResponseUpdate(ctx context.Context, key string, v1Data map[stri
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, surveyResponse); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the survey response UID
 	uid := surveyResponse.UID
 	if uid == "" {
@@ -551,17 +565,13 @@ func handleSurveyResponseUpdate(ctx context.Context, key string, v1Data map[stri
 	}
 	funcLogger = funcLogger.With("survey_response_id", uid)
 
-	// Check if parent survey exists in mappings before proceeding
+	// The parent survey ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if surveyResponse.SurveyID == "" {
 		funcLogger.ErrorContext(ctx, "survey response missing required parent survey ID")
 		return false
 	}
 	funcLogger = funcLogger.With("survey_id", surveyResponse.SurveyID)
-	surveyMappingKey := fmt.Sprintf("survey.%s", surveyResponse.SurveyID)
-	if _, err := mappingsKV.Get(ctx, surveyMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent survey not found in mappings, will retry survey response sync")
-		return true
-	}
 
 	mappingKey := fmt.Sprintf("survey_response.%s", uid)
 	indexerAction := indexerConstants.ActionCreated
@@ -574,7 +584,7 @@ func handleSurveyResponseUpdate(ctx context.Context, key string, v1Data map[stri
 		return false
 	}
 
-	if err := sendSurveyResponseAccessMessage(*surveyResponse); err != nil {
+	if err := sendSurveyResponseAccessMessage(ctx, *surveyResponse); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send survey response access message")
 		return false
 	}
@@ -588,3 +598,15 @@ func handleSurveyResponseUpdate(ctx context.Context, key string, v1Data map[stri
 	funcLogger.InfoContext(ctx, "successfully sent survey response indexer and access messages")
 	return false
 }
+
+// handleSurveyDelete processes a deletion of an itx-surveys record.
+// Returns true if the operation should be retried, false otherwise.
+func handleSurveyDelete(ctx context.Context, key string, surveyID string) bool {
+	return handleIndexedObjectDelete(ctx, key, surveyID, "survey", IndexSurveySubject, "survey.%s")
+}
+
+// handleSurveyResponseDelete processes a deletion of an itx-survey-responses record.
+// Returns true if the operation should be retried, false otherwise.
+func handleSurveyResponseDelete(ctx context.Context, key string, surveyResponseID string) bool {
+	return handleIndexedObjectDelete(ctx, key, surveyResponseID, "survey_response", IndexSurveyResponseSubject, "survey_response.%s")
+}
@@ -24,22 +24,25 @@ const (
 
 // sendVoteIndexerMessage sends the message to the NATS server for the vote indexer.
 func sendVoteIndexerMessage(ctx context.Context, subject string, action indexerConstants.MessageAction, data InputVote) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, &data); err != nil || !publish {
+		return err
+	}
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		// Fallback for system-generated events that don't have user auth context
-		// This is just a dummy value so that the indexer service can still process the message,
-		// given that it requires an authorization header.
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	public := false
@@ -80,15 +83,16 @@ func sendVoteIndexerMessage(ctx context.Context, subject string, action indexerC
 	logger.With("subject", subject, "action", action).DebugContext(ctx, "constructed indexer message")
 
 	// Publish the message to NATS
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
 
 // sendVoteAccessMessage sends the message to the NATS server for the vote access control.
-func sendVoteAccessMessage(vote InputVote) error {
+func sendVoteAccessMessage(ctx context.Context, vote InputVote) error {
 	references := map[string][]string{}
 	if vote.ProjectUID != "" {
 		references["project"] = []string{vote.ProjectUID}
@@ -116,9 +120,10 @@ func sendVoteAccessMessage(vote InputVote) error {
 		return fmt.Errorf("failed to marshal access message: %w", err)
 	}
 
-	// Publish the message to NATS
-	if err := natsConn.Publish(UpdateAccessSubject, accessMsgBytes); err != nil {
-		return fmt.Errorf("failed to publish access message to subject %s: %w", UpdateAccessSubject, err)
+	// Publish the message to NATS, sharded by project when enabled.
+	subject := shardedAccessSubject(UpdateAccessSubject, vote.ProjectUID)
+	if err := publishMessage(ctx, subject, accessMsgBytes); err != nil {
+		return fmt.Errorf("failed to publish access message to subject %s: %w", subject, err)
 	}
 
 	return nil
@@ -127,16 +132,10 @@ func sendVoteAccessMessage(vote InputVote) error {
 func convertMapToInputVote(ctx context.Context, v1Data map[string]any) (*InputVote, error) {
 	funcLogger := logger.With("handler", "vote")
 
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for vote: %w", err)
-	}
-
-	// Unmarshal JSON bytes into PollDB struct (all strings)
+	// Decode the record into the PollDB struct (all strings).
 	var pollDB PollDB
-	if err := json.Unmarshal(jsonBytes, &pollDB); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into PollDB: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &pollDB); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into PollDB: %w", err)
 	}
 
 	// Convert PollDB to InputVote, converting string ints to proper ints
@@ -167,9 +166,8 @@ func convertMapToInputVote(ctx context.Context, v1Data map[string]any) (*InputVo
 
 	// Use the v1 project ID to get the v2 project UID.
 	if pollDB.ProjectID != "" {
-		projectMappingKey := fmt.Sprintf("project.sfid.%s", pollDB.ProjectID)
-		if entry, err := mappingsKV.Get(ctx, projectMappingKey); err == nil {
-			vote.ProjectUID = string(entry.Value())
+		if projectUID, err := projectUIDBySFID(ctx, pollDB.ProjectID); err == nil && projectUID != "" {
+			vote.ProjectUID = projectUID
 		} else {
 			funcLogger.With(errKey, err).
 				With("field", "project_id").
@@ -212,6 +210,11 @@ func handleVoteUpdate(ctx context.Context, key string, v1Data map[string]any) {
 		return
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, vote); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return
+	}
+
 	// Extract the vote UID
 	uid := vote.UID
 	if uid == "" {
@@ -220,12 +223,11 @@ func handleVoteUpdate(ctx context.Context, key string, v1Data map[string]any) {
 	}
 	funcLogger = funcLogger.With("vote_id", uid)
 
-	// Check if parent project exists in mappings before proceeding. Because
-	// convertMapToInputVote has already looked up the SFID project ID
-	// mapping, we don't need to do it again: we can just check if ProjectID (v2
-	// UID) is set.
+	// The dispatcher parks votes until their parent project mapping exists,
+	// so ProjectUID (looked up by convertMapToInputVote) is only unset for
+	// votes without a v1 project.
 	if vote.ProjectUID == "" {
-		funcLogger.With("project_id", vote.ProjectID).InfoContext(ctx, "skipping vote sync - parent project not found in mappings")
+		funcLogger.With("project_id", vote.ProjectID).InfoContext(ctx, "skipping vote sync - no parent project")
 		return
 	}
 
@@ -240,7 +242,7 @@ func handleVoteUpdate(ctx context.Context, key string, v1Data map[string]any) {
 		return
 	}
 
-	if err := sendVoteAccessMessage(*vote); err != nil {
+	if err := sendVoteAccessMessage(ctx, *vote); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send vote access message")
 		return
 	}
@@ -256,22 +258,25 @@ func handleVoteUpdate(ctx context.Context, key string, v1Data map[string]any) {
 
 // sendVoteResponseIndexerMessage sends the message to the NATS server for the vote response indexer.
 func sendVoteResponseIndexerMessage(ctx context.Context, subject string, action indexerConstants.MessageAction, data VoteResponseInput) error {
+	if publish, err := runPrePublishMiddleware(ctx, subject, &data); err != nil || !publish {
+		return err
+	}
+
 	headers := make(map[string]string)
 
-	// Extract authorization from context if available
-	if authorization, ok := ctx.Value("authorization").(string); ok {
-		headers["authorization"] = authorization
-	} else {
-		// Fallback for system-generated events that don't have user auth context
-		// This is just a dummy value so that the indexer service can still process the message,
-		// given that it requires an authorization header.
-		headers["authorization"] = "Bearer v1-sync-helper"
+	// Use the authorization from context if available, otherwise a service
+	// token scoped to the indexer.
+	authorization, err := indexerAuthorization(ctx)
+	if err != nil {
+		return err
 	}
+	headers["authorization"] = authorization
 
 	// Extract principal from context if available
 	if principal, ok := ctx.Value("principal").(string); ok {
 		headers["x-on-behalf-of"] = principal
 	}
+	injectTraceContext(ctx, headers)
 
 	// Construct the indexer message
 	public := false
@@ -314,15 +319,16 @@ func sendVoteResponseIndexerMessage(ctx context.Context, subject string, action
 	logger.With("subject", subject, "action", action).DebugContext(ctx, "constructed indexer message")
 
 	// Publish the message to NATS
-	if err := natsConn.Publish(subject, messageBytes); err != nil {
+	if err := publishMessage(ctx, subject, messageBytes); err != nil {
 		return fmt.Errorf("failed to publish indexer message to subject %s: %w", subject, err)
 	}
+	recordIndexerMessage(ctx, subject, messageBytes)
 
 	return nil
 }
 
 // sendVoteResponseAccessMessage sends the message to the NATS server for the vote response access control.
-func sendVoteResponseAccessMessage(data VoteResponseInput) error {
+func sendVoteResponseAccessMessage(ctx context.Context, data VoteResponseInput) error {
 	relations := map[string][]string{}
 	if data.Username != "" {
 		relations["writer"] = []string{data.Username}
@@ -357,9 +363,10 @@ func sendVoteResponseAccessMessage(data VoteResponseInput) error {
 		return fmt.Errorf("failed to marshal access message: %w", err)
 	}
 
-	// Publish the message to NATS
-	if err := natsConn.Publish(UpdateAccessSubject, accessMsgBytes); err != nil {
-		return fmt.Errorf("failed to publish access message to subject %s: %w", UpdateAccessSubject, err)
+	// Publish the message to NATS, sharded by project when enabled.
+	subject := shardedAccessSubject(UpdateAccessSubject, data.ProjectUID)
+	if err := publishMessage(ctx, subject, accessMsgBytes); err != nil {
+		return fmt.Errorf("failed to publish access message to subject %s: %w", subject, err)
 	}
 
 	return nil
@@ -368,16 +375,10 @@ func sendVoteResponseAccessMessage(data VoteResponseInput) error {
 func convertMapToInputVoteResponse(ctx context.Context, v1Data map[string]any) (*VoteResponseInput, error) {
 	funcLogger := logger.With("handler", "vote_response")
 
-	// Convert map to JSON bytes
-	jsonBytes, err := json.Marshal(v1Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal v1Data to JSON for vote: %w", err)
-	}
-
-	// Unmarshal JSON bytes into VoteDB struct (all strings, including choice_rank)
+	// Decode the record into the VoteDB struct (all strings, including choice_rank).
 	var voteDB VoteDB
-	if err := json.Unmarshal(jsonBytes, &voteDB); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON into VoteDB: %w", err)
+	if err := decodeV1Record(ctx, v1Data, &voteDB); err != nil {
+		return nil, fmt.Errorf("failed to decode v1Data into VoteDB: %w", err)
 	}
 
 	// Convert VoteDB to VoteResponseInput
@@ -472,6 +473,11 @@ func handleVoteResponseUpdate(ctx context.Context, key string, v1Data map[string
 		return false
 	}
 
+	if err := runPostConversionMiddleware(ctx, key, voteResponse); err != nil {
+		logMiddlewareStop(ctx, funcLogger, err)
+		return false
+	}
+
 	// Extract the individual vote UID
 	uid := voteResponse.UID
 	if uid == "" {
@@ -480,17 +486,13 @@ func handleVoteResponseUpdate(ctx context.Context, key string, v1Data map[string
 	}
 	funcLogger = funcLogger.With("vote_response_id", uid)
 
-	// Check if parent vote exists in mappings before proceeding
+	// The parent vote ID is required; the dispatcher parks records until its
+	// mapping exists (see mapping_dependencies.go).
 	if voteResponse.PollID == "" {
 		funcLogger.ErrorContext(ctx, "vote response missing required parent vote ID")
 		return false
 	}
 	funcLogger = funcLogger.With("poll_id", voteResponse.PollID)
-	voteMappingKey := fmt.Sprintf("vote.%s", voteResponse.PollID)
-	if _, err := mappingsKV.Get(ctx, voteMappingKey); err != nil {
-		funcLogger.With(errKey, err).InfoContext(ctx, "parent vote not found in mappings, will retry vote response sync")
-		return true
-	}
 
 	mappingKey := fmt.Sprintf("vote_response.%s", uid)
 	indexerAction := indexerConstants.ActionCreated
@@ -503,7 +505,7 @@ func handleVoteResponseUpdate(ctx context.Context, key string, v1Data map[string
 		return false
 	}
 
-	if err := sendVoteResponseAccessMessage(*voteResponse); err != nil {
+	if err := sendVoteResponseAccessMessage(ctx, *voteResponse); err != nil {
 		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to send vote response access message")
 		return false
 	}
@@ -517,3 +519,15 @@ func handleVoteResponseUpdate(ctx context.Context, key string, v1Data map[string
 	funcLogger.InfoContext(ctx, "successfully sent vote response indexer and access messages")
 	return false
 }
+
+// handleVoteDelete processes a deletion of an itx-poll record.
+// Returns true if the operation should be retried, false otherwise.
+func handleVoteDelete(ctx context.Context, key string, voteID string) bool {
+	return handleIndexedObjectDelete(ctx, key, voteID, "vote", IndexVoteSubject, "vote.%s")
+}
+
+// handleVoteResponseDelete processes a deletion of an itx-poll-vote record.
+// Returns true if the operation should be retried, false otherwise.
+func handleVoteResponseDelete(ctx context.Context, key string, voteResponseID string) bool {
+	return handleIndexedObjectDelete(ctx, key, voteResponseID, "vote_response", IndexVoteResponseSubject, "vote_response.%s")
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	"github.com/teambition/rrule-go"
 )
 
@@ -26,7 +27,7 @@ var typeName = []string{"Daily", "Weekly", "Monthly"}
 
 // calculateOccurrences generates occurrence objects for a meeting, which can optionally include past or cancelled occurrences
 func calculateOccurrences(ctx context.Context, meeting meetingInput, pastOccurrences bool, includeCancelled bool, numOccurrencesToReturn int) (result []ZoomMeetingOccurrence, err error) {
-	timerNow := time.Now()
+	timerNow := bootstrap.Now()
 	// Occurrences only exist for recurring meetings
 	if meeting.Recurrence == nil {
 		return result, nil
@@ -120,7 +121,7 @@ func calculateOccurrences(ctx context.Context, meeting meetingInput, pastOccurre
 		// and the next recurrence start time is before the current time, which means
 		// this whole recurrence pattern must also be in the past and thus can be skipped.
 
-		if nextRecurrenceTimeUnix != 0 && !pastOccurrences && nextRecurrenceTimeUnix < time.Now().Unix() {
+		if nextRecurrenceTimeUnix != 0 && !pastOccurrences && nextRecurrenceTimeUnix < bootstrap.Now().Unix() {
 			continue
 		}
 
@@ -348,7 +349,7 @@ func calculateOccurrences(ctx context.Context, meeting meetingInput, pastOccurre
 }
 
 func isOccurrencePast(startTime time.Time, duration int) bool {
-	return startTime.Add(time.Duration(duration) * time.Minute).Add(meetingEndBuffer).Before(time.Now())
+	return startTime.Add(time.Duration(duration) * time.Minute).Add(meetingEndBuffer).Before(bootstrap.Now())
 }
 
 // timeInLocation returns error if name is invalid or empty.
@@ -0,0 +1,68 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// The service runs two HTTP servers on dedicated muxes, rather than
+// http.DefaultServeMux, so handlers registered by imported packages (such as
+// net/http/pprof) are never exposed:
+//
+//   - the health server, serving the Kubernetes probes (see bootstrap.NewHealthMux)
+//   - the admin server, serving metrics and diagnostics, which can be bound to
+//     a separate interface and protected with basic auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// newAdminMux returns the mux of the admin server, requiring basic auth when
+// admin credentials are configured.
+func newAdminMux() http.Handler {
+	mux := http.NewServeMux()
+
+	// Expose service metrics in the Prometheus text format.
+	mux.HandleFunc("/metrics", metricsHandler)
+
+	// Report JetStream consumer status and message outcomes.
+	mux.HandleFunc("/statusz", statuszHandler)
+
+	// Report the divergences of canary handlers from the current ones.
+	mux.HandleFunc("/canaryz", canaryzHandler)
+
+	// Document the handled object types, from the handler registry.
+	mux.HandleFunc("/admin/object-types", objectTypesHandler)
+
+	// Trace the mapping dependency chain of a v1 record.
+	mux.HandleFunc("/admin/dependencies", dependencyTraceHandler)
+
+	// Dry run a proposed runtime config against recently processed records.
+	mux.HandleFunc("/admin/config-diff", configDiffHandler)
+
+	// Re-run the handlers of v1 records on demand.
+	mux.HandleFunc("/admin/resync", adminResyncHandler)
+
+	// List, pause, resume, and cancel cascade jobs.
+	mux.HandleFunc("/admin/cascade-jobs", adminCascadeJobsHandler)
+
+	if cfg.AdminUsername == "" {
+		return mux
+	}
+	return requireBasicAuth(mux, cfg.AdminUsername, cfg.AdminPassword)
+}
+
+// requireBasicAuth wraps a handler to require the given basic auth credentials.
+func requireBasicAuth(next http.Handler, username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="lfx-v1-sync-helper admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Indexer document IDs. Meeting indexer messages carry no indexing config, so
+// the indexer derived the ID of their documents from their tags, which breaks
+// whenever the tags of an object type change. Each of these messages now
+// carries an explicit document_id: the canonical UID of the object (the UID
+// sent with its deletes), or, with INDEXER_DOCUMENT_ID=namespaced, the UID
+// prefixed with the object type of its subject, e.g. v1_meeting:<id>. Search
+// upserts and deletes of an object thus always address the same document.
+// Messages with an indexing config already set its object ID.
+
+import (
+	"strings"
+)
+
+const (
+	// indexerDocumentIDUID sets the document ID to the canonical UID.
+	indexerDocumentIDUID = "uid"
+
+	// indexerDocumentIDNamespaced sets the document ID to the canonical UID,
+	// prefixed with the object type.
+	indexerDocumentIDNamespaced = "namespaced"
+
+	// indexerDocumentIDNone leaves the document ID out.
+	indexerDocumentIDNone = "none"
+
+	// indexerSubjectPrefix prefixes the indexer subjects, followed by the
+	// object type.
+	indexerSubjectPrefix = "lfx.index."
+)
+
+// documentIdentifier is implemented by indexer payloads with a canonical UID.
+type documentIdentifier interface {
+	documentUID() string
+}
+
+// indexerDocumentID returns the document ID of an indexer message payload,
+// per INDEXER_DOCUMENT_ID, or an empty ID if it has none. Delete payloads are
+// the canonical UID itself.
+func indexerDocumentID(subject string, data any) string {
+	if cfg.IndexerDocumentID == indexerDocumentIDNone {
+		return ""
+	}
+	var uid string
+	switch data := data.(type) {
+	case string:
+		uid = data
+	case documentIdentifier:
+		uid = data.documentUID()
+	}
+	if uid == "" || cfg.IndexerDocumentID != indexerDocumentIDNamespaced {
+		return uid
+	}
+	return strings.TrimPrefix(subject, indexerSubjectPrefix) + ":" + uid
+}
+
+func (m *meetingInput) documentUID() string { return m.ID }
+
+func (r *registrantInput) documentUID() string { return r.UID }
+
+func (r *inviteResponseInput) documentUID() string { return r.ID }
+
+func (m *pastMeetingInput) documentUID() string { return m.ID }
+
+func (p *V2PastMeetingParticipant) documentUID() string { return p.UID }
+
+func (r *pastMeetingRecordingInput) documentUID() string { return r.ID }
+
+func (s *pastMeetingSummaryInput) documentUID() string { return s.ID }
+
+func (c *pastMeetingTranscriptContentInput) documentUID() string { return c.ID }
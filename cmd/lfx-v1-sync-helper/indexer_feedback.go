@@ -0,0 +1,257 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Indexer feedback. Indexer messages are published fire-and-forget, so a
+// document the indexer fails to write goes unnoticed. With
+// INDEXER_RESULT_SUBJECT set, the indexer's results are consumed: each replica
+// keeps the indexer messages it recently sent by correlation ID, and when a
+// result reports a failure for one of them, the message is re-enqueued (with
+// backoff) if the failure is transient, or dead-lettered to
+// indexerDLQSubject with the indexer's error attached otherwise (or once
+// indexerFeedbackAttempts re-enqueues failed). Results are received by every
+// replica, as only the one which sent a message can correlate it.
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	// indexerFeedbackCacheSize bounds the sent indexer messages kept for
+	// correlation with their results.
+	indexerFeedbackCacheSize = 10000
+
+	// indexerFeedbackTTL is how long sent indexer messages are kept for
+	// correlation with their results.
+	indexerFeedbackTTL = 15 * time.Minute
+
+	// indexerFeedbackAttempts is how many times a message failing with a
+	// transient error is re-enqueued before it is dead-lettered.
+	indexerFeedbackAttempts = 3
+
+	// indexerFeedbackBackoff is the delay before the first re-enqueue of a
+	// message, doubled on each attempt.
+	indexerFeedbackBackoff = 2 * time.Second
+
+	// indexerDLQSubject is the subject of indexer messages which failed
+	// indexing persistently.
+	indexerDLQSubject = publishTargetDLQSubjectPrefix + "indexer"
+
+	// indexerErrorHeader is the header of dead-lettered indexer messages
+	// carrying the indexer's error.
+	indexerErrorHeader = "Lfx-Indexer-Error"
+)
+
+var indexerResults = newCounterVec(
+	"v1_sync_helper_indexer_results_total",
+	"Number of indexer results correlated with sent indexer messages, by subject and result (succeeded, requeued, dead_lettered, or dlq_error).",
+	"subject", "result",
+)
+
+// indexerResult is a result published by the indexer for a processed
+// message. The correlation ID is read from the CorrelationIDHeader, or the
+// correlation_id field. The message subject narrows the correlation when the
+// indexer sets it.
+type indexerResult struct {
+	CorrelationID string `json:"correlation_id"`
+	Subject       string `json:"subject"`
+	Success       *bool  `json:"success"`
+	Status        string `json:"status"`
+	Error         string `json:"error"`
+	Transient     bool   `json:"transient"`
+	Retryable     bool   `json:"retryable"`
+}
+
+// failed reports whether the result is a failure.
+func (r indexerResult) failed() bool {
+	if r.Success != nil {
+		return !*r.Success
+	}
+	return r.Error != "" || strings.EqualFold(r.Status, "error") || strings.EqualFold(r.Status, "failed")
+}
+
+// sentIndexerMessage is an indexer message kept for correlation with its
+// result.
+type sentIndexerMessage struct {
+	correlationID string
+	subject       string
+	data          []byte
+	sentAt        time.Time
+	attempts      int
+}
+
+var (
+	// sentIndexerMessagesMu guards sentIndexerMessages and
+	// sentIndexerMessageOrder.
+	sentIndexerMessagesMu sync.Mutex
+	// sentIndexerMessages are the recently sent indexer messages by
+	// correlation ID.
+	sentIndexerMessages = map[string][]*sentIndexerMessage{}
+	// sentIndexerMessageOrder is the sends of indexer messages, oldest first,
+	// for eviction.
+	sentIndexerMessageOrder []sentIndexerMessageSend
+)
+
+// sentIndexerMessageSend is a send of an indexer message. Re-enqueued
+// messages are sent again, so only their last send evicts them.
+type sentIndexerMessageSend struct {
+	sent   *sentIndexerMessage
+	sentAt time.Time
+}
+
+// recordIndexerMessage keeps a sent indexer message for correlation with its
+// result, if indexer feedback is enabled.
+func recordIndexerMessage(ctx context.Context, subject string, data []byte) {
+	correlationID := bootstrap.CorrelationID(ctx)
+	if cfg.IndexerResultSubject == "" || correlationID == "" || contextDryRun(ctx) != nil {
+		return
+	}
+	keepSentIndexerMessage(&sentIndexerMessage{correlationID: correlationID, subject: subject, data: data})
+}
+
+// keepSentIndexerMessage keeps an indexer message just sent for correlation
+// with its result.
+func keepSentIndexerMessage(sent *sentIndexerMessage) {
+	sentIndexerMessagesMu.Lock()
+	defer sentIndexerMessagesMu.Unlock()
+	sent.sentAt = bootstrap.Now()
+	sentIndexerMessages[sent.correlationID] = append(sentIndexerMessages[sent.correlationID], sent)
+	sentIndexerMessageOrder = append(sentIndexerMessageOrder, sentIndexerMessageSend{sent: sent, sentAt: sent.sentAt})
+	evictSentIndexerMessages(sent.sentAt)
+}
+
+// evictSentIndexerMessages forgets the sent indexer messages beyond the cache
+// size or TTL. sentIndexerMessagesMu must be held.
+func evictSentIndexerMessages(now time.Time) {
+	evict := 0
+	for evict < len(sentIndexerMessageOrder) {
+		send := sentIndexerMessageOrder[evict]
+		if len(sentIndexerMessageOrder)-evict <= indexerFeedbackCacheSize && now.Sub(send.sentAt) < indexerFeedbackTTL {
+			break
+		}
+		if send.sent.sentAt.Equal(send.sentAt) {
+			forgetSentIndexerMessage(send.sent)
+		}
+		evict++
+	}
+	sentIndexerMessageOrder = sentIndexerMessageOrder[evict:]
+}
+
+// forgetSentIndexerMessage removes a sent indexer message from its
+// correlation ID. sentIndexerMessagesMu must be held.
+func forgetSentIndexerMessage(sent *sentIndexerMessage) {
+	remaining := sentIndexerMessages[sent.correlationID][:0]
+	for _, other := range sentIndexerMessages[sent.correlationID] {
+		if other != sent {
+			remaining = append(remaining, other)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(sentIndexerMessages, sent.correlationID)
+		return
+	}
+	sentIndexerMessages[sent.correlationID] = remaining
+}
+
+// takeSentIndexerMessages returns and forgets the sent indexer messages of a
+// result.
+func takeSentIndexerMessages(correlationID, subject string) []*sentIndexerMessage {
+	sentIndexerMessagesMu.Lock()
+	defer sentIndexerMessagesMu.Unlock()
+	var taken []*sentIndexerMessage
+	for _, sent := range sentIndexerMessages[correlationID] {
+		if subject == "" || sent.subject == subject {
+			taken = append(taken, sent)
+		}
+	}
+	for _, sent := range taken {
+		forgetSentIndexerMessage(sent)
+	}
+	return taken
+}
+
+// subscribeIndexerResults subscribes every replica to the indexer results,
+// if indexer feedback is enabled.
+func subscribeIndexerResults() error {
+	if cfg.IndexerResultSubject == "" {
+		return nil
+	}
+	_, err := natsConn.Subscribe(cfg.IndexerResultSubject, indexerResultHandler)
+	return err
+}
+
+// indexerResultHandler correlates an indexer result with the sent indexer
+// messages, and re-enqueues or dead-letters the failed ones.
+func indexerResultHandler(msg *nats.Msg) {
+	var result indexerResult
+	if err := json.Unmarshal(msg.Data, &result); err != nil {
+		logger.With(errKey, err, "subject", msg.Subject).Warn("failed to unmarshal indexer result")
+		return
+	}
+	if correlationID := msg.Header.Get(bootstrap.CorrelationIDHeader); correlationID != "" {
+		result.CorrelationID = correlationID
+	}
+	if result.CorrelationID == "" {
+		return
+	}
+	ctx := bootstrap.WithCorrelationID(context.Background(), result.CorrelationID)
+
+	// Messages sent by other replicas are not known here.
+	taken := takeSentIndexerMessages(result.CorrelationID, result.Subject)
+	for _, sent := range taken {
+		if !result.failed() {
+			indexerResults.inc(sent.subject, "succeeded")
+			continue
+		}
+		if (result.Transient || result.Retryable) && sent.attempts < indexerFeedbackAttempts {
+			requeueIndexerMessage(ctx, sent, result.Error)
+			continue
+		}
+		deadLetterIndexerMessage(ctx, sent, result.Error)
+	}
+}
+
+// requeueIndexerMessage publishes a failed indexer message again, after a
+// backoff, and keeps it for correlation with its next result.
+func requeueIndexerMessage(ctx context.Context, sent *sentIndexerMessage, indexerErr string) {
+	delay := indexerFeedbackBackoff << sent.attempts
+	sent.attempts++
+	indexerResults.inc(sent.subject, "requeued")
+	logger.With("subject", sent.subject, "indexer_error", indexerErr, "attempt", sent.attempts, "delay", delay.String()).
+		WarnContext(ctx, "indexer message failed with a transient error, re-enqueuing")
+
+	time.AfterFunc(delay, func() {
+		if err := publishMessage(ctx, sent.subject, sent.data); err != nil {
+			deadLetterIndexerMessage(ctx, sent, err.Error())
+			return
+		}
+		keepSentIndexerMessage(sent)
+	})
+}
+
+// deadLetterIndexerMessage publishes an indexer message which failed
+// indexing persistently to indexerDLQSubject, with the indexer's error.
+func deadLetterIndexerMessage(ctx context.Context, sent *sentIndexerMessage, indexerErr string) {
+	dlqMsg := &nats.Msg{Subject: indexerDLQSubject, Data: sent.data, Header: nats.Header{}}
+	bootstrap.SetCorrelationHeader(ctx, dlqMsg)
+	dlqMsg.Header.Set(publishTargetSubjectHeader, sent.subject)
+	dlqMsg.Header.Set(indexerErrorHeader, indexerErr)
+
+	log := logger.With("subject", sent.subject, "indexer_error", indexerErr, "attempts", sent.attempts)
+	if err := publishDeadLetter(dlqMsg); err != nil {
+		indexerResults.inc(sent.subject, "dlq_error")
+		log.With("dlq_error", err).ErrorContext(ctx, "failed to dead-letter indexer message")
+		return
+	}
+	indexerResults.inc(sent.subject, "dead_lettered")
+	log.WarnContext(ctx, "dead-lettered indexer message which failed indexing")
+}
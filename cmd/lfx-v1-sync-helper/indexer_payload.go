@@ -0,0 +1,188 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Indexer payload size guard. Some meetings carry thousands of occurrences,
+// which makes their indexer message larger than the NATS max payload (or the
+// configured limit), so the publish would fail. Oversize messages are either
+// truncated, dropping occurrences and marking the document as truncated, or
+// stored in a JetStream object store bucket and replaced by a reference.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+// Oversize indexer payload policies.
+const (
+	indexerOversizeTruncate    = "truncate"
+	indexerOversizeObjectStore = "object_store"
+)
+
+// indexerPayloadRefHeader is the header set on indexer messages whose data is
+// a reference to the payload in the object store.
+const indexerPayloadRefHeader = "x-payload-ref"
+
+var oversizeIndexerPayloads = newCounterVec(
+	"v1_sync_helper_indexer_oversize_payloads_total",
+	"Number of indexer messages over the payload size limit, by subject and policy applied.",
+	"subject", "policy",
+)
+
+// occurrenceTruncater is implemented by indexer payloads whose occurrences
+// can be dropped to fit the payload size limit.
+type occurrenceTruncater interface {
+	// occurrenceCount returns the number of occurrences.
+	occurrenceCount() int
+	// withOccurrences returns a copy of the payload keeping only keep
+	// occurrences, marked as truncated.
+	withOccurrences(keep int) any
+}
+
+// indexerPayloadRef is the data of an indexer message whose payload is stored
+// in the object store.
+type indexerPayloadRef struct {
+	Bucket string `json:"bucket"`
+	Object string `json:"object"`
+	Size   int    `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// indexerPayloadLimit returns the maximum size of an indexer message.
+func indexerPayloadLimit() int {
+	if cfg.IndexerMaxPayloadBytes > 0 {
+		return cfg.IndexerMaxPayloadBytes
+	}
+	return int(natsConn.MaxPayload())
+}
+
+// encodeIndexerMessage marshals an indexer message, applying the oversize
+// policy when it is over the payload size limit.
+func encodeIndexerMessage(ctx context.Context, subject string, message MeetingIndexerMessage) ([]byte, error) {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal indexer message for subject %s: %w", subject, err)
+	}
+
+	limit := indexerPayloadLimit()
+	if limit <= 0 || len(messageBytes) <= limit {
+		return messageBytes, nil
+	}
+
+	oversizeIndexerPayloads.inc(subject, cfg.IndexerOversizePolicy)
+	logger.With("subject", subject, "size", len(messageBytes), "max_size", limit, "policy", cfg.IndexerOversizePolicy).WarnContext(ctx, "indexer message over the payload size limit")
+
+	// Dry runs never write to the object store, so their oversize payloads
+	// are truncated.
+	if cfg.IndexerOversizePolicy == indexerOversizeObjectStore && overlayRecorder(ctx) == nil {
+		return storeIndexerPayload(ctx, subject, message, messageBytes)
+	}
+	return truncateIndexerPayload(ctx, subject, message, len(messageBytes), limit)
+}
+
+// truncateIndexerPayload keeps as many occurrences of the payload as fit the
+// limit.
+func truncateIndexerPayload(ctx context.Context, subject string, message MeetingIndexerMessage, size, limit int) ([]byte, error) {
+	truncater, ok := message.Data.(occurrenceTruncater)
+	if !ok || truncater.occurrenceCount() == 0 {
+		return nil, fmt.Errorf("indexer message for subject %s is %d bytes, over the %d bytes limit, and cannot be truncated", subject, size, limit)
+	}
+
+	// Binary search for the largest number of occurrences which fits.
+	total := truncater.occurrenceCount()
+	var fitting []byte
+	kept := -1
+	for low, high := 0, total-1; low <= high; {
+		keep := (low + high) / 2
+		message.Data = truncater.withOccurrences(keep)
+		messageBytes, err := json.Marshal(message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal truncated indexer message for subject %s: %w", subject, err)
+		}
+		if len(messageBytes) <= limit {
+			fitting, kept = messageBytes, keep
+			low = keep + 1
+		} else {
+			high = keep - 1
+		}
+	}
+	if kept < 0 {
+		return nil, fmt.Errorf("indexer message for subject %s is over the %d bytes limit even without occurrences", subject, limit)
+	}
+
+	logger.With("subject", subject, "occurrences_kept", kept, "occurrences_total", total).WarnContext(ctx, "truncated occurrences of oversize indexer message")
+	return fitting, nil
+}
+
+// storeIndexerPayload stores the full indexer message in the object store,
+// and returns a message referencing it.
+func storeIndexerPayload(ctx context.Context, subject string, message MeetingIndexerMessage, messageBytes []byte) ([]byte, error) {
+	store, err := jsContext.ObjectStore(ctx, cfg.IndexerPayloadBucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access indexer payload bucket %s: %w", cfg.IndexerPayloadBucket, err)
+	}
+
+	object := fmt.Sprintf("%s.%s", subject, bootstrap.NewID())
+	info, err := store.PutBytes(ctx, object, messageBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store indexer payload %s: %w", object, err)
+	}
+
+	headers := make(map[string]string, len(message.Headers)+1)
+	for k, v := range message.Headers {
+		headers[k] = v
+	}
+	headers[indexerPayloadRefHeader] = cfg.IndexerPayloadBucket + "/" + object
+
+	refBytes, err := json.Marshal(MeetingIndexerMessage{
+		Action:  message.Action,
+		Headers: headers,
+		Data: indexerPayloadRef{
+			Bucket: cfg.IndexerPayloadBucket,
+			Object: object,
+			Size:   len(messageBytes),
+			Digest: info.Digest,
+		},
+		Tags:       message.Tags,
+		DocumentID: message.DocumentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal indexer payload reference for subject %s: %w", subject, err)
+	}
+
+	logger.With("subject", subject, "bucket", cfg.IndexerPayloadBucket, "object", object).DebugContext(ctx, "stored oversize indexer payload")
+	return refBytes, nil
+}
+
+// occurrenceCount returns the number of occurrences of the meeting.
+func (m *meetingInput) occurrenceCount() int {
+	return len(m.Occurrences)
+}
+
+// withOccurrences returns a copy of the meeting keeping keep consecutive
+// occurrences, starting from the first one which has not ended (or the last
+// keep occurrences, when fewer remain), so upcoming occurrences are kept.
+func (m *meetingInput) withOccurrences(keep int) any {
+	now := bootstrap.Now()
+	start := len(m.Occurrences)
+	for i, occurrence := range m.Occurrences {
+		startTime, err := time.Parse(time.RFC3339, occurrence.StartTime)
+		if err != nil || !startTime.Add(time.Duration(occurrence.Duration)*time.Minute).Before(now) {
+			start = i
+			break
+		}
+	}
+	start = min(start, len(m.Occurrences)-keep)
+
+	truncated := *m
+	truncated.Occurrences = m.Occurrences[start : start+keep]
+	truncated.OccurrencesTruncated = true
+	truncated.OccurrencesTotal = len(m.Occurrences)
+	return &truncated
+}
@@ -0,0 +1,151 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Indexer payload redaction. Meeting payloads carry Zoom host keys, join page
+// passwords, and passcodes, which must not be readable from the index. Known
+// sensitive fields are cleared from indexer payloads before publishing,
+// unless listed in INDEXER_REDACTION_ALLOWLIST for environments which need
+// them. The meeting join URL embeds the join page password as its password
+// query parameter, which is removed along with the password field.
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// Sensitive indexer payload fields, named by their JSON path.
+const (
+	redactedHostKey           = "host_key"
+	redactedPassword          = "password"
+	redactedPasscode          = "zoom_config.passcode"
+	redactedRecordingPassword = "recording_password"
+	redactedSessionPassword   = "sessions.password"
+)
+
+// redactableFields are the sensitive fields which can be allowlisted.
+var redactableFields = []string{
+	redactedHostKey,
+	redactedPassword,
+	redactedPasscode,
+	redactedRecordingPassword,
+	redactedSessionPassword,
+}
+
+var redactedIndexerFields = newCounterVec(
+	"v1_sync_helper_indexer_redacted_fields_total",
+	"Number of sensitive field values redacted from indexer messages, by subject and field.",
+	"subject", "field",
+)
+
+// sensitiveFieldRedacter is implemented by indexer payloads with sensitive
+// fields.
+type sensitiveFieldRedacter interface {
+	// redactSensitiveFields clears the set sensitive fields for which redact
+	// returns true, and returns the cleared fields.
+	redactSensitiveFields(redact func(field string) bool) []string
+}
+
+// validateRedactionAllowlist checks that INDEXER_REDACTION_ALLOWLIST only
+// lists known sensitive fields.
+func validateRedactionAllowlist(allowlist []string) error {
+	for _, field := range allowlist {
+		if !slices.Contains(redactableFields, field) {
+			return fmt.Errorf("INDEXER_REDACTION_ALLOWLIST field %q is not one of %v", field, redactableFields)
+		}
+	}
+	return nil
+}
+
+// shouldRedact reports whether a sensitive field is redacted from indexer
+// payloads.
+func shouldRedact(field string) bool {
+	return !slices.Contains(cfg.IndexerRedactionAllowlist, field)
+}
+
+// applyIndexerRedaction clears the sensitive fields of an indexer payload
+// which are not allowlisted.
+func applyIndexerRedaction(subject string, data any) {
+	redacter, ok := data.(sensitiveFieldRedacter)
+	if !ok {
+		return
+	}
+	for _, field := range redacter.redactSensitiveFields(shouldRedact) {
+		redactedIndexerFields.inc(subject, field)
+	}
+}
+
+// redactString clears a sensitive string field if it is set and redacted,
+// recording it in redacted.
+func redactString(value *string, field string, redact func(field string) bool, redacted *[]string) {
+	if *value == "" || !redact(field) {
+		return
+	}
+	*value = ""
+	if !slices.Contains(*redacted, field) {
+		*redacted = append(*redacted, field)
+	}
+}
+
+// redactURLParam removes a sensitive query parameter from a URL field if it is
+// set and redacted, recording it in redacted. URLs which cannot be parsed are
+// cleared.
+func redactURLParam(value *string, param, field string, redact func(field string) bool, redacted *[]string) {
+	if *value == "" || !redact(field) {
+		return
+	}
+	u, err := url.Parse(*value)
+	if err != nil {
+		redactString(value, field, redact, redacted)
+		return
+	}
+	query := u.Query()
+	if !query.Has(param) {
+		return
+	}
+	query.Del(param)
+	u.RawQuery = query.Encode()
+	*value = u.String()
+	if !slices.Contains(*redacted, field) {
+		*redacted = append(*redacted, field)
+	}
+}
+
+// redactSensitiveFields implements sensitiveFieldRedacter.
+func (m *meetingInput) redactSensitiveFields(redact func(field string) bool) []string {
+	var redacted []string
+	redactString(&m.HostKey, redactedHostKey, redact, &redacted)
+	redactString(&m.Password, redactedPassword, redact, &redacted)
+	redactURLParam(&m.JoinURL, "password", redactedPassword, redact, &redacted)
+	redactString(&m.ZoomConfig.Passcode, redactedPasscode, redact, &redacted)
+	return redacted
+}
+
+// redactSensitiveFields implements sensitiveFieldRedacter.
+func (p *pastMeetingInput) redactSensitiveFields(redact func(field string) bool) []string {
+	var redacted []string
+	redactString(&p.RecordingPassword, redactedRecordingPassword, redact, &redacted)
+	if p.ZoomConfig != nil {
+		redactString(&p.ZoomConfig.Passcode, redactedPasscode, redact, &redacted)
+	}
+	return redacted
+}
+
+// redactSensitiveFields implements sensitiveFieldRedacter.
+func (r *pastMeetingRecordingInput) redactSensitiveFields(redact func(field string) bool) []string {
+	var redacted []string
+	for i := range r.Sessions {
+		redactString(&r.Sessions[i].Password, redactedSessionPassword, redact, &redacted)
+	}
+	return redacted
+}
+
+// redactSensitiveFields implements sensitiveFieldRedacter.
+func (s *pastMeetingSummaryInput) redactSensitiveFields(redact func(field string) bool) []string {
+	var redacted []string
+	redactString(&s.Password, redactedPassword, redact, &redacted)
+	return redacted
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -29,6 +30,10 @@ type DynamoDBStreamEvent struct {
 	Keys                    map[string]interface{} `json:"keys,omitempty"`
 	NewImage                map[string]interface{} `json:"new_image,omitempty"`
 	OldImage                map[string]interface{} `json:"old_image,omitempty"`
+	// ChangedFields lists the attributes changed by a MODIFY event. It is nil
+	// when not computed (other events, or older publishers), and empty when
+	// the item was rewritten unchanged.
+	ChangedFields []string `json:"changed_fields"`
 }
 
 // IsValid returns true when the event has enough information to be actionable.
@@ -42,7 +47,8 @@ func (e *DynamoDBStreamEvent) IsValid() bool {
 // The KV key format is "{tableName}.{keyValue}", matching the prefix convention
 // used by the existing kvHandler dispatch chain.
 func dynamodbIngestHandler(msg jetstream.Msg) {
-	ctx := context.Background()
+	ctx := bootstrap.MessageContext(context.Background(), msg.Headers())
+	started := time.Now()
 	subject := msg.Subject()
 
 	logger.With("subject", subject).DebugContext(ctx, "received DynamoDB stream message")
@@ -50,17 +56,13 @@ func dynamodbIngestHandler(msg jetstream.Msg) {
 	var event DynamoDBStreamEvent
 	if err := json.Unmarshal(msg.Data(), &event); err != nil {
 		logger.With(errKey, err, "subject", subject).ErrorContext(ctx, "failed to unmarshal DynamoDB stream event")
-		if ackErr := msg.Ack(); ackErr != nil {
-			logger.With(errKey, ackErr, "subject", subject).Error("failed to ack invalid DynamoDB message")
-		}
+		settleMessage(ctx, msg, dynamodbConsumerName, "unknown", false, 0, started)
 		return
 	}
 
 	if !event.IsValid() {
 		logger.With("subject", subject, "event", event).WarnContext(ctx, "invalid DynamoDB stream event, missing required fields")
-		if ackErr := msg.Ack(); ackErr != nil {
-			logger.With(errKey, ackErr, "subject", subject).Error("failed to ack invalid DynamoDB message")
-		}
+		settleMessage(ctx, msg, dynamodbConsumerName, "unknown", false, 0, started)
 		return
 	}
 
@@ -70,6 +72,8 @@ func dynamodbIngestHandler(msg jetstream.Msg) {
 		"table", event.TableName,
 	).DebugContext(ctx, "processing DynamoDB stream event")
 
+	ctx, cancel := withHandlerDeadline(ctx, dynamodbConsumerName, event.TableName)
+	defer cancel()
 	var shouldRetry bool
 	switch strings.ToUpper(event.EventName) {
 	case "INSERT", "MODIFY":
@@ -79,16 +83,11 @@ func dynamodbIngestHandler(msg jetstream.Msg) {
 	default:
 		logger.With("event_name", event.EventName, "table", event.TableName).WarnContext(ctx, "unknown DynamoDB event name, ignoring")
 	}
-
-	if shouldRetry {
-		if err := msg.Nak(); err != nil {
-			logger.With(errKey, err, "subject", subject).Error("failed to NAK DynamoDB message for retry")
-		}
-	} else {
-		if err := msg.Ack(); err != nil {
-			logger.With(errKey, err, "subject", subject).Error("failed to ack DynamoDB message")
-		}
+	if handlerDeadlineExceeded(ctx, dynamodbConsumerName, event.TableName) {
+		shouldRetry = true
 	}
+
+	settleMessage(ctx, msg, dynamodbConsumerName, event.TableName, shouldRetry, 0, started)
 }
 
 // handleDynamoDBUpsert writes the new image from an INSERT or MODIFY event into the
@@ -104,6 +103,13 @@ func handleDynamoDBUpsert(ctx context.Context, event *DynamoDBStreamEvent) bool
 	}
 
 	key := dynamodbKVKey(event.TableName, event.Keys)
+	recordID := dynamodbRecordID(event.Keys)
+
+	// Skip records recently written by the authoritative source of their
+	// object type.
+	if suppressIngest(ctx, ingestSourceDynamoDB, event.TableName, recordID) {
+		return false
+	}
 
 	existing, err := v1KV.Get(ctx, key)
 	if err != nil && err != jetstream.ErrKeyNotFound {
@@ -114,11 +120,24 @@ func handleDynamoDBUpsert(ctx context.Context, event *DynamoDBStreamEvent) bool
 	var lastRevision uint64
 
 	if err == nil {
+		// A MODIFY rewriting the item unchanged needs no write, as long as
+		// the item is already stored.
+		if event.ChangedFields != nil && len(event.ChangedFields) == 0 {
+			logger.With("key", key).DebugContext(ctx, "skipping DynamoDB upsert – no fields changed")
+			return false
+		}
+
 		lastRevision = existing.Revision()
 
+		existingValue, decompressErr := decompressV1Value(key, existing.Value())
+		if decompressErr != nil {
+			logger.With(errKey, decompressErr, "key", key).ErrorContext(ctx, "failed to decompress existing KV entry")
+			return false
+		}
+
 		var existingData map[string]interface{}
-		if unmarshalErr := json.Unmarshal(existing.Value(), &existingData); unmarshalErr != nil {
-			if msgpackErr := msgpack.Unmarshal(existing.Value(), &existingData); msgpackErr != nil {
+		if unmarshalErr := json.Unmarshal(existingValue, &existingData); unmarshalErr != nil {
+			if msgpackErr := msgpack.Unmarshal(existingValue, &existingData); msgpackErr != nil {
 				logger.With(errKey, unmarshalErr, "msgpack_error", msgpackErr, "key", key).
 					ErrorContext(ctx, "failed to unmarshal existing KV entry")
 				return false
@@ -164,9 +183,10 @@ func handleDynamoDBUpsert(ctx context.Context, event *DynamoDBStreamEvent) bool
 				ErrorContext(ctx, "failed to update KV entry from DynamoDB event")
 			return false
 		}
-		logger.With("key", key, "event_name", event.EventName, "revision", lastRevision, "encoding", getEncodingFormat()).
+		logger.With("key", key, "event_name", event.EventName, "revision", lastRevision, "encoding", getEncodingFormat(), "changed_fields", event.ChangedFields).
 			InfoContext(ctx, "updated KV entry from DynamoDB event")
 	}
+	markIngestSource(ctx, ingestSourceDynamoDB, event.TableName, recordID)
 
 	return false
 }
@@ -179,6 +199,13 @@ func handleDynamoDBUpsert(ctx context.Context, event *DynamoDBStreamEvent) bool
 // Returns true only on an error that warrants a retry.
 func handleDynamoDBRemove(ctx context.Context, event *DynamoDBStreamEvent) bool {
 	key := dynamodbKVKey(event.TableName, event.Keys)
+	recordID := dynamodbRecordID(event.Keys)
+
+	// Skip records recently written by the authoritative source of their
+	// object type.
+	if suppressIngest(ctx, ingestSourceDynamoDB, event.TableName, recordID) {
+		return false
+	}
 
 	// If no old image is available, we can't create a soft delete record.
 	// This shouldn't happen in practice since DynamoDB streams are configured to include old images.
@@ -190,7 +217,7 @@ func handleDynamoDBRemove(ctx context.Context, event *DynamoDBStreamEvent) bool
 	// Add Singer-compatible metadata to mark this as deleted.
 	event.OldImage["_sdc_deleted_at"] = event.ApproximateCreationTime.Format(time.RFC3339)
 	event.OldImage["_sdc_extracted_at"] = event.ApproximateCreationTime.Format(time.RFC3339)
-	event.OldImage["_sdc_received_at"] = time.Now().UTC().Format(time.RFC3339)
+	event.OldImage["_sdc_received_at"] = bootstrap.Now().UTC().Format(time.RFC3339)
 
 	// Encode the data with the deletion marker.
 	var dataBytes []byte
@@ -235,6 +262,7 @@ func handleDynamoDBRemove(ctx context.Context, event *DynamoDBStreamEvent) bool
 		}
 		logger.With("key", key, "revision", existing.Revision(), "encoding", getEncodingFormat()).InfoContext(ctx, "updated KV entry with deletion marker from DynamoDB REMOVE event")
 	}
+	markIngestSource(ctx, ingestSourceDynamoDB, event.TableName, recordID)
 
 	return false
 }
@@ -244,6 +272,12 @@ func handleDynamoDBRemove(ctx context.Context, event *DynamoDBStreamEvent) bool
 // For composite primary keys the values are sorted by attribute name and joined
 // with "#" to produce a deterministic identifier, e.g. "my-table.pk-val#sk-val".
 func dynamodbKVKey(tableName string, keys map[string]interface{}) string {
+	return tableName + "." + dynamodbRecordID(keys)
+}
+
+// dynamodbRecordID returns the record ID of a DynamoDB item, the key values
+// sorted by attribute name and joined with "#".
+func dynamodbRecordID(keys map[string]interface{}) string {
 	attrNames := make([]string, 0, len(keys))
 	for k := range keys {
 		attrNames = append(attrNames, k)
@@ -255,7 +289,7 @@ func dynamodbKVKey(tableName string, keys map[string]interface{}) string {
 		parts = append(parts, fmt.Sprintf("%v", keys[k]))
 	}
 
-	return tableName + "." + strings.Join(parts, "#")
+	return strings.Join(parts, "#")
 }
 
 // shouldDynamoDBUpdate returns true when the incoming new image should overwrite
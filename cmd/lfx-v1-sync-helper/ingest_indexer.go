@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	nats "github.com/nats-io/nats.go"
 )
 
@@ -32,7 +33,7 @@ type indexingEventBody struct {
 // committeeIndexerEventHandler handles lfx.committee.{created,updated,deleted} events
 // published by the indexer service after successful OpenSearch writes.
 func committeeIndexerEventHandler(msg *nats.Msg) {
-	ctx := context.Background()
+	ctx := bootstrap.MessageContext(context.Background(), msg.Header)
 
 	var event indexingEvent
 	if err := json.Unmarshal(msg.Data, &event); err != nil {
@@ -119,7 +120,7 @@ func committeeIndexerEventHandler(msg *nats.Msg) {
 // committeeMemberIndexerEventHandler handles lfx.committee_member.{created,updated,deleted} events
 // published by the indexer service after successful OpenSearch writes.
 func committeeMemberIndexerEventHandler(msg *nats.Msg) {
-	ctx := context.Background()
+	ctx := bootstrap.MessageContext(context.Background(), msg.Header)
 
 	var event indexingEvent
 	if err := json.Unmarshal(msg.Data, &event); err != nil {
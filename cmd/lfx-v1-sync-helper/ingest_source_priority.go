@@ -0,0 +1,206 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Ingest source priority. When both the WAL listener and the DynamoDB stream
+// consumer feed the same logical data (e.g. during a migration, or while a
+// DynamoDB backfill replays records already streamed from PostgreSQL), each
+// change is written to v1-objects twice, and synced downstream twice.
+// INGEST_SOURCE_PRIORITY makes one source authoritative for an object type:
+// every record written by the authoritative source is marked in the mappings
+// bucket, by record ID, and events of the other source for the same record
+// are suppressed (acknowledged without being written) for the window that
+// follows. Records the authoritative source has not written recently are
+// still written by the other source, so it keeps filling the gaps.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// ingestSourceWAL is the WAL listener ingest source.
+	ingestSourceWAL = "wal"
+
+	// ingestSourceDynamoDB is the DynamoDB stream ingest source.
+	ingestSourceDynamoDB = "dynamodb"
+
+	// defaultIngestSuppressionWindow is how long the other source is
+	// suppressed after the authoritative source wrote a record by default.
+	defaultIngestSuppressionWindow = 5 * time.Minute
+
+	// ingestSourceKeyPrefix prefixes the mappings KV keys of the records
+	// written by an authoritative source, followed by the object type and the
+	// record ID.
+	ingestSourceKeyPrefix = "v1_sync_helper_ingest_source."
+)
+
+var ingestSuppressed = newCounterVec(
+	"v1_sync_helper_ingest_suppressed_total",
+	"Number of ingest events suppressed because the authoritative source of their object type wrote the record within the suppression window, by object type and suppressed source.",
+	"object_type", "source",
+)
+
+// ingestSourcePriority is the authoritative ingest source of an object type.
+type ingestSourcePriority struct {
+	// Source is the authoritative source, "wal" or "dynamodb".
+	Source string
+	// Window is how long the other source is suppressed after the
+	// authoritative source wrote a record.
+	Window time.Duration
+	// Prefixes are the v1 key prefixes written by each source.
+	Prefixes map[string]string
+}
+
+// ingestSourcePriorityJSON is the INGEST_SOURCE_PRIORITY schema of the
+// priority of an object type.
+type ingestSourcePriorityJSON struct {
+	Source         string `json:"source"`
+	Window         string `json:"window"`
+	WALPrefix      string `json:"wal_prefix"`
+	DynamoDBPrefix string `json:"dynamodb_prefix"`
+}
+
+// ingestSourceMark is the mappings KV value of a record written by an
+// authoritative source.
+type ingestSourceMark struct {
+	Source    string    `json:"source"`
+	WrittenAt time.Time `json:"written_at"`
+}
+
+// parseIngestSourcePriorities parses INGEST_SOURCE_PRIORITY, a JSON object of
+// the authoritative source by object type, e.g.
+// {"community": {"source": "wal", "window": "10m", "dynamodb_prefix": "community-members"}}.
+// The v1 key prefix written by a source defaults to the object type, which is
+// "{schema}-{table}" for the WAL listener and the table name for DynamoDB.
+func parseIngestSourcePriorities(value string) (map[string]ingestSourcePriority, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var parsed map[string]ingestSourcePriorityJSON
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("INGEST_SOURCE_PRIORITY must be a JSON object of priorities by object type: %w", err)
+	}
+
+	priorities := make(map[string]ingestSourcePriority, len(parsed))
+	claimed := map[string]string{}
+	for objectType, settings := range parsed {
+		if objectType == "" || strings.ContainsAny(objectType, ".*> \t") {
+			return nil, fmt.Errorf("INGEST_SOURCE_PRIORITY object type %q is invalid", objectType)
+		}
+		if settings.Source != ingestSourceWAL && settings.Source != ingestSourceDynamoDB {
+			return nil, fmt.Errorf("INGEST_SOURCE_PRIORITY source of %s must be %q or %q", objectType, ingestSourceWAL, ingestSourceDynamoDB)
+		}
+		window := defaultIngestSuppressionWindow
+		if settings.Window != "" {
+			var err error
+			window, err = time.ParseDuration(settings.Window)
+			if err != nil || window <= 0 {
+				return nil, fmt.Errorf("INGEST_SOURCE_PRIORITY window of %s must be a positive duration", objectType)
+			}
+		}
+		prefixes := map[string]string{
+			ingestSourceWAL:      objectType,
+			ingestSourceDynamoDB: objectType,
+		}
+		if settings.WALPrefix != "" {
+			prefixes[ingestSourceWAL] = settings.WALPrefix
+		}
+		if settings.DynamoDBPrefix != "" {
+			prefixes[ingestSourceDynamoDB] = settings.DynamoDBPrefix
+		}
+		for source, prefix := range prefixes {
+			if strings.ContainsAny(prefix, ".*> \t") {
+				return nil, fmt.Errorf("INGEST_SOURCE_PRIORITY %s prefix %q of %s is invalid", source, prefix, objectType)
+			}
+			if other, ok := claimed[source+"/"+prefix]; ok {
+				return nil, fmt.Errorf("INGEST_SOURCE_PRIORITY %s prefix %q is used by both %s and %s", source, prefix, other, objectType)
+			}
+			claimed[source+"/"+prefix] = objectType
+		}
+		priorities[objectType] = ingestSourcePriority{
+			Source:   settings.Source,
+			Window:   window,
+			Prefixes: prefixes,
+		}
+	}
+	return priorities, nil
+}
+
+// ingestObjectType returns the object type with a source priority whose
+// records a source writes under a v1 key prefix.
+func ingestObjectType(source, prefix string) (string, ingestSourcePriority, bool) {
+	for objectType, priority := range cfg.IngestSourcePriorities {
+		if priority.Prefixes[source] == prefix {
+			return objectType, priority, true
+		}
+	}
+	return "", ingestSourcePriority{}, false
+}
+
+// ingestSourceKey returns the mappings KV key marking a record written by the
+// authoritative source of its object type.
+func ingestSourceKey(objectType, recordID string) string {
+	return ingestSourceKeyPrefix + objectType + "." + recordID
+}
+
+// suppressIngest reports whether an event of a source for a record is
+// suppressed, because its object type has another authoritative source,
+// which wrote the record within the suppression window. Marks which cannot be
+// read do not suppress the event, so it is written rather than lost.
+func suppressIngest(ctx context.Context, source, prefix, recordID string) bool {
+	objectType, priority, ok := ingestObjectType(source, prefix)
+	if !ok || priority.Source == source {
+		return false
+	}
+
+	entry, err := mappingsKV.Get(ctx, ingestSourceKey(objectType, recordID))
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			logger.With(errKey, err, "object_type", objectType, "record_id", recordID).WarnContext(ctx, "failed to get ingest source mark")
+		}
+		return false
+	}
+	var mark ingestSourceMark
+	if err := json.Unmarshal(entry.Value(), &mark); err != nil || mark.Source != priority.Source {
+		return false
+	}
+	if bootstrap.Now().Sub(mark.WrittenAt) >= priority.Window {
+		return false
+	}
+
+	ingestSuppressed.inc(objectType, source)
+	logger.With(
+		"object_type", objectType,
+		"record_id", recordID,
+		"source", source,
+		"authoritative_source", priority.Source,
+		"written_at", mark.WrittenAt,
+	).DebugContext(ctx, "suppressing ingest event written by the authoritative source within the window")
+	return true
+}
+
+// markIngestSource marks a record written by a source, if the source is
+// authoritative for its object type.
+func markIngestSource(ctx context.Context, source, prefix, recordID string) {
+	objectType, priority, ok := ingestObjectType(source, prefix)
+	if !ok || priority.Source != source {
+		return
+	}
+	value, err := json.Marshal(ingestSourceMark{Source: source, WrittenAt: bootstrap.Now().UTC()})
+	if err != nil {
+		return
+	}
+	if _, err := mappingsKV.Put(ctx, ingestSourceKey(objectType, recordID), value); err != nil {
+		logger.With(errKey, err, "object_type", objectType, "record_id", recordID).WarnContext(ctx, "failed to mark ingest source")
+	}
+}
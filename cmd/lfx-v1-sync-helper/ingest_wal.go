@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -85,7 +86,8 @@ func (w *WALEvent) GetSFID() (string, bool) {
 // synchronization of PostgreSQL changes to the KV store for downstream consumption.
 // Handles ACK/NAK logic internally based on retry conditions.
 func walIngestHandler(msg jetstream.Msg) {
-	ctx := context.Background()
+	ctx := bootstrap.MessageContext(context.Background(), msg.Headers())
+	started := time.Now()
 
 	subject := msg.Subject()
 	logger.With("subject", subject).DebugContext(ctx, "received WAL listener message")
@@ -94,18 +96,14 @@ func walIngestHandler(msg jetstream.Msg) {
 	var walEvent WALEvent
 	if err := json.Unmarshal(msg.Data(), &walEvent); err != nil {
 		logger.With(errKey, err, "subject", subject).ErrorContext(ctx, "failed to unmarshal WAL event")
-		if ackErr := msg.Ack(); ackErr != nil {
-			logger.With(errKey, ackErr, "subject", subject).Error("failed to acknowledge WAL JetStream message")
-		}
+		settleMessage(ctx, msg, walConsumerName, "unknown", false, 0, started)
 		return
 	}
 
 	// Validate the WAL event.
 	if !walEvent.IsValid() {
 		logger.With("subject", subject, "event", walEvent).WarnContext(ctx, "invalid WAL event, missing required fields")
-		if ackErr := msg.Ack(); ackErr != nil {
-			logger.With(errKey, ackErr, "subject", subject).Error("failed to acknowledge WAL JetStream message")
-		}
+		settleMessage(ctx, msg, walConsumerName, "unknown", false, 0, started)
 		return
 	}
 
@@ -118,6 +116,8 @@ func walIngestHandler(msg jetstream.Msg) {
 	).DebugContext(ctx, "processing WAL event")
 
 	// Handle different actions using typed constants.
+	ctx, cancel := withHandlerDeadline(ctx, walConsumerName, walEvent.Table)
+	defer cancel()
 	var shouldRetry bool
 	switch walEvent.ActionKind() {
 	case ActionInsert, ActionUpdate:
@@ -132,20 +132,12 @@ func walIngestHandler(msg jetstream.Msg) {
 		shouldRetry = false
 	}
 
-	// Handle message acknowledgment based on retry decision.
-	if shouldRetry {
-		// NAK the message to trigger retry.
-		if err := msg.Nak(); err != nil {
-			logger.With(errKey, err, "subject", subject).Error("failed to NAK WAL JetStream message for retry")
-		} else {
-			logger.With("subject", subject).Debug("NAKed WAL message for retry")
-		}
-	} else {
-		// Acknowledge the message.
-		if err := msg.Ack(); err != nil {
-			logger.With(errKey, err, "subject", subject).Error("failed to acknowledge WAL JetStream message")
-		}
+	if handlerDeadlineExceeded(ctx, walConsumerName, walEvent.Table) {
+		shouldRetry = true
 	}
+
+	// Handle message acknowledgment based on retry decision.
+	settleMessage(ctx, msg, walConsumerName, walEvent.Table, shouldRetry, 0, started)
 }
 
 // handleWALUpsert processes INSERT and UPDATE WAL events by upserting to v1-objects KV bucket.
@@ -167,6 +159,12 @@ func handleWALUpsert(ctx context.Context, walEvent *WALEvent) bool {
 	keyPrefix := fmt.Sprintf("%s-%s", walEvent.Schema, walEvent.Table)
 	key := fmt.Sprintf("%s.%s", keyPrefix, sfid)
 
+	// Skip records recently written by the authoritative source of their
+	// object type.
+	if suppressIngest(ctx, ingestSourceWAL, keyPrefix, sfid) {
+		return false
+	}
+
 	// Check if the key already exists in the KV bucket.
 	existing, err := v1KV.Get(ctx, key)
 	if err != nil && err != jetstream.ErrKeyNotFound {
@@ -184,11 +182,16 @@ func handleWALUpsert(ctx context.Context, walEvent *WALEvent) bool {
 		// Key exists, check if we should update.
 		lastRevision = existing.Revision()
 
-		// Parse existing data.
+		// Parse existing data, decompressing gzip-compressed values.
+		existingValue, decompressErr := decompressV1Value(key, existing.Value())
+		if decompressErr != nil {
+			logger.With(errKey, decompressErr, "key", key).ErrorContext(ctx, "failed to decompress existing KV entry data")
+			return false
+		}
 		var existingData map[string]interface{}
-		if unmarshalErr := json.Unmarshal(existing.Value(), &existingData); unmarshalErr != nil {
+		if unmarshalErr := json.Unmarshal(existingValue, &existingData); unmarshalErr != nil {
 			// Try msgpack if JSON fails.
-			if msgpackErr := msgpack.Unmarshal(existing.Value(), &existingData); msgpackErr != nil {
+			if msgpackErr := msgpack.Unmarshal(existingValue, &existingData); msgpackErr != nil {
 				logger.With(errKey, unmarshalErr, "msgpack_error", msgpackErr, "key", key).ErrorContext(ctx, "failed to unmarshal existing KV entry data")
 				return false
 			}
@@ -207,7 +210,7 @@ func handleWALUpsert(ctx context.Context, walEvent *WALEvent) bool {
 	if shouldUpdate {
 		// Add metadata fields.
 		walEvent.Data["_sdc_extracted_at"] = walEvent.CommitTime
-		walEvent.Data["_sdc_received_at"] = time.Now().UTC().Format(time.RFC3339)
+		walEvent.Data["_sdc_received_at"] = bootstrap.Now().UTC().Format(time.RFC3339)
 
 		// Encode the data using configured format (JSON or MessagePack).
 		var dataBytes []byte
@@ -247,6 +250,7 @@ func handleWALUpsert(ctx context.Context, walEvent *WALEvent) bool {
 			}
 			logger.With("key", key, "action", walEvent.Action, "revision", lastRevision, "encoding", getEncodingFormat()).InfoContext(ctx, "updated KV entry from WAL event")
 		}
+		markIngestSource(ctx, ingestSourceWAL, keyPrefix, sfid)
 	} else {
 		logger.With("key", key, "action", walEvent.Action).DebugContext(ctx, "skipping WAL upsert - existing data is newer or same")
 	}
@@ -272,6 +276,12 @@ func handleWALDelete(ctx context.Context, walEvent *WALEvent) bool {
 	keyPrefix := fmt.Sprintf("%s-%s", walEvent.Schema, walEvent.Table)
 	key := fmt.Sprintf("%s.%s", keyPrefix, sfid)
 
+	// Skip records recently written by the authoritative source of their
+	// object type.
+	if suppressIngest(ctx, ingestSourceWAL, keyPrefix, sfid) {
+		return false
+	}
+
 	// Check if the key exists in the KV bucket.
 	existing, err := v1KV.Get(ctx, key)
 	if err == jetstream.ErrKeyNotFound {
@@ -295,7 +305,7 @@ func handleWALDelete(ctx context.Context, walEvent *WALEvent) bool {
 
 	// Update metadata fields.
 	existingData["_sdc_extracted_at"] = walEvent.CommitTime
-	existingData["_sdc_received_at"] = time.Now().UTC().Format(time.RFC3339)
+	existingData["_sdc_received_at"] = bootstrap.Now().UTC().Format(time.RFC3339)
 	// Mark the record as deleted by adding _sdc_deleted_at field.
 	existingData["_sdc_deleted_at"] = walEvent.CommitTime
 
@@ -323,6 +333,7 @@ func handleWALDelete(ctx context.Context, walEvent *WALEvent) bool {
 	}
 
 	logger.With("key", key, "encoding", getEncodingFormat()).InfoContext(ctx, "marked KV entry as deleted from WAL event")
+	markIngestSource(ctx, ingestSourceWAL, keyPrefix, sfid)
 	return false
 }
 
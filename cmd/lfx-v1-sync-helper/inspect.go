@@ -0,0 +1,172 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Revision inspection. The inspect subcommand takes a v1-objects key and a
+// revision from the key's KV history, and runs the sync handlers on that
+// revision in dry-run mode: KV writes are kept in an in-memory overlay instead
+// of the buckets, NATS messages are recorded instead of published, and v2 API
+// writes are recorded and rejected. It prints the indexer, access, and FGA
+// messages the revision produces (and the writes the handlers attempted), to
+// explain how a record was synced at a point in time.
+//
+// Only the record itself is read at the given revision: mappings and related
+// records (parent meetings, users, committees) are read at their current
+// state.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// inspectResult is the output of the inspect subcommand.
+type inspectResult struct {
+	Key         string             `json:"key"`
+	Revision    uint64             `json:"revision"`
+	Operation   string             `json:"operation"`
+	Created     time.Time          `json:"created"`
+	Record      json.RawMessage    `json:"record,omitempty"`
+	Retry       bool               `json:"retry"`
+	Messages    []dryRunMessage    `json:"messages"`
+	KVWrites    []dryRunKVWrite    `json:"kv_writes"`
+	APIRequests []dryRunAPIRequest `json:"api_requests"`
+}
+
+// inspectRevision is a revision in the history of a key, as listed by the
+// inspect subcommand.
+type inspectRevision struct {
+	Revision  uint64    `json:"revision"`
+	Operation string    `json:"operation"`
+	Created   time.Time `json:"created"`
+}
+
+// decodeRecord returns a v1-objects value as JSON, decompressing gzip values
+// and decoding msgpack values.
+func decodeRecord(key string, value []byte) json.RawMessage {
+	if decompressed, err := decompressV1Value(key, value); err == nil {
+		value = decompressed
+	}
+	if len(value) == 0 || json.Valid(value) {
+		return rawJSON(value)
+	}
+	var v1Data map[string]any
+	if err := msgpack.Unmarshal(value, &v1Data); err != nil {
+		return rawJSON(value)
+	}
+	encoded, err := json.Marshal(v1Data)
+	if err != nil {
+		return rawJSON(value)
+	}
+	return encoded
+}
+
+// startInspectDryRun installs the dry-run hooks, and returns a context
+// recording the side effects of the handlers. Mapping locks are taken in the
+// overlay, and meeting and summary fingerprints are hidden, so unchanged
+// records are still synced.
+func startInspectDryRun(ctx context.Context) (context.Context, *dryRunRecorder) {
+	installDryRunHooks()
+	distributedSync = newKVMappingLocker(mappingsKV,
+		withLockerOptionMaxRetries(mappingLockRetryAttempts),
+		withLockerOptionRetryInterval(mappingLockRetryInterval),
+		withLockerOptionTimeout(mappingLockTimeout),
+	)
+	recorder := &dryRunRecorder{
+		hiddenPrefixes: []string{
+			strings.TrimSuffix(meetingFingerprintKeyFmt, "%s"),
+			strings.TrimSuffix(summaryFingerprintKeyFmt, "%s"),
+		},
+	}
+	return withDryRun(ctx, recorder), recorder
+}
+
+// findKeyRevision returns the history of a v1-objects key, and its entry at
+// the given revision (nil if the revision is not in the history).
+func findKeyRevision(ctx context.Context, key string, revision uint64) ([]jetstream.KeyValueEntry, jetstream.KeyValueEntry, error) {
+	history, err := v1KV.History(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range history {
+		if entry.Revision() == revision {
+			return history, entry, nil
+		}
+	}
+	return history, nil, nil
+}
+
+// runInspect runs the sync handlers on a revision of a v1-objects key in
+// dry-run mode and prints what they would emit, or lists the revisions of the
+// key when no revision is given.
+func runInspect(name string, args []string) {
+	var key *string
+	var revision *uint64
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		key = flags.String("key", "", "v1-objects key to inspect")
+		revision = flags.Uint64("revision", 0, "KV revision of the key to run the handlers on (lists the key's revisions when unset)")
+	})
+	ctx := p.ctx
+
+	if *key == "" {
+		logger.Error("-key is required")
+		os.Exit(2)
+	}
+
+	p.openBuckets()
+
+	history, entry, err := findKeyRevision(ctx, *key, *revision)
+	if err != nil {
+		logger.With(errKey, err, "key", *key).Error("error getting v1-objects key history")
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if *revision == 0 {
+		revisions := []inspectRevision{}
+		for _, e := range history {
+			revisions = append(revisions, inspectRevision{Revision: e.Revision(), Operation: e.Operation().String(), Created: e.Created()})
+		}
+		p.shutdown()
+		_ = encoder.Encode(revisions)
+		return
+	}
+	if entry == nil {
+		logger.With("key", *key, "revision", *revision).Error("revision not found in the key history (it may be older than the bucket's history)")
+		p.shutdown()
+		os.Exit(1)
+	}
+
+	dryRunCtx, recorder := startInspectDryRun(bootstrap.MessageContext(ctx, nil))
+	retry := kvHandler(dryRunCtx, entry)
+	messages, kvWrites, apiRequests := recorder.results()
+
+	result := inspectResult{
+		Key:         entry.Key(),
+		Revision:    entry.Revision(),
+		Operation:   entry.Operation().String(),
+		Created:     entry.Created(),
+		Record:      decodeRecord(entry.Key(), entry.Value()),
+		Retry:       retry,
+		Messages:    messages,
+		KVWrites:    kvWrites,
+		APIRequests: apiRequests,
+	}
+	p.shutdown()
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "error encoding inspect result: %v\n", err)
+		os.Exit(1)
+	}
+}
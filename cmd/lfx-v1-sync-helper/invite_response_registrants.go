@@ -0,0 +1,69 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Invite response registrant resolution. Some v1 invite responses (RSVPs)
+// carry the email of the invitee but no registrant_id, and were indexed
+// without a registrant, so downstream consumers linking RSVPs to registrants
+// dropped them. Such invite responses are resolved to the registrant of their
+// meeting with the same email (compared case-insensitively), found through the
+// meeting registrant index, before they are indexed. Invite responses matching
+// no registrant, or several registrants with different IDs, are indexed
+// without one.
+
+import (
+	"context"
+	"strings"
+)
+
+var inviteResponseRegistrantMatches = newCounterVec(
+	"v1_sync_helper_invite_response_registrant_matches_total",
+	"Number of invite responses without a registrant ID resolved to a meeting registrant by email, by result (matched, unmatched, ambiguous, or error).",
+	"result",
+)
+
+// resolveInviteResponseRegistrant sets the registrant ID of an invite response
+// which has none to the ID of the meeting registrant with the same email.
+func resolveInviteResponseRegistrant(ctx context.Context, inviteResponse *inviteResponseInput) {
+	email := strings.TrimSpace(inviteResponse.Email)
+	if inviteResponse.RegistrantID != "" || email == "" || inviteResponse.MeetingID == "" {
+		return
+	}
+	funcLogger := logger.With("invite_response_id", inviteResponse.ID, "meeting_id", inviteResponse.MeetingID)
+
+	registrants, err := getMeetingRegistrants(ctx, inviteResponse.MeetingID)
+	if err != nil {
+		inviteResponseRegistrantMatches.inc("error")
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to get meeting registrants to resolve invite response registrant")
+		return
+	}
+
+	registrantID := ""
+	for _, registrantData := range registrants {
+		registrantEmail, _ := registrantData["email"].(string)
+		if !strings.EqualFold(email, strings.TrimSpace(registrantEmail)) {
+			continue
+		}
+		id := registrantRecordID(registrantData)
+		if id == "" || id == registrantID {
+			continue
+		}
+		if registrantID != "" {
+			inviteResponseRegistrantMatches.inc("ambiguous")
+			funcLogger.DebugContext(ctx, "multiple meeting registrants match invite response email, not resolving registrant")
+			return
+		}
+		registrantID = id
+	}
+
+	if registrantID == "" {
+		inviteResponseRegistrantMatches.inc("unmatched")
+		funcLogger.DebugContext(ctx, "no meeting registrant matches invite response email")
+		return
+	}
+	inviteResponse.RegistrantID = registrantID
+	inviteResponseRegistrantMatches.inc("matched")
+	funcLogger.With("registrant_id", registrantID).DebugContext(ctx, "resolved invite response registrant by email")
+}
@@ -0,0 +1,65 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Compressed v1-objects values. Meltano gzip-compresses large values, so
+// values starting with the gzip magic bytes are decompressed before being
+// decoded as JSON or msgpack. Plain values are passed through unchanged.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// maxDecompressedV1ValueBytes bounds the size of a decompressed v1-objects
+// value.
+const maxDecompressedV1ValueBytes = 64 << 20
+
+// gzipMagic are the first bytes of gzip-compressed data.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+var v1ValueEncodings = newCounterVec(
+	"v1_sync_helper_v1_value_encodings_total",
+	"Number of v1-objects values decoded, by object type and encoding (gzip, plain, or gzip_error).",
+	"object_type", "encoding",
+)
+
+// decompressV1Value returns a v1-objects value, decompressed if it is
+// gzip-compressed.
+func decompressV1Value(key string, value []byte) ([]byte, error) {
+	objectType := kvObjectType(key)
+	if !bytes.HasPrefix(value, gzipMagic) {
+		v1ValueEncodings.inc(objectType, "plain")
+		return value, nil
+	}
+
+	decompressed, err := gunzip(value)
+	if err != nil {
+		v1ValueEncodings.inc(objectType, "gzip_error")
+		return nil, fmt.Errorf("failed to decompress gzip value of %s: %w", key, err)
+	}
+	v1ValueEncodings.inc(objectType, "gzip")
+	return decompressed, nil
+}
+
+// gunzip decompresses gzip data, up to maxDecompressedV1ValueBytes.
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxDecompressedV1ValueBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > maxDecompressedV1ValueBytes {
+		return nil, fmt.Errorf("decompressed value is over %d bytes", maxDecompressedV1ValueBytes)
+	}
+	return decompressed, nil
+}
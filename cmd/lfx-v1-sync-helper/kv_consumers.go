@@ -0,0 +1,164 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Per-prefix KV consumers. Heavy handlers (e.g. past meeting recordings and
+// summaries) need a longer AckWait and more deliveries than light ones (e.g.
+// RSVPs). The v1 key prefixes listed in KV_CONSUMER_PREFIXES get a dedicated
+// durable consumer filtered to the prefix, with its own delivery settings.
+// The shared KV consumer still receives their messages (JetStream filters
+// cannot exclude subjects), and acknowledges them without processing.
+//
+// A newly dedicated prefix consumer starts from the last value of every key
+// under the prefix, so adding a prefix resyncs its object type.
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// kvPrefixConsumerSettings are the delivery settings of a dedicated KV
+// consumer. Zero values fall back to the shared consumer settings.
+type kvPrefixConsumerSettings struct {
+	MaxDeliver    int
+	AckWait       time.Duration
+	MaxAckPending int
+	Weight        int
+}
+
+// kvPrefixConsumerSettingsJSON is the KV_CONSUMER_PREFIXES schema of the
+// settings of a prefix.
+type kvPrefixConsumerSettingsJSON struct {
+	MaxDeliver    int    `json:"max_deliver"`
+	AckWait       string `json:"ack_wait"`
+	MaxAckPending int    `json:"max_ack_pending"`
+	Weight        int    `json:"weight"`
+}
+
+// parseKVPrefixConsumers parses KV_CONSUMER_PREFIXES, a JSON object of the
+// delivery settings by v1 key prefix, e.g.
+// {"itx-zoom-past-meetings-recordings": {"max_deliver": 5, "ack_wait": "2m"}}.
+func parseKVPrefixConsumers(value string) (map[string]kvPrefixConsumerSettings, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var parsed map[string]kvPrefixConsumerSettingsJSON
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("KV_CONSUMER_PREFIXES must be a JSON object of settings by key prefix: %w", err)
+	}
+
+	prefixes := make(map[string]kvPrefixConsumerSettings, len(parsed))
+	for prefix, settings := range parsed {
+		if prefix == "" || strings.ContainsAny(prefix, ".*> \t") {
+			return nil, fmt.Errorf("KV_CONSUMER_PREFIXES key prefix %q is invalid", prefix)
+		}
+		if settings.MaxDeliver < 0 || settings.MaxAckPending < 0 || settings.Weight < 0 {
+			return nil, fmt.Errorf("KV_CONSUMER_PREFIXES settings of %s must not be negative", prefix)
+		}
+		var ackWait time.Duration
+		if settings.AckWait != "" {
+			var err error
+			ackWait, err = time.ParseDuration(settings.AckWait)
+			if err != nil || ackWait <= 0 {
+				return nil, fmt.Errorf("KV_CONSUMER_PREFIXES ack_wait of %s must be a positive duration", prefix)
+			}
+		}
+		prefixes[prefix] = kvPrefixConsumerSettings{
+			MaxDeliver:    settings.MaxDeliver,
+			AckWait:       ackWait,
+			MaxAckPending: settings.MaxAckPending,
+			Weight:        settings.Weight,
+		}
+	}
+	return prefixes, nil
+}
+
+// kvPrefixConsumerName returns the durable name of the dedicated consumer of
+// a v1 key prefix.
+func kvPrefixConsumerName(prefix string) string {
+	return kvConsumerName + "-" + prefix
+}
+
+// kvPrefixes returns the v1 key prefixes with a dedicated consumer, sorted.
+func kvPrefixes() []string {
+	if cfg == nil {
+		return nil
+	}
+	prefixes := make([]string, 0, len(cfg.KVPrefixConsumers))
+	for prefix := range cfg.KVPrefixConsumers {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+	return prefixes
+}
+
+// hasKVPrefixConsumer reports whether a v1 key prefix has a dedicated consumer.
+func hasKVPrefixConsumer(prefix string) bool {
+	if cfg == nil {
+		return false
+	}
+	_, ok := cfg.KVPrefixConsumers[prefix]
+	return ok
+}
+
+// consumerDelivery returns the MaxDeliver and AckWait of a consumer.
+func consumerDelivery(consumer string) (maxDeliver int, ackWait time.Duration) {
+	maxDeliver, ackWait = consumerMaxDeliver, consumerAckWait
+	for _, prefix := range kvPrefixes() {
+		if kvPrefixConsumerName(prefix) != consumer {
+			continue
+		}
+		settings := cfg.KVPrefixConsumers[prefix]
+		if settings.MaxDeliver > 0 {
+			maxDeliver = settings.MaxDeliver
+		}
+		if settings.AckWait > 0 {
+			ackWait = settings.AckWait
+		}
+	}
+	return maxDeliver, ackWait
+}
+
+// kvConsumerConfig returns the configuration of the shared KV consumer.
+func kvConsumerConfig() jetstream.ConsumerConfig {
+	return jetstream.ConsumerConfig{
+		Name:          kvConsumerName,
+		Durable:       kvConsumerName,
+		DeliverPolicy: jetstream.DeliverLastPerSubjectPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubject: "$KV.v1-objects.>",
+		MaxDeliver:    consumerMaxDeliver,
+		AckWait:       consumerAckWait,
+		MaxAckPending: 1000,
+		Description:   "durable/shared KV bucket watcher for v1-sync-helper pods",
+	}
+}
+
+// kvPrefixConsumerConfig returns the configuration of the dedicated consumer
+// of a v1 key prefix.
+func kvPrefixConsumerConfig(prefix string) jetstream.ConsumerConfig {
+	name := kvPrefixConsumerName(prefix)
+	maxDeliver, ackWait := consumerDelivery(name)
+	maxAckPending := 1000
+	if settings := cfg.KVPrefixConsumers[prefix]; settings.MaxAckPending > 0 {
+		maxAckPending = settings.MaxAckPending
+	}
+	return jetstream.ConsumerConfig{
+		Name:          name,
+		Durable:       name,
+		DeliverPolicy: jetstream.DeliverLastPerSubjectPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubject: "$KV.v1-objects." + prefix + ".>",
+		MaxDeliver:    maxDeliver,
+		AckWait:       ackWait,
+		MaxAckPending: maxAckPending,
+		Description:   "durable/shared KV bucket watcher for " + prefix + " objects",
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Weighted fairness across object types. During catch-up (e.g. a DeliverAll
+// replay), one massive object type can keep the KV consumers busy for hours
+// while the others wait. With KV_FAIRNESS_WORKERS set, KV messages are
+// processed in at most that many worker slots, and a slot freed while object
+// types are waiting goes to the one which used the least processing time
+// relative to its weight (the "weight" of its KV_CONSUMER_PREFIXES settings,
+// or 1). Every object type thus makes progress in proportion to its weight,
+// while an object type alone is processed at full speed.
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultKVFairnessWeight is the weight of object types without one.
+const defaultKVFairnessWeight = 1
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_kv_fairness_waiting",
+	"Number of KV messages waiting for a worker slot of the fairness scheduler, by object type.",
+	func() []gaugeSample { return kvFairness.waitingSamples() },
+	"object_type",
+)
+
+var kvFairnessWait = newCounterVec(
+	"v1_sync_helper_kv_fairness_waits_total",
+	"Number of KV messages which waited for a worker slot of the fairness scheduler, by object type.",
+	"object_type",
+)
+
+// kvFairness is the fairness scheduler of the KV consumers.
+var kvFairness = &fairScheduler{
+	used:    map[string]time.Duration{},
+	waiting: map[string][]chan struct{}{},
+}
+
+// fairScheduler grants a bounded number of worker slots to object types by
+// weighted fair queuing: each object type accrues its processing time
+// divided by its weight, and a freed slot goes to the waiting object type
+// with the least accrued time.
+type fairScheduler struct {
+	mu sync.Mutex
+	// busy is the number of slots in use.
+	busy int
+	// used is the processing time accrued by object type, divided by weight.
+	used map[string]time.Duration
+	// floor is the accrued time of the object type granted a slot last.
+	// Object types starting to wait are raised to it, so an idle (or new)
+	// one does not monopolize the slots to catch up.
+	floor time.Duration
+	// waiting are the queued slot requests by object type, oldest first.
+	waiting map[string][]chan struct{}
+}
+
+// kvFairnessWeight returns the weight of an object type.
+func kvFairnessWeight(objectType string) int {
+	if settings, ok := cfg.KVPrefixConsumers[objectType]; ok && settings.Weight > 0 {
+		return settings.Weight
+	}
+	return defaultKVFairnessWeight
+}
+
+// acquire waits for a worker slot for an object type, calling progress every
+// progressInterval while waiting (e.g. to extend the message AckWait), and
+// returns a function to release the slot once processing is done. Without
+// KV_FAIRNESS_WORKERS, it returns immediately.
+func (s *fairScheduler) acquire(objectType string, progressInterval time.Duration, progress func()) (release func()) {
+	if cfg.KVFairnessWorkers <= 0 {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	if s.busy < cfg.KVFairnessWorkers && len(s.waiting) == 0 {
+		s.busy++
+		s.mu.Unlock()
+		return s.releaser(objectType)
+	}
+	if len(s.waiting[objectType]) == 0 {
+		s.used[objectType] = max(s.used[objectType], s.floor)
+	}
+	granted := make(chan struct{})
+	s.waiting[objectType] = append(s.waiting[objectType], granted)
+	s.mu.Unlock()
+
+	kvFairnessWait.inc(objectType)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-granted:
+			return s.releaser(objectType)
+		case <-ticker.C:
+			progress()
+		}
+	}
+}
+
+// releaser returns the function releasing a slot granted to an object type
+// now, accruing its processing time.
+func (s *fairScheduler) releaser(objectType string) func() {
+	started := time.Now()
+	return func() {
+		s.release(objectType, time.Since(started))
+	}
+}
+
+// release accrues the processing time of an object type, and hands its slot
+// over to the waiting object type with the least accrued time, if any.
+func (s *fairScheduler) release(objectType string, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used[objectType] += elapsed / time.Duration(kvFairnessWeight(objectType))
+
+	next := ""
+	for waitingType := range s.waiting {
+		if next == "" || s.used[waitingType] < s.used[next] || (s.used[waitingType] == s.used[next] && waitingType < next) {
+			next = waitingType
+		}
+	}
+	if next == "" {
+		s.busy--
+		return
+	}
+
+	queue := s.waiting[next]
+	granted := queue[0]
+	if len(queue) == 1 {
+		delete(s.waiting, next)
+	} else {
+		s.waiting[next] = queue[1:]
+	}
+	s.floor = s.used[next]
+	close(granted)
+}
+
+// waitingSamples returns the number of waiting slot requests by object type.
+func (s *fairScheduler) waitingSamples() []gaugeSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := make([]gaugeSample, 0, len(s.waiting))
+	for objectType, queue := range s.waiting {
+		samples = append(samples, gaugeSample{labelValues: []string{objectType}, value: float64(len(queue))})
+	}
+	return samples
+}
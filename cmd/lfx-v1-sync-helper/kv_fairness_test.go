@@ -0,0 +1,116 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestFairSchedulerRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  map[string]int
+		used     map[string]time.Duration
+		waiting  []string
+		released string
+		elapsed  time.Duration
+		want     string
+		wantUsed time.Duration
+	}{
+		{
+			name:     "no waiting object types",
+			used:     map[string]time.Duration{},
+			released: "a",
+			elapsed:  time.Second,
+			wantUsed: time.Second,
+		},
+		{
+			name:     "least accrued time",
+			used:     map[string]time.Duration{"a": 10 * time.Second, "b": 5 * time.Second},
+			waiting:  []string{"a", "b"},
+			released: "c",
+			elapsed:  time.Second,
+			want:     "b",
+			wantUsed: time.Second,
+		},
+		{
+			name:     "releasing object type",
+			used:     map[string]time.Duration{"b": 6 * time.Second},
+			waiting:  []string{"a", "b"},
+			released: "a",
+			elapsed:  10 * time.Second,
+			want:     "b",
+			wantUsed: 10 * time.Second,
+		},
+		{
+			name:     "weighted releasing object type",
+			weights:  map[string]int{"a": 2},
+			used:     map[string]time.Duration{"b": 6 * time.Second},
+			waiting:  []string{"a", "b"},
+			released: "a",
+			elapsed:  10 * time.Second,
+			want:     "a",
+			wantUsed: 5 * time.Second,
+		},
+		{
+			name:     "tie broken by object type",
+			used:     map[string]time.Duration{},
+			waiting:  []string{"b", "a"},
+			released: "c",
+			want:     "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupHandlerTest(t)
+			cfg.KVPrefixConsumers = map[string]kvPrefixConsumerSettings{}
+			for objectType, weight := range tt.weights {
+				cfg.KVPrefixConsumers[objectType] = kvPrefixConsumerSettings{Weight: weight}
+			}
+
+			s := &fairScheduler{busy: 1, used: tt.used, waiting: map[string][]chan struct{}{}}
+			for _, objectType := range tt.waiting {
+				s.waiting[objectType] = []chan struct{}{make(chan struct{})}
+			}
+			queues := maps.Clone(s.waiting)
+
+			s.release(tt.released, tt.elapsed)
+
+			for objectType, queue := range queues {
+				select {
+				case <-queue[0]:
+					if objectType != tt.want {
+						t.Errorf("slot granted to %s, want %q", objectType, tt.want)
+					}
+				default:
+					if objectType == tt.want {
+						t.Errorf("slot not granted to %s", objectType)
+					}
+				}
+			}
+			if wantBusy := min(1, len(tt.waiting)); s.busy != wantBusy {
+				t.Errorf("busy slots: got %d, want %d", s.busy, wantBusy)
+			}
+			if got := s.used[tt.released]; got != tt.wantUsed {
+				t.Errorf("accrued time of %s: got %v, want %v", tt.released, got, tt.wantUsed)
+			}
+			if tt.want != "" && s.floor != s.used[tt.want] {
+				t.Errorf("floor: got %v, want the accrued time of %s, %v", s.floor, tt.want, s.used[tt.want])
+			}
+		})
+	}
+}
+
+func TestFairSchedulerAcquireDisabled(t *testing.T) {
+	setupHandlerTest(t)
+	s := &fairScheduler{used: map[string]time.Duration{}, waiting: map[string][]chan struct{}{}}
+
+	release := s.acquire("a", time.Hour, func() { t.Error("progress called without waiting") })
+	release()
+	if s.busy != 0 || len(s.used) != 0 {
+		t.Errorf("scheduler state without KV_FAIRNESS_WORKERS: busy %d, used %v", s.busy, s.used)
+	}
+}
@@ -5,8 +5,11 @@
 package main
 
 import (
+	"context"
+	"strings"
 	"time"
 
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
@@ -34,7 +37,7 @@ func (e *kvEntry) Bucket() string {
 }
 
 func (e *kvEntry) Created() time.Time {
-	return time.Now()
+	return bootstrap.Now()
 }
 
 func (e *kvEntry) Delta() uint64 {
@@ -45,11 +48,20 @@ func (e *kvEntry) Revision() uint64 {
 	return 0
 }
 
-// kvMessageHandler processes KV update messages from the consumer.
-func kvMessageHandler(msg jetstream.Msg) {
+// newKVMessageHandler returns the handler of the KV update messages from the
+// given consumer.
+func newKVMessageHandler(consumer string) jetstream.MessageHandler {
+	return func(msg jetstream.Msg) {
+		kvMessageHandler(msg, consumer)
+	}
+}
+
+// kvMessageHandler processes a KV update message from the consumer.
+func kvMessageHandler(msg jetstream.Msg, consumer string) {
 	// Parse the message as a KV entry.
 	headers := msg.Headers()
 	subject := msg.Subject()
+	ctx := bootstrap.MessageContext(context.Background(), headers)
 
 	// Extract key from the subject ($KV.v1-objects.{key}).
 	key := ""
@@ -57,6 +69,17 @@ func kvMessageHandler(msg jetstream.Msg) {
 		key = subject[len("$KV.v1-objects."):]
 	}
 
+	// Prefixes with a dedicated consumer are processed by that consumer.
+	if consumer == kvConsumerName && hasKVPrefixConsumer(kvObjectType(key)) {
+		if err := msg.Ack(); err != nil {
+			logger.With(errKey, err, "key", key).ErrorContext(ctx, "failed to acknowledge KV message of a dedicated consumer prefix")
+		}
+		return
+	}
+
+	// Trace the processing of the message, joining the trace of its headers.
+	ctx, span := startMessageSpan(ctx, headers, consumer, key)
+
 	// Determine operation from headers.
 	operation := jetstream.KeyValuePut // Default to PUT.
 	if opHeader := headers.Get("KV-Operation"); opHeader != "" {
@@ -75,42 +98,98 @@ func kvMessageHandler(msg jetstream.Msg) {
 		operation: operation,
 	}
 
+	// Wait for the downstream services to be up, and for a worker slot of
+	// the object type, keeping the message in progress meanwhile.
+	objectType := kvObjectType(key)
+	_, ackWait := consumerDelivery(consumer)
+	downstreamHealth.wait(objectType, ackWait/2, func() {
+		if err := msg.InProgress(); err != nil {
+			logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to extend KV message AckWait while waiting for downstream services")
+		}
+	})
+	release := kvFairness.acquire(objectType, ackWait/2, func() {
+		if err := msg.InProgress(); err != nil {
+			logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to extend KV message AckWait while waiting for a worker slot")
+		}
+	})
+
 	// Process the KV entry and check if retry is needed.
-	shouldRetry := kvHandler(entry)
+	started := time.Now()
+	ctx, publishes := withPublishTracker(ctx)
+	ctx, dedup := withContentDedup(ctx)
+	ctx, settlement := withDeferredSettlement(ctx, msg)
+	ctx, cancel := withHandlerDeadline(ctx, consumer, objectType)
+	shouldRetry := kvHandler(ctx, entry)
+	if handlerDeadlineExceeded(ctx, consumer, objectType) {
+		shouldRetry = true
+	}
+	cancel()
+	release()
+	if failures := publishes.failures(); failures > 0 && !shouldRetry {
+		logger.With("key", key, "failed_publishes", failures).WarnContext(ctx, "retrying KV message after failed downstream publishes")
+		shouldRetry = true
+	}
+	if operation == jetstream.KeyValuePut {
+		captureConfigDiffSample(objectType, entry)
+	}
 
-	// Handle message acknowledgment based on retry decision.
+	// Settle the message, once its deferred sync completed, if any.
+	settlement.finish(shouldRetry, func(shouldRetry bool) {
+		settleKVMessage(ctx, msg, consumer, key, objectType, dedup, shouldRetry, started)
+		endRetrySpan(span, shouldRetry)
+	})
+}
+
+// detachMessageContext returns a context for work outliving the handling of a
+// KV message (e.g. re-running the records parked on it), which is not
+// canceled with it and does not share its content deduplication, deferred
+// settlement, or publish tracker.
+func detachMessageContext(ctx context.Context) context.Context {
+	ctx = context.WithoutCancel(ctx)
+	ctx = context.WithValue(ctx, contentDedupContextKey{}, nil)
+	ctx = context.WithValue(ctx, deferredSettlementContextKey{}, nil)
+	return context.WithValue(ctx, publishTrackerContextKey{}, nil)
+}
+
+// settleKVMessage settles a processed KV message, storing the content hash
+// of its record once synced (or forgetting it if the sync failed), and
+// delaying its redelivery by its delivery attempt when retried.
+func settleKVMessage(ctx context.Context, msg jetstream.Msg, consumer, key, objectType string, dedup *contentDedup, shouldRetry bool, started time.Time) {
+	dedup.commit(context.WithoutCancel(ctx), shouldRetry)
+
+	// Calculate exponential backoff delay for retries based on delivery attempt.
+	// Attempts: 1st retry = 2s, 2nd retry = 10s, 3rd+ retry = 20s
+	// This allows time for parent objects (e.g., meetings) to be stored before retrying child objects (e.g., registrants).
+	var delay time.Duration
 	if shouldRetry {
 		// Get message metadata to determine retry attempt number.
 		metadata, err := msg.Metadata()
 		if err != nil {
-			logger.With(errKey, err, "key", key).Warn("failed to get message metadata, using default delay")
+			logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to get message metadata, using default delay")
 			metadata = &jetstream.MsgMetadata{NumDelivered: 1}
 		}
 
-		// Calculate exponential backoff delay based on delivery attempt.
-		// Attempts: 1st retry = 2s, 2nd retry = 10s, 3rd+ retry = 20s
-		var delay time.Duration
 		switch metadata.NumDelivered {
 		case 1:
 			delay = 2 * time.Second
 		case 2:
 			delay = 10 * time.Second
 		default:
-			// This case won't be hit if there is a consumer max delivery of 3 or less.
+			// Only hit by prefix consumers with a max delivery above 3.
 			delay = 20 * time.Second
 		}
+		logger.With("key", key, "attempt", metadata.NumDelivered, "delay_seconds", delay.Seconds()).DebugContext(ctx, "retrying KV message with exponential backoff")
+	}
 
-		// NAK the message with exponential backoff delay.
-		// This allows time for parent objects (e.g., meetings) to be stored before retrying child objects (e.g., registrants).
-		if err := msg.NakWithDelay(delay); err != nil {
-			logger.With(errKey, err, "key", key).Error("failed to NAK KV JetStream message for retry")
-		} else {
-			logger.With("key", key, "attempt", metadata.NumDelivered, "delay_seconds", delay.Seconds()).Debug("NAKed KV message for retry with exponential backoff")
-		}
-	} else {
-		// Acknowledge the message.
-		if err := msg.Ack(); err != nil {
-			logger.With(errKey, err, "key", key).Error("failed to acknowledge KV JetStream message")
-		}
+	// Handle message acknowledgment based on retry decision.
+	settleMessage(ctx, msg, consumer, objectType, shouldRetry, delay, started)
+}
+
+// kvObjectType returns the object type of a v1-objects key, which is its
+// prefix before the first period.
+func kvObjectType(key string) string {
+	if dotIndex := strings.Index(key, "."); dotIndex != -1 {
+		return key[:dotIndex]
 	}
+	return key
 }
@@ -0,0 +1,75 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Partial decoding of large records. Some v1 records are multi-megabyte, e.g.
+// past meeting recordings with hundreds of recording files, and decoding them
+// into nested maps (which the handlers then marshal again, to convert them to
+// their input structs) multiplies their allocations during backfills. Key
+// prefixes of such records declare their large top-level fields (the rawFields
+// of their kvTableHandler): JSON values of these prefixes are decoded with a
+// streaming decoder which keeps those fields as raw JSON in the record map,
+// so they are only decoded once, by the struct conversion. Other values (and
+// msgpack values) are decoded as usual.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+var partiallyDecodedRecords = newCounterVec(
+	"v1_sync_helper_partially_decoded_records_total",
+	"Number of v1 records decoded with their large fields kept as raw JSON, by object type.",
+	"object_type",
+)
+
+// decodeV1ObjectPartially decodes a JSON v1 record into a map, keeping the
+// given top-level fields as json.RawMessage values.
+func decodeV1ObjectPartially(value []byte, rawFields []string) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, errors.New("v1 record is not a JSON object")
+	}
+
+	v1Data := map[string]any{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		field, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected JSON token %v", token)
+		}
+		if slices.Contains(rawFields, field) {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			v1Data[field] = raw
+			continue
+		}
+		var fieldValue any
+		if err := decoder.Decode(&fieldValue); err != nil {
+			return nil, err
+		}
+		v1Data[field] = fieldValue
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	// Reject trailing data, as json.Unmarshal does.
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid data after top-level v1 record")
+	}
+	return v1Data, nil
+}
@@ -47,7 +47,8 @@ const (
 )
 
 var (
-	v1HTTPClient *http.Client
+	v1HTTPClient  *http.Client
+	v1TokenSource oauth2.TokenSource
 )
 
 // V1User represents a user from the v1-objects KV bucket (salesforce-merged_user table)
@@ -123,19 +124,22 @@ func initV1Client(cfg *Config) error {
 	}
 
 	// Create HTTP client with Auth0 token source
-	tokenSource := &ClientCredentialsTokenSource{
+	v1TokenSource = &ClientCredentialsTokenSource{
 		ctx:        context.Background(),
 		authConfig: authConfig,
 		audience:   cfg.LFXAPIGateway.String(),
 	}
 
-	v1HTTPClient = oauth2.NewClient(context.Background(), tokenSource)
+	v1HTTPClient = oauth2.NewClient(context.Background(), v1TokenSource)
 
 	return nil
 }
 
 // lookupV1User fetches user information from the v1-objects KV bucket (replicated by Meltano)
 func lookupV1User(ctx context.Context, platformID string) (*V1User, error) {
+	// Merged users are looked up as their surviving account.
+	platformID = resolveMergedV1UserID(ctx, platformID)
+
 	// Look up user in the salesforce-merged_user table via v1-objects KV bucket
 	userKey := fmt.Sprintf("salesforce-merged_user.%s", platformID)
 
@@ -387,8 +391,10 @@ func releaseV1OrgLock(ctx context.Context, sfid string) error {
 	return mappingsKV.Delete(ctx, lockKey)
 }
 
-// refreshOrgInBackground refreshes organization data in the background
+// refreshOrgInBackground refreshes organization data in the background,
+// outliving the processing deadline of the message which requested it.
 func refreshV1OrgInBackground(ctx context.Context, sfid string) {
+	ctx = context.WithoutCancel(ctx)
 	go func() {
 		// Acquire lock for this refresh operation
 		acquired, _ := acquireV1OrgLock(ctx, sfid, 1)
@@ -841,10 +847,15 @@ func getV1ObjectData(ctx context.Context, key string) (map[string]any, bool, err
 		return nil, false, nil
 	}
 
+	value, err := decompressV1Value(key, entry.Value())
+	if err != nil {
+		return nil, false, err
+	}
+
 	var data map[string]any
-	if err := json.Unmarshal(entry.Value(), &data); err != nil {
+	if err := json.Unmarshal(value, &data); err != nil {
 		// Try msgpack if JSON fails.
-		if msgpackErr := msgpack.Unmarshal(entry.Value(), &data); msgpackErr != nil {
+		if msgpackErr := msgpack.Unmarshal(value, &data); msgpackErr != nil {
 			return nil, false, fmt.Errorf("failed to unmarshal data (json: %w, msgpack: %w)", err, msgpackErr)
 		}
 	}
@@ -39,6 +39,11 @@ const (
 	// Service audiences for JWT tokens.
 	projectServiceAudience   = "lfx-v2-project-service"
 	committeeServiceAudience = "lfx-v2-committee-service"
+	indexerServiceAudience   = "lfx-v2-indexer-service"
+
+	// legacyIndexerAuthorization is the placeholder authorization formerly
+	// sent on indexer messages without an authorization in context.
+	legacyIndexerAuthorization = "Bearer v1-sync-helper"
 )
 
 // debugTransport wraps an http.RoundTripper to log requests and responses.
@@ -63,24 +68,24 @@ func (dt *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Log the request.
 	reqDump, err := httputil.DumpRequestOut(req, true)
 	if err != nil {
-		dt.logger.Error("failed to dump request", "error", err)
+		dt.logger.ErrorContext(req.Context(), "failed to dump request", "error", err)
 	} else {
-		dt.logger.Debug("HTTP Request", "dump", string(reqDump))
+		dt.logger.DebugContext(req.Context(), "HTTP Request", "dump", string(reqDump))
 	}
 
 	// Perform the request.
 	resp, err := dt.transport.RoundTrip(req)
 	if err != nil {
-		dt.logger.Error("HTTP request failed", "error", err, "url", req.URL.String())
+		dt.logger.ErrorContext(req.Context(), "HTTP request failed", "error", err, "url", req.URL.String())
 		return nil, err
 	}
 
 	// Log the response.
 	respDump, err := httputil.DumpResponse(resp, true)
 	if err != nil {
-		dt.logger.Error("failed to dump response", "error", err)
+		dt.logger.ErrorContext(req.Context(), "failed to dump response", "error", err)
 	} else {
-		dt.logger.Debug("HTTP Response", "dump", string(respDump))
+		dt.logger.DebugContext(req.Context(), "HTTP Response", "dump", string(respDump))
 	}
 
 	return resp, nil
@@ -284,6 +289,25 @@ func generateCachedJWTToken(ctx context.Context, audience string, v1Principal st
 	return token, nil
 }
 
+// indexerAuthorization returns the authorization header for an indexer
+// message: the authorization from context if available, otherwise a service
+// token for the indexer audience. The legacy placeholder is returned instead
+// when INDEXER_LEGACY_AUTHORIZATION is set, for indexers not yet validating
+// the header.
+func indexerAuthorization(ctx context.Context) (string, error) {
+	if authorization, ok := ctx.Value("authorization").(string); ok {
+		return authorization, nil
+	}
+	if cfg.IndexerLegacyAuthorization {
+		return legacyIndexerAuthorization, nil
+	}
+	token, err := generateCachedJWTToken(ctx, indexerServiceAudience, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to generate indexer authorization token: %w", err)
+	}
+	return "Bearer " + token, nil
+}
+
 // generateJWTToken generates a JWT token for API authentication with optional user impersonation.
 //
 // This function implements a dual authentication strategy:
@@ -304,13 +328,13 @@ func generateJWTToken(ctx context.Context, audience string, v1Principal string)
 	case v1Principal == "" || v1Principal == "platform":
 		// Empty or platform principal - use client authentication.
 		principal = jwtClientID + "@clients"
-		logger.With("client_id", jwtClientID, "audience", audience).Debug("generating JWT token with client authentication for empty/platform principal")
+		logger.With("client_id", jwtClientID, "audience", audience).DebugContext(ctx, "generating JWT token with client authentication for empty/platform principal")
 
 	case strings.HasSuffix(v1Principal, "@clients"):
 		// Machine user - use v1Principal as-is.
 		principal = v1Principal
 		username := strings.TrimSuffix(v1Principal, "@clients")
-		logger.With("machine_user", username, "principal", principal, "audience", audience).Debug("generating JWT token with machine user impersonation")
+		logger.With("machine_user", username, "principal", principal, "audience", audience).DebugContext(ctx, "generating JWT token with machine user impersonation")
 
 	case strings.HasPrefix(v1Principal, "00") && !strings.HasPrefix(v1Principal, "003") && !strings.HasPrefix(v1Principal, "00Q"):
 		// Salesforce principal that will be unknown to the LFX v1 User Service - fallback to client authentication.
@@ -330,7 +354,7 @@ func generateJWTToken(ctx context.Context, audience string, v1Principal string)
 			// Map username to Auth0 "sub" format for v2 compatibility.
 			principal = mapUsernameToAuthSub(user.Username)
 			email = user.Email
-			logger.With("username", user.Username, "principal", principal, "email", email, "audience", audience).Debug("generating JWT token with user impersonation")
+			logger.With("username", user.Username, "principal", principal, "email", email, "audience", audience).DebugContext(ctx, "generating JWT token with user impersonation")
 		}
 	}
 
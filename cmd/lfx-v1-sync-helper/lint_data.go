@@ -0,0 +1,270 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Data lint. The lint-data subcommand scans the v1-objects bucket for broken
+// references between v1 records: records whose parent record (the parent
+// mapping they require, from the handler registry, and the meeting and
+// committee of the meeting mappings) does not exist or is soft deleted. Such
+// records are parked by the sync until their parent appears, so the report,
+// with counts and sample keys by object type and parent, gauges the data
+// quality of a table before a migration.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// lintMappingReferences are the references checked by lint-data in addition
+// to the mapping dependencies of the handler registry: the meeting mapping
+// handlers look their meeting and committee up themselves.
+var lintMappingReferences = []dataReference{
+	{prefix: "itx-zoom-meetings-mappings-v2", dependency: meetingMappingParent.requiredBy("meeting_id")},
+	{prefix: "itx-zoom-meetings-mappings-v2", dependency: committeeMappingParent.requiredBy("committee_id")},
+	{prefix: "itx-zoom-past-meetings-mappings", dependency: pastMeetingMappingParent.requiredBy("meeting_and_occurrence_id")},
+	{prefix: "itx-zoom-past-meetings-mappings", dependency: committeeMappingParent.requiredBy("committee_id")},
+}
+
+// dataReference is a reference from the records of a v1-objects prefix to
+// their parent records.
+type dataReference struct {
+	prefix     string
+	dependency mappingDependency
+}
+
+// dataLintReport is the report printed by lint-data.
+type dataLintReport struct {
+	Records int `json:"records"`
+	// Undecodable are the keys of the records which could not be decoded.
+	Undecodable []string              `json:"undecodable,omitempty"`
+	Broken      int                   `json:"broken"`
+	References  []dataReferenceReport `json:"references"`
+}
+
+// dataReferenceReport is the check of a reference of the records of an
+// object type.
+type dataReferenceReport struct {
+	ObjectType string `json:"object_type"`
+	Field      string `json:"field"`
+	Parent     string `json:"parent"`
+	// Records is the number of records with the reference field set.
+	Records int `json:"records"`
+	// Missing is the number of records whose parent does not exist.
+	Missing int `json:"missing"`
+	// Deleted is the number of records whose parent is soft deleted.
+	Deleted int `json:"deleted"`
+	// SampleKeys are the keys of some of the records with a broken reference.
+	SampleKeys []string `json:"sample_keys,omitempty"`
+}
+
+// dataReferences returns the references checked by lint-data, by object
+// type.
+func dataReferences() []dataReference {
+	var references []dataReference
+	for _, prefix := range slices.Sorted(maps.Keys(kvTableHandlers)) {
+		for _, dependency := range kvTableHandlers[prefix].requires {
+			references = append(references, dataReference{prefix: prefix, dependency: dependency})
+		}
+	}
+	references = append(references, lintMappingReferences...)
+	slices.SortStableFunc(references, func(a, b dataReference) int { return strings.Compare(a.prefix, b.prefix) })
+	return references
+}
+
+// lintScanOrder returns the v1-objects prefixes to scan for the given
+// references: the prefixes of parent records before those of the records
+// referencing them, so each record is decoded once.
+func lintScanOrder(references []dataReference) []string {
+	requires := map[string][]string{}
+	for _, reference := range references {
+		requires[reference.prefix] = append(requires[reference.prefix], reference.dependency.parent.prefix)
+		if _, ok := requires[reference.dependency.parent.prefix]; !ok {
+			requires[reference.dependency.parent.prefix] = nil
+		}
+	}
+
+	var order []string
+	visited := map[string]bool{}
+	var visit func(prefix string)
+	visit = func(prefix string) {
+		if visited[prefix] {
+			return
+		}
+		visited[prefix] = true
+		for _, parent := range requires[prefix] {
+			visit(parent)
+		}
+		order = append(order, prefix)
+	}
+	for _, prefix := range slices.Sorted(maps.Keys(requires)) {
+		visit(prefix)
+	}
+	return order
+}
+
+// runLintData scans the v1-objects bucket for broken references between
+// records and prints a report. It exits with an error if there are any.
+func runLintData(name string, args []string) {
+	var prefix *string
+	var samples *int
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		prefix = flags.String("prefix", "", "only check the references of these comma-separated v1-objects prefixes, e.g. \"itx-zoom-meetings-registrants-v2\"")
+		samples = flags.Int("samples", 10, "number of sample keys to report for each broken reference")
+	})
+	ctx := p.ctx
+
+	references := dataReferences()
+	if *prefix != "" {
+		var only []string
+		for _, v1Prefix := range strings.Split(*prefix, ",") {
+			if v1Prefix = strings.TrimSuffix(strings.TrimSpace(v1Prefix), "."); v1Prefix != "" {
+				only = append(only, v1Prefix)
+			}
+		}
+		references = slices.DeleteFunc(references, func(reference dataReference) bool {
+			return !slices.Contains(only, reference.prefix)
+		})
+	}
+
+	p.openBuckets()
+
+	report, err := lintData(ctx, references, *samples)
+	if err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to lint v1-objects")
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to write data lint report")
+		os.Exit(1)
+	}
+	logger.With("records", report.Records, "broken", report.Broken, "undecodable", len(report.Undecodable)).InfoContext(ctx, "data lint completed")
+	p.shutdown()
+	if report.Broken > 0 {
+		os.Exit(1)
+	}
+}
+
+// lintData scans the records of the referencing and referenced prefixes and
+// checks the references of each record. Soft deleted records are not synced,
+// so their own references are not checked.
+func lintData(ctx context.Context, references []dataReference, samples int) (dataLintReport, error) {
+	report := dataLintReport{References: []dataReferenceReport{}}
+	checks := make([]dataReferenceReport, len(references))
+	for i, reference := range references {
+		checks[i] = dataReferenceReport{
+			ObjectType: reference.prefix,
+			Field:      reference.dependency.field,
+			Parent:     reference.dependency.parent.name,
+		}
+	}
+
+	// The IDs of the parent records scanned, by prefix, and whether each has
+	// a record which is not soft deleted.
+	parentIDs := map[string]map[string]bool{}
+	parentIDField := map[string]string{}
+	for _, reference := range references {
+		parentIDs[reference.dependency.parent.prefix] = map[string]bool{}
+		parentIDField[reference.dependency.parent.prefix] = reference.dependency.parent.idField
+	}
+
+	for _, prefix := range lintScanOrder(references) {
+		lister, err := v1KV.ListKeysFiltered(ctx, prefix+".>")
+		if err != nil {
+			return report, err
+		}
+		for key := range lister.Keys() {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			entry, err := v1KV.Get(ctx, key)
+			if errors.Is(err, jetstream.ErrKeyNotFound) {
+				// Deleted since it was listed.
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+			report.Records++
+			v1Data, err := decodeLintRecord(key, entry.Value())
+			if err != nil {
+				logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to decode v1-objects record")
+				report.Undecodable = append(report.Undecodable, key)
+				continue
+			}
+			deletedAt, deleted := v1Data["_sdc_deleted_at"]
+			deleted = deleted && deletedAt != nil && deletedAt != ""
+
+			if ids, isParent := parentIDs[prefix]; isParent {
+				id := strings.TrimPrefix(key, prefix+".")
+				if field := parentIDField[prefix]; field != "" {
+					id = v1FieldString(v1Data, field)
+				}
+				// A live record wins over a soft deleted one with the same ID.
+				if id != "" {
+					ids[id] = ids[id] || !deleted
+				}
+			}
+			if deleted {
+				continue
+			}
+
+			for i, reference := range references {
+				if reference.prefix != prefix {
+					continue
+				}
+				parentID := v1FieldString(v1Data, reference.dependency.field)
+				if parentID == "" {
+					continue
+				}
+				checks[i].Records++
+				live, found := parentIDs[reference.dependency.parent.prefix][parentID]
+				switch {
+				case !found:
+					checks[i].Missing++
+				case !live:
+					checks[i].Deleted++
+				default:
+					continue
+				}
+				if len(checks[i].SampleKeys) < samples {
+					checks[i].SampleKeys = append(checks[i].SampleKeys, key)
+				}
+			}
+		}
+	}
+
+	for _, check := range checks {
+		report.Broken += check.Missing + check.Deleted
+		report.References = append(report.References, check)
+	}
+	return report, nil
+}
+
+// decodeLintRecord decodes a v1-objects value, keeping the large fields of
+// known-large records as raw JSON.
+func decodeLintRecord(key string, value []byte) (map[string]any, error) {
+	value = decodeRecord(key, value)
+	if rawFields := kvTableHandlers[kvObjectType(key)].rawFields; len(rawFields) > 0 {
+		if v1Data, err := decodeV1ObjectPartially(value, rawFields); err == nil {
+			return v1Data, nil
+		}
+	}
+	var v1Data map[string]any
+	if err := json.Unmarshal(value, &v1Data); err != nil {
+		return nil, err
+	}
+	return v1Data, nil
+}
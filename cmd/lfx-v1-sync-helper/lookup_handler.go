@@ -7,20 +7,24 @@ package main
 import (
 	"context"
 
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	nats "github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nats.go/micro"
 )
 
 // lookupHandler handles NATS function calls for bidirectional v1-v2 mapping lookups.
 // It receives a mapping key as the request payload and returns the corresponding
 // value from the NATS KV store, an empty string if the key is not found or tombstoned,
 // or an error message prefixed with "error: " for other errors. Supports both v1->v2
-// and v2->v1 lookups depending on the key format used.
-func lookupHandler(msg *nats.Msg) {
-	ctx := context.Background()
-	mappingKey := string(msg.Data)
+// and v2->v1 lookups depending on the key format used. It is served as an
+// endpoint of the service registered with NATS micro, so lookups are reported
+// in the service STATS.
+func lookupHandler(req micro.Request) {
+	ctx := bootstrap.MessageContext(context.Background(), nats.Header(req.Headers()))
+	mappingKey := string(req.Data())
 
-	logger.With("mapping_key", mappingKey, "subject", msg.Subject).DebugContext(ctx, "received mapping lookup request")
+	logger.With("mapping_key", mappingKey, "subject", req.Subject()).DebugContext(ctx, "received mapping lookup request")
 
 	// Look up the mapping key in the v1-mappings KV bucket.
 	entry, err := mappingsKV.Get(ctx, mappingKey)
@@ -29,14 +33,14 @@ func lookupHandler(msg *nats.Msg) {
 		if err == jetstream.ErrKeyNotFound {
 			logger.With("mapping_key", mappingKey).DebugContext(ctx, "mapping key not found")
 			// Respond with empty string for key not found.
-			if err := msg.Respond([]byte("")); err != nil {
+			if err := req.Respond([]byte("")); err != nil {
 				logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "failed to respond to lookup request")
 			}
 		} else {
 			logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "error retrieving mapping key")
 			// Respond with error message for other errors.
 			errorResponse := "error: " + err.Error()
-			if err := msg.Respond([]byte(errorResponse)); err != nil {
+			if err := req.Respond([]byte(errorResponse)); err != nil {
 				logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "failed to respond to lookup request")
 			}
 		}
@@ -49,7 +53,7 @@ func lookupHandler(msg *nats.Msg) {
 		logger.With("mapping_key", mappingKey).DebugContext(ctx, "mapping key is tombstoned")
 
 		// Respond with empty string for tombstoned mappings.
-		if err := msg.Respond([]byte("")); err != nil {
+		if err := req.Respond([]byte("")); err != nil {
 			logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "failed to respond to lookup request")
 		}
 		return
@@ -58,7 +62,7 @@ func lookupHandler(msg *nats.Msg) {
 	// Return the mapping value.
 	logger.With("mapping_key", mappingKey, "value", string(value)).DebugContext(ctx, "returning mapping value")
 
-	if err := msg.Respond(value); err != nil {
+	if err := req.Respond(value); err != nil {
 		logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "failed to respond to lookup request")
 	}
 }
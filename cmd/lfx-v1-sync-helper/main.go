@@ -8,16 +8,22 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
-	"net/http"
 	"os"
-	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	nats "github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/ddbconsume"
 )
 
 const (
@@ -25,10 +31,17 @@ const (
 	defaultListenPort = "8080"
 	natsQueue         = "lfx.v1-sync-helper.queue"
 	lookupSubject     = "lfx.lookup_v1_mapping"
-	// gracefulShutdownSeconds should be higher than NATS client
-	// request timeout, and lower than the pod or liveness probe's
-	// terminationGracePeriodSeconds.
-	gracefulShutdownSeconds = 25
+	statusSubject     = "lfx.v1_sync_helper.status"
+	traceSubject      = "lfx.v1_sync_helper.trace_dependencies"
+	serviceName       = "lfx-v1-sync-helper"
+
+	// JetStream consumer names and delivery settings.
+	kvStreamName         = "KV_v1-objects"
+	kvConsumerName       = "v1-sync-helper-kv-consumer"
+	walConsumerName      = "v1-sync-helper-wal-consumer"
+	dynamodbConsumerName = "v1-sync-helper-dynamodb-consumer"
+	consumerMaxDeliver   = 3
+	consumerAckWait      = 30 * time.Second
 )
 
 var (
@@ -50,8 +63,85 @@ var (
 	distributedSync mappingLocker
 )
 
-// main parses optional flags and starts the NATS subscribers.
+// main runs the subcommand named by the first argument, or the sync service
+// when there is none (or the first argument is a flag), for compatibility with
+// deployments predating the subcommands.
 func main() {
+	name, args := "sync", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	switch name {
+	case "sync":
+		runSync(name, args)
+	case "ddb-consume":
+		ddbconsume.Main(name, args)
+	case "replay":
+		runReplay(name, args)
+	case "backfill":
+		runBackfill(name, args)
+	case "bootstrap":
+		runBootstrap(name, args)
+	case "verify":
+		runVerify(name, args)
+	case "verify-mappings":
+		runVerifyMappings(name, args)
+	case "lint-data":
+		runLintData(name, args)
+	case "fixtures":
+		runFixtures(name, args)
+	case "inspect":
+		runInspect(name, args)
+	case "mass-purge":
+		runMassPurge(name, args)
+	case "help":
+		printUsage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown subcommand %q\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+}
+
+// printUsage prints the available subcommands.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, `Usage: %s [subcommand] [flags]
+
+Subcommands:
+  sync         run the v1 sync service (default)
+  ddb-consume  publish DynamoDB stream records to NATS
+  replay       re-run the sync handlers for v1-objects records
+  backfill     backfill historical past meetings from the Zoom API
+  bootstrap    provision and backfill a new environment by object type, then
+               create the KV consumers for the sync service
+  verify       run the startup preflight checks and exit
+  verify-mappings
+               report (or fix) v1-mappings entries out of step with v1-objects
+  lint-data    report v1-objects records referencing missing parent records
+  fixtures     capture anonymized conversion test fixtures from v1-objects
+  inspect      show what the sync handlers emit for a revision of a v1-objects key
+  mass-purge   report, confirm, or discard the deletes held after a mass purge
+
+Run "%s <subcommand> -h" for the flags of a subcommand.
+`, filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
+}
+
+// syncProcess is the process state shared by the subcommands running the
+// sync handlers.
+type syncProcess struct {
+	ctx             context.Context
+	cancel          context.CancelFunc
+	done            chan os.Signal
+	gracefulCloseWG sync.WaitGroup
+	shutdownTracing func(context.Context) error
+}
+
+// startSyncProcess loads the configuration, parses the subcommand flags, and
+// initializes logging, the API clients, and the NATS connection. The flags
+// are defined by flagsFn, which receives the loaded configuration for their
+// defaults.
+func startSyncProcess(name string, args []string, flagsFn func(flags *flag.FlagSet)) *syncProcess {
 	// Load configuration
 	var err error
 	cfg, err = LoadConfig()
@@ -60,80 +150,46 @@ func main() {
 		os.Exit(1)
 	}
 
-	var debug = flag.Bool("d", false, "enable debug logging")
-	var port = flag.String("p", cfg.Port, "health checks port")
-	var bind = flag.String("bind", cfg.Bind, "interface to bind on")
-
-	flag.Usage = func() {
-		flag.PrintDefaults()
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var debug = flags.Bool("d", false, "enable debug logging")
+	if flagsFn != nil {
+		flagsFn(flags)
+	}
+	flags.Usage = func() {
+		flags.PrintDefaults()
 		os.Exit(2)
 	}
-	flag.Parse()
-
-	logOptions := &slog.HandlerOptions{}
+	_ = flags.Parse(args)
 
 	// Optional debug logging.
 	if cfg.Debug || *debug {
-		logOptions.Level = slog.LevelDebug
-		logOptions.AddSource = true
+		cfg.Debug = true
 	}
+	logger = bootstrap.NewLogger(logLevel, cfg.Debug, cfg.LogScrubFields)
 
-	logger = slog.New(slog.NewJSONHandler(os.Stdout, logOptions))
-	slog.SetDefault(logger)
+	if cfg.IndexerLegacyAuthorization {
+		logger.Warn("INDEXER_LEGACY_AUTHORIZATION is deprecated: indexer messages are sent with a placeholder authorization instead of a service token")
+	}
 
-	// Support GET/POST monitoring "ping".
-	http.HandleFunc("/livez", func(w http.ResponseWriter, _ *http.Request) {
-		// This always returns as long as the service is still running. As this
-		// endpoint is expected to be used as a Kubernetes liveness check, this
-		// service must likewise self-detect non-recoverable errors and
-		// self-terminate.
-		fmt.Fprintf(w, "OK\n")
-	})
+	p := &syncProcess{done: bootstrap.ShutdownSignals()}
 
-	// Basic health check.
-	http.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
-		if natsConn == nil {
-			http.Error(w, "no NATS connection", http.StatusServiceUnavailable)
-			return
-		}
-		if !natsConn.IsConnected() || natsConn.IsDraining() {
-			http.Error(w, "NATS connection not ready", http.StatusServiceUnavailable)
-			return
-		}
-		fmt.Fprintf(w, "OK\n")
-	})
-
-	// Add an http listener for health checks. This server does NOT participate
-	// in the graceful shutdown process; we want it to stay up until the process
-	// is killed, to avoid liveness checks failing during the graceful shutdown.
-	var addr string
-	if *bind == "*" {
-		addr = ":" + *port
-	} else {
-		addr = *bind + ":" + *port
-	}
-	httpServer := &http.Server{
-		Addr:              addr,
-		Handler:           http.DefaultServeMux,
-		ReadHeaderTimeout: 3 * time.Second,
-	}
-	go func() {
-		err := httpServer.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			logger.With(errKey, err).Error("http listener error")
-			os.Exit(1)
-		}
-	}()
+	// Support graceful shutdown.
+	p.ctx, p.cancel = context.WithCancel(context.Background())
 
-	// Create a wait group which is used to wait while draining (gracefully
-	// closing) a connection.
-	gracefulCloseWG := sync.WaitGroup{}
+	// Initialize tracing, if an OTLP endpoint is configured.
+	p.shutdownTracing, err = initTracing(p.ctx, cfg)
+	if err != nil {
+		logger.With(errKey, err).Error("error initializing tracing")
+		os.Exit(1)
+	}
 
-	// Support graceful shutdown.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	// Load reloadable settings, and watch the config file for changes.
+	initRuntimeSettings(p.ctx, cfg)
+	if cfg.ConfigFile != "" {
+		backgroundTasks.start(p.ctx, "config_file", defaultTaskRestartPolicy, func(ctx context.Context) {
+			watchConfigFile(ctx, cfg)
+		})
+	}
 
 	// Initialize JWT client for v2 services
 	if err := initJWTClient(cfg); err != nil {
@@ -153,37 +209,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Initialize Zoom API client for historical past meeting backfill and
+	// transcript downloads
+	if len(cfg.ZoomBackfillMeetingIDs) > 0 || cfg.TranscriptContentEnabled {
+		initZoomClient(cfg)
+	}
+
+	// Parse the message signing key, if signing is enabled
+	if err := initMessageSigning(cfg); err != nil {
+		logger.With(errKey, err).Error("error initializing message signing")
+		os.Exit(1)
+	}
+
+	// Initialize the summary translation provider, if any
+	if err := initSummaryTranslation(cfg); err != nil {
+		logger.With(errKey, err).Error("error initializing summary translation")
+		os.Exit(1)
+	}
+
 	// Create NATS connection.
-	gracefulCloseWG.Add(1)
-	natsConn, err = nats.Connect(
-		cfg.NATSURL,
-		nats.DrainTimeout(gracefulShutdownSeconds*time.Second),
-		nats.ErrorHandler(func(_ *nats.Conn, s *nats.Subscription, err error) {
-			if s != nil {
-				logger.With(errKey, err, "subject", s.Subject, "queue", s.Queue).Error("async NATS error")
-			} else {
-				logger.With(errKey, err).Error("async NATS error outside subscription")
-			}
-		}),
-		nats.ClosedHandler(func(_ *nats.Conn) {
-			if ctx.Err() != nil {
-				// If our parent background context has already been canceled, this is
-				// a graceful shutdown. Decrement the wait group but do not exit, to
-				// allow other graceful shutdown steps to complete.
-				gracefulCloseWG.Done()
-				return
-			}
-			// Otherwise, this handler means that max reconnect attempts have been
-			// exhausted.
-			logger.Error("NATS max-reconnects exhausted; connection closed")
-			// Send a synthetic interrupt and give any graceful-shutdown tasks 5
-			// seconds to clean up.
-			done <- os.Interrupt
-			time.Sleep(5 * time.Second)
-			// Exit with an error instead of decrementing the wait group.
-			os.Exit(1)
-		}),
-	)
+	natsConn, err = bootstrap.ConnectNATS(p.ctx, logger, cfg.NATSURL, &p.gracefulCloseWG, p.done)
 	if err != nil {
 		logger.With(errKey, err).Error("error creating NATS client")
 		os.Exit(1)
@@ -196,131 +241,278 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Connect the additional publish targets, if any.
+	publishTargetSpecs, err := parsePublishTargets(cfg.PublishTargets)
+	if err == nil {
+		err = connectPublishTargets(publishTargetSpecs)
+	}
+	if err != nil {
+		logger.With(errKey, err).Error("error connecting publish targets")
+		os.Exit(1)
+	}
+
+	return p
+}
+
+// openBuckets runs the preflight checks (unless skipped), then opens the KV
+// buckets and initializes the distributed sync singleton.
+func (p *syncProcess) openBuckets() {
+	// Verify buckets, streams, subjects, and client authentication before
+	// starting any consumers.
+	if !cfg.SkipPreflight {
+		if err := runPreflightChecks(p.ctx); err != nil {
+			logger.With(errKey, err).Error("startup preflight checks failed")
+			os.Exit(1)
+		}
+	}
+
 	// Create KV bucket connections for v1 objects (from Meltano)
-	v1KV, err = jsContext.KeyValue(ctx, "v1-objects")
+	var err error
+	v1KV, err = jsContext.KeyValue(p.ctx, "v1-objects")
 	if err != nil {
 		logger.With(errKey, err).Error("error accessing v1-objects KV bucket")
 		os.Exit(1)
 	}
 
 	// Create v1 mappings KV bucket for storing v1 ID mappings
-	mappingsKV, err = jsContext.KeyValue(ctx, "v1-mappings")
+	primaryMappingsKV, err := jsContext.KeyValue(p.ctx, "v1-mappings")
 	if err != nil {
 		logger.With(errKey, err).Error("error accessing v1-mappings KV bucket")
 		os.Exit(1)
 	}
+	mappingsKV = primaryMappingsKV
+
+	// Fall back to the mirror bucket for mapping reads, when configured.
+	if cfg.MappingsMirrorBucket != "" {
+		mirrorKV, err := jsContext.KeyValue(p.ctx, cfg.MappingsMirrorBucket)
+		if err != nil {
+			logger.With(errKey, err, "bucket", cfg.MappingsMirrorBucket).Error("error accessing mappings mirror KV bucket")
+			os.Exit(1)
+		}
+		mappingsKV = newFailoverKV(primaryMappingsKV, mirrorKV)
+	}
 
-	// Initialize the distributed sync singleton backed by the mappings KV bucket.
-	distributedSync = newKVMappingLocker(mappingsKV,
+	// Initialize the distributed sync singleton backed by the mappings KV
+	// bucket. Locks must not be read from the mirror bucket.
+	distributedSync = newKVMappingLocker(primaryMappingsKV,
 		withLockerOptionMaxRetries(mappingLockRetryAttempts),
 		withLockerOptionRetryInterval(mappingLockRetryInterval),
 		withLockerOptionTimeout(mappingLockTimeout),
 	)
+}
 
-	// Create or get the JetStream pull consumer for v1 objects KV bucket
-	// This replaces the KV Watch() method to enable horizontal scaling
-	consumerName := "v1-sync-helper-kv-consumer"
-	streamName := "KV_v1-objects"
+// shutdown cancels the background context, then drains the NATS connection,
+// which drains all remaining subscriptions, and waits for it to close.
+func (p *syncProcess) shutdown() {
+	p.cancel()
 
-	consumer, err := jsContext.CreateOrUpdateConsumer(ctx, streamName, jetstream.ConsumerConfig{
-		Name:          consumerName,
-		Durable:       consumerName,
-		DeliverPolicy: jetstream.DeliverLastPerSubjectPolicy,
-		AckPolicy:     jetstream.AckExplicitPolicy,
-		FilterSubject: "$KV.v1-objects.>",
-		MaxDeliver:    3,
-		AckWait:       30 * time.Second,
-		MaxAckPending: 1000,
-		Description:   "durable/shared KV bucket watcher for v1-sync-helper pods",
+	// Flush the batched meeting mapping updates, while their messages can
+	// still be published.
+	meetingMappingBatches.close()
+
+	// Write the schemas inferred since they were last written.
+	if cfg.SchemaInferenceDir != "" {
+		writeInferredSchemas(context.Background())
+	}
+
+	// Flush the publish targets first, as they dead-letter to the primary
+	// connection.
+	flushCtx, cancel := context.WithTimeout(context.Background(), bootstrap.GracefulShutdownSeconds*time.Second)
+	closePublishTargets(flushCtx)
+	cancel()
+
+	if err := bootstrap.DrainNATS(logger, natsConn, &p.gracefulCloseWG); err != nil {
+		logger.With(errKey, err).Error("error draining NATS connection")
+		os.Exit(1)
+	}
+
+	// Export the spans ended while draining.
+	tracingCtx, cancel := context.WithTimeout(context.Background(), bootstrap.GracefulShutdownSeconds*time.Second)
+	defer cancel()
+	if err := p.shutdownTracing(tracingCtx); err != nil {
+		logger.With(errKey, err).Warn("error flushing traces")
+	}
+}
+
+// runSync runs the sync service until SIGINT or SIGTERM is received, or NATS
+// disconnects.
+func runSync(name string, args []string) {
+	var port, bind, adminPort, adminBind, acknowledgeRecreatedStreams *string
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		port = flags.String("p", cfg.Port, "health checks port")
+		bind = flags.String("bind", cfg.Bind, "interface to bind on")
+		adminPort = flags.String("admin-p", cfg.AdminPort, "admin (metrics and diagnostics) port")
+		adminBind = flags.String("admin-bind", cfg.AdminBind, "interface to bind the admin server on")
+		acknowledgeRecreatedStreams = flags.String("acknowledge-recreated-streams", strings.Join(cfg.AcknowledgeRecreatedStreams, ","), "comma-separated streams whose recreation is acknowledged, so consuming them resumes")
 	})
-	if err != nil {
-		logger.With(errKey, err, "consumer", consumerName, "stream", streamName).Error("error creating JetStream pull consumer")
+	defer p.cancel()
+
+	// Serve the health checks and the admin endpoints on separate servers, so
+	// the admin surface can be bound and protected independently.
+	healthServer := bootstrap.StartHTTPServer(logger, "health", bootstrap.ListenAddr(*bind, *port), bootstrap.NewHealthMux(func() *nats.Conn { return natsConn }, backgroundTasks.readiness, downstreamHealth.readiness, subjectCoverage.readiness))
+	adminServer := bootstrap.StartHTTPServer(logger, "admin", bootstrap.ListenAddr(*adminBind, *adminPort), newAdminMux())
+
+	p.openBuckets()
+	ctx := p.ctx
+
+	failoverMappingsKV, _ := mappingsKV.(*failoverKV)
+
+	// Serve the reads of the hot mapping keys from memory, when configured.
+	if len(cfg.MappingsMemoryMirrorPrefixes) > 0 {
+		memoryMirror := newMemoryMirrorKV(mappingsKV, cfg.MappingsMemoryMirrorPrefixes)
+		mappingsKV = memoryMirror
+		backgroundTasks.start(ctx, "mappings_memory_mirror", defaultTaskRestartPolicy, memoryMirror.watch)
+	}
+
+	// Canary mode runs candidate handlers in dry runs, whose side effects are
+	// recorded by the wrapped buckets and v2 HTTP client.
+	if cfg.CanaryPercent > 0 {
+		installDryRunHooks()
+		logger.With("percent", cfg.CanaryPercent, "candidates", canaryPrefixes()).Info("canary mode enabled")
+	}
+	// Config change dry runs run the sampled records in dry runs too.
+	if cfg.ConfigDiffSamples > 0 {
+		installDryRunHooks()
+	}
+
+	// Refuse to consume streams recreated since they were last consumed, as
+	// their new consumers would replay (or skip) every change.
+	if err := checkStreamRecreation(ctx, bootstrap.ParseList(*acknowledgeRecreatedStreams)); err != nil {
+		logger.With(errKey, err).Error("stream recreation check failed")
 		os.Exit(1)
 	}
 
-	// Start consuming KV updates using the JetStream consumer with error handling.
-	kvConsumerCtx, err := consumer.Consume(kvMessageHandler, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
-		logger.With(errKey, err).Error("KV consumer error encountered")
-	}))
+	// Consumers which cannot be recreated after an unrecoverable error shut
+	// the service down with a failure, so it is restarted.
+	var consumerFailed atomic.Bool
+	terminate := func(error) {
+		consumerFailed.Store(true)
+		select {
+		case p.done <- syscall.SIGTERM:
+		default:
+		}
+	}
+
+	// Create or get the JetStream pull consumer for v1 objects KV bucket
+	// This replaces the KV Watch() method to enable horizontal scaling
+	consumerName := kvConsumerName
+	streamName := kvStreamName
+
+	kvConsumer, err := startSupervisedConsumer(ctx, streamName, kvConsumerConfig(), newKVMessageHandler(consumerName), terminate)
 	if err != nil {
-		logger.With(errKey, err, "consumer", consumerName).Error("error starting KV consumer")
+		logger.With(errKey, err, "consumer", consumerName, "stream", streamName).Error("error starting KV consumer")
 		os.Exit(1)
 	}
-	defer kvConsumerCtx.Stop()
+	defer kvConsumer.Stop()
+
+	// Start the dedicated consumers of the key prefixes with their own
+	// delivery settings.
+	var prefixConsumers []*supervisedConsumer
+	for _, prefix := range kvPrefixes() {
+		prefixConsumerConfig := kvPrefixConsumerConfig(prefix)
+		prefixConsumer, err := startSupervisedConsumer(ctx, streamName, prefixConsumerConfig, newKVMessageHandler(prefixConsumerConfig.Durable), terminate)
+		if err != nil {
+			logger.With(errKey, err, "consumer", prefixConsumerConfig.Durable, "stream", streamName, "prefix", prefix).Error("error starting KV prefix consumer")
+			os.Exit(1)
+		}
+		defer prefixConsumer.Stop()
+		prefixConsumers = append(prefixConsumers, prefixConsumer)
+	}
 
 	// Subscribe to WAL-listener events from the wal_listener stream
 	walStreamName := "wal_listener"
-	walConsumerName := "v1-sync-helper-wal-consumer"
 
 	// Create or get consumer for WAL listener events
-	walConsumer, err := jsContext.CreateOrUpdateConsumer(ctx, walStreamName, jetstream.ConsumerConfig{
+	walConsumer, err := startSupervisedConsumer(ctx, walStreamName, jetstream.ConsumerConfig{
 		Name:          walConsumerName,
 		Durable:       walConsumerName,
 		DeliverPolicy: jetstream.DeliverAllPolicy,
 		AckPolicy:     jetstream.AckExplicitPolicy,
 		FilterSubject: "wal_listener.*",
-		MaxDeliver:    3,
-		AckWait:       30 * time.Second,
+		MaxDeliver:    consumerMaxDeliver,
+		AckWait:       consumerAckWait,
 		MaxAckPending: 100,
 		Description:   "WAL listener consumer for v1-sync-helper",
-	})
-	if err != nil {
-		logger.With(errKey, err, "consumer", walConsumerName, "stream", walStreamName).Error("error creating WAL listener consumer")
-		os.Exit(1)
-	}
-
-	// Start consuming WAL listener messages with error handling.
-	walConsumerCtx, err := walConsumer.Consume(walIngestHandler, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
-		logger.With(errKey, err).Error("WAL consumer error encountered")
-	}))
+	}, walIngestHandler, terminate)
 	if err != nil {
-		logger.With(errKey, err, "consumer", walConsumerName).Error("error starting WAL listener consumer")
+		logger.With(errKey, err, "consumer", walConsumerName, "stream", walStreamName).Error("error starting WAL listener consumer")
 		os.Exit(1)
 	}
-	defer walConsumerCtx.Stop()
+	defer walConsumer.Stop()
 
 	// Optionally subscribe to DynamoDB stream events.
-	var dynamodbConsumerCtx jetstream.ConsumeContext
+	var dynamodbConsumer *supervisedConsumer
 	if cfg.DynamoDBIngestEnabled {
 		dynamodbStreamName := cfg.DynamoDBStreamName
-		dynamodbConsumerName := "v1-sync-helper-dynamodb-consumer"
 
-		dynamodbConsumer, err := jsContext.CreateOrUpdateConsumer(ctx, dynamodbStreamName, jetstream.ConsumerConfig{
+		dynamodbConsumer, err = startSupervisedConsumer(ctx, dynamodbStreamName, jetstream.ConsumerConfig{
 			Name:          dynamodbConsumerName,
 			Durable:       dynamodbConsumerName,
 			DeliverPolicy: jetstream.DeliverAllPolicy,
 			AckPolicy:     jetstream.AckExplicitPolicy,
 			FilterSubject: dynamodbStreamName + ".>",
-			MaxDeliver:    3,
-			AckWait:       30 * time.Second,
+			MaxDeliver:    consumerMaxDeliver,
+			AckWait:       consumerAckWait,
 			MaxAckPending: 100,
 			Description:   "DynamoDB stream consumer for v1-sync-helper",
-		})
+		}, dynamodbIngestHandler, terminate)
 		if err != nil {
-			logger.With(errKey, err, "consumer", dynamodbConsumerName, "stream", dynamodbStreamName).Error("error creating DynamoDB stream consumer")
+			logger.With(errKey, err, "consumer", dynamodbConsumerName, "stream", dynamodbStreamName).Error("error starting DynamoDB stream consumer")
 			os.Exit(1)
 		}
-
-		dynamodbConsumerCtx, err = dynamodbConsumer.Consume(dynamodbIngestHandler, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
-			logger.With(errKey, err).Error("DynamoDB stream consumer error encountered")
-		}))
-		if err != nil {
-			logger.With(errKey, err, "consumer", dynamodbConsumerName).Error("error starting DynamoDB stream consumer")
-			os.Exit(1)
-		}
-		defer dynamodbConsumerCtx.Stop()
+		defer dynamodbConsumer.Stop()
 
 		logger.With("stream", dynamodbStreamName, "consumer", dynamodbConsumerName).Info("DynamoDB stream consumer started")
 	}
 
-	// Subscribe to the lookup function for bidirectional v1-v2 mapping queries.
+	// Periodically correct or report drift of the consumer configurations,
+	// and run the other background jobs, restarted if they exit.
+	backgroundTasks.start(ctx, "consumer_drift", defaultTaskRestartPolicy, watchConsumerDrift)
+	backgroundTasks.start(ctx, "participant_counts", defaultTaskRestartPolicy, watchParticipantCounts)
+	backgroundTasks.start(ctx, "parked_records", defaultTaskRestartPolicy, watchParkedRecords)
+	backgroundTasks.start(ctx, "paused_records", defaultTaskRestartPolicy, watchPausedRecords)
+	backgroundTasks.start(ctx, "cascade_jobs", defaultTaskRestartPolicy, watchCascadeJobs)
+	backgroundTasks.start(ctx, "mass_purge_state", defaultTaskRestartPolicy, watchMassPurgeState)
+	if cfg.SchemaInferenceDir != "" {
+		backgroundTasks.start(ctx, "schema_inference", defaultTaskRestartPolicy, watchSchemaInference)
+	}
+	if cfg.SubjectCoverageInterval > 0 {
+		backgroundTasks.start(ctx, "subject_coverage", defaultTaskRestartPolicy, watchSubjectCoverage)
+	}
+	if checks, _ := parseDownstreamHealthChecks(cfg.DownstreamHealthChecks); len(checks) > 0 {
+		backgroundTasks.start(ctx, "downstream_health", defaultTaskRestartPolicy, func(ctx context.Context) {
+			watchDownstreamHealth(ctx, checks)
+		})
+	}
+	if failoverMappingsKV != nil {
+		backgroundTasks.start(ctx, "mappings_consistency", defaultTaskRestartPolicy, func(ctx context.Context) {
+			watchMappingsConsistency(ctx, failoverMappingsKV)
+		})
+	}
+
+	// Register as a NATS micro service, for the platform's service discovery
+	// and stats.
+	service, err := bootstrap.AddService(natsConn, serviceName, "Syncs LFX v1 data to LFX v2 services", statusSubject, processingStats)
+	if err != nil {
+		logger.With(errKey, err).Error("error registering NATS micro service")
+		os.Exit(1)
+	}
+
+	// Serve the lookup function for bidirectional v1-v2 mapping queries.
 	// Supports both v1->v2 and v2->v1 lookups depending on the key format used.
-	_, err = natsConn.QueueSubscribe(lookupSubject, natsQueue, lookupHandler)
+	err = service.AddEndpoint("lookup_v1_mapping", micro.HandlerFunc(lookupHandler), micro.WithEndpointSubject(lookupSubject), micro.WithEndpointQueueGroup(natsQueue))
 	if err != nil {
 		logger.With(errKey, err, "subject", lookupSubject).Error("error subscribing to NATS lookup subject")
 		os.Exit(1)
 	}
 
+	// Serve the dependency chain of v1 records, for support queries.
+	err = service.AddEndpoint("trace_v1_dependencies", micro.HandlerFunc(traceDependenciesHandler), micro.WithEndpointSubject(traceSubject), micro.WithEndpointQueueGroup(natsQueue))
+	if err != nil {
+		logger.With(errKey, err, "subject", traceSubject).Error("error subscribing to NATS dependency trace subject")
+		os.Exit(1)
+	}
+
 	// Subscribe to indexer domain events for bidirectional committee sync.
 	// The indexer publishes lfx.{object_type}.{action} after every successful OpenSearch write.
 	indexerEventSubscriptions := map[string]func(*nats.Msg){
@@ -338,40 +530,56 @@ func main() {
 		}
 	}
 
+	// Consume the indexer's results, to re-enqueue or dead-letter failed
+	// documents.
+	if err = subscribeIndexerResults(); err != nil {
+		logger.With(errKey, err, "subject", cfg.IndexerResultSubject).Error("error subscribing to indexer result subject")
+		os.Exit(1)
+	}
+
+	// Backfill historical past meetings from the Zoom API, now that the KV
+	// consumer is running to propagate the backfilled records.
+	if len(cfg.ZoomBackfillMeetingIDs) > 0 {
+		backgroundTasks.start(ctx, "zoom_backfill", oneShotTaskRestartPolicy, runZoomBackfill)
+	}
+
 	// This next line blocks until SIGINT or SIGTERM is received, or NATS disconnects.
-	<-done
+	<-p.done
 
 	// Begin graceful shutdown process.
 	logger.Debug("beginning graceful shutdown")
 
 	// Drain consumers first (non-blocking) to mitigate "nats: connection closed"
 	// errors in the ConsumeErrHandler.
-	kvConsumerCtx.Drain()
-	walConsumerCtx.Drain()
-	if dynamodbConsumerCtx != nil {
-		dynamodbConsumerCtx.Drain()
+	kvConsumer.Drain()
+	for _, prefixConsumer := range prefixConsumers {
+		prefixConsumer.Drain()
+	}
+	walConsumer.Drain()
+	if dynamodbConsumer != nil {
+		dynamodbConsumer.Drain()
 	}
 
-	// Cancel the background context.
-	cancel()
-
-	// Drain the connection, which will drain all remaining subscriptions, then
-	// close the connection when complete (including the consumer draining).
-	if !natsConn.IsClosed() && !natsConn.IsDraining() {
-		logger.Info("draining NATS connection")
-		if err := natsConn.Drain(); err != nil {
-			logger.With(errKey, err).Error("error draining NATS connection")
-			os.Exit(1)
-		}
+	// Deregister the service; its endpoint subscriptions would otherwise be
+	// drained with the connection.
+	if err := service.Stop(); err != nil {
+		logger.With(errKey, err).Error("error stopping NATS micro service")
 	}
 
-	// Wait for the graceful shutdown steps to complete.
-	logger.Debug("waiting for graceful shutdown steps to complete")
-	gracefulCloseWG.Wait()
-	logger.Debug("graceful shutdown steps completed")
+	// Cancel the background context and drain the connection, including the
+	// consumer draining.
+	p.shutdown()
 
-	// Immediately close the HTTP server after graceful shutdown has finished.
-	if err = httpServer.Close(); err != nil {
+	// Immediately close the HTTP servers after graceful shutdown has finished.
+	if err := adminServer.Close(); err != nil {
+		logger.With(errKey, err).Error("admin http listener error on close")
+	}
+	if err := healthServer.Close(); err != nil {
 		logger.With(errKey, err).Error("http listener error on close")
 	}
+
+	if consumerFailed.Load() {
+		logger.Error("exiting after a consumer could not be recreated")
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,397 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Mapping dependencies. Child records (registrants, attendees, vote
+// responses, committee members, ...) can only be synced once their parent has
+// a v1-mappings entry. Each key prefix declares the parent mappings its
+// records require (the requires field of its kvTableHandler), and the
+// dispatcher checks them before calling the update handler. A record whose
+// parent mapping is missing is parked under parkedRecordKeyPrefix in the
+// v1-mappings bucket, instead of being retried or skipped, and is re-run
+// through the dispatcher when the parent appears: right after a parent record
+// is synced, and by a periodic sweep of the parked records for parents synced
+// by another replica or by other means. Records whose parent will never be
+// synced (skipped, or deleted before it was synced) are not parked, and the
+// sweep drops the records parked longer than PARKED_RECORD_MAX_AGE.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// parkedRecordKeyPrefix prefixes the v1-mappings keys of parked records,
+	// followed by the parent mapping key and the v1-objects key of the record.
+	parkedRecordKeyPrefix = "v1_parked_records."
+
+	// parkedParentKeyPrefix prefixes the v1-mappings keys marking the parent
+	// mappings with parked records, followed by the parent mapping key, so
+	// syncing a parent only lists the parked records when there are some.
+	parkedParentKeyPrefix = "v1_parked_parents."
+
+	// parkedRecordSweepInterval is how often the parked records are checked
+	// for parents which have appeared.
+	parkedRecordSweepInterval = time.Minute
+
+	// parkedRecordReleaseTimeout bounds the release of the parked records of
+	// a parent.
+	parkedRecordReleaseTimeout = 5 * time.Minute
+
+	// defaultParkedRecordMaxAge is the default age after which the records
+	// still waiting for their parent mapping are dropped.
+	defaultParkedRecordMaxAge = 7 * 24 * time.Hour
+)
+
+var parkedRecords = newCounterVec(
+	"v1_sync_helper_parked_records_total",
+	"Number of records parked until a parent mapping appears, by object type, parent, and result (parked, released, retried, dropped, expired, or parent_skipped and parent_deleted for records not parked).",
+	"object_type", "parent", "result",
+)
+
+// mappingParent describes the v1-mappings entries of a parent object type.
+type mappingParent struct {
+	// name identifies the parent in logs and metrics.
+	name string
+	// keyFmt is the v1-mappings key of a parent, formatted with its v1 ID.
+	keyFmt string
+	// prefix is the v1-objects key prefix of the parent records.
+	prefix string
+	// idField is the field of the parent records holding their v1 ID. When
+	// empty, the ID is the part of the key after the prefix.
+	idField string
+	// resolvable, if set, reports whether a parent missing its mapping can be
+	// resolved by other means, so its child records are handled rather than
+	// parked.
+	resolvable func(ctx context.Context, parentID string) (bool, error)
+}
+
+// Parents required by child records.
+var (
+	projectMappingParent     = mappingParent{name: "project", keyFmt: "project.sfid.%s", prefix: "salesforce-project__c", resolvable: projectSFIDResolvable}
+	committeeMappingParent   = mappingParent{name: "committee", keyFmt: "committee.sfid.%s", prefix: "platform-collaboration__c"}
+	meetingMappingParent     = mappingParent{name: "meeting", keyFmt: "v1_meetings.%s", prefix: "itx-zoom-meetings-v2", idField: "meeting_id"}
+	pastMeetingMappingParent = mappingParent{name: "past_meeting", keyFmt: "v1_past_meetings.%s", prefix: "itx-zoom-past-meetings", idField: "meeting_and_occurrence_id"}
+	voteMappingParent        = mappingParent{name: "vote", keyFmt: "vote.%s", prefix: "itx-poll", idField: "poll_id"}
+	surveyMappingParent      = mappingParent{name: "survey", keyFmt: "survey.%s", prefix: "itx-surveys", idField: "id"}
+)
+
+// Dependencies of the meeting and past meeting child records.
+var (
+	meetingChildDependencies     = []mappingDependency{meetingMappingParent.requiredBy("meeting_id")}
+	pastMeetingChildDependencies = []mappingDependency{pastMeetingMappingParent.requiredBy("meeting_and_occurrence_id")}
+)
+
+// mappingDependency is a parent mapping required by the records of a key
+// prefix.
+type mappingDependency struct {
+	parent mappingParent
+	// field is the field of the records holding the parent's v1 ID. Records
+	// without one are passed to the handler, which validates it.
+	field string
+}
+
+// requiredBy returns the dependency of records holding the parent ID in
+// field.
+func (p mappingParent) requiredBy(field string) mappingDependency {
+	return mappingDependency{parent: p, field: field}
+}
+
+// parkedRecord is the v1-mappings value of a parked record.
+type parkedRecord struct {
+	Key        string    `json:"key"`
+	Parent     string    `json:"parent"`
+	MappingKey string    `json:"mapping_key"`
+	ParkedAt   time.Time `json:"parked_at"`
+}
+
+// v1FieldString returns a v1 record field as a string, formatting numeric
+// IDs.
+func v1FieldString(v1Data map[string]any, field string) string {
+	switch v := v1Data[field].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
+
+// missingMappingDependency returns the first dependency of a record whose
+// parent mapping does not exist (and which cannot be resolved otherwise),
+// with the parent mapping key.
+func missingMappingDependency(ctx context.Context, dependencies []mappingDependency, v1Data map[string]any) (*mappingDependency, string, error) {
+	for i, dependency := range dependencies {
+		parentID := v1FieldString(v1Data, dependency.field)
+		if parentID == "" {
+			continue
+		}
+		mappingKey := fmt.Sprintf(dependency.parent.keyFmt, parentID)
+		if _, err := mappingsKV.Get(ctx, mappingKey); err != nil {
+			if errors.Is(err, jetstream.ErrKeyNotFound) {
+				if dependency.parent.resolvable != nil {
+					resolvable, err := dependency.parent.resolvable(ctx, parentID)
+					if err != nil {
+						logger.With(errKey, err, "mapping_key", mappingKey).WarnContext(ctx, "failed to resolve missing parent mapping")
+					}
+					if resolvable {
+						continue
+					}
+				}
+				return &dependencies[i], mappingKey, nil
+			}
+			return nil, "", fmt.Errorf("failed to get parent %s mapping %s: %w", dependency.parent.name, mappingKey, err)
+		}
+	}
+	return nil, "", nil
+}
+
+// checkMappingDependencies parks a record whose parent mapping is missing.
+// It returns whether the record was parked (or failed to be) and must not be
+// handled, and whether the message should be retried.
+func checkMappingDependencies(ctx context.Context, objectType, key string, dependencies []mappingDependency, v1Data map[string]any) (stop, retry bool) {
+	if len(dependencies) == 0 {
+		return false, false
+	}
+	log := logger.With("key", key)
+	dependency, mappingKey, err := missingMappingDependency(ctx, dependencies, v1Data)
+	if err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to check parent mappings")
+		return true, true
+	}
+	if dependency == nil {
+		return false, false
+	}
+
+	log = log.With("parent", dependency.parent.name, "mapping_key", mappingKey)
+	if reason, err := unsyncableParent(ctx, dependency, v1Data); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to check parent record")
+		return true, true
+	} else if reason != "" {
+		parkedRecords.inc(objectType, dependency.parent.name, reason)
+		log.With("reason", reason).InfoContext(ctx, "parent mapping not found and parent will not be synced, skipping record")
+		return true, false
+	}
+
+	value, err := json.Marshal(parkedRecord{Key: key, Parent: dependency.parent.name, MappingKey: mappingKey, ParkedAt: bootstrap.Now().UTC()})
+	if err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to marshal parked record")
+		return true, false
+	}
+	if err := parkRecord(ctx, parkedRecordKeyPrefix+mappingKey+"."+key, mappingKey, value); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to park record until parent mapping appears")
+		return true, true
+	}
+	parkedRecords.inc(objectType, dependency.parent.name, "parked")
+	log.InfoContext(ctx, "parent mapping not found, parked record until it appears")
+	return true, false
+}
+
+// unsyncableParent returns why the parent of a record whose mapping is missing
+// will never be synced, as a parkedRecords result: parent_skipped for a
+// parent skipped by the sync (out of project scope, or originated in v2), or
+// parent_deleted for a parent deleted before it was synced. Returns an empty
+// string for a parent which may still be synced, including one not in
+// v1-objects yet.
+func unsyncableParent(ctx context.Context, dependency *mappingDependency, v1Data map[string]any) (string, error) {
+	parentKey := fmt.Sprintf("%s.%s", dependency.parent.prefix, v1FieldString(v1Data, dependency.field))
+	parentData, exists, err := getV1ObjectData(ctx, parentKey)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		// Deleted records are reported missing too, whether soft deleted or
+		// hard deleted, but have a history.
+		if _, err := v1KV.History(ctx, parentKey); err != nil {
+			if errors.Is(err, jetstream.ErrKeyNotFound) {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to get history of parent record %s: %w", parentKey, err)
+		}
+		return "parent_deleted", nil
+	}
+	if shouldSkipSync(ctx, parentData) {
+		return "parent_skipped", nil
+	}
+	return "", nil
+}
+
+// parkRecord stores a parked record, and marks its parent mapping as having
+// parked records.
+func parkRecord(ctx context.Context, parkedKey, mappingKey string, value []byte) error {
+	if _, err := mappingsKV.Put(ctx, parkedKey, value); err != nil {
+		return err
+	}
+	_, err := mappingsKV.Put(ctx, parkedParentKeyPrefix+mappingKey, []byte(bootstrap.Now().UTC().Format(time.RFC3339)))
+	return err
+}
+
+// releaseDependents re-runs, in the background, the records parked on the
+// mappings of a parent record which was just synced.
+func releaseDependents(ctx context.Context, prefix, key string, v1Data map[string]any) {
+	if contextDryRun(ctx) != nil {
+		return
+	}
+	for _, parent := range []mappingParent{
+		projectMappingParent,
+		committeeMappingParent,
+		meetingMappingParent,
+		pastMeetingMappingParent,
+		voteMappingParent,
+		surveyMappingParent,
+	} {
+		if parent.prefix != prefix {
+			continue
+		}
+		_, parentID, _ := strings.Cut(key, ".")
+		if parent.idField != "" {
+			parentID = v1FieldString(v1Data, parent.idField)
+		}
+		if parentID == "" {
+			continue
+		}
+		mappingKey := fmt.Sprintf(parent.keyFmt, parentID)
+		go func() {
+			releaseCtx, cancel := context.WithTimeout(detachMessageContext(ctx), parkedRecordReleaseTimeout)
+			defer cancel()
+			releaseParkedRecords(releaseCtx, mappingKey)
+		}()
+	}
+}
+
+// releaseParkedRecords re-runs the records parked on a parent mapping, if it
+// exists. The parent's marker is removed before listing its parked records,
+// so records parked meanwhile mark it again.
+func releaseParkedRecords(ctx context.Context, mappingKey string) {
+	markerKey := parkedParentKeyPrefix + mappingKey
+	if _, err := mappingsKV.Get(ctx, markerKey); err != nil {
+		return
+	}
+	if _, err := mappingsKV.Get(ctx, mappingKey); err != nil {
+		return
+	}
+	if err := mappingsKV.Delete(ctx, markerKey); err != nil {
+		logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "failed to clear parked records marker")
+		return
+	}
+	lister, err := mappingsKV.ListKeysFiltered(ctx, parkedRecordKeyPrefix+mappingKey+".>")
+	if err != nil {
+		logger.With(errKey, err, "mapping_key", mappingKey).ErrorContext(ctx, "failed to list parked records")
+		return
+	}
+	for parkedKey := range lister.Keys() {
+		releaseParkedRecord(ctx, parkedKey)
+	}
+}
+
+// releaseParkedRecord claims a parked record, by deleting it at its current
+// revision so no other replica releases it too, and re-runs it through the
+// dispatcher. Records to retry are parked again, for the next sweep.
+func releaseParkedRecord(ctx context.Context, parkedKey string) {
+	log := logger.With("parked_key", parkedKey)
+	parkedEntry, err := mappingsKV.Get(ctx, parkedKey)
+	if err != nil {
+		return
+	}
+	var parked parkedRecord
+	if err := json.Unmarshal(parkedEntry.Value(), &parked); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to unmarshal parked record")
+		return
+	}
+	if err := mappingsKV.Delete(ctx, parkedKey, jetstream.LastRevision(parkedEntry.Revision())); err != nil {
+		// Claimed by another replica.
+		return
+	}
+
+	log = log.With("key", parked.Key, "parent", parked.Parent)
+	objectType := kvObjectType(parked.Key)
+	entry, err := v1KV.Get(ctx, parked.Key)
+	if err != nil {
+		parkedRecords.inc(objectType, parked.Parent, "dropped")
+		log.With(errKey, err).InfoContext(ctx, "dropped parked record no longer in v1-objects")
+		return
+	}
+
+	if kvHandler(ctx, entry) {
+		parkedRecords.inc(objectType, parked.Parent, "retried")
+		if err := parkRecord(ctx, parkedKey, parked.MappingKey, parkedEntry.Value()); err != nil {
+			log.With(errKey, err).ErrorContext(ctx, "failed to park record again for retry")
+		}
+		return
+	}
+	parkedRecords.inc(objectType, parked.Parent, "released")
+	log.InfoContext(ctx, "released parked record")
+}
+
+// watchParkedRecords releases the parked records whose parent mappings have
+// appeared every parkedRecordSweepInterval, until the context is cancelled.
+func watchParkedRecords(ctx context.Context) {
+	ticker := time.NewTicker(parkedRecordSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		sweepParkedRecords(ctx)
+	}
+}
+
+// sweepParkedRecords releases the parked records whose parent mappings exist,
+// and drops those parked for longer than PARKED_RECORD_MAX_AGE.
+func sweepParkedRecords(ctx context.Context) {
+	lister, err := mappingsKV.ListKeysFiltered(ctx, parkedRecordKeyPrefix+">")
+	if err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to list parked records")
+		return
+	}
+	for parkedKey := range lister.Keys() {
+		if ctx.Err() != nil {
+			_ = lister.Stop()
+			return
+		}
+		entry, err := mappingsKV.Get(ctx, parkedKey)
+		if err != nil {
+			continue
+		}
+		var parked parkedRecord
+		if err := json.Unmarshal(entry.Value(), &parked); err != nil {
+			continue
+		}
+		if _, err := mappingsKV.Get(ctx, parked.MappingKey); err == nil {
+			releaseParkedRecord(ctx, parkedKey)
+			continue
+		}
+		if maxAge := cfg.ParkedRecordMaxAge; maxAge > 0 && bootstrap.Now().Sub(parked.ParkedAt) > maxAge {
+			expireParkedRecord(ctx, parkedKey, entry.Revision(), parked)
+		}
+	}
+}
+
+// expireParkedRecord drops a record parked for longer than
+// PARKED_RECORD_MAX_AGE, at the revision it was listed at so a record parked
+// again meanwhile is kept.
+func expireParkedRecord(ctx context.Context, parkedKey string, revision uint64, parked parkedRecord) {
+	log := logger.With("parked_key", parkedKey, "key", parked.Key, "parent", parked.Parent, "parked_at", parked.ParkedAt)
+	if err := mappingsKV.Delete(ctx, parkedKey, jetstream.LastRevision(revision)); err != nil {
+		log.With(errKey, err).DebugContext(ctx, "parked record changed, not expiring it")
+		return
+	}
+	parkedRecords.inc(kvObjectType(parked.Key), parked.Parent, "expired")
+	log.WarnContext(ctx, "dropped record parked for longer than the maximum age, its parent mapping never appeared")
+}
@@ -0,0 +1,122 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/testkit"
+)
+
+func TestCheckMappingDependencies(t *testing.T) {
+	const (
+		meetingID   = "91234567890"
+		projectSFID = "a0941000002wBz9AAE"
+	)
+	meeting := testkit.V1Meeting(meetingID, projectSFID)
+
+	tests := []struct {
+		name      string
+		scopeDeny []string
+		parent    func(ctx context.Context, v1 *testkit.KV) error
+		parked    bool
+	}{
+		{
+			name:   "parent not in v1-objects yet",
+			parent: func(context.Context, *testkit.KV) error { return nil },
+			parked: true,
+		},
+		{
+			name: "parent waiting for its project",
+			parent: func(ctx context.Context, v1 *testkit.KV) error {
+				_, err := meeting.Put(ctx, v1)
+				return err
+			},
+			parked: true,
+		},
+		{
+			name: "parent soft deleted",
+			parent: func(ctx context.Context, v1 *testkit.KV) error {
+				_, err := meeting.With("_sdc_deleted_at", testkit.FixtureTime.Format(time.RFC3339)).Put(ctx, v1)
+				return err
+			},
+		},
+		{
+			name: "parent hard deleted",
+			parent: func(ctx context.Context, v1 *testkit.KV) error {
+				if _, err := meeting.Put(ctx, v1); err != nil {
+					return err
+				}
+				return v1.Delete(ctx, meeting.Key)
+			},
+		},
+		{
+			name: "parent originated in v2",
+			parent: func(ctx context.Context, v1 *testkit.KV) error {
+				_, err := meeting.With("lastmodifiedbyid", "v2-client@clients").Put(ctx, v1)
+				return err
+			},
+		},
+		{
+			name:      "parent out of project scope",
+			scopeDeny: []string{projectSFID},
+			parent: func(ctx context.Context, v1 *testkit.KV) error {
+				_, err := meeting.Put(ctx, v1)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			v1, mappings, _ := setupHandlerTest(t)
+			cfg.Auth0ClientID, cfg.ProjectScopeDeny = "v2-client", tt.scopeDeny
+			applyRuntimeSettings(envRuntimeSettings(cfg))
+			if err := tt.parent(ctx, v1); err != nil {
+				t.Fatal(err)
+			}
+
+			registrant := testkit.V1Registrant("reg-1", meetingID, projectSFID)
+			stop, retry := checkMappingDependencies(ctx, "itx-zoom-meetings-registrants-v2", registrant.Key, meetingChildDependencies, recordData(t, registrant))
+			if !stop || retry {
+				t.Fatalf("checkMappingDependencies() = %v, %v, want true, false", stop, retry)
+			}
+
+			_, err := mappings.Get(ctx, parkedRecordKeyPrefix+"v1_meetings."+meetingID+"."+registrant.Key)
+			if parked := err == nil; parked != tt.parked {
+				t.Errorf("parked: got %v, want %v", parked, tt.parked)
+			}
+		})
+	}
+}
+
+func TestSweepParkedRecordsExpiry(t *testing.T) {
+	ctx := context.Background()
+	v1, mappings, _ := setupHandlerTest(t)
+	clock, _ := testkit.Install(t)
+	cfg.ParkedRecordMaxAge = 24 * time.Hour
+
+	registrant := testkit.V1Registrant("reg-1", "91234567890", "a0941000002wBz9AAE")
+	if _, err := registrant.Put(ctx, v1); err != nil {
+		t.Fatal(err)
+	}
+	if stop, _ := checkMappingDependencies(ctx, "itx-zoom-meetings-registrants-v2", registrant.Key, meetingChildDependencies, recordData(t, registrant)); !stop {
+		t.Fatal("registrant without a meeting mapping was not parked")
+	}
+	parkedKey := parkedRecordKeyPrefix + "v1_meetings.91234567890." + registrant.Key
+
+	clock.Advance(23 * time.Hour)
+	sweepParkedRecords(ctx)
+	if _, err := mappings.Get(ctx, parkedKey); err != nil {
+		t.Fatalf("record parked for less than the maximum age: %v", err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	sweepParkedRecords(ctx)
+	if _, err := mappings.Get(ctx, parkedKey); err == nil {
+		t.Fatal("record parked for longer than the maximum age was not dropped")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Mappings bucket failover. For disaster recovery, v1-mappings is replicated
+// to a mirror bucket (e.g. a JetStream mirror in another cluster). When
+// MAPPINGS_MIRROR_BUCKET is set, mapping reads which fail on the primary
+// bucket (other than for a missing key) are retried on the mirror. Writes
+// always go to the primary bucket. The mirror is also periodically compared
+// with a sample of the primary keys, to catch replication falling behind
+// before it is needed.
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// mappingsConsistencyCheckInterval is how often the mirror bucket is
+	// compared with the primary one.
+	mappingsConsistencyCheckInterval = 15 * time.Minute
+
+	// mappingsConsistencySampleSize is the number of primary keys compared
+	// with the mirror on each check.
+	mappingsConsistencySampleSize = 500
+)
+
+var mappingsFallbackReads = newCounterVec(
+	"v1_sync_helper_mappings_fallback_reads_total",
+	"Number of mapping reads served by the mirror bucket after the primary bucket failed, by result (found, not_found, or error).",
+	"result",
+)
+
+var (
+	mappingsConsistencyMu      sync.Mutex
+	mappingsConsistencyResults = map[string]int{}
+)
+
+var mappingsMirrorInconsistentKeys = newGaugeFunc(
+	"v1_sync_helper_mappings_mirror_inconsistent_keys",
+	"Number of sampled mapping keys which were missing from or differed in the mirror bucket at the last consistency check, by kind (checked, missing, or mismatched).",
+	func() []gaugeSample {
+		mappingsConsistencyMu.Lock()
+		defer mappingsConsistencyMu.Unlock()
+		samples := make([]gaugeSample, 0, len(mappingsConsistencyResults))
+		for kind, count := range mappingsConsistencyResults {
+			samples = append(samples, gaugeSample{labelValues: []string{kind}, value: float64(count)})
+		}
+		return samples
+	},
+	"kind",
+)
+
+// failoverKV is a KV bucket whose reads fall back to a mirror bucket when the
+// primary one fails. All other operations go to the primary bucket.
+type failoverKV struct {
+	jetstream.KeyValue
+	mirror jetstream.KeyValue
+}
+
+// newFailoverKV returns the primary bucket with reads falling back to mirror.
+func newFailoverKV(primary, mirror jetstream.KeyValue) *failoverKV {
+	return &failoverKV{KeyValue: primary, mirror: mirror}
+}
+
+// Get returns the entry from the primary bucket, or from the mirror bucket
+// when the primary read fails for any reason other than a missing key.
+func (kv *failoverKV) Get(ctx context.Context, key string) (jetstream.KeyValueEntry, error) {
+	entry, err := kv.KeyValue.Get(ctx, key)
+	if err == nil || errors.Is(err, jetstream.ErrKeyNotFound) || ctx.Err() != nil {
+		return entry, err
+	}
+
+	mirrorEntry, mirrorErr := kv.mirror.Get(ctx, key)
+	switch {
+	case mirrorErr == nil:
+		mappingsFallbackReads.inc("found")
+	case errors.Is(mirrorErr, jetstream.ErrKeyNotFound):
+		mappingsFallbackReads.inc("not_found")
+	default:
+		mappingsFallbackReads.inc("error")
+		logger.With(errKey, mirrorErr, "primary_error", err, "key", key).WarnContext(ctx, "mapping read failed on both the primary and mirror buckets")
+		return nil, err
+	}
+	logger.With(errKey, err, "key", key).DebugContext(ctx, "mapping read served by the mirror bucket")
+	return mirrorEntry, mirrorErr
+}
+
+// watchMappingsConsistency compares the mirror bucket with the primary one
+// every mappingsConsistencyCheckInterval until the context is cancelled.
+func watchMappingsConsistency(ctx context.Context, kv *failoverKV) {
+	ticker := time.NewTicker(mappingsConsistencyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		checkMappingsConsistency(ctx, kv)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkMappingsConsistency compares the values of a random sample of the
+// primary bucket keys with the mirror bucket. Recently written keys may
+// differ while replication catches up, so isolated mismatches are expected.
+func checkMappingsConsistency(ctx context.Context, kv *failoverKV) {
+	lister, err := kv.KeyValue.ListKeys(ctx)
+	if err != nil {
+		logger.With(errKey, err).WarnContext(ctx, "failed to list mapping keys for mirror consistency check")
+		return
+	}
+
+	// Reservoir sample of the primary keys.
+	sample := make([]string, 0, mappingsConsistencySampleSize)
+	seen := 0
+	for key := range lister.Keys() {
+		seen++
+		if len(sample) < mappingsConsistencySampleSize {
+			sample = append(sample, key)
+		} else if i := rand.IntN(seen); i < mappingsConsistencySampleSize {
+			sample[i] = key
+		}
+	}
+	slices.Sort(sample)
+
+	checked, missing, mismatched := 0, 0, 0
+	for _, key := range sample {
+		if ctx.Err() != nil {
+			return
+		}
+		primaryEntry, err := kv.KeyValue.Get(ctx, key)
+		if err != nil {
+			// Deleted (e.g. a released lock) or unavailable since listed.
+			continue
+		}
+		checked++
+		mirrorEntry, err := kv.mirror.Get(ctx, key)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound):
+			missing++
+		case err != nil:
+			logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to read mapping from mirror bucket for consistency check")
+			return
+		case string(mirrorEntry.Value()) != string(primaryEntry.Value()):
+			mismatched++
+		}
+	}
+
+	mappingsConsistencyMu.Lock()
+	mappingsConsistencyResults = map[string]int{"checked": checked, "missing": missing, "mismatched": mismatched}
+	mappingsConsistencyMu.Unlock()
+
+	log := logger.With("bucket", cfg.MappingsMirrorBucket, "checked", checked, "missing", missing, "mismatched", mismatched)
+	if missing > 0 || mismatched > 0 {
+		log.WarnContext(ctx, "mappings mirror bucket is inconsistent with the primary bucket")
+		return
+	}
+	log.DebugContext(ctx, "mappings mirror bucket is consistent with the primary bucket")
+}
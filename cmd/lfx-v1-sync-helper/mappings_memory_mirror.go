@@ -0,0 +1,239 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Mappings memory mirror. Some mapping lookups (e.g. project.sfid, read by
+// nearly every record) are on the hot path of every message. With
+// MAPPINGS_MEMORY_MIRROR_PREFIXES set, the mappings under these key prefixes
+// are mirrored in memory from a watcher of the v1-mappings bucket started at
+// startup, and their reads are served from memory instead of a KV get. Until
+// the watcher has delivered the current values (and again after it restarts),
+// reads go to the bucket. Each mirrored entry keeps its revision, so updates
+// delivered by the watcher never overwrite a newer value written by this
+// replica.
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var mappingsMemoryMirrorReads = newCounterVec(
+	"v1_sync_helper_mappings_memory_mirror_reads_total",
+	"Number of reads of mirrored mapping keys, by result (hit, miss, or bucket for reads sent to the bucket while the mirror is not warm).",
+	"result",
+)
+
+// memoryMirrorKV is a KV bucket whose reads of the keys under the mirrored
+// prefixes are served from memory once the mirror is warm. Writes go to the
+// bucket and, for the mirrored keys, update the mirror.
+type memoryMirrorKV struct {
+	jetstream.KeyValue
+	prefixes []string
+
+	mu      sync.RWMutex
+	warm    bool
+	entries map[string]jetstream.KeyValueEntry
+	// pending are the mirrored keys deleted by this replica whose delete has
+	// not been delivered by the watcher yet, read from the bucket meanwhile.
+	pending map[string]bool
+}
+
+// newMemoryMirrorKV returns the bucket with the reads of the keys under the
+// given prefixes served from memory, once watch has warmed the mirror.
+func newMemoryMirrorKV(kv jetstream.KeyValue, prefixes []string) *memoryMirrorKV {
+	return &memoryMirrorKV{
+		KeyValue: kv,
+		prefixes: prefixes,
+		entries:  map[string]jetstream.KeyValueEntry{},
+		pending:  map[string]bool{},
+	}
+}
+
+// memoryMirrorEntry is a mapping written by this replica.
+type memoryMirrorEntry struct {
+	bucket   string
+	key      string
+	value    []byte
+	revision uint64
+	created  time.Time
+}
+
+func (e *memoryMirrorEntry) Bucket() string                  { return e.bucket }
+func (e *memoryMirrorEntry) Key() string                     { return e.key }
+func (e *memoryMirrorEntry) Value() []byte                   { return e.value }
+func (e *memoryMirrorEntry) Revision() uint64                { return e.revision }
+func (e *memoryMirrorEntry) Created() time.Time              { return e.created }
+func (e *memoryMirrorEntry) Delta() uint64                   { return 0 }
+func (e *memoryMirrorEntry) Operation() jetstream.KeyValueOp { return jetstream.KeyValuePut }
+
+// mirrored reports whether a key is under a mirrored prefix.
+func (kv *memoryMirrorKV) mirrored(key string) bool {
+	return slices.ContainsFunc(kv.prefixes, func(prefix string) bool {
+		return strings.HasPrefix(key, prefix+".")
+	})
+}
+
+// watch mirrors the keys under the mirrored prefixes until the context is
+// cancelled or the watcher stops. The mirror is rebuilt from the current
+// values each time it runs.
+func (kv *memoryMirrorKV) watch(ctx context.Context) {
+	filters := make([]string, 0, len(kv.prefixes))
+	for _, prefix := range kv.prefixes {
+		filters = append(filters, prefix+".>")
+	}
+	watcher, err := kv.KeyValue.WatchFiltered(ctx, filters)
+	if err != nil {
+		logger.With(errKey, err, "prefixes", kv.prefixes).ErrorContext(ctx, "failed to watch mirrored mapping keys")
+		return
+	}
+	defer func() { _ = watcher.Stop() }()
+
+	kv.mu.Lock()
+	kv.warm = false
+	clear(kv.entries)
+	kv.mu.Unlock()
+	// Reads go to the bucket again once the watcher stops.
+	defer func() {
+		kv.mu.Lock()
+		kv.warm = false
+		kv.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-watcher.Updates():
+			if !ok {
+				logger.With("prefixes", kv.prefixes).WarnContext(ctx, "mapping memory mirror watcher stopped")
+				return
+			}
+			if entry == nil {
+				// The current values have all been delivered.
+				kv.mu.Lock()
+				kv.warm = true
+				keys := len(kv.entries)
+				kv.mu.Unlock()
+				logger.With("prefixes", kv.prefixes, "keys", keys).InfoContext(ctx, "mapping memory mirror is warm")
+				continue
+			}
+			kv.apply(entry)
+		}
+	}
+}
+
+// apply records an entry in the mirror, unless a newer revision of its key
+// is already mirrored.
+func (kv *memoryMirrorKV) apply(entry jetstream.KeyValueEntry) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if current, ok := kv.entries[entry.Key()]; ok && current.Revision() >= entry.Revision() {
+		return
+	}
+	kv.entries[entry.Key()] = entry
+	if entry.Operation() != jetstream.KeyValuePut {
+		delete(kv.pending, entry.Key())
+	}
+}
+
+// Get returns the entry of a mirrored key from memory when the mirror is
+// warm, and from the bucket otherwise.
+func (kv *memoryMirrorKV) Get(ctx context.Context, key string) (jetstream.KeyValueEntry, error) {
+	if !kv.mirrored(key) {
+		return kv.KeyValue.Get(ctx, key)
+	}
+	kv.mu.RLock()
+	warm := kv.warm && !kv.pending[key]
+	entry, ok := kv.entries[key]
+	kv.mu.RUnlock()
+	switch {
+	case !warm:
+		mappingsMemoryMirrorReads.inc("bucket")
+		return kv.KeyValue.Get(ctx, key)
+	case !ok || entry.Operation() != jetstream.KeyValuePut:
+		mappingsMemoryMirrorReads.inc("miss")
+		return nil, jetstream.ErrKeyNotFound
+	default:
+		mappingsMemoryMirrorReads.inc("hit")
+		return entry, nil
+	}
+}
+
+// written records a value written to a mirrored key by this replica, so it is
+// read back without waiting for the watcher.
+func (kv *memoryMirrorKV) written(key string, value []byte, revision uint64, err error) {
+	if err != nil || !kv.mirrored(key) {
+		return
+	}
+	kv.apply(&memoryMirrorEntry{
+		bucket:   kv.KeyValue.Bucket(),
+		key:      key,
+		value:    bytes.Clone(value),
+		revision: revision,
+		created:  bootstrap.Now(),
+	})
+	kv.mu.Lock()
+	delete(kv.pending, key)
+	kv.mu.Unlock()
+}
+
+// deleted records the delete of a mirrored key by this replica: the key is
+// read from the bucket until the watcher delivers the delete, as its revision
+// is not known.
+func (kv *memoryMirrorKV) deleted(key string, err error) {
+	if err != nil || !kv.mirrored(key) {
+		return
+	}
+	kv.mu.Lock()
+	kv.pending[key] = true
+	kv.mu.Unlock()
+}
+
+// Put writes to the bucket, updating the mirror.
+func (kv *memoryMirrorKV) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	revision, err := kv.KeyValue.Put(ctx, key, value)
+	kv.written(key, value, revision, err)
+	return revision, err
+}
+
+// PutString writes to the bucket, updating the mirror.
+func (kv *memoryMirrorKV) PutString(ctx context.Context, key string, value string) (uint64, error) {
+	return kv.Put(ctx, key, []byte(value))
+}
+
+// Create writes to the bucket, updating the mirror.
+func (kv *memoryMirrorKV) Create(ctx context.Context, key string, value []byte, opts ...jetstream.KVCreateOpt) (uint64, error) {
+	revision, err := kv.KeyValue.Create(ctx, key, value, opts...)
+	kv.written(key, value, revision, err)
+	return revision, err
+}
+
+// Update writes to the bucket, updating the mirror.
+func (kv *memoryMirrorKV) Update(ctx context.Context, key string, value []byte, last uint64) (uint64, error) {
+	revision, err := kv.KeyValue.Update(ctx, key, value, last)
+	kv.written(key, value, revision, err)
+	return revision, err
+}
+
+// Delete deletes from the bucket, updating the mirror.
+func (kv *memoryMirrorKV) Delete(ctx context.Context, key string, opts ...jetstream.KVDeleteOpt) error {
+	err := kv.KeyValue.Delete(ctx, key, opts...)
+	kv.deleted(key, err)
+	return err
+}
+
+// Purge purges from the bucket, updating the mirror.
+func (kv *memoryMirrorKV) Purge(ctx context.Context, key string, opts ...jetstream.KVDeleteOpt) error {
+	err := kv.KeyValue.Purge(ctx, key, opts...)
+	kv.deleted(key, err)
+	return err
+}
@@ -0,0 +1,309 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Mass purge safe mode. A purge (or reset) of the whole v1-objects bucket
+// looks like a flood of hard deletes, which would cascade every deletion
+// downstream. When a replica sees more than MASS_PURGE_THRESHOLD hard deletes
+// within a minute, it records the mass purge in the mappings bucket, which
+// switches every replica to safe mode: hard deletes are no longer propagated,
+// but held in the mappings bucket, until an operator either confirms them
+// (the mass-purge subcommand with -confirm cascades them downstream) or
+// discards them (-discard, e.g. once the bucket is reloaded).
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// massPurgeStateKey is the mappings key recording a detected mass purge.
+	massPurgeStateKey = "v1_sync_helper_mass_purge"
+
+	// heldDeleteKeyPrefix is the mappings key prefix of the hard deletes held
+	// in safe mode, followed by the v1-objects key.
+	heldDeleteKeyPrefix = "v1_held_deletes."
+
+	// defaultMassPurgeThreshold is the default number of hard deletes within
+	// massPurgeWindow above which a mass purge is detected.
+	defaultMassPurgeThreshold = 5000
+
+	// massPurgeWindow is the window over which hard deletes are counted.
+	massPurgeWindow = time.Minute
+
+	// massPurgeStateRefreshInterval is how often replicas read the mass purge
+	// state, to enter or leave safe mode on another replica's detection or an
+	// operator's decision.
+	massPurgeStateRefreshInterval = 10 * time.Second
+)
+
+var heldDeletes = newCounterVec(
+	"v1_sync_helper_held_deletes_total",
+	"Number of hard deletes held in mass purge safe mode, by object type and result (held, released, superseded, retried, or discarded).",
+	"object_type", "result",
+)
+
+// massPurgeActive is whether this replica is in safe mode.
+var massPurgeActive atomic.Bool
+
+var massPurgeSafeMode = newGaugeFunc(
+	"v1_sync_helper_mass_purge_safe_mode",
+	"Whether hard delete propagation is paused after a mass purge of v1-objects was detected (1) or not (0).",
+	func() []gaugeSample {
+		value := 0.0
+		if massPurgeActive.Load() {
+			value = 1
+		}
+		return []gaugeSample{{value: value}}
+	},
+)
+
+// massPurgeState is the mass purge recorded in the mappings bucket.
+type massPurgeState struct {
+	DetectedAt time.Time `json:"detected_at"`
+	Deletes    int       `json:"deletes"`
+}
+
+// heldDelete is a hard delete held in safe mode.
+type heldDelete struct {
+	Key       string    `json:"key"`
+	Operation string    `json:"operation"`
+	HeldAt    time.Time `json:"held_at"`
+}
+
+var (
+	// massPurgeWindowMu guards massPurgeWindowStart and massPurgeWindowDeletes.
+	massPurgeWindowMu sync.Mutex
+	// massPurgeWindowStart is the start of the current counting window.
+	massPurgeWindowStart time.Time
+	// massPurgeWindowDeletes is the number of hard deletes in the current
+	// counting window.
+	massPurgeWindowDeletes int
+)
+
+// countHardDelete counts a hard delete in the current window, and returns the
+// number of hard deletes in it.
+func countHardDelete(now time.Time) int {
+	massPurgeWindowMu.Lock()
+	defer massPurgeWindowMu.Unlock()
+	if now.Sub(massPurgeWindowStart) >= massPurgeWindow {
+		massPurgeWindowStart = now
+		massPurgeWindowDeletes = 0
+	}
+	massPurgeWindowDeletes++
+	return massPurgeWindowDeletes
+}
+
+// holdDuringMassPurge counts a hard delete, entering safe mode when the
+// threshold is exceeded, and holds the delete in safe mode. It reports whether
+// the delete was held (or failed to be), and whether to retry it.
+func holdDuringMassPurge(ctx context.Context, entry jetstream.KeyValueEntry) (held, retry bool) {
+	if cfg.MassPurgeThreshold <= 0 || contextDryRun(ctx) != nil {
+		return false, false
+	}
+
+	if deletes := countHardDelete(bootstrap.Now()); deletes > cfg.MassPurgeThreshold && !massPurgeActive.Load() {
+		enterMassPurgeSafeMode(ctx, deletes)
+	}
+	if !massPurgeActive.Load() {
+		return false, false
+	}
+
+	key := entry.Key()
+	value, err := json.Marshal(heldDelete{Key: key, Operation: entry.Operation().String(), HeldAt: bootstrap.Now().UTC()})
+	if err != nil {
+		logger.With(errKey, err, "key", key).ErrorContext(ctx, "failed to marshal held delete")
+		return true, false
+	}
+	if _, err := mappingsKV.Put(ctx, heldDeleteKeyPrefix+key, value); err != nil {
+		logger.With(errKey, err, "key", key).ErrorContext(ctx, "failed to hold delete in mass purge safe mode")
+		return true, true
+	}
+	heldDeletes.inc(kvObjectType(key), "held")
+	logger.With("key", key).DebugContext(ctx, "held delete in mass purge safe mode")
+	return true, false
+}
+
+// enterMassPurgeSafeMode records a detected mass purge, unless another replica
+// already did, and enters safe mode.
+func enterMassPurgeSafeMode(ctx context.Context, deletes int) {
+	massPurgeActive.Store(true)
+	value, err := json.Marshal(massPurgeState{DetectedAt: bootstrap.Now().UTC(), Deletes: deletes})
+	if err == nil {
+		_, err = mappingsKV.Create(ctx, massPurgeStateKey, value)
+	}
+	if err != nil && !errors.Is(err, jetstream.ErrKeyExists) {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to record mass purge")
+	}
+	logger.With("deletes", deletes, "window", massPurgeWindow.String(), "threshold", cfg.MassPurgeThreshold).
+		ErrorContext(ctx, "mass purge of v1-objects detected: holding hard deletes until confirmed or discarded with the mass-purge subcommand")
+}
+
+// refreshMassPurgeState enters or leaves safe mode according to the mass
+// purge recorded in the mappings bucket.
+func refreshMassPurgeState(ctx context.Context) {
+	_, err := mappingsKV.Get(ctx, massPurgeStateKey)
+	switch {
+	case err == nil:
+		if !massPurgeActive.Swap(true) {
+			logger.WarnContext(ctx, "entered mass purge safe mode recorded by another replica")
+		}
+	case errors.Is(err, jetstream.ErrKeyNotFound):
+		if massPurgeActive.Swap(false) {
+			logger.InfoContext(ctx, "left mass purge safe mode")
+		}
+	default:
+		logger.With(errKey, err).WarnContext(ctx, "failed to get mass purge state")
+	}
+}
+
+// watchMassPurgeState refreshes the mass purge state every
+// massPurgeStateRefreshInterval, until the context is cancelled.
+func watchMassPurgeState(ctx context.Context) {
+	if cfg.MassPurgeThreshold <= 0 {
+		return
+	}
+	refreshMassPurgeState(ctx)
+
+	ticker := time.NewTicker(massPurgeStateRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		refreshMassPurgeState(ctx)
+	}
+}
+
+// runMassPurge reports the mass purge state and the number of held deletes,
+// or resolves a mass purge: -confirm leaves safe mode and cascades the held
+// deletes downstream, and -discard leaves safe mode and drops them.
+func runMassPurge(name string, args []string) {
+	var confirm, discard *bool
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		confirm = flags.Bool("confirm", false, "leave safe mode and propagate the held deletes downstream")
+		discard = flags.Bool("discard", false, "leave safe mode and drop the held deletes")
+	})
+	ctx := p.ctx
+	if *confirm && *discard {
+		logger.Error("at most one of -confirm or -discard is allowed")
+		os.Exit(2)
+	}
+
+	p.openBuckets()
+
+	var state *massPurgeState
+	if entry, err := mappingsKV.Get(ctx, massPurgeStateKey); err == nil {
+		state = &massPurgeState{}
+		if err := json.Unmarshal(entry.Value(), state); err != nil {
+			logger.With(errKey, err).ErrorContext(ctx, "failed to unmarshal mass purge state")
+		}
+	} else if !errors.Is(err, jetstream.ErrKeyNotFound) {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to get mass purge state")
+		os.Exit(1)
+	}
+
+	lister, err := mappingsKV.ListKeysFiltered(ctx, heldDeleteKeyPrefix+">")
+	if err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to list held deletes")
+		os.Exit(1)
+	}
+	var heldKeys []string
+	for heldKey := range lister.Keys() {
+		heldKeys = append(heldKeys, heldKey)
+	}
+
+	if !*confirm && !*discard {
+		log := logger.With("safe_mode", state != nil, "held_deletes", len(heldKeys))
+		if state != nil {
+			log = log.With("detected_at", state.DetectedAt, "deletes", state.Deletes)
+		}
+		log.InfoContext(ctx, "mass purge status")
+		p.shutdown()
+		return
+	}
+
+	// Leave safe mode first, so deletes arriving meanwhile are propagated
+	// rather than held after the held ones were processed.
+	if err := mappingsKV.Delete(ctx, massPurgeStateKey); err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to clear mass purge state")
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, heldKey := range heldKeys {
+		if ctx.Err() != nil {
+			break
+		}
+		if !resolveHeldDelete(ctx, heldKey, *confirm) {
+			failed++
+		}
+	}
+	logger.With("confirmed", *confirm, "held_deletes", len(heldKeys), "failed", failed).InfoContext(ctx, "mass purge resolved")
+	p.shutdown()
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// resolveHeldDelete claims a held delete, by deleting it at its current
+// revision, then propagates it downstream if confirmed. Deletes of keys which
+// were written again since are superseded, and not propagated. It reports
+// whether the held delete was resolved; failed ones are held again.
+func resolveHeldDelete(ctx context.Context, heldKey string, confirm bool) bool {
+	log := logger.With("held_key", heldKey)
+	heldEntry, err := mappingsKV.Get(ctx, heldKey)
+	if err != nil {
+		return true
+	}
+	var held heldDelete
+	if err := json.Unmarshal(heldEntry.Value(), &held); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to unmarshal held delete")
+		return false
+	}
+	if err := mappingsKV.Delete(ctx, heldKey, jetstream.LastRevision(heldEntry.Revision())); err != nil {
+		// Claimed by another run, or held again meanwhile.
+		return true
+	}
+
+	objectType := kvObjectType(held.Key)
+	if !confirm {
+		heldDeletes.inc(objectType, "discarded")
+		return true
+	}
+
+	log = log.With("key", held.Key)
+	_, err = v1KV.Get(ctx, held.Key)
+	switch {
+	case err == nil:
+		heldDeletes.inc(objectType, "superseded")
+		log.InfoContext(ctx, "skipped held delete of a key written again since")
+		return true
+	case !errors.Is(err, jetstream.ErrKeyNotFound) && !errors.Is(err, jetstream.ErrKeyDeleted):
+		log.With(errKey, err).ErrorContext(ctx, "failed to get v1-objects entry of held delete")
+	default:
+		if !handleResourceDelete(bootstrap.MessageContext(ctx, nil), held.Key, "", nil) {
+			heldDeletes.inc(objectType, "released")
+			return true
+		}
+	}
+
+	heldDeletes.inc(objectType, "retried")
+	if _, err := mappingsKV.Put(ctx, heldKey, heldEntry.Value()); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to hold delete again for retry")
+	}
+	return false
+}
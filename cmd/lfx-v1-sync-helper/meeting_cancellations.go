@@ -0,0 +1,119 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting occurrence cancellations. Cancelling an occurrence in v1 only adds
+// its ID to the cancelled_occurrences of the meeting, which is carried through
+// to the indexed meeting, but calendar consumers have no event to update the
+// invites of the cancelled slot. The cancelled occurrences last synced for
+// each meeting are kept in the mappings bucket, and each newly cancelled
+// occurrence is sent as a cancellation event, with the meeting re-indexed.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// meetingCancelledOccurrencesKeyFmt is the mappings KV key format of the
+// cancelled occurrence IDs last synced for a meeting, by meeting ID.
+const meetingCancelledOccurrencesKeyFmt = "v1_meeting_cancelled_occurrences.%s"
+
+// MeetingOccurrenceCancelledMessage is the schema of the event sent for each
+// occurrence newly cancelled in v1, so calendar consumers can cancel its
+// invites. StartTime is derived from the occurrence ID (its original start as
+// a unix timestamp) and left empty when the ID is not one.
+type MeetingOccurrenceCancelledMessage struct {
+	MeetingUID   string    `json:"meeting_uid"`
+	ProjectUID   string    `json:"project_uid"`
+	OccurrenceID string    `json:"occurrence_id"`
+	StartTime    string    `json:"start_time,omitempty"`
+	ICSUID       string    `json:"ics_uid"`
+	CancelledAt  time.Time `json:"cancelled_at"`
+}
+
+// nextMeetingCancelledOccurrences returns the sorted cancelled occurrence IDs
+// of a meeting being synced, those added since it was last synced, and
+// whether they need to be stored. Meetings synced for the first time (or
+// before cancellations were tracked) have no newly cancelled occurrences, as
+// their indexed document already lists them.
+func nextMeetingCancelledOccurrences(ctx context.Context, meeting *meetingInput) (cancelled, added []string, store bool, err error) {
+	cancelled = slices.Clone(meeting.CancelledOccurrences)
+	slices.Sort(cancelled)
+	cancelled = slices.Compact(cancelled)
+
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(meetingCancelledOccurrencesKeyFmt, meeting.ID))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return cancelled, nil, true, nil
+	}
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to get meeting cancelled occurrences: %w", err)
+	}
+	if isTombstonedMapping(entry.Value()) {
+		return cancelled, nil, true, nil
+	}
+	var previous []string
+	if err := json.Unmarshal(entry.Value(), &previous); err != nil {
+		return nil, nil, false, fmt.Errorf("failed to unmarshal meeting cancelled occurrences: %w", err)
+	}
+
+	for _, occurrenceID := range cancelled {
+		if !slices.Contains(previous, occurrenceID) {
+			added = append(added, occurrenceID)
+		}
+	}
+	// Occurrences restored in v1 are stored too, so cancelling them again
+	// sends a new event.
+	return cancelled, added, !slices.Equal(cancelled, previous), nil
+}
+
+// putMeetingCancelledOccurrences stores the cancelled occurrence IDs of a
+// synced meeting.
+func putMeetingCancelledOccurrences(ctx context.Context, meetingID string, cancelled []string) error {
+	if cancelled == nil {
+		cancelled = []string{}
+	}
+	cancelledBytes, err := json.Marshal(cancelled)
+	if err != nil {
+		return fmt.Errorf("failed to marshal meeting cancelled occurrences: %w", err)
+	}
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(meetingCancelledOccurrencesKeyFmt, meetingID), cancelledBytes); err != nil {
+		return fmt.Errorf("failed to store meeting cancelled occurrences: %w", err)
+	}
+	return nil
+}
+
+// sendMeetingOccurrenceCancelledEvents sends a cancellation event for each
+// newly cancelled occurrence of a meeting.
+func sendMeetingOccurrenceCancelledEvents(ctx context.Context, meeting *meetingInput, occurrenceIDs []string) error {
+	cancelledAt := bootstrap.Now().UTC()
+	for _, occurrenceID := range occurrenceIDs {
+		event := MeetingOccurrenceCancelledMessage{
+			MeetingUID:   meeting.ID,
+			ProjectUID:   meeting.ProjectUID,
+			OccurrenceID: occurrenceID,
+			ICSUID:       meetingICSUID(meeting),
+			CancelledAt:  cancelledAt,
+		}
+		if unixStartTime, err := strconv.ParseInt(occurrenceID, 10, 64); err == nil {
+			event.StartTime = time.Unix(unixStartTime, 0).UTC().Format(time.RFC3339)
+		}
+		eventBytes, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal meeting occurrence cancelled event: %w", err)
+		}
+		if err := publishMessage(ctx, V1MeetingOccurrenceCancelledSubject, eventBytes); err != nil {
+			return fmt.Errorf("failed to publish meeting occurrence cancelled event to subject %s: %w", V1MeetingOccurrenceCancelledSubject, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting type classification. v1 sets meeting_type inconsistently, so a
+// canonical meeting type (board, technical, maintainer, webinar, or other) is
+// derived from the v1 meeting type, the categories of the meeting's
+// committees, and the meeting title, by an ordered ruleset: the first rule
+// with a matching condition wins. The raw and canonical types are both sent in
+// the meeting and past meeting indexer payloads and tags.
+//
+// The default ruleset can be replaced with MEETING_TYPE_RULES.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Canonical meeting types.
+const (
+	meetingTypeBoard      = "board"
+	meetingTypeTechnical  = "technical"
+	meetingTypeMaintainer = "maintainer"
+	meetingTypeWebinar    = "webinar"
+	meetingTypeOther      = "other"
+)
+
+// meetingTypeRule assigns a canonical meeting type to meetings matching any of
+// its conditions. Matches are case-insensitive.
+type meetingTypeRule struct {
+	// MeetingType is the canonical meeting type assigned by the rule.
+	MeetingType string `json:"meeting_type"`
+	// V1MeetingTypes match the v1 meeting_type of the meeting.
+	V1MeetingTypes []string `json:"v1_meeting_types,omitempty"`
+	// CommitteeCategories match the category of any committee of the
+	// meeting, as mapped from the v1 committee type__c.
+	CommitteeCategories []string `json:"committee_categories,omitempty"`
+	// TitleContains match substrings of the meeting title.
+	TitleContains []string `json:"title_contains,omitempty"`
+}
+
+// defaultMeetingTypeRules is the ruleset used when MEETING_TYPE_RULES is
+// unset. Webinars are matched first, as a format rather than an audience.
+var defaultMeetingTypeRules = []meetingTypeRule{
+	{
+		MeetingType:    meetingTypeWebinar,
+		V1MeetingTypes: []string{"Webinar"},
+		TitleContains:  []string{"webinar"},
+	},
+	{
+		MeetingType:         meetingTypeBoard,
+		V1MeetingTypes:      []string{"Board"},
+		CommitteeCategories: []string{"Board"},
+	},
+	{
+		MeetingType:         meetingTypeMaintainer,
+		V1MeetingTypes:      []string{"Maintainers", "Maintainer"},
+		CommitteeCategories: []string{"Maintainers", "Committers"},
+	},
+	{
+		MeetingType:    meetingTypeTechnical,
+		V1MeetingTypes: []string{"Technical"},
+		CommitteeCategories: []string{
+			"Technical Advisory Committee",
+			"Technical Oversight Committee",
+			"Technical Steering Committee",
+			"Technical Mailing List",
+			"Special Interest Group",
+			"Working Group",
+			"Expert Group",
+			"Product Security",
+		},
+	},
+}
+
+// parseMeetingTypeRules parses MEETING_TYPE_RULES, a JSON array of rules, e.g.
+// [{"meeting_type": "board", "v1_meeting_types": ["Board"]}]. The default
+// ruleset is returned when the value is empty.
+func parseMeetingTypeRules(value string) ([]meetingTypeRule, error) {
+	if value == "" {
+		return defaultMeetingTypeRules, nil
+	}
+	var rules []meetingTypeRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("MEETING_TYPE_RULES must be a JSON array of rules: %w", err)
+	}
+	if err := validateMeetingTypeRules("MEETING_TYPE_RULES", rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// validateMeetingTypeRules checks that each rule has a meeting type and at
+// least one condition. The name of the setting is used in errors.
+func validateMeetingTypeRules(name string, rules []meetingTypeRule) error {
+	for i, rule := range rules {
+		if rule.MeetingType == "" {
+			return fmt.Errorf("%s rule %d has no meeting_type", name, i)
+		}
+		if len(rule.V1MeetingTypes) == 0 && len(rule.CommitteeCategories) == 0 && len(rule.TitleContains) == 0 {
+			return fmt.Errorf("%s rule %d (%s) has no conditions", name, i, rule.MeetingType)
+		}
+	}
+	return nil
+}
+
+// containsFold reports whether values contains value, ignoring case.
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
+
+// matches reports whether a meeting matches any condition of the rule.
+func (r meetingTypeRule) matches(v1MeetingType, title string, committeeCategories []string) bool {
+	if v1MeetingType != "" && containsFold(r.V1MeetingTypes, v1MeetingType) {
+		return true
+	}
+	for _, category := range committeeCategories {
+		if containsFold(r.CommitteeCategories, category) {
+			return true
+		}
+	}
+	lowerTitle := strings.ToLower(title)
+	return slices.ContainsFunc(r.TitleContains, func(substring string) bool {
+		return substring != "" && strings.Contains(lowerTitle, strings.ToLower(substring))
+	})
+}
+
+// committeeCategory returns the category of a v1 committee, as mapped for the
+// committee service, or an empty string if the committee has none or cannot
+// be read.
+func committeeCategory(ctx context.Context, committeeID string) string {
+	committeeData, exists, err := getV1ObjectData(ctx, fmt.Sprintf("platform-collaboration__c.%s", committeeID))
+	if err != nil {
+		logger.With(errKey, err, "committee_id", committeeID).WarnContext(ctx, "failed to get committee for meeting type classification")
+		return ""
+	}
+	if !exists {
+		return ""
+	}
+	typeVal, _ := committeeData["type__c"].(string)
+	name, _ := committeeData["mailing_list__c"].(string)
+	if category := mapTypeToCategory(ctx, typeVal, name); category != nil {
+		return *category
+	}
+	return ""
+}
+
+// classifyMeetingType returns the canonical meeting type of a meeting from its
+// v1 meeting type, title, and committee IDs.
+func classifyMeetingType(ctx context.Context, v1MeetingType, title string, committeeIDs []string) string {
+	// Committee categories are only read once a rule needs them.
+	var categories []string
+	categoriesRead := false
+	for _, rule := range contextSettings(ctx).MeetingTypeRules {
+		if len(rule.CommitteeCategories) > 0 && !categoriesRead {
+			for _, committeeID := range committeeIDs {
+				if category := committeeCategory(ctx, committeeID); category != "" {
+					categories = append(categories, category)
+				}
+			}
+			categoriesRead = true
+		}
+		if rule.matches(v1MeetingType, title, categories) {
+			return strings.ToLower(rule.MeetingType)
+		}
+	}
+	return meetingTypeOther
+}
+
+// meetingCommitteeIDs returns the IDs of a meeting's committees.
+func meetingCommitteeIDs(committees []Committee) []string {
+	ids := make([]string, 0, len(committees))
+	for _, committee := range committees {
+		ids = append(ids, committee.UID)
+	}
+	return ids
+}
@@ -0,0 +1,64 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// The weekly Zoom host key rotation rewrites every meeting record, and each
+// rewrite would re-index the meeting for fields v2 treats as sensitive. The
+// fingerprint of the indexed fields of the last synced version of each meeting
+// is kept in the mappings bucket, so meetings whose only changes are to
+// ignorable fields are not re-indexed, while their access is still updated.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// meetingFingerprintKeyFmt is the mappings KV key format of the fingerprint of
+// a meeting, by meeting ID.
+const meetingFingerprintKeyFmt = "v1_meeting_fingerprints.%s"
+
+// newMeetingFingerprint returns the SHA-256 hash of the fields of a converted
+// meeting whose changes require a re-index.
+func newMeetingFingerprint(meeting *meetingInput) (string, error) {
+	// The hash excludes the rotated host key, the join page password, and
+	// the Zoom passcode, and the modification audit fields, which v1 bumps
+	// when rotating them. The password query parameter of the join URL is
+	// removed as it is from indexer payloads, so a password change only
+	// re-indexes the meeting where the password is allowlisted and indexed.
+	indexed := *meeting
+	indexed.HostKey = ""
+	indexed.Password = ""
+	indexed.ZoomConfig.Passcode = ""
+	indexed.UpdatedAt = ""
+	indexed.UpdatedBy = UpdatedBy{}
+	indexed.UpdatedByList = nil
+	var redacted []string
+	redactURLParam(&indexed.JoinURL, "password", redactedPassword, shouldRedact, &redacted)
+	indexedBytes, err := json.Marshal(indexed)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal meeting indexed fields: %w", err)
+	}
+	return sha256Hex(indexedBytes), nil
+}
+
+// getMeetingFingerprint returns the stored fingerprint of a meeting. It
+// returns false when there is none, or it is a tombstone, in which case the
+// meeting is synced as changed.
+func getMeetingFingerprint(ctx context.Context, meetingID string) (string, bool) {
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(meetingFingerprintKeyFmt, meetingID))
+	if err != nil || isTombstonedMapping(entry.Value()) {
+		return "", false
+	}
+	return string(entry.Value()), true
+}
+
+// putMeetingFingerprint stores the fingerprint of a synced meeting.
+func putMeetingFingerprint(ctx context.Context, meetingID, fingerprint string) error {
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(meetingFingerprintKeyFmt, meetingID), []byte(fingerprint)); err != nil {
+		return fmt.Errorf("failed to store meeting fingerprint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import "testing"
+
+func TestNewMeetingFingerprint(t *testing.T) {
+	previousCfg := cfg
+	t.Cleanup(func() { cfg = previousCfg })
+
+	base := meetingInput{
+		ID:       "91234567890",
+		Title:    "Technical Steering Committee",
+		Password: "secret1",
+		JoinURL:  "https://zoom.us/j/91234567890?password=secret1&uname=guest",
+	}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		change    func(m *meetingInput)
+		same      bool
+	}{
+		{
+			name: "password rotation",
+			change: func(m *meetingInput) {
+				m.Password = "secret2"
+				m.JoinURL = "https://zoom.us/j/91234567890?password=secret2&uname=guest"
+			},
+			same: true,
+		},
+		{
+			name:   "host key rotation",
+			change: func(m *meetingInput) { m.HostKey = "123456" },
+			same:   true,
+		},
+		{
+			name:   "join URL change",
+			change: func(m *meetingInput) { m.JoinURL = "https://zoom.us/j/91234567891?password=secret1&uname=guest" },
+			same:   false,
+		},
+		{
+			name:   "title change",
+			change: func(m *meetingInput) { m.Title = "Board" },
+			same:   false,
+		},
+		{
+			name:      "password rotation with the password allowlisted",
+			allowlist: []string{redactedPassword},
+			change: func(m *meetingInput) {
+				m.Password = "secret2"
+				m.JoinURL = "https://zoom.us/j/91234567890?password=secret2&uname=guest"
+			},
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &Config{IndexerRedactionAllowlist: tt.allowlist}
+			before, changed := base, base
+			tt.change(&changed)
+
+			beforeFingerprint, err := newMeetingFingerprint(&before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changedFingerprint, err := newMeetingFingerprint(&changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := beforeFingerprint == changedFingerprint; same != tt.same {
+				t.Errorf("same fingerprint: got %v, want %v", same, tt.same)
+			}
+			if before.JoinURL != base.JoinURL {
+				t.Errorf("fingerprint modified the meeting join URL: %s", before.JoinURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting mapping batching. Each itx-zoom-meetings-mappings-v2 record adds
+// one committee to the committee-mappings index of its meeting, under the
+// meeting mapping lock, and re-indexes the meeting: a bulk load of mappings
+// thus runs thousands of lock, read-modify-write, and re-index cycles for the
+// same meetings. With MEETING_MAPPING_BATCH_WINDOW set, the mapping updates
+// of a meeting are held for the window after the first one, then flushed
+// together as one index write and one re-index.
+//
+// Batched KV messages are only acknowledged once their batch is flushed, so
+// the content hashes of their records are only stored then: a flush which
+// fails is retried a few times, with the AckWait of the messages extended,
+// then its messages are NAKed for redelivery, as are those of the batches
+// failing to flush at shutdown. Messages which cannot defer their settlement
+// (replays, WAL events) are applied directly.
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// meetingMappingFlushAttempts is how many times a batch is flushed
+	// before its mappings are dropped.
+	meetingMappingFlushAttempts = 5
+
+	// meetingMappingFlushTimeout bounds the flush of a batch.
+	meetingMappingFlushTimeout = time.Minute
+)
+
+var meetingMappingBatchResults = newCounterVec(
+	"v1_sync_helper_meeting_mapping_batches_total",
+	"Number of meeting mapping updates batched, and of batches flushed, by result (batched, flushed, retried, or dropped).",
+	"result",
+)
+
+// meetingMappingBatches holds the meeting mapping updates pending a flush.
+var meetingMappingBatches = &meetingMappingBatcher{batches: map[string]*meetingMappingBatch{}}
+
+// meetingMappingBatcher coalesces meeting mapping updates by meeting.
+type meetingMappingBatcher struct {
+	mu      sync.Mutex
+	batches map[string]*meetingMappingBatch
+	closed  bool
+	flushes sync.WaitGroup
+}
+
+// meetingMappingBatch is the pending mapping updates of a meeting, by mapping
+// ID, the latest update of a mapping replacing the previous ones, and the
+// deferred settlements of their messages.
+type meetingMappingBatch struct {
+	mappings    map[string]*ZoomMeetingMappingDB
+	settlements []*deferredSettlement
+	timer       *time.Timer
+	attempt     int
+}
+
+// add adds a mapping update to the pending batch of its meeting, starting the
+// batch window of the meeting if needed, and defers the settlement of its
+// message until the batch is flushed. It returns false when batching is
+// disabled, the service is shutting down, or the message cannot be deferred,
+// in which case the caller applies the update itself.
+func (b *meetingMappingBatcher) add(ctx context.Context, mapping *ZoomMeetingMappingDB) bool {
+	if cfg.MeetingMappingBatchWindow <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	settlement := deferSettlement(ctx)
+	if settlement == nil {
+		return false
+	}
+	b.enqueue(mapping.MeetingID, []*ZoomMeetingMappingDB{mapping}, []*deferredSettlement{settlement}, 0)
+	meetingMappingBatchResults.inc("batched")
+	return true
+}
+
+// enqueue adds mappings to the pending batch of a meeting, keeping newer
+// pending updates of the same mappings. The caller holds the lock.
+func (b *meetingMappingBatcher) enqueue(meetingID string, mappings []*ZoomMeetingMappingDB, settlements []*deferredSettlement, attempt int) {
+	batch, ok := b.batches[meetingID]
+	if !ok {
+		batch = &meetingMappingBatch{mappings: map[string]*ZoomMeetingMappingDB{}, attempt: attempt}
+		b.batches[meetingID] = batch
+		b.flushes.Add(1)
+		batch.timer = time.AfterFunc(cfg.MeetingMappingBatchWindow, func() {
+			defer b.flushes.Done()
+			b.flush(meetingID)
+		})
+	}
+	for _, mapping := range mappings {
+		if _, pending := batch.mappings[mapping.ID]; !pending || attempt == 0 {
+			batch.mappings[mapping.ID] = mapping
+		}
+	}
+	batch.settlements = append(batch.settlements, settlements...)
+}
+
+// discard drops the pending update of a mapping which was deleted, so a
+// later flush does not add it back to the index.
+func (b *meetingMappingBatcher) discard(meetingID, mappingID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if batch, ok := b.batches[meetingID]; ok {
+		delete(batch.mappings, mappingID)
+	}
+}
+
+// flush applies the pending batch of a meeting, settling its messages, or
+// re-queuing it for another window if it should be retried.
+func (b *meetingMappingBatcher) flush(meetingID string) {
+	b.mu.Lock()
+	batch, ok := b.batches[meetingID]
+	delete(b.batches, meetingID)
+	b.mu.Unlock()
+	if !ok {
+		return
+	}
+	if len(batch.mappings) == 0 {
+		batch.resolve(false)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), meetingMappingFlushTimeout)
+	defer cancel()
+	ctx, publishes := withPublishTracker(ctx)
+	log := logger.With("meeting_id", meetingID, "mappings", len(batch.mappings), "attempt", batch.attempt+1)
+
+	mappings := make([]*ZoomMeetingMappingDB, 0, len(batch.mappings))
+	for _, mapping := range batch.mappings {
+		mappings = append(mappings, mapping)
+	}
+	retry := false
+	if meeting, retryMeeting := getMappedMeeting(ctx, log, meetingID); meeting != nil {
+		retry = applyMeetingMappings(ctx, meetingID, meeting, mappings)
+	} else {
+		retry = retryMeeting
+	}
+	if publishes.failures() > 0 {
+		retry = true
+	}
+	if !retry {
+		meetingMappingBatchResults.inc("flushed")
+		batch.resolve(false)
+		return
+	}
+
+	b.mu.Lock()
+	if b.closed || batch.attempt+1 >= meetingMappingFlushAttempts {
+		b.mu.Unlock()
+		meetingMappingBatchResults.inc("dropped")
+		log.ErrorContext(ctx, "failed to flush meeting mapping batch, NAKing its messages for redelivery")
+		batch.resolve(true)
+		return
+	}
+	meetingMappingBatchResults.inc("retried")
+	log.WarnContext(ctx, "failed to flush meeting mapping batch, will retry")
+	for _, settlement := range batch.settlements {
+		settlement.inProgress()
+	}
+	b.enqueue(meetingID, mappings, batch.settlements, batch.attempt+1)
+	b.mu.Unlock()
+}
+
+// resolve settles the messages of a batch, once flushed or dropped.
+func (batch *meetingMappingBatch) resolve(retry bool) {
+	for _, settlement := range batch.settlements {
+		settlement.resolve(retry)
+	}
+}
+
+// close flushes the pending batches, without waiting for their windows, and
+// stops batching: later updates are applied directly.
+func (b *meetingMappingBatcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	var pending []string
+	for meetingID, batch := range b.batches {
+		if batch.timer.Stop() {
+			pending = append(pending, meetingID)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, meetingID := range pending {
+		b.flush(meetingID)
+		b.flushes.Done()
+	}
+	b.flushes.Wait()
+}
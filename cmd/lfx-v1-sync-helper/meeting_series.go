@@ -0,0 +1,123 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting series splits. The calendar events of a meeting are identified by
+// its ICS UID: the meeting ID, or the use_unique_ics_uid UUID once v1 assigns
+// one. A new ICS UID makes calendar clients treat the meeting as a new series,
+// so the ICS UID last synced for each meeting is kept in the mappings bucket,
+// and a change is sent as a series split event, and flagged on the re-indexed
+// meeting, for v2 calendar consumers to reconcile the two series.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// meetingICSUIDKeyFmt is the mappings KV key format of the ICS UID state of a
+// meeting, by meeting ID.
+const meetingICSUIDKeyFmt = "v1_meeting_ics_uids.%s"
+
+// meetingICSState is the ICS UID last synced for a meeting, and the one it
+// replaced at the last series split, if any.
+type meetingICSState struct {
+	ICSUID         string     `json:"ics_uid"`
+	PreviousICSUID string     `json:"previous_ics_uid,omitempty"`
+	SplitAt        *time.Time `json:"split_at,omitempty"`
+}
+
+// MeetingSeriesSplitMessage is the schema of the event sent when the ICS UID
+// of a meeting changes, so calendar consumers can reconcile the previous
+// series with the new one.
+type MeetingSeriesSplitMessage struct {
+	MeetingUID     string    `json:"meeting_uid"`
+	ProjectUID     string    `json:"project_uid"`
+	PreviousICSUID string    `json:"previous_ics_uid"`
+	ICSUID         string    `json:"ics_uid"`
+	SplitAt        time.Time `json:"split_at"`
+}
+
+// meetingICSUID returns the ICS UID of the calendar events of a meeting.
+func meetingICSUID(meeting *meetingInput) string {
+	if meeting.UseUniqueICSUID != "" {
+		return meeting.UseUniqueICSUID
+	}
+	return meeting.ID
+}
+
+// nextMeetingICSState returns the ICS UID state of a meeting being synced,
+// whether its ICS UID changed since it was last synced, and whether the state
+// needs to be stored. Meetings synced for the first time (or before ICS UIDs
+// were tracked) are never split.
+func nextMeetingICSState(ctx context.Context, meeting *meetingInput) (state meetingICSState, split, store bool, err error) {
+	icsUID := meetingICSUID(meeting)
+
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(meetingICSUIDKeyFmt, meeting.ID))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return meetingICSState{ICSUID: icsUID}, false, true, nil
+	}
+	if err != nil {
+		return meetingICSState{}, false, false, fmt.Errorf("failed to get meeting ICS UID state: %w", err)
+	}
+	var previous meetingICSState
+	if err := json.Unmarshal(entry.Value(), &previous); err != nil {
+		return meetingICSState{}, false, false, fmt.Errorf("failed to unmarshal meeting ICS UID state: %w", err)
+	}
+
+	if previous.ICSUID == icsUID {
+		return previous, false, false, nil
+	}
+	splitAt := bootstrap.Now().UTC()
+	return meetingICSState{ICSUID: icsUID, PreviousICSUID: previous.ICSUID, SplitAt: &splitAt}, true, true, nil
+}
+
+// putMeetingICSState stores the ICS UID state of a synced meeting.
+func putMeetingICSState(ctx context.Context, meetingID string, state meetingICSState) error {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal meeting ICS UID state: %w", err)
+	}
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(meetingICSUIDKeyFmt, meetingID), stateBytes); err != nil {
+		return fmt.Errorf("failed to store meeting ICS UID state: %w", err)
+	}
+	return nil
+}
+
+// getMeetingSeriesTags returns the indexer tags flagging the ICS UIDs of a
+// meeting, including the one replaced at its last series split.
+func getMeetingSeriesTags(state meetingICSState) []string {
+	tags := []string{fmt.Sprintf("ics_uid:%s", state.ICSUID)}
+	if state.PreviousICSUID != "" {
+		tags = append(tags, fmt.Sprintf("previous_ics_uid:%s", state.PreviousICSUID), "series_split")
+	}
+	return tags
+}
+
+// sendMeetingSeriesSplitEvent sends a series split event.
+func sendMeetingSeriesSplitEvent(ctx context.Context, meeting *meetingInput, state meetingICSState) error {
+	event := MeetingSeriesSplitMessage{
+		MeetingUID:     meeting.ID,
+		ProjectUID:     meeting.ProjectUID,
+		PreviousICSUID: state.PreviousICSUID,
+		ICSUID:         state.ICSUID,
+	}
+	if state.SplitAt != nil {
+		event.SplitAt = *state.SplitAt
+	}
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal meeting series split event: %w", err)
+	}
+	if err := publishMessage(ctx, V1MeetingSeriesSplitSubject, eventBytes); err != nil {
+		return fmt.Errorf("failed to publish meeting series split event to subject %s: %w", V1MeetingSeriesSplitSubject, err)
+	}
+	return nil
+}
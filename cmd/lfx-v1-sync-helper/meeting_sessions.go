@@ -0,0 +1,90 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Zoom session deduplication. The same Zoom session can arrive in several
+// updates of a past meeting recording or attendee, which v1 appends to the
+// record's sessions again, creating duplicate sessions downstream. The
+// sessions are deduplicated when the records are decoded, before the v2
+// payloads are built: attendee sessions by participant UUID and join time,
+// and recording sessions by session UUID. The first occurrence of a session
+// is kept, with its empty fields filled from the later duplicates (e.g. the
+// leave time of an attendee session received after the join).
+
+var duplicateSessions = newCounterVec(
+	"v1_sync_helper_duplicate_sessions_total",
+	"Number of duplicate Zoom sessions dropped from past meeting records, by record kind (attendee or recording).",
+	"kind",
+)
+
+// dedupeAttendeeSessions drops the duplicate sessions of an attendee, by
+// participant UUID and join time. Sessions without either are kept as is.
+func dedupeAttendeeSessions(sessions []ZoomPastMeetingAttendeeSession) []ZoomPastMeetingAttendeeSession {
+	if len(sessions) < 2 {
+		return sessions
+	}
+	type sessionKey struct{ participantUUID, joinTime string }
+	deduped := make([]ZoomPastMeetingAttendeeSession, 0, len(sessions))
+	seen := map[sessionKey]int{}
+	for _, session := range sessions {
+		if session.ParticipantUUID == "" || session.JoinTime == "" {
+			deduped = append(deduped, session)
+			continue
+		}
+		key := sessionKey{session.ParticipantUUID, session.JoinTime}
+		i, ok := seen[key]
+		if !ok {
+			seen[key] = len(deduped)
+			deduped = append(deduped, session)
+			continue
+		}
+		duplicateSessions.inc("attendee")
+		kept := &deduped[i]
+		if kept.LeaveTime == "" {
+			kept.LeaveTime = session.LeaveTime
+		}
+		if kept.LeaveReason == "" {
+			kept.LeaveReason = session.LeaveReason
+		}
+	}
+	return deduped
+}
+
+// dedupeRecordingSessions drops the duplicate sessions of a recording, by
+// session UUID. Sessions without a UUID are kept as is.
+func dedupeRecordingSessions(sessions []ZoomPastMeetingRecordingSession) []ZoomPastMeetingRecordingSession {
+	if len(sessions) < 2 {
+		return sessions
+	}
+	deduped := make([]ZoomPastMeetingRecordingSession, 0, len(sessions))
+	seen := map[string]int{}
+	for _, session := range sessions {
+		if session.UUID == "" {
+			deduped = append(deduped, session)
+			continue
+		}
+		i, ok := seen[session.UUID]
+		if !ok {
+			seen[session.UUID] = len(deduped)
+			deduped = append(deduped, session)
+			continue
+		}
+		duplicateSessions.inc("recording")
+		kept := &deduped[i]
+		if kept.ShareURL == "" {
+			kept.ShareURL = session.ShareURL
+		}
+		if kept.TotalSize == 0 {
+			kept.TotalSize = session.TotalSize
+		}
+		if kept.StartTime == "" {
+			kept.StartTime = session.StartTime
+		}
+		if kept.Password == "" {
+			kept.Password = session.Password
+		}
+	}
+	return deduped
+}
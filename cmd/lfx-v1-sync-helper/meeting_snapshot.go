@@ -0,0 +1,147 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting snapshot enrichment. Search shows registrant and RSVP results with
+// the title and time of their meeting, which takes a second query per result.
+// With MEETING_SNAPSHOT_ENRICHMENT set, a compact snapshot of the parent
+// meeting is embedded in the meeting field of registrant and invite response
+// indexer payloads. The snapshot of each meeting is stored in the mappings
+// bucket when the meeting is synced; when it changes, a cascade job re-runs
+// the meeting's registrants and invite responses (found through their
+// per-meeting indexes) through the dispatcher, to re-index them with the new
+// snapshot.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// meetingSnapshotKeyFmt is the mappings KV key format of the snapshot of a
+// meeting, by meeting ID.
+const meetingSnapshotKeyFmt = "v1_meeting_snapshots.%s"
+
+var meetingSnapshotCascades = newCounterVec(
+	"v1_sync_helper_meeting_snapshot_cascades_total",
+	"Number of registrants and invite responses re-run after a change of their meeting snapshot, by object type and result (refreshed, retried, or dropped).",
+	"object_type", "result",
+)
+
+// rerunKVEntry re-runs a v1 record through the dispatcher. It is kvHandler,
+// assigned in init, as the dispatcher itself reaches updateMeetingSnapshot.
+var rerunKVEntry func(ctx context.Context, entry jetstream.KeyValueEntry) bool
+
+func init() {
+	rerunKVEntry = kvHandler
+}
+
+// meetingSnapshot is the compact copy of a meeting embedded in the payloads
+// of its registrants and invite responses.
+type meetingSnapshot struct {
+	UID        string `json:"uid"`
+	Title      string `json:"title"`
+	StartTime  string `json:"start_time"`
+	Timezone   string `json:"timezone,omitempty"`
+	ProjectUID string `json:"project_uid"`
+}
+
+// meetingInviteResponseIndexPrefix returns the v1-mappings key prefix of the
+// invite response index of a meeting, followed by the invite response record
+// keys.
+func meetingInviteResponseIndexPrefix(meetingID string) string {
+	return fmt.Sprintf("v1_meeting_invite_response_index.%s", meetingID)
+}
+
+// newMeetingSnapshot returns the snapshot of a converted meeting.
+func newMeetingSnapshot(meeting *meetingInput) meetingSnapshot {
+	return meetingSnapshot{
+		UID:        meeting.ID,
+		Title:      meeting.Title,
+		StartTime:  meeting.StartTime,
+		Timezone:   meeting.Timezone,
+		ProjectUID: meeting.ProjectUID,
+	}
+}
+
+// getMeetingSnapshot returns the stored snapshot of a meeting, or nil if
+// there is none.
+func getMeetingSnapshot(ctx context.Context, meetingID string) (*meetingSnapshot, error) {
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(meetingSnapshotKeyFmt, meetingID))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isTombstonedMapping(entry.Value()) {
+		return nil, nil
+	}
+	var snapshot meetingSnapshot
+	if err := json.Unmarshal(entry.Value(), &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal meeting snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// enrichmentMeetingSnapshot returns the snapshot of a meeting to embed in the
+// payload of one of its children, if enrichment is enabled. A snapshot which
+// cannot be read is logged and left out.
+func enrichmentMeetingSnapshot(ctx context.Context, meetingID string) *meetingSnapshot {
+	if !cfg.MeetingSnapshotEnrichment || meetingID == "" {
+		return nil
+	}
+	snapshot, err := getMeetingSnapshot(ctx, meetingID)
+	if err != nil {
+		logger.With(errKey, err, "meeting_id", meetingID).WarnContext(ctx, "failed to get meeting snapshot")
+		return nil
+	}
+	return snapshot
+}
+
+// updateMeetingSnapshot stores the snapshot of a synced meeting, if
+// enrichment is enabled, and queues the refresh of the payloads of its
+// registrants and invite responses when a previously stored snapshot
+// changed.
+func updateMeetingSnapshot(ctx context.Context, meeting *meetingInput) {
+	if !cfg.MeetingSnapshotEnrichment {
+		return
+	}
+	funcLogger := logger.With("meeting_id", meeting.ID)
+	snapshot := newMeetingSnapshot(meeting)
+
+	previous, err := getMeetingSnapshot(ctx, meeting.ID)
+	if err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to get meeting snapshot")
+		return
+	}
+	if previous != nil && *previous == snapshot {
+		return
+	}
+
+	value, err := json.Marshal(snapshot)
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to marshal meeting snapshot")
+		return
+	}
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(meetingSnapshotKeyFmt, meeting.ID), value); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store meeting snapshot")
+		return
+	}
+
+	// Children synced before the first snapshot are refreshed by their next
+	// sync (or a resync), rather than on every meeting's first snapshot.
+	if previous == nil || contextDryRun(ctx) != nil {
+		return
+	}
+	if err := enqueueCascadeJob(ctx, cascadeJobMeetingSnapshot, meeting.ID, meetingRegistrantIndexPrefix(meeting.ID), meetingInviteResponseIndexPrefix(meeting.ID)); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to queue the refresh of the meeting's registrants and invite responses")
+		return
+	}
+	funcLogger.InfoContext(ctx, "meeting snapshot changed, queued the refresh of its registrants and invite responses")
+}
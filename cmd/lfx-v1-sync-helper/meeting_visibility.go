@@ -0,0 +1,93 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting visibility defaults. Some v1 meetings and past meetings have no
+// visibility at all, which used to make them private. The project sync now
+// records the public flag it computes for each project next to its
+// project.sfid mapping, under project.public.<sfid>, and a meeting or past
+// meeting without a visibility takes that of its project: public for public
+// projects, private otherwise. Projects synced before the flag was recorded
+// are looked up in the v2 project service once, and the result is recorded
+// the same way.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// projectPublicKeyFmt is the mappings KV key of the public flag of a
+	// project, by project SFID.
+	projectPublicKeyFmt = "project.public.%s"
+
+	// meetingVisibilityPublic and meetingVisibilityPrivate are the v1
+	// visibility values of meetings.
+	meetingVisibilityPublic  = "public"
+	meetingVisibilityPrivate = "private"
+)
+
+var meetingVisibilityDefaults = newCounterVec(
+	"v1_sync_helper_meeting_visibility_defaults_total",
+	"Number of meetings and past meetings without a visibility, defaulted from their project, by object type and result (public, private, or unresolved for projects whose public flag could not be determined, defaulted to private).",
+	"object_type", "result",
+)
+
+// storeProjectPublic records the public flag of a synced project.
+func storeProjectPublic(ctx context.Context, projectSFID string, public bool) {
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(projectPublicKeyFmt, projectSFID), []byte(strconv.FormatBool(public))); err != nil {
+		logger.With(errKey, err, "sfid", projectSFID).WarnContext(ctx, "failed to store project public flag")
+	}
+}
+
+// deleteProjectPublic removes the public flag of a deleted project.
+func deleteProjectPublic(ctx context.Context, projectSFID string) {
+	if err := mappingsKV.Delete(ctx, fmt.Sprintf(projectPublicKeyFmt, projectSFID)); err != nil && !errors.Is(err, jetstream.ErrKeyNotFound) {
+		logger.With(errKey, err, "sfid", projectSFID).WarnContext(ctx, "failed to delete project public flag")
+	}
+}
+
+// projectPublic returns the public flag of a project, recorded by the project
+// sync, or else fetched from the v2 project service and recorded.
+func projectPublic(ctx context.Context, projectSFID, projectUID string) (bool, error) {
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(projectPublicKeyFmt, projectSFID))
+	if err == nil {
+		return strconv.ParseBool(string(entry.Value()))
+	}
+	if !errors.Is(err, jetstream.ErrKeyNotFound) {
+		return false, err
+	}
+	if projectUID == "" {
+		return false, fmt.Errorf("project %s has no v2 project UID", projectSFID)
+	}
+	project, _, err := fetchProjectBase(ctx, projectUID)
+	if err != nil {
+		return false, err
+	}
+	public := project.Public != nil && *project.Public
+	storeProjectPublic(ctx, projectSFID, public)
+	return public, nil
+}
+
+// defaultMeetingVisibility returns the visibility of a meeting or past meeting
+// without one, from the public flag of its project.
+func defaultMeetingVisibility(ctx context.Context, objectType, projectSFID, projectUID string) string {
+	public, err := projectPublic(ctx, projectSFID, projectUID)
+	switch {
+	case err != nil:
+		meetingVisibilityDefaults.inc(objectType, "unresolved")
+		logger.With(errKey, err, "object_type", objectType, "project_sfid", projectSFID).WarnContext(ctx, "failed to determine project public flag, defaulting visibility to private")
+		return meetingVisibilityPrivate
+	case public:
+		meetingVisibilityDefaults.inc(objectType, "public")
+		return meetingVisibilityPublic
+	}
+	meetingVisibilityDefaults.inc(objectType, "private")
+	return meetingVisibilityPrivate
+}
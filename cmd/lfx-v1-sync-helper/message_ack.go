@@ -0,0 +1,176 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// JetStream message settlement with outcome metrics, so messages dropped after
+// exhausting their deliveries can be told apart from those processed
+// successfully. Handlers which complete the sync of a message asynchronously
+// (e.g. batched meeting mappings) defer its settlement until they do.
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStream message outcomes.
+const (
+	// outcomeAck is a message acknowledged after processing.
+	outcomeAck = "ack"
+	// outcomeNak is a message negatively acknowledged for redelivery.
+	outcomeNak = "nak"
+	// outcomeDropped is a message NAKed on its last delivery, which JetStream
+	// drops, emitting a MAX_DELIVERIES advisory.
+	outcomeDropped = "dropped"
+	// outcomeAckTimeout is a message whose processing exceeded the consumer
+	// AckWait, so it was redelivered regardless of the settlement sent.
+	outcomeAckTimeout = "ack_timeout"
+	// outcomeError is a message whose settlement failed to be sent.
+	outcomeError = "error"
+)
+
+// jetStreamMessages counts JetStream message outcomes per consumer and object type.
+var jetStreamMessages = newCounterVec(
+	"v1_sync_helper_jetstream_messages_total",
+	"Number of JetStream messages processed, by consumer, object type, and outcome.",
+	"consumer", "object_type", "outcome",
+)
+
+// settleMessage acknowledges a processed JetStream message, or NAKs it with
+// the given delay (immediately if zero) when the handler requested a retry.
+// Messages which have exhausted their deliveries are NAKed without a delay
+// rather than terminated, so JetStream drops them with its MAX_DELIVERIES
+// advisory. The outcome is recorded against the consumer and object type, and
+// acknowledged and dropped messages are counted against the processing
+// latency SLO.
+func settleMessage(ctx context.Context, msg jetstream.Msg, consumer, objectType string, shouldRetry bool, nakDelay time.Duration, started time.Time) {
+	funcLogger := logger.With("subject", msg.Subject(), "consumer", consumer, "object_type", objectType)
+	maxDeliver, ackWait := consumerDelivery(consumer)
+
+	if time.Since(started) > ackWait {
+		jetStreamMessages.inc(consumer, objectType, outcomeAckTimeout)
+		funcLogger.With("elapsed", time.Since(started).String()).WarnContext(ctx, "JetStream message processing exceeded the consumer ack wait")
+	}
+
+	if !shouldRetry {
+		if err := msg.Ack(); err != nil {
+			jetStreamMessages.inc(consumer, objectType, outcomeError)
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to acknowledge JetStream message")
+			return
+		}
+		jetStreamMessages.inc(consumer, objectType, outcomeAck)
+		if metadata, err := msg.Metadata(); err == nil {
+			recordSLOEvent(consumer, metadata.Timestamp, true)
+		}
+		return
+	}
+
+	metadata, err := msg.Metadata()
+	if err == nil && metadata.NumDelivered >= uint64(maxDeliver) {
+		if err := msg.Nak(); err != nil {
+			jetStreamMessages.inc(consumer, objectType, outcomeError)
+			funcLogger.With(errKey, err).ErrorContext(ctx, "failed to NAK JetStream message on its last delivery")
+			return
+		}
+		jetStreamMessages.inc(consumer, objectType, outcomeDropped)
+		recordSLOEvent(consumer, metadata.Timestamp, false)
+		funcLogger.With("attempt", metadata.NumDelivered).WarnContext(ctx, "dropped JetStream message after exhausting its deliveries")
+		return
+	}
+
+	if nakDelay > 0 {
+		err = msg.NakWithDelay(nakDelay)
+	} else {
+		err = msg.Nak()
+	}
+	if err != nil {
+		jetStreamMessages.inc(consumer, objectType, outcomeError)
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to NAK JetStream message for retry")
+		return
+	}
+	jetStreamMessages.inc(consumer, objectType, outcomeNak)
+}
+
+// deferredSettlement holds the settlement of a KV message whose handler
+// completes its sync asynchronously, until both the handler returned and the
+// sync completed.
+type deferredSettlement struct {
+	msg      jetstream.Msg
+	mu       sync.Mutex
+	deferred bool
+	settled  bool
+	result   *bool
+	settle   func(retry bool)
+}
+
+// deferredSettlementContextKey is the context key of the deferred settlement
+// of a KV message.
+type deferredSettlementContextKey struct{}
+
+// withDeferredSettlement returns a context whose handler may defer the
+// settlement of its message through the returned value.
+func withDeferredSettlement(ctx context.Context, msg jetstream.Msg) (context.Context, *deferredSettlement) {
+	settlement := &deferredSettlement{msg: msg}
+	return context.WithValue(ctx, deferredSettlementContextKey{}, settlement), settlement
+}
+
+// deferSettlement defers the settlement of the message of the context until
+// its sync is resolved, returning nil if the message cannot be deferred (e.g.
+// replays and WAL events), in which case the handler completes its sync
+// itself.
+func deferSettlement(ctx context.Context) *deferredSettlement {
+	settlement, ok := ctx.Value(deferredSettlementContextKey{}).(*deferredSettlement)
+	if !ok {
+		return nil
+	}
+	settlement.mu.Lock()
+	defer settlement.mu.Unlock()
+	settlement.deferred = true
+	return settlement
+}
+
+// inProgress extends the AckWait of a deferred message.
+func (d *deferredSettlement) inProgress() {
+	if err := d.msg.InProgress(); err != nil {
+		logger.With(errKey, err, "subject", d.msg.Subject()).Warn("failed to extend AckWait of a deferred KV message")
+	}
+}
+
+// resolve records the completion of the deferred sync of a message, settling
+// it if its handler returned.
+func (d *deferredSettlement) resolve(retry bool) {
+	d.mu.Lock()
+	if d.settled {
+		d.mu.Unlock()
+		return
+	}
+	if d.settle == nil {
+		d.result = &retry
+		d.mu.Unlock()
+		return
+	}
+	d.settled = true
+	settle := d.settle
+	d.mu.Unlock()
+	settle(retry)
+}
+
+// finish settles a message once its handler returned: immediately if it
+// was not deferred or is retried, or else once its deferred sync is
+// resolved.
+func (d *deferredSettlement) finish(retry bool, settle func(retry bool)) {
+	d.mu.Lock()
+	if !d.deferred || retry || d.result != nil {
+		d.settled = true
+		retry = retry || (d.result != nil && *d.result)
+		d.mu.Unlock()
+		settle(retry)
+		return
+	}
+	d.settle = settle
+	d.mu.Unlock()
+}
@@ -0,0 +1,174 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Outbound message signing. Anything able to publish to the NATS cluster can
+// publish indexer and access messages, so downstream services cannot tell
+// whether a message originated from this service. With
+// MESSAGE_SIGNING_ALGORITHM set, every published message (indexer, access,
+// and event messages, including their copies to the publish targets) carries
+// a signature of its subject, signing timestamp, and payload, made with
+// MESSAGE_SIGNING_KEY, which consumers verify as described in the README
+// ("Message signing").
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
+)
+
+// Message signing algorithms.
+const (
+	signingAlgorithmHMACSHA256 = "hmac-sha256"
+	signingAlgorithmEd25519    = "ed25519"
+)
+
+// Message signing headers.
+const (
+	signatureHeader          = "Lfx-Signature"
+	signatureAlgorithmHeader = "Lfx-Signature-Algorithm"
+	signatureKeyIDHeader     = "Lfx-Signature-Key-Id"
+	signatureTimestampHeader = "Lfx-Signature-Timestamp"
+)
+
+// minHMACSigningKeyBytes is the minimum size of HMAC signing keys.
+const minHMACSigningKeyBytes = 32
+
+// messageSigner signs published messages, or is nil when signing is disabled.
+var messageSigner *signer
+
+// signer signs messages with a key.
+type signer struct {
+	algorithm string
+	keyID     string
+	hmacKey   []byte
+	ed25519   ed25519.PrivateKey
+}
+
+// messageSigningStatus is the message signing configuration reported by
+// /statusz, with the public key consumers verify Ed25519 signatures with.
+type messageSigningStatus struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+// validateMessageSigningAlgorithm checks that MESSAGE_SIGNING_ALGORITHM is
+// empty or a supported algorithm.
+func validateMessageSigningAlgorithm(algorithm string) error {
+	switch algorithm {
+	case "", signingAlgorithmHMACSHA256, signingAlgorithmEd25519:
+		return nil
+	}
+	return fmt.Errorf("MESSAGE_SIGNING_ALGORITHM must be %s or %s", signingAlgorithmHMACSHA256, signingAlgorithmEd25519)
+}
+
+// initMessageSigning parses the signing key, if message signing is enabled.
+func initMessageSigning(cfg *Config) error {
+	if cfg.MessageSigningAlgorithm == "" {
+		return nil
+	}
+	s := &signer{algorithm: cfg.MessageSigningAlgorithm, keyID: cfg.MessageSigningKeyID}
+
+	switch cfg.MessageSigningAlgorithm {
+	case signingAlgorithmHMACSHA256:
+		if len(cfg.MessageSigningKey) < minHMACSigningKeyBytes {
+			return fmt.Errorf("HMAC signing key must be at least %d bytes", minHMACSigningKeyBytes)
+		}
+		s.hmacKey = []byte(cfg.MessageSigningKey)
+	case signingAlgorithmEd25519:
+		block, _ := pem.Decode([]byte(cfg.MessageSigningKey))
+		if block == nil {
+			return fmt.Errorf("failed to parse PEM block containing the signing key")
+		}
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		var ok bool
+		s.ed25519, ok = privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return fmt.Errorf("signing key is not Ed25519")
+		}
+	}
+
+	messageSigner = s
+	logger.With("algorithm", s.algorithm, "key_id", s.keyID, "public_key", s.status().PublicKey).Info("message signing enabled")
+	return nil
+}
+
+// signMessage sets the signature headers of a message, if message signing is
+// enabled.
+func signMessage(msg *nats.Msg) {
+	if messageSigner == nil {
+		return
+	}
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	timestamp := bootstrap.Now().UTC().Format(time.RFC3339)
+	msg.Header.Set(signatureHeader, messageSigner.sign(msg.Subject, timestamp, msg.Data))
+	msg.Header.Set(signatureAlgorithmHeader, messageSigner.algorithm)
+	msg.Header.Set(signatureTimestampHeader, timestamp)
+	if messageSigner.keyID != "" {
+		msg.Header.Set(signatureKeyIDHeader, messageSigner.keyID)
+	}
+}
+
+// signedContent returns the content signed for a message: its subject, the
+// signing timestamp, and its payload, separated by newlines.
+func signedContent(subject, timestamp string, data []byte) []byte {
+	content := make([]byte, 0, len(subject)+len(timestamp)+len(data)+2)
+	content = append(content, subject...)
+	content = append(content, '\n')
+	content = append(content, timestamp...)
+	content = append(content, '\n')
+	return append(content, data...)
+}
+
+// sign returns the base64 (standard encoding) signature of a message.
+func (s *signer) sign(subject, timestamp string, data []byte) string {
+	content := signedContent(subject, timestamp, data)
+	var signature []byte
+	switch s.algorithm {
+	case signingAlgorithmHMACSHA256:
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write(content)
+		signature = mac.Sum(nil)
+	case signingAlgorithmEd25519:
+		signature = ed25519.Sign(s.ed25519, content)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// status returns the signing configuration, with the PEM-encoded public key
+// of Ed25519 keys.
+func (s *signer) status() messageSigningStatus {
+	status := messageSigningStatus{Algorithm: s.algorithm, KeyID: s.keyID}
+	if s.ed25519 != nil {
+		if der, err := x509.MarshalPKIXPublicKey(s.ed25519.Public()); err == nil {
+			status.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		}
+	}
+	return status
+}
+
+// messageSigningStatusz returns the message signing status of /statusz, or
+// nil when signing is disabled.
+func messageSigningStatusz() *messageSigningStatus {
+	if messageSigner == nil {
+		return nil
+	}
+	status := messageSigner.status()
+	return &status
+}
@@ -0,0 +1,182 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Minimal, dependency-free metrics registry exposed in the Prometheus text
+// exposition format on the health server's /metrics endpoint.
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// counterVec is a concurrency-safe monotonically increasing counter
+// partitioned by a fixed set of label names.
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.RWMutex
+	values map[string]*atomic.Uint64 // keyed by label values joined with "\xff"
+}
+
+// collector is a metric family rendered in the Prometheus text exposition format.
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	metricsMu       sync.Mutex
+	metricsRegistry []collector
+)
+
+// newCounterVec creates and registers a counter with the given label names.
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	c := &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*atomic.Uint64),
+	}
+	metricsMu.Lock()
+	metricsRegistry = append(metricsRegistry, c)
+	metricsMu.Unlock()
+	return c
+}
+
+// inc increments the counter for the given label values by one. The number
+// of label values must match the number of label names.
+func (c *counterVec) inc(labelValues ...string) {
+	c.add(1, labelValues...)
+}
+
+// add increments the counter for the given label values by n.
+func (c *counterVec) add(n uint64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.RLock()
+	v, ok := c.values[key]
+	c.mu.RUnlock()
+	if !ok {
+		c.mu.Lock()
+		if v, ok = c.values[key]; !ok {
+			v = &atomic.Uint64{}
+			c.values[key] = v
+		}
+		c.mu.Unlock()
+	}
+	v.Add(n)
+}
+
+// counterSample is the value of a counter for one set of label values.
+type counterSample struct {
+	labelValues []string
+	value       uint64
+}
+
+// samples returns the current value of every label combination, sorted by
+// label values.
+func (c *counterVec) samples() []counterSample {
+	c.mu.RLock()
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	c.mu.RUnlock()
+	sort.Strings(keys)
+
+	samples := make([]counterSample, 0, len(keys))
+	for _, k := range keys {
+		c.mu.RLock()
+		value := c.values[k].Load()
+		c.mu.RUnlock()
+		samples = append(samples, counterSample{labelValues: strings.Split(k, "\xff"), value: value})
+	}
+	return samples
+}
+
+// write renders the counter in the Prometheus text exposition format.
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, sample := range c.samples() {
+		fmt.Fprintf(w, "%s%s %d\n", c.name, formatLabels(c.labels, sample.labelValues), sample.value)
+	}
+}
+
+// gaugeSample is the value of a gauge for one set of label values.
+type gaugeSample struct {
+	labelValues []string
+	value       float64
+}
+
+// gaugeFunc is a gauge partitioned by a fixed set of label names, whose
+// values are collected when the metrics are scraped.
+type gaugeFunc struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() []gaugeSample
+}
+
+// newGaugeFunc creates and registers a gauge whose samples are returned by
+// collect on each scrape.
+func newGaugeFunc(name, help string, collect func() []gaugeSample, labels ...string) *gaugeFunc {
+	g := &gaugeFunc{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		collect: collect,
+	}
+	metricsMu.Lock()
+	metricsRegistry = append(metricsRegistry, g)
+	metricsMu.Unlock()
+	return g
+}
+
+// write renders the gauge in the Prometheus text exposition format.
+func (g *gaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, sample := range g.collect() {
+		fmt.Fprintf(w, "%s%s %g\n", g.name, formatLabels(g.labels, sample.labelValues), sample.value)
+	}
+}
+
+// formatLabels renders label pairs as {name="value",...}, or an empty string
+// when there are no labels.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// metricsHandler serves all registered metrics in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metricsMu.Lock()
+	registry := make([]collector, len(metricsRegistry))
+	copy(registry, metricsRegistry)
+	metricsMu.Unlock()
+
+	for _, c := range registry {
+		c.write(w)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Handler middleware composes cross-cutting behavior (redaction, enrichment,
+// validation, delta detection) per object type, declared on the handler
+// registry entry, instead of being repeated in every handler. Each middleware
+// may hook into three stages of an update:
+//
+//   - pre-conversion: on the v1 record, before the handler runs
+//   - post-conversion: on the input struct converted from the v1 record
+//   - pre-publish: on the payload of each indexer message the handler sends
+//
+// Middleware runs in the order declared. The middleware of the object type
+// being handled is carried in the context, so handlers only call the
+// post-conversion and pre-publish stages without knowing which hooks apply.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// errSkipRecord is returned by a middleware hook to stop processing the
+// record without an error (e.g. when nothing changed).
+var errSkipRecord = errors.New("record skipped by middleware")
+
+// handlerMiddleware is a named set of optional handler hooks.
+type handlerMiddleware struct {
+	// name identifies the middleware in logs.
+	name string
+	// preConversion may modify and return the v1 record, or return an error
+	// (such as errSkipRecord) to stop processing it.
+	preConversion func(ctx context.Context, key string, v1Data map[string]any) (map[string]any, error)
+	// postConversion may modify the converted input struct (a pointer), or
+	// return an error to stop processing it.
+	postConversion func(ctx context.Context, key string, input any) error
+	// prePublish may modify the indexer message payload, or return an error
+	// to stop the message from being published.
+	prePublish func(ctx context.Context, subject string, data any) error
+}
+
+// middlewareContextKey is the context key of the middleware of the object
+// type being handled.
+type middlewareContextKey struct{}
+
+// withMiddleware returns a context carrying the given middleware.
+func withMiddleware(ctx context.Context, middleware []handlerMiddleware) context.Context {
+	if len(middleware) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, middlewareContextKey{}, middleware)
+}
+
+// contextMiddleware returns the middleware carried by the context.
+func contextMiddleware(ctx context.Context) []handlerMiddleware {
+	middleware, _ := ctx.Value(middlewareContextKey{}).([]handlerMiddleware)
+	return middleware
+}
+
+// runPreConversionMiddleware runs the pre-conversion hooks of the context's
+// middleware on a v1 record, returning the (possibly modified) record.
+func runPreConversionMiddleware(ctx context.Context, key string, v1Data map[string]any) (map[string]any, error) {
+	for _, m := range contextMiddleware(ctx) {
+		if m.preConversion == nil {
+			continue
+		}
+		var err error
+		if v1Data, err = m.preConversion(ctx, key, v1Data); err != nil {
+			return nil, fmt.Errorf("%s pre-conversion: %w", m.name, err)
+		}
+	}
+	return v1Data, nil
+}
+
+// runPostConversionMiddleware runs the post-conversion hooks of the context's
+// middleware on a converted input struct.
+func runPostConversionMiddleware(ctx context.Context, key string, input any) error {
+	for _, m := range contextMiddleware(ctx) {
+		if m.postConversion == nil {
+			continue
+		}
+		if err := m.postConversion(ctx, key, input); err != nil {
+			return fmt.Errorf("%s post-conversion: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// runPrePublishMiddleware runs the pre-publish hooks of the context's
+// middleware on an indexer message payload. It returns false if the message
+// was skipped and should not be published.
+func runPrePublishMiddleware(ctx context.Context, subject string, data any) (bool, error) {
+	for _, m := range contextMiddleware(ctx) {
+		if m.prePublish == nil {
+			continue
+		}
+		if err := m.prePublish(ctx, subject, data); err != nil {
+			if errors.Is(err, errSkipRecord) {
+				logger.With("subject", subject, "middleware", m.name).DebugContext(ctx, "indexer message skipped by middleware")
+				return false, nil
+			}
+			return false, fmt.Errorf("%s pre-publish: %w", m.name, err)
+		}
+	}
+	return true, nil
+}
+
+// logMiddlewareStop logs a record or message stopped by middleware: at debug
+// level when skipped on purpose, otherwise as a warning.
+func logMiddlewareStop(ctx context.Context, funcLogger *slog.Logger, err error) {
+	if errors.Is(err, errSkipRecord) {
+		funcLogger.With("reason", err.Error()).DebugContext(ctx, "record skipped by middleware")
+		return
+	}
+	funcLogger.With(errKey, err).WarnContext(ctx, "record rejected by middleware")
+}
@@ -321,10 +321,11 @@ func (u *UpdatedOccurrence) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// Committee represents a committee with optional filters.
+// Committee represents a committee with the voting statuses allowed by its
+// filters, always listed explicitly (see normalizeCommitteeFilters).
 type Committee struct {
 	UID                   string   `json:"uid"`
-	AllowedVotingStatuses []string `json:"allowed_voting_statuses,omitempty"`
+	AllowedVotingStatuses []string `json:"allowed_voting_statuses"`
 }
 
 // meetingInput represents input data for creating or updating meetings.
@@ -367,6 +368,10 @@ type meetingInput struct {
 	// MeetingType is the type of meeting - this field exists in Zoom for a meeting
 	MeetingType string `json:"meeting_type"`
 
+	// CanonicalMeetingType is the meeting type classified from MeetingType and
+	// the meeting's committees. This is a v2 only attribute.
+	CanonicalMeetingType string `json:"canonical_meeting_type,omitempty"`
+
 	// StartTime is the start time of the meeting in RFC3339 format.
 	// If the meeting is a recurring meeting, this is the start time of the first occurrence.
 	StartTime string `json:"start_time"`
@@ -513,6 +518,14 @@ type meetingInput struct {
 	// ShowMeetingAttendees determines whether or not LFX One should show data about
 	// meeting attendees to each other
 	ShowMeetingAttendees bool `json:"show_meeting_attendees"`
+
+	// OccurrencesTruncated is set when occurrences were dropped from the indexer
+	// message to fit the payload size limit. This is a v2 only attribute.
+	OccurrencesTruncated bool `json:"occurrences_truncated,omitempty"`
+
+	// OccurrencesTotal is the number of occurrences before truncation, set
+	// along with OccurrencesTruncated. This is a v2 only attribute.
+	OccurrencesTotal int `json:"occurrences_total,omitempty"`
 }
 
 // MarshalJSON custom marshaler to include integer fields that are excluded from unmarshaling
@@ -966,6 +979,11 @@ type registrantInput struct {
 
 	// UpdatedBy is the user that last updated the registrant
 	UpdatedBy UpdatedBy `json:"updated_by"`
+
+	// Meeting is a snapshot of the parent meeting, with meeting snapshot
+	// enrichment.
+	// This is a v2 only attribute.
+	Meeting *meetingSnapshot `json:"meeting,omitempty"`
 }
 
 // RSVPResponseType represents the type of RSVP response
@@ -1055,6 +1073,11 @@ type inviteResponseInput struct {
 
 	// ModifiedAt is the timestamp in RFC3339 format of when the invite response was last modified.
 	ModifiedAt string `json:"modified_at" dynamodbav:"modified_at"`
+
+	// Meeting is a snapshot of the parent meeting, with meeting snapshot
+	// enrichment.
+	// This is a v2 only attribute.
+	Meeting *meetingSnapshot `json:"meeting,omitempty" dynamodbav:"-"`
 }
 
 // MeetingAttachmentDB is the model for meeting attachments in the database
@@ -1214,12 +1237,25 @@ type pastMeetingInput struct {
 	// MeetingType is the type of the past meeting
 	MeetingType string `json:"meeting_type"`
 
+	// CanonicalMeetingType is the past meeting type classified from
+	// MeetingType and the past meeting's committees. This is a v2 only
+	// attribute.
+	CanonicalMeetingType string `json:"canonical_meeting_type,omitempty"`
+
 	// TranscriptAccess is the access type of the transcript of the past meeting
 	TranscriptAccess string `json:"transcript_access"`
 
 	// TranscriptEnabled is whether the transcript of the past meeting is enabled
 	TranscriptEnabled bool `json:"transcript_enabled"`
 
+	// InviteeCount is the number of invitees of the past meeting
+	// This is a v2 only attribute, counted from the synced invitee records.
+	InviteeCount *int `json:"invitee_count,omitempty"`
+
+	// AttendeeCount is the number of attendees of the past meeting
+	// This is a v2 only attribute, counted from the synced attendee records.
+	AttendeeCount *int `json:"attendee_count,omitempty"`
+
 	// Type is the type of the past meeting
 	Type int `json:"-"`
 
@@ -1797,6 +1833,13 @@ type V2PastMeetingParticipant struct {
 	Sessions               []ParticipantSession `json:"sessions,omitempty"`
 	CreatedAt              *time.Time           `json:"created_at,omitempty"`
 	UpdatedAt              *time.Time           `json:"updated_at,omitempty"`
+	// Auto-matching of attendees who could not be identified to a meeting registrant.
+	IsAutoMatched        bool    `json:"is_auto_matched,omitempty"`
+	MappedInviteeName    string  `json:"mapped_invitee_name,omitempty"`
+	MatchedRegistrantUID string  `json:"matched_registrant_uid,omitempty"`
+	MatchConfidence      float64 `json:"match_confidence,omitempty"`
+	// Conversion warnings, sent when INDEXER_SYNC_WARNINGS is enabled.
+	syncWarnings
 }
 
 // ParticipantSession represents a single join/leave session of a participant in a meeting
@@ -1921,6 +1964,17 @@ type ZoomPastMeetingRecordingSession struct {
 
 	// Password is the password of the session.
 	Password string `json:"password"` // legacy from V1 meetings when there was a password to view recordings
+
+	// PastMeetingSessionUID is the UUID of the matching session of the past meeting, set by
+	// linkRecordingSessions when the past meeting has a session with the same UUID.
+	PastMeetingSessionUID string `json:"past_meeting_session_uid,omitempty"`
+
+	// SessionNumber is the 1-based position of the matching past meeting session, ordered by
+	// start time, out of the SessionCount sessions of the past meeting.
+	SessionNumber int `json:"session_number,omitempty"`
+
+	// SessionCount is the number of sessions of the past meeting.
+	SessionCount int `json:"session_count,omitempty"`
 }
 
 // MarshalJSON custom marshaler to include integer fields that are excluded from unmarshaling
@@ -2058,6 +2112,16 @@ type pastMeetingSummaryInput struct {
 	// This is a v2 only attribute.
 	EditedContent string `json:"edited_content"`
 
+	// ContentLanguage is the ISO 639-1 code of the detected language of the
+	// summary content, if it could be detected.
+	// This is a v2 only attribute.
+	ContentLanguage string `json:"content_language,omitempty"`
+
+	// TranslatedContent is the content of the summary translated to the
+	// SUMMARY_TRANSLATION_TARGET_LANGUAGE, when translation is enabled.
+	// This is a v2 only attribute.
+	TranslatedContent string `json:"translated_content,omitempty"`
+
 	// RequiresApproval is whether the summary requires approval.
 	RequiresApproval bool `json:"requires_approval"`
 
@@ -9,8 +9,70 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
 )
 
+// publishCore publishes a message on the NATS connection. Tests replace it
+// with an in-memory publisher.
+var publishCore = func(msg *nats.Msg) error {
+	return natsConn.PublishMsg(msg)
+}
+
+// publishMessage publishes a message to NATS, with the correlation ID of the
+// context as a header, signed if message signing is enabled. Once published
+// to the primary connection (and acknowledged by its stream, when publishes
+// go through JetStream), the message is queued for the additional publish
+// targets. Publish failures are recorded in the publish tracker of the
+// context. In a dry run, the message is recorded, and only published for
+// passthrough recorders.
+func publishMessage(ctx context.Context, subject string, data []byte) error {
+	if err := checkPublishSubject("publish", subject); err != nil {
+		return err
+	}
+	msg := &nats.Msg{Subject: subject, Data: data}
+	bootstrap.SetCorrelationHeader(ctx, msg)
+	ctx, span := startPublishSpan(ctx, msg)
+	signMessage(msg)
+	if recorder := contextDryRun(ctx); recorder != nil {
+		recorder.recordPublish(msg)
+		if !recorder.passthrough {
+			endSpan(span, nil)
+			recordPublished(ctx)
+			return nil
+		}
+	}
+	publish := publishCore
+	if publishAckEnabled() {
+		publish = func(msg *nats.Msg) error { return publishAcked(ctx, msg) }
+	}
+	err := publish(msg)
+	endSpan(span, err)
+	if err != nil {
+		recordPublishFailure(ctx)
+		return err
+	}
+	recordPublished(ctx)
+	fanOutMessage(msg)
+	sampleSchema(subject, data)
+	return nil
+}
+
+// requestMessage sends a NATS request, with the correlation ID of the context
+// as a header, and waits for the response until the context is done.
+func requestMessage(ctx context.Context, subject string, data []byte) (*nats.Msg, error) {
+	if err := checkPublishSubject("request", subject); err != nil {
+		return nil, err
+	}
+	msg := &nats.Msg{Subject: subject, Data: data}
+	bootstrap.SetCorrelationHeader(ctx, msg)
+	ctx, span := startPublishSpan(ctx, msg)
+	resp, err := natsConn.RequestMsgWithContext(ctx, msg)
+	endSpan(span, err)
+	return resp, err
+}
+
 // getProjectUIDBySlug looks up a v2 project UID from a project slug via NATS.
 // Can be used to lookup any project by its slug (e.g., "ROOT", "kubernetes", "linux", etc.).
 func getProjectUIDBySlug(ctx context.Context, slug string) (string, error) {
@@ -21,7 +83,7 @@ func getProjectUIDBySlug(ctx context.Context, slug string) (string, error) {
 	logger.With("slug", slug).DebugContext(ctx, "requesting project UID via NATS")
 
 	// Make a NATS request to the slug_to_uid subject.
-	resp, err := natsConn.RequestWithContext(requestCtx, "lfx.projects-api.slug_to_uid", []byte(slug))
+	resp, err := requestMessage(requestCtx, "lfx.projects-api.slug_to_uid", []byte(slug))
 	if err != nil {
 		return "", fmt.Errorf("failed to request project UID for slug %s: %w", slug, err)
 	}
@@ -46,7 +108,7 @@ func getProjectSlugByUID(ctx context.Context, projectUID string) (string, error)
 	logger.With("project_uid", projectUID).DebugContext(ctx, "requesting project slug via NATS")
 
 	// Make a NATS request to the get_slug subject.
-	resp, err := natsConn.RequestWithContext(requestCtx, "lfx.projects-api.get_slug", []byte(projectUID))
+	resp, err := requestMessage(requestCtx, "lfx.projects-api.get_slug", []byte(projectUID))
 	if err != nil {
 		return "", fmt.Errorf("failed to request project slug for UID %s: %w", projectUID, err)
 	}
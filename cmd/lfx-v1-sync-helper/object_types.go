@@ -0,0 +1,98 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Handled object type documentation. /admin/object-types lists the v1 key
+// prefixes handled by the service, generated from the handler registry
+// (kvTableHandlers), so it stays in step with the handlers: the downstream
+// subjects and v1-mappings keys of each prefix, its parent mapping
+// dependencies, and whether its deletes, canary handler, and dedicated
+// consumer are currently enabled.
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+// objectTypesResponse is the /admin/object-types response.
+type objectTypesResponse struct {
+	// HardDeletesHeld is whether hard deletes of every object type are held
+	// in mass purge safe mode.
+	HardDeletesHeld bool             `json:"hard_deletes_held"`
+	ObjectTypes     []objectTypeInfo `json:"object_types"`
+}
+
+// objectTypeInfo documents the handling of a v1 key prefix.
+type objectTypeInfo struct {
+	Prefix         string                 `json:"prefix"`
+	Subjects       []string               `json:"subjects"`
+	Mappings       []string               `json:"mappings"`
+	Dependencies   []objectTypeDependency `json:"dependencies"`
+	SchemaVersions []string               `json:"schema_versions,omitempty"`
+	DeletesSynced  bool                   `json:"deletes_synced"`
+	CanaryEnabled  bool                   `json:"canary_enabled"`
+	// Consumer is the durable KV consumer processing the prefix.
+	Consumer string `json:"consumer"`
+}
+
+// objectTypeDependency is a parent mapping required by the records of a
+// prefix.
+type objectTypeDependency struct {
+	Parent     string `json:"parent"`
+	MappingKey string `json:"mapping_key"`
+	Field      string `json:"field"`
+}
+
+// objectTypes returns the documentation of the registered prefixes, sorted
+// by prefix.
+func objectTypes() []objectTypeInfo {
+	prefixes := make([]string, 0, len(kvTableHandlers))
+	for prefix := range kvTableHandlers {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+
+	infos := make([]objectTypeInfo, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		table := kvTableHandlers[prefix]
+		info := objectTypeInfo{
+			Prefix:        prefix,
+			Subjects:      append([]string{}, table.subjects...),
+			Mappings:      append([]string{}, table.mappings...),
+			Dependencies:  []objectTypeDependency{},
+			DeletesSynced: table.delete != nil,
+			CanaryEnabled: table.canary != nil && cfg.CanaryPercent > 0,
+			Consumer:      kvConsumerName,
+		}
+		for _, dependency := range table.requires {
+			info.Dependencies = append(info.Dependencies, objectTypeDependency{
+				Parent:     dependency.parent.name,
+				MappingKey: dependency.parent.keyFmt,
+				Field:      dependency.field,
+			})
+		}
+		for _, version := range table.versions {
+			info.SchemaVersions = append(info.SchemaVersions, version.name)
+		}
+		if hasKVPrefixConsumer(prefix) {
+			info.Consumer = kvPrefixConsumerName(prefix)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// objectTypesHandler serves the documentation of the handled object types.
+func objectTypesHandler(w http.ResponseWriter, r *http.Request) {
+	response := objectTypesResponse{
+		HardDeletesHeld: massPurgeActive.Load(),
+		ObjectTypes:     objectTypes(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.With(errKey, err).ErrorContext(r.Context(), "failed to encode object types response")
+	}
+}
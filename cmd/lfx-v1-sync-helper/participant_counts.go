@@ -0,0 +1,283 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Past meeting participant counts. The v2 past meeting list views show the
+// number of invitees and attendees, so the invitee and attendee record IDs of
+// each past meeting are kept in per-past-meeting indexes in the v1-mappings
+// bucket, maintained by the participant update and delete handlers, and their
+// sizes are sent as invitee_count and attendee_count with every past meeting
+// indexer message.
+//
+// Participant changes do not re-index their past meeting right away, since a
+// meeting's participants usually sync in bursts: changed past meetings are
+// re-indexed every participantCountFlushInterval, or as soon as one has
+// participantCountFlushThreshold pending changes. Pending re-indexes are also
+// recorded in the v1-mappings bucket, under participantCountPendingKeyPrefix,
+// until they succeed, and are resumed at startup, so a restart does not leave
+// stale counts. Participant index updates which fail are retried by their
+// handlers.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Past meeting participant kinds, as used in the index keys.
+const (
+	pastMeetingInvitees  = "invitees"
+	pastMeetingAttendees = "attendees"
+)
+
+const (
+	// participantCountFlushInterval is how often past meetings with changed
+	// participant counts are re-indexed.
+	participantCountFlushInterval = 30 * time.Second
+
+	// participantCountFlushThreshold is the number of pending participant
+	// changes of a past meeting which triggers an early re-index.
+	participantCountFlushThreshold = 100
+
+	// participantCountPendingKeyPrefix prefixes the mappings KV keys of the
+	// pending participant count re-indexes, followed by the past meeting ID.
+	participantCountPendingKeyPrefix = "v1_participant_count_pending."
+)
+
+var participantCountReindexes = newCounterVec(
+	"v1_sync_helper_participant_count_reindexes_total",
+	"Number of past meeting re-indexes for participant count changes, by result (success, skipped, or error).",
+	"result",
+)
+
+var (
+	// participantCountChangesMu guards participantCountChanges.
+	participantCountChangesMu sync.Mutex
+	// participantCountChanges holds the number of pending participant
+	// changes by past meeting ID.
+	participantCountChanges = map[string]int{}
+	// participantCountFlush is signalled when a past meeting reaches the
+	// flush threshold.
+	participantCountFlush = make(chan struct{}, 1)
+)
+
+// pastMeetingParticipantIndexKey returns the v1-mappings key of the list of
+// invitee or attendee record IDs of a past meeting.
+func pastMeetingParticipantIndexKey(kind, meetingAndOccurrenceID string) string {
+	return fmt.Sprintf("v1-past-meeting.%s.%s", kind, meetingAndOccurrenceID)
+}
+
+// updatePastMeetingParticipantIndex adds or removes an invitee or attendee
+// from the participant index of its past meeting, and schedules a re-index of
+// the past meeting when its count changed. The handler retries on error.
+func updatePastMeetingParticipantIndex(ctx context.Context, kind, meetingAndOccurrenceID, participantID string, isDeleted bool) error {
+	changed, err := updateKeyIndex(ctx, pastMeetingParticipantIndexKey(kind, meetingAndOccurrenceID), participantID, isDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to update past meeting %s index: %w", kind, err)
+	}
+	if changed {
+		return markParticipantCountChanged(ctx, meetingAndOccurrenceID)
+	}
+	return nil
+}
+
+// pastMeetingParticipantCount returns the number of invitees or attendees of
+// a past meeting.
+func pastMeetingParticipantCount(ctx context.Context, kind, meetingAndOccurrenceID string) (int, error) {
+	entry, err := mappingsKV.Get(ctx, pastMeetingParticipantIndexKey(kind, meetingAndOccurrenceID))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var participantIDs []string
+	if err := json.Unmarshal(entry.Value(), &participantIDs); err != nil {
+		return 0, err
+	}
+	return len(participantIDs), nil
+}
+
+// setPastMeetingParticipantCounts sets the invitee and attendee counts of a
+// past meeting indexer payload. Counts which cannot be read are left unset,
+// rather than reported as zero.
+func setPastMeetingParticipantCounts(ctx context.Context, pastMeeting *pastMeetingInput) {
+	pastMeeting.InviteeCount = participantCountField(ctx, pastMeetingInvitees, pastMeeting.MeetingAndOccurrenceID)
+	pastMeeting.AttendeeCount = participantCountField(ctx, pastMeetingAttendees, pastMeeting.MeetingAndOccurrenceID)
+}
+
+// participantCountField returns a participant count of a past meeting, or nil
+// if it cannot be read.
+func participantCountField(ctx context.Context, kind, meetingAndOccurrenceID string) *int {
+	count, err := pastMeetingParticipantCount(ctx, kind, meetingAndOccurrenceID)
+	if err != nil {
+		logger.With(errKey, err, "meeting_and_occurrence_id", meetingAndOccurrenceID, "kind", kind).
+			WarnContext(ctx, "failed to get past meeting participant count")
+		return nil
+	}
+	return &count
+}
+
+// markParticipantCountChanged schedules a re-index of a past meeting whose
+// participant count changed, recording it as pending on its first change
+// since the last flush.
+func markParticipantCountChanged(ctx context.Context, meetingAndOccurrenceID string) error {
+	participantCountChangesMu.Lock()
+	participantCountChanges[meetingAndOccurrenceID]++
+	first := participantCountChanges[meetingAndOccurrenceID] == 1
+	reached := participantCountChanges[meetingAndOccurrenceID] >= participantCountFlushThreshold
+	participantCountChangesMu.Unlock()
+
+	if first {
+		if _, err := mappingsKV.Put(ctx, participantCountPendingKeyPrefix+meetingAndOccurrenceID, []byte("1")); err != nil {
+			return fmt.Errorf("failed to record pending participant count re-index: %w", err)
+		}
+	}
+
+	if reached {
+		select {
+		case participantCountFlush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// loadPendingParticipantCounts schedules the re-index of the past meetings
+// whose pending participant count re-indexes were recorded, e.g. by a
+// replica which restarted before flushing them.
+func loadPendingParticipantCounts(ctx context.Context) {
+	lister, err := mappingsKV.ListKeysFiltered(ctx, participantCountPendingKeyPrefix+">")
+	if err != nil {
+		logger.With(errKey, err).WarnContext(ctx, "failed to list pending participant count re-indexes")
+		return
+	}
+	loaded := 0
+	participantCountChangesMu.Lock()
+	for key := range lister.Keys() {
+		meetingAndOccurrenceID := strings.TrimPrefix(key, participantCountPendingKeyPrefix)
+		if participantCountChanges[meetingAndOccurrenceID] == 0 {
+			participantCountChanges[meetingAndOccurrenceID] = 1
+			loaded++
+		}
+	}
+	participantCountChangesMu.Unlock()
+	if loaded > 0 {
+		logger.With("past_meetings", loaded).InfoContext(ctx, "resuming pending participant count re-indexes")
+	}
+}
+
+// watchParticipantCounts re-indexes the past meetings with changed
+// participant counts every participantCountFlushInterval, or when one
+// reaches the flush threshold, until the context is cancelled.
+func watchParticipantCounts(ctx context.Context) {
+	loadPendingParticipantCounts(ctx)
+
+	ticker := time.NewTicker(participantCountFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-participantCountFlush:
+		}
+		flushParticipantCounts(ctx)
+	}
+}
+
+// flushParticipantCounts re-indexes the past meetings with changed
+// participant counts, clearing their pending records. Past meetings which
+// fail to re-index are retried on the next flush.
+func flushParticipantCounts(ctx context.Context) {
+	participantCountChangesMu.Lock()
+	changes := participantCountChanges
+	participantCountChanges = map[string]int{}
+	participantCountChangesMu.Unlock()
+
+	for meetingAndOccurrenceID, pending := range changes {
+		if ctx.Err() != nil {
+			return
+		}
+		pendingKey := participantCountPendingKeyPrefix + meetingAndOccurrenceID
+		var pendingRevision uint64
+		if entry, err := mappingsKV.Get(ctx, pendingKey); err == nil {
+			pendingRevision = entry.Revision()
+		}
+		if err := reindexPastMeetingCounts(ctx, meetingAndOccurrenceID); err != nil {
+			participantCountReindexes.inc("error")
+			logger.With(errKey, err, "meeting_and_occurrence_id", meetingAndOccurrenceID).
+				WarnContext(ctx, "failed to re-index past meeting participant counts")
+			participantCountChangesMu.Lock()
+			participantCountChanges[meetingAndOccurrenceID] += pending
+			participantCountChangesMu.Unlock()
+			continue
+		}
+		// Changes recorded since the re-index started keep their record.
+		if pendingRevision > 0 {
+			if err := mappingsKV.Delete(ctx, pendingKey, jetstream.LastRevision(pendingRevision)); err != nil && !errors.Is(err, jetstream.ErrKeyNotFound) {
+				logger.With(errKey, err, "meeting_and_occurrence_id", meetingAndOccurrenceID).
+					DebugContext(ctx, "kept pending participant count re-index record")
+			}
+		}
+	}
+}
+
+// reindexPastMeetingCounts sends a past meeting indexer update with its
+// current participant counts.
+func reindexPastMeetingCounts(ctx context.Context, meetingAndOccurrenceID string) error {
+	// Only re-index past meetings which have been synced and not deleted.
+	mappingKey := fmt.Sprintf("v1_past_meetings.%s", meetingAndOccurrenceID)
+	if entry, err := mappingsKV.Get(ctx, mappingKey); err != nil || isTombstonedMapping(entry.Value()) {
+		participantCountReindexes.inc("skipped")
+		return nil
+	}
+
+	pastMeetingData, exists, err := getV1ObjectData(ctx, fmt.Sprintf("itx-zoom-past-meetings.%s", meetingAndOccurrenceID))
+	if err != nil {
+		return fmt.Errorf("failed to get past meeting data: %w", err)
+	}
+	if !exists || !isRecordInScope(ctx, pastMeetingData) {
+		participantCountReindexes.inc("skipped")
+		return nil
+	}
+
+	pastMeeting, err := convertMapToInputPastMeeting(ctx, pastMeetingData)
+	if err != nil {
+		return fmt.Errorf("failed to convert past meeting data: %w", err)
+	}
+
+	// Use the committees of the mapping index, as the mapping handlers do.
+	indexKey := fmt.Sprintf("v1-mappings.past-meeting-mappings.%s", meetingAndOccurrenceID)
+	if indexEntry, err := mappingsKV.Get(ctx, indexKey); err == nil && !isTombstonedMapping(indexEntry.Value()) {
+		committeeMappings := make(map[string]mappingCommittee)
+		if err := json.Unmarshal(indexEntry.Value(), &committeeMappings); err == nil {
+			pastMeeting.Committees = []Committee{}
+			for _, committee := range committeeMappings {
+				pastMeeting.Committees = append(pastMeeting.Committees, Committee{
+					UID:                   committee.CommitteeID,
+					AllowedVotingStatuses: normalizeCommitteeFilters(committee.CommitteeFilters),
+				})
+			}
+		}
+	}
+
+	setPastMeetingParticipantCounts(ctx, pastMeeting)
+	pastMeeting.CanonicalMeetingType = classifyMeetingType(ctx, pastMeeting.MeetingType, pastMeeting.Title, meetingCommitteeIDs(pastMeeting.Committees))
+	if err := sendIndexerMessage(ctx, IndexV1PastMeetingSubject, MessageActionUpdated, pastMeeting, getPastMeetingTags(pastMeeting)); err != nil {
+		return err
+	}
+
+	participantCountReindexes.inc("success")
+	logger.With("meeting_and_occurrence_id", meetingAndOccurrenceID).DebugContext(ctx, "re-indexed past meeting participant counts")
+	return nil
+}
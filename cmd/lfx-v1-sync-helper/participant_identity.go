@@ -0,0 +1,154 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Past meeting participant identity continuity. Downstream, the invitee and
+// attendee records of a participant are combined by their identity (their
+// username, or their email without one), so an invitee or attendee record
+// whose identity changes (e.g. a user correcting a typo in their email, or a
+// record later linked to an LF user) left the participant of the superseded
+// identity behind, next to the new one. The identity key of each participant
+// record (its lf_user_id, falling back to its email) is kept in the mappings
+// bucket, and when it changes, a delete is sent for the superseded identity
+// before the record is synced again as a new participant: an indexer delete
+// of the participant, and, when the username changed, the removal of the
+// access of the superseded username (unless another record of the
+// participant still grants it).
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Participant record types, as used in the v1_participant_by_meeting_user
+// cross-reference and participant identity mapping keys.
+const (
+	participantTypeAttendee = "attendee"
+	participantTypeInvitee  = "invitee"
+)
+
+// participantIdentityKeyFmt is the v1-mappings key of the identity of a past
+// meeting participant record, by participant type and record ID.
+const participantIdentityKeyFmt = "v1_participant_identity.%s.%s"
+
+var participantIdentityChanges = newCounterVec(
+	"v1_sync_helper_participant_identity_changes_total",
+	"Number of past meeting participant records whose identity changed, by participant type and result (superseded or error).",
+	"participant_type", "result",
+)
+
+// participantIdentity is the identity of a past meeting participant record.
+type participantIdentity struct {
+	// Key is "lf_user_id:{id}", or "email:{email}" for participants without
+	// an LF user ID.
+	Key string `json:"key"`
+	// Username is the LF username the participant was synced with, if any.
+	Username string `json:"username,omitempty"`
+}
+
+// newParticipantIdentity returns the identity of a participant record, with
+// an empty key if it has neither an LF user ID nor an email.
+func newParticipantIdentity(lfUserID, email, username string) participantIdentity {
+	identity := participantIdentity{Username: username}
+	if lfUserID != "" {
+		identity.Key = "lf_user_id:" + lfUserID
+	} else if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		identity.Key = "email:" + email
+	}
+	return identity
+}
+
+// participantCounterpartType returns the other participant record type
+// combined with a participant type downstream.
+func participantCounterpartType(participantType string) string {
+	if participantType == participantTypeAttendee {
+		return participantTypeInvitee
+	}
+	return participantTypeAttendee
+}
+
+// supersedeParticipantIdentity compares the identity a participant record was
+// last synced with to its current identity, and when it changed, sends the
+// delete of the superseded identity. It returns whether the identity was
+// superseded, in which case the record is synced again as a new participant,
+// and whether the message should be retried.
+func supersedeParticipantIdentity(ctx context.Context, participantType, recordID, meetingAndOccurrenceID string, current participantIdentity) (superseded, retry bool) {
+	if current.Key == "" {
+		return false, false
+	}
+	log := logger.With("participant_type", participantType, "id", recordID, "meeting_and_occurrence_id", meetingAndOccurrenceID)
+
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(participantIdentityKeyFmt, participantType, recordID))
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			log.With(errKey, err).WarnContext(ctx, "failed to get participant identity")
+		}
+		return false, false
+	}
+	var previous participantIdentity
+	if isTombstonedMapping(entry.Value()) || json.Unmarshal(entry.Value(), &previous) != nil || previous.Key == "" || previous.Key == current.Key {
+		return false, false
+	}
+	log = log.With("previous_identity", previous.Key, "identity", current.Key)
+
+	if err := sendIndexerMessage(ctx, IndexV1PastMeetingParticipantSubject, MessageActionDeleted, recordID, []string{}); err != nil {
+		participantIdentityChanges.inc(participantType, "error")
+		log.With(errKey, err).ErrorContext(ctx, "failed to send delete indexer message for superseded participant identity")
+		return false, true
+	}
+
+	if previous.Username != "" && previous.Username != current.Username {
+		xrefKey := fmt.Sprintf("v1_participant_by_meeting_user.%s.%s.%s", participantType, meetingAndOccurrenceID, previous.Username)
+		if err := tombstoneMapping(ctx, xrefKey); err != nil {
+			log.With(errKey, err).WarnContext(ctx, "failed to tombstone superseded participant cross-reference mapping")
+		}
+
+		counterpartKey := fmt.Sprintf("v1_participant_by_meeting_user.%s.%s.%s", participantCounterpartType(participantType), meetingAndOccurrenceID, previous.Username)
+		if counterpart, err := mappingsKV.Get(ctx, counterpartKey); err == nil && !isTombstonedMapping(counterpart.Value()) {
+			log.DebugContext(ctx, "superseded participant username still has a counterpart record, keeping its access")
+		} else {
+			accessMsg := PastMeetingParticipantAccessMessage{
+				MeetingAndOccurrenceID: meetingAndOccurrenceID,
+				Username:               mapUsernameToAuthSub(previous.Username),
+				IsInvited:              participantType == participantTypeInvitee,
+				IsAttended:             participantType == participantTypeAttendee,
+			}
+			accessMsgBytes, err := json.Marshal(accessMsg)
+			if err != nil {
+				log.With(errKey, err).ErrorContext(ctx, "failed to marshal superseded participant access message")
+				return false, false
+			}
+			if err := sendAccessMessage(ctx, V1PastMeetingParticipantRemoveSubject, accessMsgBytes); err != nil {
+				participantIdentityChanges.inc(participantType, "error")
+				log.With(errKey, err).ErrorContext(ctx, "failed to send superseded participant access removal")
+				return false, true
+			}
+		}
+	}
+
+	participantIdentityChanges.inc(participantType, "superseded")
+	log.InfoContext(ctx, "participant identity changed, deleted the superseded participant")
+	return true, false
+}
+
+// storeParticipantIdentity records the identity a participant record was
+// synced with.
+func storeParticipantIdentity(ctx context.Context, participantType, recordID string, identity participantIdentity) {
+	if identity.Key == "" {
+		return
+	}
+	value, err := json.Marshal(identity)
+	if err != nil {
+		return
+	}
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(participantIdentityKeyFmt, participantType, recordID), value); err != nil {
+		logger.With(errKey, err, "participant_type", participantType, "id", recordID).WarnContext(ctx, "failed to store participant identity")
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Past meeting participant state. The invitee and attendee records of a
+// participant arrive in any order, and each used to be synced with only its
+// own flags: an attendee synced after its invitee reset is_invited, and the
+// invitee synced first was never told the participant attended. The
+// participants of each record are merged in a participant state entry of the
+// v1-mappings bucket, keyed by past meeting and username (or a hash of the
+// email, without one), holding the last converted participant of each record
+// type. Under a per-participant lock, each update records its participant in
+// the state and is synced with the union of both records: invited if either
+// record says so, attended, host, and the attendee sessions. When the merge
+// changes the view of the other record, its participant is re-sent to the
+// indexer with the union too. Records synced before the state existed fall
+// back to the v1_participant_by_meeting_user cross-references.
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// participantStateKeyFmt is the v1-mappings key of the state of a past
+	// meeting participant, by past meeting and participant key.
+	participantStateKeyFmt = "v1_participant_state.%s.%s"
+
+	// participantStateLockKeyPrefix prefixes the lock keys of the participant
+	// states.
+	participantStateLockKeyPrefix = "v1_participant_state_lock."
+)
+
+var participantStateMerges = newCounterVec(
+	"v1_sync_helper_participant_state_merges_total",
+	"Number of past meeting participant records merged with the other record of their participant, by participant type and result (merged, resent for the other record re-sent with the union, or error).",
+	"participant_type", "result",
+)
+
+// participantState is the last converted participant of each record type of
+// a past meeting participant.
+type participantState struct {
+	Invitee  *V2PastMeetingParticipant `json:"invitee,omitempty"`
+	Attendee *V2PastMeetingParticipant `json:"attendee,omitempty"`
+}
+
+// participantStateKey returns the key of the state of a past meeting
+// participant, or an empty string if it has neither a username nor an email.
+// Emails are hashed, as they are not valid KV key tokens.
+func participantStateKey(meetingAndOccurrenceID, username, email string) string {
+	switch {
+	case meetingAndOccurrenceID == "":
+		return ""
+	case username != "":
+		return fmt.Sprintf(participantStateKeyFmt, meetingAndOccurrenceID, username)
+	case email != "":
+		sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+		return fmt.Sprintf(participantStateKeyFmt, meetingAndOccurrenceID, "email-"+hex.EncodeToString(sum[:16]))
+	}
+	return ""
+}
+
+// participantStateLockKey returns the lock key of a participant state.
+func participantStateLockKey(key string) string {
+	return participantStateLockKeyPrefix + strings.TrimPrefix(key, "v1_participant_state.")
+}
+
+// side returns the participant of a record type in the state.
+func (s *participantState) side(participantType string) **V2PastMeetingParticipant {
+	if participantType == participantTypeInvitee {
+		return &s.Invitee
+	}
+	return &s.Attendee
+}
+
+// otherParticipantType returns the participant type of the other record of
+// a participant.
+func otherParticipantType(participantType string) string {
+	if participantType == participantTypeInvitee {
+		return participantTypeAttendee
+	}
+	return participantTypeInvitee
+}
+
+// merged returns a copy of the participant with the union of the records in
+// the state.
+func (s *participantState) merged(participant *V2PastMeetingParticipant) *V2PastMeetingParticipant {
+	merged := *participant
+	if s.Invitee != nil {
+		merged.IsInvited = true
+		merged.Host = merged.Host || s.Invitee.Host
+	}
+	if s.Attendee != nil {
+		merged.IsAttended = true
+		merged.IsInvited = merged.IsInvited || s.Attendee.IsInvited
+		merged.Host = merged.Host || s.Attendee.Host
+		if len(merged.Sessions) == 0 {
+			merged.Sessions = s.Attendee.Sessions
+		}
+	}
+	return &merged
+}
+
+// participantMerge is the merge of a participant record into its participant
+// state, holding the state lock until released.
+type participantMerge struct {
+	key             string
+	lockKey         string
+	participantType string
+	state           participantState
+	// resend is the participant of the other record, when the merge changed
+	// its view.
+	resend *V2PastMeetingParticipant
+}
+
+// beginParticipantMerge locks the state of a participant, records the
+// converted participant of a record in it, and updates the participant with
+// the union of both records, in place. It returns a nil merge for
+// participants without a state key, and whether the message should be
+// retried as the state could not be locked or read. The merge must be
+// released, and committed once the participant is synced.
+func beginParticipantMerge(ctx context.Context, participantType string, participant *V2PastMeetingParticipant, username string) (*participantMerge, bool) {
+	key := participantStateKey(participant.MeetingAndOccurrenceID, username, participant.Email)
+	if key == "" {
+		return nil, false
+	}
+	m := &participantMerge{
+		key:             key,
+		lockKey:         participantStateLockKey(key),
+		participantType: participantType,
+	}
+	if acquired, _ := distributedSync.acquire(ctx, m.lockKey); !acquired {
+		logger.With("key", key).WarnContext(ctx, "failed to acquire participant state lock, will retry")
+		return nil, true
+	}
+
+	state, err := loadParticipantState(ctx, key)
+	if err != nil {
+		logger.With(errKey, err, "key", key).ErrorContext(ctx, "failed to read participant state")
+		participantStateMerges.inc(participantType, "error")
+		m.release(ctx)
+		return nil, true
+	}
+
+	// The view of the other record before and after this one is recorded.
+	other := *state.side(otherParticipantType(participantType))
+	var before *V2PastMeetingParticipant
+	if other != nil {
+		before = state.merged(other)
+	}
+	own := *participant
+	*state.side(participantType) = &own
+	*participant = *state.merged(participant)
+	if other != nil {
+		after := state.merged(other)
+		if !sameParticipant(before, after) {
+			m.resend = after
+		}
+	}
+	m.state = state
+	return m, false
+}
+
+// commit re-sends the participant of the other record when its view changed,
+// then stores the state. It returns whether the message should be retried.
+func (m *participantMerge) commit(ctx context.Context) bool {
+	if m == nil {
+		return false
+	}
+	result := "merged"
+	if m.resend != nil {
+		tags := getPastMeetingParticipantTags(m.resend)
+		if err := sendIndexerMessage(ctx, IndexV1PastMeetingParticipantSubject, MessageActionUpdated, m.resend, tags); err != nil {
+			logger.With(errKey, err, "key", m.key).ErrorContext(ctx, "failed to re-send merged participant of the other record")
+			participantStateMerges.inc(m.participantType, "error")
+			return true
+		}
+		result = "resent"
+	}
+	if err := storeParticipantState(ctx, m.key, m.state); err != nil {
+		logger.With(errKey, err, "key", m.key).ErrorContext(ctx, "failed to store participant state")
+		participantStateMerges.inc(m.participantType, "error")
+		return true
+	}
+	participantStateMerges.inc(m.participantType, result)
+	return false
+}
+
+// release releases the state lock.
+func (m *participantMerge) release(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	if err := distributedSync.release(ctx, m.lockKey); err != nil {
+		logger.With(errKey, err, "key", m.key).WarnContext(ctx, "failed to release participant state lock")
+	}
+}
+
+// removeParticipantState removes a deleted record from the state of its
+// participant, deleting the state once neither record remains.
+func removeParticipantState(ctx context.Context, participantType, meetingAndOccurrenceID, username, email string) {
+	key := participantStateKey(meetingAndOccurrenceID, username, email)
+	if key == "" {
+		return
+	}
+	lockKey := participantStateLockKey(key)
+	if acquired, _ := distributedSync.acquire(ctx, lockKey); !acquired {
+		logger.With("key", key).WarnContext(ctx, "failed to acquire participant state lock, participant state not updated")
+		return
+	}
+	defer func() { _ = distributedSync.release(ctx, lockKey) }()
+
+	state, err := loadParticipantState(ctx, key)
+	if err != nil {
+		logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to read participant state")
+		return
+	}
+	*state.side(participantType) = nil
+	if err := storeParticipantState(ctx, key, state); err != nil {
+		logger.With(errKey, err, "key", key).WarnContext(ctx, "failed to store participant state")
+	}
+}
+
+// loadParticipantState reads the state of a participant, empty if it has
+// none.
+func loadParticipantState(ctx context.Context, key string) (participantState, error) {
+	var state participantState
+	entry, err := mappingsKV.Get(ctx, key)
+	switch {
+	case errors.Is(err, jetstream.ErrKeyNotFound):
+		return state, nil
+	case err != nil:
+		return state, err
+	case isTombstonedMapping(entry.Value()):
+		return state, nil
+	}
+	if err := json.Unmarshal(entry.Value(), &state); err != nil {
+		return state, fmt.Errorf("failed to unmarshal participant state: %w", err)
+	}
+	return state, nil
+}
+
+// storeParticipantState writes the state of a participant, tombstoning it
+// once neither record remains.
+func storeParticipantState(ctx context.Context, key string, state participantState) error {
+	if state.Invitee == nil && state.Attendee == nil {
+		return tombstoneMapping(ctx, key)
+	}
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = mappingsKV.Put(ctx, key, value)
+	return err
+}
+
+// sameParticipant reports whether two participants have the same encoding.
+func sameParticipant(a, b *V2PastMeetingParticipant) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
@@ -0,0 +1,222 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Startup self-test. Verifies that the NATS buckets and streams the service
+// depends on exist with compatible configurations, and that the v1 and v2
+// clients can authenticate, so misconfiguration fails fast with a single
+// consolidated report instead of degrading silently at runtime.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const preflightTimeout = 30 * time.Second
+
+// preflightStream describes a JetStream stream consumed by this service.
+type preflightStream struct {
+	name   string
+	filter string
+}
+
+// preflightReport collects the outcome of all preflight checks.
+type preflightReport struct {
+	failures []string
+	warnings []string
+}
+
+// failf records a check failure which prevents the service from starting.
+func (r *preflightReport) failf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// warnf records a non-fatal check result.
+func (r *preflightReport) warnf(format string, args ...any) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+// err returns a consolidated error for all failures, or nil if none.
+func (r *preflightReport) err() error {
+	if len(r.failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d preflight check(s) failed: %s", len(r.failures), strings.Join(r.failures, "; "))
+}
+
+// downstreamSubjects returns the subjects this service publishes to. These
+// are checked for stream coverage; subjects served by core NATS subscribers
+// (rather than streams) are reported as warnings only.
+func downstreamSubjects() []string {
+	return []string{
+		IndexV1MeetingSubject,
+		UpdateAccessV1MeetingSubject,
+		V1MeetingSeriesSplitSubject,
+		V1MeetingOccurrenceCancelledSubject,
+		IndexV1MeetingRegistrantSubject,
+		V1MeetingRegistrantPutSubject,
+		V1MeetingRegistrantRemoveSubject,
+		V1MeetingRegistrantHostPromoteSubject,
+		V1MeetingRegistrantHostDemoteSubject,
+		IndexV1MeetingInviteResponseSubject,
+		V1MeetingRSVPChangedSubject,
+		IndexV1MeetingAttachmentSubject,
+		DeleteAllAccessV1MeetingSubject,
+		DeleteAllAccessV1PastMeetingSubject,
+		IndexV1PastMeetingSubject,
+		V1PastMeetingUpdateAccessSubject,
+		IndexV1PastMeetingParticipantSubject,
+		V1PastMeetingParticipantPutSubject,
+		V1PastMeetingParticipantRemoveSubject,
+		IndexV1PastMeetingAttachmentSubject,
+		IndexV1PastMeetingRecordingSubject,
+		V1PastMeetingRecordingUpdateAccessSubject,
+		IndexV1PastMeetingTranscriptSubject,
+		IndexV1PastMeetingTranscriptContentSubject,
+		V1PastMeetingTranscriptUpdateAccessSubject,
+		IndexV1PastMeetingSummarySubject,
+		V1PastMeetingSummaryUpdateAccessSubject,
+		IndexSurveySubject,
+		IndexSurveyResponseSubject,
+		IndexVoteSubject,
+		IndexVoteResponseSubject,
+		UpdateAccessSubject,
+	}
+}
+
+// runPreflightChecks runs all startup checks and logs a consolidated report.
+// It returns an error if any check failed.
+func runPreflightChecks(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	report := &preflightReport{}
+
+	checkPreflightBuckets(ctx, report)
+	checkPreflightStreams(ctx, report)
+	checkPreflightSubjects(ctx, report)
+	checkPreflightClients(ctx, report)
+
+	for _, warning := range report.warnings {
+		logger.With("check", warning).WarnContext(ctx, "preflight warning")
+	}
+	if err := report.err(); err != nil {
+		logger.With("failures", report.failures, "warnings", len(report.warnings)).ErrorContext(ctx, "preflight checks failed")
+		return err
+	}
+
+	logger.With("warnings", len(report.warnings)).InfoContext(ctx, "preflight checks passed")
+	return nil
+}
+
+// checkPreflightBuckets verifies the KV and object store buckets used by this
+// service exist.
+func checkPreflightBuckets(ctx context.Context, report *preflightReport) {
+	buckets := []string{"v1-objects", "v1-mappings"}
+	if cfg.MappingsMirrorBucket != "" {
+		buckets = append(buckets, cfg.MappingsMirrorBucket)
+	}
+	for _, bucket := range buckets {
+		kv, err := jsContext.KeyValue(ctx, bucket)
+		if err != nil {
+			report.failf("KV bucket %s is not accessible: %v", bucket, err)
+			continue
+		}
+		status, err := kv.Status(ctx)
+		if err != nil {
+			report.failf("KV bucket %s status unavailable: %v", bucket, err)
+			continue
+		}
+		if status.History() < 1 {
+			report.failf("KV bucket %s has invalid history %d", bucket, status.History())
+		}
+	}
+
+	// Oversize indexer payloads are stored in an object store bucket.
+	if cfg.IndexerOversizePolicy == indexerOversizeObjectStore {
+		if _, err := jsContext.ObjectStore(ctx, cfg.IndexerPayloadBucket); err != nil {
+			report.failf("object store bucket %s is not accessible: %v", cfg.IndexerPayloadBucket, err)
+		}
+	}
+}
+
+// checkPreflightStreams verifies the streams this service consumes from exist
+// and capture the subjects its consumers filter on.
+func checkPreflightStreams(ctx context.Context, report *preflightReport) {
+	streams := []preflightStream{
+		{name: "KV_v1-objects", filter: "$KV.v1-objects.>"},
+		{name: "wal_listener", filter: "wal_listener.*"},
+	}
+	if cfg.DynamoDBIngestEnabled {
+		streams = append(streams, preflightStream{name: cfg.DynamoDBStreamName, filter: cfg.DynamoDBStreamName + ".>"})
+	}
+
+	for _, s := range streams {
+		stream, err := jsContext.Stream(ctx, s.name)
+		if err != nil {
+			report.failf("stream %s is not accessible: %v", s.name, err)
+			continue
+		}
+		if stream.CachedInfo().Config.Retention == jetstream.WorkQueuePolicy {
+			report.failf("stream %s uses work-queue retention, which is incompatible with shared durable consumers", s.name)
+		}
+
+		name, err := jsContext.StreamNameBySubject(ctx, s.filter)
+		switch {
+		case err != nil:
+			report.failf("consumer filter %s is not covered by stream %s: %v", s.filter, s.name, err)
+		case name != s.name:
+			report.failf("consumer filter %s is captured by stream %s instead of %s", s.filter, name, s.name)
+		}
+	}
+}
+
+// checkPreflightSubjects checks the downstream subjects for stream coverage
+// (see subject_coverage.go). The indexer and access control services may
+// consume these over core NATS, so a subject without a stream is only
+// reported as a warning, unless publishes go through JetStream (see
+// publish_ack.go).
+func checkPreflightSubjects(ctx context.Context, report *preflightReport) {
+	uncovered, errs := checkSubjectCoverage(ctx)
+	for _, subject := range uncovered {
+		if publishAckEnabled() {
+			report.failf("subject %s is not covered by any stream, as PUBLISH_ACK_TIMEOUT requires", subject)
+		} else {
+			report.warnf("subject %s is not covered by any stream", subject)
+		}
+	}
+	for _, err := range errs {
+		report.failf("%v", err)
+	}
+}
+
+// checkPreflightClients verifies that JWTs can be signed for the v2 services,
+// the v2 services are reachable, and the v1 Auth0 client can obtain a token.
+func checkPreflightClients(ctx context.Context, report *preflightReport) {
+	for _, audience := range []string{projectServiceAudience, committeeServiceAudience, indexerServiceAudience} {
+		if _, err := generateJWTToken(ctx, audience, ""); err != nil {
+			report.failf("unable to sign JWT for %s: %v", audience, err)
+		}
+	}
+
+	if _, err := projectClient.Readyz(ctx); err != nil {
+		report.failf("project service is not ready: %v", err)
+	}
+	if committeeClient != nil {
+		if _, err := committeeClient.Readyz(ctx); err != nil {
+			report.failf("committee service is not ready: %v", err)
+		}
+	}
+
+	if v1TokenSource != nil {
+		if _, err := v1TokenSource.Token(); err != nil {
+			report.failf("unable to obtain v1 Auth0 token: %v", err)
+		}
+	}
+}
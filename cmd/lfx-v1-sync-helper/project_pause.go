@@ -0,0 +1,173 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Per-project sync pause. When the data of a project is known to be bad (e.g.
+// a corrupted import), listing it in PAUSED_PROJECTS (or paused_projects in
+// the config file, reloaded at runtime) pauses its sync without skipping its
+// records for good: the records of a paused project (including soft deletes,
+// and the records of its meetings and past meetings) are held under
+// pausedRecordKeyPrefix in the v1-mappings bucket instead of being synced. A
+// periodic sweep re-runs the held records of the projects no longer paused
+// through the dispatcher, from their latest v1-objects value. Hard deletes
+// carry no data to resolve their project from, so they are not held.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// pausedRecordKeyPrefix prefixes the v1-mappings keys of the records held for
+// a paused project, followed by the project SFID and the v1-objects key of
+// the record.
+const pausedRecordKeyPrefix = "v1_paused_records."
+
+var pausedRecords = newCounterVec(
+	"v1_sync_helper_paused_records_total",
+	"Number of records held because their project is paused, by object type and result (held, released, retried, or dropped).",
+	"object_type", "result",
+)
+
+// pausedRecord is the v1-mappings value of a record held for a paused
+// project.
+type pausedRecord struct {
+	Key         string    `json:"key"`
+	ProjectSFID string    `json:"project_sfid"`
+	PausedAt    time.Time `json:"paused_at"`
+}
+
+// isProjectPaused checks the given project SFID (and its mapped v2 UID, if
+// any) against the paused projects.
+func isProjectPaused(ctx context.Context, projectSFID string) bool {
+	paused := contextSettings(ctx).PausedProjects
+	if len(paused) == 0 || projectSFID == "" {
+		return false
+	}
+	if slices.Contains(paused, projectSFID) {
+		return true
+	}
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf("project.sfid.%s", projectSFID))
+	if err != nil || isTombstonedMapping(entry.Value()) {
+		return false
+	}
+	return slices.Contains(paused, string(entry.Value()))
+}
+
+// holdForPausedProject holds a record whose project is paused until it is
+// unpaused. It returns whether the record was held (or failed to be) and must
+// not be handled, and whether the message should be retried.
+func holdForPausedProject(ctx context.Context, key string, v1Data map[string]any) (held, retry bool) {
+	if len(contextSettings(ctx).PausedProjects) == 0 {
+		return false, false
+	}
+	projectSFID := recordProjectSFID(ctx, v1Data)
+	if !isProjectPaused(ctx, projectSFID) {
+		return false, false
+	}
+
+	log := logger.With("key", key, "project_sfid", projectSFID)
+	value, err := json.Marshal(pausedRecord{Key: key, ProjectSFID: projectSFID, PausedAt: bootstrap.Now().UTC()})
+	if err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to marshal paused record")
+		return true, false
+	}
+	if _, err := mappingsKV.Put(ctx, pausedRecordKeyPrefix+projectSFID+"."+key, value); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to hold record of paused project")
+		return true, true
+	}
+	pausedRecords.inc(kvObjectType(key), "held")
+	log.InfoContext(ctx, "project is paused, held record until it is unpaused")
+	return true, false
+}
+
+// watchPausedRecords releases the records held for projects which are no
+// longer paused every parkedRecordSweepInterval, until the context is
+// cancelled.
+func watchPausedRecords(ctx context.Context) {
+	ticker := time.NewTicker(parkedRecordSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		sweepPausedRecords(ctx)
+	}
+}
+
+// sweepPausedRecords releases the records held for projects which are no
+// longer paused.
+func sweepPausedRecords(ctx context.Context) {
+	lister, err := mappingsKV.ListKeysFiltered(ctx, pausedRecordKeyPrefix+">")
+	if err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to list paused records")
+		return
+	}
+	// Only check each project once per sweep.
+	stillPaused := map[string]bool{}
+	for pausedKey := range lister.Keys() {
+		if ctx.Err() != nil {
+			_ = lister.Stop()
+			return
+		}
+		projectSFID, _, _ := strings.Cut(strings.TrimPrefix(pausedKey, pausedRecordKeyPrefix), ".")
+		paused, checked := stillPaused[projectSFID]
+		if !checked {
+			paused = isProjectPaused(ctx, projectSFID)
+			stillPaused[projectSFID] = paused
+		}
+		if !paused {
+			releasePausedRecord(ctx, pausedKey)
+		}
+	}
+}
+
+// releasePausedRecord claims a held record, by deleting it at its current
+// revision so no other replica releases it too, and re-runs it through the
+// dispatcher. Records to retry are held again, for the next sweep.
+func releasePausedRecord(ctx context.Context, pausedKey string) {
+	log := logger.With("paused_key", pausedKey)
+	pausedEntry, err := mappingsKV.Get(ctx, pausedKey)
+	if err != nil {
+		return
+	}
+	var paused pausedRecord
+	if err := json.Unmarshal(pausedEntry.Value(), &paused); err != nil {
+		log.With(errKey, err).ErrorContext(ctx, "failed to unmarshal paused record")
+		return
+	}
+	if err := mappingsKV.Delete(ctx, pausedKey, jetstream.LastRevision(pausedEntry.Revision())); err != nil {
+		// Claimed by another replica.
+		return
+	}
+
+	log = log.With("key", paused.Key, "project_sfid", paused.ProjectSFID)
+	objectType := kvObjectType(paused.Key)
+	entry, err := v1KV.Get(ctx, paused.Key)
+	if err != nil {
+		pausedRecords.inc(objectType, "dropped")
+		log.With(errKey, err).InfoContext(ctx, "dropped paused record no longer in v1-objects")
+		return
+	}
+
+	if kvHandler(bootstrap.MessageContext(ctx, nil), entry) {
+		pausedRecords.inc(objectType, "retried")
+		if _, err := mappingsKV.Put(ctx, pausedKey, pausedEntry.Value()); err != nil {
+			log.With(errKey, err).ErrorContext(ctx, "failed to hold record again for retry")
+		}
+		return
+	}
+	pausedRecords.inc(objectType, "released")
+	log.InfoContext(ctx, "released record of unpaused project")
+}
@@ -0,0 +1,106 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Per-environment project scoping. When PROJECT_SCOPE_ALLOW is set, only
+// records belonging to the listed projects are synced; projects listed in
+// PROJECT_SCOPE_DENY are never synced. Entries may be v1 project SFIDs or v2
+// project UIDs. This is independent from (and applied in addition to) the
+// hard-coded project allowlists used when creating new projects. Both lists
+// may be reloaded at runtime from the config file.
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// projectScopeFields are the v1 record fields that reference the owning
+// project's SFID, in order of precedence.
+var projectScopeFields = []string{
+	"proj_id",         // Meetings and past meetings.
+	"project_id",      // Meeting mappings, surveys, and votes.
+	"project_name__c", // Committees and committee members.
+}
+
+// scopedOutRecords counts records skipped because of project scoping.
+var scopedOutRecords = newCounterVec(
+	"v1_sync_helper_project_scoped_out_total",
+	"Number of v1 records skipped because their project is out of the configured scope.",
+	"reason",
+)
+
+// isProjectScopeEnabled returns true when an allowlist or denylist is configured.
+func isProjectScopeEnabled(ctx context.Context) bool {
+	scope := contextSettings(ctx)
+	return len(scope.ProjectScopeAllow) > 0 || len(scope.ProjectScopeDeny) > 0
+}
+
+// extractProjectSFID returns the SFID of the project owning the v1 record, or
+// an empty string if the record does not reference a project directly.
+func extractProjectSFID(v1Data map[string]any) string {
+	// Project records identify themselves by their own SFID.
+	if _, isProject := v1Data["slug__c"]; isProject {
+		sfid, _ := v1Data["sfid"].(string)
+		return strings.TrimSpace(sfid)
+	}
+	for _, field := range projectScopeFields {
+		if sfid, ok := v1Data[field].(string); ok && strings.TrimSpace(sfid) != "" {
+			return strings.TrimSpace(sfid)
+		}
+	}
+	return ""
+}
+
+// isProjectInScope checks the given project SFID (and its mapped v2 UID, if
+// any) against the configured project scope. Returns (inScope, reason).
+func isProjectInScope(ctx context.Context, projectSFID string) (bool, string) {
+	if !isProjectScopeEnabled(ctx) || projectSFID == "" {
+		return true, ""
+	}
+	scope := contextSettings(ctx)
+
+	identifiers := []string{projectSFID}
+	if entry, err := mappingsKV.Get(ctx, fmt.Sprintf("project.sfid.%s", projectSFID)); err == nil && !isTombstonedMapping(entry.Value()) {
+		if uid := string(entry.Value()); uid != "" {
+			identifiers = append(identifiers, uid)
+		}
+	}
+
+	for _, id := range identifiers {
+		if slices.Contains(scope.ProjectScopeDeny, id) {
+			return false, "denylist"
+		}
+	}
+
+	if len(scope.ProjectScopeAllow) == 0 {
+		return true, ""
+	}
+	for _, id := range identifiers {
+		if slices.Contains(scope.ProjectScopeAllow, id) {
+			return true, ""
+		}
+	}
+	return false, "allowlist"
+}
+
+// isRecordInScope checks whether a v1 record belongs to a project in the
+// configured scope, incrementing the scoped-out counter when it does not.
+// Records that do not reference a project directly (e.g. registrants) are
+// considered in scope: they are gated by their parent's mapping instead.
+func isRecordInScope(ctx context.Context, v1Data map[string]any) bool {
+	if !isProjectScopeEnabled(ctx) {
+		return true
+	}
+
+	projectSFID := extractProjectSFID(v1Data)
+	inScope, reason := isProjectInScope(ctx, projectSFID)
+	if !inScope {
+		scopedOutRecords.inc(reason)
+		logger.With("project_sfid", projectSFID, "reason", reason).DebugContext(ctx, "skipping record for project outside of configured scope")
+	}
+	return inScope
+}
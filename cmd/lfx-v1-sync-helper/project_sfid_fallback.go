@@ -0,0 +1,185 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Project SFID fallback resolution. Meetings and polls of a project are
+// parked (or skipped) until the project sync has written its project.sfid
+// mapping, which can take a while for a new project, or during a replay. With
+// PROJECT_SFID_API_FALLBACK set, a missing project.sfid mapping is resolved
+// through the v1 Project Service instead: the slug of the v1 project is
+// fetched, and looked up in the v2 project service. Resolutions (including
+// failed ones) are cached in the mappings bucket, so every replica shares
+// them; the project.sfid mapping itself is left to the project sync, which
+// decides between creating and updating the v2 project from it.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// projectSFIDFallbackKeyPrefix prefixes the mappings KV keys of cached
+	// fallback resolutions, by project SFID.
+	projectSFIDFallbackKeyPrefix = "v1_project_sfid_fallback."
+
+	// projectSFIDFallbackExpiry is how long a resolved project UID is used.
+	projectSFIDFallbackExpiry = time.Hour
+
+	// projectSFIDFallbackNegativeExpiry is how long a project which could not
+	// be resolved is not looked up again.
+	projectSFIDFallbackNegativeExpiry = 5 * time.Minute
+)
+
+var projectSFIDFallbacks = newCounterVec(
+	"v1_sync_helper_project_sfid_fallbacks_total",
+	"Number of missing project.sfid mappings resolved through the v1 Project Service, by result (resolved, unresolved, cached, or error).",
+	"result",
+)
+
+// projectSFIDResolution is a cached fallback resolution of a project SFID.
+type projectSFIDResolution struct {
+	// ProjectUID is the v2 project UID, or empty if it could not be resolved.
+	ProjectUID string `json:"project_uid"`
+	// Slug is the slug of the v1 project.
+	Slug        string    `json:"slug,omitempty"`
+	LastFetched time.Time `json:"_last_fetched"`
+}
+
+// fresh reports whether a cached resolution can be used.
+func (r projectSFIDResolution) fresh(now time.Time) bool {
+	expiry := projectSFIDFallbackExpiry
+	if r.ProjectUID == "" {
+		expiry = projectSFIDFallbackNegativeExpiry
+	}
+	return now.Sub(r.LastFetched) < expiry
+}
+
+// V1ProjectResponse represents the API response from the v1 Project Service
+type V1ProjectResponse struct {
+	ID   string `json:"ID"`
+	Name string `json:"Name"`
+	Slug string `json:"Slug"`
+}
+
+// projectUIDBySFID returns the v2 project UID of a v1 project SFID from its
+// project.sfid mapping, or, when it is missing, through the v1 Project
+// Service if PROJECT_SFID_API_FALLBACK is set. It returns an empty UID if the
+// project cannot be resolved.
+func projectUIDBySFID(ctx context.Context, projectSFID string) (string, error) {
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf("project.sfid.%s", projectSFID))
+	if err == nil {
+		return string(entry.Value()), nil
+	}
+	if !errors.Is(err, jetstream.ErrKeyNotFound) {
+		return "", err
+	}
+	return resolveProjectSFIDFallback(ctx, projectSFID)
+}
+
+// resolveProjectSFIDFallback resolves a project SFID missing its
+// project.sfid mapping through the v1 Project Service, if enabled, using the
+// cached resolution while it is fresh.
+func resolveProjectSFIDFallback(ctx context.Context, projectSFID string) (string, error) {
+	if !cfg.ProjectSFIDAPIFallback {
+		return "", nil
+	}
+	log := logger.With("project_sfid", projectSFID)
+	now := bootstrap.Now()
+
+	cacheKey := projectSFIDFallbackKeyPrefix + projectSFID
+	if entry, err := mappingsKV.Get(ctx, cacheKey); err == nil {
+		var cached projectSFIDResolution
+		if err := json.Unmarshal(entry.Value(), &cached); err == nil && cached.fresh(now) {
+			projectSFIDFallbacks.inc("cached")
+			return cached.ProjectUID, nil
+		}
+	}
+
+	resolution := projectSFIDResolution{LastFetched: now.UTC()}
+	project, err := getV1ProjectFromProjectSvc(ctx, projectSFID)
+	switch {
+	case errors.Is(err, errV1ProjectNotFound):
+		// Cached as unresolved below.
+	case err != nil:
+		projectSFIDFallbacks.inc("error")
+		return "", fmt.Errorf("failed to fetch v1 project %s: %w", projectSFID, err)
+	case project.Slug != "":
+		resolution.Slug = project.Slug
+		projectUID, err := getProjectUIDBySlug(ctx, project.Slug)
+		if err != nil {
+			// The project may not be in v2 yet, which the projects API does not
+			// tell apart from a failure; it is cached as unresolved.
+			log.With(errKey, err, "slug", project.Slug).DebugContext(ctx, "v1 project slug not found in v2")
+		}
+		resolution.ProjectUID = projectUID
+	}
+
+	if resolution.ProjectUID == "" {
+		projectSFIDFallbacks.inc("unresolved")
+	} else {
+		projectSFIDFallbacks.inc("resolved")
+		log.With("project_uid", resolution.ProjectUID, "slug", resolution.Slug).InfoContext(ctx, "resolved missing project mapping through the v1 Project Service")
+	}
+	if value, err := json.Marshal(resolution); err == nil {
+		if _, err := mappingsKV.Put(ctx, cacheKey, value); err != nil {
+			log.With(errKey, err).WarnContext(ctx, "failed to cache project SFID resolution")
+		}
+	}
+	return resolution.ProjectUID, nil
+}
+
+// projectSFIDResolvable reports whether a project whose project.sfid mapping
+// is missing can be resolved through the fallback, so its child records are
+// handled rather than parked.
+func projectSFIDResolvable(ctx context.Context, projectSFID string) (bool, error) {
+	projectUID, err := resolveProjectSFIDFallback(ctx, projectSFID)
+	return projectUID != "", err
+}
+
+// errV1ProjectNotFound is returned for projects unknown to the v1 Project
+// Service.
+var errV1ProjectNotFound = errors.New("v1 project not found")
+
+// getV1ProjectFromProjectSvc fetches project information from the LFX v1 Project Service
+func getV1ProjectFromProjectSvc(ctx context.Context, sfid string) (*V1ProjectResponse, error) {
+	url := fmt.Sprintf("%sproject-service/v1/projects/%s", cfg.LFXAPIGateway.String(), sfid)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v1HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errV1ProjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("v1 Project Service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var project V1ProjectResponse
+	if err := json.Unmarshal(body, &project); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal project response: %w", err)
+	}
+	return &project, nil
+}
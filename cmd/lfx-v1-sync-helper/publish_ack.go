@@ -0,0 +1,133 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Acknowledged publishes. Indexer and access messages are published with core
+// NATS by default, which gives no delivery guarantee: a broker hiccup silently
+// loses the update. With PUBLISH_ACK_TIMEOUT set, publishMessage publishes
+// through JetStream instead, waiting up to the timeout for the stream
+// acknowledgment, and retrying unacknowledged publishes up to
+// PUBLISH_ACK_RETRIES times with a backoff. The downstream subjects must then
+// be captured by a stream. A KV message is only acknowledged once every
+// publish of its handler succeeded: a failed publish makes the message be
+// retried, even when its handler did not request it.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// defaultPublishAckRetries is the default number of retries of
+	// unacknowledged JetStream publishes.
+	defaultPublishAckRetries = 3
+
+	// publishAckRetryBackoff is the delay before the first retry of an
+	// unacknowledged publish, doubled for each further retry.
+	publishAckRetryBackoff = 250 * time.Millisecond
+)
+
+var publishAcks = newCounterVec(
+	"v1_sync_helper_publish_acks_total",
+	"Number of messages published through JetStream, by result (acknowledged, retried for publishes acknowledged after a retry, or failed).",
+	"result",
+)
+
+// publishTracker records the publishes of a handled message, and whether any
+// of them failed. Trackers nest: publishes are also recorded in the trackers
+// of the parent contexts.
+type publishTracker struct {
+	parent    *publishTracker
+	failed    atomic.Int32
+	published atomic.Int32
+}
+
+// publishTrackerContextKey is the context key of the publish tracker of the
+// handled message.
+type publishTrackerContextKey struct{}
+
+// withPublishTracker returns a context recording the publish failures of a
+// handled message in the returned tracker.
+func withPublishTracker(ctx context.Context) (context.Context, *publishTracker) {
+	parent, _ := ctx.Value(publishTrackerContextKey{}).(*publishTracker)
+	tracker := &publishTracker{parent: parent}
+	return context.WithValue(ctx, publishTrackerContextKey{}, tracker), tracker
+}
+
+// recordPublishFailure records a publish failure in the publish trackers of
+// the context, if any.
+func recordPublishFailure(ctx context.Context) {
+	tracker, _ := ctx.Value(publishTrackerContextKey{}).(*publishTracker)
+	for ; tracker != nil; tracker = tracker.parent {
+		tracker.failed.Add(1)
+	}
+}
+
+// recordPublished records a successful publish in the publish trackers of
+// the context, if any.
+func recordPublished(ctx context.Context) {
+	tracker, _ := ctx.Value(publishTrackerContextKey{}).(*publishTracker)
+	for ; tracker != nil; tracker = tracker.parent {
+		tracker.published.Add(1)
+	}
+}
+
+// failures returns how many publishes failed.
+func (t *publishTracker) failures() int {
+	return int(t.failed.Load())
+}
+
+// publishes returns how many publishes succeeded.
+func (t *publishTracker) publishes() int {
+	return int(t.published.Load())
+}
+
+// publishAckEnabled reports whether messages are published through JetStream.
+func publishAckEnabled() bool {
+	return cfg.PublishAckTimeout > 0
+}
+
+// publishAcked publishes a message through JetStream, retrying it until the
+// stream acknowledges it or the retries are exhausted. Publishes to subjects
+// without a stream are not retried.
+func publishAcked(ctx context.Context, msg *nats.Msg) error {
+	backoff := publishAckRetryBackoff
+	var err error
+	for attempt := 0; attempt <= cfg.PublishAckRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				publishAcks.inc("failed")
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		publishCtx, cancel := context.WithTimeout(ctx, cfg.PublishAckTimeout)
+		_, err = jsContext.PublishMsg(publishCtx, msg)
+		cancel()
+		if err == nil {
+			if attempt > 0 {
+				publishAcks.inc("retried")
+			} else {
+				publishAcks.inc("acknowledged")
+			}
+			return nil
+		}
+		if errors.Is(err, jetstream.ErrNoStreamResponse) {
+			publishAcks.inc("failed")
+			return fmt.Errorf("no stream captures subject %s: %w", msg.Subject, err)
+		}
+		logger.With(errKey, err, "subject", msg.Subject, "attempt", attempt+1).WarnContext(ctx, "JetStream publish not acknowledged")
+	}
+	publishAcks.inc("failed")
+	return fmt.Errorf("JetStream publish not acknowledged after %d attempts: %w", cfg.PublishAckRetries+1, err)
+}
@@ -0,0 +1,94 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Publish subject allowlist. The service publishes to a shared NATS cluster,
+// where a misconfigured subject (or a bug building one, e.g. from record data)
+// would reach whatever service consumes it. With PUBLISH_SUBJECT_ALLOWLIST
+// set, the messages and requests sent by publishMessage, requestMessage,
+// publishDeadLetter, and access acknowledgment requests are checked against
+// its subject patterns (NATS wildcards: "*" matches one token, and a trailing
+// ">" one or more), and messages to other subjects are refused with
+// errPublishSubjectNotAllowed, before they are signed, recorded by dry runs,
+// or copied to the publish targets. The service's own dead-letter subjects,
+// matching deadLetterSubjectPattern, are always allowed.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// deadLetterSubjectPattern matches the dead-letter subjects of the service,
+// allowed in addition to the publish subject allowlist.
+const deadLetterSubjectPattern = publishTargetDLQSubjectPrefix + ">"
+
+// errPublishSubjectNotAllowed is returned for messages to a subject outside
+// of the publish subject allowlist.
+var errPublishSubjectNotAllowed = errors.New("subject not in the publish subject allowlist")
+
+var blockedPublishes = newCounterVec(
+	"v1_sync_helper_publish_blocked_total",
+	"Number of messages refused because their subject is outside of the publish subject allowlist, by operation (publish, request, or dead_letter).",
+	"operation",
+)
+
+// validatePublishSubjectAllowlist checks that PUBLISH_SUBJECT_ALLOWLIST holds
+// valid NATS subject patterns.
+func validatePublishSubjectAllowlist(patterns []string) error {
+	for _, pattern := range patterns {
+		tokens := strings.Split(pattern, ".")
+		for i, token := range tokens {
+			if token == "" || strings.ContainsAny(token, " \t\r\n") ||
+				(token != "*" && token != ">" && strings.ContainsAny(token, "*>")) ||
+				(token == ">" && i != len(tokens)-1) {
+				return fmt.Errorf("PUBLISH_SUBJECT_ALLOWLIST pattern %q is not a valid NATS subject pattern", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// subjectMatches reports whether a subject matches a NATS subject pattern.
+func subjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(subjectTokens) == len(patternTokens)
+}
+
+// checkPublishSubject returns errPublishSubjectNotAllowed if the publish
+// subject allowlist is set and the subject matches none of its patterns.
+func checkPublishSubject(operation, subject string) error {
+	if len(cfg.PublishSubjectAllowlist) == 0 || subjectMatches(deadLetterSubjectPattern, subject) {
+		return nil
+	}
+	for _, pattern := range cfg.PublishSubjectAllowlist {
+		if subjectMatches(pattern, subject) {
+			return nil
+		}
+	}
+	blockedPublishes.inc(operation)
+	logger.With("subject", subject, "operation", operation).Error("refused message to a subject outside of the publish subject allowlist")
+	return fmt.Errorf("%w: %s", errPublishSubjectNotAllowed, subject)
+}
+
+// publishDeadLetter publishes a dead-lettered message on the primary
+// connection, if its subject passes the publish subject allowlist.
+func publishDeadLetter(msg *nats.Msg) error {
+	if err := checkPublishSubject("dead_letter", msg.Subject); err != nil {
+		return err
+	}
+	return publishCore(msg)
+}
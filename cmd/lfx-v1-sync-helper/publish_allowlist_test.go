@@ -0,0 +1,54 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"lfx.index.v1_meeting", "lfx.index.v1_meeting", true},
+		{"lfx.index.v1_meeting", "lfx.index.v1_meeting.updated", false},
+		{"lfx.index.v1_meeting", "lfx.index", false},
+		{"lfx.*.v1_meeting", "lfx.index.v1_meeting", true},
+		{"lfx.*.v1_meeting", "lfx.index.v1_past_meeting", false},
+		{"lfx.*", "lfx.index.v1_meeting", false},
+		{"*", "lfx", true},
+		{"lfx.index.>", "lfx.index.v1_meeting", true},
+		{"lfx.index.>", "lfx.index.v1_meeting.updated", true},
+		{"lfx.index.>", "lfx.index", false},
+		{"lfx.*.v1_meeting.>", "lfx.access.v1_meeting.3", true},
+		{"lfx.*.v1_meeting.>", "lfx.access.v1_meeting", false},
+		{">", "lfx.index.v1_meeting", true},
+		{deadLetterSubjectPattern, indexerDLQSubject, true},
+		{deadLetterSubjectPattern, "lfx.v1_sync_helper.dlq", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatches(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestPublishDeadLetter(t *testing.T) {
+	_, _, publisher := setupHandlerTest(t)
+	cfg.PublishSubjectAllowlist = []string{"lfx.index.>"}
+
+	if err := publishDeadLetter(&nats.Msg{Subject: indexerDLQSubject, Data: []byte("{}")}); err != nil {
+		t.Fatalf("dead letter to %s: %v", indexerDLQSubject, err)
+	}
+	if err := publishDeadLetter(&nats.Msg{Subject: "lfx.other.dlq", Data: []byte("{}")}); !errors.Is(err, errPublishSubjectNotAllowed) {
+		t.Fatalf("dead letter outside of the allowlist: got %v, want errPublishSubjectNotAllowed", err)
+	}
+	if n := len(publisher.Messages(">")); n != 1 {
+		t.Errorf("published dead letters: got %d, want 1", n)
+	}
+}
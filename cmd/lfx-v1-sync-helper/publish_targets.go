@@ -0,0 +1,349 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Publish targets. During migrations the sync output (indexer, access, and
+// FGA messages) can be delivered to additional NATS clusters, listed in
+// PUBLISH_TARGETS as name=url pairs. The primary connection is published to
+// synchronously, as before, so handler failures are still retried through
+// JetStream redelivery. Each additional target has its own connection and
+// bounded queue, published to by a worker which retries failed publishes with
+// backoff, and dead-letters messages it cannot deliver (or cannot queue) to
+// the target's subject under publishTargetDLQSubjectPrefix on the primary
+// connection. One target being down never blocks or fails the others.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	// publishTargetQueueSize bounds the messages queued for a target.
+	publishTargetQueueSize = 10000
+
+	// publishTargetAttempts is how many times a message is published to a
+	// target before it is dead-lettered.
+	publishTargetAttempts = 5
+
+	// publishTargetBackoff is the delay before the first publish retry,
+	// doubled on each failed attempt.
+	publishTargetBackoff = 500 * time.Millisecond
+
+	// publishTargetDLQSubjectPrefix is the subject prefix, on the primary
+	// connection, of messages which could not be delivered to a target.
+	publishTargetDLQSubjectPrefix = "lfx.v1_sync_helper.dlq."
+
+	// Headers of dead-lettered messages.
+	publishTargetSubjectHeader = "Lfx-Original-Subject"
+	publishTargetErrorHeader   = "Lfx-Publish-Error"
+)
+
+var publishTargetMessages = newCounterVec(
+	"v1_sync_helper_publish_target_messages_total",
+	"Number of messages fanned out to additional publish targets, by target and result (published, retried, dead_lettered, or dlq_error).",
+	"target", "result",
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_publish_target_healthy",
+	"Whether an additional publish target is connected and its last publish succeeded (1) or not (0).",
+	publishTargetHealthSamples,
+	"target",
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_publish_target_queued",
+	"Number of messages queued for an additional publish target.",
+	publishTargetQueueSamples,
+	"target",
+)
+
+// publishTargetSpec is a configured additional publish target.
+type publishTargetSpec struct {
+	name string
+	url  string
+}
+
+// parsePublishTargets parses PUBLISH_TARGETS entries of the form name=url.
+func parsePublishTargets(entries []string) ([]publishTargetSpec, error) {
+	var specs []publishTargetSpec
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		name, targetURL, ok := strings.Cut(entry, "=")
+		name, targetURL = strings.TrimSpace(name), strings.TrimSpace(targetURL)
+		if !ok || name == "" || targetURL == "" {
+			return nil, fmt.Errorf("invalid PUBLISH_TARGETS entry %q: expected name=url", entry)
+		}
+		if strings.ContainsAny(name, ".*> ") {
+			return nil, fmt.Errorf("invalid PUBLISH_TARGETS name %q: must be a single subject token", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate PUBLISH_TARGETS name %q", name)
+		}
+		seen[name] = true
+		specs = append(specs, publishTargetSpec{name: name, url: targetURL})
+	}
+	return specs, nil
+}
+
+// publishTarget is an additional NATS cluster receiving the sync output.
+type publishTarget struct {
+	name  string
+	conn  *nats.Conn
+	queue chan *nats.Msg
+	done  chan struct{}
+
+	mu            sync.Mutex
+	lastErr       error
+	lastPublished time.Time
+}
+
+// publishTargetStatus is the status of an additional publish target, as
+// served by the /statusz endpoint.
+type publishTargetStatus struct {
+	Name          string     `json:"name"`
+	Connected     bool       `json:"connected"`
+	Queued        int        `json:"queued"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastPublished *time.Time `json:"last_published,omitempty"`
+}
+
+var (
+	// publishTargets are the additional publish targets, set on startup.
+	publishTargets []*publishTarget
+	// publishTargetsMu guards closing the publish target queues.
+	publishTargetsMu sync.RWMutex
+	// publishTargetsClosed is set once the publish target queues are closed.
+	publishTargetsClosed bool
+)
+
+// connectPublishTargets connects to the configured additional publish
+// targets and starts their workers. A target which cannot be reached yet is
+// retried in the background, with its messages queued meanwhile.
+func connectPublishTargets(specs []publishTargetSpec) error {
+	for _, spec := range specs {
+		target := &publishTarget{
+			name:  spec.name,
+			queue: make(chan *nats.Msg, publishTargetQueueSize),
+			done:  make(chan struct{}),
+		}
+		log := logger.With("target", spec.name, "url", redactURLCredentials(spec.url))
+		conn, err := nats.Connect(spec.url,
+			nats.Name(fmt.Sprintf("%s (publish target %s)", serviceName, spec.name)),
+			nats.RetryOnFailedConnect(true),
+			nats.MaxReconnects(-1),
+			nats.DrainTimeout(bootstrap.GracefulShutdownSeconds*time.Second),
+			nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+				log.With(errKey, err).Warn("publish target disconnected")
+			}),
+			nats.ReconnectHandler(func(_ *nats.Conn) {
+				log.Info("publish target reconnected")
+			}),
+			nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+				log.With(errKey, err).Error("async publish target error")
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to connect to publish target %s: %w", spec.name, err)
+		}
+		target.conn = conn
+		publishTargets = append(publishTargets, target)
+		go target.run()
+		log.Info("publish target configured")
+	}
+	return nil
+}
+
+// redactURLCredentials removes any user info from a URL, for logging.
+func redactURLCredentials(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// fanOutMessage queues a copy of a published message for each additional
+// publish target. A target whose queue is full, or closed for shutdown,
+// dead-letters the message.
+func fanOutMessage(msg *nats.Msg) {
+	publishTargetsMu.RLock()
+	defer publishTargetsMu.RUnlock()
+	for _, target := range publishTargets {
+		targetMsg := &nats.Msg{Subject: msg.Subject, Data: msg.Data, Header: msg.Header}
+		if publishTargetsClosed {
+			target.deadLetter(targetMsg, errors.New("publish target closed"))
+			continue
+		}
+		select {
+		case target.queue <- targetMsg:
+		default:
+			target.deadLetter(targetMsg, errors.New("publish target queue full"))
+		}
+	}
+}
+
+// run publishes the queued messages of a target until its queue is closed.
+func (t *publishTarget) run() {
+	defer close(t.done)
+	for msg := range t.queue {
+		t.publish(msg)
+	}
+}
+
+// publish publishes a message to the target, retrying with exponential
+// backoff, and dead-letters it if every attempt fails.
+func (t *publishTarget) publish(msg *nats.Msg) {
+	backoff := publishTargetBackoff
+	var err error
+	for attempt := 1; attempt <= publishTargetAttempts; attempt++ {
+		if err = t.conn.PublishMsg(msg); err == nil {
+			t.recordResult(nil)
+			publishTargetMessages.inc(t.name, "published")
+			return
+		}
+		t.recordResult(err)
+		if t.conn.IsClosed() {
+			break
+		}
+		publishTargetMessages.inc(t.name, "retried")
+		logger.With(errKey, err, "target", t.name, "subject", msg.Subject, "attempt", attempt).Warn("failed to publish to publish target")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	t.deadLetter(msg, err)
+}
+
+// recordResult records the outcome of the last publish to the target.
+func (t *publishTarget) recordResult(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastErr = err
+	if err == nil {
+		t.lastPublished = bootstrap.Now()
+	}
+}
+
+// deadLetter publishes a message which could not be delivered to the target
+// to the target's dead-letter subject on the primary connection.
+func (t *publishTarget) deadLetter(msg *nats.Msg, cause error) {
+	dlqMsg := &nats.Msg{
+		Subject: publishTargetDLQSubjectPrefix + t.name,
+		Data:    msg.Data,
+		Header:  nats.Header{},
+	}
+	for key, values := range msg.Header {
+		dlqMsg.Header[key] = values
+	}
+	dlqMsg.Header.Set(publishTargetSubjectHeader, msg.Subject)
+	if cause != nil {
+		dlqMsg.Header.Set(publishTargetErrorHeader, cause.Error())
+	}
+
+	log := logger.With(errKey, cause, "target", t.name, "subject", msg.Subject)
+	if err := publishDeadLetter(dlqMsg); err != nil {
+		publishTargetMessages.inc(t.name, "dlq_error")
+		log.With("dlq_error", err).Error("failed to dead-letter message for publish target")
+		return
+	}
+	publishTargetMessages.inc(t.name, "dead_lettered")
+	log.Warn("dead-lettered message for publish target")
+}
+
+// healthy reports whether the target is connected and its last publish
+// succeeded.
+func (t *publishTarget) healthy() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conn.IsConnected() && t.lastErr == nil
+}
+
+// status returns the status of the target.
+func (t *publishTarget) status() publishTargetStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := publishTargetStatus{
+		Name:      t.name,
+		Connected: t.conn.IsConnected(),
+		Queued:    len(t.queue),
+	}
+	if t.lastErr != nil {
+		status.LastError = t.lastErr.Error()
+	}
+	if !t.lastPublished.IsZero() {
+		lastPublished := t.lastPublished
+		status.LastPublished = &lastPublished
+	}
+	return status
+}
+
+// publishTargetStatuses returns the status of each additional publish target.
+func publishTargetStatuses() []publishTargetStatus {
+	statuses := []publishTargetStatus{}
+	for _, target := range publishTargets {
+		statuses = append(statuses, target.status())
+	}
+	return statuses
+}
+
+// publishTargetHealthSamples returns the health gauge samples of the
+// additional publish targets.
+func publishTargetHealthSamples() []gaugeSample {
+	var samples []gaugeSample
+	for _, target := range publishTargets {
+		value := 0.0
+		if target.healthy() {
+			value = 1
+		}
+		samples = append(samples, gaugeSample{labelValues: []string{target.name}, value: value})
+	}
+	return samples
+}
+
+// publishTargetQueueSamples returns the queue length gauge samples of the
+// additional publish targets.
+func publishTargetQueueSamples() []gaugeSample {
+	var samples []gaugeSample
+	for _, target := range publishTargets {
+		samples = append(samples, gaugeSample{labelValues: []string{target.name}, value: float64(len(target.queue))})
+	}
+	return samples
+}
+
+// closePublishTargets stops queuing messages for the additional publish
+// targets, waits for their queued messages to be published (or the context
+// to be done), then drains their connections. It must be called before the
+// primary connection is drained, as undeliverable messages are dead-lettered
+// to it.
+func closePublishTargets(ctx context.Context) {
+	publishTargetsMu.Lock()
+	if !publishTargetsClosed {
+		publishTargetsClosed = true
+		for _, target := range publishTargets {
+			close(target.queue)
+		}
+	}
+	publishTargetsMu.Unlock()
+
+	for _, target := range publishTargets {
+		select {
+		case <-target.done:
+		case <-ctx.Done():
+			logger.With("target", target.name, "queued", len(target.queue)).Warn("timed out publishing queued messages to publish target")
+		}
+		if err := target.conn.Drain(); err != nil {
+			logger.With(errKey, err, "target", target.name).Error("error draining publish target connection")
+		}
+	}
+}
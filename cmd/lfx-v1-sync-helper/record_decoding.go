@@ -0,0 +1,67 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Record decoding. Handlers receive v1 records as maps, which the dispatcher
+// needs (for scoping, schema versions, and parent mapping dependencies), and
+// the converters used to marshal the map back to JSON to unmarshal it into
+// their input struct: three JSON passes per message. handleKVPut now keeps
+// the JSON value it decoded in the context, with the map decoded from it, and
+// decodeV1Record unmarshals the struct from that value directly when it is
+// given the same map. Maps replaced along the way (converted schema versions,
+// middleware, canary copies) and records read from the bucket by handlers
+// (e.g. parent meetings) or decoded from msgpack are still round-tripped
+// through JSON. Maps are never modified in place, so a map identical to the
+// decoded one still matches its value.
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+var recordDecodes = newCounterVec(
+	"v1_sync_helper_record_decodes_total",
+	"Number of v1 records decoded into handler input structs, by path (source, from the KV value, or map, through a JSON round trip).",
+	"path",
+)
+
+// recordSourceContextKey is the context key of the source of a record.
+type recordSourceContextKey struct{}
+
+// recordSource is the JSON value a record map was decoded from.
+type recordSource struct {
+	v1Data map[string]any
+	value  []byte
+}
+
+// withRecordSource returns a context carrying the JSON value a record map was
+// decoded from.
+func withRecordSource(ctx context.Context, v1Data map[string]any, value []byte) context.Context {
+	return context.WithValue(ctx, recordSourceContextKey{}, &recordSource{v1Data: v1Data, value: value})
+}
+
+// decodeV1Record decodes a v1 record into the struct pointed to by out, from
+// its source JSON value when the context carries that of this map, and
+// otherwise through a JSON round trip of the map.
+func decodeV1Record(ctx context.Context, v1Data map[string]any, out any) error {
+	if source, ok := ctx.Value(recordSourceContextKey{}).(*recordSource); ok &&
+		reflect.ValueOf(source.v1Data).UnsafePointer() == reflect.ValueOf(v1Data).UnsafePointer() {
+		if err := json.Unmarshal(source.value, out); err == nil {
+			recordDecodes.inc("source")
+			return nil
+		}
+		// Values the map round trip normalizes (e.g. numbers in exponent
+		// notation for integer fields) fall back to it.
+		reflect.ValueOf(out).Elem().SetZero()
+	}
+
+	jsonBytes, err := json.Marshal(v1Data)
+	if err != nil {
+		return err
+	}
+	recordDecodes.inc("map")
+	return json.Unmarshal(jsonBytes, out)
+}
@@ -0,0 +1,91 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Recording session cross-linking. The sessions of a past meeting recording
+// and those of its past meeting share their Zoom session UUIDs, but were never
+// linked in the output, so v2 could not tell which session a recording
+// belongs to. Before a recording is indexed, each of its sessions matching a
+// session of the past meeting (read from v1-objects) references it by UUID,
+// with its position among the past meeting sessions ordered by start time,
+// e.g. "recording for session 2 of 3". Recordings synced before their past
+// meeting are linked when they are next updated or replayed.
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var recordingSessionLinks = newCounterVec(
+	"v1_sync_helper_recording_session_links_total",
+	"Number of past meeting recording sessions processed for cross-linking, by result (linked, unmatched for sessions without a matching past meeting session, or no_past_meeting).",
+	"result",
+)
+
+// linkRecordingSessions references the matching past meeting session from
+// each session of a recording. A missing past meeting leaves the sessions
+// unlinked.
+func linkRecordingSessions(ctx context.Context, recording *pastMeetingRecordingInput) error {
+	if len(recording.Sessions) == 0 || recording.MeetingAndOccurrenceID == "" {
+		return nil
+	}
+	pastMeetingData, exists, err := getV1ObjectData(ctx, fmt.Sprintf("itx-zoom-past-meetings.%s", recording.MeetingAndOccurrenceID))
+	if err != nil {
+		return fmt.Errorf("failed to get past meeting data: %w", err)
+	}
+	if deletedAt, deleted := pastMeetingData["_sdc_deleted_at"]; !exists || (deleted && deletedAt != nil && deletedAt != "") {
+		recordingSessionLinks.inc("no_past_meeting")
+		return nil
+	}
+	var pastMeeting struct {
+		Sessions []ZoomPastMeetingSession `json:"sessions"`
+	}
+	if err := decodeV1Record(ctx, pastMeetingData, &pastMeeting); err != nil {
+		return fmt.Errorf("failed to decode past meeting sessions: %w", err)
+	}
+
+	sessions := orderedPastMeetingSessions(pastMeeting.Sessions)
+	for i := range recording.Sessions {
+		session := &recording.Sessions[i]
+		position := slices.IndexFunc(sessions, func(s ZoomPastMeetingSession) bool { return s.UUID == session.UUID })
+		if session.UUID == "" || position < 0 {
+			recordingSessionLinks.inc("unmatched")
+			continue
+		}
+		session.PastMeetingSessionUID = session.UUID
+		session.SessionNumber = position + 1
+		session.SessionCount = len(sessions)
+		recordingSessionLinks.inc("linked")
+	}
+	return nil
+}
+
+// orderedPastMeetingSessions returns the distinct sessions of a past meeting
+// with a UUID, ordered by start time. Sessions without a valid start time come
+// last, in their original order.
+func orderedPastMeetingSessions(sessions []ZoomPastMeetingSession) []ZoomPastMeetingSession {
+	var ordered []ZoomPastMeetingSession
+	for _, session := range sessions {
+		if session.UUID != "" && !slices.ContainsFunc(ordered, func(s ZoomPastMeetingSession) bool { return s.UUID == session.UUID }) {
+			ordered = append(ordered, session)
+		}
+	}
+	slices.SortStableFunc(ordered, func(a, b ZoomPastMeetingSession) int {
+		aStart, aErr := time.Parse(time.RFC3339, a.StartTime)
+		bStart, bErr := time.Parse(time.RFC3339, b.StartTime)
+		switch {
+		case aErr != nil && bErr != nil:
+			return 0
+		case aErr != nil:
+			return 1
+		case bErr != nil:
+			return -1
+		}
+		return aStart.Compare(bStart)
+	})
+	return ordered
+}
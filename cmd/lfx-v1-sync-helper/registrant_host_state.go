@@ -0,0 +1,170 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Meeting registrant host tracking.
+//
+// The registrant put message carries the registrant's host flag, but a
+// true→false flip is only implied by a later put message, and retried or
+// concurrent messages can reach fga-sync out of order. The host flag last sent
+// for each registrant is kept in the mappings bucket with a per-registrant
+// sequence number, so flips are sent as explicit promote and demote events,
+// and fga-sync can discard any access message older than one it has applied.
+// The committee the registrant was last granted access through is kept too,
+// so a committee reassignment removes the previous committee relationship
+// before the new one is put, with the sequence number preceding the put's.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// registrantHostStateKeyFmt is the mappings KV key format of the host state
+	// last sent for a meeting registrant, by registrant ID.
+	registrantHostStateKeyFmt = "v1_meeting_registrant_hosts.%s"
+
+	// registrantHostStateUpdateAttempts is how many times to retry storing the
+	// host state on a concurrent update.
+	registrantHostStateUpdateAttempts = 3
+)
+
+// Host change values of a MeetingRegistrantHostMessage.
+const (
+	RegistrantHostPromoted = "promoted"
+	RegistrantHostDemoted  = "demoted"
+)
+
+// registrantHostState is the access state last sent for a meeting registrant.
+type registrantHostState struct {
+	// Username is the Auth0 "sub" the access was granted to.
+	Username string `json:"username"`
+	// Host is the host flag last sent.
+	Host bool `json:"host"`
+	// CommitteeUID is the committee last sent, if any.
+	CommitteeUID string `json:"committee_uid,omitempty"`
+	// Sequence is incremented for every access message sent for the registrant.
+	Sequence uint64 `json:"sequence"`
+}
+
+// MeetingRegistrantHostMessage is the schema for the explicit host promote and
+// demote events sent to the fga-sync service when a registrant's host flag
+// flips. Sequence orders all access messages of a registrant.
+type MeetingRegistrantHostMessage struct {
+	ID         string `json:"id"`
+	MeetingID  string `json:"meeting_id"`
+	Username   string `json:"username"`
+	HostChange string `json:"host_change"`
+	Sequence   uint64 `json:"sequence"`
+}
+
+// advanceRegistrantHostState stores the next access state of a registrant,
+// incrementing its sequence number, using optimistic concurrency control. A
+// committee reassignment increments it twice, reserving the sequence number
+// preceding the stored one for the removal of the previous committee. It
+// returns the previous state, which is only valid when found is true, and the
+// stored state.
+func advanceRegistrantHostState(ctx context.Context, registrantID, username, committeeUID string, host bool) (previous, next registrantHostState, found bool, err error) {
+	stateKey := fmt.Sprintf(registrantHostStateKeyFmt, registrantID)
+
+	for attempt := 1; ; attempt++ {
+		previous, found = registrantHostState{}, false
+		var revision uint64
+
+		entry, err := mappingsKV.Get(ctx, stateKey)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound):
+		case err != nil:
+			return previous, next, false, fmt.Errorf("failed to get registrant host state %s: %w", stateKey, err)
+		default:
+			revision = entry.Revision()
+			if err := json.Unmarshal(entry.Value(), &previous); err != nil {
+				return previous, next, false, fmt.Errorf("failed to unmarshal registrant host state %s: %w", stateKey, err)
+			}
+			found = true
+		}
+
+		next = registrantHostState{
+			Username:     username,
+			Host:         host,
+			CommitteeUID: committeeUID,
+			Sequence:     previous.Sequence + 1,
+		}
+		if found && committeeReassigned(previous, next) {
+			next.Sequence++
+		}
+		data, err := json.Marshal(next)
+		if err != nil {
+			return previous, next, found, fmt.Errorf("failed to marshal registrant host state %s: %w", stateKey, err)
+		}
+
+		if revision == 0 {
+			_, err = mappingsKV.Create(ctx, stateKey, data)
+		} else {
+			_, err = mappingsKV.Update(ctx, stateKey, data, revision)
+		}
+		if err == nil {
+			return previous, next, found, nil
+		}
+		if (isRevisionMismatchError(err) || errors.Is(err, jetstream.ErrKeyExists)) && attempt < registrantHostStateUpdateAttempts {
+			continue
+		}
+		return previous, next, found, fmt.Errorf("failed to store registrant host state %s: %w", stateKey, err)
+	}
+}
+
+// committeeReassigned reports whether the next access state of a registrant
+// moves the same user from one committee to another, so the previous
+// committee relationship must be removed.
+func committeeReassigned(previous, next registrantHostState) bool {
+	return previous.Username == next.Username &&
+		previous.CommitteeUID != "" && previous.CommitteeUID != next.CommitteeUID
+}
+
+// sendRegistrantHostChanges sends the explicit host demote and promote events
+// for the transition from the previous to the next access state of a
+// registrant. A username change demotes the previous user if they were a host.
+func sendRegistrantHostChanges(ctx context.Context, registrantID, meetingID string, previous, next registrantHostState, found bool) error {
+	if !found {
+		// The initial put message carries the host flag.
+		return nil
+	}
+
+	if previous.Host && (!next.Host || previous.Username != next.Username) {
+		if err := sendRegistrantHostMessage(ctx, registrantID, meetingID, previous.Username, RegistrantHostDemoted, next.Sequence); err != nil {
+			return err
+		}
+	}
+	if next.Host && (!previous.Host || previous.Username != next.Username) {
+		if err := sendRegistrantHostMessage(ctx, registrantID, meetingID, next.Username, RegistrantHostPromoted, next.Sequence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendRegistrantHostMessage sends a host promote or demote event.
+func sendRegistrantHostMessage(ctx context.Context, registrantID, meetingID, username, hostChange string, sequence uint64) error {
+	subject := V1MeetingRegistrantHostPromoteSubject
+	if hostChange == RegistrantHostDemoted {
+		subject = V1MeetingRegistrantHostDemoteSubject
+	}
+
+	msgBytes, err := json.Marshal(MeetingRegistrantHostMessage{
+		ID:         registrantID,
+		MeetingID:  meetingID,
+		Username:   username,
+		HostChange: hostChange,
+		Sequence:   sequence,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal registrant host %s message: %w", hostChange, err)
+	}
+	return sendAccessMessage(ctx, subject, msgBytes)
+}
@@ -0,0 +1,73 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Time-window replays. Incident remediation usually means resyncing every
+// record changed since the incident started, e.g. in the last 6 hours. The
+// -since flag of the replay subcommand, and the since field of the
+// /admin/resync prefix requests, only replay the v1-objects keys modified at
+// or after a time (RFC 3339) or within a duration (e.g. "6h"). A record is
+// modified at its v1 modification timestamp (modified_at, or lastmodifieddate
+// for Salesforce records), or at the time its v1-objects revision was
+// written, for records without one.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// parseReplaySince parses the start of a replay time window, either a time
+// in RFC 3339 or a duration before now.
+func parseReplaySince(value string, now time.Time) (time.Time, error) {
+	if since, err := time.Parse(time.RFC3339, value); err == nil {
+		return since, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return time.Time{}, fmt.Errorf("since must be an RFC 3339 time or a positive duration, got %q", value)
+	}
+	return now.Add(-window), nil
+}
+
+// recordModifiedAt returns the modification time of a v1-objects entry: its
+// v1 modification timestamp, or the time its revision was written.
+func recordModifiedAt(entry jetstream.KeyValueEntry) time.Time {
+	v1Data, err := decodeLintRecord(entry.Key(), entry.Value())
+	if err != nil {
+		return entry.Created()
+	}
+	for _, field := range []string{"modified_at", "lastmodifieddate"} {
+		if modifiedAt, err := parseTimestamp(getTimestampString(v1Data, field)); err == nil {
+			return modifiedAt
+		}
+	}
+	return entry.Created()
+}
+
+// filterModifiedSince returns the keys whose records were modified at or
+// after since. Deleted keys are skipped.
+func filterModifiedSince(ctx context.Context, keys []string, since time.Time) ([]string, error) {
+	var modified []string
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := v1KV.Get(ctx, k)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound), errors.Is(err, jetstream.ErrKeyDeleted):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("error getting v1-objects entry %s: %w", k, err)
+		}
+		if !recordModifiedAt(entry).Before(since) {
+			modified = append(modified, k)
+		}
+	}
+	return modified, nil
+}
@@ -0,0 +1,377 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Published payload schema inference. The downstream services (the indexer,
+// fga-sync) declare schemas of the payloads they consume, which drift from
+// what the service actually publishes. With SCHEMA_INFERENCE_DIR set, a
+// SCHEMA_INFERENCE_SAMPLE_RATE ratio of the JSON payloads published by
+// publishMessage, and of the access messages acknowledged by fga-sync, is
+// sampled per subject (access subjects without their project shard suffix),
+// and a JSON Schema inferred from the samples of each subject is written to
+// <dir>/<subject>.schema.json every schemaInferenceWriteInterval, and on
+// shutdown. Each schema records the service version which inferred it. The schemas found in the directory at
+// startup, inferred by another release, are the baseline: once a subject has
+// schemaDriftMinSamples samples, the fields added, removed, or whose types
+// changed since the baseline are logged, and reported by the
+// v1_sync_helper_schema_drift gauge for alerting.
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+const (
+	// defaultSchemaInferenceSampleRate is the default ratio of published
+	// payloads sampled for schema inference.
+	defaultSchemaInferenceSampleRate = 0.01
+
+	// schemaInferenceWriteInterval is how often the inferred schemas are
+	// written.
+	schemaInferenceWriteInterval = time.Minute
+
+	// schemaDriftMinSamples is how many samples of a subject are needed
+	// before its schema is compared with the baseline, so optional fields
+	// not sampled yet are not reported as removed.
+	schemaDriftMinSamples = 100
+
+	// jsonSchemaDialect is the JSON Schema version of the inferred schemas.
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_schema_drift",
+	"Whether the schema inferred from the published payloads of a subject differs from the one inferred by the previous release (1) or not (0), by subject.",
+	func() []gaugeSample { return schemaInference.driftSamples() },
+	"subject",
+)
+
+var schemaSamples = newCounterVec(
+	"v1_sync_helper_schema_samples_total",
+	"Number of published payloads sampled for schema inference, by result (sampled, or skipped for payloads which are not JSON).",
+	"result",
+)
+
+// jsonSchema is an inferred JSON Schema.
+type jsonSchema struct {
+	Schema     string                 `json:"$schema,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Type       []string               `json:"type,omitempty"`
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *jsonSchema            `json:"items,omitempty"`
+	// ServiceVersion and Samples describe the inference of a subject schema.
+	ServiceVersion string `json:"x-service-version,omitempty"`
+	Samples        int    `json:"x-samples,omitempty"`
+}
+
+// flatten returns the types of each field path of the schema, e.g.
+// ".participants[].email" -> "null|string".
+func (s *jsonSchema) flatten(path string, fields map[string]string) map[string]string {
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	fields[path] = strings.Join(s.Type, "|")
+	for name, property := range s.Properties {
+		property.flatten(path+"."+name, fields)
+	}
+	if s.Items != nil {
+		s.Items.flatten(path+"[]", fields)
+	}
+	return fields
+}
+
+// schemaNode accumulates the values sampled at a path of a payload.
+type schemaNode struct {
+	types      map[string]bool
+	properties map[string]*schemaNode
+	// objects is how many objects were sampled, and present how many of them
+	// had each property, to tell the required properties.
+	objects int
+	present map[string]int
+	items   *schemaNode
+}
+
+func newSchemaNode() *schemaNode {
+	return &schemaNode{types: map[string]bool{}, properties: map[string]*schemaNode{}, present: map[string]int{}}
+}
+
+// observe merges a decoded JSON value into the node.
+func (n *schemaNode) observe(value any) {
+	switch v := value.(type) {
+	case nil:
+		n.types["null"] = true
+	case bool:
+		n.types["boolean"] = true
+	case float64:
+		if v == math.Trunc(v) {
+			n.types["integer"] = true
+		} else {
+			n.types["number"] = true
+		}
+	case string:
+		n.types["string"] = true
+	case []any:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = newSchemaNode()
+		}
+		for _, item := range v {
+			n.items.observe(item)
+		}
+	case map[string]any:
+		n.types["object"] = true
+		n.objects++
+		for name, property := range v {
+			n.present[name]++
+			if n.properties[name] == nil {
+				n.properties[name] = newSchemaNode()
+			}
+			n.properties[name].observe(property)
+		}
+	}
+}
+
+// schema returns the JSON Schema of the values sampled at the node.
+func (n *schemaNode) schema() *jsonSchema {
+	s := &jsonSchema{}
+	for t := range n.types {
+		// Integers are numbers.
+		if t == "integer" && n.types["number"] {
+			continue
+		}
+		s.Type = append(s.Type, t)
+	}
+	slices.Sort(s.Type)
+	if len(n.properties) > 0 {
+		s.Properties = make(map[string]*jsonSchema, len(n.properties))
+		for name, property := range n.properties {
+			s.Properties[name] = property.schema()
+			if n.present[name] == n.objects {
+				s.Required = append(s.Required, name)
+			}
+		}
+		slices.Sort(s.Required)
+	}
+	if n.items != nil && len(n.items.types) > 0 {
+		s.Items = n.items.schema()
+	}
+	return s
+}
+
+// schemaInference is the schema inference state of the published subjects.
+var schemaInference = &schemaInferenceState{
+	subjects: map[string]*subjectSchema{},
+	baseline: map[string]*jsonSchema{},
+}
+
+// schemaInferenceState holds the samples of each subject, and the baseline
+// schemas inferred by another release.
+type schemaInferenceState struct {
+	mu       sync.Mutex
+	subjects map[string]*subjectSchema
+	baseline map[string]*jsonSchema
+}
+
+// subjectSchema is the schema inferred from the samples of a subject.
+type subjectSchema struct {
+	root    *schemaNode
+	samples int
+	changed bool
+	// drift are the fields which changed since the baseline, once compared.
+	drift   []string
+	checked bool
+}
+
+// schemaSubject returns the subject a payload schema is inferred for: access
+// subjects are inferred without their project shard suffix.
+func schemaSubject(subject string) string {
+	for _, accessSubject := range accessSubjects() {
+		if strings.HasPrefix(subject, accessSubject+".") {
+			return accessSubject
+		}
+	}
+	return subject
+}
+
+// sampleSchema samples a published payload for schema inference, when
+// enabled.
+func sampleSchema(subject string, data []byte) {
+	if cfg.SchemaInferenceDir == "" || rand.Float64() >= cfg.SchemaInferenceSampleRate {
+		return
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		schemaSamples.inc("skipped")
+		return
+	}
+	schemaSamples.inc("sampled")
+
+	subject = schemaSubject(subject)
+	schemaInference.mu.Lock()
+	defer schemaInference.mu.Unlock()
+	s := schemaInference.subjects[subject]
+	if s == nil {
+		s = &subjectSchema{root: newSchemaNode()}
+		schemaInference.subjects[subject] = s
+	}
+	s.root.observe(value)
+	s.samples++
+	s.changed = true
+}
+
+// loadSchemaBaseline reads the schemas inferred by other releases from the
+// schema directory, as the baseline of drift detection.
+func loadSchemaBaseline(ctx context.Context) {
+	paths, err := filepath.Glob(filepath.Join(cfg.SchemaInferenceDir, "*.schema.json"))
+	if err != nil {
+		return
+	}
+	version := bootstrap.Version()
+	schemaInference.mu.Lock()
+	defer schemaInference.mu.Unlock()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.With(errKey, err, "path", path).WarnContext(ctx, "failed to read baseline schema")
+			continue
+		}
+		var baseline jsonSchema
+		if err := json.Unmarshal(data, &baseline); err != nil || baseline.Title == "" {
+			logger.With(errKey, err, "path", path).WarnContext(ctx, "ignored invalid baseline schema")
+			continue
+		}
+		// Schemas inferred by this release (before a restart) are no baseline.
+		if baseline.ServiceVersion == version {
+			continue
+		}
+		schemaInference.baseline[baseline.Title] = &baseline
+	}
+	logger.With("dir", cfg.SchemaInferenceDir, "subjects", len(schemaInference.baseline)).InfoContext(ctx, "loaded baseline schemas")
+}
+
+// watchSchemaInference writes the inferred schemas every
+// schemaInferenceWriteInterval until the context is cancelled. They are
+// written once more on shutdown.
+func watchSchemaInference(ctx context.Context) {
+	if err := os.MkdirAll(cfg.SchemaInferenceDir, 0o755); err != nil {
+		logger.With(errKey, err, "dir", cfg.SchemaInferenceDir).ErrorContext(ctx, "failed to create schema inference directory")
+		return
+	}
+	loadSchemaBaseline(ctx)
+
+	ticker := time.NewTicker(schemaInferenceWriteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		writeInferredSchemas(ctx)
+	}
+}
+
+// writeInferredSchemas writes the schemas of the subjects sampled since they
+// were last written, comparing them with their baseline.
+func writeInferredSchemas(ctx context.Context) {
+	version := bootstrap.Version()
+	schemaInference.mu.Lock()
+	schemas := map[string]*jsonSchema{}
+	for subject, s := range schemaInference.subjects {
+		if !s.changed {
+			continue
+		}
+		s.changed = false
+		schema := s.root.schema()
+		schema.Schema, schema.Title, schema.ServiceVersion, schema.Samples = jsonSchemaDialect, subject, version, s.samples
+		schemas[subject] = schema
+
+		baseline := schemaInference.baseline[subject]
+		if baseline == nil || s.samples < schemaDriftMinSamples {
+			continue
+		}
+		drift := schemaDrift(baseline, schema)
+		if !slices.Equal(drift, s.drift) || !s.checked {
+			s.checked = true
+			s.drift = drift
+			if len(drift) > 0 {
+				logger.With("subject", subject, "baseline_version", baseline.ServiceVersion, "fields", drift).WarnContext(ctx, "published payload schema drifted from the previous release")
+			}
+		}
+	}
+	schemaInference.mu.Unlock()
+
+	for subject, schema := range schemas {
+		if err := writeSchemaFile(subject, schema); err != nil {
+			logger.With(errKey, err, "subject", subject).ErrorContext(ctx, "failed to write inferred schema")
+		}
+	}
+}
+
+// schemaDrift returns the field paths added, removed, or whose types changed
+// between the baseline and the inferred schema, sorted.
+func schemaDrift(baseline, inferred *jsonSchema) []string {
+	before, after := baseline.flatten("", nil), inferred.flatten("", nil)
+	var drift []string
+	for path, types := range after {
+		switch previous, ok := before[path]; {
+		case !ok:
+			drift = append(drift, "added "+path)
+		case previous != types:
+			drift = append(drift, "changed "+path+" ("+previous+" -> "+types+")")
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			drift = append(drift, "removed "+path)
+		}
+	}
+	slices.Sort(drift)
+	return drift
+}
+
+// writeSchemaFile writes the schema of a subject, through a temporary file so
+// readers never see a partial schema.
+func writeSchemaFile(subject string, schema *jsonSchema) error {
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(cfg.SchemaInferenceDir, subject+".schema.json")
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// driftSamples returns the v1_sync_helper_schema_drift samples of the
+// subjects compared with their baseline.
+func (s *schemaInferenceState) driftSamples() []gaugeSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var samples []gaugeSample
+	for subject, inferred := range s.subjects {
+		if !inferred.checked {
+			continue
+		}
+		value := 0.0
+		if len(inferred.drift) > 0 {
+			value = 1
+		}
+		samples = append(samples, gaugeSample{labelValues: []string{subject}, value: value})
+	}
+	return samples
+}
@@ -0,0 +1,149 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Processing latency SLO burn rates. The service objective is that
+// SLO_OBJECTIVE of the messages (by default 99%) are processed within
+// SLO_LATENCY_TARGET (by default 60s) of their write to the stream (e.g. the
+// KV write of a v1 record). Each settled message is a good or bad event of the
+// SLO: acknowledged in time, or acknowledged late or dropped after exhausting
+// its deliveries. The events are kept in per-minute buckets for the longest
+// window, and the burn rate of each window (its bad event ratio divided by
+// the error budget, 1-SLO_OBJECTIVE) is exposed as a gauge, so alerts can
+// follow the multi-window, multi-burn-rate practice without recording rules.
+
+import (
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+const (
+	// defaultSLOLatencyTarget is the default processing latency target.
+	defaultSLOLatencyTarget = 60 * time.Second
+
+	// defaultSLOObjective is the default ratio of messages to process within
+	// the latency target.
+	defaultSLOObjective = 0.99
+
+	// sloBucketDuration is the resolution of the SLO windows.
+	sloBucketDuration = time.Minute
+)
+
+// sloWindows are the burn rate windows, the short and long windows of the
+// usual multi-window, multi-burn-rate alerts (e.g. 1h and 5m at 14.4x, 6h and
+// 30m at 6x, 1d and 2h at 3x, 3d and 6h at 1x).
+var sloWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"2h", 2 * time.Hour},
+	{"6h", 6 * time.Hour},
+	{"1d", 24 * time.Hour},
+	{"3d", 72 * time.Hour},
+}
+
+var sloEvents = newCounterVec(
+	"v1_sync_helper_slo_events_total",
+	"Number of settled messages counted against the processing latency SLO, by consumer and result (good or bad).",
+	"consumer", "result",
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_slo_burn_rate",
+	"Burn rate of the processing latency SLO error budget, by window: the ratio of messages acknowledged after SLO_LATENCY_TARGET or dropped after exhausting their deliveries, divided by 1-SLO_OBJECTIVE.",
+	func() []gaugeSample { return processingSLO.burnRates(bootstrap.Now()) },
+	"window",
+)
+
+// processingSLO tracks the events of the processing latency SLO.
+var processingSLO = &sloTracker{}
+
+// sloBucket counts the events of a minute.
+type sloBucket struct {
+	minute int64
+	total  uint64
+	bad    uint64
+}
+
+// sloTracker keeps the SLO events in a ring of per-minute buckets spanning
+// the longest window.
+type sloTracker struct {
+	mu      sync.Mutex
+	buckets []sloBucket
+}
+
+// recordSLOEvent counts a settled message against the processing latency
+// SLO: it is good if it was acknowledged within the latency target of its
+// write.
+func recordSLOEvent(consumer string, written time.Time, acked bool) {
+	now := bootstrap.Now()
+	good := acked && now.Sub(written) <= cfg.SLOLatencyTarget
+	if good {
+		sloEvents.inc(consumer, "good")
+	} else {
+		sloEvents.inc(consumer, "bad")
+	}
+	processingSLO.record(now, good)
+}
+
+// record counts an event in the bucket of its minute.
+func (t *sloTracker) record(at time.Time, good bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bucket := t.bucket(at.Unix() / int64(sloBucketDuration/time.Second))
+	bucket.total++
+	if !good {
+		bucket.bad++
+	}
+}
+
+// bucket returns the bucket of a minute, resetting it if it held an older
+// minute. t.mu must be held.
+func (t *sloTracker) bucket(minute int64) *sloBucket {
+	if t.buckets == nil {
+		longest := sloWindows[len(sloWindows)-1].duration
+		t.buckets = make([]sloBucket, longest/sloBucketDuration)
+	}
+	bucket := &t.buckets[minute%int64(len(t.buckets))]
+	if bucket.minute != minute {
+		*bucket = sloBucket{minute: minute}
+	}
+	return bucket
+}
+
+// burnRates returns the burn rate of each window ending now. Windows without
+// events have a zero burn rate.
+func (t *sloTracker) burnRates(now time.Time) []gaugeSample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	budget := 1 - defaultSLOObjective
+	if cfg != nil {
+		budget = 1 - cfg.SLOObjective
+	}
+
+	current := now.Unix() / int64(sloBucketDuration/time.Second)
+	samples := make([]gaugeSample, 0, len(sloWindows))
+	for _, window := range sloWindows {
+		var total, bad uint64
+		oldest := current - int64(window.duration/sloBucketDuration) + 1
+		for i := range t.buckets {
+			if bucket := t.buckets[i]; bucket.minute >= oldest && bucket.minute <= current {
+				total += bucket.total
+				bad += bucket.bad
+			}
+		}
+		rate := 0.0
+		if total > 0 {
+			rate = float64(bad) / float64(total) / budget
+		}
+		samples = append(samples, gaugeSample{labelValues: []string{window.name}, value: rate})
+	}
+	return samples
+}
@@ -0,0 +1,80 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSLOBurnRates(t *testing.T) {
+	setupHandlerTest(t)
+	cfg.SLOObjective = 0.99
+	now := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	tracker := &sloTracker{}
+	events := []struct {
+		ago       time.Duration
+		good, bad int
+	}{
+		{2 * time.Minute, 9, 1},
+		{20 * time.Minute, 5, 5},
+		{3 * time.Hour, 0, 10},
+		// Outside of the longest window.
+		{4 * 24 * time.Hour, 0, 10},
+	}
+	for _, event := range events {
+		for range event.good {
+			tracker.record(now.Add(-event.ago), true)
+		}
+		for range event.bad {
+			tracker.record(now.Add(-event.ago), false)
+		}
+	}
+
+	budget := 1 - cfg.SLOObjective
+	want := map[string]float64{
+		"5m":  1.0 / 10 / budget,
+		"30m": 6.0 / 20 / budget,
+		"1h":  6.0 / 20 / budget,
+		"2h":  6.0 / 20 / budget,
+		"6h":  16.0 / 30 / budget,
+		"1d":  16.0 / 30 / budget,
+		"3d":  16.0 / 30 / budget,
+	}
+	samples := tracker.burnRates(now)
+	if len(samples) != len(sloWindows) {
+		t.Fatalf("samples: got %d, want %d", len(samples), len(sloWindows))
+	}
+	for _, sample := range samples {
+		window := sample.labelValues[0]
+		if math.Abs(sample.value-want[window]) > 1e-9 {
+			t.Errorf("burn rate of %s: got %v, want %v", window, sample.value, want[window])
+		}
+	}
+
+	// Windows without events have a zero burn rate.
+	for _, sample := range tracker.burnRates(now.Add(10 * 24 * time.Hour)) {
+		if sample.value != 0 {
+			t.Errorf("burn rate of %s without events: got %v, want 0", sample.labelValues[0], sample.value)
+		}
+	}
+}
+
+func TestSLOTrackerBucketReuse(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	// A bucket is reused for the minute one longest window later, dropping
+	// its older events.
+	tracker := &sloTracker{}
+	tracker.record(now.Add(-72*time.Hour), false)
+	tracker.record(now, true)
+
+	minute := now.Unix() / int64(sloBucketDuration/time.Second)
+	bucket := tracker.buckets[minute%int64(len(tracker.buckets))]
+	if bucket != (sloBucket{minute: minute, total: 1}) {
+		t.Errorf("reused bucket: got %+v, want a single good event of minute %d", bucket, minute)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// The /statusz endpoint reports JetStream message outcomes per consumer and
+// object type since startup, along with live consumer state from the server.
+// The outcome totals per consumer are also served as the status of the NATS
+// micro service.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const statuszTimeout = 5 * time.Second
+
+// consumerStatus is the status of a single JetStream consumer.
+type consumerStatus struct {
+	Name           string                       `json:"name"`
+	Stream         string                       `json:"stream"`
+	NumPending     *uint64                      `json:"num_pending,omitempty"`
+	NumAckPending  *int                         `json:"num_ack_pending,omitempty"`
+	NumRedelivered *int                         `json:"num_redelivered,omitempty"`
+	InfoError      string                       `json:"info_error,omitempty"`
+	Outcomes       map[string]map[string]uint64 `json:"outcomes"`
+}
+
+// statuszResponse is the response body of the /statusz endpoint.
+type statuszResponse struct {
+	Consumers      []*consumerStatus     `json:"consumers"`
+	PublishTargets []publishTargetStatus `json:"publish_targets"`
+	MassPurge      bool                  `json:"mass_purge_safe_mode"`
+	Access         accessFailuresStatus  `json:"access_acks"`
+	Signing        *messageSigningStatus `json:"message_signing,omitempty"`
+	Tasks          []taskStatus          `json:"tasks"`
+}
+
+// statuszHandler serves the JetStream consumer status as JSON.
+func statuszHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), statuszTimeout)
+	defer cancel()
+
+	infos := consumerInfos(ctx)
+
+	consumers := []*consumerStatus{}
+	byName := map[string]*consumerStatus{}
+	for _, c := range syncConsumers() {
+		status := &consumerStatus{
+			Name:     c.name,
+			Stream:   c.stream,
+			Outcomes: map[string]map[string]uint64{},
+		}
+		consumers = append(consumers, status)
+		byName[status.Name] = status
+
+		result, ok := infos[c.name]
+		if !ok {
+			continue
+		}
+		if result.err != nil {
+			status.InfoError = result.err.Error()
+			continue
+		}
+		status.NumPending = &result.info.NumPending
+		status.NumAckPending = &result.info.NumAckPending
+		status.NumRedelivered = &result.info.NumRedelivered
+	}
+
+	// Samples are labeled by consumer, object type, and outcome.
+	for _, sample := range jetStreamMessages.samples() {
+		status, ok := byName[sample.labelValues[0]]
+		if !ok {
+			continue
+		}
+		objectType, outcome := sample.labelValues[1], sample.labelValues[2]
+		if status.Outcomes[objectType] == nil {
+			status.Outcomes[objectType] = map[string]uint64{}
+		}
+		status.Outcomes[objectType][outcome] = sample.value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuszResponse{Consumers: consumers, PublishTargets: publishTargetStatuses(), MassPurge: massPurgeActive.Load(), Access: accessStatus(), Signing: messageSigningStatusz(), Tasks: backgroundTasks.statuses()}); err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to encode statusz response")
+	}
+}
+
+// processingStats returns the JetStream message outcomes per consumer since
+// startup, summed over object types. It is the status reported by the NATS
+// micro service.
+func processingStats() any {
+	outcomes := map[string]map[string]uint64{}
+	for _, c := range syncConsumers() {
+		outcomes[c.name] = map[string]uint64{}
+	}
+	for _, sample := range jetStreamMessages.samples() {
+		consumer, outcome := sample.labelValues[0], sample.labelValues[2]
+		if outcomes[consumer] == nil {
+			outcomes[consumer] = map[string]uint64{}
+		}
+		outcomes[consumer][outcome] += sample.value
+	}
+	return map[string]any{"outcomes": outcomes}
+}
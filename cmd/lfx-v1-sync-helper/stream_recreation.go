@@ -0,0 +1,122 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Stream recreation protection. When a consumed stream (e.g. the v1-objects KV
+// bucket) is dropped and recreated, its durable consumers are recreated with
+// it, so the service would replay every object (or, with sequences reset,
+// skip changes). The creation time of each consumed stream is recorded in the
+// mappings bucket, and on startup a stream whose creation time changed stops
+// the service until an operator acknowledges the recreation with
+// -acknowledge-recreated-streams (or ACKNOWLEDGE_RECREATED_STREAMS).
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// streamCreatedKeyFmt is the mappings key of the recorded creation time
+	// of a stream.
+	streamCreatedKeyFmt = "v1_sync_helper_streams.%s"
+
+	// streamCreatedUpdateAttempts bounds the compare-and-set attempts when
+	// recording a stream creation time concurrently with other replicas.
+	streamCreatedUpdateAttempts = 3
+)
+
+// checkStreamRecreation compares the creation time of each consumed stream
+// with the one recorded in the mappings bucket. Unrecorded streams are
+// recorded. A recreated stream is recorded again if acknowledged, and
+// otherwise reported in the returned error.
+func checkStreamRecreation(ctx context.Context, acknowledged []string) error {
+	streams := []string{}
+	for _, c := range syncConsumers() {
+		if !slices.Contains(streams, c.stream) {
+			streams = append(streams, c.stream)
+		}
+	}
+
+	var recreated []string
+	for _, name := range streams {
+		stream, err := jsContext.Stream(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to get stream %s: %w", name, err)
+		}
+		created := stream.CachedInfo().Created.UTC()
+
+		wasRecreated, recorded, err := recordStreamCreated(ctx, name, created, slices.Contains(acknowledged, name))
+		if err != nil {
+			return fmt.Errorf("failed to record creation time of stream %s: %w", name, err)
+		}
+		if !wasRecreated {
+			continue
+		}
+
+		log := logger.With("stream", name, "created", created, "recorded_created", recorded)
+		if slices.Contains(acknowledged, name) {
+			log.WarnContext(ctx, "stream was recreated, and the recreation was acknowledged; its consumers will resume from the new stream")
+			continue
+		}
+		log.ErrorContext(ctx, "stream was recreated since it was last consumed; acknowledge the recreation to resume consuming it")
+		recreated = append(recreated, name)
+	}
+
+	if len(recreated) > 0 {
+		return fmt.Errorf("streams recreated without acknowledgement: %s (acknowledge with -acknowledge-recreated-streams=%s)",
+			strings.Join(recreated, ", "), strings.Join(recreated, ","))
+	}
+	return nil
+}
+
+// recordStreamCreated records the creation time of a stream if none was
+// recorded, or if it changed and overwrite is set. It reports whether the
+// recorded creation time differed, and what it was.
+func recordStreamCreated(ctx context.Context, name string, created time.Time, overwrite bool) (bool, time.Time, error) {
+	key := fmt.Sprintf(streamCreatedKeyFmt, name)
+	value := []byte(created.Format(time.RFC3339Nano))
+
+	for attempt := 1; ; attempt++ {
+		var revision uint64
+		entry, err := mappingsKV.Get(ctx, key)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound):
+		case err != nil:
+			return false, time.Time{}, err
+		default:
+			revision = entry.Revision()
+			recorded, parseErr := time.Parse(time.RFC3339Nano, string(entry.Value()))
+			if parseErr == nil && recorded.Equal(created) {
+				return false, recorded, nil
+			}
+			if !overwrite {
+				return true, recorded, nil
+			}
+		}
+
+		if revision == 0 {
+			_, err = mappingsKV.Create(ctx, key, value)
+		} else {
+			_, err = mappingsKV.Update(ctx, key, value, revision)
+		}
+		if err == nil {
+			if revision == 0 {
+				logger.With("stream", name, "created", created).InfoContext(ctx, "recorded stream creation time")
+				return false, time.Time{}, nil
+			}
+			recorded, _ := time.Parse(time.RFC3339Nano, string(entry.Value()))
+			return true, recorded, nil
+		}
+		if attempt >= streamCreatedUpdateAttempts || !(isRevisionMismatchError(err) || errors.Is(err, jetstream.ErrKeyExists)) {
+			return false, time.Time{}, err
+		}
+	}
+}
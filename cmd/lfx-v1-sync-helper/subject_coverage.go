@@ -0,0 +1,149 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Downstream subject coverage. Messages published to a downstream subject
+// which no stream captures vanish when its consumers are not subscribed over
+// core NATS. The preflight checks look up the stream of each downstream
+// subject at startup, and the lookup is repeated every
+// SUBJECT_COVERAGE_INTERVAL: with SUBJECT_COVERAGE_POLICY=fail, /readyz fails
+// while a subject is not covered, and with the default warn policy, every
+// check logs the uncovered subjects as warnings. The coverage of each subject
+// is reported by the v1_sync_helper_subject_covered gauge.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// subjectCoverageWarn logs the uncovered downstream subjects.
+	subjectCoverageWarn = "warn"
+
+	// subjectCoverageFail fails readiness while a downstream subject is not
+	// covered.
+	subjectCoverageFail = "fail"
+
+	// defaultSubjectCoverageInterval is the default interval of the periodic
+	// subject coverage checks.
+	defaultSubjectCoverageInterval = 5 * time.Minute
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_subject_covered",
+	"Whether a downstream subject is captured by a stream (1) or not (0), by subject, as of the last coverage check.",
+	func() []gaugeSample { return subjectCoverage.samples() },
+	"subject",
+)
+
+// subjectCoverage is the coverage of the downstream subjects, as of the last
+// check.
+var subjectCoverage = &subjectCoverageState{}
+
+// subjectCoverageState holds the result of the last subject coverage check.
+type subjectCoverageState struct {
+	mu        sync.Mutex
+	checked   []string
+	uncovered []string
+}
+
+// checkSubjectCoverage looks up the stream of each downstream subject, and
+// records which are not covered. Sharded access subjects are checked by a
+// representative shard. It returns the uncovered subjects, and the lookups
+// which failed, whose subjects keep their previous coverage.
+func checkSubjectCoverage(ctx context.Context) ([]string, []error) {
+	sharded := map[string]bool{}
+	if cfg.AccessSubjectShards > 0 {
+		for _, subject := range accessSubjects() {
+			sharded[subject] = true
+		}
+	}
+
+	subjectCoverage.mu.Lock()
+	previous := slices.Clone(subjectCoverage.uncovered)
+	subjectCoverage.mu.Unlock()
+
+	var checked, uncovered []string
+	var errs []error
+	for _, subject := range downstreamSubjects() {
+		if sharded[subject] {
+			subject = shardedAccessSubject(subject, "")
+		}
+		_, err := jsContext.StreamNameBySubject(ctx, subject)
+		switch {
+		case errors.Is(err, jetstream.ErrStreamNotFound):
+			uncovered = append(uncovered, subject)
+		case err != nil:
+			errs = append(errs, fmt.Errorf("stream lookup for subject %s failed: %w", subject, err))
+			if slices.Contains(previous, subject) {
+				uncovered = append(uncovered, subject)
+			}
+		}
+		checked = append(checked, subject)
+	}
+
+	subjectCoverage.mu.Lock()
+	subjectCoverage.checked, subjectCoverage.uncovered = checked, uncovered
+	subjectCoverage.mu.Unlock()
+	return uncovered, errs
+}
+
+// watchSubjectCoverage checks the subject coverage, then every
+// SUBJECT_COVERAGE_INTERVAL until the context is cancelled.
+func watchSubjectCoverage(ctx context.Context) {
+	ticker := time.NewTicker(cfg.SubjectCoverageInterval)
+	defer ticker.Stop()
+	for {
+		uncovered, errs := checkSubjectCoverage(ctx)
+		for _, err := range errs {
+			logger.With(errKey, err).WarnContext(ctx, "subject coverage check failed")
+		}
+		if len(uncovered) > 0 {
+			logger.With("subjects", uncovered, "policy", cfg.SubjectCoveragePolicy).WarnContext(ctx, "downstream subjects are not covered by any stream, messages published to them may be lost")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readiness returns an error naming the uncovered downstream subjects, with
+// the fail policy, for /readyz.
+func (s *subjectCoverageState) readiness() error {
+	if cfg.SubjectCoveragePolicy != subjectCoverageFail {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.uncovered) == 0 {
+		return nil
+	}
+	return fmt.Errorf("downstream subjects not covered by any stream: %s", strings.Join(s.uncovered, ", "))
+}
+
+// samples returns the v1_sync_helper_subject_covered samples of the checked
+// subjects.
+func (s *subjectCoverageState) samples() []gaugeSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	samples := make([]gaugeSample, 0, len(s.checked))
+	for _, subject := range s.checked {
+		value := 1.0
+		if slices.Contains(s.uncovered, subject) {
+			value = 0
+		}
+		samples = append(samples, gaugeSample{labelValues: []string{subject}, value: value})
+	}
+	return samples
+}
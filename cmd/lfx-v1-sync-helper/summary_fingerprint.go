@@ -0,0 +1,107 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Past meeting summaries carry large composed Content and EditedContent
+// strings, and v1 often rewrites summary records without changing them. The
+// fingerprint of the last synced version of each summary is kept in the
+// mappings bucket, so unchanged summaries are not re-indexed, while their
+// access is still re-evaluated when the parent past meeting's
+// ai_summary_access changes.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// summaryFingerprintKeyFmt is the mappings KV key format of the fingerprint of
+// a past meeting summary, by summary ID.
+const summaryFingerprintKeyFmt = "v1_past_meeting_summary_fingerprints.%s"
+
+// summaryFingerprint identifies the last synced version of a past meeting summary.
+type summaryFingerprint struct {
+	// ContentHash is the SHA-256 hash of the composed Content.
+	ContentHash string `json:"content_hash"`
+	// EditedContentHash is the SHA-256 hash of the composed EditedContent.
+	EditedContentHash string `json:"edited_content_hash"`
+	// MetadataHash is the SHA-256 hash of the remaining summary fields.
+	MetadataHash string `json:"metadata_hash"`
+	// SummaryAccess is the parent past meeting's ai_summary_access.
+	SummaryAccess string `json:"summary_access"`
+}
+
+// newSummaryFingerprint returns the fingerprint of a converted summary and the
+// summary access of its parent past meeting.
+func newSummaryFingerprint(summary *pastMeetingSummaryInput, summaryAccess string) (summaryFingerprint, error) {
+	// The metadata hash excludes the content and the fields it is composed
+	// from, which are hashed separately, and the modification audit fields,
+	// which v1 bumps when rewriting an unchanged summary.
+	metadata := *summary
+	metadata.UpdatedAt = ""
+	metadata.ModifiedBy = UpdatedBy{}
+	metadata.Content = ""
+	metadata.EditedContent = ""
+	metadata.SummaryOverview = ""
+	metadata.SummaryDetails = nil
+	metadata.NextSteps = nil
+	metadata.EditedSummaryOverview = ""
+	metadata.EditedSummaryDetails = nil
+	metadata.EditedNextSteps = nil
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return summaryFingerprint{}, fmt.Errorf("failed to marshal summary metadata: %w", err)
+	}
+
+	return summaryFingerprint{
+		ContentHash:       sha256Hex([]byte(summary.Content)),
+		EditedContentHash: sha256Hex([]byte(summary.EditedContent)),
+		MetadataHash:      sha256Hex(metadataBytes),
+		SummaryAccess:     summaryAccess,
+	}, nil
+}
+
+// contentChanged reports whether the summary content or metadata differ from
+// a previous fingerprint.
+func (f summaryFingerprint) contentChanged(previous summaryFingerprint) bool {
+	return f.ContentHash != previous.ContentHash ||
+		f.EditedContentHash != previous.EditedContentHash ||
+		f.MetadataHash != previous.MetadataHash
+}
+
+// getSummaryFingerprint returns the stored fingerprint of a summary. It
+// returns false when there is none, or it is a tombstone or unreadable, in
+// which case the summary is synced as changed.
+func getSummaryFingerprint(ctx context.Context, summaryID string) (summaryFingerprint, bool) {
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(summaryFingerprintKeyFmt, summaryID))
+	if err != nil || isTombstonedMapping(entry.Value()) {
+		return summaryFingerprint{}, false
+	}
+	var fingerprint summaryFingerprint
+	if err := json.Unmarshal(entry.Value(), &fingerprint); err != nil {
+		return summaryFingerprint{}, false
+	}
+	return fingerprint, true
+}
+
+// putSummaryFingerprint stores the fingerprint of a synced summary.
+func putSummaryFingerprint(ctx context.Context, summaryID string, fingerprint summaryFingerprint) error {
+	fingerprintBytes, err := json.Marshal(fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary fingerprint: %w", err)
+	}
+	if _, err := mappingsKV.Put(ctx, fmt.Sprintf(summaryFingerprintKeyFmt, summaryID), fingerprintBytes); err != nil {
+		return fmt.Errorf("failed to store summary fingerprint: %w", err)
+	}
+	return nil
+}
+
+// sha256Hex returns the hex-encoded SHA-256 hash of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
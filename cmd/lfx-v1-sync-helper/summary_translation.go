@@ -0,0 +1,293 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Summary language detection and translation. Some AI summaries are written
+// in the language of the meeting rather than in English. The language of the
+// text of each summary is detected (by script, or by its most common words),
+// and sent in the content_language field of the indexed summary. With
+// SUMMARY_TRANSLATION_PROVIDER set, the Content of summaries in another
+// language than SUMMARY_TRANSLATION_TARGET_LANGUAGE is also translated by the
+// provider, and sent in the translated_content field. Providers implement
+// summaryTranslator, and are registered in summaryTranslationProviders.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// defaultSummaryTranslationTargetLanguage is the default language
+	// summaries are translated to.
+	defaultSummaryTranslationTargetLanguage = "en"
+
+	// summaryTranslationTimeout bounds a translation request.
+	summaryTranslationTimeout = 30 * time.Second
+
+	// summaryLanguageMinWords is the number of words below which the
+	// language of a summary is not guessed from its common words.
+	summaryLanguageMinWords = 5
+)
+
+var summaryLanguages = newCounterVec(
+	"v1_sync_helper_summary_languages_total",
+	"Number of past meeting summaries indexed, by detected content language (or \"unknown\").",
+	"language",
+)
+
+var summaryTranslations = newCounterVec(
+	"v1_sync_helper_summary_translations_total",
+	"Number of past meeting summary translations, by provider and result (translated or error).",
+	"provider", "result",
+)
+
+// summaryTranslator is a translation provider of summary content.
+type summaryTranslator interface {
+	// translate returns text, in sourceLanguage, translated to
+	// targetLanguage. Languages are ISO 639-1 codes.
+	translate(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error)
+}
+
+// summaryTranslationProviders are the constructors of the translation
+// providers, by SUMMARY_TRANSLATION_PROVIDER name.
+var summaryTranslationProviders = map[string]func(cfg *Config) (summaryTranslator, error){
+	"http": newHTTPSummaryTranslator,
+}
+
+// summaryTranslation is the translation provider, or nil when summaries are
+// not translated.
+var summaryTranslation summaryTranslator
+
+// initSummaryTranslation creates the translation provider set by
+// SUMMARY_TRANSLATION_PROVIDER, if any.
+func initSummaryTranslation(cfg *Config) error {
+	if cfg.SummaryTranslationProvider == "" {
+		return nil
+	}
+	newProvider, ok := summaryTranslationProviders[cfg.SummaryTranslationProvider]
+	if !ok {
+		return fmt.Errorf("unknown summary translation provider %q", cfg.SummaryTranslationProvider)
+	}
+	provider, err := newProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create %s summary translation provider: %w", cfg.SummaryTranslationProvider, err)
+	}
+	summaryTranslation = provider
+	return nil
+}
+
+// validateSummaryTranslationProvider checks that SUMMARY_TRANSLATION_PROVIDER
+// is empty or a registered provider.
+func validateSummaryTranslationProvider(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := summaryTranslationProviders[name]; !ok {
+		providers := make([]string, 0, len(summaryTranslationProviders))
+		for provider := range summaryTranslationProviders {
+			providers = append(providers, provider)
+		}
+		sort.Strings(providers)
+		return fmt.Errorf("SUMMARY_TRANSLATION_PROVIDER %q is not one of %v", name, providers)
+	}
+	return nil
+}
+
+// applySummaryLanguage detects the content language of a summary, and
+// translates its Content if a translation provider is set and the language
+// was detected and is not the target language. Translation failures are logged, and the
+// summary is indexed untranslated; it returns false in that case.
+func applySummaryLanguage(ctx context.Context, summary *pastMeetingSummaryInput) bool {
+	summary.ContentLanguage = detectLanguage(summaryText(summary))
+	if summary.ContentLanguage == "" {
+		summaryLanguages.inc("unknown")
+	} else {
+		summaryLanguages.inc(summary.ContentLanguage)
+	}
+
+	target := cfg.SummaryTranslationTargetLanguage
+	if summaryTranslation == nil || summary.ContentLanguage == "" || summary.ContentLanguage == target {
+		return true
+	}
+
+	translated, err := summaryTranslation.translate(ctx, summary.Content, summary.ContentLanguage, target)
+	if err != nil {
+		summaryTranslations.inc(cfg.SummaryTranslationProvider, "error")
+		logger.With(errKey, err, "summary_id", summary.ID, "content_language", summary.ContentLanguage).
+			WarnContext(ctx, "failed to translate past meeting summary, indexing it untranslated")
+		return false
+	}
+	summaryTranslations.inc(cfg.SummaryTranslationProvider, "translated")
+	summary.TranslatedContent = translated
+	return true
+}
+
+// summaryText returns the text a summary's Content is composed from, without
+// the (English) section headings added by the conversion.
+func summaryText(summary *pastMeetingSummaryInput) string {
+	parts := []string{summary.SummaryOverview}
+	for _, detail := range summary.SummaryDetails {
+		parts = append(parts, detail.Label, detail.Summary)
+	}
+	parts = append(parts, summary.NextSteps...)
+	return strings.Join(parts, "\n")
+}
+
+// scriptLanguages are the languages detected by the script of their letters.
+var scriptLanguages = []struct {
+	language string
+	script   *unicode.RangeTable
+}{
+	// Japanese mixes kana with Han characters, so kana is checked first.
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ru", unicode.Cyrillic},
+	{"ar", unicode.Arabic},
+	{"he", unicode.Hebrew},
+	{"el", unicode.Greek},
+	{"th", unicode.Thai},
+	{"hi", unicode.Devanagari},
+}
+
+// commonWords are frequent short words of the languages written in the Latin
+// script, which rarely occur in the other ones.
+var commonWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "for", "with", "was", "are", "this", "will", "be", "on", "it"},
+	"es": {"el", "los", "las", "del", "que", "y", "en", "por", "para", "una", "con", "se", "es", "fue", "como"},
+	"fr": {"le", "les", "des", "et", "est", "que", "une", "pour", "dans", "du", "sur", "qui", "avec", "au", "pas"},
+	"de": {"der", "die", "das", "und", "ist", "zu", "den", "mit", "von", "für", "nicht", "ein", "eine", "auf", "wurde"},
+	"pt": {"o", "os", "as", "do", "da", "que", "e", "em", "para", "uma", "com", "não", "foi", "dos", "ao"},
+	"it": {"il", "la", "di", "che", "e", "per", "una", "con", "sono", "della", "gli", "non", "è", "nel", "anche"},
+	"nl": {"de", "het", "een", "en", "van", "dat", "is", "op", "te", "voor", "met", "niet", "zijn", "werd", "ook"},
+}
+
+// detectLanguage returns the ISO 639-1 code of the language of a text: the
+// language of the script of most of its letters if it is not Latin, or
+// otherwise the language whose common words occur most in it. It returns ""
+// if the text is too short or has no common words.
+func detectLanguage(text string) string {
+	letters := 0
+	scriptCounts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for i, script := range scriptLanguages {
+			if unicode.Is(script.script, r) {
+				scriptCounts[i]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	// Kana is a marker of Japanese even in text mostly written in Han
+	// characters.
+	if scriptCounts[0]+scriptCounts[1] > 0 && scriptCounts[0]+scriptCounts[1]+scriptCounts[3] > letters/2 {
+		return "ja"
+	}
+	for i, count := range scriptCounts {
+		if count > letters/2 {
+			return scriptLanguages[i].language
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < summaryLanguageMinWords {
+		return ""
+	}
+	counts := map[string]int{}
+	for language, common := range commonWords {
+		for _, word := range words {
+			for _, commonWord := range common {
+				if word == commonWord {
+					counts[language]++
+					break
+				}
+			}
+		}
+	}
+	best := ""
+	for language, count := range counts {
+		if count > counts[best] || (count == counts[best] && count > 0 && language < best) {
+			best = language
+		}
+	}
+	return best
+}
+
+// httpSummaryTranslator translates summaries by posting them to the
+// SUMMARY_TRANSLATION_URL endpoint.
+type httpSummaryTranslator struct {
+	url    string
+	client *http.Client
+}
+
+// httpSummaryTranslationRequest is the request body of the http provider.
+type httpSummaryTranslationRequest struct {
+	Text           string `json:"text"`
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+}
+
+// httpSummaryTranslationResponse is the response body of the http provider.
+type httpSummaryTranslationResponse struct {
+	TranslatedText string `json:"translated_text"`
+}
+
+// newHTTPSummaryTranslator returns the http translation provider.
+func newHTTPSummaryTranslator(cfg *Config) (summaryTranslator, error) {
+	if cfg.SummaryTranslationURL == "" {
+		return nil, fmt.Errorf("SUMMARY_TRANSLATION_URL is required by the http provider")
+	}
+	return &httpSummaryTranslator{
+		url:    cfg.SummaryTranslationURL,
+		client: &http.Client{Timeout: summaryTranslationTimeout},
+	}, nil
+}
+
+// translate posts the text to translate to the endpoint, and returns the
+// translated text of its response.
+func (t *httpSummaryTranslator) translate(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	body, err := json.Marshal(httpSummaryTranslationRequest{Text: text, SourceLanguage: sourceLanguage, TargetLanguage: targetLanguage})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal translation request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translation request returned status %d", resp.StatusCode)
+	}
+
+	var translation httpSummaryTranslationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&translation); err != nil {
+		return "", fmt.Errorf("failed to decode translation response: %w", err)
+	}
+	if translation.TranslatedText == "" {
+		return "", fmt.Errorf("translation response has no translated_text")
+	}
+	return translation.TranslatedText, nil
+}
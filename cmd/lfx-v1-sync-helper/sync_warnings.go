@@ -0,0 +1,80 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Conversion warnings. When a v1 value cannot be converted (e.g. an
+// unparsable date), the conversion logs a warning and leaves the v2 field
+// empty. Payloads embedding syncWarnings also collect these warnings, which
+// are sent in the _sync_warnings field of the indexed document when
+// INDEXER_SYNC_WARNINGS is enabled, so downstream data-quality dashboards can
+// quantify the mapping losses.
+
+import (
+	"context"
+	"fmt"
+)
+
+var conversionWarnings = newCounterVec(
+	"v1_sync_helper_conversion_warnings_total",
+	"Number of v1 values which could not be converted and were left empty in indexer messages, by subject.",
+	"subject",
+)
+
+// syncWarning describes a v1 value which could not be converted.
+type syncWarning struct {
+	// Field is the v1 field, e.g. "created_at" or "sessions[0].join_time".
+	Field string `json:"field"`
+	// Value is the unconverted v1 value.
+	Value string `json:"value,omitempty"`
+	// Error is the conversion error.
+	Error string `json:"error"`
+}
+
+// syncWarnings collects the conversion warnings of an indexer payload. It is
+// embedded in the payload structs.
+type syncWarnings struct {
+	SyncWarnings []syncWarning `json:"_sync_warnings,omitempty"`
+}
+
+// syncWarner is implemented by payloads embedding syncWarnings.
+type syncWarner interface {
+	syncWarningCount() int
+	clearSyncWarnings()
+}
+
+// addSyncWarning records that a v1 field value could not be converted.
+func (w *syncWarnings) addSyncWarning(field, value string, err error) {
+	w.SyncWarnings = append(w.SyncWarnings, syncWarning{Field: field, Value: value, Error: err.Error()})
+}
+
+// syncWarningCount returns the number of warnings collected.
+func (w *syncWarnings) syncWarningCount() int {
+	return len(w.SyncWarnings)
+}
+
+// clearSyncWarnings drops the collected warnings.
+func (w *syncWarnings) clearSyncWarnings() {
+	w.SyncWarnings = nil
+}
+
+// applySyncWarnings counts the conversion warnings of an indexer payload, and
+// drops them from the payload unless they are enabled.
+func applySyncWarnings(ctx context.Context, subject string, data any) {
+	warner, ok := data.(syncWarner)
+	if !ok {
+		return
+	}
+	if count := warner.syncWarningCount(); count > 0 {
+		conversionWarnings.add(uint64(count), subject)
+	}
+	if !contextSettings(ctx).IndexerSyncWarnings {
+		warner.clearSyncWarnings()
+	}
+}
+
+// sessionField returns the warning field name of a participant session field.
+func sessionField(index int, field string) string {
+	return fmt.Sprintf("sessions[%d].%s", index, field)
+}
@@ -0,0 +1,220 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Background task supervision. The consumer supervision loops and the
+// periodic background jobs (drift checks, parked record sweeps, etc.) run in
+// their own goroutines for the life of the service; one returning early or
+// panicking would silently degrade the service (or, for a panic, crash it).
+// They run as named tasks instead, restarted with exponential backoff by
+// their restart policy. A task which exhausts its restarts is marked failed,
+// which fails /readyz; task states are reported by /statusz. One-shot tasks
+// (e.g. the Zoom backfill) are only restarted if they panic, and are marked
+// completed once they return.
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Task states.
+const (
+	taskRunning    = "running"
+	taskRestarting = "restarting"
+	taskFailed     = "failed"
+	taskStopped    = "stopped"
+	taskCompleted  = "completed"
+)
+
+// taskRestartPolicy is how a task is restarted when it exits before the
+// service shuts down.
+type taskRestartPolicy struct {
+	// maxRestarts is how many consecutive restarts are attempted before the
+	// task is marked failed; 0 restarts it indefinitely.
+	maxRestarts int
+	// backoff is the delay before the first restart, doubled on each
+	// consecutive restart up to maxBackoff.
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// resetAfter is how long a task must run for its consecutive restarts to
+	// be reset.
+	resetAfter time.Duration
+	// oneShot marks a task which completes when it returns, and is only
+	// restarted if it panics.
+	oneShot bool
+}
+
+// defaultTaskRestartPolicy restarts a task up to 5 consecutive times, from 1s
+// to 1m apart.
+var defaultTaskRestartPolicy = taskRestartPolicy{
+	maxRestarts: 5,
+	backoff:     time.Second,
+	maxBackoff:  time.Minute,
+	resetAfter:  5 * time.Minute,
+}
+
+// oneShotTaskRestartPolicy restarts a one-shot task which panics like the
+// default policy, and completes it when it returns.
+var oneShotTaskRestartPolicy = taskRestartPolicy{
+	maxRestarts: 5,
+	backoff:     time.Second,
+	maxBackoff:  time.Minute,
+	resetAfter:  5 * time.Minute,
+	oneShot:     true,
+}
+
+var taskRestarts = newCounterVec(
+	"v1_sync_helper_task_restarts_total",
+	"Number of background task restarts, by task and reason (exit or panic).",
+	"task", "reason",
+)
+
+var _ = newGaugeFunc(
+	"v1_sync_helper_task_up",
+	"Whether a background task is running (1) or restarting, failed, stopped, or completed (0), by task.",
+	func() []gaugeSample {
+		var samples []gaugeSample
+		for _, status := range backgroundTasks.statuses() {
+			value := 0.0
+			if status.State == taskRunning {
+				value = 1
+			}
+			samples = append(samples, gaugeSample{labelValues: []string{status.Name}, value: value})
+		}
+		return samples
+	},
+	"task",
+)
+
+// backgroundTasks supervises the background tasks of the service.
+var backgroundTasks = &taskSupervisor{tasks: map[string]*taskStatus{}}
+
+// taskSupervisor runs named tasks, restarting them by their policy.
+type taskSupervisor struct {
+	mu    sync.Mutex
+	tasks map[string]*taskStatus
+}
+
+// taskStatus is the state of a task, as reported by /statusz.
+type taskStatus struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// start runs a task in the background until the context is cancelled. A task
+// returning (or panicking) before then is restarted by the policy.
+func (s *taskSupervisor) start(ctx context.Context, name string, policy taskRestartPolicy, run func(ctx context.Context)) {
+	s.setState(name, taskRunning, "")
+	go s.supervise(ctx, name, policy, run)
+}
+
+// supervise runs a task, restarting it by the policy until the context is
+// cancelled or its restarts are exhausted.
+func (s *taskSupervisor) supervise(ctx context.Context, name string, policy taskRestartPolicy, run func(ctx context.Context)) {
+	log := logger.With("task", name)
+	consecutive := 0
+	for {
+		started := time.Now()
+		reason, err := runTask(ctx, run)
+		if ctx.Err() != nil {
+			s.setState(name, taskStopped, "")
+			return
+		}
+		if policy.oneShot && reason == "exit" {
+			s.setState(name, taskCompleted, "")
+			return
+		}
+
+		if time.Since(started) >= policy.resetAfter {
+			consecutive = 0
+		}
+		consecutive++
+		taskRestarts.inc(name, reason)
+		if policy.maxRestarts > 0 && consecutive > policy.maxRestarts {
+			log.With(errKey, err, "restarts", consecutive-1).ErrorContext(ctx, "background task failed after exhausting its restarts")
+			s.setState(name, taskFailed, err.Error())
+			return
+		}
+
+		delay := min(policy.backoff<<(consecutive-1), policy.maxBackoff)
+		log.With(errKey, err, "attempt", consecutive, "delay", delay).WarnContext(ctx, "background task exited, restarting it")
+		s.setState(name, taskRestarting, err.Error())
+		if sleepContext(ctx, delay) != nil {
+			s.setState(name, taskStopped, "")
+			return
+		}
+		s.setState(name, taskRunning, "")
+	}
+}
+
+// runTask runs a task once, recovering panics. It returns why the task
+// stopped (exit or panic), with an error describing it.
+func runTask(ctx context.Context, run func(ctx context.Context)) (reason string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			reason = "panic"
+			err = fmt.Errorf("panic: %v", recovered)
+			logger.With(errKey, err, "stack", string(debug.Stack())).ErrorContext(ctx, "background task panicked")
+		}
+	}()
+	run(ctx)
+	return "exit", fmt.Errorf("task returned")
+}
+
+// setState records the state of a task. Restarts are counted on entering the
+// restarting state.
+func (s *taskSupervisor) setState(name, state, lastError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.tasks[name]
+	if !ok {
+		status = &taskStatus{Name: name}
+		s.tasks[name] = status
+	}
+	switch state {
+	case taskRunning:
+		status.StartedAt = time.Now().UTC()
+	case taskRestarting:
+		status.Restarts++
+	}
+	status.State = state
+	if lastError != "" {
+		status.LastError = lastError
+	}
+}
+
+// statuses returns the states of the tasks, sorted by name.
+func (s *taskSupervisor) statuses() []taskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]taskStatus, 0, len(s.tasks))
+	for _, status := range s.tasks {
+		statuses = append(statuses, *status)
+	}
+	slices.SortFunc(statuses, func(a, b taskStatus) int { return strings.Compare(a.Name, b.Name) })
+	return statuses
+}
+
+// readiness returns an error naming the failed tasks, if any, for /readyz.
+func (s *taskSupervisor) readiness() error {
+	var failed []string
+	for _, status := range s.statuses() {
+		if status.State == taskFailed {
+			failed = append(failed, status.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("background tasks failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
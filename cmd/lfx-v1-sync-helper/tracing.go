@@ -0,0 +1,187 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// OpenTelemetry tracing. With OTEL_EXPORTER_OTLP_ENDPOINT set, the processing
+// of each KV message is traced: a consumer span starts when the message is
+// fetched (joining the trace of its headers, if any), a span per handler
+// covers the sync of the record, and a producer span per published message
+// covers its publish. The W3C trace context of the publishing span is
+// injected into the NATS headers of the published messages, and into the
+// headers of the indexer message envelopes, so the indexer and fga-sync can
+// join the trace. Spans are exported over OTLP/HTTP to the /v1/traces path of
+// the endpoint, sampled per OTEL_TRACES_SAMPLER_ARG (the ratio of new traces
+// sampled; joined traces follow their parent). Without an endpoint, the
+// tracer is a no-op.
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName is the instrumentation scope of the service spans.
+	tracerName = "github.com/linuxfoundation/lfx-v1-sync-helper"
+
+	// defaultOTELServiceName is the default service name of the spans.
+	defaultOTELServiceName = "lfx-v1-sync-helper"
+)
+
+// tracer creates the service spans, from the global tracer provider.
+var tracer = otel.Tracer(tracerName)
+
+// natsHeaderCarrier carries the trace context in NATS message headers, whose
+// keys, unlike HTTP headers, are case-sensitive and kept as propagated (e.g.
+// traceparent).
+type natsHeaderCarrier nats.Header
+
+// Get returns the value of a header.
+func (c natsHeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+// Set sets the value of a header.
+func (c natsHeaderCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+// Keys returns the keys of the headers.
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// parseOTLPHeaders parses OTEL_EXPORTER_OTLP_HEADERS, a comma-separated list
+// of key=value headers sent with exported spans, with URL-encoded values, e.g.
+// authorization=Bearer%20<token>.
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	headers := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		key, headerValue, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS entry %q must be key=value", entry)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(headerValue))
+		if err != nil {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS value of %s is not URL-encoded: %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+// initTracing installs the OTLP exporting tracer provider, if an endpoint is
+// configured, and returns the function flushing and stopping it.
+func initTracing(ctx context.Context, cfg *Config) (func(context.Context) error, error) {
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTLPEndpoint, "/") + "/v1/traces")}
+	if len(cfg.OTLPHeaders) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(cfg.OTLPHeaders))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", cfg.OTELServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTEL resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.OTELSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// startMessageSpan starts the consumer span of a KV message from a consumer,
+// joining the trace context of its headers.
+func startMessageSpan(ctx context.Context, headers nats.Header, consumer, key string) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, natsHeaderCarrier(headers))
+	return tracer.Start(ctx, "process "+kvObjectType(key),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.consumer.group.name", consumer),
+			attribute.String("v1.key", key),
+			attribute.String("v1.object_type", kvObjectType(key)),
+		),
+	)
+}
+
+// startHandlerSpan starts the span of the handler of a v1 record.
+func startHandlerSpan(ctx context.Context, operation, key string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation+" "+kvObjectType(key), trace.WithAttributes(attribute.String("v1.key", key)))
+}
+
+// endRetrySpan ends a span, marking it as an error when its message or
+// record is retried.
+func endRetrySpan(span trace.Span, retry bool) {
+	span.SetAttributes(attribute.Bool("retry", retry))
+	if retry {
+		span.SetStatus(codes.Error, "retry requested")
+	}
+	span.End()
+}
+
+// startPublishSpan starts the producer span of a message published to a
+// subject, and injects its trace context into the message headers, leaving
+// them unset while tracing is disabled.
+func startPublishSpan(ctx context.Context, msg *nats.Msg) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, "publish "+msg.Subject,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", msg.Subject),
+		),
+	)
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for key, value := range carrier {
+		if msg.Header == nil {
+			msg.Header = nats.Header{}
+		}
+		msg.Header.Set(key, value)
+	}
+	return ctx, span
+}
+
+// endSpan ends a span, recording the error it ended with, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext adds the trace context of the context to the headers of
+// an indexer message envelope.
+func injectTraceContext(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
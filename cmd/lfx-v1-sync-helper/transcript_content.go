@@ -0,0 +1,410 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Transcript content indexing. Transcripts are indexed with links to their
+// files only, so their text is not searchable. With
+// TRANSCRIPT_CONTENT_ENABLED set, the TRANSCRIPT (VTT) file of a synced
+// recording is downloaded from Zoom (up to TRANSCRIPT_CONTENT_MAX_BYTES, and
+// at most TRANSCRIPT_CONTENT_DOWNLOADS_PER_MINUTE downloads per replica), its
+// cue text extracted and split into chunks, and each chunk indexed as a
+// transcript content document referencing its parent transcript. The file
+// last indexed and its number of chunks are kept in the mappings bucket, so
+// unchanged files are not downloaded again, and stale chunks are deleted.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// transcriptContentKeyFmt is the mappings KV key format of the indexed
+	// transcript content of a recording, by meeting and occurrence ID.
+	transcriptContentKeyFmt = "v1_past_meeting_transcript_contents.%s"
+
+	// defaultTranscriptContentMaxBytes is the default size cap of downloaded
+	// transcript files.
+	defaultTranscriptContentMaxBytes = 5 << 20
+
+	// defaultTranscriptContentDownloadsPerMinute is the default rate limit of
+	// transcript file downloads.
+	defaultTranscriptContentDownloadsPerMinute = 30
+
+	// transcriptContentChunkChars is the size above which transcript text is
+	// split into another chunk, at a cue boundary.
+	transcriptContentChunkChars = 4000
+
+	// transcriptContentMaxChunks bounds the chunks indexed per transcript.
+	transcriptContentMaxChunks = 500
+
+	// transcriptContentRateWait is how long a download waits for the rate
+	// limit before the recording is retried later.
+	transcriptContentRateWait = 10 * time.Second
+)
+
+var transcriptContents = newCounterVec(
+	"v1_sync_helper_transcript_contents_total",
+	"Number of transcript files processed for content indexing, by result (indexed, unchanged, oversize, rate_limited, or error).",
+	"result",
+)
+
+// pastMeetingTranscriptContentInput is a chunk of the text of a transcript,
+// indexed as a transcript content document.
+type pastMeetingTranscriptContentInput struct {
+	// ID is the ID of the chunk: the transcript UID and the chunk index.
+	ID string `json:"id"`
+
+	// TranscriptUID is the UID of the parent transcript.
+	TranscriptUID string `json:"transcript_uid"`
+
+	// MeetingAndOccurrenceID is the ID of the past meeting of the transcript.
+	MeetingAndOccurrenceID string `json:"meeting_and_occurrence_id"`
+
+	// ProjectUID is the UID of the project of the transcript.
+	ProjectUID string `json:"project_uid"`
+
+	// FileID is the Zoom ID of the transcript file.
+	FileID string `json:"file_id"`
+
+	// ChunkIndex is the position of the chunk in the transcript, from 0.
+	ChunkIndex int `json:"chunk_index"`
+
+	// ChunkCount is the number of chunks of the transcript.
+	ChunkCount int `json:"chunk_count"`
+
+	// StartOffset and EndOffset are the VTT timestamps of the first and last
+	// cues of the chunk, relative to the start of the recording.
+	StartOffset string `json:"start_offset"`
+	EndOffset   string `json:"end_offset"`
+
+	// Content is the text of the chunk's cues, one per line.
+	Content string `json:"content"`
+
+	// TranscriptAccess is the access of the parent transcript.
+	TranscriptAccess string `json:"transcript_access"`
+}
+
+// transcriptContentState is the transcript file last indexed for a recording.
+type transcriptContentState struct {
+	FileID     string `json:"file_id"`
+	ChunkCount int    `json:"chunk_count"`
+}
+
+// transcriptCue is a cue of a VTT transcript.
+type transcriptCue struct {
+	start, end string
+	text       string
+}
+
+// transcriptContentID returns the ID of a chunk of a transcript.
+func transcriptContentID(transcriptUID string, chunkIndex int) string {
+	return fmt.Sprintf("%s-%d", transcriptUID, chunkIndex)
+}
+
+// errTranscriptOversize is returned for transcript files over the size cap.
+var errTranscriptOversize = errors.New("transcript file over the size cap")
+
+// transcriptDownloads rate limits the transcript file downloads.
+var transcriptDownloads transcriptDownloadLimiter
+
+// transcriptDownloadLimiter spaces transcript file downloads evenly, at
+// TRANSCRIPT_CONTENT_DOWNLOADS_PER_MINUTE.
+type transcriptDownloadLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait waits for the next download slot, up to maxWait, and reports whether
+// one was reserved.
+func (l *transcriptDownloadLimiter) wait(ctx context.Context, maxWait time.Duration) bool {
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	if slot.Sub(now) > maxWait {
+		l.mu.Unlock()
+		return false
+	}
+	l.next = slot.Add(time.Minute / time.Duration(settings().TranscriptContentDownloadsPerMinute))
+	l.mu.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// indexPastMeetingTranscriptContent indexes the text of the transcript file
+// of a recording, if enabled, and deletes the chunks no longer part of it.
+// Returns true if the recording should be retried (e.g. when rate limited).
+func indexPastMeetingTranscriptContent(ctx context.Context, recording *pastMeetingRecordingInput) bool {
+	if !cfg.TranscriptContentEnabled {
+		return false
+	}
+	funcLogger := logger.With("meeting_and_occurrence_id", recording.MeetingAndOccurrenceID)
+
+	var file *ZoomPastMeetingRecordingFile
+	for i := range recording.RecordingFiles {
+		if recording.RecordingFiles[i].FileType == "TRANSCRIPT" && recording.RecordingFiles[i].DownloadURL != "" {
+			file = &recording.RecordingFiles[i]
+			break
+		}
+	}
+
+	previous, err := getTranscriptContentState(ctx, recording.MeetingAndOccurrenceID)
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to get transcript content state")
+		return true
+	}
+	if file == nil {
+		// The transcript file was removed: delete its chunks.
+		if previous.ChunkCount > 0 {
+			return replaceTranscriptContent(ctx, recording, previous, transcriptContentState{}, nil)
+		}
+		return false
+	}
+	if previous.FileID == file.ID {
+		transcriptContents.inc("unchanged")
+		return false
+	}
+	if !transcriptDownloads.wait(ctx, transcriptContentRateWait) {
+		transcriptContents.inc("rate_limited")
+		funcLogger.DebugContext(ctx, "transcript download rate limited, retrying later")
+		return true
+	}
+	data, err := downloadZoomFile(ctx, file.DownloadURL, cfg.TranscriptContentMaxBytes)
+	if errors.Is(err, errTranscriptOversize) {
+		transcriptContents.inc("oversize")
+		funcLogger.With("file_id", file.ID).WarnContext(ctx, "transcript file over the size cap, skipping content indexing")
+		return false
+	}
+	if err != nil {
+		transcriptContents.inc("error")
+		funcLogger.With(errKey, err, "file_id", file.ID).ErrorContext(ctx, "failed to download transcript file")
+		return false
+	}
+
+	chunks := chunkTranscript(parseVTT(string(data)))
+	next := transcriptContentState{FileID: file.ID, ChunkCount: len(chunks)}
+	return replaceTranscriptContent(ctx, recording, previous, next, chunks)
+}
+
+// replaceTranscriptContent indexes the chunks of a transcript, deletes the
+// chunks of the previous file beyond them, and stores the new state.
+func replaceTranscriptContent(ctx context.Context, recording *pastMeetingRecordingInput, previous, next transcriptContentState, chunks []pastMeetingTranscriptContentInput) bool {
+	funcLogger := logger.With("meeting_and_occurrence_id", recording.MeetingAndOccurrenceID, "chunks", len(chunks))
+	transcriptUID := recording.ID
+
+	for i := range chunks {
+		chunk := &chunks[i]
+		chunk.ID = transcriptContentID(transcriptUID, i)
+		chunk.TranscriptUID = transcriptUID
+		chunk.MeetingAndOccurrenceID = recording.MeetingAndOccurrenceID
+		chunk.ProjectUID = recording.ProjectUID
+		chunk.FileID = next.FileID
+		chunk.ChunkIndex = i
+		chunk.ChunkCount = len(chunks)
+		chunk.TranscriptAccess = recording.TranscriptAccess
+
+		action := MessageActionCreated
+		if i < previous.ChunkCount {
+			action = MessageActionUpdated
+		}
+		tags := []string{
+			chunk.ID,
+			fmt.Sprintf("past_meeting_transcript_content_id:%s", chunk.ID),
+			fmt.Sprintf("past_meeting_transcript_id:%s", transcriptUID),
+			fmt.Sprintf("meeting_and_occurrence_id:%s", recording.MeetingAndOccurrenceID),
+		}
+		if err := sendIndexerMessage(ctx, IndexV1PastMeetingTranscriptContentSubject, action, chunk, tags); err != nil {
+			transcriptContents.inc("error")
+			funcLogger.With(errKey, err, "chunk_index", i).ErrorContext(ctx, "failed to send transcript content indexer message")
+			return true
+		}
+	}
+
+	if retry := deleteTranscriptContentChunks(ctx, transcriptUID, len(chunks), previous.ChunkCount); retry {
+		return true
+	}
+
+	if err := putTranscriptContentState(ctx, recording.MeetingAndOccurrenceID, next); err != nil {
+		funcLogger.With(errKey, err).WarnContext(ctx, "failed to store transcript content state")
+	}
+	if len(chunks) > 0 {
+		transcriptContents.inc("indexed")
+	}
+	funcLogger.InfoContext(ctx, "indexed transcript content")
+	return false
+}
+
+// deleteTranscriptContentChunks deletes the chunks of a transcript from index
+// from up to index to (excluded). Returns true if it should be retried.
+func deleteTranscriptContentChunks(ctx context.Context, transcriptUID string, from, to int) bool {
+	for i := from; i < to; i++ {
+		id := transcriptContentID(transcriptUID, i)
+		if err := sendIndexerMessage(ctx, IndexV1PastMeetingTranscriptContentSubject, MessageActionDeleted, id, []string{}); err != nil {
+			logger.With(errKey, err, "id", id).ErrorContext(ctx, "failed to send transcript content delete indexer message")
+			return true
+		}
+	}
+	return false
+}
+
+// deletePastMeetingTranscriptContent deletes the indexed transcript content
+// of a deleted recording. Returns true if it should be retried.
+func deletePastMeetingTranscriptContent(ctx context.Context, meetingAndOccurrenceID string) bool {
+	state, err := getTranscriptContentState(ctx, meetingAndOccurrenceID)
+	if err != nil {
+		logger.With(errKey, err, "meeting_and_occurrence_id", meetingAndOccurrenceID).ErrorContext(ctx, "failed to get transcript content state")
+		return true
+	}
+	if state.ChunkCount == 0 {
+		return false
+	}
+	// The transcript UID is the meeting and occurrence ID of its recording.
+	if retry := deleteTranscriptContentChunks(ctx, meetingAndOccurrenceID, 0, state.ChunkCount); retry {
+		return true
+	}
+	if err := mappingsKV.Purge(ctx, fmt.Sprintf(transcriptContentKeyFmt, meetingAndOccurrenceID)); err != nil {
+		logger.With(errKey, err, "meeting_and_occurrence_id", meetingAndOccurrenceID).WarnContext(ctx, "failed to clear transcript content state")
+	}
+	return false
+}
+
+// getTranscriptContentState returns the transcript file last indexed for a
+// recording, or a zero state.
+func getTranscriptContentState(ctx context.Context, meetingAndOccurrenceID string) (transcriptContentState, error) {
+	var state transcriptContentState
+	entry, err := mappingsKV.Get(ctx, fmt.Sprintf(transcriptContentKeyFmt, meetingAndOccurrenceID))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(entry.Value(), &state); err != nil {
+		return state, fmt.Errorf("failed to unmarshal transcript content state: %w", err)
+	}
+	return state, nil
+}
+
+// putTranscriptContentState stores the transcript file last indexed for a
+// recording.
+func putTranscriptContentState(ctx context.Context, meetingAndOccurrenceID string, state transcriptContentState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript content state: %w", err)
+	}
+	_, err = mappingsKV.Put(ctx, fmt.Sprintf(transcriptContentKeyFmt, meetingAndOccurrenceID), value)
+	return err
+}
+
+// downloadZoomFile downloads a Zoom recording file, failing with
+// errTranscriptOversize if it is larger than maxBytes.
+func downloadZoomFile(ctx context.Context, downloadURL string, maxBytes int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := zoomHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("zoom file download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > maxBytes {
+		return nil, errTranscriptOversize
+	}
+	return data, nil
+}
+
+// parseVTT returns the cues of a WebVTT transcript, skipping its header and
+// NOTE, STYLE, and REGION blocks.
+func parseVTT(vtt string) []transcriptCue {
+	var cues []transcriptCue
+	vtt = strings.ReplaceAll(vtt, "\r\n", "\n")
+	for _, block := range strings.Split(vtt, "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing == -1 {
+			continue
+		}
+		start, end, _ := strings.Cut(lines[timing], "-->")
+		text := strings.TrimSpace(strings.Join(lines[timing+1:], " "))
+		if text == "" {
+			continue
+		}
+		// Drop the cue settings following the end timestamp.
+		endFields := strings.Fields(end)
+		if len(endFields) == 0 {
+			continue
+		}
+		cues = append(cues, transcriptCue{start: strings.TrimSpace(start), end: endFields[0], text: text})
+	}
+	return cues
+}
+
+// chunkTranscript splits transcript cues into chunks of about
+// transcriptContentChunkChars, at cue boundaries, up to
+// transcriptContentMaxChunks.
+func chunkTranscript(cues []transcriptCue) []pastMeetingTranscriptContentInput {
+	var chunks []pastMeetingTranscriptContentInput
+	var content strings.Builder
+	var start, end string
+	flush := func() {
+		if content.Len() == 0 {
+			return
+		}
+		chunks = append(chunks, pastMeetingTranscriptContentInput{StartOffset: start, EndOffset: end, Content: content.String()})
+		content.Reset()
+	}
+	for _, cue := range cues {
+		if content.Len() > 0 && content.Len()+len(cue.text)+1 > transcriptContentChunkChars {
+			flush()
+			if len(chunks) == transcriptContentMaxChunks {
+				return chunks
+			}
+		}
+		if content.Len() == 0 {
+			start = cue.start
+		} else {
+			content.WriteByte('\n')
+		}
+		content.WriteString(cue.text)
+		end = cue.end
+	}
+	if len(chunks) < transcriptContentMaxChunks {
+		flush()
+	}
+	return chunks
+}
@@ -0,0 +1,246 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// v1 user merges. When v1 merges duplicate user accounts, the merged
+// salesforce-merged_user record is marked with the ID of the surviving account
+// in masterrecordid. The merged ID is then stored as an alias of the surviving
+// one, and user lookups and the registrant, invitee, and attendee handlers
+// resolve aliased IDs to the surviving account. Records referencing each user
+// ID are kept in a per-user index, so a merge re-syncs them: their access is
+// granted to the surviving account and removed from the merged one.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// maxMergedUserAliasDepth bounds the alias chain followed when resolving a
+// merged user ID, in case accounts were merged in turn (or an alias loops).
+const maxMergedUserAliasDepth = 5
+
+var userMergeResyncs = newCounterVec(
+	"v1_sync_helper_user_merge_resyncs_total",
+	"Number of records re-synced after v1 user merges, by result (success, retry, or skipped).",
+	"result",
+)
+
+// mergedUserReferenceDependencies are the parent mappings required by the
+// records re-synced after a user merge, by key prefix.
+var mergedUserReferenceDependencies = map[string][]mappingDependency{
+	"itx-zoom-meetings-registrants-v2": meetingChildDependencies,
+	"itx-zoom-meetings-registrants-v3": meetingChildDependencies,
+	"itx-zoom-past-meetings-invitees":  pastMeetingChildDependencies,
+	"itx-zoom-past-meetings-attendees": pastMeetingChildDependencies,
+}
+
+// mergedUserAliasKey returns the v1-mappings key holding the surviving user ID
+// of a merged v1 user.
+func mergedUserAliasKey(userID string) string {
+	return fmt.Sprintf("v1-merged-user.alias.%s", userID)
+}
+
+// userReferenceIndexPrefix returns the v1-mappings key prefix of the index of
+// the v1-objects records referencing a v1 user ID, followed by their keys.
+func userReferenceIndexPrefix(userID string) string {
+	return fmt.Sprintf("v1_user_reference_index.%s", userID)
+}
+
+// resolveMergedV1UserID returns the ID of the surviving account of a merged v1
+// user, or the user ID itself if it was not merged (or cannot be resolved).
+func resolveMergedV1UserID(ctx context.Context, userID string) string {
+	resolved := userID
+	for depth := 0; depth < maxMergedUserAliasDepth && resolved != ""; depth++ {
+		entry, err := mappingsKV.Get(ctx, mergedUserAliasKey(resolved))
+		if err != nil {
+			if !errors.Is(err, jetstream.ErrKeyNotFound) {
+				logger.With(errKey, err, "user_id", resolved).WarnContext(ctx, "failed to get merged v1 user alias")
+			}
+			return resolved
+		}
+		survivorID := string(entry.Value())
+		if survivorID == "" || survivorID == resolved || isTombstonedMapping(entry.Value()) {
+			return resolved
+		}
+		resolved = survivorID
+	}
+	return resolved
+}
+
+// mergedV1Username returns the username of the surviving account of a merged
+// v1 user, or an empty string if the user ID was not merged.
+func mergedV1Username(ctx context.Context, userID string) string {
+	if userID == "" {
+		return ""
+	}
+	survivorID := resolveMergedV1UserID(ctx, userID)
+	if survivorID == userID {
+		return ""
+	}
+	survivor, err := lookupV1User(ctx, survivorID)
+	if err != nil {
+		logger.With(errKey, err, "user_id", userID, "surviving_user_id", survivorID).
+			WarnContext(ctx, "failed to lookup surviving v1 user of merged user")
+		return ""
+	}
+	return survivor.Username
+}
+
+// recordV1UserReference adds a v1-objects record key to the references of a
+// v1 user ID, so the record is re-synced if the user is merged.
+func recordV1UserReference(ctx context.Context, userID, key string) {
+	if userID == "" {
+		return
+	}
+	if _, err := addKeyIndexMember(ctx, userReferenceIndexPrefix(userID), key); err != nil {
+		logger.With(errKey, err, "user_id", userID, "key", key).WarnContext(ctx, "failed to index v1 user reference")
+	}
+}
+
+// handleMergedUserUpdate processes salesforce-merged_user records. Records
+// are otherwise used on-demand during user lookups; a record merged into
+// another account aliases its ID to the surviving account and re-syncs the
+// records referencing it.
+// Returns true if the operation should be retried, false otherwise.
+func handleMergedUserUpdate(ctx context.Context, key string, v1Data map[string]any) bool {
+	funcLogger := logger.With("key", key)
+
+	userID, _ := v1Data["sfid"].(string)
+	if userID == "" {
+		_, userID, _ = strings.Cut(key, ".")
+	}
+	survivorID, _ := v1Data["masterrecordid"].(string)
+	if userID == "" || survivorID == "" || survivorID == userID {
+		funcLogger.DebugContext(ctx, "salesforce-merged_user record updated")
+		return false
+	}
+	funcLogger = funcLogger.With("user_id", userID, "surviving_user_id", survivorID)
+
+	if _, err := mappingsKV.Put(ctx, mergedUserAliasKey(userID), []byte(survivorID)); err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to store merged v1 user alias")
+		return true
+	}
+	funcLogger.InfoContext(ctx, "v1 user merged, re-syncing records referencing the merged user")
+
+	// The merged account's username, for records which do not carry one.
+	mergedUsername, _ := v1Data["username__c"].(string)
+	return resyncMergedUserReferences(ctx, userID, mergedUsername)
+}
+
+// resyncMergedUserReferences re-runs the handlers of the records referencing
+// a merged v1 user, so their access follows the surviving account, and
+// removes the merged account's past meeting participant access.
+// Returns true if any record requested a retry.
+func resyncMergedUserReferences(ctx context.Context, userID, mergedUsername string) bool {
+	funcLogger := logger.With("user_id", userID)
+
+	keys, err := listKeyIndex(ctx, userReferenceIndexPrefix(userID))
+	if err != nil {
+		funcLogger.With(errKey, err).ErrorContext(ctx, "failed to list merged v1 user references")
+		return true
+	}
+	if len(keys) == 0 {
+		funcLogger.DebugContext(ctx, "no records reference the merged v1 user")
+		return false
+	}
+
+	survivorUsername := mergedV1Username(ctx, userID)
+	retry := false
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return true
+		}
+		recordData, exists, err := getV1ObjectData(ctx, key)
+		if err != nil {
+			funcLogger.With(errKey, err, "key", key).WarnContext(ctx, "failed to get record referencing merged v1 user")
+			retry = true
+			continue
+		}
+		if !exists {
+			userMergeResyncs.inc("skipped")
+			continue
+		}
+
+		// Records whose parent mapping is missing are parked, and re-synced
+		// with the surviving user when released.
+		tablePrefix, _, _ := strings.Cut(key, ".")
+		if stop, parkRetry := checkMappingDependencies(ctx, tablePrefix, key, mergedUserReferenceDependencies[tablePrefix], recordData); stop {
+			if parkRetry {
+				userMergeResyncs.inc("retry")
+				retry = true
+			} else {
+				userMergeResyncs.inc("skipped")
+			}
+			continue
+		}
+
+		recordCtx := withRecordAccessProject(ctx, key, recordData)
+		var recordRetry bool
+		switch tablePrefix {
+		case "itx-zoom-meetings-registrants-v2", "itx-zoom-meetings-registrants-v3":
+			// The registrant handler removes the previous user's access on a
+			// username change.
+			recordRetry = handleZoomMeetingRegistrantUpdate(recordCtx, key, recordData)
+		case "itx-zoom-past-meetings-invitees":
+			recordRetry = handleZoomPastMeetingInviteeUpdate(recordCtx, key, recordData)
+			if !recordRetry {
+				recordRetry = removeMergedParticipantAccess(recordCtx, recordData, mergedUsername, survivorUsername, true)
+			}
+		case "itx-zoom-past-meetings-attendees":
+			recordRetry = handleZoomPastMeetingAttendeeUpdate(recordCtx, key, recordData)
+			if !recordRetry {
+				recordRetry = removeMergedParticipantAccess(recordCtx, recordData, mergedUsername, survivorUsername, false)
+			}
+		default:
+			userMergeResyncs.inc("skipped")
+			continue
+		}
+
+		if recordRetry {
+			userMergeResyncs.inc("retry")
+			retry = true
+			continue
+		}
+		userMergeResyncs.inc("success")
+	}
+	return retry
+}
+
+// removeMergedParticipantAccess removes the past meeting participant access
+// of a merged v1 user's account, once the participant record was re-synced
+// for the surviving account.
+// Returns true if the operation should be retried, false otherwise.
+func removeMergedParticipantAccess(ctx context.Context, recordData map[string]any, mergedUsername, survivorUsername string, isInvitee bool) bool {
+	username, _ := recordData["lf_sso"].(string)
+	if username == "" {
+		username = mergedUsername
+	}
+	meetingAndOccurrenceID, _ := recordData["meeting_and_occurrence_id"].(string)
+	if username == "" || username == survivorUsername || meetingAndOccurrenceID == "" {
+		return false
+	}
+
+	accessMsgBytes, err := json.Marshal(PastMeetingParticipantAccessMessage{
+		MeetingAndOccurrenceID: meetingAndOccurrenceID,
+		Username:               mapUsernameToAuthSub(username),
+		IsInvited:              isInvitee,
+		IsAttended:             !isInvitee,
+	})
+	if err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to marshal merged participant access message")
+		return false
+	}
+	if err := sendAccessMessage(ctx, V1PastMeetingParticipantRemoveSubject, accessMsgBytes); err != nil {
+		logger.With(errKey, err, "meeting_and_occurrence_id", meetingAndOccurrenceID).
+			ErrorContext(ctx, "failed to send merged participant remove message")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Mappings verification. The verify-mappings subcommand compares the
+// v1-mappings bucket with the v1-objects bucket, for the mappings keyed by
+// the ID of their v1 record: mappings whose v1 record no longer exists
+// (orphans, e.g. of hard deletes missed while the service was down), and v1
+// records without a mapping (missing, e.g. of records whose sync failed). It
+// prints a JSON report; with -fix, orphan mappings are deleted and the v1
+// records missing a mapping are re-run through the handlers.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// verifiedMappings are the mapping key prefixes checked by verify-mappings,
+// each followed by the ID of a record under one of its v1-objects prefixes.
+var verifiedMappings = []struct {
+	mappingPrefix string
+	v1Prefixes    []string
+}{
+	{"project.sfid.", []string{"salesforce-project__c"}},
+	{"committee.sfid.", []string{"platform-collaboration__c"}},
+	{"committee_member.sfid.", []string{"platform-community__c"}},
+	{"vote.", []string{"itx-poll"}},
+	{"vote_response.", []string{"itx-poll-vote"}},
+	{"survey.", []string{"itx-surveys"}},
+	{"survey_response.", []string{"itx-survey-responses"}},
+	{"v1_meetings.", []string{"itx-zoom-meetings-v2"}},
+	{"v1_meeting_registrants.", []string{"itx-zoom-meetings-registrants-v2", "itx-zoom-meetings-registrants-v3"}},
+	{"v1_invite_responses.", []string{"itx-zoom-meetings-invite-responses-v2"}},
+	{"v1_meeting_attachments.", []string{"itx-zoom-meetings-attachments-v2"}},
+	{"v1_past_meetings.", []string{"itx-zoom-past-meetings"}},
+	{"v1_past_meeting_attendees.", []string{"itx-zoom-past-meetings-attendees"}},
+	{"v1_past_meeting_invitees.", []string{"itx-zoom-past-meetings-invitees"}},
+	{"v1_past_meeting_recordings.", []string{"itx-zoom-past-meetings-recordings"}},
+	{"v1_past_meeting_summaries.", []string{"itx-zoom-past-meetings-summaries"}},
+	{"v1_past_meeting_attachments.", []string{"itx-zoom-past-meetings-attachments"}},
+}
+
+// mappingsReport is the report printed by verify-mappings.
+type mappingsReport struct {
+	Fix    bool                  `json:"fix"`
+	Tables []mappingsTableReport `json:"tables"`
+}
+
+// mappingsTableReport is the verification of a mapping key prefix.
+type mappingsTableReport struct {
+	MappingPrefix string   `json:"mapping_prefix"`
+	V1Prefixes    []string `json:"v1_prefixes"`
+	Mappings      int      `json:"mappings"`
+	Records       int      `json:"records"`
+	// OrphanMappings are the mapping keys whose v1 record no longer exists.
+	OrphanMappings []string `json:"orphan_mappings,omitempty"`
+	// MissingMappings are the v1-objects keys of synced records without a
+	// mapping.
+	MissingMappings []string `json:"missing_mappings,omitempty"`
+	Fixed           int      `json:"fixed,omitempty"`
+	FixFailed       []string `json:"fix_failed,omitempty"`
+}
+
+// runVerifyMappings compares the v1-mappings bucket with the v1-objects
+// bucket, prints the discrepancies, and optionally fixes them. It exits with
+// an error if discrepancies remain.
+func runVerifyMappings(name string, args []string) {
+	var fix *bool
+	var prefix *string
+	p := startSyncProcess(name, args, func(flags *flag.FlagSet) {
+		fix = flags.Bool("fix", false, "delete orphan mappings and re-run the v1 records missing a mapping")
+		prefix = flags.String("prefix", "", "only verify the mappings of these comma-separated v1-objects prefixes, e.g. \"itx-zoom-meetings-v2\"")
+	})
+	ctx := p.ctx
+
+	var only []string
+	for _, v1Prefix := range strings.Split(*prefix, ",") {
+		if v1Prefix = strings.TrimSuffix(strings.TrimSpace(v1Prefix), "."); v1Prefix != "" {
+			only = append(only, v1Prefix)
+		}
+	}
+
+	p.openBuckets()
+
+	report := mappingsReport{Fix: *fix}
+	remaining := 0
+	for _, verified := range verifiedMappings {
+		if len(only) > 0 && !slices.ContainsFunc(verified.v1Prefixes, func(v1Prefix string) bool { return slices.Contains(only, v1Prefix) }) {
+			continue
+		}
+		table, err := verifyMappingTable(ctx, verified.mappingPrefix, verified.v1Prefixes, *fix)
+		if err != nil {
+			logger.With(errKey, err, "mapping_prefix", verified.mappingPrefix).ErrorContext(ctx, "failed to verify mappings")
+			os.Exit(1)
+		}
+		if *fix {
+			remaining += len(table.FixFailed)
+		} else {
+			remaining += len(table.OrphanMappings) + len(table.MissingMappings)
+		}
+		report.Tables = append(report.Tables, table)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.With(errKey, err).ErrorContext(ctx, "failed to write mappings report")
+		os.Exit(1)
+	}
+	logger.With("fix", *fix, "remaining", remaining).InfoContext(ctx, "mappings verification completed")
+	p.shutdown()
+	if remaining > 0 {
+		os.Exit(1)
+	}
+}
+
+// verifyMappingTable compares the mappings under a key prefix with the
+// records under their v1-objects prefixes. Tombstoned mappings are kept as
+// delete markers, so they are neither orphans nor missing. Records which are
+// soft deleted, or which the sync skips (out of scope, or written by v2), are
+// not expected to have a mapping.
+func verifyMappingTable(ctx context.Context, mappingPrefix string, v1Prefixes []string, fix bool) (mappingsTableReport, error) {
+	table := mappingsTableReport{MappingPrefix: mappingPrefix, V1Prefixes: v1Prefixes}
+
+	mappingIDs := map[string]bool{}
+	lister, err := mappingsKV.ListKeysFiltered(ctx, mappingPrefix+">")
+	if err != nil {
+		return table, err
+	}
+	for mappingKey := range lister.Keys() {
+		mappingIDs[strings.TrimPrefix(mappingKey, mappingPrefix)] = true
+	}
+	table.Mappings = len(mappingIDs)
+
+	recordIDs := map[string]bool{}
+	for _, v1Prefix := range v1Prefixes {
+		lister, err := v1KV.ListKeysFiltered(ctx, v1Prefix+".>")
+		if err != nil {
+			return table, err
+		}
+		for key := range lister.Keys() {
+			table.Records++
+			id := strings.TrimPrefix(key, v1Prefix+".")
+			recordIDs[id] = true
+			if !mappingIDs[id] && expectsMapping(ctx, key) {
+				table.MissingMappings = append(table.MissingMappings, key)
+			}
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(mappingIDs)) {
+		if recordIDs[id] {
+			continue
+		}
+		mappingKey := mappingPrefix + id
+		entry, err := mappingsKV.Get(ctx, mappingKey)
+		if err != nil || isTombstonedMapping(entry.Value()) {
+			continue
+		}
+		table.OrphanMappings = append(table.OrphanMappings, mappingKey)
+		if fix {
+			// Delete at the read revision, in case the record was synced again
+			// meanwhile.
+			if err := mappingsKV.Delete(ctx, mappingKey, jetstream.LastRevision(entry.Revision())); err != nil {
+				logger.With(errKey, err, "mapping_key", mappingKey).WarnContext(ctx, "failed to delete orphan mapping")
+				table.FixFailed = append(table.FixFailed, mappingKey)
+				continue
+			}
+			table.Fixed++
+		}
+	}
+
+	if fix {
+		for _, key := range table.MissingMappings {
+			if ctx.Err() != nil {
+				return table, ctx.Err()
+			}
+			entry, err := v1KV.Get(ctx, key)
+			if errors.Is(err, jetstream.ErrKeyNotFound) {
+				// Deleted since it was listed.
+				continue
+			}
+			if err != nil || kvHandler(bootstrap.MessageContext(ctx, nil), entry) {
+				table.FixFailed = append(table.FixFailed, key)
+				continue
+			}
+			table.Fixed++
+		}
+	}
+	return table, nil
+}
+
+// expectsMapping reports whether a v1-objects record without a mapping is
+// expected to have one: it is neither soft deleted nor skipped by the sync.
+// Records which cannot be read or decoded are reported, as the sync fails on
+// them too.
+func expectsMapping(ctx context.Context, key string) bool {
+	entry, err := v1KV.Get(ctx, key)
+	if err != nil {
+		return !errors.Is(err, jetstream.ErrKeyNotFound)
+	}
+	var v1Data map[string]any
+	if err := json.Unmarshal(decodeRecord(key, entry.Value()), &v1Data); err != nil {
+		return true
+	}
+	if deletedAt, exists := v1Data["_sdc_deleted_at"]; exists && deletedAt != nil && deletedAt != "" {
+		return false
+	}
+	return !shouldSkipSync(ctx, v1Data)
+}
@@ -0,0 +1,222 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The lfx-v1-sync-helper service.
+package main
+
+// Zoom API client used for historical past meeting backfill.
+//
+// Authenticates with a Zoom Server-to-Server OAuth app (account credentials
+// grant) and reads past meeting instances, meeting and participant reports,
+// and cloud recordings for a meeting.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	zoomAPIBaseURL  = "https://api.zoom.us/v2"
+	zoomTokenURL    = "https://zoom.us/oauth/token"
+	zoomPageSize    = 300
+	zoomHTTPTimeout = 30 * time.Second
+)
+
+var (
+	zoomHTTPClient *http.Client
+)
+
+// ZoomPastMeetingInstance is a single ended instance of a Zoom meeting.
+type ZoomPastMeetingInstance struct {
+	UUID      string `json:"uuid"`
+	StartTime string `json:"start_time"`
+}
+
+// ZoomMeetingReport is the report of a single ended Zoom meeting instance.
+type ZoomMeetingReport struct {
+	UUID      string `json:"uuid"`
+	ID        int64  `json:"id"`
+	Topic     string `json:"topic"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Duration  int    `json:"duration"`
+}
+
+// ZoomParticipantReport is a single join/leave of a participant in a meeting
+// instance. A participant rejoining the meeting produces another entry.
+type ZoomParticipantReport struct {
+	ID                string `json:"id"`
+	UserID            string `json:"user_id"`
+	ParticipantUserID string `json:"participant_user_id"`
+	Name              string `json:"name"`
+	UserEmail         string `json:"user_email"`
+	RegistrantID      string `json:"registrant_id"`
+	JoinTime          string `json:"join_time"`
+	LeaveTime         string `json:"leave_time"`
+	Duration          int    `json:"duration"`
+}
+
+// ZoomRecordingFile is a single file of a Zoom cloud recording.
+type ZoomRecordingFile struct {
+	ID             string `json:"id"`
+	MeetingID      string `json:"meeting_id"`
+	RecordingStart string `json:"recording_start"`
+	RecordingEnd   string `json:"recording_end"`
+	FileType       string `json:"file_type"`
+	FileExtension  string `json:"file_extension"`
+	FileSize       int    `json:"file_size"`
+	PlayURL        string `json:"play_url"`
+	DownloadURL    string `json:"download_url"`
+	Status         string `json:"status"`
+	RecordingType  string `json:"recording_type"`
+}
+
+// ZoomRecording is the cloud recording of a single Zoom meeting instance.
+type ZoomRecording struct {
+	UUID           string              `json:"uuid"`
+	ID             int64               `json:"id"`
+	HostID         string              `json:"host_id"`
+	HostEmail      string              `json:"host_email"`
+	Topic          string              `json:"topic"`
+	StartTime      string              `json:"start_time"`
+	ShareURL       string              `json:"share_url"`
+	TotalSize      int                 `json:"total_size"`
+	RecordingCount int                 `json:"recording_count"`
+	RecordingFiles []ZoomRecordingFile `json:"recording_files"`
+}
+
+// initZoomClient initializes the Zoom API HTTP client using Server-to-Server
+// OAuth account credentials.
+func initZoomClient(cfg *Config) {
+	oauthConfig := &clientcredentials.Config{
+		ClientID:     cfg.ZoomClientID,
+		ClientSecret: cfg.ZoomClientSecret,
+		TokenURL:     zoomTokenURL,
+		EndpointParams: url.Values{
+			"grant_type": {"account_credentials"},
+			"account_id": {cfg.ZoomAccountID},
+		},
+	}
+
+	zoomHTTPClient = oauthConfig.Client(context.Background())
+	zoomHTTPClient.Timeout = zoomHTTPTimeout
+}
+
+// zoomMeetingUUIDPath escapes a meeting instance UUID for use in a path. Zoom
+// requires UUIDs that begin with "/" or contain "//" to be double-encoded.
+func zoomMeetingUUIDPath(uuid string) string {
+	escaped := url.PathEscape(uuid)
+	if strings.HasPrefix(uuid, "/") || strings.Contains(uuid, "//") {
+		escaped = url.PathEscape(escaped)
+	}
+	return escaped
+}
+
+// zoomGet performs a GET request against the Zoom API and decodes the JSON
+// response into out. Returns (found, error), where found is false on 404.
+func zoomGet(ctx context.Context, path string, query url.Values, out any) (bool, error) {
+	reqURL := zoomAPIBaseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := zoomHTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("zoom API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return false, fmt.Errorf("failed to unmarshal zoom API response: %w", err)
+	}
+	return true, nil
+}
+
+// listZoomPastMeetingInstances returns the ended instances of a meeting.
+func listZoomPastMeetingInstances(ctx context.Context, meetingID string) ([]ZoomPastMeetingInstance, error) {
+	var resp struct {
+		Meetings []ZoomPastMeetingInstance `json:"meetings"`
+	}
+	if _, err := zoomGet(ctx, fmt.Sprintf("/past_meetings/%s/instances", url.PathEscape(meetingID)), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Meetings, nil
+}
+
+// getZoomMeetingReport returns the report of a meeting instance, or nil if
+// Zoom has no report for it.
+func getZoomMeetingReport(ctx context.Context, instanceUUID string) (*ZoomMeetingReport, error) {
+	var report ZoomMeetingReport
+	found, err := zoomGet(ctx, fmt.Sprintf("/report/meetings/%s", zoomMeetingUUIDPath(instanceUUID)), nil, &report)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// listZoomMeetingParticipants returns all participant entries of a meeting
+// instance, following pagination.
+func listZoomMeetingParticipants(ctx context.Context, instanceUUID string) ([]ZoomParticipantReport, error) {
+	var participants []ZoomParticipantReport
+	nextPageToken := ""
+	for {
+		query := url.Values{"page_size": {fmt.Sprint(zoomPageSize)}}
+		if nextPageToken != "" {
+			query.Set("next_page_token", nextPageToken)
+		}
+
+		var resp struct {
+			NextPageToken string                  `json:"next_page_token"`
+			Participants  []ZoomParticipantReport `json:"participants"`
+		}
+		found, err := zoomGet(ctx, fmt.Sprintf("/report/meetings/%s/participants", zoomMeetingUUIDPath(instanceUUID)), query, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return participants, nil
+		}
+
+		participants = append(participants, resp.Participants...)
+		if resp.NextPageToken == "" {
+			return participants, nil
+		}
+		nextPageToken = resp.NextPageToken
+	}
+}
+
+// getZoomMeetingRecording returns the cloud recording of a meeting instance,
+// or nil if the instance was not recorded.
+func getZoomMeetingRecording(ctx context.Context, instanceUUID string) (*ZoomRecording, error) {
+	var recording ZoomRecording
+	found, err := zoomGet(ctx, fmt.Sprintf("/meetings/%s/recordings", zoomMeetingUUIDPath(instanceUUID)), nil, &recording)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &recording, nil
+}
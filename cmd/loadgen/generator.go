@@ -0,0 +1,190 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The loadgen tool.
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// record is a single v1 object to write to the KV bucket.
+type record struct {
+	key  string
+	data map[string]any
+}
+
+// generator builds synthetic v1 meeting hierarchies. Records use the same key
+// prefixes and field names as the v1 DynamoDB tables replicated by Meltano, so
+// they are processed by the sync helper's handlers unchanged.
+type generator struct {
+	rng      *rand.Rand
+	projects []string
+	now      time.Time
+}
+
+var (
+	loadgenTopics     = []string{"Technical Steering Committee", "Governing Board", "Community Call", "Working Group Sync", "Release Planning", "Security Review", "Marketing Committee", "Maintainers Meeting"}
+	loadgenFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn"}
+	loadgenLastNames  = []string{"Garcia", "Chen", "Okafor", "Novak", "Silva", "Kim", "Patel", "Muller", "Haddad", "Larsen"}
+	loadgenOrgs       = []string{"Example Corp", "Acme Inc", "Initech", "Globex", "Umbrella Labs", ""}
+	loadgenTimezones  = []string{"UTC", "America/Los_Angeles", "America/New_York", "Europe/Berlin", "Asia/Tokyo"}
+	loadgenVisibility = []string{"public", "private"}
+	loadgenAccess     = []string{"public", "meeting_hosts", "meeting_participants"}
+)
+
+// newGenerator creates a generator for the given project SFIDs.
+func newGenerator(seed int64, projects []string) *generator {
+	return &generator{
+		rng:      rand.New(rand.NewSource(seed)),
+		projects: projects,
+		now:      time.Now().UTC(),
+	}
+}
+
+// pick returns a random element of values.
+func (g *generator) pick(values []string) string {
+	return values[g.rng.Intn(len(values))]
+}
+
+// id returns a deterministic (per seed) UUID string.
+func (g *generator) id() string {
+	var b [16]byte
+	g.rng.Read(b[:])
+	return uuid.Must(uuid.FromBytes(b[:])).String()
+}
+
+// meetingHierarchy generates a meeting with its registrants, past meetings,
+// and past meeting attendees, parents first.
+func (g *generator) meetingHierarchy(registrants, pastMeetings, attendees int) []record {
+	meetingID := strconv.FormatInt(80000000000+g.rng.Int63n(9999999999), 10)
+	projectSFID := g.pick(g.projects)
+	topic := fmt.Sprintf("%s %d", g.pick(loadgenTopics), g.rng.Intn(1000))
+	duration := 30 * (1 + g.rng.Intn(4))
+	start := g.now.Add(time.Duration(g.rng.Intn(30*24)) * time.Hour).Truncate(time.Hour)
+	timestamp := g.now.Format(time.RFC3339)
+
+	meeting := map[string]any{
+		"meeting_id":         meetingID,
+		"proj_id":            projectSFID,
+		"topic":              topic,
+		"agenda":             "Synthetic meeting generated by loadgen.",
+		"visibility":         g.pick(loadgenVisibility),
+		"meeting_type":       "None",
+		"start_time":         start.Format(time.RFC3339),
+		"timezone":           g.pick(loadgenTimezones),
+		"duration":           duration,
+		"restricted":         g.rng.Intn(2) == 0,
+		"recording_enabled":  true,
+		"recording_access":   g.pick(loadgenAccess),
+		"transcript_enabled": g.rng.Intn(2) == 0,
+		"transcript_access":  g.pick(loadgenAccess),
+		"ai_summary_access":  g.pick(loadgenAccess),
+		"created_at":         timestamp,
+		"modified_at":        timestamp,
+	}
+	records := []record{{key: "itx-zoom-meetings-v2." + meetingID, data: meeting}}
+
+	type person struct {
+		registrantID, firstName, lastName, email string
+	}
+	people := make([]person, 0, registrants)
+	for i := 0; i < registrants; i++ {
+		p := person{
+			registrantID: g.id(),
+			firstName:    g.pick(loadgenFirstNames),
+			lastName:     g.pick(loadgenLastNames),
+		}
+		p.email = fmt.Sprintf("loadgen+%s@example.com", p.registrantID[:8])
+		people = append(people, p)
+
+		records = append(records, record{
+			key: "itx-zoom-meetings-registrants-v2." + p.registrantID,
+			data: map[string]any{
+				"registrant_id": p.registrantID,
+				"meeting_id":    meetingID,
+				"proj_id":       projectSFID,
+				"type":          "direct",
+				"email":         p.email,
+				"first_name":    p.firstName,
+				"last_name":     p.lastName,
+				"org":           g.pick(loadgenOrgs),
+				"host":          i == 0,
+				"created_at":    timestamp,
+				"modified_at":   timestamp,
+			},
+		})
+	}
+
+	for i := 0; i < pastMeetings; i++ {
+		occurrenceStart := g.now.Add(-time.Duration(7*(i+1)) * 24 * time.Hour).Truncate(time.Hour)
+		occurrenceID := strconv.FormatInt(occurrenceStart.Unix(), 10)
+		meetingAndOccurrenceID := fmt.Sprintf("%s-%s", meetingID, occurrenceID)
+		occurrenceEnd := occurrenceStart.Add(time.Duration(duration) * time.Minute)
+		sessionUUID := g.id()
+
+		records = append(records, record{
+			key: "itx-zoom-past-meetings." + meetingAndOccurrenceID,
+			data: map[string]any{
+				"meeting_and_occurrence_id": meetingAndOccurrenceID,
+				"meeting_id":                meetingID,
+				"occurrence_id":             occurrenceID,
+				"proj_id":                   projectSFID,
+				"topic":                     topic,
+				"agenda":                    meeting["agenda"],
+				"visibility":                meeting["visibility"],
+				"timezone":                  meeting["timezone"],
+				"duration":                  duration,
+				"recording_access":          meeting["recording_access"],
+				"transcript_access":         meeting["transcript_access"],
+				"scheduled_start_time":      occurrenceStart.Format(time.RFC3339),
+				"scheduled_end_time":        occurrenceEnd.Format(time.RFC3339),
+				"sessions": []map[string]any{{
+					"uuid":       sessionUUID,
+					"start_time": occurrenceStart.Format(time.RFC3339),
+					"end_time":   occurrenceEnd.Format(time.RFC3339),
+				}},
+				"created_at":  timestamp,
+				"modified_at": timestamp,
+			},
+		})
+
+		for j := 0; j < attendees; j++ {
+			attendeeID := g.id()
+			data := map[string]any{
+				"id":                        attendeeID,
+				"proj_id":                   projectSFID,
+				"meeting_id":                meetingID,
+				"occurrence_id":             occurrenceID,
+				"meeting_and_occurrence_id": meetingAndOccurrenceID,
+				"sessions": []map[string]any{{
+					"participant_uuid": g.id(),
+					"join_time":        occurrenceStart.Format(time.RFC3339),
+					"leave_time":       occurrenceEnd.Format(time.RFC3339),
+				}},
+				"created_at":  timestamp,
+				"modified_at": timestamp,
+			}
+			// Attendees are registrants where possible, otherwise guests.
+			if j < len(people) {
+				p := people[j]
+				data["registrant_id"] = p.registrantID
+				data["email"] = p.email
+				data["name"] = p.firstName + " " + p.lastName
+				data["zoom_user_name"] = p.firstName + " " + p.lastName
+			} else {
+				name := g.pick(loadgenFirstNames) + " " + g.pick(loadgenLastNames)
+				data["name"] = name
+				data["zoom_user_name"] = name
+			}
+			records = append(records, record{key: "itx-zoom-past-meetings-attendees." + attendeeID, data: data})
+		}
+	}
+
+	return records
+}
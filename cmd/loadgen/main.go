@@ -0,0 +1,223 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// The loadgen tool generates realistic synthetic v1 meeting hierarchies
+// (meetings, registrants, past meetings, and past meeting attendees) at a
+// configurable volume into a v1-objects KV bucket. Pointing a test instance of
+// the sync helper at the bucket exercises the full handler pipeline, for
+// capacity planning and performance regression testing before large
+// migrations.
+//
+// Records are written parents first per meeting, matching the order in which
+// Meltano replicates the v1 tables. Generation is deterministic for a given
+// -seed.
+//
+// Writing to the production "v1-objects" bucket name requires -force.
+//
+// Optional environment variables (with defaults):
+//
+//	NATS_URL  nats://localhost:4222
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	errKey = "error"
+
+	// productionBucket is the bucket consumed by the deployed sync helper.
+	productionBucket = "v1-objects"
+)
+
+var logger *slog.Logger
+
+func main() {
+	natsURL := os.Getenv("NATS_URL")
+	if natsURL == "" {
+		natsURL = "nats://localhost:4222"
+	}
+
+	var (
+		url          = flag.String("nats-url", natsURL, "NATS server URL")
+		bucket       = flag.String("bucket", "v1-objects-loadgen", "v1-objects KV bucket to write to")
+		createBucket = flag.Bool("create-bucket", false, "create the KV bucket if it does not exist")
+		force        = flag.Bool("force", false, "allow writing to the production v1-objects bucket")
+		projects     = flag.String("projects", "a0941000002wBz4AAE", "comma-separated v1 project SFIDs to assign meetings to")
+		meetings     = flag.Int("meetings", 100, "number of meetings to generate")
+		registrants  = flag.Int("registrants", 20, "registrants per meeting")
+		pastMeetings = flag.Int("past-meetings", 4, "past meetings per meeting")
+		attendees    = flag.Int("attendees", 15, "attendees per past meeting")
+		concurrency  = flag.Int("concurrency", 8, "number of meeting hierarchies written concurrently")
+		rate         = flag.Int("rate", 0, "maximum records written per second (0 for unlimited)")
+		seed         = flag.Int64("seed", time.Now().UnixNano(), "random seed for deterministic generation")
+		useMsgpack   = flag.Bool("msgpack", false, "encode records as MessagePack instead of JSON")
+		dryRun       = flag.Bool("dry-run", false, "generate records and print them as JSON lines without writing")
+		debug        = flag.Bool("d", false, "enable debug logging")
+	)
+	flag.Parse()
+
+	logOptions := &slog.HandlerOptions{}
+	if *debug {
+		logOptions.Level = slog.LevelDebug
+	}
+	logger = slog.New(slog.NewJSONHandler(os.Stderr, logOptions))
+	slog.SetDefault(logger)
+
+	projectSFIDs := []string{}
+	for _, p := range strings.Split(*projects, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			projectSFIDs = append(projectSFIDs, p)
+		}
+	}
+	if len(projectSFIDs) == 0 || *meetings < 0 || *registrants < 0 || *pastMeetings < 0 || *attendees < 0 || *concurrency < 1 || *rate < 0 {
+		fmt.Fprintln(os.Stderr, "invalid arguments")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *bucket == productionBucket && !*force && !*dryRun {
+		fmt.Fprintf(os.Stderr, "refusing to write to the %s bucket without -force\n", productionBucket)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	gen := newGenerator(*seed, projectSFIDs)
+
+	if *dryRun {
+		encoder := json.NewEncoder(os.Stdout)
+		for i := 0; i < *meetings; i++ {
+			for _, r := range gen.meetingHierarchy(*registrants, *pastMeetings, *attendees) {
+				if err := encoder.Encode(map[string]any{"key": r.key, "value": r.data}); err != nil {
+					logger.With(errKey, err).Error("failed to encode record")
+					os.Exit(1)
+				}
+			}
+		}
+		return
+	}
+
+	natsConn, err := nats.Connect(*url)
+	if err != nil {
+		logger.With(errKey, err).Error("error creating NATS client")
+		os.Exit(1)
+	}
+	defer natsConn.Close()
+
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.With(errKey, err).Error("error creating JetStream context")
+		os.Exit(1)
+	}
+
+	kv, err := js.KeyValue(ctx, *bucket)
+	if err == jetstream.ErrBucketNotFound && *createBucket {
+		kv, err = js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      *bucket,
+			Description: "synthetic v1 objects generated by loadgen",
+			History:     1,
+		})
+	}
+	if err != nil {
+		logger.With(errKey, err, "bucket", *bucket).Error("error accessing KV bucket")
+		os.Exit(1)
+	}
+
+	// Generate all hierarchies up front so generation cost is not included in
+	// the measured write throughput, and so output is deterministic per seed
+	// regardless of concurrency.
+	hierarchies := make(chan []record, *meetings)
+	total := 0
+	for i := 0; i < *meetings; i++ {
+		records := gen.meetingHierarchy(*registrants, *pastMeetings, *attendees)
+		total += len(records)
+		hierarchies <- records
+	}
+	close(hierarchies)
+
+	logger.With("bucket", *bucket, "meetings", *meetings, "records", total, "concurrency", *concurrency, "rate", *rate, "seed", *seed).Info("writing synthetic records")
+
+	var limiter <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(*rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	var written, failed atomic.Int64
+	started := time.Now()
+	wg := sync.WaitGroup{}
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for records := range hierarchies {
+				for _, r := range records {
+					if limiter != nil {
+						select {
+						case <-limiter:
+						case <-ctx.Done():
+							return
+						}
+					}
+					if ctx.Err() != nil {
+						return
+					}
+					if err := putRecord(ctx, kv, r, *useMsgpack); err != nil {
+						failed.Add(1)
+						logger.With(errKey, err, "key", r.key).Warn("failed to write record")
+						continue
+					}
+					written.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(started)
+	logger.With(
+		"records_written", written.Load(),
+		"records_failed", failed.Load(),
+		"elapsed", elapsed.String(),
+		"records_per_second", float64(written.Load())/elapsed.Seconds(),
+	).Info("load generation complete")
+
+	if failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+// putRecord encodes and writes a single record to the KV bucket.
+func putRecord(ctx context.Context, kv jetstream.KeyValue, r record, useMsgpack bool) error {
+	var data []byte
+	var err error
+	if useMsgpack {
+		data, err = msgpack.Marshal(r.data)
+	} else {
+		data, err = json.Marshal(r.data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	if _, err := kv.Put(ctx, r.key, data); err != nil {
+		return fmt.Errorf("failed to put record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// Package bootstrap holds the process setup shared by the lfx-v1-sync-helper
+// subcommands: environment parsing, logging, the health check server, the
+// NATS connection, and graceful shutdown.
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	errKey = "error"
+
+	// GracefulShutdownSeconds should be higher than NATS client request
+	// timeout, and lower than the pod or liveness probe's
+	// terminationGracePeriodSeconds.
+	GracefulShutdownSeconds = 25
+)
+
+// NewLogger returns a JSON logger writing to stdout at the given level, and
+// sets it as the default logger. Source locations are added when addSource is
+// set (in debug mode), and the correlation ID of the context (see
+// WithCorrelationID) to the lines logged with one. The values of the
+// attributes matching DefaultLogScrubFields or scrubFields are redacted, and
+// email addresses are masked in all values.
+func NewLogger(level slog.Leveler, addSource bool, scrubFields []string) *slog.Logger {
+	logger := slog.New(correlationHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       level,
+		AddSource:   addSource,
+		ReplaceAttr: newLogScrubber(scrubFields).replaceAttr,
+	})})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ShutdownSignals returns a channel notified on SIGINT and SIGTERM. It is
+// also written to when the NATS connection is lost, to trigger a shutdown.
+func ShutdownSignals() chan os.Signal {
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	return done
+}
+
+// ListenAddr returns the listen address for a bind interface ("*" for all
+// interfaces) and port.
+func ListenAddr(bind, port string) string {
+	if bind == "*" {
+		return ":" + port
+	}
+	return bind + ":" + port
+}
+
+// StartHTTPServer serves the handler on the address in the background,
+// exiting the process if the listener fails. These servers do NOT participate
+// in the graceful shutdown process; we want them to stay up until the
+// graceful shutdown has finished, to avoid liveness checks failing during it.
+func StartHTTPServer(logger *slog.Logger, name, addr string, handler http.Handler) *http.Server {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			logger.With(errKey, err, "server", name, "addr", addr).Error("http listener error")
+			os.Exit(1)
+		}
+	}()
+	return httpServer
+}
+
+// NewHealthMux returns a mux serving the Kubernetes probes: /livez always
+// succeeds while the process runs, and /readyz succeeds while the NATS
+// connection returned by conn is connected and not draining, and every
+// readiness check returns nil.
+func NewHealthMux(conn func() *nats.Conn, checks ...func() error) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Support GET/POST monitoring "ping".
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, _ *http.Request) {
+		// This always returns as long as the service is still running. As this
+		// endpoint is expected to be used as a Kubernetes liveness check, this
+		// service must likewise self-detect non-recoverable errors and
+		// self-terminate.
+		fmt.Fprintf(w, "OK\n")
+	})
+
+	// Basic health check.
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		natsConn := conn()
+		if natsConn == nil {
+			http.Error(w, "no NATS connection", http.StatusServiceUnavailable)
+			return
+		}
+		if !natsConn.IsConnected() || natsConn.IsDraining() {
+			http.Error(w, "NATS connection not ready", http.StatusServiceUnavailable)
+			return
+		}
+		for _, check := range checks {
+			if err := check(); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		fmt.Fprintf(w, "OK\n")
+	})
+
+	return mux
+}
+
+// ConnectNATS connects to NATS. The graceful close wait group is incremented,
+// and decremented once the connection is closed after ctx is cancelled (a
+// graceful shutdown). If the connection is closed otherwise (reconnects
+// exhausted), a shutdown is signaled on done and the process exits.
+func ConnectNATS(ctx context.Context, logger *slog.Logger, url string, gracefulCloseWG *sync.WaitGroup, done chan<- os.Signal, opts ...nats.Option) (*nats.Conn, error) {
+	gracefulCloseWG.Add(1)
+	opts = append([]nats.Option{
+		nats.DrainTimeout(GracefulShutdownSeconds * time.Second),
+		nats.ErrorHandler(func(_ *nats.Conn, s *nats.Subscription, err error) {
+			if s != nil {
+				logger.With(errKey, err, "subject", s.Subject, "queue", s.Queue).Error("async NATS error")
+			} else {
+				logger.With(errKey, err).Error("async NATS error outside subscription")
+			}
+		}),
+		nats.ClosedHandler(func(_ *nats.Conn) {
+			if ctx.Err() != nil {
+				// If our parent background context has already been canceled, this is
+				// a graceful shutdown. Decrement the wait group but do not exit, to
+				// allow other graceful shutdown steps to complete.
+				gracefulCloseWG.Done()
+				return
+			}
+			// Otherwise, this handler means that max reconnect attempts have been
+			// exhausted.
+			logger.Error("NATS max-reconnects exhausted; connection closed")
+			// Send a synthetic interrupt and give any graceful-shutdown tasks 5
+			// seconds to clean up.
+			done <- os.Interrupt
+			time.Sleep(5 * time.Second)
+			// Exit with an error instead of decrementing the wait group.
+			os.Exit(1)
+		}),
+	}, opts...)
+
+	natsConn, err := nats.Connect(url, opts...)
+	if err != nil {
+		gracefulCloseWG.Done()
+		return nil, err
+	}
+	return natsConn, nil
+}
+
+// DrainNATS drains the connection, which drains all remaining subscriptions
+// then closes the connection, and waits for the graceful close wait group.
+// The context passed to ConnectNATS must be cancelled first.
+func DrainNATS(logger *slog.Logger, natsConn *nats.Conn, gracefulCloseWG *sync.WaitGroup) error {
+	if !natsConn.IsClosed() && !natsConn.IsDraining() {
+		logger.Info("draining NATS connection")
+		if err := natsConn.Drain(); err != nil {
+			return err
+		}
+	}
+
+	// Wait for the graceful shutdown steps to complete.
+	logger.Debug("waiting for graceful shutdown steps to complete")
+	gracefulCloseWG.Wait()
+	logger.Debug("graceful shutdown steps completed")
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package bootstrap
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator generates unique IDs, such as correlation IDs.
+type IDGenerator interface {
+	NewID() string
+}
+
+// systemClock is the Clock of the system time.
+type systemClock struct{}
+
+// Now implements Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// randomIDs is the IDGenerator of random (version 4) UUIDs.
+type randomIDs struct{}
+
+// NewID implements IDGenerator.
+func (randomIDs) NewID() string {
+	return uuid.NewString()
+}
+
+// clockHolder and idGeneratorHolder wrap the Clock and IDGenerator in use, so
+// they are stored with a consistent concrete type.
+type (
+	clockHolder       struct{ Clock }
+	idGeneratorHolder struct{ IDGenerator }
+)
+
+var (
+	clock       atomic.Pointer[clockHolder]
+	idGenerator atomic.Pointer[idGeneratorHolder]
+)
+
+// SetClock sets the Clock returning the time of Now, e.g. a fixed clock so
+// test runs produce the same output; nil restores the system time.
+func SetClock(c Clock) {
+	if c == nil {
+		clock.Store(nil)
+		return
+	}
+	clock.Store(&clockHolder{c})
+}
+
+// SetIDGenerator sets the IDGenerator returning the IDs of NewID, e.g. a
+// sequence so test runs produce the same output; nil restores random UUIDs.
+func SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		idGenerator.Store(nil)
+		return
+	}
+	idGenerator.Store(&idGeneratorHolder{g})
+}
+
+// Now returns the current time of the Clock set with SetClock, or the system
+// time. It is used for the times written to synced data and state, rather
+// than for elapsed time measurements.
+func Now() time.Time {
+	if c := clock.Load(); c != nil {
+		return c.Now()
+	}
+	return systemClock{}.Now()
+}
+
+// NewID returns a new ID from the IDGenerator set with SetIDGenerator, or a
+// random UUID.
+func NewID() string {
+	if g := idGenerator.Load(); g != nil {
+		return g.NewID()
+	}
+	return randomIDs{}.NewID()
+}
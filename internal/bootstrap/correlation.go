@@ -0,0 +1,82 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package bootstrap
+
+import (
+	"context"
+	"log/slog"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	// CorrelationIDHeader is the NATS message header carrying the
+	// correlation ID of the change which caused the message.
+	CorrelationIDHeader = "X-Correlation-ID"
+
+	// correlationIDKey is the log attribute of the correlation ID.
+	correlationIDKey = "correlation_id"
+)
+
+// correlationIDContextKey is the context key of the correlation ID.
+type correlationIDContextKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID, which is
+// added to the log lines written with the context.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation ID of the context, or an empty string.
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDContextKey{}).(string)
+	return correlationID
+}
+
+// MessageContext returns a context carrying the correlation ID of a consumed
+// message, from its CorrelationIDHeader if set upstream, or a new one.
+func MessageContext(ctx context.Context, header nats.Header) context.Context {
+	correlationID := header.Get(CorrelationIDHeader)
+	if correlationID == "" {
+		correlationID = NewID()
+	}
+	return WithCorrelationID(ctx, correlationID)
+}
+
+// SetCorrelationHeader sets the CorrelationIDHeader of a message to be
+// published from the correlation ID of the context, if any.
+func SetCorrelationHeader(ctx context.Context, msg *nats.Msg) {
+	correlationID := CorrelationID(ctx)
+	if correlationID == "" {
+		return
+	}
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	msg.Header.Set(CorrelationIDHeader, correlationID)
+}
+
+// correlationHandler is a log handler adding the correlation ID of the
+// context to each record.
+type correlationHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler.
+func (h correlationHandler) Handle(ctx context.Context, record slog.Record) error {
+	if correlationID := CorrelationID(ctx); correlationID != "" {
+		record.AddAttrs(slog.String(correlationIDKey, correlationID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler.
+func (h correlationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return correlationHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h correlationHandler) WithGroup(name string) slog.Handler {
+	return correlationHandler{h.Handler.WithGroup(name)}
+}
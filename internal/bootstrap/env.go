@@ -0,0 +1,52 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package bootstrap
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParseBooleanEnv parses a boolean environment variable with common truthy values.
+// Returns true if the value (case-insensitive) is "true", "yes", "t", "y", or "1".
+// Returns false for any other value including empty string.
+func ParseBooleanEnv(envVar string) bool {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(envVar)))
+	truthyValues := []string{"true", "yes", "t", "y", "1"}
+	return slices.Contains(truthyValues, value)
+}
+
+// ParseListEnv parses a comma-separated environment variable into a slice of
+// trimmed, non-empty values. Returns nil if the variable is unset or empty.
+func ParseListEnv(envVar string) []string {
+	return ParseList(os.Getenv(envVar))
+}
+
+// ParseList parses a comma-separated list into a slice of trimmed, non-empty
+// values. Returns nil if the list is empty.
+func ParseList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// ParseIntEnv parses a positive integer environment variable, returning the
+// default value when it is unset or invalid.
+func ParseIntEnv(envVar string, defaultVal int) int {
+	s := strings.TrimSpace(os.Getenv(envVar))
+	if s == "" {
+		return defaultVal
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return defaultVal
+	}
+	return v
+}
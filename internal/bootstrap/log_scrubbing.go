@@ -0,0 +1,173 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// redactedLogValue replaces the values of sensitive log attributes.
+	redactedLogValue = "[redacted]"
+
+	// redactedLogEmail replaces the email addresses in logged strings.
+	redactedLogEmail = "[redacted-email]"
+)
+
+// DefaultLogScrubFields are the patterns of the log attribute keys whose
+// values are always redacted: Zoom host keys, passwords and passcodes,
+// secrets, tokens, and email addresses.
+var DefaultLogScrubFields = []string{
+	"host_key",
+	"hostkey",
+	"*password*",
+	"*passcode*",
+	"*secret*",
+	"*token",
+	"authorization",
+	"*email",
+	"*emails",
+}
+
+// logEmailPattern matches the email addresses in logged strings.
+var logEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// ValidateLogScrubFields checks that log scrub field patterns are valid
+// path.Match patterns.
+func ValidateLogScrubFields(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid log scrub field pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// logScrubber redacts sensitive values from log records.
+type logScrubber struct {
+	// patterns match the lowercase keys of the attributes whose values are
+	// redacted, including the keys of the maps and structs logged as values.
+	patterns []string
+}
+
+// newLogScrubber returns a scrubber redacting the attributes matching the
+// default patterns and the extra ones.
+func newLogScrubber(extra []string) *logScrubber {
+	patterns := append([]string{}, DefaultLogScrubFields...)
+	for _, pattern := range extra {
+		patterns = append(patterns, strings.ToLower(pattern))
+	}
+	return &logScrubber{patterns: patterns}
+}
+
+// sensitive reports whether the values of a key are redacted.
+func (s *logScrubber) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range s.patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceAttr implements slog.HandlerOptions.ReplaceAttr. Sensitive
+// attributes are redacted, email addresses are masked in strings (including
+// errors), and other values (the payloads logged as maps, structs, or raw
+// JSON) are scrubbed field by field.
+func (s *logScrubber) replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && (attr.Key == slog.TimeKey || attr.Key == slog.LevelKey || attr.Key == slog.SourceKey) {
+		return attr
+	}
+	if s.sensitive(attr.Key) {
+		return slog.String(attr.Key, redactedLogValue)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		if scrubbed, changed := scrubLogString(attr.Value.String()); changed {
+			return slog.String(attr.Key, scrubbed)
+		}
+	case slog.KindAny:
+		value := attr.Value.Any()
+		if err, ok := value.(error); ok {
+			if scrubbed, changed := scrubLogString(err.Error()); changed {
+				return slog.String(attr.Key, scrubbed)
+			}
+			return attr
+		}
+		if scrubbed, changed := s.scrubLogValue(value); changed {
+			return slog.Any(attr.Key, scrubbed)
+		}
+	}
+	return attr
+}
+
+// scrubLogString masks the email addresses in a string.
+func scrubLogString(s string) (string, bool) {
+	if !strings.Contains(s, "@") {
+		return s, false
+	}
+	scrubbed := logEmailPattern.ReplaceAllString(s, redactedLogEmail)
+	return scrubbed, scrubbed != s
+}
+
+// scrubLogValue scrubs a value logged as JSON, by round-tripping it through
+// its JSON encoding, so the keys of maps and structs are scrubbed alike. It
+// returns the value unchanged when nothing needed scrubbing, so values log
+// exactly as before unless they carry sensitive data.
+func (s *logScrubber) scrubLogValue(value any) (any, bool) {
+	if value == nil {
+		return value, false
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value, false
+	}
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return value, false
+	}
+	scrubbed, changed := s.scrubJSON(decoded)
+	if !changed {
+		return value, false
+	}
+	return scrubbed, true
+}
+
+// scrubJSON scrubs a decoded JSON value in place, reporting whether it
+// changed.
+func (s *logScrubber) scrubJSON(value any) (any, bool) {
+	changed := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if s.sensitive(key) {
+				if field != nil && field != "" {
+					v[key] = redactedLogValue
+					changed = true
+				}
+				continue
+			}
+			if scrubbed, fieldChanged := s.scrubJSON(field); fieldChanged {
+				v[key] = scrubbed
+				changed = true
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if scrubbed, itemChanged := s.scrubJSON(item); itemChanged {
+				v[i] = scrubbed
+				changed = true
+			}
+		}
+	case string:
+		return scrubLogString(v)
+	}
+	return value, changed
+}
@@ -0,0 +1,56 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package bootstrap
+
+import (
+	"runtime/debug"
+	"strings"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// StatusEndpoint is the name of the endpoint added to every service by
+// AddService.
+const StatusEndpoint = "status"
+
+// Version returns the main module version from the build information, without
+// the "v" prefix, as NATS micro services require a semantic version. It
+// returns "0.0.0" for development builds without a version.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return "0.0.0"
+	}
+	return strings.TrimPrefix(info.Main.Version, "v")
+}
+
+// AddService registers the process as a NATS micro service, which answers the
+// $SRV.PING, $SRV.INFO, and $SRV.STATS discovery requests with its version and
+// uptime. A status endpoint on statusSubject replies with the JSON encoding of
+// status(), which is also reported as the data of every endpoint in the STATS
+// response. Every instance replies to status requests (there is no queue
+// group), like to the discovery requests.
+func AddService(natsConn *nats.Conn, name, description, statusSubject string, status func() any) (micro.Service, error) {
+	svc, err := micro.AddService(natsConn, micro.Config{
+		Name:        name,
+		Version:     Version(),
+		Description: description,
+		StatsHandler: func(*micro.Endpoint) any {
+			return status()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.AddEndpoint(StatusEndpoint, micro.HandlerFunc(func(req micro.Request) {
+		_ = req.RespondJSON(status())
+	}), micro.WithEndpointSubject(statusSubject), micro.WithEndpointQueueGroupDisabled())
+	if err != nil {
+		_ = svc.Stop()
+		return nil, err
+	}
+	return svc, nil
+}
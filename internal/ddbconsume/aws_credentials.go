@@ -0,0 +1,51 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// configureAWSCredentials replaces the credentials of the AWS config with the
+// ones built from the service configuration:
+//
+//  1. When a web identity (IRSA) role and token file are configured, the base
+//     credentials come from assuming that role with the token, which the SDK
+//     re-reads from the file on every refresh. Otherwise the base credentials
+//     come from the standard AWS credential chain.
+//  2. Each configured assume-role ARN is then assumed in order using the
+//     credentials of the previous step (role chaining), with the configured
+//     external ID, so cross-account access works without long-lived keys.
+func configureAWSCredentials(awsCfg *aws.Config, cfg *Config) {
+	if cfg.WebIdentityRoleARN != "" {
+		logger.With("role_arn", cfg.WebIdentityRoleARN, "token_file", cfg.WebIdentityTokenFile).Info("assuming IAM role with web identity token")
+		stsClient := sts.NewFromConfig(*awsCfg)
+		provider := stscreds.NewWebIdentityRoleProvider(
+			stsClient,
+			cfg.WebIdentityRoleARN,
+			stscreds.IdentityTokenFile(cfg.WebIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = cfg.AssumeRoleSessionName
+				o.Duration = cfg.AssumeRoleDuration
+			},
+		)
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	for _, roleARN := range cfg.AssumeRoleARNs {
+		logger.With("role_arn", roleARN, "external_id_set", cfg.AssumeRoleExternalID != "").Info("assuming IAM role for DynamoDB access")
+		// Each STS client signs with the credentials assumed so far.
+		stsClient := sts.NewFromConfig(*awsCfg)
+		provider := stscreds.NewAssumeRoleProvider(stsClient, roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = cfg.AssumeRoleSessionName
+			o.Duration = cfg.AssumeRoleDuration
+			if cfg.AssumeRoleExternalID != "" {
+				o.ExternalID = aws.String(cfg.AssumeRoleExternalID)
+			}
+		})
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+}
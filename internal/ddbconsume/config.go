@@ -0,0 +1,191 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+// Config holds all configuration values for the dynamodb-stream-consumer service.
+type Config struct {
+	// NATS configuration
+	NATSURL string
+
+	// NATS JetStream stream configuration
+	NATSStreamName    string // Stream name (default: dynamodb_streams)
+	NATSSubjectPrefix string // Subject prefix (default: dynamodb_streams)
+
+	// Template of the subject of each record (default: {prefix}.{table})
+	SubjectTemplate subjectTemplate
+
+	// Checkpoint KV bucket name
+	CheckpointBucket string
+
+	// AWS configuration
+	AWSRegion string
+
+	// AWS web identity (IRSA) configuration. On EKS, both are injected into pods
+	// whose service account is annotated with eks.amazonaws.com/role-arn.
+	WebIdentityRoleARN   string // Optional: IAM role ARN to assume with the web identity token
+	WebIdentityTokenFile string // Optional: path of the web identity token file
+
+	// AWS assume-role configuration
+	AssumeRoleARNs        []string      // Optional: IAM role ARNs to assume in order via STS (role chaining) for cross-account access
+	AssumeRoleExternalID  string        // Optional: external ID passed when assuming the roles
+	AssumeRoleSessionName string        // Session name of the assumed roles
+	AssumeRoleDuration    time.Duration // Session duration of the assumed roles
+
+	// DynamoDB tables to consume (comma-separated)
+	Tables []string
+
+	// Iterator start position for new shards with no checkpoint.
+	// If true, start from LATEST (only new records). If false, start from TRIM_HORIZON (all available records).
+	StartFromLatest bool
+
+	// Polling interval for each shard when caught up
+	PollInterval time.Duration
+
+	// How often to re-discover shards on a stream (new shards appear when DynamoDB splits)
+	ShardRefreshInterval time.Duration
+
+	// Iterator age at which a warning is logged, ahead of the 24-hour stream trim horizon
+	IteratorAgeWarning time.Duration
+
+	// Filters dropping records irrelevant to sync before they are published
+	RecordFilters []recordFilter
+
+	// Whether MODIFY events include the old image (REMOVE events always do)
+	IncludeOldImage bool
+
+	// Server configuration
+	Port string
+	Bind string
+
+	// Logging
+	Debug          bool
+	LogScrubFields []string // Log attribute key patterns redacted in addition to the defaults
+}
+
+// LoadConfig loads configuration from environment variables.
+func LoadConfig() (*Config, error) {
+	tablesStr := os.Getenv("DYNAMODB_TABLES")
+	if tablesStr == "" {
+		return nil, fmt.Errorf("DYNAMODB_TABLES environment variable is required (comma-separated list of table names)")
+	}
+
+	tables := []string{}
+	for _, t := range strings.Split(tablesStr, ",") {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("DYNAMODB_TABLES must contain at least one table name")
+	}
+
+	pollIntervalMS := bootstrap.ParseIntEnv("POLL_INTERVAL_MS", 1000)
+	shardRefreshSec := bootstrap.ParseIntEnv("SHARD_REFRESH_INTERVAL_SEC", 10)
+	assumeRoleDurationSec := bootstrap.ParseIntEnv("AWS_ASSUME_ROLE_DURATION_SEC", 900)
+	iteratorAgeWarningSec := bootstrap.ParseIntEnv("ITERATOR_AGE_WARNING_SEC", 72000)
+
+	recordFilters, err := parseRecordFilters(os.Getenv("RECORD_FILTERS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECORD_FILTERS: %w", err)
+	}
+
+	subjectTemplateStr := os.Getenv("NATS_SUBJECT_TEMPLATE")
+	if subjectTemplateStr == "" {
+		subjectTemplateStr = defaultSubjectTemplate
+	}
+	subjectTemplate, err := parseSubjectTemplate(subjectTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS_SUBJECT_TEMPLATE: %w", err)
+	}
+
+	assumeRoleARNs := []string{}
+	for _, arn := range strings.Split(os.Getenv("AWS_ASSUME_ROLE_ARN"), ",") {
+		arn = strings.TrimSpace(arn)
+		if arn != "" {
+			assumeRoleARNs = append(assumeRoleARNs, arn)
+		}
+	}
+
+	cfg := &Config{
+		NATSURL:              os.Getenv("NATS_URL"),
+		NATSStreamName:       os.Getenv("NATS_STREAM_NAME"),
+		NATSSubjectPrefix:    os.Getenv("NATS_SUBJECT_PREFIX"),
+		SubjectTemplate:      subjectTemplate,
+		CheckpointBucket:     os.Getenv("CHECKPOINT_BUCKET"),
+		AWSRegion:            os.Getenv("AWS_REGION"),
+		Tables:               tables,
+		StartFromLatest:      bootstrap.ParseBooleanEnv("START_FROM_LATEST"),
+		PollInterval:         time.Duration(pollIntervalMS) * time.Millisecond,
+		ShardRefreshInterval: time.Duration(shardRefreshSec) * time.Second,
+		IteratorAgeWarning:   time.Duration(iteratorAgeWarningSec) * time.Second,
+		RecordFilters:        recordFilters,
+		IncludeOldImage:      bootstrap.ParseBooleanEnv("INCLUDE_OLD_IMAGE"),
+		Port:                 os.Getenv("PORT"),
+		Bind:                 os.Getenv("BIND"),
+		Debug:                bootstrap.ParseBooleanEnv("DEBUG"),
+		LogScrubFields:       bootstrap.ParseListEnv("LOG_SCRUB_FIELDS"),
+		// AWS credentials
+		WebIdentityRoleARN:    os.Getenv("AWS_ROLE_ARN"),
+		WebIdentityTokenFile:  os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"),
+		AssumeRoleARNs:        assumeRoleARNs,
+		AssumeRoleExternalID:  os.Getenv("AWS_ASSUME_ROLE_EXTERNAL_ID"),
+		AssumeRoleSessionName: os.Getenv("AWS_ROLE_SESSION_NAME"),
+		AssumeRoleDuration:    time.Duration(assumeRoleDurationSec) * time.Second,
+	}
+
+	if cfg.NATSURL == "" {
+		cfg.NATSURL = "nats://localhost:4222"
+	}
+	if cfg.NATSStreamName == "" {
+		cfg.NATSStreamName = "dynamodb_streams"
+	}
+	if cfg.NATSSubjectPrefix == "" {
+		cfg.NATSSubjectPrefix = "dynamodb_streams"
+	}
+	if cfg.CheckpointBucket == "" {
+		cfg.CheckpointBucket = "dynamodb-stream-checkpoints"
+	}
+	if cfg.AWSRegion == "" {
+		cfg.AWSRegion = "us-west-2"
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	if cfg.Bind == "" {
+		cfg.Bind = "*"
+	}
+	if cfg.AssumeRoleSessionName == "" {
+		cfg.AssumeRoleSessionName = "dynamodb-stream-consumer"
+	}
+
+	if (cfg.WebIdentityRoleARN == "") != (cfg.WebIdentityTokenFile == "") {
+		return nil, fmt.Errorf("AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE must be set together")
+	}
+	if cfg.AssumeRoleExternalID != "" && len(cfg.AssumeRoleARNs) == 0 {
+		return nil, fmt.Errorf("AWS_ASSUME_ROLE_EXTERNAL_ID requires AWS_ASSUME_ROLE_ARN")
+	}
+	if err := bootstrap.ValidateLogScrubFields(cfg.LogScrubFields); err != nil {
+		return nil, fmt.Errorf("invalid LOG_SCRUB_FIELDS: %w", err)
+	}
+	// STS accepts session durations from 15 minutes to 12 hours (chained role
+	// sessions are further limited to 1 hour by STS).
+	if cfg.IteratorAgeWarning >= streamTrimHorizon {
+		return nil, fmt.Errorf("ITERATOR_AGE_WARNING_SEC must be less than 86400 (the stream trim horizon)")
+	}
+	if cfg.AssumeRoleDuration < 15*time.Minute || cfg.AssumeRoleDuration > 12*time.Hour {
+		return nil, fmt.Errorf("AWS_ASSUME_ROLE_DURATION_SEC must be between 900 and 43200")
+	}
+
+	return cfg, nil
+}
@@ -1,8 +1,7 @@
 // Copyright The Linux Foundation and each contributor to LFX.
 // SPDX-License-Identifier: MIT
 
-// The dynamodb-stream-consumer service.
-package main
+package ddbconsume
 
 import (
 	"context"
@@ -30,6 +29,11 @@ type TableConsumer struct {
 	logger        *slog.Logger
 
 	activeShards sync.Map // shardID -> struct{}, tracks goroutines already started
+	closedShards sync.Map // shardID -> struct{}, tracks shards consumed to their end
+
+	// shardClosed is signaled when a shard is exhausted, so its children are
+	// started without waiting for the next refresh.
+	shardClosed chan struct{}
 }
 
 // Run starts the consumer loop: it discovers shards and periodically refreshes
@@ -42,6 +46,8 @@ func (c *TableConsumer) Run(ctx context.Context) error {
 
 	c.logger.With("stream_arn", streamARN).Info("starting DynamoDB stream consumer")
 
+	c.shardClosed = make(chan struct{}, 1)
+
 	// Initial shard discovery.
 	c.discoverShards(ctx, streamARN)
 
@@ -54,6 +60,8 @@ func (c *TableConsumer) Run(ctx context.Context) error {
 			return nil
 		case <-ticker.C:
 			c.discoverShards(ctx, streamARN)
+		case <-c.shardClosed:
+			c.discoverShards(ctx, streamARN)
 		}
 	}
 }
@@ -75,12 +83,58 @@ func (c *TableConsumer) getStreamARN(ctx context.Context) (string, error) {
 // discoverShards calls DescribeStream (with pagination) and starts a goroutine for
 // each shard that doesn't already have an active consumer goroutine.
 //
-// Note: DynamoDB Streams preserves item-level order within a shard, but there is no
-// ordering guarantee across shards. When a shard splits, child shards contain newer
-// records than the parent. For strict ordering, you would need to finish parent shards
-// before starting children. For this use case (eventual-consistency sync), we accept
-// that concurrent shard consumers may deliver events slightly out of order across splits.
+// DynamoDB Streams preserves item-level order within a shard, but not across
+// shards. When a shard splits, its child shards contain newer records than the
+// parent, so a child shard is only started once its parent has been consumed
+// to its end (and its final checkpoint written), preserving per-item ordering
+// across shard splits. A parent no longer listed by DescribeStream has been
+// trimmed, so its children are started without waiting.
 func (c *TableConsumer) discoverShards(ctx context.Context, streamARN string) {
+	shards, err := c.describeShards(ctx, streamARN)
+	if err != nil {
+		c.logger.With(errKey, err).Error("failed to describe DynamoDB stream")
+		return
+	}
+
+	listed := make(map[string]struct{}, len(shards))
+	for _, shard := range shards {
+		listed[*shard.ShardId] = struct{}{}
+	}
+
+	for _, shard := range shards {
+		shardID := *shard.ShardId
+		if _, active := c.activeShards.Load(shardID); active {
+			continue
+		}
+		if c.isShardClosed(ctx, shardID) {
+			continue
+		}
+
+		// Children of a parent consumed by this service resume where the parent
+		// left off, so they are read from the start even with START_FROM_LATEST.
+		parentConsumed := false
+		if shard.ParentShardId != nil {
+			parentID := *shard.ParentShardId
+			if _, parentListed := listed[parentID]; parentListed {
+				if !c.isShardClosed(ctx, parentID) {
+					c.logger.With("shard_id", shardID, "parent_shard_id", parentID).Debug("waiting for parent shard to be consumed before starting child shard")
+					continue
+				}
+				parentConsumed = true
+			}
+		}
+
+		// LoadOrStore returns loaded=true if the key already existed.
+		if _, loaded := c.activeShards.LoadOrStore(shardID, struct{}{}); !loaded {
+			c.logger.With("shard_id", shardID).Debug("discovered shard, starting consumer")
+			go c.runShardConsumer(ctx, streamARN, shard, parentConsumed)
+		}
+	}
+}
+
+// describeShards returns all shards of the stream, paginating DescribeStream.
+func (c *TableConsumer) describeShards(ctx context.Context, streamARN string) ([]dynamostypes.Shard, error) {
+	var shards []dynamostypes.Shard
 	var lastShardID *string
 
 	for {
@@ -93,37 +147,71 @@ func (c *TableConsumer) discoverShards(ctx context.Context, streamARN string) {
 
 		out, err := c.streamsClient.DescribeStream(ctx, input)
 		if err != nil {
-			c.logger.With(errKey, err).Error("failed to describe DynamoDB stream")
-			return
-		}
-
-		for _, shard := range out.StreamDescription.Shards {
-			shardID := *shard.ShardId
-			// LoadOrStore returns loaded=true if the key already existed.
-			if _, loaded := c.activeShards.LoadOrStore(shardID, struct{}{}); !loaded {
-				c.logger.With("shard_id", shardID).Debug("discovered shard, starting consumer")
-				go c.runShardConsumer(ctx, streamARN, shard)
-			}
+			return nil, err
 		}
+		shards = append(shards, out.StreamDescription.Shards...)
 
 		if out.StreamDescription.LastEvaluatedShardId == nil {
-			break
+			return shards, nil
 		}
 		lastShardID = out.StreamDescription.LastEvaluatedShardId
 	}
 }
 
+// closedShardKey returns the checkpoint KV key marking a shard as consumed to its end.
+func (c *TableConsumer) closedShardKey(shardID string) string {
+	return fmt.Sprintf("%s.%s.closed", c.tableName, shardID)
+}
+
+// isShardClosed reports whether a shard has been consumed to its end, by this
+// process or (according to the checkpoint KV bucket) a previous one.
+func (c *TableConsumer) isShardClosed(ctx context.Context, shardID string) bool {
+	if _, closed := c.closedShards.Load(shardID); closed {
+		return true
+	}
+	if _, err := c.checkpointKV.Get(ctx, c.closedShardKey(shardID)); err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			c.logger.With(errKey, err, "shard_id", shardID).Warn("failed to read closed shard marker")
+		}
+		return false
+	}
+	c.closedShards.Store(shardID, struct{}{})
+	return true
+}
+
+// markShardClosed records that a shard has been consumed to its end, after
+// its final checkpoint, and signals the discovery loop to start its children.
+func (c *TableConsumer) markShardClosed(ctx context.Context, shardID string) error {
+	if _, err := c.checkpointKV.Put(ctx, c.closedShardKey(shardID), []byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		return err
+	}
+	c.closedShards.Store(shardID, struct{}{})
+	select {
+	case c.shardClosed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 // runShardConsumer polls one DynamoDB stream shard until it is exhausted or the
 // context is cancelled. Checkpoints are stored in the NATS KV bucket keyed as
 // "{tableName}.{shardID}" and hold the last-successfully-published sequence number.
-func (c *TableConsumer) runShardConsumer(ctx context.Context, streamARN string, shard dynamostypes.Shard) {
+//
+// When fromStart is set (the shard's parent was consumed by this service), a
+// shard without a checkpoint is read from TRIM_HORIZON regardless of
+// START_FROM_LATEST, so no records are skipped across a shard split.
+func (c *TableConsumer) runShardConsumer(ctx context.Context, streamARN string, shard dynamostypes.Shard, fromStart bool) {
 	shardID := *shard.ShardId
 	defer c.activeShards.Delete(shardID)
 
 	log := c.logger.With("shard_id", shardID)
 	log.Info("shard consumer started")
 
-	iterator, err := c.getInitialIterator(ctx, streamARN, shardID)
+	metrics := newShardMetrics(c.tableName, shardID, c.config.IteratorAgeWarning, log)
+	exhausted := false
+	defer func() { metrics.stop(exhausted) }()
+
+	iterator, err := c.getInitialIterator(ctx, streamARN, shardID, fromStart)
 	if err != nil {
 		log.With(errKey, err).Error("failed to get initial shard iterator")
 		return
@@ -144,7 +232,7 @@ func (c *TableConsumer) runShardConsumer(ctx context.Context, streamARN string,
 			var expiredErr *dynamostypes.ExpiredIteratorException
 			if errors.As(err, &expiredErr) {
 				log.Warn("shard iterator expired, resuming from checkpoint")
-				iterator, err = c.getInitialIterator(ctx, streamARN, shardID)
+				iterator, err = c.getInitialIterator(ctx, streamARN, shardID, fromStart)
 				if err != nil {
 					log.With(errKey, err).Error("failed to resume shard iterator after expiry")
 					return
@@ -161,19 +249,29 @@ func (c *TableConsumer) runShardConsumer(ctx context.Context, streamARN string,
 			continue
 		}
 
+		metrics.recordsRead(out.Records)
+
 		for _, record := range out.Records {
 			seqNum := *record.Dynamodb.SequenceNumber
 
-			if err := c.publishRecord(ctx, record); err != nil {
+			publishStart := time.Now()
+			published, err := c.publishRecord(ctx, record)
+			if err != nil {
 				log.With(errKey, err, "sequence_number", seqNum).Error("failed to publish record; stopping shard consumer to avoid data loss")
 				// Stop the shard consumer: on the next shard discovery cycle (or restart)
 				// a new goroutine will resume from the last good checkpoint.
 				return
 			}
 
-			// Advance checkpoint only after successful publish.
+			if published {
+				metrics.published(time.Since(publishStart))
+			}
+
+			// Advance checkpoint only after successful publish (or filtering).
 			if _, putErr := c.checkpointKV.Put(ctx, checkpointKey, []byte(seqNum)); putErr != nil {
 				log.With(errKey, putErr, "sequence_number", seqNum).Warn("failed to update checkpoint")
+			} else {
+				metrics.checkpointed()
 			}
 		}
 
@@ -190,12 +288,20 @@ func (c *TableConsumer) runShardConsumer(ctx context.Context, streamARN string,
 	}
 
 	// NextShardIterator being nil means the shard has been closed (no more records).
+	exhausted = true
 	log.Info("shard exhausted")
+
+	// Every record was checkpointed above, so the children of this shard can
+	// now be started.
+	if err := c.markShardClosed(ctx, shardID); err != nil {
+		log.With(errKey, err).Warn("failed to mark shard as closed; its child shards will wait for it to be re-read")
+	}
 }
 
 // getInitialIterator returns a shard iterator, resuming from the last checkpoint
-// if one exists, or from TRIM_HORIZON / LATEST depending on config.
-func (c *TableConsumer) getInitialIterator(ctx context.Context, streamARN, shardID string) (*string, error) {
+// if one exists, or from TRIM_HORIZON / LATEST depending on config (always
+// TRIM_HORIZON when fromStart is set).
+func (c *TableConsumer) getInitialIterator(ctx context.Context, streamARN, shardID string, fromStart bool) (*string, error) {
 	checkpointKey := fmt.Sprintf("%s.%s", c.tableName, shardID)
 
 	var iteratorType dynamostypes.ShardIteratorType
@@ -208,7 +314,7 @@ func (c *TableConsumer) getInitialIterator(ctx context.Context, streamARN, shard
 		sequenceNumber = &seq
 		iteratorType = dynamostypes.ShardIteratorTypeAfterSequenceNumber
 	case errors.Is(err, jetstream.ErrKeyNotFound):
-		if c.config.StartFromLatest {
+		if c.config.StartFromLatest && !fromStart {
 			iteratorType = dynamostypes.ShardIteratorTypeLatest
 		} else {
 			iteratorType = dynamostypes.ShardIteratorTypeTrimHorizon
@@ -0,0 +1,210 @@
+// Package ddbconsume is the dynamodb-stream-consumer service, which reads
+// DynamoDB Streams and publishes each change record as a JSON message to a NATS
+// JetStream stream. It tracks per-shard sequence positions in a NATS KV bucket
+// so that it can resume from where it left off after a restart. It runs as the
+// ddb-consume subcommand of lfx-v1-sync-helper, and as the standalone
+// dynamodb-stream-consumer binary.
+//
+// Published subjects use the form: {NATS_SUBJECT_PREFIX}.{table_name}
+// (dots in table names are replaced with underscores), or the form set by
+// NATS_SUBJECT_TEMPLATE.
+//
+// Required environment variables:
+//
+//	DYNAMODB_TABLES  Comma-separated list of DynamoDB table names to consume.
+//
+// Optional environment variables (with defaults):
+//
+//	NATS_URL                    nats://nats:4222
+//	NATS_STREAM_NAME            dynamodb_streams
+//	NATS_SUBJECT_PREFIX         dynamodb_streams
+//	NATS_SUBJECT_TEMPLATE       {prefix}.{table}
+//	CHECKPOINT_BUCKET           dynamodb-stream-checkpoints
+//	AWS_REGION                  us-east-1
+//	AWS_ROLE_ARN                (unset; IRSA web identity role, with AWS_WEB_IDENTITY_TOKEN_FILE)
+//	AWS_ASSUME_ROLE_ARN         (unset; comma-separated roles to assume in order)
+//	AWS_ASSUME_ROLE_EXTERNAL_ID (unset)
+//	AWS_ROLE_SESSION_NAME       dynamodb-stream-consumer
+//	AWS_ASSUME_ROLE_DURATION_SEC 900
+//	START_FROM_LATEST           false  (use TRIM_HORIZON for new shards)
+//	POLL_INTERVAL_MS            1000
+//	SHARD_REFRESH_INTERVAL_SEC  30
+//	ITERATOR_AGE_WARNING_SEC    72000
+//	INCLUDE_OLD_IMAGE           false  (old images are always included in REMOVE events)
+//	RECORD_FILTERS              (unset; e.g. "itx-poll:project_id^=test-")
+//	PORT                        8080
+//	BIND                        *
+//	DEBUG                       false
+package ddbconsume
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+const (
+	errKey = "error"
+
+	// serviceName and statusSubject identify the NATS micro service.
+	serviceName   = "dynamodb-stream-consumer"
+	statusSubject = "lfx.dynamodb_stream_consumer.status"
+)
+
+var (
+	logger   *slog.Logger
+	cfg      *Config
+	natsConn *nats.Conn
+)
+
+// Main runs the service with the given command-line arguments (excluding the
+// program or subcommand name), until SIGINT or SIGTERM is received.
+func Main(name string, args []string) {
+	var err error
+	cfg, err = LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var debug = flags.Bool("d", false, "enable debug logging")
+	var port = flags.String("p", cfg.Port, "health checks port")
+	var bind = flags.String("bind", cfg.Bind, "interface to bind on")
+	_ = flags.Parse(args)
+
+	logLevel := slog.LevelInfo
+	if cfg.Debug || *debug {
+		logLevel = slog.LevelDebug
+	}
+	logger = bootstrap.NewLogger(logLevel, logLevel == slog.LevelDebug, cfg.LogScrubFields)
+
+	// Health check server. Handlers are registered on a dedicated mux rather
+	// than http.DefaultServeMux, so handlers registered by imported packages
+	// are never exposed on the health port.
+	healthMux := bootstrap.NewHealthMux(func() *nats.Conn { return natsConn })
+	healthMux.HandleFunc("/metrics", metricsHandler)
+	httpServer := bootstrap.StartHTTPServer(logger, "health", bootstrap.ListenAddr(*bind, *port), healthMux)
+
+	gracefulCloseWG := sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := bootstrap.ShutdownSignals()
+
+	// Connect to NATS.
+	natsConn, err = bootstrap.ConnectNATS(ctx, logger, cfg.NATSURL, &gracefulCloseWG, done)
+	if err != nil {
+		logger.With(errKey, err).Error("error creating NATS client")
+		os.Exit(1)
+	}
+
+	jsCtx, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.With(errKey, err).Error("error creating JetStream context")
+		os.Exit(1)
+	}
+
+	// Create (or update) the NATS JetStream stream that receives DynamoDB events.
+	_, err = jsCtx.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        cfg.NATSStreamName,
+		Subjects:    []string{cfg.NATSSubjectPrefix + ".>"},
+		Retention:   jetstream.LimitsPolicy,
+		MaxAge:      14 * 24 * time.Hour,
+		Storage:     jetstream.FileStorage,
+		Compression: jetstream.S2Compression,
+		Description: "DynamoDB Streams change events",
+	})
+	if err != nil {
+		logger.With(errKey, err, "stream", cfg.NATSStreamName).Error("error creating NATS stream")
+		os.Exit(1)
+	}
+
+	// Register as a NATS micro service, for the platform's service discovery
+	// and stats.
+	service, err := bootstrap.AddService(natsConn, serviceName, "Publishes DynamoDB stream records to NATS", statusSubject, serviceStatus)
+	if err != nil {
+		logger.With(errKey, err).Error("error registering NATS micro service")
+		os.Exit(1)
+	}
+
+	// Create (or get) the KV bucket used to store per-shard sequence checkpoints.
+	checkpointKV, err := jsCtx.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      cfg.CheckpointBucket,
+		Description: "DynamoDB stream shard sequence checkpoints",
+		Storage:     jetstream.FileStorage,
+		History:     1,
+	})
+	if err != nil {
+		logger.With(errKey, err, "bucket", cfg.CheckpointBucket).Error("error creating checkpoint KV bucket")
+		os.Exit(1)
+	}
+
+	// Load AWS configuration from the environment / instance profile.
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.AWSRegion))
+	if err != nil {
+		logger.With(errKey, err).Error("error loading AWS config")
+		os.Exit(1)
+	}
+
+	// Use web identity (IRSA) and/or assumed roles for cross-account DynamoDB access, if configured.
+	configureAWSCredentials(&awsCfg, cfg)
+
+	dynClient := dynamodb.NewFromConfig(awsCfg)
+	streamsClient := dynamodbstreams.NewFromConfig(awsCfg)
+
+	// Start one TableConsumer per configured table.
+	var consumerWG sync.WaitGroup
+	for _, tableName := range cfg.Tables {
+		tableName := tableName
+		consumer := &TableConsumer{
+			tableName:     tableName,
+			config:        cfg,
+			dynClient:     dynClient,
+			streamsClient: streamsClient,
+			js:            jsCtx,
+			checkpointKV:  checkpointKV,
+			logger:        logger.With("table", tableName),
+		}
+		consumerWG.Add(1)
+		go func() {
+			defer consumerWG.Done()
+			if err := consumer.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.With(errKey, err, "table", tableName).Error("table consumer error")
+			}
+		}()
+	}
+
+	// Block until SIGINT / SIGTERM.
+	<-done
+	logger.Debug("beginning graceful shutdown")
+
+	// Cancel the context so all consumer goroutines exit.
+	cancel()
+	consumerWG.Wait()
+
+	if err := service.Stop(); err != nil {
+		logger.With(errKey, err).Error("error stopping NATS micro service")
+	}
+
+	// Drain the NATS connection (flushes pending publishes).
+	if err := bootstrap.DrainNATS(logger, natsConn, &gracefulCloseWG); err != nil {
+		logger.With(errKey, err).Error("error draining NATS connection")
+		os.Exit(1)
+	}
+
+	if err = httpServer.Close(); err != nil {
+		logger.With(errKey, err).Error("http listener error on close")
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+// Minimal, dependency-free metrics registry exposed in the Prometheus text
+// exposition format on the health server's /metrics endpoint.
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// collector is a metric family rendered in the Prometheus text exposition format.
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	metricsMu       sync.Mutex
+	metricsRegistry []collector
+)
+
+// register adds a collector to the metrics registry.
+func register(c collector) {
+	metricsMu.Lock()
+	metricsRegistry = append(metricsRegistry, c)
+	metricsMu.Unlock()
+}
+
+// metricVec holds float values partitioned by a fixed set of label names.
+type metricVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64 // keyed by label values joined with "\xff"
+}
+
+func newMetricVec(name, help string, labels []string) metricVec {
+	return metricVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+}
+
+// update applies fn to the value for the given label values.
+func (m *metricVec) update(fn func(float64) float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	m.mu.Lock()
+	m.values[key] = fn(m.values[key])
+	m.mu.Unlock()
+}
+
+// delete removes the value for the given label values, so series of closed
+// shards are not exported forever.
+func (m *metricVec) delete(labelValues ...string) {
+	m.mu.Lock()
+	delete(m.values, strings.Join(labelValues, "\xff"))
+	m.mu.Unlock()
+}
+
+// writeSamples renders the values, sorted by label values, with the given
+// metric name suffix.
+func (m *metricVec) writeSamples(w io.Writer, suffix string) {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]float64, len(keys))
+	for i, k := range keys {
+		values[i] = m.values[k]
+	}
+	m.mu.Unlock()
+
+	for i, k := range keys {
+		fmt.Fprintf(w, "%s%s%s %s\n", m.name, suffix, formatLabels(m.labels, strings.Split(k, "\xff")), formatValue(values[i]))
+	}
+}
+
+// totalsBy returns the values summed by the value of the label at index i.
+func (m *metricVec) totalsBy(i int) map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totals := map[string]float64{}
+	for k, v := range m.values {
+		totals[strings.Split(k, "\xff")[i]] += v
+	}
+	return totals
+}
+
+// counterVec is a monotonically increasing counter partitioned by labels.
+type counterVec struct {
+	metricVec
+}
+
+// newCounterVec creates and registers a counter with the given label names.
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	c := &counterVec{metricVec: newMetricVec(name, help, labels)}
+	register(c)
+	return c
+}
+
+// add increments the counter for the given label values by n.
+func (c *counterVec) add(n float64, labelValues ...string) {
+	c.update(func(v float64) float64 { return v + n }, labelValues...)
+}
+
+// write renders the counter in the Prometheus text exposition format.
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	c.writeSamples(w, "")
+}
+
+// gaugeVec is a value which can go up and down, partitioned by labels.
+type gaugeVec struct {
+	metricVec
+}
+
+// newGaugeVec creates and registers a gauge with the given label names.
+func newGaugeVec(name, help string, labels ...string) *gaugeVec {
+	g := &gaugeVec{metricVec: newMetricVec(name, help, labels)}
+	register(g)
+	return g
+}
+
+// set sets the gauge for the given label values.
+func (g *gaugeVec) set(value float64, labelValues ...string) {
+	g.update(func(float64) float64 { return value }, labelValues...)
+}
+
+// write renders the gauge in the Prometheus text exposition format.
+func (g *gaugeVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	g.writeSamples(w, "")
+}
+
+// summaryVec tracks the sum and count of observations (such as latencies),
+// partitioned by labels, from which the average can be computed.
+type summaryVec struct {
+	sum   metricVec
+	count metricVec
+}
+
+// newSummaryVec creates and registers a summary with the given label names.
+func newSummaryVec(name, help string, labels ...string) *summaryVec {
+	s := &summaryVec{
+		sum:   newMetricVec(name, help, labels),
+		count: newMetricVec(name, help, labels),
+	}
+	register(s)
+	return s
+}
+
+// observe records one observation for the given label values.
+func (s *summaryVec) observe(value float64, labelValues ...string) {
+	s.sum.update(func(v float64) float64 { return v + value }, labelValues...)
+	s.count.update(func(v float64) float64 { return v + 1 }, labelValues...)
+}
+
+// write renders the summary in the Prometheus text exposition format.
+func (s *summaryVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n", s.sum.name, s.sum.help, s.sum.name)
+	s.sum.writeSamples(w, "_sum")
+	s.count.writeSamples(w, "_count")
+}
+
+// formatLabels renders label pairs as {name="value",...}, or an empty string
+// when there are no labels.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue renders a sample value, using integer notation for whole
+// numbers so counters stay readable.
+func formatValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// metricsHandler serves all registered metrics in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metricsMu.Lock()
+	registry := make([]collector, len(metricsRegistry))
+	copy(registry, metricsRegistry)
+	metricsMu.Unlock()
+
+	for _, c := range registry {
+		c.write(w)
+	}
+}
@@ -1,18 +1,19 @@
 // Copyright The Linux Foundation and each contributor to LFX.
 // SPDX-License-Identifier: MIT
 
-// The dynamodb-stream-consumer service.
-package main
+package ddbconsume
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	dynamostypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
 	nats "github.com/nats-io/nats.go"
 )
 
@@ -28,13 +29,20 @@ type DynamoDBStreamEvent struct {
 	// identifier without needing to know the full item schema.
 	Keys     map[string]interface{} `json:"keys,omitempty"`
 	NewImage map[string]interface{} `json:"new_image,omitempty"`
+	// OldImage is always included in REMOVE events, and in MODIFY events only
+	// when INCLUDE_OLD_IMAGE is enabled.
 	OldImage map[string]interface{} `json:"old_image,omitempty"`
+	// ChangedFields lists the (sorted) attributes which differ between the old
+	// and new image of a MODIFY event; it is empty when nothing changed, and
+	// null for other events or when the stream does not include both images.
+	ChangedFields []string `json:"changed_fields"`
 }
 
 // publishRecord converts a DynamoDB stream record to a DynamoDBStreamEvent and publishes it to NATS.
-func (c *TableConsumer) publishRecord(ctx context.Context, record dynamostypes.Record) error {
+// It returns false when the record was dropped by a record filter instead of being published.
+func (c *TableConsumer) publishRecord(ctx context.Context, record dynamostypes.Record) (bool, error) {
 	if record.Dynamodb == nil {
-		return fmt.Errorf("record has nil Dynamodb field")
+		return false, fmt.Errorf("record has nil Dynamodb field")
 	}
 
 	event := DynamoDBStreamEvent{
@@ -53,12 +61,28 @@ func (c *TableConsumer) publishRecord(ctx context.Context, record dynamostypes.R
 		event.ApproximateCreationTime = *record.Dynamodb.ApproximateCreationDateTime
 	}
 
+	if record.EventName == dynamostypes.OperationTypeModify && event.NewImage != nil && event.OldImage != nil {
+		event.ChangedFields = changedFields(event.OldImage, event.NewImage)
+	}
+
+	if filter, filtered := matchRecordFilter(c.config.RecordFilters, event); filtered {
+		recordsFiltered.add(1, c.tableName, filter.String())
+		c.logger.With("filter", filter.String(), "event_name", event.EventName, "sequence_number", event.SequenceNumber).
+			DebugContext(ctx, "DynamoDB stream event dropped by record filter")
+		return false, nil
+	}
+
+	// The old image of a REMOVE event is the only image of the deleted item.
+	if !c.config.IncludeOldImage && record.EventName != dynamostypes.OperationTypeRemove {
+		event.OldImage = nil
+	}
+
 	data, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return false, fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	subject := subjectForTable(c.config.NATSSubjectPrefix, c.tableName)
+	subject := c.config.SubjectTemplate.render(c.config.NATSSubjectPrefix, c.tableName, event.Keys)
 
 	msg := &nats.Msg{
 		Subject: subject,
@@ -68,22 +92,37 @@ func (c *TableConsumer) publishRecord(ctx context.Context, record dynamostypes.R
 	// Use the sequence number as the deduplication ID so NATS won't re-deliver
 	// if we restart and re-read records we already published.
 	msg.Header.Set("Nats-Msg-Id", event.SequenceNumber)
+	// Start the correlation ID of the change, carried through the sync
+	// helper log lines and messages.
+	ctx = bootstrap.WithCorrelationID(ctx, bootstrap.NewID())
+	bootstrap.SetCorrelationHeader(ctx, msg)
 
 	if _, err := c.js.PublishMsg(ctx, msg); err != nil {
-		return fmt.Errorf("failed to publish to NATS subject %s: %w", subject, err)
+		return false, fmt.Errorf("failed to publish to NATS subject %s: %w", subject, err)
 	}
 
 	c.logger.With("subject", subject, "event_name", event.EventName, "sequence_number", event.SequenceNumber).
 		DebugContext(ctx, "published DynamoDB stream event")
 
-	return nil
+	return true, nil
 }
 
-// subjectForTable constructs a NATS subject for the given table name, sanitizing
-// any characters that have special meaning in NATS subjects (dots become underscores).
-func subjectForTable(prefix, tableName string) string {
-	safe := strings.NewReplacer(".", "_", " ", "_").Replace(tableName)
-	return prefix + "." + safe
+// changedFields returns the sorted names of the attributes added, removed, or
+// modified between two images.
+func changedFields(oldImage, newImage map[string]interface{}) []string {
+	changed := []string{}
+	for field, newValue := range newImage {
+		if oldValue, ok := oldImage[field]; !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range oldImage {
+		if _, ok := newImage[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed
 }
 
 // convertImage converts a map of DynamoDB stream AttributeValue types to
@@ -0,0 +1,105 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allTables is the table of a record filter which applies to every table.
+const allTables = "*"
+
+// recordFilter drops the stream records of a table whose image has a field
+// equal to (or, for prefix filters, starting with) a value. Filters are
+// configured with the RECORD_FILTERS environment variable as a comma-separated
+// list of "<table>:<field>=<value>" and "<table>:<field>^=<value>" rules, where
+// the table may be "*" for all tables.
+type recordFilter struct {
+	table  string
+	field  string
+	value  string
+	prefix bool
+}
+
+var recordsFiltered = newCounterVec(
+	"dynamodb_stream_consumer_records_filtered_total",
+	"Number of DynamoDB stream records dropped by record filters instead of being published, by table and filter.",
+	"table", "filter",
+)
+
+// parseRecordFilters parses a comma-separated list of record filter rules.
+func parseRecordFilters(rules string) ([]recordFilter, error) {
+	filters := []recordFilter{}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		table, predicate, ok := strings.Cut(rule, ":")
+		if !ok || table == "" {
+			return nil, fmt.Errorf("invalid record filter %q: expected <table>:<field>=<value> or <table>:<field>^=<value>", rule)
+		}
+
+		var filter recordFilter
+		if field, value, ok := strings.Cut(predicate, "^="); ok {
+			filter = recordFilter{table: table, field: field, value: value, prefix: true}
+		} else if field, value, ok := strings.Cut(predicate, "="); ok {
+			filter = recordFilter{table: table, field: field, value: value}
+		} else {
+			return nil, fmt.Errorf("invalid record filter %q: expected <table>:<field>=<value> or <table>:<field>^=<value>", rule)
+		}
+		if filter.field == "" {
+			return nil, fmt.Errorf("invalid record filter %q: missing field name", rule)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// String returns the filter in its configuration syntax.
+func (f recordFilter) String() string {
+	op := "="
+	if f.prefix {
+		op = "^="
+	}
+	return f.table + ":" + f.field + op + f.value
+}
+
+// matches reports whether the filter drops a record of the table with the
+// given image.
+func (f recordFilter) matches(tableName string, image map[string]interface{}) bool {
+	if f.table != allTables && f.table != tableName {
+		return false
+	}
+	raw, ok := image[f.field]
+	if !ok || raw == nil {
+		return false
+	}
+	value := fmt.Sprint(raw)
+	if f.prefix {
+		return strings.HasPrefix(value, f.value)
+	}
+	return value == f.value
+}
+
+// matchRecordFilter returns the first filter dropping the event, if any. The
+// new image is evaluated, or the old image for REMOVE events, so the deletion
+// of a filtered item is dropped too.
+func matchRecordFilter(filters []recordFilter, event DynamoDBStreamEvent) (recordFilter, bool) {
+	image := event.NewImage
+	if image == nil {
+		image = event.OldImage
+	}
+	if image == nil {
+		return recordFilter{}, false
+	}
+	for _, filter := range filters {
+		if filter.matches(event.TableName, image) {
+			return filter, true
+		}
+	}
+	return recordFilter{}, false
+}
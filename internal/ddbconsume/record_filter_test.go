@@ -0,0 +1,81 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import "testing"
+
+func TestParseRecordFilters(t *testing.T) {
+	tests := []struct {
+		rules string
+		want  []string
+	}{
+		{"", []string{}},
+		{"meetings:type=test", []string{"meetings:type=test"}},
+		{" meetings:topic^=[TEST] , *:deleted=true ", []string{"meetings:topic^=[TEST]", "*:deleted=true"}},
+		{"meetings:url=https://example.com/?a=b", []string{"meetings:url=https://example.com/?a=b"}},
+		{"meetings:type=", []string{"meetings:type="}},
+		{"type=test", nil},
+		{":type=test", nil},
+		{"meetings:type", nil},
+		{"meetings:=test", nil},
+	}
+	for _, tt := range tests {
+		filters, err := parseRecordFilters(tt.rules)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parseRecordFilters(%q): got %v, want an error", tt.rules, filters)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRecordFilters(%q): %v", tt.rules, err)
+			continue
+		}
+		got := make([]string, len(filters))
+		for i, filter := range filters {
+			got[i] = filter.String()
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseRecordFilters(%q) = %v, want %v", tt.rules, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseRecordFilters(%q) = %v, want %v", tt.rules, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchRecordFilter(t *testing.T) {
+	filters, err := parseRecordFilters("meetings:topic^=[TEST],*:deleted=true,registrants:host=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		event DynamoDBStreamEvent
+		want  string
+	}{
+		{"prefix", DynamoDBStreamEvent{TableName: "meetings", NewImage: map[string]interface{}{"topic": "[TEST] sync"}}, "meetings:topic^=[TEST]"},
+		{"prefix of another table", DynamoDBStreamEvent{TableName: "past_meetings", NewImage: map[string]interface{}{"topic": "[TEST] sync"}}, ""},
+		{"all tables", DynamoDBStreamEvent{TableName: "past_meetings", NewImage: map[string]interface{}{"deleted": true}}, "*:deleted=true"},
+		{"non-string value", DynamoDBStreamEvent{TableName: "registrants", NewImage: map[string]interface{}{"host": true}}, "registrants:host=true"},
+		{"other value", DynamoDBStreamEvent{TableName: "meetings", NewImage: map[string]interface{}{"topic": "Weekly [TEST]", "deleted": false}}, ""},
+		{"missing field", DynamoDBStreamEvent{TableName: "meetings", NewImage: map[string]interface{}{"deleted": nil}}, ""},
+		{"old image of a remove", DynamoDBStreamEvent{TableName: "meetings", EventName: "REMOVE", OldImage: map[string]interface{}{"topic": "[TEST] sync"}}, "meetings:topic^=[TEST]"},
+		{"new image over old image", DynamoDBStreamEvent{TableName: "meetings", NewImage: map[string]interface{}{"topic": "sync"}, OldImage: map[string]interface{}{"topic": "[TEST] sync"}}, ""},
+		{"no image", DynamoDBStreamEvent{TableName: "meetings"}, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if filter, ok := matchRecordFilter(filters, tt.event); ok {
+			got = filter.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: matched filter %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import (
+	"log/slog"
+	"time"
+
+	dynamostypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+const (
+	// streamTrimHorizon is how long DynamoDB Streams retains records. Records
+	// not read within it are trimmed, and silently lost to the consumer.
+	streamTrimHorizon = 24 * time.Hour
+	// iteratorAgeWarningInterval limits how often the iterator age warning is
+	// logged for a shard.
+	iteratorAgeWarningInterval = 5 * time.Minute
+)
+
+var (
+	shardRecordsRead = newCounterVec(
+		"dynamodb_stream_consumer_records_read_total",
+		"Number of records read from DynamoDB stream shards, by table and shard.",
+		"table", "shard_id",
+	)
+	shardIteratorAge = newGaugeVec(
+		"dynamodb_stream_consumer_iterator_age_seconds",
+		"Age of the oldest record in the last batch read from the shard (0 when caught up), by table and shard.",
+		"table", "shard_id",
+	)
+	shardCheckpointLag = newGaugeVec(
+		"dynamodb_stream_consumer_checkpoint_lag_records",
+		"Number of records published from the shard since its last successful checkpoint, by table and shard.",
+		"table", "shard_id",
+	)
+	publishDuration = newSummaryVec(
+		"dynamodb_stream_consumer_publish_duration_seconds",
+		"Time spent publishing DynamoDB stream records to NATS, by table.",
+		"table",
+	)
+)
+
+// tableStatus is the processing status of a table, reported by the NATS micro
+// service.
+type tableStatus struct {
+	Published uint64 `json:"published"`
+	Filtered  uint64 `json:"filtered"`
+}
+
+// serviceStatus returns the records published and filtered per table since
+// startup.
+func serviceStatus() any {
+	tables := map[string]*tableStatus{}
+	status := func(table string) *tableStatus {
+		if tables[table] == nil {
+			tables[table] = &tableStatus{}
+		}
+		return tables[table]
+	}
+	for table, n := range publishDuration.count.totalsBy(0) {
+		status(table).Published = uint64(n)
+	}
+	for table, n := range recordsFiltered.totalsBy(0) {
+		status(table).Filtered = uint64(n)
+	}
+	return map[string]any{"tables": tables}
+}
+
+// shardMetrics records the metrics of one shard consumer.
+type shardMetrics struct {
+	table   string
+	shardID string
+	log     *slog.Logger
+
+	warningAge     time.Duration
+	lastWarnedAt   time.Time
+	uncheckpointed int
+}
+
+func newShardMetrics(table, shardID string, warningAge time.Duration, log *slog.Logger) *shardMetrics {
+	return &shardMetrics{
+		table:      table,
+		shardID:    shardID,
+		log:        log,
+		warningAge: warningAge,
+	}
+}
+
+// recordsRead records a batch of records read from the shard, updating the
+// iterator age, and warns when it approaches the stream trim horizon.
+func (m *shardMetrics) recordsRead(records []dynamostypes.Record) {
+	shardRecordsRead.add(float64(len(records)), m.table, m.shardID)
+
+	var age time.Duration
+	for _, record := range records {
+		if record.Dynamodb != nil && record.Dynamodb.ApproximateCreationDateTime != nil {
+			age = time.Since(*record.Dynamodb.ApproximateCreationDateTime)
+			break
+		}
+	}
+	shardIteratorAge.set(age.Seconds(), m.table, m.shardID)
+
+	if age >= m.warningAge && time.Since(m.lastWarnedAt) >= iteratorAgeWarningInterval {
+		m.lastWarnedAt = time.Now()
+		m.log.With(
+			"iterator_age", age.Round(time.Second).String(),
+			"trim_horizon", streamTrimHorizon.String(),
+			"remaining", (streamTrimHorizon - age).Round(time.Second).String(),
+		).Warn("shard iterator age is approaching the stream trim horizon; records not read before it are lost")
+	}
+}
+
+// published records the latency of publishing a record.
+func (m *shardMetrics) published(duration time.Duration) {
+	publishDuration.observe(duration.Seconds(), m.table)
+	m.uncheckpointed++
+	shardCheckpointLag.set(float64(m.uncheckpointed), m.table, m.shardID)
+}
+
+// checkpointed records a successful checkpoint write.
+func (m *shardMetrics) checkpointed() {
+	m.uncheckpointed = 0
+	shardCheckpointLag.set(0, m.table, m.shardID)
+}
+
+// stop removes the gauges of the shard when its consumer stops, and its
+// counter too when the shard is exhausted and will not be read again.
+func (m *shardMetrics) stop(exhausted bool) {
+	shardIteratorAge.delete(m.table, m.shardID)
+	shardCheckpointLag.delete(m.table, m.shardID)
+	if exhausted {
+		shardRecordsRead.delete(m.table, m.shardID)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultSubjectTemplate publishes all records of a table on one subject.
+const defaultSubjectTemplate = "{prefix}.{table}"
+
+var (
+	subjectPlaceholderPattern = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+	pkHashModPattern          = regexp.MustCompile(`^pk_hash_mod_([0-9]+)$`)
+)
+
+// subjectTemplate renders the NATS subject of a record. The supported
+// placeholders are:
+//   - {prefix}: the subject prefix (NATS_SUBJECT_PREFIX)
+//   - {table}: the table name, with dots and spaces (which have special
+//     meaning in NATS subjects) replaced by underscores
+//   - {pk_hash_mod_N}: a hash of the record's primary key modulo N, so records
+//     of the same item always share a subject (preserving per-item ordering)
+//     while downstream consumers can parallelize across subjects
+type subjectTemplate struct {
+	template string
+}
+
+// parseSubjectTemplate validates a subject template. The template must start
+// with "{prefix}." so rendered subjects stay within the stream's subjects.
+func parseSubjectTemplate(template string) (subjectTemplate, error) {
+	if !strings.HasPrefix(template, "{prefix}.") {
+		return subjectTemplate{}, fmt.Errorf("subject template %q must start with {prefix}.", template)
+	}
+	for _, match := range subjectPlaceholderPattern.FindAllStringSubmatch(template, -1) {
+		name := match[1]
+		if name == "prefix" || name == "table" {
+			continue
+		}
+		m := pkHashModPattern.FindStringSubmatch(name)
+		if m == nil {
+			return subjectTemplate{}, fmt.Errorf("subject template %q has unknown placeholder {%s}", template, name)
+		}
+		if n, err := strconv.Atoi(m[1]); err != nil || n < 1 {
+			return subjectTemplate{}, fmt.Errorf("subject template %q has invalid modulus in {%s}", template, name)
+		}
+	}
+	if strings.ContainsAny(subjectPlaceholderPattern.ReplaceAllString(template, "x"), "{}*> \t") {
+		return subjectTemplate{}, fmt.Errorf("subject template %q has invalid characters", template)
+	}
+	return subjectTemplate{template: template}, nil
+}
+
+// render returns the subject of a record of the table with the given primary key.
+func (t subjectTemplate) render(prefix, tableName string, keys map[string]interface{}) string {
+	return subjectPlaceholderPattern.ReplaceAllStringFunc(t.template, func(placeholder string) string {
+		name := strings.Trim(placeholder, "{}")
+		switch name {
+		case "prefix":
+			return prefix
+		case "table":
+			return strings.NewReplacer(".", "_", " ", "_").Replace(tableName)
+		}
+		// Validated by parseSubjectTemplate.
+		modulus, _ := strconv.Atoi(pkHashModPattern.FindStringSubmatch(name)[1])
+		return strconv.FormatUint(uint64(primaryKeyHash(keys)%uint32(modulus)), 10)
+	})
+}
+
+// primaryKeyHash returns a stable FNV-1a hash of a record's primary key
+// attributes and values.
+func primaryKeyHash(keys map[string]interface{}) uint32 {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := fnv.New32a()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%v\x00", name, keys[name])
+	}
+	return h.Sum32()
+}
@@ -0,0 +1,58 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package ddbconsume
+
+import "testing"
+
+func TestParseSubjectTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{defaultSubjectTemplate, true},
+		{"{prefix}.{table}.{pk_hash_mod_16}", true},
+		{"{prefix}.v1.{table}", true},
+		{"{table}.{prefix}", false},
+		{"{prefix}.{table}.{region}", false},
+		{"{prefix}.{table}.{pk_hash_mod_0}", false},
+		{"{prefix}.{table}.*", false},
+		{"{prefix}.{table}.>", false},
+		{"{prefix}.{table} x", false},
+	}
+	for _, tt := range tests {
+		if _, err := parseSubjectTemplate(tt.template); (err == nil) != tt.valid {
+			t.Errorf("parseSubjectTemplate(%q): got error %v, want valid %v", tt.template, err, tt.valid)
+		}
+	}
+}
+
+func TestSubjectTemplateRender(t *testing.T) {
+	tests := []struct {
+		template string
+		table    string
+		keys     map[string]interface{}
+		want     string
+	}{
+		{defaultSubjectTemplate, "itx-zoom-meetings-v2", nil, "lfx.ddb.itx-zoom-meetings-v2"},
+		{defaultSubjectTemplate, "prod.zoom meetings", nil, "lfx.ddb.prod_zoom_meetings"},
+		// FNV-1a of "id=abc\x00" is 2534008203.
+		{"{prefix}.{table}.{pk_hash_mod_8}", "meetings", map[string]interface{}{"id": "abc"}, "lfx.ddb.meetings.3"},
+		// Key attributes are hashed in name order: FNV-1a of
+		// "meeting_id=91234567890\x00registrant_id=r1\x00" is 885907356.
+		{
+			"{prefix}.{table}.{pk_hash_mod_16}", "registrants",
+			map[string]interface{}{"registrant_id": "r1", "meeting_id": "91234567890"},
+			"lfx.ddb.registrants.12",
+		},
+	}
+	for _, tt := range tests {
+		template, err := parseSubjectTemplate(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := template.render("lfx.ddb", tt.table, tt.keys); got != tt.want {
+			t.Errorf("render of %q for %s %v: got %q, want %q", tt.template, tt.table, tt.keys, got, tt.want)
+		}
+	}
+}
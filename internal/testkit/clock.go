@@ -0,0 +1,85 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package testkit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+)
+
+var (
+	_ bootstrap.Clock       = (*Clock)(nil)
+	_ bootstrap.IDGenerator = (*IDs)(nil)
+)
+
+// Clock is a manual clock, only moving when set or advanced, so runs produce
+// the same times. Install it with bootstrap.SetClock.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock stopped at FixtureTime.
+func NewClock() *Clock {
+	return &Clock{now: FixtureTime}
+}
+
+// Now implements bootstrap.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the time of the clock.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// IDs generates sequential UUIDs (00000000-0000-4000-8000-000000000001, and
+// so on), so runs produce the same IDs. Install it with
+// bootstrap.SetIDGenerator.
+type IDs struct {
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewIDs returns a generator starting at the first sequential UUID.
+func NewIDs() *IDs {
+	return &IDs{next: 1}
+}
+
+// NewID implements bootstrap.IDGenerator.
+func (g *IDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := fmt.Sprintf("00000000-0000-4000-8000-%012d", g.next)
+	g.next++
+	return id
+}
+
+// Install sets a new clock and ID generator for the duration of a test, and
+// returns them. The system time and random IDs are restored on cleanup.
+func Install(t interface{ Cleanup(func()) }) (*Clock, *IDs) {
+	clock, ids := NewClock(), NewIDs()
+	bootstrap.SetClock(clock)
+	bootstrap.SetIDGenerator(ids)
+	t.Cleanup(func() {
+		bootstrap.SetClock(nil)
+		bootstrap.SetIDGenerator(nil)
+	})
+	return clock, ids
+}
@@ -0,0 +1,139 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package testkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// FixtureTime is the reference time of the built records, so they are
+// deterministic.
+var FixtureTime = time.Date(2025, time.January, 15, 16, 0, 0, 0, time.UTC)
+
+// Record is a v1 record, keyed as in the v1-objects bucket. Records use the
+// key prefixes and field names of the v1 DynamoDB tables replicated by
+// Meltano, so they are processed by the sync handlers unchanged.
+type Record struct {
+	Key  string
+	Data map[string]any
+}
+
+// With returns a copy of the record with a field set, or removed if value is
+// nil.
+func (r Record) With(field string, value any) Record {
+	data := maps.Clone(r.Data)
+	if value == nil {
+		delete(data, field)
+	} else {
+		data[field] = value
+	}
+	return Record{Key: r.Key, Data: data}
+}
+
+// JSON returns the record data encoded as in the v1-objects bucket.
+func (r Record) JSON() []byte {
+	value, err := json.Marshal(r.Data)
+	if err != nil {
+		panic(fmt.Sprintf("testkit: failed to marshal record %s: %v", r.Key, err))
+	}
+	return value
+}
+
+// Put writes the record to a bucket.
+func (r Record) Put(ctx context.Context, kv *KV) (uint64, error) {
+	return kv.Put(ctx, r.Key, r.JSON())
+}
+
+// Entry returns the record as a KV entry of the v1-objects bucket, e.g. to
+// pass to a KV handler directly.
+func (r Record) Entry(revision uint64) *Entry {
+	return NewEntry("v1-objects", r.Key, r.JSON(), revision, jetstream.KeyValuePut)
+}
+
+// V1Meeting returns a v1 meeting of a project.
+func V1Meeting(meetingID, projectSFID string) Record {
+	timestamp := FixtureTime.Format(time.RFC3339)
+	return Record{
+		Key: "itx-zoom-meetings-v2." + meetingID,
+		Data: map[string]any{
+			"meeting_id":         meetingID,
+			"proj_id":            projectSFID,
+			"topic":              "Technical Steering Committee",
+			"agenda":             "Test meeting built by testkit.",
+			"visibility":         "public",
+			"meeting_type":       "None",
+			"start_time":         FixtureTime.Add(24 * time.Hour).Format(time.RFC3339),
+			"timezone":           "UTC",
+			"duration":           60,
+			"restricted":         false,
+			"recording_enabled":  true,
+			"recording_access":   "meeting_participants",
+			"transcript_enabled": false,
+			"transcript_access":  "meeting_hosts",
+			"ai_summary_access":  "meeting_hosts",
+			"committees":         []any{},
+			"created_at":         timestamp,
+			"modified_at":        timestamp,
+		},
+	}
+}
+
+// V1Registrant returns a direct registrant of a v1 meeting.
+func V1Registrant(registrantID, meetingID, projectSFID string) Record {
+	timestamp := FixtureTime.Format(time.RFC3339)
+	return Record{
+		Key: "itx-zoom-meetings-registrants-v2." + registrantID,
+		Data: map[string]any{
+			"registrant_id": registrantID,
+			"meeting_id":    meetingID,
+			"proj_id":       projectSFID,
+			"type":          "direct",
+			"email":         registrantID + "@example.com",
+			"first_name":    "Alex",
+			"last_name":     "Garcia",
+			"org":           "Example Corp",
+			"host":          false,
+			"created_at":    timestamp,
+			"modified_at":   timestamp,
+		},
+	}
+}
+
+// V1PastMeeting returns a v1 past meeting, for the occurrence of a meeting
+// starting at FixtureTime.
+func V1PastMeeting(meetingID, occurrenceID, projectSFID string) Record {
+	timestamp := FixtureTime.Format(time.RFC3339)
+	meetingAndOccurrenceID := meetingID + "-" + occurrenceID
+	return Record{
+		Key: "itx-zoom-past-meetings." + meetingAndOccurrenceID,
+		Data: map[string]any{
+			"meeting_and_occurrence_id": meetingAndOccurrenceID,
+			"meeting_id":                meetingID,
+			"occurrence_id":             occurrenceID,
+			"proj_id":                   projectSFID,
+			"topic":                     "Technical Steering Committee",
+			"agenda":                    "Test meeting built by testkit.",
+			"visibility":                "public",
+			"timezone":                  "UTC",
+			"duration":                  60,
+			"recording_access":          "meeting_participants",
+			"transcript_access":         "meeting_hosts",
+			"scheduled_start_time":      FixtureTime.Format(time.RFC3339),
+			"scheduled_end_time":        FixtureTime.Add(time.Hour).Format(time.RFC3339),
+			"sessions": []any{map[string]any{
+				"uuid":       meetingAndOccurrenceID + "-session",
+				"start_time": FixtureTime.Format(time.RFC3339),
+				"end_time":   FixtureTime.Add(time.Hour).Format(time.RFC3339),
+			}},
+			"created_at":  timestamp,
+			"modified_at": timestamp,
+		},
+	}
+}
@@ -0,0 +1,497 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+// Package testkit holds in-memory fakes of the NATS KV buckets and publisher
+// used by the lfx-v1-sync-helper handlers, and builders of v1 records, so
+// handler tests do not each stub NATS from scratch.
+package testkit
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxfoundation/lfx-v1-sync-helper/internal/bootstrap"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// KV is an in-memory jetstream.KeyValue bucket, keeping the full history of
+// every key. Revisions are sequential across the bucket, as in JetStream.
+//
+// Options of the jetstream package (e.g. jetstream.LastRevision on Delete, or
+// the WatchOpt options) are opaque outside of it, so they are accepted and
+// ignored: deletes are unconditional, and watchers always start with the
+// latest entry of each key.
+type KV struct {
+	bucket string
+
+	mu       sync.Mutex
+	revision uint64
+	history  map[string][]*Entry
+	watchers []*kvWatcher
+}
+
+var _ jetstream.KeyValue = (*KV)(nil)
+
+// NewKV returns an empty in-memory bucket.
+func NewKV(bucket string) *KV {
+	return &KV{bucket: bucket, history: map[string][]*Entry{}}
+}
+
+// Entry is an entry of an in-memory bucket.
+type Entry struct {
+	bucket    string
+	key       string
+	value     []byte
+	revision  uint64
+	created   time.Time
+	delta     uint64
+	operation jetstream.KeyValueOp
+}
+
+var _ jetstream.KeyValueEntry = (*Entry)(nil)
+
+// NewEntry returns a KV entry, e.g. to pass to a KV handler directly.
+func NewEntry(bucket, key string, value []byte, revision uint64, operation jetstream.KeyValueOp) *Entry {
+	return &Entry{bucket: bucket, key: key, value: value, revision: revision, created: bootstrap.Now().UTC(), operation: operation}
+}
+
+// Bucket is the bucket of the entry.
+func (e *Entry) Bucket() string { return e.bucket }
+
+// Key is the key of the entry.
+func (e *Entry) Key() string { return e.key }
+
+// Value is the value of the entry.
+func (e *Entry) Value() []byte { return e.value }
+
+// Revision is the revision of the entry.
+func (e *Entry) Revision() uint64 { return e.revision }
+
+// Created is the time the entry was written.
+func (e *Entry) Created() time.Time { return e.created }
+
+// Delta is the distance from the latest revision of the bucket, for watched
+// entries.
+func (e *Entry) Delta() uint64 { return e.delta }
+
+// Operation is the operation which wrote the entry.
+func (e *Entry) Operation() jetstream.KeyValueOp { return e.operation }
+
+// wrongLastRevision returns the error of a write expecting another revision,
+// as reported by JetStream.
+func wrongLastRevision(revision uint64) error {
+	return &jetstream.APIError{
+		Code:        400,
+		ErrorCode:   jetstream.JSErrCodeStreamWrongLastSequence,
+		Description: fmt.Sprintf("wrong last sequence: %d", revision),
+	}
+}
+
+// latest returns the latest entry of a key, if any. The lock must be held.
+func (kv *KV) latest(key string) *Entry {
+	entries := kv.history[key]
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[len(entries)-1]
+}
+
+// write appends an entry to the history of a key, purging its previous
+// entries on a purge, and notifies the watchers.
+func (kv *KV) write(key string, value []byte, operation jetstream.KeyValueOp, expected func(latest *Entry) error) (uint64, error) {
+	if key == "" || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") || strings.ContainsAny(key, " *>") {
+		return 0, jetstream.ErrInvalidKey
+	}
+
+	kv.mu.Lock()
+	if expected != nil {
+		if err := expected(kv.latest(key)); err != nil {
+			kv.mu.Unlock()
+			return 0, err
+		}
+	}
+	kv.revision++
+	entry := &Entry{
+		bucket:    kv.bucket,
+		key:       key,
+		value:     slices.Clone(value),
+		revision:  kv.revision,
+		created:   bootstrap.Now().UTC(),
+		operation: operation,
+	}
+	if operation == jetstream.KeyValuePurge {
+		kv.history[key] = nil
+	}
+	kv.history[key] = append(kv.history[key], entry)
+	watchers := slices.Clone(kv.watchers)
+	kv.mu.Unlock()
+
+	for _, w := range watchers {
+		if w.matches(key) {
+			w.send(entry)
+		}
+	}
+	return entry.revision, nil
+}
+
+// Get returns the latest value of a key, or jetstream.ErrKeyNotFound if it
+// does not exist or was deleted.
+func (kv *KV) Get(_ context.Context, key string) (jetstream.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entry := kv.latest(key)
+	if entry == nil || entry.operation != jetstream.KeyValuePut {
+		return nil, jetstream.ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+// GetRevision returns a revision of a key, or jetstream.ErrKeyDeleted if
+// that revision deleted the key.
+func (kv *KV) GetRevision(_ context.Context, key string, revision uint64) (jetstream.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for _, entry := range kv.history[key] {
+		if entry.revision != revision {
+			continue
+		}
+		if entry.operation != jetstream.KeyValuePut {
+			return nil, jetstream.ErrKeyDeleted
+		}
+		return entry, nil
+	}
+	return nil, jetstream.ErrKeyNotFound
+}
+
+// Put writes a new value of a key.
+func (kv *KV) Put(_ context.Context, key string, value []byte) (uint64, error) {
+	return kv.write(key, value, jetstream.KeyValuePut, nil)
+}
+
+// PutString writes a new string value of a key.
+func (kv *KV) PutString(ctx context.Context, key string, value string) (uint64, error) {
+	return kv.Put(ctx, key, []byte(value))
+}
+
+// Create writes the value of a key which does not exist (or was deleted), or
+// returns jetstream.ErrKeyExists.
+func (kv *KV) Create(_ context.Context, key string, value []byte, _ ...jetstream.KVCreateOpt) (uint64, error) {
+	return kv.write(key, value, jetstream.KeyValuePut, func(latest *Entry) error {
+		if latest != nil && latest.operation == jetstream.KeyValuePut {
+			return jetstream.ErrKeyExists
+		}
+		return nil
+	})
+}
+
+// Update writes a new value of a key if its latest revision is the given one
+// (zero for a key which never existed), or returns a wrong last sequence
+// error.
+func (kv *KV) Update(_ context.Context, key string, value []byte, revision uint64) (uint64, error) {
+	return kv.write(key, value, jetstream.KeyValuePut, func(latest *Entry) error {
+		var current uint64
+		if latest != nil {
+			current = latest.revision
+		}
+		if current != revision {
+			return wrongLastRevision(current)
+		}
+		return nil
+	})
+}
+
+// Delete writes a delete marker for a key.
+func (kv *KV) Delete(_ context.Context, key string, _ ...jetstream.KVDeleteOpt) error {
+	_, err := kv.write(key, nil, jetstream.KeyValueDelete, nil)
+	return err
+}
+
+// Purge removes the history of a key, leaving a purge marker.
+func (kv *KV) Purge(_ context.Context, key string, _ ...jetstream.KVDeleteOpt) error {
+	_, err := kv.write(key, nil, jetstream.KeyValuePurge, nil)
+	return err
+}
+
+// Watch watches the keys matching a NATS subject pattern.
+func (kv *KV) Watch(ctx context.Context, keys string, opts ...jetstream.WatchOpt) (jetstream.KeyWatcher, error) {
+	return kv.WatchFiltered(ctx, []string{keys}, opts...)
+}
+
+// WatchAll watches every key of the bucket.
+func (kv *KV) WatchAll(ctx context.Context, opts ...jetstream.WatchOpt) (jetstream.KeyWatcher, error) {
+	return kv.WatchFiltered(ctx, []string{">"}, opts...)
+}
+
+// WatchFiltered watches the keys matching any of the NATS subject patterns.
+// The latest entry of each matching key is sent first, followed by a nil
+// entry, then every later write.
+func (kv *KV) WatchFiltered(ctx context.Context, keys []string, _ ...jetstream.WatchOpt) (jetstream.KeyWatcher, error) {
+	w := newKVWatcher(keys)
+
+	kv.mu.Lock()
+	var initial []*Entry
+	for key := range kv.history {
+		if entry := kv.latest(key); entry != nil && w.matches(key) {
+			initial = append(initial, entry)
+		}
+	}
+	slices.SortFunc(initial, func(a, b *Entry) int { return cmp.Compare(a.revision, b.revision) })
+	for i, entry := range initial {
+		watched := *entry
+		watched.delta = uint64(len(initial) - 1 - i)
+		w.send(&watched)
+	}
+	w.send(nil)
+	kv.watchers = append(kv.watchers, w)
+	kv.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = w.Stop()
+		case <-w.stopped:
+		}
+		kv.mu.Lock()
+		kv.watchers = slices.DeleteFunc(kv.watchers, func(other *kvWatcher) bool { return other == w })
+		kv.mu.Unlock()
+	}()
+	return w, nil
+}
+
+// liveKeys returns the sorted keys which exist and match any of the patterns.
+func (kv *KV) liveKeys(patterns []string) []string {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	var keys []string
+	for key := range kv.history {
+		if entry := kv.latest(key); entry == nil || entry.operation != jetstream.KeyValuePut {
+			continue
+		}
+		if slices.ContainsFunc(patterns, func(pattern string) bool { return MatchSubject(pattern, key) }) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Keys returns the keys of the bucket, or jetstream.ErrNoKeysFound if it is
+// empty.
+func (kv *KV) Keys(_ context.Context, _ ...jetstream.WatchOpt) ([]string, error) {
+	keys := kv.liveKeys([]string{">"})
+	if len(keys) == 0 {
+		return nil, jetstream.ErrNoKeysFound
+	}
+	return keys, nil
+}
+
+// ListKeys lists the keys of the bucket.
+func (kv *KV) ListKeys(_ context.Context, _ ...jetstream.WatchOpt) (jetstream.KeyLister, error) {
+	return newKeyLister(kv.liveKeys([]string{">"})), nil
+}
+
+// ListKeysFiltered lists the keys of the bucket matching any of the NATS
+// subject patterns.
+func (kv *KV) ListKeysFiltered(_ context.Context, filters ...string) (jetstream.KeyLister, error) {
+	return newKeyLister(kv.liveKeys(filters)), nil
+}
+
+// History returns every entry of a key, oldest first.
+func (kv *KV) History(_ context.Context, key string, _ ...jetstream.WatchOpt) ([]jetstream.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entries := kv.history[key]
+	if len(entries) == 0 {
+		return nil, jetstream.ErrKeyNotFound
+	}
+	history := make([]jetstream.KeyValueEntry, len(entries))
+	for i, entry := range entries {
+		history[i] = entry
+	}
+	return history, nil
+}
+
+// Bucket returns the name of the bucket.
+func (kv *KV) Bucket() string {
+	return kv.bucket
+}
+
+// PurgeDeletes removes the keys whose latest entry is a delete or purge
+// marker.
+func (kv *KV) PurgeDeletes(_ context.Context, _ ...jetstream.KVPurgeOpt) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for key := range kv.history {
+		if entry := kv.latest(key); entry == nil || entry.operation != jetstream.KeyValuePut {
+			delete(kv.history, key)
+		}
+	}
+	return nil
+}
+
+// Status returns the status of the bucket.
+func (kv *KV) Status(_ context.Context) (jetstream.KeyValueStatus, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	status := &kvStatus{bucket: kv.bucket}
+	for _, entries := range kv.history {
+		for _, entry := range entries {
+			status.values++
+			status.bytes += uint64(len(entry.key) + len(entry.value))
+		}
+	}
+	return status, nil
+}
+
+// Snapshot returns the latest value of every existing key, for assertions.
+func (kv *KV) Snapshot() map[string][]byte {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	snapshot := map[string][]byte{}
+	for key := range kv.history {
+		if entry := kv.latest(key); entry != nil && entry.operation == jetstream.KeyValuePut {
+			snapshot[key] = slices.Clone(entry.value)
+		}
+	}
+	return snapshot
+}
+
+// kvStatus is the status of an in-memory bucket.
+type kvStatus struct {
+	bucket string
+	values uint64
+	bytes  uint64
+}
+
+func (s *kvStatus) Bucket() string                { return s.bucket }
+func (s *kvStatus) Values() uint64                { return s.values }
+func (s *kvStatus) History() int64                { return 0 }
+func (s *kvStatus) TTL() time.Duration            { return 0 }
+func (s *kvStatus) BackingStore() string          { return "memory" }
+func (s *kvStatus) Bytes() uint64                 { return s.bytes }
+func (s *kvStatus) IsCompressed() bool            { return false }
+func (s *kvStatus) LimitMarkerTTL() time.Duration { return 0 }
+func (s *kvStatus) Metadata() map[string]string   { return nil }
+
+// keyLister lists a fixed set of keys.
+type keyLister struct {
+	keys chan string
+}
+
+// newKeyLister returns a lister of the given keys.
+func newKeyLister(keys []string) *keyLister {
+	l := &keyLister{keys: make(chan string, len(keys))}
+	for _, key := range keys {
+		l.keys <- key
+	}
+	close(l.keys)
+	return l
+}
+
+func (l *keyLister) Keys() <-chan string { return l.keys }
+func (l *keyLister) Stop() error         { return nil }
+
+// kvWatcher delivers the entries of watched keys. Entries are queued, so
+// writes never block on a watcher which is not read.
+type kvWatcher struct {
+	patterns []string
+	updates  chan jetstream.KeyValueEntry
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.Mutex
+	queue  []jetstream.KeyValueEntry
+	notify chan struct{}
+}
+
+// newKVWatcher returns a watcher of the keys matching any of the patterns,
+// and starts delivering its queued entries.
+func newKVWatcher(patterns []string) *kvWatcher {
+	w := &kvWatcher{
+		patterns: patterns,
+		updates:  make(chan jetstream.KeyValueEntry),
+		stopped:  make(chan struct{}),
+		notify:   make(chan struct{}, 1),
+	}
+	go w.deliver()
+	return w
+}
+
+// matches reports whether a key is watched.
+func (w *kvWatcher) matches(key string) bool {
+	return slices.ContainsFunc(w.patterns, func(pattern string) bool { return MatchSubject(pattern, key) })
+}
+
+// send queues an entry for delivery. A nil entry marks the end of the
+// initial entries.
+func (w *kvWatcher) send(entry *Entry) {
+	w.mu.Lock()
+	if entry == nil {
+		w.queue = append(w.queue, nil)
+	} else {
+		w.queue = append(w.queue, entry)
+	}
+	w.mu.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends the queued entries on the updates channel until stopped.
+func (w *kvWatcher) deliver() {
+	defer close(w.updates)
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			select {
+			case <-w.notify:
+				continue
+			case <-w.stopped:
+				return
+			}
+		}
+		entry := w.queue[0]
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+
+		select {
+		case w.updates <- entry:
+		case <-w.stopped:
+			return
+		}
+	}
+}
+
+func (w *kvWatcher) Updates() <-chan jetstream.KeyValueEntry { return w.updates }
+
+func (w *kvWatcher) Stop() error {
+	w.stopOnce.Do(func() { close(w.stopped) })
+	return nil
+}
+
+// MatchSubject reports whether a subject (or KV key) matches a NATS subject
+// pattern, where "*" matches a single token and a trailing ">" matches one or
+// more tokens.
+func MatchSubject(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return i == len(patternTokens)-1 && len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) {
+			return false
+		}
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
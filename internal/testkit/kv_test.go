@@ -0,0 +1,194 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package testkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestKVCreate(t *testing.T) {
+	ctx := context.Background()
+	kv := NewKV("test")
+
+	if _, err := kv.Create(ctx, "a", []byte("1")); err != nil {
+		t.Fatalf("create of a new key: %v", err)
+	}
+	if _, err := kv.Create(ctx, "a", []byte("2")); !errors.Is(err, jetstream.ErrKeyExists) {
+		t.Fatalf("create of an existing key: got %v, want ErrKeyExists", err)
+	}
+
+	if err := kv.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Create(ctx, "a", []byte("3")); err != nil {
+		t.Fatalf("create of a deleted key: %v", err)
+	}
+	entry, err := kv.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(entry.Value()) != "3" {
+		t.Fatalf("value after re-create: got %q, want %q", entry.Value(), "3")
+	}
+}
+
+func TestKVUpdate(t *testing.T) {
+	ctx := context.Background()
+	kv := NewKV("test")
+
+	revision, err := kv.Update(ctx, "a", []byte("1"), 0)
+	if err != nil {
+		t.Fatalf("update of a new key at revision 0: %v", err)
+	}
+	if _, err := kv.Update(ctx, "b", []byte("1"), revision); !isWrongLastSequence(err) {
+		t.Fatalf("update of a new key at revision %d: got %v, want wrong last sequence", revision, err)
+	}
+
+	// Writes to other keys move the bucket revision, but not the revision
+	// expected for this key.
+	if _, err := kv.Put(ctx, "c", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Update(ctx, "a", []byte("2"), revision+1); !isWrongLastSequence(err) {
+		t.Fatalf("update at a stale revision: got %v, want wrong last sequence", err)
+	}
+	next, err := kv.Update(ctx, "a", []byte("2"), revision)
+	if err != nil {
+		t.Fatalf("update at the latest revision: %v", err)
+	}
+	if next != revision+2 {
+		t.Fatalf("revision after update: got %d, want %d", next, revision+2)
+	}
+
+	// A delete marker is the latest revision of a deleted key.
+	if err := kv.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Update(ctx, "a", []byte("3"), next); !isWrongLastSequence(err) {
+		t.Fatalf("update of a deleted key at its last put: got %v, want wrong last sequence", err)
+	}
+}
+
+func TestKVHistory(t *testing.T) {
+	ctx := context.Background()
+	kv := NewKV("test")
+
+	if _, err := kv.History(ctx, "a"); !errors.Is(err, jetstream.ErrKeyNotFound) {
+		t.Fatalf("history of a new key: got %v, want ErrKeyNotFound", err)
+	}
+
+	first, _ := kv.Put(ctx, "a", []byte("1"))
+	second, _ := kv.Put(ctx, "a", []byte("2"))
+	if err := kv.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := kv.History(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		revision  uint64
+		operation jetstream.KeyValueOp
+		value     string
+	}{
+		{first, jetstream.KeyValuePut, "1"},
+		{second, jetstream.KeyValuePut, "2"},
+		{second + 1, jetstream.KeyValueDelete, ""},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history length: got %d, want %d", len(history), len(want))
+	}
+	for i, entry := range history {
+		if entry.Revision() != want[i].revision || entry.Operation() != want[i].operation || string(entry.Value()) != want[i].value {
+			t.Errorf("history[%d]: got revision %d %v %q, want revision %d %v %q", i,
+				entry.Revision(), entry.Operation(), entry.Value(),
+				want[i].revision, want[i].operation, want[i].value)
+		}
+	}
+}
+
+func TestKVTombstones(t *testing.T) {
+	ctx := context.Background()
+	kv := NewKV("test")
+
+	put, _ := kv.Put(ctx, "a", []byte("1"))
+	if _, err := kv.Put(ctx, "b", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	deleted := put + 2
+
+	if _, err := kv.Get(ctx, "a"); !errors.Is(err, jetstream.ErrKeyNotFound) {
+		t.Fatalf("get of a deleted key: got %v, want ErrKeyNotFound", err)
+	}
+	if _, err := kv.GetRevision(ctx, "a", deleted); !errors.Is(err, jetstream.ErrKeyDeleted) {
+		t.Fatalf("get of the delete revision: got %v, want ErrKeyDeleted", err)
+	}
+	if entry, err := kv.GetRevision(ctx, "a", put); err != nil || string(entry.Value()) != "1" {
+		t.Fatalf("get of the revision before the delete: got %v, %v", entry, err)
+	}
+	keys, err := kv.Keys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("keys with a deleted key: got %v, want [b]", keys)
+	}
+
+	// A purge leaves only its marker in the history.
+	if err := kv.Purge(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	history, err := kv.History(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Operation() != jetstream.KeyValuePurge {
+		t.Fatalf("history after purge: got %d entries, want a single purge marker", len(history))
+	}
+
+	// PurgeDeletes drops the keys whose latest entry is a marker.
+	if err := kv.PurgeDeletes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, err := kv.History(ctx, key); !errors.Is(err, jetstream.ErrKeyNotFound) {
+			t.Errorf("history of %s after PurgeDeletes: got %v, want ErrKeyNotFound", key, err)
+		}
+	}
+}
+
+func TestMatchSubject(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"a.b", "a.b", true},
+		{"a.*", "a.b", true},
+		{"a.*", "a.b.c", false},
+		{"a.>", "a.b.c", true},
+		{"a.>", "a", false},
+		{">", "a.b", true},
+		{"a.*.c", "a.b.d", false},
+	}
+	for _, tt := range tests {
+		if got := MatchSubject(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("MatchSubject(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+// isWrongLastSequence reports whether err is the JetStream error of a write
+// expecting another revision.
+func isWrongLastSequence(err error) bool {
+	var apiErr *jetstream.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence
+}
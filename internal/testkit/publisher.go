@@ -0,0 +1,85 @@
+// Copyright The Linux Foundation and each contributor to LFX.
+// SPDX-License-Identifier: MIT
+
+package testkit
+
+import (
+	"sync"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// MessagePublisher publishes NATS messages. It is implemented by *nats.Conn
+// and by the in-memory Publisher.
+type MessagePublisher interface {
+	PublishMsg(msg *nats.Msg) error
+}
+
+var (
+	_ MessagePublisher = (*nats.Conn)(nil)
+	_ MessagePublisher = (*Publisher)(nil)
+)
+
+// Publisher is an in-memory NATS publisher, recording the published messages
+// in order.
+type Publisher struct {
+	mu       sync.Mutex
+	messages []*nats.Msg
+	err      error
+}
+
+// NewPublisher returns an in-memory publisher.
+func NewPublisher() *Publisher {
+	return &Publisher{}
+}
+
+// PublishMsg records a message, or returns the error set with FailWith.
+func (p *Publisher) PublishMsg(msg *nats.Msg) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	recorded := &nats.Msg{Subject: msg.Subject, Reply: msg.Reply, Data: append([]byte(nil), msg.Data...)}
+	if msg.Header != nil {
+		recorded.Header = nats.Header{}
+		for name, values := range msg.Header {
+			recorded.Header[name] = append([]string(nil), values...)
+		}
+	}
+	p.messages = append(p.messages, recorded)
+	return nil
+}
+
+// Publish records a message with the given subject and data.
+func (p *Publisher) Publish(subject string, data []byte) error {
+	return p.PublishMsg(&nats.Msg{Subject: subject, Data: data})
+}
+
+// FailWith makes later publishes fail with err, or succeed again if nil.
+func (p *Publisher) FailWith(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Messages returns the recorded messages whose subject matches a NATS subject
+// pattern (">" for all of them), in publish order.
+func (p *Publisher) Messages(pattern string) []*nats.Msg {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var messages []*nats.Msg
+	for _, msg := range p.messages {
+		if MatchSubject(pattern, msg.Subject) {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// Reset forgets the recorded messages.
+func (p *Publisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = nil
+}